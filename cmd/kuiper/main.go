@@ -19,6 +19,9 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"net/rpc"
 	"os"
 	"sort"
@@ -36,9 +39,41 @@ import (
 type clientConf struct {
 	Host string `yaml:"host"`
 	Port int    `yaml:"port"`
+	// Token is the jwt token to access the server when the authentication is enabled
+	Token string `yaml:"token"`
 }
 
-const ClientYaml = "client.yaml"
+const (
+	ClientYaml = "client.yaml"
+	// TokenEnv is the environment variable of the token which overrides the one in client.yaml
+	TokenEnv = "KUIPER_TOKEN"
+)
+
+// dialRPC connects to the rpc server like rpc.DialHTTP and sends the token in the CONNECT request if set
+func dialRPC(address, token string) (*rpc.Client, error) {
+	if token == "" {
+		return rpc.DialHTTP("tcp", address)
+	}
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.WriteString(conn, "CONNECT "+rpc.DefaultRPCPath+" HTTP/1.0\nAuthorization: "+token+"\n\n")
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: "CONNECT"})
+	if err == nil && resp.Status == "200 Connected to Go RPC" {
+		return rpc.NewClient(conn), nil
+	}
+	if err == nil {
+		msg, _ := io.ReadAll(resp.Body)
+		err = fmt.Errorf("unexpected HTTP response: %s %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	_ = conn.Close()
+	return nil, err
+}
 
 func streamProcess(client *rpc.Client, args string) {
 	var reply string
@@ -109,11 +144,15 @@ func main() {
 		}
 	}
 
+	if t := os.Getenv(TokenEnv); t != "" {
+		config.Token = t
+	}
+
 	fmt.Printf("Connecting to %s... \n", cast.JoinHostPortInt(config.Host, config.Port))
 	// Create a TCP connection to localhost on port 1234
-	client, err := rpc.DialHTTP("tcp", cast.JoinHostPortInt(config.Host, config.Port))
+	client, err := dialRPC(cast.JoinHostPortInt(config.Host, config.Port), config.Token)
 	if err != nil {
-		fmt.Printf("Failed to connect the server, please start the server.\n")
+		fmt.Printf("Failed to connect the server, please start the server. Error: %v\n", err)
 		return
 	}
 
@@ -37,10 +37,50 @@ import (
 	_ "github.com/influxdata/influxdb1-client/v2"
 	client "github.com/influxdata/influxdb1-client/v2"
 
+	"github.com/lf-edge/ekuiper/internal/topo/connection/clients"
 	"github.com/lf-edge/ekuiper/internal/topo/transform"
 	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
 )
 
+func init() {
+	clients.RegisterConnectionFactory("influx", newConnection)
+}
+
+// connection is the influxdb client shared by the influx sinks with the same connectionSelector
+type connection struct {
+	cli client.Client
+}
+
+func newConnection(props map[string]interface{}) (clients.Connection, error) {
+	c := struct {
+		Addr     string `json:"addr"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}{}
+	if err := cast.MapToStruct(props, &c); err != nil {
+		return nil, err
+	}
+	cli, err := client.NewHTTPClient(client.HTTPConfig{
+		Addr:     c.Addr,
+		Username: c.Username,
+		Password: c.Password,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &connection{cli: cli}, nil
+}
+
+func (c *connection) Ping(_ api.StreamContext) error {
+	_, _, err := c.cli.Ping(0)
+	return err
+}
+
+func (c *connection) Close(_ api.StreamContext) error {
+	return c.cli.Close()
+}
+
 type influxSink struct {
 	addr         string
 	username     string
@@ -52,11 +92,19 @@ type influxSink struct {
 	dataField    string
 	fields       []string
 	cli          client.Client
+	// conn is the shared client if connectionSelector is set
+	conn         clients.Connection
+	selector     string
 	fieldMap     map[string]interface{}
 	hasTransform bool
 }
 
 func (m *influxSink) Configure(props map[string]interface{}) error {
+	if i, ok := props["connectionSelector"]; ok {
+		if i, ok := i.(string); ok {
+			m.selector = i
+		}
+	}
 	if i, ok := props["addr"]; ok {
 		if i, ok := i.(string); ok {
 			m.addr = i
@@ -117,6 +165,14 @@ func (m *influxSink) Configure(props map[string]interface{}) error {
 func (m *influxSink) Open(ctx api.StreamContext) (err error) {
 	logger := ctx.GetLogger()
 	logger.Debug("Opening influx sink")
+	if m.selector != "" {
+		m.conn, err = clients.GetConnection("influx", map[string]interface{}{"connectionSelector": m.selector})
+		if err != nil {
+			return err
+		}
+		m.cli = m.conn.(*connection).cli
+		return nil
+	}
 	m.cli, err = client.NewHTTPClient(client.HTTPConfig{
 		Addr:     m.addr,
 		Username: m.username,
@@ -190,6 +246,9 @@ func (m *influxSink) Collect(ctx api.StreamContext, data interface{}) error {
 }
 
 func (m *influxSink) Close(ctx api.StreamContext) error {
+	if m.conn != nil {
+		return clients.ReleaseConnection(ctx, m.conn)
+	}
 	m.cli.Close()
 	return nil
 }
@@ -34,26 +34,73 @@ import (
 	_ "github.com/influxdata/influxdb-client-go/v2"
 	client "github.com/influxdata/influxdb-client-go/v2"
 
+	"github.com/lf-edge/ekuiper/internal/topo/connection/clients"
 	"github.com/lf-edge/ekuiper/internal/topo/transform"
 	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
 )
 
+func init() {
+	clients.RegisterConnectionFactory("influx2", newConnection)
+}
+
+// connection is the influxdb2 client shared by the influx2 sinks with the same connectionSelector
+type connection struct {
+	cli client.Client
+}
+
+func newConnection(props map[string]interface{}) (clients.Connection, error) {
+	c := struct {
+		Addr  string `json:"addr"`
+		Token string `json:"token"`
+	}{}
+	if err := cast.MapToStruct(props, &c); err != nil {
+		return nil, err
+	}
+	options := client.DefaultOptions().SetBatchSize(100)
+	return &connection{cli: client.NewClientWithOptions(c.Addr, c.Token, options)}, nil
+}
+
+func (c *connection) Ping(ctx api.StreamContext) error {
+	ok, err := c.cli.Ping(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("influxdb2 %s is not ready", c.cli.ServerURL())
+	}
+	return nil
+}
+
+func (c *connection) Close(_ api.StreamContext) error {
+	c.cli.Close()
+	return nil
+}
+
 type influxSink2 struct {
-	addr         string
-	token        string
-	measurement  string
-	org          string
-	bucket       string
-	tagKey       string
-	tagValue     string
-	dataField    string
-	fields       []string
-	cli          client.Client
+	addr        string
+	token       string
+	measurement string
+	org         string
+	bucket      string
+	tagKey      string
+	tagValue    string
+	dataField   string
+	fields      []string
+	cli         client.Client
+	// conn is the shared client if connectionSelector is set
+	conn         clients.Connection
+	selector     string
 	fieldMap     map[string]interface{}
 	hasTransform bool
 }
 
 func (m *influxSink2) Configure(props map[string]interface{}) error {
+	if i, ok := props["connectionSelector"]; ok {
+		if i, ok := i.(string); ok {
+			m.selector = i
+		}
+	}
 	if i, ok := props["addr"]; ok {
 		if i, ok := i.(string); ok {
 			m.addr = i
@@ -116,6 +163,14 @@ func (m *influxSink2) Configure(props map[string]interface{}) error {
 func (m *influxSink2) Open(ctx api.StreamContext) (err error) {
 	logger := ctx.GetLogger()
 	logger.Debug("Opening influx2 sink")
+	if m.selector != "" {
+		m.conn, err = clients.GetConnection("influx2", map[string]interface{}{"connectionSelector": m.selector})
+		if err != nil {
+			return err
+		}
+		m.cli = m.conn.(*connection).cli
+		return nil
+	}
 	options := client.DefaultOptions().SetBatchSize(100)
 	m.cli = client.NewClientWithOptions(m.addr, m.token, options)
 	return nil
@@ -172,6 +227,9 @@ func (m *influxSink2) Collect(ctx api.StreamContext, data interface{}) error {
 }
 
 func (m *influxSink2) Close(ctx api.StreamContext) error {
+	if m.conn != nil {
+		return clients.ReleaseConnection(ctx, m.conn)
+	}
 	m.cli.Close()
 	return nil
 }
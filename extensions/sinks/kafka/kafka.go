@@ -23,6 +23,9 @@ import (
 	"github.com/segmentio/kafka-go/sasl/plain"
 	"github.com/segmentio/kafka-go/sasl/scram"
 
+	"github.com/lf-edge/ekuiper/extensions/util"
+	"github.com/lf-edge/ekuiper/internal/pkg/tracer"
+	"github.com/lf-edge/ekuiper/internal/topo/connection/clients"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/cast"
 	"github.com/lf-edge/ekuiper/pkg/errorx"
@@ -31,6 +34,8 @@ import (
 type kafkaSink struct {
 	writer *kafkago.Writer
 	c      *sinkConf
+	// conn is the shared connection if connectionSelector is set
+	conn clients.Connection
 }
 
 const (
@@ -45,6 +50,8 @@ type sinkConf struct {
 	SaslAuthType string `json:"saslAuthType"`
 	SaslUserName string `json:"saslUserName"`
 	SaslPassword string `json:"saslPassword"`
+	// Use the shared brokers in connection.yaml instead of the brokers and sasl properties
+	ConnectionSelector string `json:"connectionSelector"`
 }
 
 func (m *kafkaSink) Configure(props map[string]interface{}) error {
@@ -76,6 +83,17 @@ func (m *kafkaSink) Configure(props map[string]interface{}) error {
 func (m *kafkaSink) Open(ctx api.StreamContext) error {
 	ctx.GetLogger().Debug("Opening kafka sink")
 
+	if m.c.ConnectionSelector != "" {
+		conn, err := clients.GetConnection("kafka", map[string]interface{}{"connectionSelector": m.c.ConnectionSelector})
+		if err != nil {
+			return err
+		}
+		kc := conn.(*util.KafkaConnection)
+		m.conn = conn
+		m.writer = m.newWriter(kc.Brokers, kc.Transport)
+		return nil
+	}
+
 	var err error
 	var mechanism sasl.Mechanism
 
@@ -95,7 +113,14 @@ func (m *kafkaSink) Open(ctx api.StreamContext) error {
 		mechanism = nil
 	}
 	brokers := strings.Split(m.c.Brokers, ",")
-	w := &kafkago.Writer{
+	m.writer = m.newWriter(brokers, &kafkago.Transport{
+		SASL: mechanism,
+	})
+	return nil
+}
+
+func (m *kafkaSink) newWriter(brokers []string, transport *kafkago.Transport) *kafkago.Writer {
+	return &kafkago.Writer{
 		Addr:                   kafkago.TCP(brokers...),
 		Topic:                  m.c.Topic,
 		Balancer:               &kafkago.LeastBytes{},
@@ -104,12 +129,8 @@ func (m *kafkaSink) Open(ctx api.StreamContext) error {
 		MaxAttempts:            1,
 		RequiredAcks:           -1,
 		BatchSize:              1,
-		Transport: &kafkago.Transport{
-			SASL: mechanism,
-		},
+		Transport:              transport,
 	}
-	m.writer = w
-	return nil
 }
 
 func (m *kafkaSink) Collect(ctx api.StreamContext, item interface{}) error {
@@ -134,6 +155,16 @@ func (m *kafkaSink) Collect(ctx api.StreamContext, item interface{}) error {
 	default:
 		return fmt.Errorf("unrecognized format of %s", item)
 	}
+	// Propagate the trace context of the data to the consumers
+	if tc := tracer.InjectMap(ctx); tc != nil {
+		headers := make([]kafkago.Header, 0, len(tc))
+		for k, v := range tc {
+			headers = append(headers, kafkago.Header{Key: k, Value: []byte(v)})
+		}
+		for i := range messages {
+			messages[i].Headers = headers
+		}
+	}
 
 	err := m.writer.WriteMessages(ctx, messages...)
 	switch err := err.(type) {
@@ -163,7 +194,11 @@ func (m *kafkaSink) Collect(ctx api.StreamContext, item interface{}) error {
 }
 
 func (m *kafkaSink) Close(ctx api.StreamContext) error {
-	return m.writer.Close()
+	err := m.writer.Close()
+	if m.conn != nil {
+		_ = clients.ReleaseConnection(ctx, m.conn)
+	}
+	return err
 }
 
 func Kafka() api.Sink {
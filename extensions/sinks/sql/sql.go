@@ -15,14 +15,22 @@
 package main
 
 import (
+	"database/sql"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/lf-edge/ekuiper/extensions/sqldatabase"
 	"github.com/lf-edge/ekuiper/extensions/sqldatabase/driver"
+	"github.com/lf-edge/ekuiper/extensions/sqldatabase/sqlgen"
 	"github.com/lf-edge/ekuiper/extensions/util"
+	"github.com/lf-edge/ekuiper/internal/topo/connection/clients"
 	"github.com/lf-edge/ekuiper/internal/topo/transform"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/ast"
@@ -39,64 +47,132 @@ type sqlConfig struct {
 	DataField      string   `json:"dataField"`
 	RowkindField   string   `json:"rowkindField"`
 	KeyField       string   `json:"keyField"`
+	// Write the inserted rows by upsert against the keyField
+	Upsert bool `json:"upsert"`
+	// Create the table by the columns of the data if not exists
+	CreateTable bool `json:"createTable"`
+	// The times to retry writing a batch when it fails
+	RetryCount int `json:"retryCount"`
+	// The interval in milliseconds between the retries
+	RetryInterval int `json:"retryInterval"`
+	// The table to record the committed checkpoint of each sink for exactly once
+	TransactionTable string `json:"transactionTable"`
+	// Use the shared database connection in connection.yaml instead of url
+	ConnectionSelector string `json:"connectionSelector"`
 }
 
-func (t *sqlConfig) buildInsertSql(ctx api.StreamContext, mapData map[string]interface{}) ([]string, string, error) {
-	keys, vals, err := t.getKeyValues(ctx, mapData)
-	if err != nil {
-		return keys, "", err
+// quote quotes the column name for dm
+func (t *sqlConfig) quote(column string) string {
+	if column != "" && strings.HasPrefix(strings.ToLower(t.Url), "dm://") {
+		return fmt.Sprintf(`"%v"`, column)
 	}
-	if strings.HasPrefix(strings.ToLower(t.Url), "dm://") {
-		for i, key := range keys {
-			keys[i] = fmt.Sprintf(`"%v"`, key)
+	return column
+}
+
+func (t *sqlConfig) quoteColumns(columns []string) []string {
+	result := make([]string, len(columns))
+	for i, c := range columns {
+		result[i] = t.quote(c)
+	}
+	return result
+}
+
+// columns returns the fields if set, otherwise the sorted keys of the data except the rowkind field
+func (t *sqlConfig) columns(mapData map[string]interface{}) []string {
+	if len(t.Fields) != 0 {
+		return t.Fields
+	}
+	keys := make([]string, 0, len(mapData))
+	for k := range mapData {
+		if k != t.RowkindField {
+			keys = append(keys, k)
 		}
 	}
-	sqlStr := "(" + strings.Join(vals, ",") + ")"
-	return keys, sqlStr, nil
+	sort.Strings(keys)
+	return keys
+}
+
+// rowValues returns the values of the columns in the data as sql literals
+func (t *sqlConfig) rowValues(ctx api.StreamContext, columns []string, mapData map[string]interface{}) []string {
+	vals := make([]string, len(columns))
+	for i, k := range columns {
+		v, ok := mapData[k]
+		if !ok {
+			ctx.GetLogger().Warnln("not found field:", k)
+		}
+		vals[i] = sqlValue(v)
+	}
+	return vals
 }
 
 func (t *sqlConfig) getKeyValues(ctx api.StreamContext, mapData map[string]interface{}) ([]string, []string, error) {
 	if 0 == len(mapData) {
 		return nil, nil, fmt.Errorf("data is empty.")
 	}
-	logger := ctx.GetLogger()
-	var keys, vals []string
+	keys := t.columns(mapData)
+	return keys, t.rowValues(ctx, keys, mapData), nil
+}
 
-	if len(t.Fields) != 0 {
-		for _, k := range t.Fields {
-			keys = append(keys, k)
-			if v, ok := mapData[k]; ok && v != nil {
-				if reflect.String == reflect.TypeOf(v).Kind() {
-					vals = append(vals, fmt.Sprintf("'%v'", v))
-				} else {
-					vals = append(vals, fmt.Sprintf(`%v`, v))
-				}
-			} else {
-				logger.Warnln("not found field:", k)
-				vals = append(vals, fmt.Sprintf(`NULL`))
-			}
-		}
-	} else {
-		for k, v := range mapData {
-			keys = append(keys, k)
-			if reflect.String == reflect.TypeOf(v).Kind() {
-				vals = append(vals, fmt.Sprintf("'%v'", v))
-			} else {
-				vals = append(vals, fmt.Sprintf(`%v`, v))
-			}
+// sqlValue formats the value as a sql literal
+func sqlValue(v interface{}) string {
+	switch vt := v.(type) {
+	case nil:
+		return "NULL"
+	case time.Time:
+		return fmt.Sprintf("'%s'", vt.Format("2006-01-02 15:04:05.000"))
+	case []byte:
+		return fmt.Sprintf("'%s'", base64.StdEncoding.EncodeToString(vt))
+	case map[string]interface{}, []interface{}:
+		if b, err := json.Marshal(vt); err == nil {
+			return quoteString(string(b))
 		}
 	}
-	return keys, vals, nil
+	if reflect.String == reflect.TypeOf(v).Kind() {
+		return quoteString(fmt.Sprintf("%v", v))
+	}
+	return fmt.Sprintf(`%v`, v)
+}
+
+func quoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// columnType infers the type of the column to create by the value
+func columnType(v interface{}) sqlgen.ColumnType {
+	switch v.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return sqlgen.BigintColumn
+	case float32, float64:
+		return sqlgen.FloatColumn
+	case bool:
+		return sqlgen.BooleanColumn
+	case time.Time:
+		return sqlgen.DatetimeColumn
+	default:
+		return sqlgen.StringColumn
+	}
 }
 
 type sqlSink struct {
 	conf *sqlConfig
 	// The db connection instance
 	db sqldatabase.DB
+	// The shared connection if connectionSelector is set
+	conn clients.Connection
+	// two-phase commit mode, the statements are buffered and executed in a db transaction when committing
+	tx     bool
+	buffer []string
+	// the driver parsed from the url to decide the sql dialect
+	driver string
+	// the tables which are created if createTable is set
+	created map[string]bool
 }
 
 func (m *sqlSink) Configure(props map[string]interface{}) error {
-	cfg := &sqlConfig{}
+	cfg := &sqlConfig{
+		RetryInterval:    1000,
+		TransactionTable: "ekuiper_sink_transaction",
+	}
 	err := cast.MapToStruct(props, cfg)
 	if err != nil {
 		return fmt.Errorf("read properties %v fail with error: %v", props, err)
@@ -104,6 +180,12 @@ func (m *sqlSink) Configure(props map[string]interface{}) error {
 	if cfg.DataField == "" {
 		cfg.DataField = cfg.TableDataField
 	}
+	if cfg.ConnectionSelector != "" {
+		cfg.Url, err = util.ConnectionUrl(cfg.ConnectionSelector)
+		if err != nil {
+			return err
+		}
+	}
 	if cfg.Url == "" {
 		return fmt.Errorf("property Url is required")
 	}
@@ -113,6 +195,22 @@ func (m *sqlSink) Configure(props map[string]interface{}) error {
 	if cfg.RowkindField != "" && cfg.KeyField == "" {
 		return fmt.Errorf("keyField is required when rowkindField is set")
 	}
+	if cfg.Upsert && cfg.KeyField == "" {
+		return fmt.Errorf("keyField is required when upsert is set")
+	}
+	if cfg.RetryCount < 0 {
+		return fmt.Errorf("invalid retryCount %d, must not be negative", cfg.RetryCount)
+	}
+	if cfg.RetryInterval < 0 {
+		return fmt.Errorf("invalid retryInterval %d, must not be negative", cfg.RetryInterval)
+	}
+	if !tableNameRegex.MatchString(cfg.TransactionTable) {
+		return fmt.Errorf("invalid transactionTable %s", cfg.TransactionTable)
+	}
+	m.driver, err = util.ParseDriver(cfg.Url)
+	if err != nil {
+		return err
+	}
 	m.conf = cfg
 	return nil
 }
@@ -120,27 +218,76 @@ func (m *sqlSink) Configure(props map[string]interface{}) error {
 func (m *sqlSink) Open(ctx api.StreamContext) (err error) {
 	logger := ctx.GetLogger()
 	logger.Debugf("Opening sql sink")
-	db, err := util.FetchDBToOneNode(util.GlobalPool, m.conf.Url)
+	conn, db, err := util.FetchDB(m.conf.ConnectionSelector, m.conf.Url)
 	if err != nil {
 		logger.Errorf("support build tags are %v", driver.KnownBuildTags())
 		return err
 	}
+	m.conn = conn
 	m.db = db
+	m.created = make(map[string]bool)
 	return
 }
 
-func (m *sqlSink) writeToDB(ctx api.StreamContext, sqlStr *string) error {
-	ctx.GetLogger().Debugf(*sqlStr)
-	r, err := m.db.Exec(*sqlStr)
+// writeToDB writes the statements of a batch. If it fails, the whole batch is retried.
+func (m *sqlSink) writeToDB(ctx api.StreamContext, stmts ...string) error {
+	if m.tx {
+		for _, stmt := range stmts {
+			ctx.GetLogger().Debugf(stmt)
+		}
+		m.buffer = append(m.buffer, stmts...)
+		return nil
+	}
+	err := m.exec(ctx, stmts)
+	for i := 0; err != nil && i < m.conf.RetryCount; i++ {
+		ctx.GetLogger().Warnf("sql sink write error: %v, retry %d", err, i+1)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %s", errorx.IOErr, err.Error())
+		case <-time.After(time.Duration(m.conf.RetryInterval) * time.Millisecond):
+		}
+		err = m.exec(ctx, stmts)
+	}
 	if err != nil {
 		return fmt.Errorf("%s: %s", errorx.IOErr, err.Error())
 	}
-	d, err := r.RowsAffected()
+	return nil
+}
+
+// exec executes the statements in one db transaction, so that the batch is never partially written
+func (m *sqlSink) exec(ctx api.StreamContext, stmts []string) (err error) {
+	db, ok := m.db.(*sql.DB)
+	if !ok || len(stmts) == 1 {
+		for _, stmt := range stmts {
+			ctx.GetLogger().Debugf(stmt)
+			r, err := m.db.Exec(stmt)
+			if err != nil {
+				return err
+			}
+			d, err := r.RowsAffected()
+			if err != nil {
+				ctx.GetLogger().Errorf("get rows affected error: %s", err.Error())
+			}
+			ctx.GetLogger().Debugf("Rows affected: %d", d)
+		}
+		return nil
+	}
+	tx, err := db.Begin()
 	if err != nil {
-		ctx.GetLogger().Errorf("get rows affected error: %s", err.Error())
+		return err
 	}
-	ctx.GetLogger().Debugf("Rows affected: %d", d)
-	return nil
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	for _, stmt := range stmts {
+		ctx.GetLogger().Debugf(stmt)
+		if _, err = tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
 func (m *sqlSink) Collect(ctx api.StreamContext, item interface{}) error {
@@ -157,189 +304,322 @@ func (m *sqlSink) Collect(ctx api.StreamContext, item interface{}) error {
 		}
 		item = tm
 	} else {
-		tm, _, err := transform.TransItem(item, m.conf.DataField, m.conf.Fields)
+		fields := m.conf.Fields
+		if len(fields) > 0 && m.conf.RowkindField != "" {
+			// Keep the rowkind field to decide the statement. It is not written because the columns are the fields.
+			fields = append(append(make([]string, 0, len(fields)+1), fields...), m.conf.RowkindField)
+		}
+		tm, _, err := transform.TransItem(item, m.conf.DataField, fields)
 		if err != nil {
 			return fmt.Errorf("fail to 1 transform data %v for error %v!!!!!", item, err)
 		}
 		item = tm
 	}
 
-	var (
-		table string
-		err   error
-	)
+	var rows []map[string]interface{}
 	switch v := item.(type) {
 	case map[string]interface{}:
-		table, err = ctx.ParseTemplate(m.conf.Table, v)
-		if err != nil {
-			ctx.GetLogger().Errorf("parse template for table %s error: %v", m.conf.Table, err)
-			return err
-		}
+		rows = []map[string]interface{}{v}
 	case []map[string]interface{}:
-		if len(v) == 0 {
-			ctx.GetLogger().Warnf("empty data array")
-			return nil
-		}
-		table, err = ctx.ParseTemplate(m.conf.Table, v[0])
-		if err != nil {
-			ctx.GetLogger().Errorf("parse template for table %s error: %v", m.conf.Table, err)
-			return err
+		rows = v
+	case []interface{}:
+		rows = make([]map[string]interface{}, 0, len(v))
+		for _, data := range v {
+			mapData, ok := data.(map[string]interface{})
+			if !ok {
+				ctx.GetLogger().Errorf("unsupported type: %T", data)
+				return fmt.Errorf("unsupported type: %T", data)
+			}
+			rows = append(rows, mapData)
 		}
+	default: // never happen
+		return fmt.Errorf("unsupported type: %T", item)
+	}
+	if len(rows) == 0 {
+		ctx.GetLogger().Warnf("empty data array")
+		return nil
 	}
+	table, err := ctx.ParseTemplate(m.conf.Table, rows[0])
+	if err != nil {
+		ctx.GetLogger().Errorf("parse template for table %s error: %v", m.conf.Table, err)
+		return err
+	}
+	if err := m.createTable(ctx, table, rows); err != nil {
+		return err
+	}
+	return m.save(ctx, table, rows)
+}
 
-	var keys []string = nil
-	var values []string = nil
-	var vars string
+func (m *sqlSink) Close(ctx api.StreamContext) error {
+	if m.db != nil {
+		return util.ReturnDB(ctx, m.conn, m.conf.Url)
+	}
+	return nil
+}
 
-	if m.conf.RowkindField == "" {
-		switch v := item.(type) {
-		case []map[string]interface{}:
-			for _, mapData := range v {
-				keys, vars, err = m.conf.buildInsertSql(ctx, mapData)
-				if err != nil {
-					return err
-				}
-				values = append(values, vars)
-			}
-			if keys != nil {
-				sqlStr := fmt.Sprintf("INSERT INTO %s (%s) values ", table, strings.Join(keys, ",")) + strings.Join(values, ",") + ";"
-				return m.writeToDB(ctx, &sqlStr)
-			}
-			return nil
-		case map[string]interface{}:
-			keys, vars, err = m.conf.buildInsertSql(ctx, v)
-			if err != nil {
-				return err
-			}
-			values = append(values, vars)
-			if keys != nil {
-				sqlStr := fmt.Sprintf("INSERT INTO %s (%s) values ", table, strings.Join(keys, ",")) + strings.Join(values, ",") + ";"
-				return m.writeToDB(ctx, &sqlStr)
+// createTable creates the table by the columns of the rows once if createTable is set. The type of each column is
+// inferred from its first non-null value, which follows the type of the field in the stream schema.
+func (m *sqlSink) createTable(ctx api.StreamContext, table string, rows []map[string]interface{}) error {
+	if !m.conf.CreateTable || m.created[table] {
+		return nil
+	}
+	names := m.conf.Fields
+	if len(names) == 0 {
+		all := make(map[string]interface{})
+		for _, row := range rows {
+			for k := range row {
+				all[k] = nil
 			}
-			return nil
-		case []interface{}:
-			for _, data := range v {
-				mapData, ok := data.(map[string]interface{})
-				if !ok {
-					ctx.GetLogger().Errorf("unsupported type: %T", data)
-					return fmt.Errorf("unsupported type: %T", data)
-				}
-
-				keys, vars, err = m.conf.buildInsertSql(ctx, mapData)
-				if err != nil {
-					ctx.GetLogger().Errorf("sql sink build sql error %v for data", err, mapData)
-					return err
-				}
-				values = append(values, vars)
+		}
+		names = m.conf.columns(all)
+	}
+	columns := make([]sqlgen.Column, len(names))
+	for i, name := range names {
+		t := sqlgen.StringColumn
+		for _, row := range rows {
+			if v, ok := row[name]; ok && v != nil {
+				t = columnType(v)
+				break
 			}
+		}
+		columns[i] = sqlgen.Column{Name: m.conf.quote(name), Type: t}
+	}
+	stmt := sqlgen.GetWriteDialect(m.driver).CreateTableStatement(table, columns, m.conf.quote(m.conf.KeyField))
+	ctx.GetLogger().Infof("create table %s if not exists: %s", table, stmt)
+	if _, err := m.db.Exec(stmt); err != nil {
+		return fmt.Errorf("%s: fail to create table %s: %v", errorx.IOErr, table, err)
+	}
+	m.created[table] = true
+	return nil
+}
 
-			if keys != nil {
-				sqlStr := fmt.Sprintf("INSERT INTO %s (%s) values ", table, strings.Join(keys, ",")) + strings.Join(values, ",") + ";"
-				return m.writeToDB(ctx, &sqlStr)
-			}
-			return nil
-		default: // never happen
-			return fmt.Errorf("unsupported type: %T", item)
+// save writes the rows in order as a batch. The consecutive inserted rows with the same columns are written in one
+// statement. If rowkindField is set, the rows with invalid rowkind or without the key are not written, and the error
+// is returned after writing the other rows.
+func (m *sqlSink) save(ctx api.StreamContext, table string, rows []map[string]interface{}) error {
+	dialect := sqlgen.GetWriteDialect(m.driver)
+	var (
+		stmts   []string
+		columns []string
+		values  [][]string
+		upsert  bool
+		// invalid is the error of the first invalid row and skipped is the number of them
+		invalid error
+		skipped int
+	)
+	flush := func() {
+		if len(values) == 0 {
+			return
 		}
-	} else {
-		switch d := item.(type) {
-		case []map[string]interface{}:
-			for _, el := range d {
-				err := m.save(ctx, table, el)
-				if err != nil {
-					ctx.GetLogger().Error(err)
+		if upsert {
+			stmts = append(stmts, dialect.UpsertStatement(table, m.conf.quoteColumns(columns), values, m.conf.quote(m.conf.KeyField)))
+		} else {
+			stmts = append(stmts, dialect.InsertStatement(table, m.conf.quoteColumns(columns), values))
+		}
+		values = nil
+	}
+	for _, data := range rows {
+		if len(data) == 0 {
+			return fmt.Errorf("data is empty.")
+		}
+		rowkind := ast.RowkindInsert
+		if m.conf.RowkindField != "" {
+			var err error
+			rowkind, err = m.rowkind(data)
+			if err == nil && rowkind != ast.RowkindInsert && rowkind != ast.RowkindUpsert {
+				var stmt string
+				stmt, err = m.updateStatement(ctx, table, rowkind, data)
+				if err == nil {
+					flush()
+					stmts = append(stmts, stmt)
+					continue
 				}
 			}
-		case map[string]interface{}:
-			err := m.save(ctx, table, d)
 			if err != nil {
-				return err
-			}
-		case []interface{}:
-			for _, vv := range d {
-				el, ok := vv.(map[string]interface{})
-				if !ok {
-					ctx.GetLogger().Errorf("unsupported type: %T", vv)
-					return fmt.Errorf("unsupported type: %T", vv)
-				}
-				err := m.save(ctx, table, el)
-				if err != nil {
-					ctx.GetLogger().Error(err)
+				if invalid == nil {
+					invalid = err
 				}
+				skipped++
+				continue
 			}
-		default:
-			return fmt.Errorf("unrecognized format of %s", item)
 		}
-		return nil
+		isUpsert := m.conf.Upsert || rowkind == ast.RowkindUpsert
+		cols := m.conf.columns(data)
+		if isUpsert != upsert || !reflect.DeepEqual(cols, columns) {
+			flush()
+		}
+		columns, upsert = cols, isUpsert
+		values = append(values, m.conf.rowValues(ctx, cols, data))
 	}
-}
-
-func (m *sqlSink) Close(_ api.StreamContext) error {
-	if m.db != nil {
-		return util.ReturnDBFromOneNode(util.GlobalPool, m.conf.Url)
+	flush()
+	if len(stmts) > 0 {
+		if err := m.writeToDB(ctx, stmts...); err != nil {
+			return err
+		}
+	}
+	if invalid != nil {
+		if len(rows) == 1 {
+			return invalid
+		}
+		return fmt.Errorf("%d of %d rows are not written, the first error: %v", skipped, len(rows), invalid)
 	}
 	return nil
 }
 
-// save save updatable data only to db
-func (m *sqlSink) save(ctx api.StreamContext, table string, data map[string]interface{}) error {
-	rowkind := ast.RowkindInsert
+func (m *sqlSink) rowkind(data map[string]interface{}) (string, error) {
 	c, ok := data[m.conf.RowkindField]
-	if ok {
-		rowkind, ok = c.(string)
-		if !ok {
-			return fmt.Errorf("rowkind field %s is not a string in data %v", m.conf.RowkindField, data)
-		}
-		if rowkind != ast.RowkindInsert && rowkind != ast.RowkindUpdate && rowkind != ast.RowkindDelete {
-			return fmt.Errorf("invalid rowkind %s", rowkind)
-		}
+	if !ok || c == nil {
+		return ast.RowkindInsert, nil
+	}
+	rowkind, ok := c.(string)
+	if !ok {
+		return "", fmt.Errorf("rowkind field %s is not a string in data %v", m.conf.RowkindField, data)
+	}
+	if rowkind != ast.RowkindInsert && rowkind != ast.RowkindUpdate && rowkind != ast.RowkindDelete && rowkind != ast.RowkindUpsert {
+		return "", fmt.Errorf("invalid rowkind %s", rowkind)
+	}
+	return rowkind, nil
+}
+
+// updateStatement builds the statement to update or delete the row by the keyField
+func (m *sqlSink) updateStatement(ctx api.StreamContext, table string, rowkind string, data map[string]interface{}) (string, error) {
+	keyval, ok := data[m.conf.KeyField]
+	if !ok {
+		return "", fmt.Errorf("field %s does not exist in data %v", m.conf.KeyField, data)
 	}
-	var sqlStr string
 	switch rowkind {
-	case ast.RowkindInsert:
-		keys, vars, err := m.conf.buildInsertSql(ctx, data)
-		if err != nil {
-			return err
-		}
-		values := []string{vars}
-		if keys != nil {
-			sqlStr = fmt.Sprintf("INSERT INTO %s (%s) values ", table, strings.Join(keys, ",")) + strings.Join(values, ",") + ";"
-		}
 	case ast.RowkindUpdate:
-		keyval, ok := data[m.conf.KeyField]
-		if !ok {
-			return fmt.Errorf("field %s does not exist in data %v", m.conf.KeyField, data)
-		}
 		keys, vals, err := m.conf.getKeyValues(ctx, data)
 		if err != nil {
-			return err
+			return "", err
 		}
-		sqlStr = fmt.Sprintf("UPDATE %s SET ", table)
+		sqlStr := fmt.Sprintf("UPDATE %s SET ", table)
 		for i, key := range keys {
 			if i != 0 {
 				sqlStr += ","
 			}
 			sqlStr += fmt.Sprintf("%s=%s", key, vals[i])
 		}
-		if _, ok := keyval.(string); ok {
-			sqlStr += fmt.Sprintf(" WHERE %s = '%s';", m.conf.KeyField, keyval)
-		} else {
-			sqlStr += fmt.Sprintf(" WHERE %s = %v;", m.conf.KeyField, keyval)
-		}
+		return sqlStr + fmt.Sprintf(" WHERE %s = %s;", m.conf.KeyField, sqlValue(keyval)), nil
 	case ast.RowkindDelete:
-		keyval, ok := data[m.conf.KeyField]
-		if !ok {
-			return fmt.Errorf("field %s does not exist in data %v", m.conf.KeyField, data)
+		return fmt.Sprintf("DELETE FROM %s WHERE %s = %s;", table, m.conf.KeyField, sqlValue(keyval)), nil
+	default:
+		return "", fmt.Errorf("invalid rowkind %s", rowkind)
+	}
+}
+
+// The sql sink implements api.TwoPhaseCommitSink by write-ahead. The statements of a transaction are buffered and
+// saved in the checkpoint state when pre-committing. When committing, they are executed in one db transaction together
+// with the update of the last committed checkpoint id in the transaction table, so that commit is idempotent.
+const txStatementsKey = "$$sqlTransaction_"
+
+var _ api.TwoPhaseCommitSink = &sqlSink{}
+
+var tableNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+func txSinkId(ctx api.StreamContext) string {
+	return fmt.Sprintf("%s_%s", ctx.GetRuleId(), ctx.GetOpId())
+}
+
+// bindVar returns the placeholder of the ith (starts from 1) parameter of a query for the driver
+func bindVar(driver string, i int) string {
+	switch driver {
+	case "postgres", "pgx":
+		return fmt.Sprintf("$%d", i)
+	case "sqlserver":
+		return fmt.Sprintf("@p%d", i)
+	case "oracle", "godror":
+		return fmt.Sprintf(":%d", i)
+	default:
+		return "?"
+	}
+}
+
+func (m *sqlSink) Recover(ctx api.StreamContext, pending []int64) error {
+	if _, ok := m.db.(*sql.DB); !ok {
+		return fmt.Errorf("sql sink does not support transaction for db %s", m.conf.Url)
+	}
+	// The table may be created manually if the db does not support this syntax
+	_, err := m.db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (sink_id VARCHAR(255) NOT NULL PRIMARY KEY, checkpoint_id BIGINT NOT NULL)", m.conf.TransactionTable))
+	if err != nil {
+		ctx.GetLogger().Warnf("fail to create transaction table %s: %v", m.conf.TransactionTable, err)
+	}
+	m.tx = true
+	m.buffer = nil
+	// The statements not pre-committed are never written to the db, so just commit the pending ones
+	for _, cid := range pending {
+		if err := m.Commit(ctx, cid); err != nil {
+			return err
 		}
-		if _, ok := keyval.(string); ok {
-			sqlStr = fmt.Sprintf("DELETE FROM %s WHERE %s = '%s';", table, m.conf.KeyField, keyval)
-		} else {
-			sqlStr = fmt.Sprintf("DELETE FROM %s WHERE %s = %v;", table, m.conf.KeyField, keyval)
+	}
+	return nil
+}
+
+func (m *sqlSink) PreCommit(ctx api.StreamContext, checkpointId int64) error {
+	if len(m.buffer) > 0 {
+		if err := ctx.PutState(fmt.Sprintf("%s%d", txStatementsKey, checkpointId), m.buffer); err != nil {
+			return err
+		}
+	}
+	m.buffer = nil
+	return nil
+}
+
+func (m *sqlSink) Commit(ctx api.StreamContext, checkpointId int64) error {
+	key := fmt.Sprintf("%s%d", txStatementsKey, checkpointId)
+	s, err := ctx.GetState(key)
+	if err != nil {
+		return err
+	}
+	stmts, _ := s.([]string)
+	if len(stmts) > 0 {
+		if err := m.commitTx(ctx, checkpointId, stmts); err != nil {
+			return fmt.Errorf("%s: %s", errorx.IOErr, err.Error())
+		}
+	}
+	return ctx.DeleteState(key)
+}
+
+func (m *sqlSink) commitTx(ctx api.StreamContext, checkpointId int64, stmts []string) (err error) {
+	tx, err := m.db.(*sql.DB).Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
 		}
+	}()
+	sinkId := txSinkId(ctx)
+	var last int64
+	// The rule id is set by the user, so always bind it as a parameter
+	err = tx.QueryRow(fmt.Sprintf("SELECT checkpoint_id FROM %s WHERE sink_id = %s", m.conf.TransactionTable, bindVar(m.driver, 1)), sinkId).Scan(&last)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		_, err = tx.Exec(fmt.Sprintf("INSERT INTO %s (sink_id, checkpoint_id) VALUES (%s, %s)", m.conf.TransactionTable, bindVar(m.driver, 1), bindVar(m.driver, 2)), sinkId, checkpointId)
+	case err != nil:
+		return err
+	case last >= checkpointId:
+		ctx.GetLogger().Infof("sql sink transaction %d is already committed", checkpointId)
+		return tx.Rollback()
 	default:
-		return fmt.Errorf("invalid rowkind %s", rowkind)
+		_, err = tx.Exec(fmt.Sprintf("UPDATE %s SET checkpoint_id = %s WHERE sink_id = %s", m.conf.TransactionTable, bindVar(m.driver, 1), bindVar(m.driver, 2)), checkpointId, sinkId)
+	}
+	if err != nil {
+		return err
 	}
-	return m.writeToDB(ctx, &sqlStr)
+	for _, stmt := range stmts {
+		ctx.GetLogger().Debugf(stmt)
+		if _, err = tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (m *sqlSink) Abort(_ api.StreamContext) error {
+	m.buffer = nil
+	return nil
 }
 
 func Sql() api.Sink {
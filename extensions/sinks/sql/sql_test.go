@@ -20,13 +20,18 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/lf-edge/ekuiper/extensions/sqldatabase"
 	econf "github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
 )
 
 func TestSingle(t *testing.T) {
@@ -291,13 +296,226 @@ func TestSaveSql(t *testing.T) {
 	}
 	for _, tt := range test {
 		t.Run(tt.name, func(t *testing.T) {
-			err := s.save(ctx, "test", tt.d)
+			err := s.save(ctx, "test", []map[string]interface{}{tt.d})
 			assert.NoError(t, err)
 			assert.Equal(t, tt.s, mdb.LastSql())
 		})
 	}
 }
 
+func TestUpsertAndCreateTable(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:test.db")
+	assert.NoError(t, err)
+	contextLogger := econf.Log.WithField("rule", "test")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	s := &sqlSink{}
+	defer func() {
+		db.Close()
+		s.Close(ctx)
+		err := os.Remove("test.db")
+		if err != nil {
+			fmt.Println(err)
+		}
+	}()
+	assert.EqualError(t, (&sqlSink{}).Configure(map[string]interface{}{
+		"url":    "sqlite://test.db",
+		"table":  "upsertTable",
+		"upsert": true,
+	}), "keyField is required when upsert is set")
+	err = s.Configure(map[string]interface{}{
+		"url":         "sqlite://test.db",
+		"table":       "upsertTable",
+		"keyField":    "id",
+		"upsert":      true,
+		"createTable": true,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, s.Open(ctx))
+	ts := time.Date(2023, 7, 1, 10, 0, 0, 0, time.UTC)
+	assert.NoError(t, s.Collect(ctx, []map[string]interface{}{
+		{"id": int64(1), "name": "John's", "temperature": 20.5, "ok": true, "ts": ts},
+		{"id": int64(2), "name": "Susan", "temperature": 21.5, "ok": false, "ts": ts},
+	}))
+	rows, err := db.Query("SELECT name, type, pk FROM pragma_table_info('upsertTable')")
+	assert.NoError(t, err)
+	act, _ := rowsToMap(rows)
+	assert.Equal(t, []map[string]interface{}{
+		{"name": "id", "type": "BIGINT", "pk": int64(1)},
+		{"name": "name", "type": "TEXT", "pk": int64(0)},
+		{"name": "ok", "type": "BOOLEAN", "pk": int64(0)},
+		{"name": "temperature", "type": "DOUBLE PRECISION", "pk": int64(0)},
+		{"name": "ts", "type": "TIMESTAMP", "pk": int64(0)},
+	}, act)
+	// The existing rows are updated
+	assert.NoError(t, s.Collect(ctx, []map[string]interface{}{
+		{"id": int64(2), "name": "Diana", "temperature": 22.5, "ok": true, "ts": ts},
+		{"id": int64(3), "name": "Lizz", "temperature": 23.5, "ok": true, "ts": ts},
+	}))
+	rows, err = db.Query("SELECT id, name, temperature FROM upsertTable")
+	assert.NoError(t, err)
+	act, _ = rowsToMap(rows)
+	assert.Equal(t, []map[string]interface{}{
+		{"id": int64(1), "name": "John's", "temperature": 20.5},
+		{"id": int64(2), "name": "Diana", "temperature": 22.5},
+		{"id": int64(3), "name": "Lizz", "temperature": 23.5},
+	}, act)
+}
+
+func TestBatchRollback(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:test.db")
+	assert.NoError(t, err)
+	contextLogger := econf.Log.WithField("rule", "test")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	s := &sqlSink{}
+	defer func() {
+		db.Close()
+		s.Close(ctx)
+		err := os.Remove("test.db")
+		if err != nil {
+			fmt.Println(err)
+		}
+	}()
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS rollbackTable (id BIGINT PRIMARY KEY, name TEXT NOT NULL)")
+	assert.NoError(t, err)
+	err = s.Configure(map[string]interface{}{
+		"url":           "sqlite://test.db",
+		"table":         "rollbackTable",
+		"rowkindField":  "action",
+		"keyField":      "id",
+		"retryCount":    2,
+		"retryInterval": 10,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, s.Open(ctx))
+	query := func() []map[string]interface{} {
+		rows, err := db.Query("SELECT * FROM rollbackTable")
+		assert.NoError(t, err)
+		act, _ := rowsToMap(rows)
+		return act
+	}
+	// The update violates the not null constraint, so the whole batch is not written
+	err = s.Collect(ctx, []map[string]interface{}{
+		{"id": int64(1), "name": "John"},
+		{"id": int64(2), "name": "Susan"},
+		{"action": "update", "id": int64(1), "name": nil},
+	})
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), errorx.IOErr))
+	assert.Nil(t, query())
+	assert.NoError(t, s.Collect(ctx, []map[string]interface{}{
+		{"id": int64(1), "name": "John"},
+		{"id": int64(2), "name": "Susan"},
+		{"action": "update", "id": int64(1), "name": "Diana"},
+		{"action": "delete", "id": int64(2)},
+	}))
+	assert.Equal(t, []map[string]interface{}{{"id": int64(1), "name": "Diana"}}, query())
+}
+
+func TestBatchSql(t *testing.T) {
+	contextLogger := econf.Log.WithField("rule", "test")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	s := &sqlSink{}
+	assert.NoError(t, s.Configure(map[string]interface{}{
+		"url":          "mysql://root@127.0.0.1:3306/test",
+		"table":        "test",
+		"rowkindField": "action",
+		"keyField":     "id",
+	}))
+	mdb := &sqldatabase.MockDB{}
+	s.db = mdb
+	// Write in the two-phase commit mode to get all the statements of a batch
+	s.tx = true
+	err := s.save(ctx, "test", []map[string]interface{}{
+		{"id": 1, "name": "John"},
+		{"action": "insert", "id": 2, "name": "Susan"},
+		{"action": "upsert", "id": 3, "name": "Lizz"},
+		{"action": "upsert", "id": 4, "name": "Diana"},
+		{"action": "delete", "id": 4},
+		{"action": "insert", "id": 5},
+		{"action": "invalid", "id": 6},
+		{"action": "delete", "name": "Lizz"},
+		{"action": "upsert", "id": 1, "name": "Jack"},
+		{"action": "upsert", "id": 2, "name": "Ana"},
+		{"action": "upsert", "id": 1, "name": "Kate"},
+	})
+	// The valid rows are written and the invalid rows are reported
+	assert.EqualError(t, err, "2 of 11 rows are not written, the first error: invalid rowkind invalid")
+	assert.Equal(t, []string{
+		"INSERT INTO test (id,name) values (1,'John'),(2,'Susan');",
+		"INSERT INTO test (id,name) values (3,'Lizz'),(4,'Diana') ON DUPLICATE KEY UPDATE name=VALUES(name);",
+		"DELETE FROM test WHERE id = 4;",
+		"INSERT INTO test (id) values (5);",
+		"INSERT INTO test (id,name) values (2,'Ana'),(1,'Kate') ON DUPLICATE KEY UPDATE name=VALUES(name);",
+	}, s.buffer)
+}
+
+func TestTransaction(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:test.db")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	contextLogger := econf.Log.WithField("rule", "test")
+	tempStore, _ := state.CreateStore("test", api.AtMostOnce)
+	// The rule id with quote must not break the queries of the transaction table
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithMeta("test'rule", "sink1", tempStore)
+	s := &sqlSink{}
+	defer func() {
+		db.Close()
+		s.Close(ctx)
+		err := os.Remove("test.db")
+		if err != nil {
+			fmt.Println(err)
+		}
+	}()
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS txTable (id BIGINT PRIMARY KEY, name TEXT NOT NULL)")
+	if err != nil {
+		panic(err)
+	}
+	err = s.Configure(map[string]interface{}{
+		"url":    "sqlite://test.db",
+		"table":  "txTable",
+		"fields": []string{"id", "name"},
+	})
+	assert.NoError(t, err)
+	assert.EqualError(t, (&sqlSink{}).Configure(map[string]interface{}{
+		"url":              "sqlite://test.db",
+		"table":            "txTable",
+		"transactionTable": "tx;DROP TABLE txTable",
+	}), "invalid transactionTable tx;DROP TABLE txTable")
+	err = s.Open(ctx)
+	assert.NoError(t, err)
+	err = s.Recover(ctx, nil)
+	assert.NoError(t, err)
+	query := func() []map[string]interface{} {
+		rows, err := db.Query("SELECT * FROM txTable")
+		assert.NoError(t, err)
+		act, _ := rowsToMap(rows)
+		return act
+	}
+
+	assert.NoError(t, s.Collect(ctx, map[string]interface{}{"id": 1, "name": "John"}))
+	assert.NoError(t, s.PreCommit(ctx, 1))
+	assert.NoError(t, s.Collect(ctx, map[string]interface{}{"id": 2, "name": "Susan"}))
+	// Invisible before commit
+	assert.Nil(t, query())
+	assert.NoError(t, s.Commit(ctx, 1))
+	assert.Equal(t, []map[string]interface{}{{"id": int64(1), "name": "John"}}, query())
+	assert.NoError(t, s.PreCommit(ctx, 2))
+	stmts, _ := ctx.GetState(txStatementsKey + "2")
+	assert.NoError(t, s.Commit(ctx, 2))
+	// Commit again after recovery will not write duplicates
+	assert.NoError(t, ctx.PutState(txStatementsKey+"2", stmts))
+	assert.NoError(t, s.Recover(ctx, []int64{2}))
+	assert.Equal(t, []map[string]interface{}{{"id": int64(1), "name": "John"}, {"id": int64(2), "name": "Susan"}}, query())
+	// Abort discards the data not pre-committed
+	assert.NoError(t, s.Collect(ctx, map[string]interface{}{"id": 3, "name": "Lizz"}))
+	assert.NoError(t, s.Abort(ctx))
+	assert.NoError(t, s.PreCommit(ctx, 3))
+	assert.NoError(t, s.Commit(ctx, 3))
+	assert.Equal(t, 2, len(query()))
+}
+
 func rowsToMap(rows *sql.Rows) ([]map[string]interface{}, error) {
 	cols, _ := rows.Columns()
 
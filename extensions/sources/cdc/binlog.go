@@ -0,0 +1,450 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+// binlog event types
+const (
+	eventQuery             = 2
+	eventRotate            = 4
+	eventFormatDescription = 15
+	eventXid               = 16
+	eventTableMap          = 19
+	eventWriteRowsV1       = 23
+	eventUpdateRowsV1      = 24
+	eventDeleteRowsV1      = 25
+	eventHeartbeat         = 27
+	eventWriteRowsV2       = 30
+	eventUpdateRowsV2      = 31
+	eventDeleteRowsV2      = 32
+
+	eventHeaderLength = 19
+	checksumLength    = 4
+)
+
+// column types of the binlog
+const (
+	typeDecimal    = 0
+	typeTiny       = 1
+	typeShort      = 2
+	typeLong       = 3
+	typeFloat      = 4
+	typeDouble     = 5
+	typeNull       = 6
+	typeTimestamp  = 7
+	typeLongLong   = 8
+	typeInt24      = 9
+	typeDate       = 10
+	typeTime       = 11
+	typeDatetime   = 12
+	typeYear       = 13
+	typeVarchar    = 15
+	typeBit        = 16
+	typeTimestamp2 = 17
+	typeDatetime2  = 18
+	typeTime2      = 19
+	typeJSON       = 245
+	typeNewDecimal = 246
+	typeEnum       = 247
+	typeSet        = 248
+	typeBlob       = 252
+	typeVarString  = 253
+	typeString     = 254
+	typeGeometry   = 255
+)
+
+// optional metadata of the table map event
+const (
+	metaSignedness = 1
+	metaColumnName = 4
+)
+
+type eventHeader struct {
+	timestamp uint32
+	eventType byte
+	size      uint32
+	logPos    uint32
+}
+
+func parseEventHeader(b []byte) (*eventHeader, error) {
+	r := newReader(b, binary.LittleEndian)
+	h := &eventHeader{timestamp: r.u32(), eventType: r.u8()}
+	r.skip(4)
+	h.size = r.u32()
+	h.logPos = r.u32()
+	r.skip(2)
+	return h, r.err
+}
+
+// tableMap is the column definition of a table in the table map event
+type tableMap struct {
+	schema   string
+	table    string
+	types    []byte
+	meta     []uint16
+	unsigned []bool
+	// names are available if binlog_row_metadata is FULL
+	names []string
+}
+
+func parseTableMap(b []byte) (uint64, *tableMap, error) {
+	r := newReader(b, binary.LittleEndian)
+	id := r.uint(6)
+	r.skip(2)
+	t := &tableMap{}
+	t.schema = string(r.bytes(int(r.u8())))
+	r.skip(1)
+	t.table = string(r.bytes(int(r.u8())))
+	r.skip(1)
+	n, _ := r.lenenc()
+	t.types = r.bytes(int(n))
+	ml, _ := r.lenenc()
+	mr := newReader(r.bytes(int(ml)), binary.LittleEndian)
+	t.meta = make([]uint16, len(t.types))
+	for i, typ := range t.types {
+		switch typ {
+		case typeFloat, typeDouble, typeBlob, typeGeometry, typeJSON, typeTime2, typeDatetime2, typeTimestamp2:
+			t.meta[i] = uint16(mr.u8())
+		case typeVarchar, typeVarString:
+			t.meta[i] = mr.u16()
+		case typeBit, typeNewDecimal, typeString, typeEnum, typeSet:
+			t.meta[i] = uint16(mr.u8())<<8 | uint16(mr.u8())
+		}
+	}
+	if mr.err != nil {
+		return 0, nil, fmt.Errorf("invalid column metadata of table map: %v", mr.err)
+	}
+	// null bitmap
+	r.skip((len(t.types) + 7) / 8)
+	t.unsigned = make([]bool, len(t.types))
+	for r.err == nil && r.remaining() > 0 {
+		typ := r.u8()
+		l, _ := r.lenenc()
+		vr := newReader(r.bytes(int(l)), binary.LittleEndian)
+		switch typ {
+		case metaSignedness:
+			bits := vr.rest()
+			j := 0
+			for i, ct := range t.types {
+				if !isNumericType(ct) {
+					continue
+				}
+				if j/8 < len(bits) {
+					t.unsigned[i] = bits[j/8]&(0x80>>(j%8)) != 0
+				}
+				j++
+			}
+		case metaColumnName:
+			for vr.remaining() > 0 {
+				name, _ := vr.lenencString()
+				t.names = append(t.names, name)
+			}
+		}
+	}
+	return id, t, r.err
+}
+
+func isNumericType(t byte) bool {
+	switch t {
+	case typeTiny, typeShort, typeInt24, typeLong, typeLongLong, typeFloat, typeDouble, typeDecimal, typeNewDecimal:
+		return true
+	}
+	return false
+}
+
+// rowsEvent is the decoded rows event of a table. For the update event, the rows are in pairs of before and after.
+type rowsEvent struct {
+	tableId uint64
+	rows    [][]interface{}
+	// present is the columns present in the rows, and presentAfter is the ones in the after image of the update event
+	present      []bool
+	presentAfter []bool
+}
+
+func rowkindOf(t byte) string {
+	switch t {
+	case eventWriteRowsV1, eventWriteRowsV2:
+		return ast.RowkindInsert
+	case eventUpdateRowsV1, eventUpdateRowsV2:
+		return ast.RowkindUpdate
+	default:
+		return ast.RowkindDelete
+	}
+}
+
+// rowsTableId reads the table id of the rows event so that the rows of other tables can be skipped without decoding
+func rowsTableId(b []byte) uint64 {
+	return newReader(b, binary.LittleEndian).uint(6)
+}
+
+func parseRows(eventType byte, b []byte, t *tableMap) (*rowsEvent, error) {
+	r := newReader(b, binary.LittleEndian)
+	e := &rowsEvent{tableId: r.uint(6)}
+	r.skip(2)
+	if eventType >= eventWriteRowsV2 {
+		// the length of the extra data includes itself
+		r.skip(int(r.u16()) - 2)
+	}
+	n, _ := r.lenenc()
+	if int(n) != len(t.types) {
+		return nil, fmt.Errorf("rows event of %s.%s has %d columns but the table map has %d", t.schema, t.table, n, len(t.types))
+	}
+	e.present = bitmap(r.bytes(int(n+7)/8), int(n))
+	update := eventType == eventUpdateRowsV1 || eventType == eventUpdateRowsV2
+	if update {
+		e.presentAfter = bitmap(r.bytes(int(n+7)/8), int(n))
+	}
+	for r.err == nil && r.remaining() > 0 {
+		row, err := decodeRow(r, t, e.present)
+		if err != nil {
+			return nil, err
+		}
+		e.rows = append(e.rows, row)
+		if update {
+			row, err = decodeRow(r, t, e.presentAfter)
+			if err != nil {
+				return nil, err
+			}
+			e.rows = append(e.rows, row)
+		}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("invalid rows event of %s.%s: %v", t.schema, t.table, r.err)
+	}
+	return e, nil
+}
+
+func bitmap(b []byte, n int) []bool {
+	result := make([]bool, n)
+	for i := 0; i < n && i/8 < len(b); i++ {
+		result[i] = b[i/8]&(1<<(i%8)) != 0
+	}
+	return result
+}
+
+// decodeRow decodes the present columns of a row image. The absent columns are left as nil.
+func decodeRow(r *reader, t *tableMap, present []bool) ([]interface{}, error) {
+	count := 0
+	for _, p := range present {
+		if p {
+			count++
+		}
+	}
+	nulls := bitmap(r.bytes((count+7)/8), count)
+	row := make([]interface{}, len(t.types))
+	j := 0
+	for i, typ := range t.types {
+		if !present[i] {
+			continue
+		}
+		null := nulls[j]
+		j++
+		if null {
+			continue
+		}
+		v, err := decodeValue(r, typ, t.meta[i], t.unsigned[i])
+		if err != nil {
+			return nil, fmt.Errorf("fail to decode column %d of %s.%s: %v", i, t.schema, t.table, err)
+		}
+		row[i] = v
+	}
+	return row, r.err
+}
+
+// decodeValue decodes a column value of the row image. The integers are decoded as int64 or uint64, the decimals
+// as float64, the date and time as time.Time except the date and time types which are formatted strings.
+func decodeValue(r *reader, typ byte, meta uint16, unsigned bool) (interface{}, error) {
+	switch typ {
+	case typeTiny:
+		v := r.u8()
+		if unsigned {
+			return int64(v), nil
+		}
+		return int64(int8(v)), nil
+	case typeShort:
+		v := r.u16()
+		if unsigned {
+			return int64(v), nil
+		}
+		return int64(int16(v)), nil
+	case typeInt24:
+		v := uint32(r.uint(3))
+		if unsigned {
+			return int64(v), nil
+		}
+		return int64(int32(v<<8) >> 8), nil
+	case typeLong:
+		v := r.u32()
+		if unsigned {
+			return int64(v), nil
+		}
+		return int64(int32(v)), nil
+	case typeLongLong:
+		v := r.u64()
+		if unsigned && v > math.MaxInt64 {
+			return v, nil
+		}
+		return int64(v), nil
+	case typeYear:
+		v := r.u8()
+		if v == 0 {
+			return int64(0), nil
+		}
+		return int64(v) + 1900, nil
+	case typeFloat:
+		return float64(math.Float32frombits(r.u32())), nil
+	case typeDouble:
+		return math.Float64frombits(r.u64()), nil
+	case typeVarchar, typeVarString:
+		if meta < 256 {
+			return string(r.bytes(int(r.u8()))), nil
+		}
+		return string(r.bytes(int(r.u16()))), nil
+	case typeString:
+		return decodeString(r, meta)
+	case typeEnum, typeSet:
+		return int64(r.uint(int(meta & 0xff))), nil
+	case typeBlob:
+		return string(r.bytes(int(r.uint(int(meta))))), nil
+	case typeGeometry:
+		return r.bytes(int(r.uint(int(meta)))), nil
+	case typeNewDecimal:
+		return decodeDecimal(r, int(meta>>8), int(meta&0xff))
+	case typeBit:
+		n := int(meta & 0xff)
+		if meta>>8 > 0 {
+			n++
+		}
+		return int64(newReader(r.bytes(n), binary.BigEndian).uint(n)), nil
+	case typeDate:
+		v := r.uint(3)
+		return fmt.Sprintf("%04d-%02d-%02d", v>>9, (v>>5)&15, v&31), nil
+	case typeTimestamp2:
+		sec := int64(newReader(r.bytes(4), binary.BigEndian).u32())
+		usec := decodeFraction(r, int(meta))
+		return time.Unix(sec, usec*1000).UTC(), nil
+	case typeDatetime2:
+		v := int64(newReader(r.bytes(5), binary.BigEndian).uint(5)) - 0x8000000000
+		usec := decodeFraction(r, int(meta))
+		ymd, hms := v>>17, v&(1<<17-1)
+		ym := ymd >> 5
+		if ymd == 0 {
+			return "0000-00-00 00:00:00", nil
+		}
+		return time.Date(int(ym/13), time.Month(ym%13), int(ymd&31), int(hms>>12), int((hms>>6)&63), int(hms&63), int(usec*1000), time.UTC), nil
+	case typeTime2:
+		v := int64(newReader(r.bytes(3), binary.BigEndian).uint(3)) - 0x800000
+		usec := decodeFraction(r, int(meta))
+		sign := ""
+		if v < 0 {
+			sign, v = "-", -v
+		}
+		s := fmt.Sprintf("%s%02d:%02d:%02d", sign, (v>>12)&0x3ff, (v>>6)&63, v&63)
+		if meta > 0 {
+			s += fmt.Sprintf(".%06d", usec)[:meta+1]
+		}
+		return s, nil
+	case typeNull:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported column type %d", typ)
+	}
+}
+
+// decodeString decodes the CHAR, ENUM and SET column whose real type is encoded in the metadata
+func decodeString(r *reader, meta uint16) (interface{}, error) {
+	realType := byte(meta >> 8)
+	length := int(meta & 0xff)
+	if realType&0x30 != 0x30 {
+		// the length larger than 255 is encoded in the high bits of the type
+		length |= int((realType&0x30)^0x30) << 4
+		realType |= 0x30
+	}
+	switch realType {
+	case typeEnum, typeSet:
+		return int64(r.uint(length)), nil
+	default:
+		if length < 256 {
+			return string(r.bytes(int(r.u8()))), nil
+		}
+		return string(r.bytes(int(r.u16()))), nil
+	}
+}
+
+// decodeFraction reads the fractional seconds of the temporal types in microseconds
+func decodeFraction(r *reader, fsp int) int64 {
+	n := (fsp + 1) / 2
+	if n == 0 {
+		return 0
+	}
+	v := int64(newReader(r.bytes(n), binary.BigEndian).uint(n))
+	// the fraction is stored in units of 100 microseconds for every 2 digits
+	for i := n; i < 3; i++ {
+		v *= 100
+	}
+	return v
+}
+
+var digitsToBytes = [10]int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+
+// decodeDecimal decodes the binary format of DECIMAL which stores every 9 digits in 4 bytes
+func decodeDecimal(r *reader, precision int, scale int) (interface{}, error) {
+	intg := precision - scale
+	intg0, intg0x := intg/9, intg%9
+	frac0, frac0x := scale/9, scale%9
+	size := intg0*4 + digitsToBytes[intg0x] + frac0*4 + digitsToBytes[frac0x]
+	raw := r.bytes(size)
+	if raw == nil {
+		return nil, r.err
+	}
+	b := make([]byte, size)
+	copy(b, raw)
+	negative := b[0]&0x80 == 0
+	b[0] ^= 0x80
+	if negative {
+		for i := range b {
+			b[i] ^= 0xff
+		}
+	}
+	br := newReader(b, binary.BigEndian)
+	var sb strings.Builder
+	if negative {
+		sb.WriteByte('-')
+	}
+	sb.WriteString(strconv.FormatUint(br.uint(digitsToBytes[intg0x]), 10))
+	for i := 0; i < intg0; i++ {
+		sb.WriteString(fmt.Sprintf("%09d", br.u32()))
+	}
+	if scale > 0 {
+		sb.WriteByte('.')
+		for i := 0; i < frac0; i++ {
+			sb.WriteString(fmt.Sprintf("%09d", br.u32()))
+		}
+		if frac0x > 0 {
+			sb.WriteString(fmt.Sprintf("%0*d", frac0x, br.uint(digitsToBytes[frac0x])))
+		}
+	}
+	return strconv.ParseFloat(sb.String(), 64)
+}
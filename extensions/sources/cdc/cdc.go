@@ -0,0 +1,294 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/infra"
+)
+
+type sourceConf struct {
+	Url string `json:"url"`
+	// ServerId is the replica server id of MySQL which must be unique in the replication topology
+	ServerId uint32 `json:"serverId"`
+	// Slot and Publication are the replication slot and the publication of PostgreSQL
+	Slot        string `json:"slot"`
+	Publication string `json:"publication"`
+	// RowkindField is the field to put the rowkind of the change into, empty to not put
+	RowkindField string `json:"rowkindField"`
+	// AckOnCheckpoint acknowledges the position to the database only when the checkpoint is completed. It must be set
+	// only if the rule qos is at least once, otherwise the position is never acknowledged.
+	AckOnCheckpoint bool `json:"ackOnCheckpoint"`
+}
+
+// changeEvent is a changed row of the captured table
+type changeEvent struct {
+	rowkind string
+	schema  string
+	table   string
+	// data is the row after the change, or the row before deletion for a delete event
+	data map[string]interface{}
+	// before is the row before the update if provided by the database
+	before map[string]interface{}
+}
+
+// transaction is the changes of the captured table in a committed transaction
+type transaction struct {
+	events []*changeEvent
+	// offset is the position after the transaction
+	offset map[string]interface{}
+	// timestamp is the commit time in milliseconds
+	timestamp int64
+}
+
+// replicator reads the changes of a table by the replication protocol of the database
+type replicator interface {
+	// start connects to the database and starts the replication from the offset, or from the current position if
+	// the offset is nil
+	start(offset map[string]interface{}) error
+	// next blocks until the next transaction is committed
+	next() (*transaction, error)
+	// ack acknowledges to the database that the changes before the offset are consumed
+	ack(offset map[string]interface{}) error
+	close() error
+}
+
+// cdcSource captures the row changes of a table from the MySQL binlog or the PostgreSQL logical replication. Each
+// change is sent as a tuple with the rowkind so that the sinks supporting rowkindField can mirror the table. The
+// offset is the position after the last consumed transaction.
+type cdcSource struct {
+	c      *sourceConf
+	schema string
+	table  string
+
+	newReplicator func() replicator
+
+	// mu guards the offset and the replicator
+	mu     sync.Mutex
+	offset map[string]interface{}
+	rep    replicator
+}
+
+func (s *cdcSource) Configure(datasource string, props map[string]interface{}) error {
+	c := &sourceConf{
+		RowkindField: "action",
+	}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if c.Url == "" {
+		return fmt.Errorf("property url is required")
+	}
+	u, err := url.Parse(c.Url)
+	if err != nil {
+		return fmt.Errorf("invalid url %s: %v", c.Url, err)
+	}
+	ssl, err := parseSSL(u)
+	if err != nil {
+		return err
+	}
+	if datasource == "" {
+		return fmt.Errorf("datasource which is the table to capture is required")
+	}
+	if i := strings.LastIndex(datasource, "."); i >= 0 {
+		s.schema, s.table = datasource[:i], datasource[i+1:]
+	} else {
+		s.table = datasource
+	}
+	switch u.Scheme {
+	case "mysql":
+		if s.schema == "" {
+			s.schema = strings.TrimPrefix(u.Path, "/")
+		}
+		if s.schema == "" {
+			return fmt.Errorf("the database of table %s is not specified in the datasource or the url", datasource)
+		}
+		if c.ServerId == 0 {
+			c.ServerId = 1001
+		}
+		s.newReplicator = func() replicator {
+			return newMysqlReplicator(u, ssl, c.ServerId, s.schema, s.table)
+		}
+	case "postgres", "postgresql":
+		if s.schema == "" {
+			s.schema = "public"
+		}
+		if c.Slot == "" {
+			return fmt.Errorf("property slot is required for postgres")
+		}
+		if c.Publication == "" {
+			return fmt.Errorf("property publication is required for postgres")
+		}
+		s.newReplicator = func() replicator {
+			return newPgReplicator(u, ssl, c.Slot, c.Publication, s.schema, s.table)
+		}
+	default:
+		return fmt.Errorf("unsupported url scheme %s, must be mysql or postgres", u.Scheme)
+	}
+	s.c = c
+	return nil
+}
+
+func (s *cdcSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	logger := ctx.GetLogger()
+	r := s.newReplicator()
+	s.mu.Lock()
+	s.rep = r
+	offset := s.offset
+	s.mu.Unlock()
+	if err := r.start(offset); err != nil {
+		_ = r.close()
+		infra.DrainError(ctx, fmt.Errorf("cdc source fails to start replication of %s.%s: %v", s.schema, s.table, err), errCh)
+		return
+	}
+	logger.Infof("cdc source starts to capture %s.%s from %v", s.schema, s.table, offset)
+	if s.c.AckOnCheckpoint {
+		logger.Infof("cdc source acknowledges the position on checkpoint, make sure the rule qos is at least once")
+	}
+	// Unblock the reading when the rule stops
+	go func() {
+		<-ctx.Done()
+		_ = r.close()
+	}()
+	for {
+		tx, err := r.next()
+		if err != nil {
+			if ctx.Err() != nil {
+				logger.Infof("cdc source done")
+				return
+			}
+			infra.DrainError(ctx, fmt.Errorf("cdc source fails to read the replication of %s.%s: %v", s.schema, s.table, err), errCh)
+			return
+		}
+		if !s.publish(ctx, consumer, tx) {
+			return
+		}
+		if !s.c.AckOnCheckpoint {
+			if err := r.ack(tx.offset); err != nil {
+				logger.Warnf("cdc source fails to ack offset %v: %v", tx.offset, err)
+			}
+		}
+	}
+}
+
+// publish sends the changes of the transaction. The last tuple carries the offset after the transaction.
+func (s *cdcSource) publish(ctx api.StreamContext, consumer chan<- api.SourceTuple, tx *transaction) bool {
+	s.mu.Lock()
+	prev := s.offset
+	s.mu.Unlock()
+	rcvTime := conf.GetNow()
+	for i, ev := range tx.events {
+		msg := make(map[string]interface{}, len(ev.data)+1)
+		for k, v := range ev.data {
+			msg[k] = v
+		}
+		if s.c.RowkindField != "" {
+			msg[s.c.RowkindField] = ev.rowkind
+		}
+		meta := map[string]interface{}{
+			"rowkind":   ev.rowkind,
+			"schema":    ev.schema,
+			"table":     ev.table,
+			"timestamp": tx.timestamp,
+		}
+		if ev.before != nil {
+			meta["before"] = ev.before
+		}
+		offset := prev
+		if i == len(tx.events)-1 {
+			offset = tx.offset
+		}
+		select {
+		case consumer <- api.NewOffsetSourceTuple(api.NewDefaultSourceTupleWithTime(msg, meta, rcvTime), offset):
+		case <-ctx.Done():
+			return false
+		}
+	}
+	s.mu.Lock()
+	s.offset = tx.offset
+	s.mu.Unlock()
+	return true
+}
+
+func parseOffset(offset interface{}) (map[string]interface{}, error) {
+	if offset == nil {
+		return nil, nil
+	}
+	o, ok := offset.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid cdc source offset %v", offset)
+	}
+	return o, nil
+}
+
+func (s *cdcSource) GetOffset() (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.offset, nil
+}
+
+// Rewind sets the position to continue from. It is called before Open.
+func (s *cdcSource) Rewind(offset interface{}) error {
+	o, err := parseOffset(offset)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offset = o
+	return nil
+}
+
+// CommitOffset acknowledges the position of the completed checkpoint so that the database can recycle the log
+func (s *cdcSource) CommitOffset(ctx api.StreamContext, offset interface{}) error {
+	if !s.c.AckOnCheckpoint {
+		return nil
+	}
+	o, err := parseOffset(offset)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	r := s.rep
+	s.mu.Unlock()
+	if r == nil || len(o) == 0 {
+		return nil
+	}
+	ctx.GetLogger().Debugf("cdc source acks offset %v", o)
+	return r.ack(o)
+}
+
+func (s *cdcSource) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("Closing cdc source")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rep != nil {
+		return s.rep.close()
+	}
+	return nil
+}
+
+var _ api.OffsetCommitter = &cdcSource{}
+
+func Cdc() api.Source {
+	return &cdcSource{}
+}
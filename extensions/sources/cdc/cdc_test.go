@@ -0,0 +1,123 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		datasource string
+		props      map[string]interface{}
+		err        error
+	}{
+		{
+			datasource: "t1",
+			props:      map[string]interface{}{},
+			err:        errors.New("property url is required"),
+		},
+		{
+			datasource: "",
+			props:      map[string]interface{}{"url": "mysql://root@localhost:3306/db"},
+			err:        errors.New("datasource which is the table to capture is required"),
+		},
+		{
+			datasource: "t1",
+			props:      map[string]interface{}{"url": "mysql://root@localhost:3306"},
+			err:        errors.New("the database of table t1 is not specified in the datasource or the url"),
+		},
+		{
+			datasource: "t1",
+			props:      map[string]interface{}{"url": "postgres://root@localhost:5432/db"},
+			err:        errors.New("property slot is required for postgres"),
+		},
+		{
+			datasource: "t1",
+			props:      map[string]interface{}{"url": "postgres://root@localhost:5432/db", "slot": "s1"},
+			err:        errors.New("property publication is required for postgres"),
+		},
+		{
+			datasource: "t1",
+			props:      map[string]interface{}{"url": "sqlserver://root@localhost:1433/db"},
+			err:        errors.New("unsupported url scheme sqlserver, must be mysql or postgres"),
+		},
+	}
+	for i, tt := range tests {
+		err := Cdc().Configure(tt.datasource, tt.props)
+		assert.Equal(t, tt.err, err, "case %d", i)
+	}
+
+	s := Cdc().(*cdcSource)
+	assert.NoError(t, s.Configure("t1", map[string]interface{}{"url": "mysql://root@localhost:3306/db"}))
+	assert.Equal(t, "db", s.schema)
+	assert.Equal(t, "t1", s.table)
+	assert.Equal(t, &sourceConf{Url: "mysql://root@localhost:3306/db", ServerId: 1001, RowkindField: "action"}, s.c)
+	s = Cdc().(*cdcSource)
+	assert.NoError(t, s.Configure("t1", map[string]interface{}{"url": "postgres://root@localhost:5432/db", "slot": "s1", "publication": "p1"}))
+	assert.Equal(t, "public", s.schema)
+	s = Cdc().(*cdcSource)
+	assert.NoError(t, s.Configure("inventory.t1", map[string]interface{}{"url": "postgresql://root@localhost:5432/db", "slot": "s1", "publication": "p1"}))
+	assert.Equal(t, "inventory", s.schema)
+	assert.Equal(t, "t1", s.table)
+}
+
+// runSource opens the source and receives the tuples until the limit
+func runSource(t *testing.T, s api.Source, limit int) (api.StreamContext, []api.SourceTuple) {
+	ctx, cancel := mockContext.NewMockContext("ruleCdc", "op1").WithCancel()
+	t.Cleanup(cancel)
+	consumer := make(chan api.SourceTuple)
+	errCh := make(chan error, 1)
+	go s.Open(ctx, consumer, errCh)
+	var result []api.SourceTuple
+	timeout := time.After(5 * time.Second)
+	for len(result) < limit {
+		select {
+		case tuple := <-consumer:
+			result = append(result, tuple)
+		case err := <-errCh:
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatalf("timeout, only received %d tuples", len(result))
+		}
+	}
+	return ctx, result
+}
+
+// openError opens the source and returns the error reported
+func openError(t *testing.T, s api.Source) error {
+	ctx, cancel := mockContext.NewMockContext("ruleCdc", "op1").WithCancel()
+	defer cancel()
+	errCh := make(chan error, 1)
+	go s.Open(ctx, make(chan api.SourceTuple), errCh)
+	select {
+	case err := <-errCh:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout to wait for the error")
+		return nil
+	}
+}
+
+func offsetOf(tuple api.SourceTuple) interface{} {
+	return tuple.(api.OffsetSourceTuple).Offset()
+}
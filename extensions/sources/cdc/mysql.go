@@ -0,0 +1,689 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lf-edge/ekuiper/pkg/ast"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+// mysql client protocol constants
+const (
+	clientLongPassword     = 0x00000001
+	clientConnectWithDB    = 0x00000008
+	clientProtocol41       = 0x00000200
+	clientSSL              = 0x00000800
+	clientTransactions     = 0x00002000
+	clientSecureConnection = 0x00008000
+	clientPluginAuth       = 0x00080000
+
+	comQuery      = 0x03
+	comBinlogDump = 0x12
+
+	packetOK  = 0x00
+	packetEOF = 0xfe
+	packetERR = 0xff
+
+	// the auth data of caching_sha2_password
+	authMoreData     = 0x01
+	fastAuthSuccess  = 0x03
+	performFullAuth  = 0x04
+	requestPublicKey = 0x02
+
+	maxPacketSize  = 1<<24 - 1
+	charsetUtf8mb4 = 45
+)
+
+// mysqlConn is a minimal client of the MySQL protocol to run the text queries and to dump the binlog
+type mysqlConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	seq  byte
+	// secure is true if the connection is upgraded to TLS so that the password can be sent in plain text
+	secure bool
+	// scramble is the auth data of the current auth method
+	scramble []byte
+}
+
+func dialMysql(address string, user string, password string, ssl *sslConf) (*mysqlConn, error) {
+	conn, err := net.DialTimeout("tcp", address, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	c := &mysqlConn{conn: conn, r: bufio.NewReader(conn)}
+	if err := c.handshake(user, password, ssl); err != nil {
+		_ = c.conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *mysqlConn) readPacket() ([]byte, error) {
+	var payload []byte
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(c.r, header); err != nil {
+			return nil, err
+		}
+		n := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+		c.seq = header[3] + 1
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		payload = append(payload, buf...)
+		if n < maxPacketSize {
+			return payload, nil
+		}
+	}
+}
+
+func (c *mysqlConn) writePacket(payload []byte) error {
+	buf := make([]byte, 4, 4+len(payload))
+	buf[0], buf[1], buf[2], buf[3] = byte(len(payload)), byte(len(payload)>>8), byte(len(payload)>>16), c.seq
+	c.seq++
+	_, err := c.conn.Write(append(buf, payload...))
+	return err
+}
+
+func (c *mysqlConn) command(cmd byte, data []byte) error {
+	c.seq = 0
+	return c.writePacket(append([]byte{cmd}, data...))
+}
+
+func parseMysqlError(p []byte) error {
+	r := newReader(p, binary.LittleEndian)
+	r.skip(1)
+	code := r.u16()
+	msg := r.rest()
+	// skip the sql state marker and the sql state
+	if len(msg) > 6 && msg[0] == '#' {
+		msg = msg[6:]
+	}
+	return fmt.Errorf("mysql error %d: %s", code, msg)
+}
+
+func (c *mysqlConn) handshake(user string, password string, ssl *sslConf) error {
+	p, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+	if p[0] == packetERR {
+		return parseMysqlError(p)
+	}
+	if p[0] != 10 {
+		return fmt.Errorf("unsupported mysql protocol version %d", p[0])
+	}
+	r := newReader(p, binary.LittleEndian)
+	r.skip(1)
+	_ = r.cstring()
+	r.skip(4)
+	scramble := append([]byte{}, r.bytes(8)...)
+	r.skip(1)
+	capabilities := uint32(r.u16())
+	plugin := "mysql_native_password"
+	if r.remaining() > 0 {
+		r.skip(3)
+		capabilities |= uint32(r.u16()) << 16
+		authLen := int(r.u8())
+		r.skip(10)
+		if capabilities&clientSecureConnection != 0 {
+			n := authLen - 8
+			if n < 13 {
+				n = 13
+			}
+			// the last byte is NUL
+			scramble = append(scramble, r.bytes(n)[:n-1]...)
+		}
+		if capabilities&clientPluginAuth != 0 && r.remaining() > 0 {
+			plugin = strings.TrimRight(string(r.rest()), "\x00")
+		}
+	}
+	if r.err != nil {
+		return fmt.Errorf("invalid mysql handshake: %v", r.err)
+	}
+	flags := uint32(clientLongPassword | clientProtocol41 | clientSecureConnection | clientTransactions | clientPluginAuth)
+	if ssl.enabled() {
+		if capabilities&clientSSL != 0 {
+			flags |= clientSSL
+			// the ssl request is the header of the handshake response
+			if err := c.writePacket(handshakeHeader(flags)); err != nil {
+				return err
+			}
+			tc := tls.Client(c.conn, ssl.config)
+			if err := tc.Handshake(); err != nil {
+				return fmt.Errorf("mysql tls handshake error: %v", err)
+			}
+			c.conn, c.r, c.secure = tc, bufio.NewReader(tc), true
+		} else if ssl.required() {
+			return fmt.Errorf("mysql server does not support TLS which is required by sslmode %s", ssl.mode)
+		}
+	}
+	c.scramble = scramble
+	auth, err := authResponse(plugin, scramble, password)
+	if err != nil {
+		return err
+	}
+	resp := handshakeHeader(flags)
+	resp = append(append(resp, user...), 0)
+	resp = append(append(resp, byte(len(auth))), auth...)
+	resp = append(append(resp, plugin...), 0)
+	if err := c.writePacket(resp); err != nil {
+		return err
+	}
+	return c.authResult(password)
+}
+
+func handshakeHeader(flags uint32) []byte {
+	resp := binary.LittleEndian.AppendUint32(nil, flags)
+	resp = binary.LittleEndian.AppendUint32(resp, maxPacketSize)
+	resp = append(resp, charsetUtf8mb4)
+	return append(resp, make([]byte, 23)...)
+}
+
+func (c *mysqlConn) authResult(password string) error {
+	for {
+		p, err := c.readPacket()
+		if err != nil {
+			return err
+		}
+		switch p[0] {
+		case packetOK:
+			return nil
+		case packetERR:
+			return parseMysqlError(p)
+		case packetEOF:
+			// switch to the auth method requested by the server
+			r := newReader(p[1:], binary.LittleEndian)
+			plugin := r.cstring()
+			c.scramble = bytes.TrimRight(r.rest(), "\x00")
+			auth, err := authResponse(plugin, c.scramble, password)
+			if err != nil {
+				return err
+			}
+			if err := c.writePacket(auth); err != nil {
+				return err
+			}
+		case authMoreData:
+			// caching_sha2_password: the OK packet follows the fast auth success, or the password is required
+			if len(p) > 1 && p[1] == fastAuthSuccess {
+				continue
+			}
+			if len(p) > 1 && p[1] == performFullAuth {
+				if err := c.fullAuth(password); err != nil {
+					return err
+				}
+				continue
+			}
+			return fmt.Errorf("unexpected caching_sha2_password auth data %x", p[1:])
+		default:
+			return fmt.Errorf("unexpected mysql auth packet %x", p[0])
+		}
+	}
+}
+
+// fullAuth sends the password of caching_sha2_password in plain text over TLS. Otherwise, the password is encrypted by
+// the RSA public key requested from the server.
+func (c *mysqlConn) fullAuth(password string) error {
+	pwd := append([]byte(password), 0)
+	if c.secure {
+		return c.writePacket(pwd)
+	}
+	if err := c.writePacket([]byte{requestPublicKey}); err != nil {
+		return err
+	}
+	p, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+	switch p[0] {
+	case authMoreData:
+	case packetERR:
+		return parseMysqlError(p)
+	default:
+		return fmt.Errorf("unexpected mysql public key packet %x", p[0])
+	}
+	block, _ := pem.Decode(p[1:])
+	if block == nil {
+		return fmt.Errorf("invalid mysql public key %s", p[1:])
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("invalid mysql public key: %v", err)
+	}
+	key, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("mysql public key is %T instead of RSA", pub)
+	}
+	scramble := c.scramble
+	if len(scramble) > 20 {
+		scramble = scramble[:20]
+	}
+	if len(scramble) > 0 {
+		for i := range pwd {
+			pwd[i] ^= scramble[i%len(scramble)]
+		}
+	}
+	enc, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, key, pwd, nil)
+	if err != nil {
+		return fmt.Errorf("fail to encrypt the mysql password: %v", err)
+	}
+	return c.writePacket(enc)
+}
+
+func authResponse(plugin string, scramble []byte, password string) ([]byte, error) {
+	if password == "" {
+		return nil, nil
+	}
+	if len(scramble) > 20 {
+		scramble = scramble[:20]
+	}
+	switch plugin {
+	case "mysql_native_password":
+		// SHA1(password) XOR SHA1(scramble + SHA1(SHA1(password)))
+		h1 := sha1.Sum([]byte(password))
+		h2 := sha1.Sum(h1[:])
+		h3 := sha1.Sum(append(append([]byte{}, scramble...), h2[:]...))
+		for i := range h1 {
+			h1[i] ^= h3[i]
+		}
+		return h1[:], nil
+	case "caching_sha2_password":
+		// SHA256(password) XOR SHA256(SHA256(SHA256(password)) + scramble)
+		m1 := sha256.Sum256([]byte(password))
+		m2 := sha256.Sum256(m1[:])
+		m3 := sha256.Sum256(append(m2[:], scramble...))
+		for i := range m1 {
+			m1[i] ^= m3[i]
+		}
+		return m1[:], nil
+	default:
+		return nil, fmt.Errorf("unsupported mysql auth plugin %s", plugin)
+	}
+}
+
+// query runs the statement and returns the rows of the text result set. NULL is returned as an empty string.
+func (c *mysqlConn) query(q string) ([][]string, error) {
+	if err := c.command(comQuery, []byte(q)); err != nil {
+		return nil, err
+	}
+	p, err := c.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	switch p[0] {
+	case packetOK:
+		return nil, nil
+	case packetERR:
+		return nil, parseMysqlError(p)
+	}
+	n, _ := newReader(p, binary.LittleEndian).lenenc()
+	// skip the column definitions and the EOF packet
+	for i := uint64(0); i <= n; i++ {
+		if _, err := c.readPacket(); err != nil {
+			return nil, err
+		}
+	}
+	var rows [][]string
+	for {
+		p, err := c.readPacket()
+		if err != nil {
+			return nil, err
+		}
+		if p[0] == packetEOF && len(p) < 9 {
+			return rows, nil
+		}
+		if p[0] == packetERR {
+			return nil, parseMysqlError(p)
+		}
+		r := newReader(p, binary.LittleEndian)
+		row := make([]string, n)
+		for i := range row {
+			row[i], _ = r.lenencString()
+		}
+		if r.err != nil {
+			return nil, fmt.Errorf("invalid mysql row: %v", r.err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+func (c *mysqlConn) binlogDump(serverId uint32, file string, pos uint32) error {
+	data := binary.LittleEndian.AppendUint32(nil, pos)
+	data = binary.LittleEndian.AppendUint16(data, 0)
+	data = binary.LittleEndian.AppendUint32(data, serverId)
+	return c.command(comBinlogDump, append(data, file...))
+}
+
+// readEvent reads the next binlog event after the binlog dump command
+func (c *mysqlConn) readEvent() ([]byte, error) {
+	p, err := c.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	switch p[0] {
+	case packetOK:
+		return p[1:], nil
+	case packetERR:
+		return nil, parseMysqlError(p)
+	case packetEOF:
+		return nil, io.EOF
+	default:
+		return nil, fmt.Errorf("unexpected binlog packet %x", p[0])
+	}
+}
+
+func (c *mysqlConn) close() error {
+	return c.conn.Close()
+}
+
+// mysqlReplicator reads the row events of a table from the binlog as a replica. The binlog_format must be ROW.
+type mysqlReplicator struct {
+	u        *url.URL
+	ssl      *sslConf
+	serverId uint32
+	schema   string
+	table    string
+
+	conn      *mysqlConn
+	closeOnce sync.Once
+	checksum  bool
+	// file and pos are the position of the next event
+	file string
+	pos  uint32
+	// tables are the table maps of the captured table by the table id
+	tables map[uint64]*tableMap
+	// columns are the column names from the information schema if the binlog has no column names. They are stale
+	// after a DDL statement of the table.
+	columns      []string
+	columnsStale bool
+}
+
+func newMysqlReplicator(u *url.URL, ssl *sslConf, serverId uint32, schema string, table string) *mysqlReplicator {
+	return &mysqlReplicator{u: u, ssl: ssl, serverId: serverId, schema: schema, table: table, tables: make(map[uint64]*tableMap)}
+}
+
+func (m *mysqlReplicator) dial() (*mysqlConn, error) {
+	host := m.u.Host
+	if m.u.Port() == "" {
+		host = net.JoinHostPort(m.u.Hostname(), "3306")
+	}
+	password, _ := m.u.User.Password()
+	return dialMysql(host, m.u.User.Username(), password, m.ssl)
+}
+
+func (m *mysqlReplicator) start(offset map[string]interface{}) error {
+	conn, err := m.dial()
+	if err != nil {
+		return err
+	}
+	m.conn = conn
+	rows, err := conn.query("SELECT @@global.binlog_checksum")
+	if err != nil {
+		return err
+	}
+	if len(rows) > 0 && rows[0][0] != "" && rows[0][0] != "NONE" {
+		m.checksum = true
+		if _, err := conn.query("SET @master_binlog_checksum = @@global.binlog_checksum"); err != nil {
+			return err
+		}
+	}
+	if len(offset) > 0 {
+		m.file, err = cast.ToString(offset["file"], cast.CONVERT_SAMEKIND)
+		if err != nil {
+			return fmt.Errorf("invalid binlog file in offset %v", offset)
+		}
+		pos, err := cast.ToInt64(offset["pos"], cast.CONVERT_ALL)
+		if err != nil {
+			return fmt.Errorf("invalid binlog position in offset %v", offset)
+		}
+		m.pos = uint32(pos)
+	} else {
+		rows, err := conn.query("SHOW MASTER STATUS")
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 || len(rows[0]) < 2 {
+			return fmt.Errorf("binary log is not enabled")
+		}
+		pos, err := cast.ToInt64(rows[0][1], cast.CONVERT_ALL)
+		if err != nil {
+			return fmt.Errorf("invalid binlog position %s", rows[0][1])
+		}
+		m.file, m.pos = rows[0][0], uint32(pos)
+	}
+	if err := m.loadColumns(conn); err != nil {
+		return err
+	}
+	return conn.binlogDump(m.serverId, m.file, m.pos)
+}
+
+// loadColumns reads the column names of the table from the information schema
+func (m *mysqlReplicator) loadColumns(conn *mysqlConn) error {
+	rows, err := conn.query(fmt.Sprintf("SELECT COLUMN_NAME FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = '%s' AND TABLE_NAME = '%s' ORDER BY ORDINAL_POSITION", escapeQuote(m.schema), escapeQuote(m.table)))
+	if err != nil {
+		return err
+	}
+	columns := make([]string, 0, len(rows))
+	for _, row := range rows {
+		columns = append(columns, row[0])
+	}
+	m.columns = columns
+	m.columnsStale = false
+	return nil
+}
+
+// refreshColumns reloads the column names by another connection because the replication connection is streaming. The
+// names are the current ones, which may be newer than the binlog being read if the replication lags behind.
+func (m *mysqlReplicator) refreshColumns() error {
+	conn, err := m.dial()
+	if err != nil {
+		return fmt.Errorf("fail to refresh the columns of %s.%s: %v", m.schema, m.table, err)
+	}
+	defer conn.close()
+	if err := m.loadColumns(conn); err != nil {
+		return fmt.Errorf("fail to refresh the columns of %s.%s: %v", m.schema, m.table, err)
+	}
+	return nil
+}
+
+// alters returns whether the statement is a DDL which may change the columns of the captured table
+func (m *mysqlReplicator) alters(q string) bool {
+	fields := strings.Fields(q)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "ALTER", "CREATE", "DROP", "RENAME":
+		return strings.Contains(strings.ToLower(q), strings.ToLower(m.table))
+	}
+	return false
+}
+
+func escapeQuote(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), "'", "''")
+}
+
+func (m *mysqlReplicator) next() (*transaction, error) {
+	tx := &transaction{}
+	for {
+		ev, err := m.conn.readEvent()
+		if err != nil {
+			return nil, err
+		}
+		h, err := parseEventHeader(ev)
+		if err != nil {
+			return nil, fmt.Errorf("invalid binlog event header: %v", err)
+		}
+		body := ev[eventHeaderLength:]
+		if m.checksum {
+			if len(body) < checksumLength {
+				return nil, fmt.Errorf("invalid binlog event of type %d: too short", h.eventType)
+			}
+			if crc32.ChecksumIEEE(ev[:len(ev)-checksumLength]) != binary.LittleEndian.Uint32(ev[len(ev)-checksumLength:]) {
+				return nil, fmt.Errorf("binlog event of type %d at %s:%d has a wrong checksum", h.eventType, m.file, h.logPos)
+			}
+			body = body[:len(body)-checksumLength]
+		}
+		switch h.eventType {
+		case eventRotate:
+			r := newReader(body, binary.LittleEndian)
+			m.pos = uint32(r.u64())
+			m.file = string(r.rest())
+			continue
+		case eventTableMap:
+			id, t, err := parseTableMap(body)
+			if err != nil {
+				return nil, fmt.Errorf("invalid table map event: %v", err)
+			}
+			if t.schema == m.schema && t.table == m.table {
+				// The binlog without column names uses the names of the information schema, which are reloaded if the
+				// table is altered
+				if len(t.names) == 0 && (m.columnsStale || len(m.columns) != len(t.types)) {
+					if err := m.refreshColumns(); err != nil {
+						return nil, err
+					}
+				}
+				m.tables[id] = t
+			}
+		case eventWriteRowsV1, eventUpdateRowsV1, eventDeleteRowsV1, eventWriteRowsV2, eventUpdateRowsV2, eventDeleteRowsV2:
+			t, ok := m.tables[rowsTableId(body)]
+			if !ok {
+				break
+			}
+			e, err := parseRows(h.eventType, body, t)
+			if err != nil {
+				return nil, err
+			}
+			tx.events = append(tx.events, m.toChanges(h.eventType, t, e)...)
+		case eventXid:
+			m.advance(h)
+			return m.commit(tx, h), nil
+		case eventQuery:
+			// the non-transactional tables are committed by the COMMIT query instead of the XID event
+			q := parseQuery(body)
+			if strings.EqualFold(q, "COMMIT") {
+				m.advance(h)
+				return m.commit(tx, h), nil
+			}
+			if m.alters(q) {
+				m.columnsStale = true
+			}
+		}
+		m.advance(h)
+	}
+}
+
+// advance moves the position after the event. The artificial events and the heartbeats have no position.
+func (m *mysqlReplicator) advance(h *eventHeader) {
+	if h.logPos > 0 && h.eventType != eventHeartbeat {
+		m.pos = h.logPos
+	}
+}
+
+func (m *mysqlReplicator) commit(tx *transaction, h *eventHeader) *transaction {
+	tx.offset = map[string]interface{}{"file": m.file, "pos": int64(m.pos)}
+	tx.timestamp = int64(h.timestamp) * 1000
+	// the table ids are only valid in the transaction
+	for id := range m.tables {
+		delete(m.tables, id)
+	}
+	return tx
+}
+
+func parseQuery(b []byte) string {
+	r := newReader(b, binary.LittleEndian)
+	r.skip(8)
+	schemaLen := int(r.u8())
+	r.skip(2)
+	statusLen := int(r.u16())
+	r.skip(statusLen + schemaLen + 1)
+	return string(r.rest())
+}
+
+func (m *mysqlReplicator) toChanges(eventType byte, t *tableMap, e *rowsEvent) []*changeEvent {
+	names := t.names
+	if len(names) != len(t.types) {
+		names = m.columns
+	}
+	toMap := func(row []interface{}, present []bool) map[string]interface{} {
+		result := make(map[string]interface{}, len(row))
+		for i, v := range row {
+			if !present[i] {
+				continue
+			}
+			if i < len(names) {
+				result[names[i]] = v
+			} else {
+				result[fmt.Sprintf("col%d", i+1)] = v
+			}
+		}
+		return result
+	}
+	rowkind := rowkindOf(eventType)
+	var result []*changeEvent
+	if rowkind == ast.RowkindUpdate {
+		for i := 0; i+1 < len(e.rows); i += 2 {
+			result = append(result, &changeEvent{
+				rowkind: rowkind, schema: t.schema, table: t.table,
+				before: toMap(e.rows[i], e.present),
+				data:   toMap(e.rows[i+1], e.presentAfter),
+			})
+		}
+		return result
+	}
+	for _, row := range e.rows {
+		result = append(result, &changeEvent{rowkind: rowkind, schema: t.schema, table: t.table, data: toMap(row, e.present)})
+	}
+	return result
+}
+
+// ack is a no-op because the binlog is purged by its retention instead of the replicas
+func (m *mysqlReplicator) ack(_ map[string]interface{}) error {
+	return nil
+}
+
+func (m *mysqlReplicator) close() error {
+	var err error
+	m.closeOnce.Do(func() {
+		if m.conn != nil {
+			err = m.conn.close()
+		}
+	})
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
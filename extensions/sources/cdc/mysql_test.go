@@ -0,0 +1,565 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+const fakeScramble = "abcdefghijklmnopqrst"
+
+// fakeMysql is an in-process MySQL server which supports TLS, the native password and the caching_sha2_password full
+// authentication, the queries run by the replicator and the binlog dump of the prepared events
+type fakeMysql struct {
+	ln       net.Listener
+	password string
+	checksum bool
+	columns  []string
+	// altered are the columns returned by the information schema after the first query
+	altered []string
+	events  [][]byte
+	// plugin is the auth plugin, mysql_native_password by default. The caching_sha2_password always requires the full
+	// authentication by the key.
+	plugin string
+	key    *rsa.PrivateKey
+	// tls accepts the ssl request if set
+	tls *tls.Config
+	// dumps receives the binlog file and position requested by the dump command
+	dumps chan string
+
+	mu           sync.Mutex
+	secured      bool
+	columnsQuery int
+}
+
+// newFakeMysql starts the server. The options set the fields read before the first packet of the client.
+func newFakeMysql(t *testing.T, password string, opts ...func(f *fakeMysql)) *fakeMysql {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	f := &fakeMysql{ln: ln, password: password, checksum: true, dumps: make(chan string, 10)}
+	for _, opt := range opts {
+		opt(f)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.handle(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeMysql) url(password string) string {
+	return fmt.Sprintf("mysql://repl:%s@%s/db", password, f.ln.Addr().String())
+}
+
+func (f *fakeMysql) handle(conn net.Conn) {
+	defer conn.Close()
+	c := &mysqlConn{conn: conn, r: bufio.NewReader(conn)}
+	plugin := f.plugin
+	if plugin == "" {
+		plugin = "mysql_native_password"
+	}
+	hs := []byte{10}
+	hs = append(hs, "8.0.33-fake\x00"...)
+	hs = binary.LittleEndian.AppendUint32(hs, 1)
+	hs = append(append(hs, fakeScramble[:8]...), 0)
+	caps := uint32(clientLongPassword | clientProtocol41 | clientSecureConnection | clientTransactions | clientPluginAuth)
+	if f.tls != nil {
+		caps |= clientSSL
+	}
+	hs = binary.LittleEndian.AppendUint16(hs, uint16(caps))
+	hs = append(hs, charsetUtf8mb4, 2, 0)
+	hs = binary.LittleEndian.AppendUint16(hs, uint16(caps>>16))
+	hs = append(hs, 21)
+	hs = append(hs, make([]byte, 10)...)
+	hs = append(append(hs, fakeScramble[8:]...), 0)
+	hs = append(append(hs, plugin...), 0)
+	if c.writePacket(hs) != nil {
+		return
+	}
+	p, err := c.readPacket()
+	if err != nil {
+		return
+	}
+	// the ssl request is the header of the handshake response only. The client hello may be buffered already.
+	if len(p) == 32 && binary.LittleEndian.Uint32(p)&clientSSL != 0 {
+		tc := tls.Server(&bufferedConn{Conn: conn, r: c.r}, f.tls)
+		if tc.Handshake() != nil {
+			return
+		}
+		c.conn, c.r = tc, bufio.NewReader(tc)
+		f.mu.Lock()
+		f.secured = true
+		f.mu.Unlock()
+		if p, err = c.readPacket(); err != nil {
+			return
+		}
+	}
+	r := newReader(p, binary.LittleEndian)
+	r.skip(32)
+	_ = r.cstring()
+	auth := r.bytes(int(r.u8()))
+	if plugin == "caching_sha2_password" {
+		err = f.fullAuth(c, auth)
+	} else if expected, _ := authResponse(plugin, []byte(fakeScramble), f.password); !bytes.Equal(auth, expected) {
+		err = fmt.Errorf("wrong auth response")
+	}
+	if err != nil {
+		_ = c.writePacket(append([]byte{packetERR, 0x15, 0x04}, "#28000Access denied for user 'repl'"...))
+		return
+	}
+	_ = c.writePacket([]byte{packetOK, 0, 0, 2, 0, 0, 0})
+	for {
+		p, err := c.readPacket()
+		if err != nil {
+			return
+		}
+		switch p[0] {
+		case comQuery:
+			q := string(p[1:])
+			switch {
+			case q == "SELECT @@global.binlog_checksum":
+				checksum := "NONE"
+				if f.checksum {
+					checksum = "CRC32"
+				}
+				f.writeRows(c, [][]string{{checksum}})
+			case strings.HasPrefix(q, "SET "):
+				_ = c.writePacket([]byte{packetOK, 0, 0, 2, 0, 0, 0})
+			case q == "SHOW MASTER STATUS":
+				f.writeRows(c, [][]string{{"binlog.000001", "4", "", "", ""}})
+			case strings.Contains(q, "information_schema.COLUMNS") && strings.Contains(q, "TABLE_NAME = 't1'"):
+				f.mu.Lock()
+				f.columnsQuery++
+				columns := f.columns
+				if f.columnsQuery > 1 && f.altered != nil {
+					columns = f.altered
+				}
+				f.mu.Unlock()
+				rows := make([][]string, len(columns))
+				for i, col := range columns {
+					rows[i] = []string{col}
+				}
+				f.writeRows(c, rows)
+			default:
+				_ = c.writePacket(append([]byte{packetERR, 0x28, 0x04}, "#42000unknown query"...))
+			}
+		case comBinlogDump:
+			r := newReader(p[1:], binary.LittleEndian)
+			pos := r.u32()
+			r.skip(6)
+			file := string(r.rest())
+			f.dumps <- fmt.Sprintf("%s:%d", file, pos)
+			// the artificial rotate event to the requested position
+			rotate := binary.LittleEndian.AppendUint64(nil, uint64(pos))
+			if c.writePacket(append([]byte{packetOK}, f.event(eventRotate, 0, 0, append(rotate, file...))...)) != nil {
+				return
+			}
+			for _, ev := range f.events {
+				if c.writePacket(append([]byte{packetOK}, ev...)) != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// bufferedConn reads the data buffered by the reader first
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (b *bufferedConn) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
+
+// fullAuth requires the password of caching_sha2_password in plain text over TLS or encrypted by the public key
+func (f *fakeMysql) fullAuth(c *mysqlConn, scrambled []byte) error {
+	if len(scrambled) == 0 {
+		return fmt.Errorf("empty auth response")
+	}
+	if err := c.writePacket([]byte{authMoreData, performFullAuth}); err != nil {
+		return err
+	}
+	p, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	secured := f.secured
+	f.mu.Unlock()
+	if !secured {
+		if !bytes.Equal(p, []byte{requestPublicKey}) {
+			return fmt.Errorf("public key is not requested")
+		}
+		der, _ := x509.MarshalPKIXPublicKey(&f.key.PublicKey)
+		if err := c.writePacket(append([]byte{authMoreData}, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})...)); err != nil {
+			return err
+		}
+		enc, err := c.readPacket()
+		if err != nil {
+			return err
+		}
+		if p, err = rsa.DecryptOAEP(sha1.New(), rand.Reader, f.key, enc, nil); err != nil {
+			return err
+		}
+		for i := range p {
+			p[i] ^= fakeScramble[i%len(fakeScramble)]
+		}
+	}
+	if string(p) != f.password+"\x00" {
+		return fmt.Errorf("wrong password")
+	}
+	return nil
+}
+
+func (f *fakeMysql) writeRows(c *mysqlConn, rows [][]string) {
+	n := 1
+	if len(rows) > 0 {
+		n = len(rows[0])
+	}
+	_ = c.writePacket(appendLenenc(nil, uint64(n)))
+	for i := 0; i < n; i++ {
+		def := []byte{3, 'd', 'e', 'f', 0, 0, 0, 1, 'c', 0, 0x0c, 0x21, 0, 0, 1, 0, 0, 0xfd, 0, 0, 0, 0, 0}
+		_ = c.writePacket(def)
+	}
+	_ = c.writePacket([]byte{packetEOF, 0, 0, 2, 0})
+	for _, row := range rows {
+		var p []byte
+		for _, v := range row {
+			p = appendLenenc(p, uint64(len(v)))
+			p = append(p, v...)
+		}
+		_ = c.writePacket(p)
+	}
+	_ = c.writePacket([]byte{packetEOF, 0, 0, 2, 0})
+}
+
+// event builds a binlog event with the checksum if enabled
+func (f *fakeMysql) event(eventType byte, timestamp uint32, logPos uint32, body []byte) []byte {
+	size := eventHeaderLength + len(body)
+	if f.checksum {
+		size += checksumLength
+	}
+	ev := binary.LittleEndian.AppendUint32(nil, timestamp)
+	ev = append(ev, eventType)
+	ev = binary.LittleEndian.AppendUint32(ev, 1)
+	ev = binary.LittleEndian.AppendUint32(ev, uint32(size))
+	ev = binary.LittleEndian.AppendUint32(ev, logPos)
+	ev = binary.LittleEndian.AppendUint16(ev, 0)
+	ev = append(ev, body...)
+	if f.checksum {
+		ev = binary.LittleEndian.AppendUint32(ev, crc32.ChecksumIEEE(ev))
+	}
+	return ev
+}
+
+func queryBody(q string) []byte {
+	b := make([]byte, 8)
+	b = append(b, 2, 0, 0, 0, 0)
+	b = append(b, "db\x00"...)
+	return append(b, q...)
+}
+
+func tableMapBody(id uint64, schema string, table string, types []byte, meta []byte, names []string, unsigned []byte) []byte {
+	b := binary.LittleEndian.AppendUint64(nil, id)[:6]
+	b = append(b, 1, 0)
+	b = append(append(append(b, byte(len(schema))), schema...), 0)
+	b = append(append(append(b, byte(len(table))), table...), 0)
+	b = append(appendLenenc(b, uint64(len(types))), types...)
+	b = append(appendLenenc(b, uint64(len(meta))), meta...)
+	b = append(b, make([]byte, (len(types)+7)/8)...)
+	if unsigned != nil {
+		b = append(appendLenenc(append(b, metaSignedness), uint64(len(unsigned))), unsigned...)
+	}
+	if names != nil {
+		var nb []byte
+		for _, n := range names {
+			nb = append(appendLenenc(nb, uint64(len(n))), n...)
+		}
+		b = append(appendLenenc(append(b, metaColumnName), uint64(len(nb))), nb...)
+	}
+	return b
+}
+
+func rowsBody(id uint64, n int, update bool, rows ...[]byte) []byte {
+	b := binary.LittleEndian.AppendUint64(nil, id)[:6]
+	b = append(b, 1, 0, 2, 0)
+	b = appendLenenc(b, uint64(n))
+	present := bytes.Repeat([]byte{0xff}, (n+7)/8)
+	b = append(b, present...)
+	if update {
+		b = append(b, present...)
+	}
+	for _, r := range rows {
+		b = append(b, r...)
+	}
+	return b
+}
+
+// the columns of the test table: id INT, name VARCHAR(80), price DECIMAL(14,4), updated DATETIME
+var (
+	testTypes = []byte{typeLong, typeVarchar, typeNewDecimal, typeDatetime2}
+	testMeta  = []byte{80, 0, 14, 4, 0}
+)
+
+// testRow encodes a row of the test table. The price is 1234567890.1234 or its negative.
+func testRow(id int32, name string, negative bool, nullUpdated bool) []byte {
+	nulls := byte(0)
+	if nullUpdated {
+		nulls = 0x08
+	}
+	b := []byte{nulls}
+	b = binary.LittleEndian.AppendUint32(b, uint32(id))
+	b = append(append(b, byte(len(name))), name...)
+	if negative {
+		b = append(b, 0x7e, 0xf2, 0x04, 0xc7, 0x2d, 0xfb, 0x2d)
+	} else {
+		b = append(b, 0x81, 0x0d, 0xfb, 0x38, 0xd2, 0x04, 0xd2)
+	}
+	if !nullUpdated {
+		b = append(b, datetime2(2023, 7, 1, 12, 30, 45)...)
+	}
+	return b
+}
+
+func datetime2(y, m, d, h, mi, s int64) []byte {
+	v := ((y*13+m)<<5|d)<<17 | h<<12 | mi<<6 | s
+	return binary.BigEndian.AppendUint64(nil, uint64(v+0x8000000000))[3:]
+}
+
+func TestDecodeValue(t *testing.T) {
+	tests := []struct {
+		typ      byte
+		meta     uint16
+		unsigned bool
+		b        []byte
+		v        interface{}
+	}{
+		{typ: typeTiny, b: []byte{0xff}, v: int64(-1)},
+		{typ: typeTiny, unsigned: true, b: []byte{0xff}, v: int64(255)},
+		{typ: typeInt24, b: []byte{0xfe, 0xff, 0xff}, v: int64(-2)},
+		{typ: typeLongLong, unsigned: true, b: bytes.Repeat([]byte{0xff}, 8), v: uint64(1<<64 - 1)},
+		{typ: typeYear, b: []byte{123}, v: int64(2023)},
+		{typ: typeNewDecimal, meta: 14<<8 | 4, b: []byte{0x81, 0x0d, 0xfb, 0x38, 0xd2, 0x04, 0xd2}, v: 1234567890.1234},
+		{typ: typeNewDecimal, meta: 14<<8 | 4, b: []byte{0x7e, 0xf2, 0x04, 0xc7, 0x2d, 0xfb, 0x2d}, v: -1234567890.1234},
+		{typ: typeNewDecimal, meta: 5<<8 | 2, b: []byte{0x80, 0x7b, 0x2d}, v: 123.45},
+		{typ: typeString, meta: uint16(typeString)<<8 | 40, b: []byte{2, 'a', 'b'}, v: "ab"},
+		{typ: typeString, meta: uint16(typeEnum)<<8 | 1, b: []byte{2}, v: int64(2)},
+		{typ: typeBlob, meta: 2, b: []byte{3, 0, 'x', 'y', 'z'}, v: "xyz"},
+		{typ: typeBit, meta: 1<<8 | 1, b: []byte{0x01, 0x05}, v: int64(261)},
+		{typ: typeDate, b: []byte{0xe1, 0xcc, 0x0f}, v: "2022-07-01"},
+		{typ: typeDatetime2, b: datetime2(2023, 7, 1, 12, 30, 45), v: time.Date(2023, 7, 1, 12, 30, 45, 0, time.UTC)},
+		{typ: typeDatetime2, meta: 3, b: append(datetime2(2023, 7, 1, 12, 30, 45), 0x04, 0xd2), v: time.Date(2023, 7, 1, 12, 30, 45, 123400000, time.UTC)},
+		{typ: typeTimestamp2, b: []byte{0x64, 0x9f, 0xfc, 0x25}, v: time.Unix(1688206373, 0).UTC()},
+		{typ: typeTime2, b: []byte{0x80, 0xc7, 0xad}, v: "12:30:45"},
+	}
+	for i, tt := range tests {
+		r := newReader(tt.b, binary.LittleEndian)
+		v, err := decodeValue(r, tt.typ, tt.meta, tt.unsigned)
+		require.NoError(t, err, "case %d", i)
+		assert.Equal(t, tt.v, v, "case %d", i)
+		assert.Equal(t, 0, r.remaining(), "case %d", i)
+	}
+	_, err := decodeValue(newReader([]byte{1}, binary.LittleEndian), typeJSON, 4, false)
+	assert.EqualError(t, err, "unsupported column type 245")
+}
+
+func TestParseTableMap(t *testing.T) {
+	id, tm, err := parseTableMap(tableMapBody(42, "db", "t1", []byte{typeTiny, typeVarchar, typeLong}, []byte{10, 0}, []string{"a", "b", "c"}, []byte{0x40}))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(42), id)
+	assert.Equal(t, &tableMap{
+		schema:   "db",
+		table:    "t1",
+		types:    []byte{typeTiny, typeVarchar, typeLong},
+		meta:     []uint16{0, 10, 0},
+		unsigned: []bool{false, false, true},
+		names:    []string{"a", "b", "c"},
+	}, tm)
+}
+
+func TestMysqlCapture(t *testing.T) {
+	f := newFakeMysql(t, "secret")
+	f.columns = []string{"id", "name", "price", "updated"}
+	updated := time.Date(2023, 7, 1, 12, 30, 45, 0, time.UTC)
+	f.events = [][]byte{
+		f.event(eventFormatDescription, 1688200000, 126, make([]byte, 80)),
+		f.event(eventQuery, 1688200001, 200, queryBody("BEGIN")),
+		f.event(eventTableMap, 1688200001, 250, tableMapBody(100, "db", "t1", testTypes, testMeta, nil, nil)),
+		f.event(eventWriteRowsV2, 1688200001, 300, rowsBody(100, 4, false, testRow(1, "a", false, false), testRow(2, "b", true, true))),
+		f.event(eventTableMap, 1688200001, 350, tableMapBody(101, "db", "other", []byte{typeLong}, nil, nil, nil)),
+		f.event(eventWriteRowsV2, 1688200001, 400, rowsBody(101, 1, false, []byte{0, 1, 0, 0, 0})),
+		f.event(eventXid, 1688200001, 431, make([]byte, 8)),
+		f.event(eventQuery, 1688200002, 500, queryBody("BEGIN")),
+		f.event(eventTableMap, 1688200002, 550, tableMapBody(100, "db", "t1", testTypes, testMeta, nil, nil)),
+		f.event(eventUpdateRowsV2, 1688200002, 600, rowsBody(100, 4, true, testRow(1, "a", false, false), testRow(1, "c", false, false))),
+		f.event(eventDeleteRowsV2, 1688200002, 650, rowsBody(100, 4, false, testRow(2, "b", true, true))),
+		f.event(eventXid, 1688200002, 681, make([]byte, 8)),
+	}
+
+	s := Cdc()
+	require.NoError(t, s.Configure("t1", map[string]interface{}{"url": f.url("wrong")}))
+	err := openError(t, s)
+	assert.Contains(t, err.Error(), "mysql error 1045: Access denied for user 'repl'")
+
+	s = Cdc()
+	require.NoError(t, s.Configure("t1", map[string]interface{}{"url": f.url("secret"), "serverId": 2001}))
+	_, result := runSource(t, s, 4)
+	assert.Equal(t, "binlog.000001:4", <-f.dumps)
+	exp := []struct {
+		msg    map[string]interface{}
+		before map[string]interface{}
+		offset interface{}
+	}{
+		{
+			msg: map[string]interface{}{"id": int64(1), "name": "a", "price": 1234567890.1234, "updated": updated, "action": ast.RowkindInsert},
+		},
+		{
+			msg:    map[string]interface{}{"id": int64(2), "name": "b", "price": -1234567890.1234, "updated": nil, "action": ast.RowkindInsert},
+			offset: map[string]interface{}{"file": "binlog.000001", "pos": int64(431)},
+		},
+		{
+			msg:    map[string]interface{}{"id": int64(1), "name": "c", "price": 1234567890.1234, "updated": updated, "action": ast.RowkindUpdate},
+			before: map[string]interface{}{"id": int64(1), "name": "a", "price": 1234567890.1234, "updated": updated},
+			offset: map[string]interface{}{"file": "binlog.000001", "pos": int64(431)},
+		},
+		{
+			msg:    map[string]interface{}{"id": int64(2), "name": "b", "price": -1234567890.1234, "updated": nil, "action": ast.RowkindDelete},
+			offset: map[string]interface{}{"file": "binlog.000001", "pos": int64(681)},
+		},
+	}
+	for i, e := range exp {
+		assert.Equal(t, e.msg, result[i].Message(), "case %d", i)
+		assert.Equal(t, "db", result[i].Meta()["schema"], "case %d", i)
+		assert.Equal(t, "t1", result[i].Meta()["table"], "case %d", i)
+		assert.Equal(t, e.msg["action"], result[i].Meta()["rowkind"], "case %d", i)
+		if e.before != nil {
+			assert.Equal(t, e.before, result[i].Meta()["before"], "case %d", i)
+		}
+		if e.offset == nil {
+			assert.Nil(t, offsetOf(result[i]), "case %d", i)
+		} else {
+			assert.Equal(t, e.offset, offsetOf(result[i]), "case %d", i)
+		}
+	}
+	assert.Equal(t, int64(1688200002000), result[3].Meta()["timestamp"])
+
+	// Continue from the offset of the checkpoint without the rowkind field
+	s2 := Cdc().(*cdcSource)
+	require.NoError(t, s2.Configure("db.t1", map[string]interface{}{"url": f.url("secret"), "rowkindField": ""}))
+	require.NoError(t, s2.Rewind(offsetOf(result[1])))
+	_, result = runSource(t, s2, 1)
+	assert.Equal(t, "binlog.000001:431", <-f.dumps)
+	assert.Equal(t, map[string]interface{}{"id": int64(1), "name": "a", "price": 1234567890.1234, "updated": updated}, result[0].Message())
+}
+
+// testEvents are the binlog events of a transaction inserting a row to the test table
+func (f *fakeMysql) testEvents() [][]byte {
+	return [][]byte{
+		f.event(eventFormatDescription, 1688200000, 126, make([]byte, 80)),
+		f.event(eventQuery, 1688200001, 200, queryBody("BEGIN")),
+		f.event(eventTableMap, 1688200001, 250, tableMapBody(100, "db", "t1", testTypes, testMeta, nil, nil)),
+		f.event(eventWriteRowsV2, 1688200001, 300, rowsBody(100, 4, false, testRow(1, "a", false, true))),
+		f.event(eventXid, 1688200001, 331, make([]byte, 8)),
+	}
+}
+
+func TestMysqlFullAuthAndTLS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	serverTLS, certFile := testServerTLS(t)
+	fullAuth := func(f *fakeMysql) {
+		f.plugin, f.key = "caching_sha2_password", key
+	}
+	f := newFakeMysql(t, "secret", fullAuth)
+	f.columns = []string{"id", "name", "price", "updated"}
+	f.events = f.testEvents()
+	exp := map[string]interface{}{"id": int64(1), "name": "a", "price": 1234567890.1234, "updated": nil, "action": ast.RowkindInsert}
+
+	s := Cdc()
+	require.NoError(t, s.Configure("t1", map[string]interface{}{"url": f.url("secret") + "?sslmode=require"}))
+	err = openError(t, s)
+	assert.Contains(t, err.Error(), "mysql server does not support TLS which is required by sslmode require")
+
+	// The password is encrypted by the public key without TLS
+	s = Cdc()
+	require.NoError(t, s.Configure("t1", map[string]interface{}{"url": f.url("wrong")}))
+	err = openError(t, s)
+	assert.Contains(t, err.Error(), "mysql error 1045: Access denied for user 'repl'")
+	s = Cdc()
+	require.NoError(t, s.Configure("t1", map[string]interface{}{"url": f.url("secret")}))
+	_, result := runSource(t, s, 1)
+	assert.Equal(t, exp, result[0].Message())
+
+	// The password is sent in plain text over TLS
+	tf := newFakeMysql(t, "secret", fullAuth, func(f *fakeMysql) { f.tls = serverTLS })
+	tf.columns = f.columns
+	tf.events = tf.testEvents()
+	s = Cdc()
+	require.NoError(t, s.Configure("t1", map[string]interface{}{"url": tf.url("secret") + "?sslmode=verify-full&sslrootcert=" + certFile}))
+	_, result = runSource(t, s, 1)
+	assert.Equal(t, exp, result[0].Message())
+	tf.mu.Lock()
+	assert.True(t, tf.secured)
+	tf.mu.Unlock()
+}
+
+func TestMysqlColumnRefresh(t *testing.T) {
+	f := newFakeMysql(t, "secret")
+	f.columns = []string{"id", "name", "price", "updated"}
+	f.altered = []string{"id", "title", "price", "updated"}
+	f.events = append(f.testEvents(),
+		f.event(eventQuery, 1688200002, 400, queryBody("ALTER TABLE `t1` RENAME COLUMN name TO title")),
+		f.event(eventQuery, 1688200003, 450, queryBody("BEGIN")),
+		f.event(eventTableMap, 1688200003, 500, tableMapBody(101, "db", "t1", testTypes, testMeta, nil, nil)),
+		f.event(eventWriteRowsV2, 1688200003, 550, rowsBody(101, 4, false, testRow(2, "b", false, true))),
+		f.event(eventXid, 1688200003, 581, make([]byte, 8)),
+	)
+	s := Cdc()
+	require.NoError(t, s.Configure("t1", map[string]interface{}{"url": f.url("secret")}))
+	_, result := runSource(t, s, 2)
+	assert.Equal(t, map[string]interface{}{"id": int64(1), "name": "a", "price": 1234567890.1234, "updated": nil, "action": ast.RowkindInsert}, result[0].Message())
+	assert.Equal(t, map[string]interface{}{"id": int64(2), "title": "b", "price": 1234567890.1234, "updated": nil, "action": ast.RowkindInsert}, result[1].Message())
+	f.mu.Lock()
+	assert.Equal(t, 2, f.columnsQuery)
+	f.mu.Unlock()
+}
@@ -0,0 +1,699 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lf-edge/ekuiper/pkg/ast"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+const (
+	pgProtocolVersion = 196608
+	pgSSLRequestCode  = 80877103
+	// pgEpochMicros is the unix time of the postgres epoch 2000-01-01 in microseconds
+	pgEpochMicros = 946684800000000
+	// pgDuplicateObject is the error code when the replication slot already exists
+	pgDuplicateObject = "42710"
+)
+
+// type oids of postgres
+const (
+	oidBool        = 16
+	oidInt8        = 20
+	oidInt2        = 21
+	oidInt4        = 23
+	oidJSON        = 114
+	oidFloat4      = 700
+	oidFloat8      = 701
+	oidTimestamp   = 1114
+	oidTimestamptz = 1184
+	oidNumeric     = 1700
+	oidJSONB       = 3802
+)
+
+type pgError struct {
+	code    string
+	message string
+}
+
+func (e *pgError) Error() string {
+	return fmt.Sprintf("postgres error %s: %s", e.code, e.message)
+}
+
+func parsePgError(b []byte) *pgError {
+	e := &pgError{}
+	r := newReader(b, binary.BigEndian)
+	for r.err == nil {
+		field := r.u8()
+		if field == 0 {
+			break
+		}
+		v := r.cstring()
+		switch field {
+		case 'C':
+			e.code = v
+		case 'M':
+			e.message = v
+		}
+	}
+	return e
+}
+
+// pgConn is a minimal client of the postgres protocol in the replication mode
+type pgConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	// wmu serializes the writing of the standby status and the other messages
+	wmu sync.Mutex
+}
+
+func dialPg(address string, user string, password string, database string, ssl *sslConf) (*pgConn, error) {
+	conn, err := net.DialTimeout("tcp", address, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	c := &pgConn{conn: conn}
+	if ssl.enabled() {
+		if err := c.requestSSL(ssl); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	c.r = bufio.NewReader(c.conn)
+	if err := c.startup(user, password, database); err != nil {
+		_ = c.conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// requestSSL upgrades the connection to TLS if the server accepts the SSLRequest
+func (c *pgConn) requestSSL(ssl *sslConf) error {
+	if err := c.writeMsg(0, binary.BigEndian.AppendUint32(nil, pgSSLRequestCode)); err != nil {
+		return err
+	}
+	resp := make([]byte, 1)
+	if _, err := io.ReadFull(c.conn, resp); err != nil {
+		return err
+	}
+	switch resp[0] {
+	case 'S':
+		tc := tls.Client(c.conn, ssl.config)
+		if err := tc.Handshake(); err != nil {
+			return fmt.Errorf("postgres tls handshake error: %v", err)
+		}
+		c.conn = tc
+	case 'N':
+		if ssl.required() {
+			return fmt.Errorf("postgres server does not support TLS which is required by sslmode %s", ssl.mode)
+		}
+	default:
+		return fmt.Errorf("unexpected postgres response %q to the ssl request", resp[0])
+	}
+	return nil
+}
+
+func (c *pgConn) readMsg() (byte, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(c.r, header); err != nil {
+		return 0, nil, err
+	}
+	n := int(binary.BigEndian.Uint32(header[1:])) - 4
+	if n < 0 {
+		return 0, nil, fmt.Errorf("invalid postgres message length %d", n)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return 0, nil, err
+	}
+	return header[0], body, nil
+}
+
+func (c *pgConn) writeMsg(typ byte, body []byte) error {
+	buf := make([]byte, 0, 5+len(body))
+	if typ != 0 {
+		buf = append(buf, typ)
+	}
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(body)+4))
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := c.conn.Write(append(buf, body...))
+	return err
+}
+
+func (c *pgConn) startup(user string, password string, database string) error {
+	body := binary.BigEndian.AppendUint32(nil, pgProtocolVersion)
+	for _, p := range [][2]string{{"user", user}, {"database", database}, {"replication", "database"}} {
+		body = append(append(body, p[0]...), 0)
+		body = append(append(body, p[1]...), 0)
+	}
+	if err := c.writeMsg(0, append(body, 0)); err != nil {
+		return err
+	}
+	for {
+		typ, body, err := c.readMsg()
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case 'R':
+			r := newReader(body, binary.BigEndian)
+			switch code := r.u32(); code {
+			case 0:
+			case 3:
+				if err := c.writeMsg('p', append([]byte(password), 0)); err != nil {
+					return err
+				}
+			case 5:
+				// md5(md5(password + user) + salt)
+				inner := md5.Sum([]byte(password + user))
+				outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), r.bytes(4)...))
+				if err := c.writeMsg('p', append([]byte("md5"+hex.EncodeToString(outer[:])), 0)); err != nil {
+					return err
+				}
+			case 10:
+				if err := c.scram(password, r.rest()); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unsupported postgres authentication method %d", code)
+			}
+		case 'E':
+			return parsePgError(body)
+		case 'Z':
+			return nil
+		}
+	}
+}
+
+// scram authenticates by SCRAM-SHA-256 without channel binding
+func (c *pgConn) scram(password string, mechanisms []byte) error {
+	if !bytes.Contains(mechanisms, []byte("SCRAM-SHA-256\x00")) {
+		return fmt.Errorf("unsupported postgres sasl mechanisms %q", mechanisms)
+	}
+	nonce := make([]byte, 18)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	clientNonce := base64.StdEncoding.EncodeToString(nonce)
+	clientFirstBare := "n=,r=" + clientNonce
+	first := "n,," + clientFirstBare
+	body := append([]byte("SCRAM-SHA-256"), 0)
+	body = binary.BigEndian.AppendUint32(body, uint32(len(first)))
+	if err := c.writeMsg('p', append(body, first...)); err != nil {
+		return err
+	}
+	serverFirst, err := c.saslMsg(11)
+	if err != nil {
+		return err
+	}
+	attrs := scramAttributes(serverFirst)
+	if !strings.HasPrefix(attrs["r"], clientNonce) {
+		return fmt.Errorf("invalid scram server nonce")
+	}
+	salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+	if err != nil {
+		return fmt.Errorf("invalid scram salt: %v", err)
+	}
+	iterations, err := strconv.Atoi(attrs["i"])
+	if err != nil {
+		return fmt.Errorf("invalid scram iterations: %v", err)
+	}
+	salted := pbkdf2(password, salt, iterations)
+	clientKey := hmacSum(salted, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	clientFinal := "c=biws,r=" + attrs["r"]
+	authMessage := clientFirstBare + "," + serverFirst + "," + clientFinal
+	proof := hmacSum(storedKey[:], authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+	if err := c.writeMsg('p', []byte(clientFinal+",p="+base64.StdEncoding.EncodeToString(proof))); err != nil {
+		return err
+	}
+	serverFinal, err := c.saslMsg(12)
+	if err != nil {
+		return err
+	}
+	signature := hmacSum(hmacSum(salted, "Server Key"), authMessage)
+	if scramAttributes(serverFinal)["v"] != base64.StdEncoding.EncodeToString(signature) {
+		return fmt.Errorf("invalid scram server signature")
+	}
+	return nil
+}
+
+// saslMsg reads the sasl authentication message of the code
+func (c *pgConn) saslMsg(code uint32) (string, error) {
+	typ, body, err := c.readMsg()
+	if err != nil {
+		return "", err
+	}
+	if typ == 'E' {
+		return "", parsePgError(body)
+	}
+	r := newReader(body, binary.BigEndian)
+	if typ != 'R' || r.u32() != code {
+		return "", fmt.Errorf("unexpected postgres message %c during sasl authentication", typ)
+	}
+	return string(r.rest()), nil
+}
+
+func scramAttributes(s string) map[string]string {
+	result := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+func hmacSum(key []byte, msg string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(msg))
+	return h.Sum(nil)
+}
+
+// pbkdf2 derives the key of the size of one sha256 block which is enough for scram
+func pbkdf2(password string, salt []byte, iterations int) []byte {
+	h := hmac.New(sha256.New, []byte(password))
+	h.Write(salt)
+	h.Write([]byte{0, 0, 0, 1})
+	u := h.Sum(nil)
+	result := append([]byte{}, u...)
+	for i := 1; i < iterations; i++ {
+		h.Reset()
+		h.Write(u)
+		u = h.Sum(u[:0])
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+	return result
+}
+
+// exec runs the simple query and discards the results
+func (c *pgConn) exec(q string) error {
+	if err := c.writeMsg('Q', append([]byte(q), 0)); err != nil {
+		return err
+	}
+	var result error
+	for {
+		typ, body, err := c.readMsg()
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case 'E':
+			result = parsePgError(body)
+		case 'Z':
+			return result
+		}
+	}
+}
+
+// startReplication sends the replication command and waits for the copy mode
+func (c *pgConn) startReplication(q string) error {
+	if err := c.writeMsg('Q', append([]byte(q), 0)); err != nil {
+		return err
+	}
+	var result error
+	for {
+		typ, body, err := c.readMsg()
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case 'W':
+			return nil
+		case 'E':
+			result = parsePgError(body)
+		case 'Z':
+			if result == nil {
+				result = fmt.Errorf("replication is not started")
+			}
+			return result
+		}
+	}
+}
+
+func parseLSN(s string) (uint64, error) {
+	var hi, lo uint32
+	if _, err := fmt.Sscanf(s, "%X/%X", &hi, &lo); err != nil {
+		return 0, fmt.Errorf("invalid lsn %s", s)
+	}
+	return uint64(hi)<<32 | uint64(lo), nil
+}
+
+func formatLSN(lsn uint64) string {
+	return fmt.Sprintf("%X/%X", uint32(lsn>>32), uint32(lsn))
+}
+
+func quoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+type pgColumn struct {
+	name string
+	oid  uint32
+}
+
+type pgRelation struct {
+	schema  string
+	table   string
+	columns []pgColumn
+}
+
+// pgReplicator reads the changes of a table by the logical replication with the pgoutput plugin. The table must be
+// in the publication. The offset is the lsn after the transaction, which is acknowledged to the slot so that the
+// database can recycle the wal.
+type pgReplicator struct {
+	u           *url.URL
+	ssl         *sslConf
+	slot        string
+	publication string
+	schema      string
+	table       string
+
+	// mu guards the conn which is written by the ack and closed by another goroutine
+	mu        sync.Mutex
+	conn      *pgConn
+	closeOnce sync.Once
+	relations map[uint32]*pgRelation
+	tx        *transaction
+	// received is the end of the received wal and acked is the acknowledged lsn, accessed atomically
+	received uint64
+	acked    uint64
+}
+
+func newPgReplicator(u *url.URL, ssl *sslConf, slot string, publication string, schema string, table string) *pgReplicator {
+	return &pgReplicator{u: u, ssl: ssl, slot: slot, publication: publication, schema: schema, table: table, relations: make(map[uint32]*pgRelation)}
+}
+
+func (p *pgReplicator) start(offset map[string]interface{}) error {
+	host := p.u.Host
+	if p.u.Port() == "" {
+		host = net.JoinHostPort(p.u.Hostname(), "5432")
+	}
+	user := p.u.User.Username()
+	password, _ := p.u.User.Password()
+	database := strings.TrimPrefix(p.u.Path, "/")
+	if database == "" {
+		database = user
+	}
+	conn, err := dialPg(host, user, password, database, p.ssl)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.conn = conn
+	p.mu.Unlock()
+	if err := conn.exec(fmt.Sprintf("CREATE_REPLICATION_SLOT %s LOGICAL pgoutput", quoteIdent(p.slot))); err != nil {
+		var pe *pgError
+		if !errors.As(err, &pe) || pe.code != pgDuplicateObject {
+			return fmt.Errorf("fail to create replication slot %s: %v", p.slot, err)
+		}
+	}
+	// 0/0 starts from the confirmed position of the slot
+	var lsn uint64
+	if len(offset) > 0 {
+		s, err := cast.ToString(offset["lsn"], cast.CONVERT_SAMEKIND)
+		if err != nil {
+			return fmt.Errorf("invalid lsn in offset %v", offset)
+		}
+		lsn, err = parseLSN(s)
+		if err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&p.acked, lsn)
+	atomic.StoreUint64(&p.received, lsn)
+	return conn.startReplication(fmt.Sprintf("START_REPLICATION SLOT %s LOGICAL %s (proto_version '1', publication_names '%s')",
+		quoteIdent(p.slot), formatLSN(lsn), strings.ReplaceAll(p.publication, "'", "''")))
+}
+
+func (p *pgReplicator) next() (*transaction, error) {
+	for {
+		typ, body, err := p.conn.readMsg()
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case 'd':
+			r := newReader(body, binary.BigEndian)
+			switch r.u8() {
+			case 'w':
+				r.skip(8)
+				p.receive(r.u64())
+				r.skip(8)
+				tx, err := p.decode(r.rest())
+				if err != nil {
+					return nil, err
+				}
+				if tx != nil {
+					return tx, nil
+				}
+			case 'k':
+				p.receive(r.u64())
+				r.skip(8)
+				if r.u8() == 1 {
+					if err := p.sendStatus(); err != nil {
+						return nil, err
+					}
+				}
+			}
+		case 'E':
+			return nil, parsePgError(body)
+		case 'c':
+			return nil, io.EOF
+		}
+	}
+}
+
+func (p *pgReplicator) receive(lsn uint64) {
+	if lsn > atomic.LoadUint64(&p.received) {
+		atomic.StoreUint64(&p.received, lsn)
+	}
+}
+
+// decode decodes the pgoutput message and returns the transaction when it is committed
+func (p *pgReplicator) decode(b []byte) (*transaction, error) {
+	r := newReader(b, binary.BigEndian)
+	switch r.u8() {
+	case 'B':
+		r.skip(8)
+		p.tx = &transaction{timestamp: (int64(r.u64()) + pgEpochMicros) / 1000}
+	case 'C':
+		r.skip(1 + 8)
+		end := r.u64()
+		ts := int64(r.u64())
+		tx := p.tx
+		p.tx = nil
+		if tx == nil {
+			tx = &transaction{}
+		}
+		tx.offset = map[string]interface{}{"lsn": formatLSN(end)}
+		tx.timestamp = (ts + pgEpochMicros) / 1000
+		return tx, r.err
+	case 'R':
+		id := r.u32()
+		rel := &pgRelation{schema: r.cstring(), table: r.cstring()}
+		r.skip(1)
+		n := int(r.u16())
+		for i := 0; i < n && r.err == nil; i++ {
+			r.skip(1)
+			rel.columns = append(rel.columns, pgColumn{name: r.cstring(), oid: r.u32()})
+			r.skip(4)
+		}
+		p.relations[id] = rel
+	case 'I', 'U', 'D':
+		ev, err := p.decodeChange(b[0], r)
+		if err != nil {
+			return nil, err
+		}
+		if ev != nil {
+			if p.tx == nil {
+				return nil, fmt.Errorf("change of %s.%s is out of transaction", ev.schema, ev.table)
+			}
+			p.tx.events = append(p.tx.events, ev)
+		}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("invalid pgoutput message %c: %v", b[0], r.err)
+	}
+	return nil, nil
+}
+
+// decodeChange decodes the insert, update or delete message. It returns nil if the table is not the captured one.
+func (p *pgReplicator) decodeChange(kind byte, r *reader) (*changeEvent, error) {
+	rel, ok := p.relations[r.u32()]
+	if !ok {
+		return nil, fmt.Errorf("pgoutput message %c refers to an unknown relation", kind)
+	}
+	if rel.schema != p.schema || rel.table != p.table {
+		return nil, nil
+	}
+	ev := &changeEvent{schema: rel.schema, table: rel.table}
+	switch kind {
+	case 'I':
+		ev.rowkind = ast.RowkindInsert
+		r.skip(1)
+		ev.data = decodeTuple(r, rel)
+	case 'U':
+		ev.rowkind = ast.RowkindUpdate
+		// the old key or the old row is only sent if the replica identity is changed or full
+		if t := r.u8(); t == 'K' || t == 'O' {
+			ev.before = decodeTuple(r, rel)
+			r.skip(1)
+		}
+		ev.data = decodeTuple(r, rel)
+	case 'D':
+		ev.rowkind = ast.RowkindDelete
+		r.skip(1)
+		ev.data = decodeTuple(r, rel)
+	}
+	return ev, r.err
+}
+
+// decodeTuple decodes the columns of a tuple. The unchanged toasted values are not sent so they are absent.
+func decodeTuple(r *reader, rel *pgRelation) map[string]interface{} {
+	n := int(r.u16())
+	result := make(map[string]interface{}, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		var col pgColumn
+		if i < len(rel.columns) {
+			col = rel.columns[i]
+		} else {
+			col.name = fmt.Sprintf("col%d", i+1)
+		}
+		switch r.u8() {
+		case 'n':
+			result[col.name] = nil
+		case 't':
+			result[col.name] = decodePgText(col.oid, string(r.bytes(int(r.u32()))))
+		case 'b':
+			result[col.name] = r.bytes(int(r.u32()))
+		}
+	}
+	return result
+}
+
+// decodePgText converts the text value of the common types. The other types and the values failing to convert are
+// kept as strings.
+func decodePgText(oid uint32, s string) interface{} {
+	switch oid {
+	case oidBool:
+		return s == "t"
+	case oidInt2, oidInt4, oidInt8:
+		if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return v
+		}
+	case oidFloat4, oidFloat8, oidNumeric:
+		if v, err := strconv.ParseFloat(s, 64); err == nil {
+			return v
+		}
+	case oidJSON, oidJSONB:
+		var v interface{}
+		if err := json.Unmarshal([]byte(s), &v); err == nil {
+			return v
+		}
+	case oidTimestamp:
+		if v, err := time.Parse("2006-01-02 15:04:05.999999999", s); err == nil {
+			return v
+		}
+	case oidTimestamptz:
+		for _, layout := range []string{"2006-01-02 15:04:05.999999999-07", "2006-01-02 15:04:05.999999999-07:00"} {
+			if v, err := time.Parse(layout, s); err == nil {
+				return v
+			}
+		}
+	}
+	return s
+}
+
+// ack acknowledges the lsn as flushed so that the slot can move forward
+func (p *pgReplicator) ack(offset map[string]interface{}) error {
+	s, err := cast.ToString(offset["lsn"], cast.CONVERT_SAMEKIND)
+	if err != nil {
+		return fmt.Errorf("invalid lsn in offset %v", offset)
+	}
+	lsn, err := parseLSN(s)
+	if err != nil {
+		return err
+	}
+	if lsn <= atomic.LoadUint64(&p.acked) {
+		return nil
+	}
+	atomic.StoreUint64(&p.acked, lsn)
+	return p.sendStatus()
+}
+
+// sendStatus sends the standby status update with the received and the acknowledged lsn
+func (p *pgReplicator) sendStatus() error {
+	p.mu.Lock()
+	conn := p.conn
+	p.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+	acked := atomic.LoadUint64(&p.acked)
+	received := atomic.LoadUint64(&p.received)
+	if received < acked {
+		received = acked
+	}
+	body := []byte{'r'}
+	body = binary.BigEndian.AppendUint64(body, received)
+	body = binary.BigEndian.AppendUint64(body, acked)
+	body = binary.BigEndian.AppendUint64(body, acked)
+	body = binary.BigEndian.AppendUint64(body, uint64(time.Now().UnixMicro()-pgEpochMicros))
+	return conn.writeMsg('d', append(body, 0))
+}
+
+func (p *pgReplicator) close() error {
+	var err error
+	p.closeOnce.Do(func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.conn != nil {
+			err = p.conn.conn.Close()
+		}
+	})
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,392 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+// fakePostgres is an in-process PostgreSQL server which supports the SSLRequest, the SCRAM-SHA-256 authentication,
+// the replication slot creation and the logical replication of the prepared pgoutput messages
+type fakePostgres struct {
+	ln       net.Listener
+	password string
+	messages [][]byte
+	// tls accepts the SSLRequest if set
+	tls *tls.Config
+
+	mu          sync.Mutex
+	slotCreated bool
+	secured     bool
+	// starts receives the lsn requested by START_REPLICATION and flushed receives the flushed lsn of the status
+	starts  chan string
+	flushed chan uint64
+}
+
+func newFakePostgres(t *testing.T, password string) *fakePostgres {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	f := &fakePostgres{ln: ln, password: password, starts: make(chan string, 10), flushed: make(chan uint64, 100)}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.handle(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakePostgres) url(password string) string {
+	return fmt.Sprintf("postgres://repl:%s@%s/db", password, f.ln.Addr().String())
+}
+
+func (f *fakePostgres) handle(conn net.Conn) {
+	defer conn.Close()
+	c := &pgConn{conn: conn, r: bufio.NewReader(conn)}
+	body, err := readStartup(c.r)
+	if err != nil {
+		return
+	}
+	if binary.BigEndian.Uint32(body) == pgSSLRequestCode {
+		if f.tls == nil {
+			_, _ = conn.Write([]byte{'N'})
+		} else {
+			_, _ = conn.Write([]byte{'S'})
+			tc := tls.Server(&bufferedConn{Conn: conn, r: c.r}, f.tls)
+			if tc.Handshake() != nil {
+				return
+			}
+			c.conn, c.r = tc, bufio.NewReader(tc)
+			f.mu.Lock()
+			f.secured = true
+			f.mu.Unlock()
+		}
+		if _, err := readStartup(c.r); err != nil {
+			return
+		}
+	}
+	if err := f.auth(c); err != nil {
+		_ = c.writeMsg('E', []byte("SFATAL\x00C28P01\x00M"+err.Error()+"\x00\x00"))
+		return
+	}
+	_ = c.writeMsg('R', []byte{0, 0, 0, 0})
+	_ = c.writeMsg('Z', []byte{'I'})
+	for {
+		typ, body, err := c.readMsg()
+		if err != nil {
+			return
+		}
+		switch typ {
+		case 'Q':
+			q := strings.TrimRight(string(body), "\x00")
+			switch {
+			case strings.HasPrefix(q, "CREATE_REPLICATION_SLOT"):
+				f.mu.Lock()
+				created := f.slotCreated
+				f.slotCreated = true
+				f.mu.Unlock()
+				if created {
+					_ = c.writeMsg('E', []byte("SERROR\x00C42710\x00Mreplication slot \"s1\" already exists\x00\x00"))
+				} else {
+					_ = c.writeMsg('C', []byte("CREATE_REPLICATION_SLOT\x00"))
+				}
+				_ = c.writeMsg('Z', []byte{'I'})
+			case strings.HasPrefix(q, "START_REPLICATION"):
+				f.starts <- strings.Fields(q)[4]
+				_ = c.writeMsg('W', []byte{0, 0, 0})
+				for _, m := range f.messages {
+					data := append([]byte{'w'}, make([]byte, 24)...)
+					_ = c.writeMsg('d', append(data, m...))
+				}
+				// request the status
+				keepalive := append([]byte{'k'}, make([]byte, 16)...)
+				_ = c.writeMsg('d', append(keepalive, 1))
+			default:
+				_ = c.writeMsg('E', []byte("SERROR\x00C42601\x00Msyntax error\x00\x00"))
+				_ = c.writeMsg('Z', []byte{'I'})
+			}
+		case 'd':
+			if len(body) == 34 && body[0] == 'r' {
+				f.flushed <- binary.BigEndian.Uint64(body[9:])
+			}
+		case 'X':
+			return
+		}
+	}
+}
+
+// readStartup reads the untyped startup or SSLRequest packet
+func readStartup(r io.Reader) ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	body := make([]byte, binary.BigEndian.Uint32(header)-4)
+	_, err := io.ReadFull(r, body)
+	return body, err
+}
+
+// auth verifies the client proof of SCRAM-SHA-256
+func (f *fakePostgres) auth(c *pgConn) error {
+	_ = c.writeMsg('R', append([]byte{0, 0, 0, 10}, "SCRAM-SHA-256\x00\x00"...))
+	_, body, err := c.readMsg()
+	if err != nil {
+		return err
+	}
+	r := newReader(body, binary.BigEndian)
+	_ = r.cstring()
+	clientFirstBare := strings.TrimPrefix(string(r.bytes(int(r.u32()))), "n,,")
+	salt := []byte("fakesalt")
+	serverFirst := fmt.Sprintf("r=%sserver,s=%s,i=16", scramAttributes(clientFirstBare)["r"], base64.StdEncoding.EncodeToString(salt))
+	_ = c.writeMsg('R', append([]byte{0, 0, 0, 11}, serverFirst...))
+	_, body, err = c.readMsg()
+	if err != nil {
+		return err
+	}
+	clientFinal, proof, _ := strings.Cut(string(body), ",p=")
+	salted := pbkdf2(f.password, salt, 16)
+	storedKey := sha256.Sum256(hmacSum(salted, "Client Key"))
+	authMessage := clientFirstBare + "," + serverFirst + "," + clientFinal
+	clientKey, _ := base64.StdEncoding.DecodeString(proof)
+	signature := hmacSum(storedKey[:], authMessage)
+	for i := range clientKey {
+		clientKey[i] ^= signature[i]
+	}
+	if sha256.Sum256(clientKey) != storedKey {
+		return fmt.Errorf("password authentication failed for user \"repl\"")
+	}
+	_ = c.writeMsg('R', append([]byte{0, 0, 0, 12}, "v="+base64.StdEncoding.EncodeToString(hmacSum(hmacSum(salted, "Server Key"), authMessage))...))
+	return nil
+}
+
+func pgBegin(ts int64) []byte {
+	b := append([]byte{'B'}, make([]byte, 8)...)
+	b = binary.BigEndian.AppendUint64(b, uint64(ts*1000-pgEpochMicros))
+	return binary.BigEndian.AppendUint32(b, 1)
+}
+
+func pgCommit(end uint64, ts int64) []byte {
+	b := append([]byte{'C', 0}, make([]byte, 8)...)
+	b = binary.BigEndian.AppendUint64(b, end)
+	return binary.BigEndian.AppendUint64(b, uint64(ts*1000-pgEpochMicros))
+}
+
+func pgRelationMsg(id uint32, schema string, table string, columns ...pgColumn) []byte {
+	b := binary.BigEndian.AppendUint32([]byte{'R'}, id)
+	b = append(append(b, schema...), 0)
+	b = append(append(b, table...), 0)
+	b = append(b, 'd')
+	b = binary.BigEndian.AppendUint16(b, uint16(len(columns)))
+	for _, col := range columns {
+		b = append(append(append(b, 0), col.name...), 0)
+		b = binary.BigEndian.AppendUint32(b, col.oid)
+		b = binary.BigEndian.AppendUint32(b, 0xffffffff)
+	}
+	return b
+}
+
+// pgTuple encodes the values in text, nil for null and "\u0000" for the unchanged toasted value
+func pgTuple(values ...interface{}) []byte {
+	b := binary.BigEndian.AppendUint16(nil, uint16(len(values)))
+	for _, v := range values {
+		switch {
+		case v == nil:
+			b = append(b, 'n')
+		case v == "\u0000":
+			b = append(b, 'u')
+		default:
+			s := fmt.Sprint(v)
+			b = binary.BigEndian.AppendUint32(append(b, 't'), uint32(len(s)))
+			b = append(b, s...)
+		}
+	}
+	return b
+}
+
+func pgChange(kind byte, id uint32, parts ...interface{}) []byte {
+	b := binary.BigEndian.AppendUint32([]byte{kind}, id)
+	for _, p := range parts {
+		switch pt := p.(type) {
+		case byte:
+			b = append(b, pt)
+		case []byte:
+			b = append(b, pt...)
+		}
+	}
+	return b
+}
+
+func TestDecodePgText(t *testing.T) {
+	tests := []struct {
+		oid uint32
+		s   string
+		v   interface{}
+	}{
+		{oid: oidBool, s: "t", v: true},
+		{oid: oidInt8, s: "-12", v: int64(-12)},
+		{oid: oidNumeric, s: "3.25", v: 3.25},
+		{oid: oidNumeric, s: "NaN?", v: "NaN?"},
+		{oid: oidJSONB, s: `{"a": [1]}`, v: map[string]interface{}{"a": []interface{}{float64(1)}}},
+		{oid: oidTimestamp, s: "2023-07-01 12:30:45.5", v: time.Date(2023, 7, 1, 12, 30, 45, 500000000, time.UTC)},
+		{oid: oidTimestamptz, s: "2023-07-01 20:30:45+08", v: time.Date(2023, 7, 1, 12, 30, 45, 0, time.UTC)},
+		{oid: oidTimestamptz, s: "2023-07-01 18:00:45+05:30", v: time.Date(2023, 7, 1, 12, 30, 45, 0, time.UTC)},
+		{oid: 25, s: "text", v: "text"},
+	}
+	for i, tt := range tests {
+		v := decodePgText(tt.oid, tt.s)
+		if tv, ok := v.(time.Time); ok {
+			assert.True(t, tt.v.(time.Time).Equal(tv), "case %d", i)
+		} else {
+			assert.Equal(t, tt.v, v, "case %d", i)
+		}
+	}
+	lsn, err := parseLSN("16/B374D848")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0x16B374D848), lsn)
+	assert.Equal(t, "16/B374D848", formatLSN(lsn))
+}
+
+func TestPostgresCapture(t *testing.T) {
+	f := newFakePostgres(t, "secret")
+	columns := []pgColumn{{"id", oidInt4}, {"name", 25}, {"price", oidNumeric}, {"doc", oidJSONB}}
+	f.messages = [][]byte{
+		pgBegin(1688200001000),
+		pgRelationMsg(16384, "public", "t1", columns...),
+		pgChange('I', 16384, byte('N'), pgTuple(1, "a", "1.5", `{"k":1}`)),
+		pgRelationMsg(16385, "public", "other", pgColumn{"id", oidInt4}),
+		pgChange('I', 16385, byte('N'), pgTuple(9)),
+		pgCommit(0x16B3748, 1688200001000),
+		pgBegin(1688200002000),
+		pgChange('U', 16384, byte('O'), pgTuple(1, "a", "1.5", `{"k":1}`), byte('N'), pgTuple(1, "b", nil, "\u0000")),
+		pgChange('D', 16384, byte('K'), pgTuple(1, nil, nil, nil)),
+		pgCommit(0x16B3800, 1688200002000),
+	}
+
+	s := Cdc()
+	require.NoError(t, s.Configure("t1", map[string]interface{}{"url": f.url("wrong"), "slot": "s1", "publication": "p1"}))
+	err := openError(t, s)
+	assert.Contains(t, err.Error(), "postgres error 28P01: password authentication failed")
+
+	s = Cdc()
+	require.NoError(t, s.Configure("t1", map[string]interface{}{"url": f.url("secret"), "slot": "s1", "publication": "p1", "ackOnCheckpoint": true}))
+	ctx, result := runSource(t, s, 3)
+	assert.Equal(t, "0/0", <-f.starts)
+	exp := []struct {
+		msg    map[string]interface{}
+		before map[string]interface{}
+		offset interface{}
+	}{
+		{
+			msg:    map[string]interface{}{"id": int64(1), "name": "a", "price": 1.5, "doc": map[string]interface{}{"k": float64(1)}, "action": ast.RowkindInsert},
+			offset: map[string]interface{}{"lsn": "0/16B3748"},
+		},
+		{
+			msg:    map[string]interface{}{"id": int64(1), "name": "b", "price": nil, "action": ast.RowkindUpdate},
+			before: map[string]interface{}{"id": int64(1), "name": "a", "price": 1.5, "doc": map[string]interface{}{"k": float64(1)}},
+			offset: map[string]interface{}{"lsn": "0/16B3748"},
+		},
+		{
+			msg:    map[string]interface{}{"id": int64(1), "name": nil, "price": nil, "doc": nil, "action": ast.RowkindDelete},
+			offset: map[string]interface{}{"lsn": "0/16B3800"},
+		},
+	}
+	for i, e := range exp {
+		assert.Equal(t, e.msg, result[i].Message(), "case %d", i)
+		assert.Equal(t, "public", result[i].Meta()["schema"], "case %d", i)
+		assert.Equal(t, e.msg["action"], result[i].Meta()["rowkind"], "case %d", i)
+		if e.before != nil {
+			assert.Equal(t, e.before, result[i].Meta()["before"], "case %d", i)
+		}
+		assert.Equal(t, e.offset, offsetOf(result[i]), "case %d", i)
+	}
+	assert.Equal(t, int64(1688200002000), result[2].Meta()["timestamp"])
+	// The position is acknowledged when the checkpoint is completed
+	require.NoError(t, s.(*cdcSource).CommitOffset(ctx, offsetOf(result[2])))
+	waitFlushed(t, f, 0x16B3800)
+
+	// Continue from the offset of the checkpoint and ack once the transaction is consumed
+	s2 := Cdc().(*cdcSource)
+	require.NoError(t, s2.Configure("public.t1", map[string]interface{}{"url": f.url("secret"), "slot": "s1", "publication": "p1"}))
+	require.NoError(t, s2.Rewind(offsetOf(result[0])))
+	_, _ = runSource(t, s2, 3)
+	assert.Equal(t, "0/16B3748", <-f.starts)
+	waitFlushed(t, f, 0x16B3800)
+}
+
+func TestPostgresTLSAndRelationChange(t *testing.T) {
+	f := newFakePostgres(t, "secret")
+	f.messages = [][]byte{
+		pgBegin(1688200001000),
+		pgRelationMsg(16384, "public", "t1", pgColumn{"id", oidInt4}, pgColumn{"name", 25}),
+		pgChange('I', 16384, byte('N'), pgTuple(1, "a")),
+		pgCommit(0x16B3748, 1688200001000),
+		pgBegin(1688200002000),
+		// The relation is sent again after the table is altered
+		pgRelationMsg(16384, "public", "t1", pgColumn{"id", oidInt4}, pgColumn{"title", 25}, pgColumn{"price", oidNumeric}),
+		pgChange('I', 16384, byte('N'), pgTuple(2, "b", "2.5")),
+		pgCommit(0x16B3800, 1688200002000),
+	}
+	s := Cdc()
+	require.NoError(t, s.Configure("t1", map[string]interface{}{"url": f.url("secret") + "?sslmode=require", "slot": "s1", "publication": "p1"}))
+	err := openError(t, s)
+	assert.Contains(t, err.Error(), "postgres server does not support TLS which is required by sslmode require")
+
+	tf := newFakePostgres(t, "secret")
+	tf.messages = f.messages
+	var certFile string
+	tf.tls, certFile = testServerTLS(t)
+	s = Cdc()
+	require.NoError(t, s.Configure("t1", map[string]interface{}{"url": tf.url("secret") + "?sslmode=verify-full&sslrootcert=" + certFile, "slot": "s1", "publication": "p1"}))
+	_, result := runSource(t, s, 2)
+	tf.mu.Lock()
+	assert.True(t, tf.secured)
+	tf.mu.Unlock()
+	assert.Equal(t, map[string]interface{}{"id": int64(1), "name": "a", "action": ast.RowkindInsert}, result[0].Message())
+	assert.Equal(t, map[string]interface{}{"id": int64(2), "title": "b", "price": 2.5, "action": ast.RowkindInsert}, result[1].Message())
+}
+
+func waitFlushed(t *testing.T, f *fakePostgres, lsn uint64) {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case flushed := <-f.flushed:
+			if flushed == lsn {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timeout to wait for the flushed lsn %s", formatLSN(lsn))
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// reader decodes the fields of a protocol message. The first out of range read sets err to io.ErrUnexpectedEOF and
+// all the later reads return zero values, so the error only needs to be checked once after decoding.
+type reader struct {
+	b     []byte
+	pos   int
+	order binary.ByteOrder
+	err   error
+}
+
+func newReader(b []byte, order binary.ByteOrder) *reader {
+	return &reader{b: b, order: order}
+}
+
+func (r *reader) remaining() int {
+	return len(r.b) - r.pos
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil || n < 0 || r.remaining() < n {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := r.b[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *reader) skip(n int) {
+	r.bytes(n)
+}
+
+func (r *reader) rest() []byte {
+	return r.bytes(r.remaining())
+}
+
+func (r *reader) u8() uint8 {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *reader) u16() uint16 {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return r.order.Uint16(b)
+}
+
+func (r *reader) u32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return r.order.Uint32(b)
+}
+
+func (r *reader) u64() uint64 {
+	b := r.bytes(8)
+	if b == nil {
+		return 0
+	}
+	return r.order.Uint64(b)
+}
+
+// uint reads an unsigned integer of n bytes up to 8
+func (r *reader) uint(n int) uint64 {
+	b := r.bytes(n)
+	var v uint64
+	for i := range b {
+		if r.order == binary.LittleEndian {
+			v |= uint64(b[i]) << (8 * i)
+		} else {
+			v = v<<8 | uint64(b[i])
+		}
+	}
+	return v
+}
+
+// cstring reads a string terminated by NUL
+func (r *reader) cstring() string {
+	if r.err != nil {
+		return ""
+	}
+	i := bytes.IndexByte(r.b[r.pos:], 0)
+	if i < 0 {
+		r.err = io.ErrUnexpectedEOF
+		return ""
+	}
+	s := string(r.b[r.pos : r.pos+i])
+	r.pos += i + 1
+	return s
+}
+
+// lenenc reads a length encoded integer of the MySQL protocol. The second result is true if it is a NULL value.
+func (r *reader) lenenc() (uint64, bool) {
+	switch first := r.u8(); first {
+	case 0xfb:
+		return 0, true
+	case 0xfc:
+		return r.uint(2), false
+	case 0xfd:
+		return r.uint(3), false
+	case 0xfe:
+		return r.uint(8), false
+	default:
+		return uint64(first), false
+	}
+}
+
+// lenencString reads a length encoded string of the MySQL protocol
+func (r *reader) lenencString() (string, bool) {
+	n, null := r.lenenc()
+	if null {
+		return "", true
+	}
+	return string(r.bytes(int(n))), false
+}
+
+// appendLenenc appends a length encoded integer of the MySQL protocol
+func appendLenenc(b []byte, n uint64) []byte {
+	switch {
+	case n < 0xfb:
+		return append(b, byte(n))
+	case n < 1<<16:
+		return append(b, 0xfc, byte(n), byte(n>>8))
+	case n < 1<<24:
+		return append(b, 0xfd, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		b = append(b, 0xfe)
+		return binary.LittleEndian.AppendUint64(b, n)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+)
+
+// ssl modes of the connection like libpq
+const (
+	sslDisable    = "disable"
+	sslPrefer     = "prefer"
+	sslRequire    = "require"
+	sslVerifyCA   = "verify-ca"
+	sslVerifyFull = "verify-full"
+)
+
+// sslConf is the TLS setting of the database connection by the url parameters sslmode, sslrootcert, sslcert and
+// sslkey. The default mode prefer uses TLS without verifying the certificate if the server supports it.
+type sslConf struct {
+	mode   string
+	config *tls.Config
+}
+
+func parseSSL(u *url.URL) (*sslConf, error) {
+	q := u.Query()
+	s := &sslConf{mode: q.Get("sslmode")}
+	if s.mode == "" {
+		s.mode = sslPrefer
+	}
+	switch s.mode {
+	case sslDisable:
+		return s, nil
+	case sslPrefer, sslRequire, sslVerifyCA, sslVerifyFull:
+	default:
+		return nil, fmt.Errorf("invalid sslmode %s, must be disable, prefer, require, verify-ca or verify-full", s.mode)
+	}
+	s.config = &tls.Config{ServerName: u.Hostname()}
+	if f := q.Get("sslrootcert"); f != "" {
+		pem, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("fail to read sslrootcert %s: %v", f, err)
+		}
+		s.config.RootCAs = x509.NewCertPool()
+		if !s.config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate is found in sslrootcert %s", f)
+		}
+	}
+	if cert, key := q.Get("sslcert"), q.Get("sslkey"); cert != "" || key != "" {
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("fail to load sslcert %s and sslkey %s: %v", cert, key, err)
+		}
+		s.config.Certificates = []tls.Certificate{pair}
+	}
+	switch s.mode {
+	case sslPrefer, sslRequire:
+		s.config.InsecureSkipVerify = true
+	case sslVerifyCA:
+		// verify the chain without the host name
+		s.config.InsecureSkipVerify = true
+		roots := s.config.RootCAs
+		s.config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			certs := make([]*x509.Certificate, len(rawCerts))
+			for i, raw := range rawCerts {
+				c, err := x509.ParseCertificate(raw)
+				if err != nil {
+					return err
+				}
+				certs[i] = c
+			}
+			if len(certs) == 0 {
+				return fmt.Errorf("no server certificate")
+			}
+			opts := x509.VerifyOptions{Roots: roots, Intermediates: x509.NewCertPool()}
+			for _, c := range certs[1:] {
+				opts.Intermediates.AddCert(c)
+			}
+			_, err := certs[0].Verify(opts)
+			return err
+		}
+	}
+	return s, nil
+}
+
+// enabled returns whether to request TLS from the server
+func (s *sslConf) enabled() bool {
+	return s != nil && s.mode != sslDisable
+}
+
+// required returns whether the connection fails if the server does not support TLS
+func (s *sslConf) required() bool {
+	return s.enabled() && s.mode != sslPrefer
+}
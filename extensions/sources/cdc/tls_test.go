@@ -0,0 +1,92 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testServerTLS creates a self-signed certificate of 127.0.0.1 and returns the server config and the certificate file
+func testServerTLS(t *testing.T) (*tls.Config, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fake db"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	certFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}, certFile
+}
+
+func TestParseSSL(t *testing.T) {
+	_, certFile := testServerTLS(t)
+	tests := []struct {
+		url      string
+		enabled  bool
+		required bool
+		verify   bool
+		err      string
+	}{
+		{url: "mysql://u@h:3306/db", enabled: true},
+		{url: "mysql://u@h:3306/db?sslmode=disable"},
+		{url: "postgres://u@h/db?sslmode=require", enabled: true, required: true},
+		{url: "postgres://u@h/db?sslmode=verify-ca&sslrootcert=" + certFile, enabled: true, required: true},
+		{url: "postgres://u@h/db?sslmode=verify-full&sslrootcert=" + certFile, enabled: true, required: true, verify: true},
+		{url: "postgres://u@h/db?sslmode=allow", err: "invalid sslmode allow, must be disable, prefer, require, verify-ca or verify-full"},
+		{url: "postgres://u@h/db?sslmode=require&sslrootcert=/not/exist", err: "fail to read sslrootcert /not/exist: open /not/exist: no such file or directory"},
+	}
+	for i, tt := range tests {
+		u, err := url.Parse(tt.url)
+		require.NoError(t, err)
+		s, err := parseSSL(u)
+		if tt.err != "" {
+			assert.EqualError(t, err, tt.err, "case %d", i)
+			continue
+		}
+		require.NoError(t, err, "case %d", i)
+		assert.Equal(t, tt.enabled, s.enabled(), "case %d", i)
+		assert.Equal(t, tt.required, s.required(), "case %d", i)
+		if s.enabled() {
+			assert.Equal(t, "h", s.config.ServerName, "case %d", i)
+			assert.Equal(t, !tt.verify, s.config.InsecureSkipVerify, "case %d", i)
+		}
+	}
+}
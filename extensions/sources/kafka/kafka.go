@@ -0,0 +1,521 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+
+	"github.com/lf-edge/ekuiper/extensions/util"
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/cert"
+	"github.com/lf-edge/ekuiper/internal/topo/connection/clients"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/infra"
+)
+
+const (
+	SASL_NONE  = "none"
+	SASL_PLAIN = "plain"
+	SASL_SCRAM = "scram"
+
+	ASSIGN_RANGE      = "range"
+	ASSIGN_ROUNDROBIN = "roundRobin"
+
+	OFFSET_EARLIEST = "earliest"
+	OFFSET_LATEST   = "latest"
+)
+
+type sourceConf struct {
+	Brokers             string      `json:"brokers"`
+	GroupId             string      `json:"groupId"`
+	PartitionAssignment string      `json:"partitionAssignment"`
+	StartOffset         interface{} `json:"startOffset"`
+	SaslAuthType        string      `json:"saslAuthType"`
+	SaslUserName        string      `json:"saslUserName"`
+	SaslPassword        string      `json:"saslPassword"`
+	Tls                 bool        `json:"tls"`
+	InsecureSkipVerify  bool        `json:"insecureSkipVerify"`
+	CertificationPath   string      `json:"certificationPath"`
+	PrivateKeyPath      string      `json:"privateKeyPath"`
+	RootCaPath          string      `json:"rootCaPath"`
+	MaxBytes            int         `json:"maxBytes"`
+	// Use the shared brokers in connection.yaml instead of the brokers, sasl and tls properties
+	ConnectionSelector string `json:"connectionSelector"`
+}
+
+// consumerGroup, generation and partitionReader are the parts of kafka-go used by the source. They are abstracted so
+// that the source can be tested against a fake broker.
+type consumerGroup interface {
+	// Next blocks until the next generation of the group is joined
+	Next(ctx context.Context) (generation, error)
+	Close() error
+}
+
+type generation interface {
+	// GetAssignments returns the assigned partitions with the committed offsets of each topic
+	GetAssignments() map[string][]kafkago.PartitionAssignment
+	// Start runs the function in a goroutine which context is done when the generation ends
+	Start(fn func(ctx context.Context))
+	CommitOffsets(offsets map[string]map[int]int64) error
+}
+
+type partitionReader interface {
+	SetOffset(offset int64) error
+	SetOffsetAt(ctx context.Context, t time.Time) error
+	ReadMessage(ctx context.Context) (kafkago.Message, error)
+	Close() error
+}
+
+type kafkaGroup struct {
+	*kafkago.ConsumerGroup
+}
+
+func (g *kafkaGroup) Next(ctx context.Context) (generation, error) {
+	gen, err := g.ConsumerGroup.Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaGeneration{Generation: gen}, nil
+}
+
+type kafkaGeneration struct {
+	*kafkago.Generation
+}
+
+func (g *kafkaGeneration) GetAssignments() map[string][]kafkago.PartitionAssignment {
+	return g.Assignments
+}
+
+// kafkaSource consumes the topics in a consumer group. The offset of the source is the next offset to read of each
+// partition. It is saved in the checkpoint and committed to the group only when the checkpoint is completed.
+type kafkaSource struct {
+	c           *sourceConf
+	topics      []string
+	startOffset int64
+	startTime   time.Time
+	dialer      *kafkago.Dialer
+	// conn is the shared connection if connectionSelector is set
+	conn clients.Connection
+
+	newGroup  func(groupId string) (consumerGroup, error)
+	newReader func(topic string, partition int) partitionReader
+
+	// mu guards offsets and serializes the sending so that the offsets carried by the tuples are in order
+	mu      sync.Mutex
+	offsets map[string]map[int]int64
+	// genMu guards the current generation and the group
+	genMu sync.Mutex
+	gen   generation
+	group consumerGroup
+}
+
+func (s *kafkaSource) Configure(topic string, props map[string]interface{}) error {
+	c := &sourceConf{
+		Brokers:             "localhost:9092",
+		PartitionAssignment: ASSIGN_RANGE,
+		SaslAuthType:        SASL_NONE,
+	}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if c.Brokers == "" {
+		return fmt.Errorf("brokers can not be empty")
+	}
+	for _, t := range strings.Split(topic, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			s.topics = append(s.topics, t)
+		}
+	}
+	if len(s.topics) == 0 {
+		return fmt.Errorf("topic can not be empty")
+	}
+	if c.PartitionAssignment != ASSIGN_RANGE && c.PartitionAssignment != ASSIGN_ROUNDROBIN {
+		return fmt.Errorf("partitionAssignment must be range or roundRobin but got %s", c.PartitionAssignment)
+	}
+	so := OFFSET_LATEST
+	if c.StartOffset != nil {
+		var err error
+		so, err = cast.ToString(c.StartOffset, cast.CONVERT_ALL)
+		if err != nil {
+			return fmt.Errorf("invalid startOffset %v", c.StartOffset)
+		}
+	}
+	switch so {
+	case OFFSET_EARLIEST:
+		s.startOffset = kafkago.FirstOffset
+	case OFFSET_LATEST:
+		s.startOffset = kafkago.LastOffset
+	default:
+		ts, err := strconv.ParseInt(so, 10, 64)
+		if err != nil || ts < 0 {
+			return fmt.Errorf("startOffset must be earliest, latest or a timestamp in milliseconds but got %s", so)
+		}
+		s.startOffset = kafkago.FirstOffset
+		s.startTime = time.UnixMilli(ts)
+	}
+	if !(c.SaslAuthType == SASL_NONE || c.SaslAuthType == SASL_SCRAM || c.SaslAuthType == SASL_PLAIN) {
+		return fmt.Errorf("saslAuthType incorrect")
+	}
+	if (c.SaslAuthType == SASL_SCRAM || c.SaslAuthType == SASL_PLAIN) && (c.SaslUserName == "" || c.SaslPassword == "") {
+		return fmt.Errorf("username and password can not be empty")
+	}
+	s.c = c
+	var err error
+	if c.ConnectionSelector != "" {
+		s.conn, err = clients.GetConnection("kafka", map[string]interface{}{"connectionSelector": c.ConnectionSelector})
+		if err != nil {
+			return err
+		}
+		kc := s.conn.(*util.KafkaConnection)
+		c.Brokers = strings.Join(kc.Brokers, ",")
+		s.dialer = kc.Dialer
+	} else {
+		s.dialer, err = s.buildDialer()
+		if err != nil {
+			return err
+		}
+	}
+	s.offsets = make(map[string]map[int]int64)
+	s.newGroup = s.createGroup
+	s.newReader = s.createReader
+	return nil
+}
+
+func (s *kafkaSource) buildDialer() (*kafkago.Dialer, error) {
+	var (
+		mechanism sasl.Mechanism
+		err       error
+	)
+	switch s.c.SaslAuthType {
+	case SASL_PLAIN:
+		mechanism = plain.Mechanism{
+			Username: s.c.SaslUserName,
+			Password: s.c.SaslPassword,
+		}
+	case SASL_SCRAM:
+		mechanism, err = scram.Mechanism(scram.SHA512, s.c.SaslUserName, s.c.SaslPassword)
+		if err != nil {
+			return nil, err
+		}
+	}
+	dialer := &kafkago.Dialer{
+		Timeout:       10 * time.Second,
+		DualStack:     true,
+		SASLMechanism: mechanism,
+	}
+	if s.c.Tls || s.c.InsecureSkipVerify || s.c.CertificationPath != "" || s.c.RootCaPath != "" {
+		tlsConf, err := cert.GenerateTLSForClient(cert.TlsConfigurationOptions{
+			SkipCertVerify: s.c.InsecureSkipVerify,
+			CertFile:       s.c.CertificationPath,
+			KeyFile:        s.c.PrivateKeyPath,
+			CaFile:         s.c.RootCaPath,
+		})
+		if err != nil {
+			return nil, err
+		}
+		dialer.TLS = tlsConf
+	}
+	return dialer, nil
+}
+
+func (s *kafkaSource) createGroup(groupId string) (consumerGroup, error) {
+	var balancer kafkago.GroupBalancer = kafkago.RangeGroupBalancer{}
+	if s.c.PartitionAssignment == ASSIGN_ROUNDROBIN {
+		balancer = kafkago.RoundRobinGroupBalancer{}
+	}
+	g, err := kafkago.NewConsumerGroup(kafkago.ConsumerGroupConfig{
+		ID:             groupId,
+		Brokers:        strings.Split(s.c.Brokers, ","),
+		Dialer:         s.dialer,
+		Topics:         s.topics,
+		GroupBalancers: []kafkago.GroupBalancer{balancer},
+		StartOffset:    s.startOffset,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaGroup{ConsumerGroup: g}, nil
+}
+
+func (s *kafkaSource) createReader(topic string, partition int) partitionReader {
+	rc := kafkago.ReaderConfig{
+		Brokers:   strings.Split(s.c.Brokers, ","),
+		Topic:     topic,
+		Partition: partition,
+		Dialer:    s.dialer,
+	}
+	if s.c.MaxBytes > 0 {
+		rc.MaxBytes = s.c.MaxBytes
+	}
+	return kafkago.NewReader(rc)
+}
+
+func (s *kafkaSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	logger := ctx.GetLogger()
+	groupId := s.c.GroupId
+	if groupId == "" {
+		groupId = fmt.Sprintf("ekuiper_%s_%s", ctx.GetRuleId(), ctx.GetOpId())
+	}
+	group, err := s.newGroup(groupId)
+	if err != nil {
+		infra.DrainError(ctx, fmt.Errorf("kafka source fails to create consumer group %s: %v", groupId, err), errCh)
+		return
+	}
+	s.genMu.Lock()
+	s.group = group
+	s.genMu.Unlock()
+	defer group.Close()
+	logger.Infof("kafka source joins consumer group %s for topics %v", groupId, s.topics)
+	for {
+		gen, err := group.Next(ctx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, kafkago.ErrGroupClosed) {
+				logger.Infof("kafka source done")
+				return
+			}
+			infra.DrainError(ctx, fmt.Errorf("kafka source fails to join consumer group %s: %v", groupId, err), errCh)
+			return
+		}
+		s.genMu.Lock()
+		s.gen = gen
+		s.genMu.Unlock()
+		for topic, assignments := range gen.GetAssignments() {
+			for _, a := range assignments {
+				topic, a := topic, a
+				logger.Infof("kafka source is assigned topic %s partition %d with committed offset %d", topic, a.ID, a.Offset)
+				gen.Start(func(gctx context.Context) {
+					s.consume(ctx, gctx, consumer, topic, a)
+				})
+			}
+		}
+	}
+}
+
+// consume reads the assigned partition until the generation ends
+func (s *kafkaSource) consume(ctx api.StreamContext, gctx context.Context, consumer chan<- api.SourceTuple, topic string, a kafkago.PartitionAssignment) {
+	logger := ctx.GetLogger()
+	r := s.newReader(topic, a.ID)
+	defer r.Close()
+	if err := s.seek(gctx, r, topic, a); err != nil {
+		s.send(gctx, consumer, &xsql.ErrorSourceTuple{Error: fmt.Errorf("kafka source fails to seek topic %s partition %d: %v", topic, a.ID, err)})
+		return
+	}
+	for {
+		msg, err := r.ReadMessage(gctx)
+		if err != nil {
+			if gctx.Err() == nil {
+				s.send(gctx, consumer, &xsql.ErrorSourceTuple{Error: fmt.Errorf("kafka source fails to read topic %s partition %d: %v", topic, a.ID, err)})
+			}
+			return
+		}
+		logger.Debugf("kafka source receive message at topic %s partition %d offset %d", msg.Topic, msg.Partition, msg.Offset)
+		if !s.publish(ctx, gctx, consumer, topic, msg) {
+			return
+		}
+	}
+}
+
+// seek sets the reader to the larger one of the committed offset and the consumed offset, which is restored from the
+// checkpoint or kept during rebalancing. If no offset is found, the startOffset is used.
+func (s *kafkaSource) seek(ctx context.Context, r partitionReader, topic string, a kafkago.PartitionAssignment) error {
+	offset := a.Offset
+	s.mu.Lock()
+	if o, ok := s.offsets[topic][a.ID]; ok && o > offset {
+		offset = o
+	}
+	s.mu.Unlock()
+	if offset < 0 && !s.startTime.IsZero() {
+		return r.SetOffsetAt(ctx, s.startTime)
+	}
+	return r.SetOffset(offset)
+}
+
+// publish sends the decoded tuples of the message. The last tuple carries the offset after the message.
+func (s *kafkaSource) publish(ctx api.StreamContext, gctx context.Context, consumer chan<- api.SourceTuple, topic string, msg kafkago.Message) bool {
+	rcvTime := conf.GetNow()
+	headers := make(map[string]interface{}, len(msg.Headers))
+	for _, h := range msg.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	meta := map[string]interface{}{
+		"topic":     msg.Topic,
+		"partition": int64(msg.Partition),
+		"offset":    msg.Offset,
+		"key":       string(msg.Key),
+		"timestamp": msg.Time.UnixMilli(),
+		"headers":   headers,
+	}
+	results, err := ctx.DecodeIntoList(msg.Value)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		if !s.send(gctx, consumer, &xsql.ErrorSourceTuple{Error: fmt.Errorf("invalid data format, cannot decode %s with error %s", string(msg.Value), err), Payload: msg.Value}) {
+			return false
+		}
+		s.setOffset(topic, msg.Partition, msg.Offset+1)
+		return true
+	}
+	prev := s.offset()
+	s.setOffset(topic, msg.Partition, msg.Offset+1)
+	next := s.offset()
+	for i, result := range results {
+		offset := prev
+		if i == len(results)-1 {
+			offset = next
+		}
+		if !s.send(gctx, consumer, api.NewOffsetSourceTuple(api.NewDefaultSourceTupleWithTime(result, meta, rcvTime), offset)) {
+			// Not consumed, so that it will be read again when the partition is assigned again
+			s.setOffset(topic, msg.Partition, msg.Offset)
+			return false
+		}
+	}
+	return true
+}
+
+func (s *kafkaSource) send(ctx context.Context, consumer chan<- api.SourceTuple, tuple api.SourceTuple) bool {
+	select {
+	case consumer <- tuple:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (s *kafkaSource) setOffset(topic string, partition int, offset int64) {
+	if _, ok := s.offsets[topic]; !ok {
+		s.offsets[topic] = make(map[int]int64)
+	}
+	s.offsets[topic][partition] = offset
+}
+
+// offset returns a copy of the offsets in the format which can be saved in the checkpoint
+func (s *kafkaSource) offset() map[string]interface{} {
+	result := make(map[string]interface{}, len(s.offsets))
+	for topic, partitions := range s.offsets {
+		po := make(map[string]interface{}, len(partitions))
+		for p, o := range partitions {
+			po[strconv.Itoa(p)] = o
+		}
+		result[topic] = po
+	}
+	return result
+}
+
+func parseOffset(offset interface{}) (map[string]map[int]int64, error) {
+	m, ok := offset.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid kafka source offset %v", offset)
+	}
+	result := make(map[string]map[int]int64, len(m))
+	for topic, v := range m {
+		pm, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid kafka source offset %v", offset)
+		}
+		result[topic] = make(map[int]int64, len(pm))
+		for p, o := range pm {
+			partition, err := strconv.Atoi(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid kafka source offset %v: %v", offset, err)
+			}
+			po, err := cast.ToInt64(o, cast.CONVERT_SAMEKIND)
+			if err != nil {
+				return nil, fmt.Errorf("invalid kafka source offset %v: %v", offset, err)
+			}
+			result[topic][partition] = po
+		}
+	}
+	return result, nil
+}
+
+func (s *kafkaSource) GetOffset() (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.offset(), nil
+}
+
+// Rewind sets the offsets to continue from. It is called before Open.
+func (s *kafkaSource) Rewind(offset interface{}) error {
+	offsets, err := parseOffset(offset)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offsets = offsets
+	return nil
+}
+
+// CommitOffset commits the offsets of the partitions assigned to the current generation to the consumer group
+func (s *kafkaSource) CommitOffset(ctx api.StreamContext, offset interface{}) error {
+	offsets, err := parseOffset(offset)
+	if err != nil {
+		return err
+	}
+	s.genMu.Lock()
+	gen := s.gen
+	s.genMu.Unlock()
+	if gen == nil {
+		return nil
+	}
+	toCommit := make(map[string]map[int]int64)
+	for topic, assignments := range gen.GetAssignments() {
+		for _, a := range assignments {
+			if o, ok := offsets[topic][a.ID]; ok {
+				if _, ok := toCommit[topic]; !ok {
+					toCommit[topic] = make(map[int]int64)
+				}
+				toCommit[topic][a.ID] = o
+			}
+		}
+	}
+	if len(toCommit) == 0 {
+		return nil
+	}
+	ctx.GetLogger().Debugf("kafka source commits offsets %v", toCommit)
+	return gen.CommitOffsets(toCommit)
+}
+
+func (s *kafkaSource) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("Closing kafka source")
+	s.genMu.Lock()
+	defer s.genMu.Unlock()
+	var err error
+	if s.group != nil {
+		err = s.group.Close()
+	}
+	if s.conn != nil {
+		_ = clients.ReleaseConnection(ctx, s.conn)
+		s.conn = nil
+	}
+	return err
+}
+
+func Kafka() api.Source {
+	return &kafkaSource{}
+}
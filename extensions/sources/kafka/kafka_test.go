@@ -0,0 +1,368 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lf-edge/ekuiper/internal/converter"
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
+	kctx "github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+// fakeBroker is an in-process broker which keeps the messages of each partition and the committed offsets
+type fakeBroker struct {
+	mu        sync.Mutex
+	topics    map[string][][]kafkago.Message
+	committed map[string]map[int]int64
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{
+		topics:    make(map[string][][]kafkago.Message),
+		committed: make(map[string]map[int]int64),
+	}
+}
+
+func (b *fakeBroker) produce(topic string, partition int, value string, ts int64, headers ...kafkago.Header) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for len(b.topics[topic]) <= partition {
+		b.topics[topic] = append(b.topics[topic], nil)
+	}
+	msgs := b.topics[topic][partition]
+	b.topics[topic][partition] = append(msgs, kafkago.Message{
+		Topic:     topic,
+		Partition: partition,
+		Offset:    int64(len(msgs)),
+		Key:       []byte(fmt.Sprintf("k%d", len(msgs))),
+		Value:     []byte(value),
+		Headers:   headers,
+		Time:      time.UnixMilli(ts),
+	})
+}
+
+func (b *fakeBroker) getCommitted() map[string]map[int]int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.committed
+}
+
+type fakeGroup struct {
+	broker      *fakeBroker
+	topics      []string
+	startOffset int64
+	ctx         context.Context
+	cancel      context.CancelFunc
+	joined      bool
+}
+
+func (g *fakeGroup) Next(ctx context.Context) (generation, error) {
+	if g.joined {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-g.ctx.Done():
+			return nil, kafkago.ErrGroupClosed
+		}
+	}
+	g.joined = true
+	g.broker.mu.Lock()
+	defer g.broker.mu.Unlock()
+	assignments := make(map[string][]kafkago.PartitionAssignment)
+	for _, topic := range g.topics {
+		for p := range g.broker.topics[topic] {
+			offset, ok := g.broker.committed[topic][p]
+			if !ok {
+				offset = g.startOffset
+			}
+			assignments[topic] = append(assignments[topic], kafkago.PartitionAssignment{ID: p, Offset: offset})
+		}
+	}
+	return &fakeGeneration{broker: g.broker, ctx: g.ctx, assignments: assignments}, nil
+}
+
+func (g *fakeGroup) Close() error {
+	g.cancel()
+	return nil
+}
+
+type fakeGeneration struct {
+	broker      *fakeBroker
+	ctx         context.Context
+	assignments map[string][]kafkago.PartitionAssignment
+}
+
+func (g *fakeGeneration) GetAssignments() map[string][]kafkago.PartitionAssignment {
+	return g.assignments
+}
+
+func (g *fakeGeneration) Start(fn func(ctx context.Context)) {
+	go fn(g.ctx)
+}
+
+func (g *fakeGeneration) CommitOffsets(offsets map[string]map[int]int64) error {
+	g.broker.mu.Lock()
+	defer g.broker.mu.Unlock()
+	for topic, po := range offsets {
+		if _, ok := g.broker.committed[topic]; !ok {
+			g.broker.committed[topic] = make(map[int]int64)
+		}
+		for p, o := range po {
+			g.broker.committed[topic][p] = o
+		}
+	}
+	return nil
+}
+
+type fakeReader struct {
+	broker    *fakeBroker
+	topic     string
+	partition int
+	pos       int64
+}
+
+func (r *fakeReader) SetOffset(offset int64) error {
+	r.broker.mu.Lock()
+	defer r.broker.mu.Unlock()
+	switch offset {
+	case kafkago.FirstOffset:
+		r.pos = 0
+	case kafkago.LastOffset:
+		r.pos = int64(len(r.broker.topics[r.topic][r.partition]))
+	default:
+		r.pos = offset
+	}
+	return nil
+}
+
+func (r *fakeReader) SetOffsetAt(_ context.Context, t time.Time) error {
+	r.broker.mu.Lock()
+	defer r.broker.mu.Unlock()
+	msgs := r.broker.topics[r.topic][r.partition]
+	r.pos = int64(len(msgs))
+	for _, m := range msgs {
+		if !m.Time.Before(t) {
+			r.pos = m.Offset
+			break
+		}
+	}
+	return nil
+}
+
+func (r *fakeReader) ReadMessage(ctx context.Context) (kafkago.Message, error) {
+	for {
+		r.broker.mu.Lock()
+		msgs := r.broker.topics[r.topic][r.partition]
+		if r.pos < int64(len(msgs)) {
+			m := msgs[r.pos]
+			r.pos++
+			r.broker.mu.Unlock()
+			return m, nil
+		}
+		r.broker.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return kafkago.Message{}, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (r *fakeReader) Close() error {
+	return nil
+}
+
+func newTestSource(t *testing.T, b *fakeBroker, topic string, props map[string]interface{}) *kafkaSource {
+	s := Kafka().(*kafkaSource)
+	err := s.Configure(topic, props)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.newGroup = func(_ string) (consumerGroup, error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		return &fakeGroup{broker: b, topics: s.topics, startOffset: s.startOffset, ctx: ctx, cancel: cancel}, nil
+	}
+	s.newReader = func(topic string, partition int) partitionReader {
+		return &fakeReader{broker: b, topic: topic, partition: partition}
+	}
+	return s
+}
+
+func runSource(t *testing.T, s *kafkaSource, limit int) (api.StreamContext, []api.SourceTuple) {
+	ctx, cancel := mockContext.NewMockContext("ruleKafka", "op1").WithCancel()
+	t.Cleanup(cancel)
+	cv, _ := converter.GetOrCreateConverter(&ast.Options{FORMAT: "json"})
+	ctx = kctx.WithValue(ctx.(*kctx.DefaultContext), kctx.DecodeKey, cv)
+	consumer := make(chan api.SourceTuple)
+	errCh := make(chan error)
+	go s.Open(ctx, consumer, errCh)
+	var result []api.SourceTuple
+	timeout := time.After(5 * time.Second)
+	for len(result) < limit {
+		select {
+		case tuple := <-consumer:
+			result = append(result, tuple)
+		case err := <-errCh:
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatalf("timeout, only received %d tuples", len(result))
+		}
+	}
+	return ctx, result
+}
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		topic string
+		props map[string]interface{}
+		err   error
+	}{
+		{
+			topic: "t1",
+			props: map[string]interface{}{},
+		},
+		{
+			topic: "",
+			props: map[string]interface{}{},
+			err:   errors.New("topic can not be empty"),
+		},
+		{
+			topic: "t1",
+			props: map[string]interface{}{"brokers": ""},
+			err:   errors.New("brokers can not be empty"),
+		},
+		{
+			topic: "t1",
+			props: map[string]interface{}{"partitionAssignment": "sticky"},
+			err:   errors.New("partitionAssignment must be range or roundRobin but got sticky"),
+		},
+		{
+			topic: "t1",
+			props: map[string]interface{}{"startOffset": "middle"},
+			err:   errors.New("startOffset must be earliest, latest or a timestamp in milliseconds but got middle"),
+		},
+		{
+			topic: "t1",
+			props: map[string]interface{}{"saslAuthType": "plain"},
+			err:   errors.New("username and password can not be empty"),
+		},
+		{
+			topic: "t1",
+			props: map[string]interface{}{"saslAuthType": "oauth"},
+			err:   errors.New("saslAuthType incorrect"),
+		},
+	}
+	for i, tt := range tests {
+		err := Kafka().Configure(tt.topic, tt.props)
+		assert.Equal(t, tt.err, err, "case %d", i)
+	}
+	s := Kafka().(*kafkaSource)
+	err := s.Configure(" t1, t2 ", map[string]interface{}{"startOffset": 1690000000000})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"t1", "t2"}, s.topics)
+	assert.Equal(t, kafkago.FirstOffset, s.startOffset)
+	assert.Equal(t, time.UnixMilli(1690000000000), s.startTime)
+}
+
+func TestConsumeAndCommit(t *testing.T) {
+	b := newFakeBroker()
+	b.produce("t1", 0, `{"a":1}`, 1000, kafkago.Header{Key: "h1", Value: []byte("v1")})
+	b.produce("t1", 0, `[{"a":2},{"a":3}]`, 2000)
+	b.produce("t1", 1, `{"a":4}`, 3000)
+	s := newTestSource(t, b, "t1", map[string]interface{}{"startOffset": "earliest"})
+	ctx, result := runSource(t, s, 4)
+
+	var p0, p1 []api.SourceTuple
+	for _, r := range result {
+		if r.Meta()["partition"] == int64(0) {
+			p0 = append(p0, r)
+		} else {
+			p1 = append(p1, r)
+		}
+	}
+	assert.Len(t, p0, 3)
+	assert.Len(t, p1, 1)
+	assert.Equal(t, map[string]interface{}{"a": float64(1)}, p0[0].Message())
+	assert.Equal(t, map[string]interface{}{
+		"topic":     "t1",
+		"partition": int64(0),
+		"offset":    int64(0),
+		"key":       "k0",
+		"timestamp": int64(1000),
+		"headers":   map[string]interface{}{"h1": "v1"},
+	}, p0[0].Meta())
+	assert.Equal(t, map[string]interface{}{"a": float64(3)}, p0[2].Message())
+	assert.Equal(t, int64(1), p0[2].Meta()["offset"])
+	assert.Equal(t, map[string]interface{}{"a": float64(4)}, p1[0].Message())
+	// Only the last tuple of a message moves the offset forward
+	assert.Equal(t, int64(1), p0[1].(api.OffsetSourceTuple).Offset().(map[string]interface{})["t1"].(map[string]interface{})["0"])
+	assert.Equal(t, int64(2), p0[2].(api.OffsetSourceTuple).Offset().(map[string]interface{})["t1"].(map[string]interface{})["0"])
+
+	offset, err := s.GetOffset()
+	assert.NoError(t, err)
+	exp := map[string]interface{}{"t1": map[string]interface{}{"0": int64(2), "1": int64(1)}}
+	assert.Equal(t, exp, offset)
+	// Nothing is committed until the checkpoint completes
+	assert.Empty(t, b.getCommitted())
+	err = s.CommitOffset(ctx, exp)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]map[int]int64{"t1": {0: 2, 1: 1}}, b.getCommitted())
+	assert.NoError(t, s.Close(ctx))
+
+	// A new consumer of the group continues from the committed offsets
+	b.produce("t1", 1, `{"a":5}`, 4000)
+	s = newTestSource(t, b, "t1", map[string]interface{}{"startOffset": "earliest"})
+	ctx, result = runSource(t, s, 1)
+	assert.Equal(t, map[string]interface{}{"a": float64(5)}, result[0].Message())
+	assert.NoError(t, s.Close(ctx))
+}
+
+func TestRewindAndStartOffset(t *testing.T) {
+	b := newFakeBroker()
+	for i := 0; i < 4; i++ {
+		b.produce("t1", 0, fmt.Sprintf(`{"a":%d}`, i), int64(i*1000))
+	}
+	b.committed["t1"] = map[int]int64{0: 1}
+
+	// The restored offset from the checkpoint is ahead of the committed offset
+	s := newTestSource(t, b, "t1", nil)
+	err := s.Rewind(map[string]interface{}{"t1": map[string]interface{}{"0": float64(3)}})
+	assert.NoError(t, err)
+	ctx, result := runSource(t, s, 1)
+	assert.Equal(t, map[string]interface{}{"a": float64(3)}, result[0].Message())
+	assert.NoError(t, s.Close(ctx))
+
+	err = s.Rewind("invalid")
+	assert.EqualError(t, err, "invalid kafka source offset invalid")
+
+	// Without committed offset, start from the timestamp
+	b.committed = make(map[string]map[int]int64)
+	s = newTestSource(t, b, "t1", map[string]interface{}{"startOffset": 2000})
+	ctx, result = runSource(t, s, 2)
+	assert.Equal(t, map[string]interface{}{"a": float64(2)}, result[0].Message())
+	assert.Equal(t, map[string]interface{}{"a": float64(3)}, result[1].Message())
+	assert.NoError(t, s.Close(ctx))
+}
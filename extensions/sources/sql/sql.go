@@ -23,13 +23,15 @@ import (
 	"github.com/lf-edge/ekuiper/extensions/sqldatabase/sqlgen"
 	"github.com/lf-edge/ekuiper/extensions/util"
 	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/connection/clients"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/cast"
 )
 
 type sqlConConfig struct {
-	Interval int    `json:"interval"`
-	Url      string `json:"url"`
+	Interval           int    `json:"interval"`
+	Url                string `json:"url"`
+	ConnectionSelector string `json:"connectionSelector"`
 }
 
 type sqlsource struct {
@@ -37,6 +39,8 @@ type sqlsource struct {
 	Query sqlgen.SqlQueryGenerator
 	// The db connection instance
 	db *sql.DB
+	// The shared connection if connectionSelector is set
+	conn clients.Connection
 }
 
 func (m *sqlsource) Configure(_ string, props map[string]interface{}) error {
@@ -46,6 +50,12 @@ func (m *sqlsource) Configure(_ string, props map[string]interface{}) error {
 	if err != nil {
 		return fmt.Errorf("read properties %v fail with error: %v", props, err)
 	}
+	if cfg.ConnectionSelector != "" {
+		cfg.Url, err = util.ConnectionUrl(cfg.ConnectionSelector)
+		if err != nil {
+			return err
+		}
+	}
 	if cfg.Url == "" {
 		return fmt.Errorf("property Url is required")
 	}
@@ -65,10 +75,11 @@ func (m *sqlsource) Configure(_ string, props map[string]interface{}) error {
 
 	m.Query = generator
 	m.conf = cfg
-	db, err := util.FetchDBToOneNode(util.GlobalPool, m.conf.Url)
+	conn, db, err := util.FetchDB(m.conf.ConnectionSelector, m.conf.Url)
 	if err != nil {
 		return fmt.Errorf("connection to %s Open with error %v, support build tags are %v", m.conf.Url, err, driver2.KnownBuildTags())
 	}
+	m.conn = conn
 	m.db = db
 
 	return nil
@@ -138,7 +149,7 @@ func (m *sqlsource) Close(ctx api.StreamContext) error {
 	logger := ctx.GetLogger()
 	logger.Debugf("Closing sql stream to %v", m.conf)
 	if m.db != nil {
-		return util.ReturnDBFromOneNode(util.GlobalPool, m.conf.Url)
+		return util.ReturnDB(ctx, m.conn, m.conf.Url)
 	}
 
 	return nil
@@ -20,27 +20,32 @@ import (
 
 	"github.com/lf-edge/ekuiper/extensions/util"
 	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/connection/clients"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/cast"
 )
 
 type sqlLookupConfig struct {
-	Url string `json:"url"`
+	Url                string `json:"url"`
+	ConnectionSelector string `json:"connectionSelector"`
 }
 
 type sqlLookupSource struct {
-	url   string
-	table string
-	db    *sql.DB
+	url      string
+	selector string
+	table    string
+	db       *sql.DB
+	conn     clients.Connection
 }
 
 // Open establish a connection to the database
 func (s *sqlLookupSource) Open(ctx api.StreamContext) error {
 	ctx.GetLogger().Debugf("Opening sql lookup source")
-	db, err := util.FetchDBToOneNode(util.GlobalPool, s.url)
+	conn, db, err := util.FetchDB(s.selector, s.url)
 	if err != nil {
 		return fmt.Errorf("connection to %s Open with error %v", s.url, err)
 	}
+	s.conn = conn
 	s.db = db
 	return nil
 }
@@ -51,17 +56,126 @@ func (s *sqlLookupSource) Configure(datasource string, props map[string]interfac
 	if err != nil {
 		return fmt.Errorf("read properties %v fail with error: %v", props, err)
 	}
+	if cfg.ConnectionSelector != "" {
+		cfg.Url, err = util.ConnectionUrl(cfg.ConnectionSelector)
+		if err != nil {
+			return err
+		}
+	}
 	if cfg.Url == "" {
 		return fmt.Errorf("property Url is required")
 	}
 	s.url = cfg.Url
+	s.selector = cfg.ConnectionSelector
 	s.table = datasource
 	return nil
 }
 
 func (s *sqlLookupSource) Lookup(ctx api.StreamContext, fields []string, keys []string, values []interface{}) ([]api.SourceTuple, error) {
 	ctx.GetLogger().Debug("Start to lookup tuple")
-	rcvTime := conf.GetNow()
+	query := s.selectClause(fields) + " WHERE " + condition(keys, values)
+	ctx.GetLogger().Debugf("Query is %s", query)
+	return s.query(query)
+}
+
+// LookupBatch queries all the lookup values in one statement by OR conditions and matches the result rows back to
+// each lookup values by the key columns
+func (s *sqlLookupSource) LookupBatch(ctx api.StreamContext, fields []string, keys []string, values [][]interface{}) ([][]api.SourceTuple, error) {
+	ctx.GetLogger().Debugf("Start to lookup %d tuples", len(values))
+	if len(values) == 0 {
+		return nil, nil
+	}
+	fields, extra := withKeys(fields, keys)
+	query := s.selectClause(fields) + " WHERE "
+	index := make(map[string]int, len(values))
+	for i, v := range values {
+		if i > 0 {
+			query += " OR "
+		}
+		query += "(" + condition(keys, v) + ")"
+		index[cast.ToKeyString(v)] = i
+	}
+	ctx.GetLogger().Debugf("Query is %s", query)
+	rows, err := s.query(query)
+	if err != nil {
+		return nil, err
+	}
+	result := make([][]api.SourceTuple, len(values))
+	kvs := make([]interface{}, len(keys))
+	for _, r := range rows {
+		m := r.Message()
+		for i, k := range keys {
+			kvs[i] = m[k]
+		}
+		i, ok := index[cast.ToKeyString(kvs)]
+		if !ok {
+			continue
+		}
+		for _, k := range extra {
+			delete(m, k)
+		}
+		result[i] = append(result[i], r)
+	}
+	return result, nil
+}
+
+// Preload reads the whole table and groups the rows by the key columns
+func (s *sqlLookupSource) Preload(ctx api.StreamContext, fields []string, keys []string) ([][]interface{}, [][]api.SourceTuple, error) {
+	fields, extra := withKeys(fields, keys)
+	query := s.selectClause(fields)
+	ctx.GetLogger().Debugf("Preload query is %s", query)
+	rows, err := s.query(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		values  [][]interface{}
+		results [][]api.SourceTuple
+	)
+	index := make(map[string]int)
+	for _, r := range rows {
+		m := r.Message()
+		kvs := make([]interface{}, len(keys))
+		for i, k := range keys {
+			kvs[i] = m[k]
+		}
+		for _, k := range extra {
+			delete(m, k)
+		}
+		k := cast.ToKeyString(kvs)
+		i, ok := index[k]
+		if !ok {
+			i = len(values)
+			index[k] = i
+			values = append(values, kvs)
+			results = append(results, nil)
+		}
+		results[i] = append(results[i], r)
+	}
+	return values, results, nil
+}
+
+// withKeys adds the key columns which are required to match the rows to the selected fields. It returns the fields
+// to select and the added columns.
+func withKeys(fields []string, keys []string) ([]string, []string) {
+	if len(fields) == 0 {
+		return fields, nil
+	}
+	var extra []string
+	selected := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		selected[f] = true
+	}
+	for _, k := range keys {
+		if !selected[k] {
+			extra = append(extra, k)
+			selected[k] = true
+		}
+	}
+	return append(fields[:len(fields):len(fields)], extra...), extra
+}
+
+func (s *sqlLookupSource) selectClause(fields []string) string {
 	query := "SELECT "
 	if len(fields) == 0 {
 		query += "*"
@@ -73,23 +187,32 @@ func (s *sqlLookupSource) Lookup(ctx api.StreamContext, fields []string, keys []
 			query += f
 		}
 	}
-	query += fmt.Sprintf(" FROM %s WHERE ", s.table)
+	return query + fmt.Sprintf(" FROM %s", s.table)
+}
+
+func condition(keys []string, values []interface{}) string {
+	var cond string
 	for i, k := range keys {
 		if i > 0 {
-			query += " AND "
+			cond += " AND "
 		}
 		switch v := values[i].(type) {
 		case string:
-			query += fmt.Sprintf("`%s` = '%s'", k, v)
+			cond += fmt.Sprintf("`%s` = '%s'", k, v)
 		default:
-			query += fmt.Sprintf("`%s` = %v", k, v)
+			cond += fmt.Sprintf("`%s` = %v", k, v)
 		}
 	}
-	ctx.GetLogger().Debugf("Query is %s", query)
+	return cond
+}
+
+func (s *sqlLookupSource) query(query string) ([]api.SourceTuple, error) {
+	rcvTime := conf.GetNow()
 	rows, err := s.db.Query(query)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 	cols, _ := rows.Columns()
 
 	types, err := rows.ColumnTypes()
@@ -116,7 +239,7 @@ func (s *sqlLookupSource) Close(ctx api.StreamContext) error {
 	ctx.GetLogger().Debugf("Closing sql lookup source")
 	defer func() { s.db = nil }()
 	if s.db != nil {
-		return util.ReturnDBFromOneNode(util.GlobalPool, s.url)
+		return util.ReturnDB(ctx, s.conn, s.url)
 	}
 	return nil
 }
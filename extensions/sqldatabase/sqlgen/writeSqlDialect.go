@@ -0,0 +1,318 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlgen
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ColumnType is the type of the column to create. It is inferred from the data type of the stream field.
+type ColumnType int
+
+const (
+	BigintColumn ColumnType = iota
+	FloatColumn
+	BooleanColumn
+	StringColumn
+	DatetimeColumn
+)
+
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// SqlWriteDialect generates the statements to write the rows whose syntax varies among the databases.
+// The columns and the values are already quoted as sql identifiers and literals.
+type SqlWriteDialect interface {
+	// InsertStatement inserts all the rows in one statement
+	InsertStatement(table string, columns []string, rows [][]string) string
+	// UpsertStatement inserts the rows or updates the existing rows which have the same value of the key column. The
+	// rows of the same key are merged into the last one because a statement cannot update a row twice.
+	UpsertStatement(table string, columns []string, rows [][]string, key string) string
+	// CreateTableStatement creates the table if not exists. The key is the primary key which is optional.
+	CreateTableStatement(table string, columns []Column, key string) string
+}
+
+// GetWriteDialect returns the dialect by the driver name parsed from the url
+func GetWriteDialect(driver string) SqlWriteDialect {
+	switch driver {
+	case "mysql", "mymysql":
+		return mysqlDialect
+	case "sqlserver", "mssql":
+		return sqlServerDialect
+	case "godror", "oracle":
+		return oracleDialect
+	case "clickhouse":
+		return clickhouseDialect
+	default:
+		return commonDialect
+	}
+}
+
+var (
+	// commonDialect uses ON CONFLICT which is supported by postgres and sqlite
+	commonDialect = &commonWriteDialect{
+		types: map[ColumnType]string{
+			BigintColumn:   "BIGINT",
+			FloatColumn:    "DOUBLE PRECISION",
+			BooleanColumn:  "BOOLEAN",
+			StringColumn:   "TEXT",
+			DatetimeColumn: "TIMESTAMP",
+		},
+		keyString: "TEXT",
+	}
+	mysqlDialect = &mysqlWriteDialect{
+		commonWriteDialect: &commonWriteDialect{
+			types: map[ColumnType]string{
+				BigintColumn:   "BIGINT",
+				FloatColumn:    "DOUBLE",
+				BooleanColumn:  "BOOLEAN",
+				StringColumn:   "TEXT",
+				DatetimeColumn: "DATETIME(3)",
+			},
+			// TEXT cannot be the primary key of mysql
+			keyString: "VARCHAR(255)",
+		},
+	}
+	sqlServerDialect = &sqlServerWriteDialect{
+		commonWriteDialect: &commonWriteDialect{
+			types: map[ColumnType]string{
+				BigintColumn:   "BIGINT",
+				FloatColumn:    "FLOAT",
+				BooleanColumn:  "BIT",
+				StringColumn:   "NVARCHAR(MAX)",
+				DatetimeColumn: "DATETIME2",
+			},
+			keyString: "NVARCHAR(255)",
+		},
+	}
+	oracleDialect = &oracleWriteDialect{
+		commonWriteDialect: &commonWriteDialect{
+			types: map[ColumnType]string{
+				BigintColumn:   "NUMBER(19)",
+				FloatColumn:    "BINARY_DOUBLE",
+				BooleanColumn:  "NUMBER(1)",
+				StringColumn:   "CLOB",
+				DatetimeColumn: "TIMESTAMP",
+			},
+			keyString: "VARCHAR2(255)",
+		},
+	}
+	clickhouseDialect = &clickhouseWriteDialect{
+		commonWriteDialect: &commonWriteDialect{
+			types: map[ColumnType]string{
+				BigintColumn:   "Int64",
+				FloatColumn:    "Float64",
+				BooleanColumn:  "Bool",
+				StringColumn:   "String",
+				DatetimeColumn: "DateTime64(3)",
+			},
+			keyString: "String",
+		},
+	}
+)
+
+type commonWriteDialect struct {
+	types map[ColumnType]string
+	// the type of the string column which is the primary key
+	keyString string
+}
+
+func (d *commonWriteDialect) InsertStatement(table string, columns []string, rows [][]string) string {
+	return fmt.Sprintf("INSERT INTO %s (%s) values %s;", table, strings.Join(columns, ","), joinRows(rows))
+}
+
+func (d *commonWriteDialect) UpsertStatement(table string, columns []string, rows [][]string, key string) string {
+	rows = lastByKey(columns, rows, key)
+	var sets []string
+	for _, c := range columns {
+		if c != key {
+			sets = append(sets, fmt.Sprintf("%s=excluded.%s", c, c))
+		}
+	}
+	action := "DO NOTHING"
+	if len(sets) > 0 {
+		action = "DO UPDATE SET " + strings.Join(sets, ",")
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) values %s ON CONFLICT (%s) %s;", table, strings.Join(columns, ","), joinRows(rows), key, action)
+}
+
+func (d *commonWriteDialect) CreateTableStatement(table string, columns []Column, key string) string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s);", table, d.columnDefs(columns, key))
+}
+
+// columnDefs returns the definitions of the columns and the primary key
+func (d *commonWriteDialect) columnDefs(columns []Column, key string) string {
+	defs := make([]string, 0, len(columns)+1)
+	for _, c := range columns {
+		t := d.types[c.Type]
+		if c.Name == key {
+			if c.Type == StringColumn {
+				t = d.keyString
+			}
+			t += " NOT NULL"
+		}
+		defs = append(defs, c.Name+" "+t)
+	}
+	if key != "" {
+		defs = append(defs, fmt.Sprintf("PRIMARY KEY (%s)", key))
+	}
+	return strings.Join(defs, ",")
+}
+
+// lastByKey removes the rows which are followed by a row of the same key. The rows without the key or whose key is
+// NULL are kept because they never conflict.
+func lastByKey(columns []string, rows [][]string, key string) [][]string {
+	k := -1
+	for i, c := range columns {
+		if c == key {
+			k = i
+			break
+		}
+	}
+	if k < 0 || len(rows) < 2 {
+		return rows
+	}
+	last := make(map[string]int, len(rows))
+	for i, row := range rows {
+		last[row[k]] = i
+	}
+	if len(last) == len(rows) {
+		return rows
+	}
+	result := make([][]string, 0, len(last))
+	for i, row := range rows {
+		if row[k] == "NULL" || last[row[k]] == i {
+			result = append(result, row)
+		}
+	}
+	return result
+}
+
+func joinRows(rows [][]string) string {
+	values := make([]string, len(rows))
+	for i, row := range rows {
+		values[i] = "(" + strings.Join(row, ",") + ")"
+	}
+	return strings.Join(values, ",")
+}
+
+type mysqlWriteDialect struct {
+	*commonWriteDialect
+}
+
+func (d *mysqlWriteDialect) UpsertStatement(table string, columns []string, rows [][]string, key string) string {
+	rows = lastByKey(columns, rows, key)
+	var sets []string
+	for _, c := range columns {
+		if c != key {
+			sets = append(sets, fmt.Sprintf("%s=VALUES(%s)", c, c))
+		}
+	}
+	if len(sets) == 0 {
+		sets = []string{fmt.Sprintf("%s=%s", key, key)}
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) values %s ON DUPLICATE KEY UPDATE %s;", table, strings.Join(columns, ","), joinRows(rows), strings.Join(sets, ","))
+}
+
+// mergeClauses returns the clauses of the MERGE statement after the ON condition
+func mergeClauses(columns []string, key string) string {
+	var sets, sources []string
+	for _, c := range columns {
+		if c != key {
+			sets = append(sets, fmt.Sprintf("target.%s=source.%s", c, c))
+		}
+		sources = append(sources, "source."+c)
+	}
+	var b strings.Builder
+	if len(sets) > 0 {
+		b.WriteString(" WHEN MATCHED THEN UPDATE SET ")
+		b.WriteString(strings.Join(sets, ","))
+	}
+	b.WriteString(fmt.Sprintf(" WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)", strings.Join(columns, ","), strings.Join(sources, ",")))
+	return b.String()
+}
+
+type sqlServerWriteDialect struct {
+	*commonWriteDialect
+}
+
+func (d *sqlServerWriteDialect) UpsertStatement(table string, columns []string, rows [][]string, key string) string {
+	rows = lastByKey(columns, rows, key)
+	return fmt.Sprintf("MERGE INTO %s AS target USING (VALUES %s) AS source (%s) ON target.%s = source.%s%s;", table, joinRows(rows), strings.Join(columns, ","), key, key, mergeClauses(columns, key))
+}
+
+func (d *sqlServerWriteDialect) CreateTableStatement(table string, columns []Column, key string) string {
+	return fmt.Sprintf("IF OBJECT_ID(N'%s', N'U') IS NULL CREATE TABLE %s (%s);", table, table, d.columnDefs(columns, key))
+}
+
+// oracleWriteDialect generates the statements without the ending semicolon which is not accepted by the oracle drivers
+type oracleWriteDialect struct {
+	*commonWriteDialect
+}
+
+func (d *oracleWriteDialect) InsertStatement(table string, columns []string, rows [][]string) string {
+	var b strings.Builder
+	b.WriteString("INSERT ALL")
+	for _, row := range rows {
+		b.WriteString(fmt.Sprintf(" INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ","), strings.Join(row, ",")))
+	}
+	b.WriteString(" SELECT 1 FROM dual")
+	return b.String()
+}
+
+func (d *oracleWriteDialect) UpsertStatement(table string, columns []string, rows [][]string, key string) string {
+	rows = lastByKey(columns, rows, key)
+	selects := make([]string, len(rows))
+	for i, row := range rows {
+		fields := make([]string, len(row))
+		for j, v := range row {
+			fields[j] = v + " " + columns[j]
+		}
+		selects[i] = "SELECT " + strings.Join(fields, ",") + " FROM dual"
+	}
+	return fmt.Sprintf("MERGE INTO %s target USING (%s) source ON (target.%s = source.%s)%s", table, strings.Join(selects, " UNION ALL "), key, key, mergeClauses(columns, key))
+}
+
+func (d *oracleWriteDialect) CreateTableStatement(table string, columns []Column, key string) string {
+	// Ignore the error ORA-00955 if the table exists
+	return fmt.Sprintf("BEGIN EXECUTE IMMEDIATE 'CREATE TABLE %s (%s)'; EXCEPTION WHEN OTHERS THEN IF SQLCODE != -955 THEN RAISE; END IF; END;", table, strings.ReplaceAll(d.columnDefs(columns, key), "'", "''"))
+}
+
+// clickhouseWriteDialect upserts by the ReplacingMergeTree engine which keeps the last inserted row of the same key
+type clickhouseWriteDialect struct {
+	*commonWriteDialect
+}
+
+func (d *clickhouseWriteDialect) UpsertStatement(table string, columns []string, rows [][]string, key string) string {
+	return d.InsertStatement(table, columns, lastByKey(columns, rows, key))
+}
+
+func (d *clickhouseWriteDialect) CreateTableStatement(table string, columns []Column, key string) string {
+	defs := make([]string, len(columns))
+	for i, c := range columns {
+		t := d.types[c.Type]
+		if c.Name != key {
+			t = "Nullable(" + t + ")"
+		}
+		defs[i] = c.Name + " " + t
+	}
+	if key == "" {
+		return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = MergeTree ORDER BY tuple();", table, strings.Join(defs, ","))
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = ReplacingMergeTree ORDER BY %s;", table, strings.Join(defs, ","), key)
+}
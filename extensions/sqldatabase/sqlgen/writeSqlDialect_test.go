@@ -0,0 +1,119 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlgen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteDialect(t *testing.T) {
+	columns := []string{"id", "name"}
+	rows := [][]string{{"1", "'John'"}, {"2", "'Susan'"}}
+	defs := []Column{{Name: "id", Type: BigintColumn}, {Name: "name", Type: StringColumn}, {Name: "ts", Type: DatetimeColumn}}
+	tests := []struct {
+		driver string
+		insert string
+		upsert string
+		create string
+	}{
+		{
+			driver: "postgres",
+			insert: "INSERT INTO t (id,name) values (1,'John'),(2,'Susan');",
+			upsert: "INSERT INTO t (id,name) values (1,'John'),(2,'Susan') ON CONFLICT (id) DO UPDATE SET name=excluded.name;",
+			create: "CREATE TABLE IF NOT EXISTS t (id BIGINT NOT NULL,name TEXT,ts TIMESTAMP,PRIMARY KEY (id));",
+		},
+		{
+			driver: "mysql",
+			insert: "INSERT INTO t (id,name) values (1,'John'),(2,'Susan');",
+			upsert: "INSERT INTO t (id,name) values (1,'John'),(2,'Susan') ON DUPLICATE KEY UPDATE name=VALUES(name);",
+			create: "CREATE TABLE IF NOT EXISTS t (id BIGINT NOT NULL,name TEXT,ts DATETIME(3),PRIMARY KEY (id));",
+		},
+		{
+			driver: "sqlserver",
+			insert: "INSERT INTO t (id,name) values (1,'John'),(2,'Susan');",
+			upsert: "MERGE INTO t AS target USING (VALUES (1,'John'),(2,'Susan')) AS source (id,name) ON target.id = source.id WHEN MATCHED THEN UPDATE SET target.name=source.name WHEN NOT MATCHED THEN INSERT (id,name) VALUES (source.id,source.name);",
+			create: "IF OBJECT_ID(N't', N'U') IS NULL CREATE TABLE t (id BIGINT NOT NULL,name NVARCHAR(MAX),ts DATETIME2,PRIMARY KEY (id));",
+		},
+		{
+			driver: "oracle",
+			insert: "INSERT ALL INTO t (id,name) VALUES (1,'John') INTO t (id,name) VALUES (2,'Susan') SELECT 1 FROM dual",
+			upsert: "MERGE INTO t target USING (SELECT 1 id,'John' name FROM dual UNION ALL SELECT 2 id,'Susan' name FROM dual) source ON (target.id = source.id) WHEN MATCHED THEN UPDATE SET target.name=source.name WHEN NOT MATCHED THEN INSERT (id,name) VALUES (source.id,source.name)",
+			create: "BEGIN EXECUTE IMMEDIATE 'CREATE TABLE t (id NUMBER(19) NOT NULL,name CLOB,ts TIMESTAMP,PRIMARY KEY (id))'; EXCEPTION WHEN OTHERS THEN IF SQLCODE != -955 THEN RAISE; END IF; END;",
+		},
+		{
+			driver: "clickhouse",
+			insert: "INSERT INTO t (id,name) values (1,'John'),(2,'Susan');",
+			upsert: "INSERT INTO t (id,name) values (1,'John'),(2,'Susan');",
+			create: "CREATE TABLE IF NOT EXISTS t (id Int64,name Nullable(String),ts Nullable(DateTime64(3))) ENGINE = ReplacingMergeTree ORDER BY id;",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.driver, func(t *testing.T) {
+			d := GetWriteDialect(tt.driver)
+			assert.Equal(t, tt.insert, d.InsertStatement("t", columns, rows))
+			assert.Equal(t, tt.upsert, d.UpsertStatement("t", columns, rows, "id"))
+			assert.Equal(t, tt.create, d.CreateTableStatement("t", defs, "id"))
+		})
+	}
+}
+
+func TestWriteDialectDuplicateKey(t *testing.T) {
+	columns := []string{"id", "name"}
+	rows := [][]string{{"1", "'John'"}, {"NULL", "'Lizz'"}, {"2", "'Susan'"}, {"1", "'Jack'"}, {"NULL", "'Diana'"}}
+	tests := []struct {
+		driver string
+		upsert string
+	}{
+		{
+			driver: "postgres",
+			upsert: "INSERT INTO t (id,name) values (NULL,'Lizz'),(2,'Susan'),(1,'Jack'),(NULL,'Diana') ON CONFLICT (id) DO UPDATE SET name=excluded.name;",
+		},
+		{
+			driver: "mysql",
+			upsert: "INSERT INTO t (id,name) values (NULL,'Lizz'),(2,'Susan'),(1,'Jack'),(NULL,'Diana') ON DUPLICATE KEY UPDATE name=VALUES(name);",
+		},
+		{
+			driver: "sqlserver",
+			upsert: "MERGE INTO t AS target USING (VALUES (NULL,'Lizz'),(2,'Susan'),(1,'Jack'),(NULL,'Diana')) AS source (id,name) ON target.id = source.id WHEN MATCHED THEN UPDATE SET target.name=source.name WHEN NOT MATCHED THEN INSERT (id,name) VALUES (source.id,source.name);",
+		},
+		{
+			driver: "oracle",
+			upsert: "MERGE INTO t target USING (SELECT NULL id,'Lizz' name FROM dual UNION ALL SELECT 2 id,'Susan' name FROM dual UNION ALL SELECT 1 id,'Jack' name FROM dual UNION ALL SELECT NULL id,'Diana' name FROM dual) source ON (target.id = source.id) WHEN MATCHED THEN UPDATE SET target.name=source.name WHEN NOT MATCHED THEN INSERT (id,name) VALUES (source.id,source.name)",
+		},
+		{
+			driver: "clickhouse",
+			upsert: "INSERT INTO t (id,name) values (NULL,'Lizz'),(2,'Susan'),(1,'Jack'),(NULL,'Diana');",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.driver, func(t *testing.T) {
+			assert.Equal(t, tt.upsert, GetWriteDialect(tt.driver).UpsertStatement("t", columns, rows, "id"))
+		})
+	}
+	// The input rows are not changed
+	assert.Len(t, rows, 5)
+}
+
+func TestWriteDialectKeyOnly(t *testing.T) {
+	rows := [][]string{{"'a'"}}
+	assert.Equal(t, "INSERT INTO t (id) values ('a') ON CONFLICT (id) DO NOTHING;", GetWriteDialect("sqlite3").UpsertStatement("t", []string{"id"}, rows, "id"))
+	assert.Equal(t, "INSERT INTO t (id) values ('a') ON DUPLICATE KEY UPDATE id=id;", GetWriteDialect("mysql").UpsertStatement("t", []string{"id"}, rows, "id"))
+	assert.Equal(t, "MERGE INTO t AS target USING (VALUES ('a')) AS source (id) ON target.id = source.id WHEN NOT MATCHED THEN INSERT (id) VALUES (source.id);", GetWriteDialect("sqlserver").UpsertStatement("t", []string{"id"}, rows, "id"))
+	// The string key needs a bounded type
+	assert.Equal(t, "CREATE TABLE IF NOT EXISTS t (id VARCHAR(255) NOT NULL,PRIMARY KEY (id));", GetWriteDialect("mysql").CreateTableStatement("t", []Column{{Name: "id", Type: StringColumn}}, "id"))
+	assert.Equal(t, "CREATE TABLE IF NOT EXISTS t (id Nullable(String)) ENGINE = MergeTree ORDER BY tuple();", GetWriteDialect("clickhouse").CreateTableStatement("t", []Column{{Name: "id", Type: StringColumn}}, ""))
+}
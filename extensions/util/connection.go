@@ -0,0 +1,94 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/internal/topo/connection/clients"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+func init() {
+	clients.RegisterConnectionFactory("sql", newConnection)
+}
+
+// dbConnection is the *sql.DB declared in connection.yaml and shared by the sql sources, lookup sources and sinks
+// with the same connectionSelector
+type dbConnection struct {
+	db *sql.DB
+}
+
+func newConnection(props map[string]interface{}) (clients.Connection, error) {
+	c := struct {
+		Url string `json:"url"`
+	}{}
+	if err := cast.MapToStruct(props, &c); err != nil {
+		return nil, err
+	}
+	if c.Url == "" {
+		return nil, fmt.Errorf("property Url is required")
+	}
+	db, err := openDB(c.Url, false)
+	if err != nil {
+		return nil, err
+	}
+	return &dbConnection{db: db}, nil
+}
+
+func (c *dbConnection) Ping(ctx api.StreamContext) error {
+	return c.db.PingContext(ctx)
+}
+
+func (c *dbConnection) Close(_ api.StreamContext) error {
+	return c.db.Close()
+}
+
+// ConnectionUrl returns the database url of a sql connection selector
+func ConnectionUrl(selector string) (string, error) {
+	props, err := clients.ReadConnectionConf("sql", selector)
+	if err != nil {
+		return "", err
+	}
+	url, ok := props["url"].(string)
+	if !ok || url == "" {
+		return "", fmt.Errorf("property Url is required in connection %s", selector)
+	}
+	return url, nil
+}
+
+// FetchDB returns the *sql.DB of the connection selector if set. Otherwise, it returns the *sql.DB of the url from
+// the GlobalPool. The returned connection must be passed to ReturnDB when not used.
+func FetchDB(selector string, url string) (clients.Connection, *sql.DB, error) {
+	if selector == "" {
+		db, err := FetchDBToOneNode(GlobalPool, url)
+		return nil, db, err
+	}
+	conn, err := clients.GetConnection("sql", map[string]interface{}{"connectionSelector": selector})
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, conn.(*dbConnection).db, nil
+}
+
+// ReturnDB returns the *sql.DB fetched by FetchDB
+func ReturnDB(ctx api.StreamContext, conn clients.Connection, url string) error {
+	if conn != nil {
+		return clients.ReleaseConnection(ctx, conn)
+	}
+	return ReturnDBFromOneNode(GlobalPool, url)
+}
@@ -0,0 +1,136 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+
+	"github.com/lf-edge/ekuiper/internal/pkg/cert"
+	"github.com/lf-edge/ekuiper/internal/topo/connection/clients"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+func init() {
+	clients.RegisterConnectionFactory("kafka", newKafkaConnection)
+}
+
+type kafkaConnectionConf struct {
+	Brokers            string `json:"brokers"`
+	SaslAuthType       string `json:"saslAuthType"`
+	SaslUserName       string `json:"saslUserName"`
+	SaslPassword       string `json:"saslPassword"`
+	Tls                bool   `json:"tls"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+	CertificationPath  string `json:"certificationPath"`
+	PrivateKeyPath     string `json:"privateKeyPath"`
+	RootCaPath         string `json:"rootCaPath"`
+}
+
+// KafkaConnection is the kafka brokers declared in connection.yaml. The kafka sources share the dialer and the kafka
+// sinks share the transport which pools the connections to the brokers.
+type KafkaConnection struct {
+	Brokers   []string
+	Dialer    *kafkago.Dialer
+	Transport *kafkago.Transport
+}
+
+func newKafkaConnection(props map[string]interface{}) (clients.Connection, error) {
+	c := &kafkaConnectionConf{
+		Brokers:      "localhost:9092",
+		SaslAuthType: "none",
+	}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return nil, err
+	}
+	var brokers []string
+	for _, b := range strings.Split(c.Brokers, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			brokers = append(brokers, b)
+		}
+	}
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("brokers can not be empty")
+	}
+	var (
+		mechanism sasl.Mechanism
+		err       error
+	)
+	switch c.SaslAuthType {
+	case "none":
+	case "plain":
+		mechanism = plain.Mechanism{
+			Username: c.SaslUserName,
+			Password: c.SaslPassword,
+		}
+	case "scram":
+		mechanism, err = scram.Mechanism(scram.SHA512, c.SaslUserName, c.SaslPassword)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("saslAuthType incorrect")
+	}
+	conn := &KafkaConnection{
+		Brokers: brokers,
+		Dialer: &kafkago.Dialer{
+			Timeout:       10 * time.Second,
+			DualStack:     true,
+			SASLMechanism: mechanism,
+		},
+		Transport: &kafkago.Transport{
+			SASL: mechanism,
+		},
+	}
+	if c.Tls || c.InsecureSkipVerify || c.CertificationPath != "" || c.RootCaPath != "" {
+		tlsConf, err := cert.GenerateTLSForClient(cert.TlsConfigurationOptions{
+			SkipCertVerify: c.InsecureSkipVerify,
+			CertFile:       c.CertificationPath,
+			KeyFile:        c.PrivateKeyPath,
+			CaFile:         c.RootCaPath,
+		})
+		if err != nil {
+			return nil, err
+		}
+		conn.Dialer.TLS = tlsConf
+		conn.Transport.TLS = tlsConf
+	}
+	return conn, nil
+}
+
+// Ping dials the first reachable broker
+func (c *KafkaConnection) Ping(ctx api.StreamContext) error {
+	var err error
+	for _, b := range c.Brokers {
+		var conn *kafkago.Conn
+		conn, err = c.Dialer.DialContext(ctx, "tcp", b)
+		if err == nil {
+			return conn.Close()
+		}
+	}
+	return err
+}
+
+func (c *KafkaConnection) Close(_ api.StreamContext) error {
+	c.Transport.CloseIdleConnections()
+	return nil
+}
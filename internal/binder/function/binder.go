@@ -113,6 +113,25 @@ func IsAggFunc(funcName string) bool {
 	return false
 }
 
+// IsIncAggFunc returns true if the function is an aggregate function which can be calculated incrementally
+func IsIncAggFunc(funcName string) bool {
+	f, _ := Function(funcName)
+	if af, ok := f.(api.AggregateFunction); ok {
+		return af.IsAggregate() && af.IsIncremental()
+	}
+	return false
+}
+
+// IsAnalyticCall returns true if the call is calculated as an analytic function. Besides the built-in analytic
+// functions, an incremental aggregate function called with OVER clause accumulates each row as an analytic function.
+func IsAnalyticCall(c *ast.Call) bool {
+	if IsAnalyticFunc(c.Name) {
+		return true
+	}
+	// Only the incremental aggregate functions can have the OVER clause besides the analytic functions
+	return (c.Partition != nil || c.WhenExpr != nil) && IsAggFunc(c.Name)
+}
+
 // NoAggFunc returns true if the function CANNOT be used in an aggregate query
 func NoAggFunc(funcName string) bool {
 	if funcName == "last_hit_count" || funcName == "last_hit_time" {
@@ -0,0 +1,365 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package function
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+// IncAggPrefix is the prefix of the cached field of the aggregate functions calculated incrementally by the window
+const IncAggPrefix = "$$ia"
+
+// The kind of the first valid value accumulated, which decides the result type like the batch calculation
+const (
+	accKindNone = iota
+	accKindInt
+	accKindFloat
+	accKindString
+)
+
+// IncAggAcc is the accumulator of the built-in incremental aggregate functions.
+// It is saved in the window state, so the fields are exported to be encoded by gob.
+type IncAggAcc struct {
+	// Count is the count of the non-nil values
+	Count int64
+	Kind  int
+	Int   int64
+	Float float64
+	Str   string
+	// Mean and M2 are the running mean and the sum of the squared differences from the mean for variance
+	Mean float64
+	M2   float64
+}
+
+func init() {
+	gob.Register(&IncAggAcc{})
+}
+
+// incAggFuncs are the built-in aggregate functions which can be calculated incrementally.
+// They are only used by the window to accumulate the tuples into panes. The aggregation of the whole group is
+// still calculated by the batch implementations in funcs_agg.go.
+var incAggFuncs = map[string]struct{}{
+	"count":   {},
+	"sum":     {},
+	"avg":     {},
+	"min":     {},
+	"max":     {},
+	"stddev":  {},
+	"stddevs": {},
+	"var":     {},
+	"vars":    {},
+}
+
+// BuiltinIncAggFunc returns the incremental implementation of the built-in aggregate function
+func BuiltinIncAggFunc(name string) (api.AggregateFunction, bool) {
+	if _, ok := incAggFuncs[name]; !ok {
+		return nil, false
+	}
+	return &builtinIncAgg{name: name}, true
+}
+
+// builtinIncAgg updates the accumulator in place and returns it
+type builtinIncAgg struct {
+	name string
+}
+
+var _ api.AggregateFunction = &builtinIncAgg{}
+
+func (f *builtinIncAgg) Validate(_ []interface{}) error {
+	return nil
+}
+
+func (f *builtinIncAgg) Exec(args []interface{}, ctx api.FunctionContext) (interface{}, bool) {
+	return builtins[f.name].exec(ctx, args)
+}
+
+func (f *builtinIncAgg) IsAggregate() bool {
+	return true
+}
+
+func (f *builtinIncAgg) IsIncremental() bool {
+	return true
+}
+
+func (f *builtinIncAgg) Init(_ api.FunctionContext) (interface{}, error) {
+	return &IncAggAcc{}, nil
+}
+
+func (f *builtinIncAgg) Accumulate(_ api.FunctionContext, acc interface{}, args []interface{}) (interface{}, error) {
+	a, err := toIncAggAcc(acc)
+	if err != nil {
+		return nil, err
+	}
+	v := args[0]
+	if v == nil && !f.isVariance() {
+		return a, nil
+	}
+	switch f.name {
+	case "count":
+		a.Count++
+	case "sum", "avg":
+		if a.Kind == accKindNone {
+			switch v.(type) {
+			case int, int64:
+				a.Kind = accKindInt
+			case float64:
+				a.Kind = accKindFloat
+			default:
+				return nil, fmt.Errorf("run %s function error: found invalid arg %[2]T(%[2]v)", f.name, v)
+			}
+		}
+		if err := a.add(v); err != nil {
+			return nil, err
+		}
+		a.Count++
+	case "min", "max":
+		if a.Kind == accKindNone {
+			switch t := v.(type) {
+			case int:
+				a.Kind, a.Int = accKindInt, int64(t)
+			case int64:
+				a.Kind, a.Int = accKindInt, t
+			case float64:
+				a.Kind, a.Float = accKindFloat, t
+			case string:
+				a.Kind, a.Str = accKindString, t
+			default:
+				return nil, fmt.Errorf("found invalid arg %[1]T(%[1]v)", v)
+			}
+		} else if err := a.compare(v, f.name == "max"); err != nil {
+			return nil, err
+		}
+		a.Count++
+	default: // variance
+		x, err := cast.ToFloat64(v, cast.CONVERT_SAMEKIND)
+		if err != nil {
+			return nil, fmt.Errorf("requires float64 but found %[1]T(%[1]v)", v)
+		}
+		a.Count++
+		d := x - a.Mean
+		a.Mean += d / float64(a.Count)
+		a.M2 += d * (x - a.Mean)
+	}
+	return a, nil
+}
+
+func (f *builtinIncAgg) Retract(_ api.FunctionContext, acc interface{}, args []interface{}) (interface{}, error) {
+	a, err := toIncAggAcc(acc)
+	if err != nil {
+		return nil, err
+	}
+	v := args[0]
+	if v == nil || a.Count == 0 {
+		return a, nil
+	}
+	switch f.name {
+	case "count":
+		a.Count--
+	case "sum", "avg":
+		switch a.Kind {
+		case accKindInt:
+			vi, err := cast.ToInt64(v, cast.CONVERT_SAMEKIND)
+			if err != nil {
+				return nil, fmt.Errorf("requires int but found %[1]T(%[1]v)", v)
+			}
+			a.Int -= vi
+		case accKindFloat:
+			vf, ok := v.(float64)
+			if !ok {
+				return nil, fmt.Errorf("requires float64 but found %[1]T(%[1]v)", v)
+			}
+			a.Float -= vf
+		}
+		a.Count--
+	case "min", "max":
+		return nil, fmt.Errorf("%s function does not support retract", f.name)
+	default: // variance
+		x, err := cast.ToFloat64(v, cast.CONVERT_SAMEKIND)
+		if err != nil {
+			return nil, fmt.Errorf("requires float64 but found %[1]T(%[1]v)", v)
+		}
+		if a.Count == 1 {
+			*a = IncAggAcc{}
+			return a, nil
+		}
+		mean := (float64(a.Count)*a.Mean - x) / float64(a.Count-1)
+		a.M2 -= (x - mean) * (x - a.Mean)
+		a.Mean = mean
+		a.Count--
+	}
+	if a.Count == 0 {
+		*a = IncAggAcc{}
+	}
+	return a, nil
+}
+
+func (f *builtinIncAgg) Merge(_ api.FunctionContext, acc1 interface{}, acc2 interface{}) (interface{}, error) {
+	a, err := toIncAggAcc(acc1)
+	if err != nil {
+		return nil, err
+	}
+	b, err := toIncAggAcc(acc2)
+	if err != nil {
+		return nil, err
+	}
+	if b.Count == 0 {
+		return a, nil
+	}
+	if a.Count == 0 {
+		*a = *b
+		return a, nil
+	}
+	switch f.name {
+	case "sum", "avg":
+		switch b.Kind {
+		case accKindInt:
+			if err := a.add(b.Int); err != nil {
+				return nil, err
+			}
+		case accKindFloat:
+			if err := a.add(b.Float); err != nil {
+				return nil, err
+			}
+		}
+	case "min", "max":
+		var err error
+		switch b.Kind {
+		case accKindInt:
+			err = a.compare(b.Int, f.name == "max")
+		case accKindFloat:
+			err = a.compare(b.Float, f.name == "max")
+		case accKindString:
+			err = a.compare(b.Str, f.name == "max")
+		}
+		if err != nil {
+			return nil, err
+		}
+	case "stddev", "stddevs", "var", "vars":
+		n := a.Count + b.Count
+		d := b.Mean - a.Mean
+		a.M2 += b.M2 + d*d*float64(a.Count)*float64(b.Count)/float64(n)
+		a.Mean += d * float64(b.Count) / float64(n)
+	}
+	a.Count += b.Count
+	return a, nil
+}
+
+func (f *builtinIncAgg) Result(_ api.FunctionContext, acc interface{}) (interface{}, error) {
+	a, err := toIncAggAcc(acc)
+	if err != nil {
+		return nil, err
+	}
+	if f.name == "count" {
+		return int(a.Count), nil
+	}
+	if a.Count == 0 {
+		return nil, nil
+	}
+	switch f.name {
+	case "sum", "min", "max":
+		switch a.Kind {
+		case accKindInt:
+			return a.Int, nil
+		case accKindFloat:
+			return a.Float, nil
+		default:
+			return a.Str, nil
+		}
+	case "avg":
+		if a.Kind == accKindInt {
+			return a.Int / a.Count, nil
+		}
+		return a.Float / float64(a.Count), nil
+	case "var":
+		return a.M2 / float64(a.Count), nil
+	case "vars":
+		return a.M2 / float64(a.Count-1), nil
+	case "stddev":
+		return math.Sqrt(a.M2 / float64(a.Count)), nil
+	default: // stddevs
+		return math.Sqrt(a.M2 / float64(a.Count-1)), nil
+	}
+}
+
+func (f *builtinIncAgg) isVariance() bool {
+	switch f.name {
+	case "stddev", "stddevs", "var", "vars":
+		return true
+	}
+	return false
+}
+
+func toIncAggAcc(acc interface{}) (*IncAggAcc, error) {
+	a, ok := acc.(*IncAggAcc)
+	if !ok {
+		return nil, fmt.Errorf("invalid accumulator %[1]T(%[1]v)", acc)
+	}
+	return a, nil
+}
+
+// add the value to the total by the kind like sliceIntTotal and sliceFloatTotal
+func (a *IncAggAcc) add(v interface{}) error {
+	switch a.Kind {
+	case accKindInt:
+		vi, err := cast.ToInt64(v, cast.CONVERT_SAMEKIND)
+		if err != nil {
+			return fmt.Errorf("requires int but found %[1]T(%[1]v)", v)
+		}
+		a.Int += vi
+	case accKindFloat:
+		vf, ok := v.(float64)
+		if !ok {
+			return fmt.Errorf("requires float64 but found %[1]T(%[1]v)", v)
+		}
+		a.Float += vf
+	}
+	return nil
+}
+
+// compare the value with the current max or min value by the kind like sliceIntMax and sliceFloatMax
+func (a *IncAggAcc) compare(v interface{}, isMax bool) error {
+	switch a.Kind {
+	case accKindInt:
+		vi, err := cast.ToInt64(v, cast.CONVERT_SAMEKIND)
+		if err != nil {
+			return fmt.Errorf("requires int64 but found %[1]T(%[1]v)", v)
+		}
+		if (isMax && vi > a.Int) || (!isMax && vi < a.Int) {
+			a.Int = vi
+		}
+	case accKindFloat:
+		vf, ok := v.(float64)
+		if !ok {
+			return fmt.Errorf("requires float64 but found %[1]T(%[1]v)", v)
+		}
+		if (isMax && vf > a.Float) || (!isMax && vf < a.Float) {
+			a.Float = vf
+		}
+	case accKindString:
+		vs, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("requires string but found %[1]T(%[1]v)", v)
+		}
+		if (isMax && vs > a.Str) || (!isMax && vs < a.Str) {
+			a.Str = vs
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package function
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	kctx "github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+func TestIncAggExec(t *testing.T) {
+	contextLogger := conf.Log.WithField("rule", "testIncExec")
+	ctx := kctx.WithValue(kctx.Background(), kctx.LoggerKey, contextLogger)
+	tempStore, _ := state.CreateStore("mockRule0", api.AtMostOnce)
+	fctx := kctx.NewDefaultFuncContext(ctx.WithMeta("mockRule0", "test", tempStore), 2)
+	all := []string{"count", "sum", "avg", "min", "max", "stddev", "stddevs", "var", "vars"}
+	tests := []struct {
+		args  []interface{}
+		names []string
+	}{
+		{args: []interface{}{1, 2, 3, 4, 5}, names: all},
+		{args: []interface{}{1.5, 2.5, 10.0, 3.25}, names: all},
+		// the variance functions do not accept nil like the batch ones
+		{args: []interface{}{int64(5), nil, int64(-2), int64(7)}, names: []string{"count", "sum", "avg", "min", "max"}},
+		{args: []interface{}{"foo", "bar", "self"}, names: []string{"count", "min", "max"}},
+		{args: []interface{}{}, names: all},
+	}
+	for i, tt := range tests {
+		for _, name := range tt.names {
+			f, ok := BuiltinIncAggFunc(name)
+			require.True(t, ok)
+			// Accumulate into two panes and merge them like the window
+			acc1, err := f.Init(fctx)
+			require.NoError(t, err)
+			acc2, err := f.Init(fctx)
+			require.NoError(t, err)
+			for j, v := range tt.args {
+				if j%2 == 0 {
+					acc1, err = f.Accumulate(fctx, acc1, []interface{}{v})
+				} else {
+					acc2, err = f.Accumulate(fctx, acc2, []interface{}{v})
+				}
+				require.NoError(t, err, fmt.Sprintf("%d.%s", i, name))
+			}
+			acc, err := f.Init(fctx)
+			require.NoError(t, err)
+			acc, err = f.Merge(fctx, acc, acc1)
+			require.NoError(t, err)
+			acc, err = f.Merge(fctx, acc, acc2)
+			require.NoError(t, err)
+			r, err := f.Result(fctx, acc)
+			require.NoError(t, err)
+			exp, _ := builtins[name].exec(fctx, []interface{}{tt.args})
+			if ef, ok := exp.(float64); ok {
+				assert.InDelta(t, ef, r, 1e-9, fmt.Sprintf("%d.%s", i, name))
+			} else {
+				assert.Equal(t, exp, r, fmt.Sprintf("%d.%s", i, name))
+			}
+		}
+	}
+}
+
+func TestIncAggRetract(t *testing.T) {
+	fctx := kctx.NewDefaultFuncContext(kctx.Background(), 1)
+	tests := []struct {
+		name string
+		exp  interface{}
+	}{
+		{name: "count", exp: 2},
+		{name: "sum", exp: int64(4)},
+		{name: "avg", exp: int64(2)},
+		{name: "var", exp: float64(1)},
+	}
+	for _, tt := range tests {
+		f, _ := BuiltinIncAggFunc(tt.name)
+		acc, err := f.Init(fctx)
+		require.NoError(t, err)
+		for _, v := range []interface{}{1, 10, 3} {
+			acc, err = f.Accumulate(fctx, acc, []interface{}{v})
+			require.NoError(t, err)
+		}
+		acc, err = f.Retract(fctx, acc, []interface{}{10})
+		require.NoError(t, err)
+		r, err := f.Result(fctx, acc)
+		require.NoError(t, err)
+		if ef, ok := tt.exp.(float64); ok {
+			assert.InDelta(t, ef, r, 1e-9, tt.name)
+		} else {
+			assert.Equal(t, tt.exp, r, tt.name)
+		}
+	}
+	f, _ := BuiltinIncAggFunc("max")
+	acc, _ := f.Init(fctx)
+	_, err := f.Retract(fctx, acc, []interface{}{1})
+	assert.NoError(t, err)
+	acc, _ = f.Accumulate(fctx, acc, []interface{}{1})
+	_, err = f.Retract(fctx, acc, []interface{}{1})
+	assert.EqualError(t, err, "max function does not support retract")
+
+	f, _ = BuiltinIncAggFunc("sum")
+	acc, _ = f.Init(fctx)
+	acc, _ = f.Accumulate(fctx, acc, []interface{}{1})
+	_, err = f.Accumulate(fctx, acc, []interface{}{"a"})
+	assert.EqualError(t, err, "requires int but found string(a)")
+	_, ok := BuiltinIncAggFunc("collect")
+	assert.False(t, ok)
+}
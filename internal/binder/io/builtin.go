@@ -21,6 +21,7 @@ import (
 	"github.com/lf-edge/ekuiper/internal/io/mqtt"
 	"github.com/lf-edge/ekuiper/internal/io/neuron"
 	"github.com/lf-edge/ekuiper/internal/io/sink"
+	"github.com/lf-edge/ekuiper/internal/io/tsstore"
 	plugin2 "github.com/lf-edge/ekuiper/internal/plugin"
 	"github.com/lf-edge/ekuiper/pkg/api"
 )
@@ -39,6 +40,7 @@ var (
 		"file":     func() api.Source { return &file.FileSource{} },
 		"memory":   func() api.Source { return memory.GetSource() },
 		"neuron":   func() api.Source { return neuron.GetSource() },
+		"tsstore":  func() api.Source { return tsstore.GetSource() },
 	}
 	sinks = map[string]NewSinkFunc{
 		"log":         sink.NewLogSink,
@@ -49,10 +51,12 @@ var (
 		"memory":      func() api.Sink { return memory.GetSink() },
 		"neuron":      func() api.Sink { return neuron.GetSink() },
 		"file":        func() api.Sink { return file.File() },
+		"tsstore":     func() api.Sink { return tsstore.GetSink() },
 	}
 	lookupSources = map[string]NewLookupSourceFunc{
 		"memory":   func() api.LookupSource { return memory.GetLookupSource() },
 		"httppull": func() api.LookupSource { return http.GetLookUpSource() },
+		"tsstore":  func() api.LookupSource { return tsstore.GetLookupSource() },
 	}
 )
 
@@ -125,6 +125,91 @@ func (sc *SourceConf) Validate() error {
 	return errs
 }
 
+// RuleEventConf is the configuration of the subscribers of the rule lifecycle events
+type RuleEventConf struct {
+	// BufferLength is the number of events buffered for each subscriber. Events are dropped if the buffer is full.
+	BufferLength int                        `json:"bufferLength" yaml:"bufferLength"`
+	Subscribers  []*RuleEventSubscriberConf `json:"subscribers" yaml:"subscribers"`
+}
+
+// RuleEventSubscriberConf defines a sink to send the rule events to
+type RuleEventSubscriberConf struct {
+	// Type is the sink type such as rest, mqtt and memory
+	Type string `json:"type" yaml:"type"`
+	// Events are the event types to subscribe. Subscribe all if not set.
+	Events []string `json:"events" yaml:"events"`
+	// Rules are the rule ids to subscribe. Subscribe all if not set.
+	Rules []string               `json:"rules" yaml:"rules"`
+	Props map[string]interface{} `json:"props" yaml:"props"`
+}
+
+func (rc *RuleEventConf) Validate() error {
+	var errs error
+	if rc.BufferLength <= 0 {
+		rc.BufferLength = 1024
+	}
+	for i, s := range rc.Subscribers {
+		if s == nil || s.Type == "" {
+			errs = errors.Join(errs, fmt.Errorf("invalidRuleEventSubscriber:type of subscriber %d is required", i))
+		}
+	}
+	return errs
+}
+
+// TracingConf is the configuration of the OpenTelemetry tracing which exports the spans by OTLP over http
+type TracingConf struct {
+	Enable bool `json:"enable" yaml:"enable"`
+	// Endpoint is the host and port of the OTLP collector
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+	// UrlPath is the path to export the spans, default to /v1/traces
+	UrlPath  string            `json:"urlPath" yaml:"urlPath"`
+	Insecure bool              `json:"insecure" yaml:"insecure"`
+	Headers  map[string]string `json:"headers" yaml:"headers"`
+	// ServiceName is the service.name of the spans
+	ServiceName string `json:"serviceName" yaml:"serviceName"`
+	// SampleRatio is the ratio of the messages to trace if the message does not carry a trace context
+	SampleRatio float64 `json:"sampleRatio" yaml:"sampleRatio"`
+}
+
+func (tc *TracingConf) Validate() error {
+	var errs error
+	if tc.Endpoint == "" {
+		tc.Endpoint = "localhost:4318"
+	}
+	if tc.ServiceName == "" {
+		tc.ServiceName = "kuiperd"
+	}
+	if tc.SampleRatio < 0 || tc.SampleRatio > 1 {
+		tc.SampleRatio = 1
+		Log.Warnf("tracing sampleRatio is not in [0, 1], set to 1")
+		errs = errors.Join(errs, errors.New("invalidSampleRatio:sampleRatio must be between 0 and 1"))
+	}
+	return errs
+}
+
+// AuditConf is the retention of the audit records. The oldest records are deleted when saving a new one.
+type AuditConf struct {
+	// MaxCount is the max number of the records to keep, 0 means no limit
+	MaxCount int `json:"maxCount" yaml:"maxCount"`
+	// MaxAge is the max hours to keep the records, 0 means no limit
+	MaxAge int `json:"maxAge" yaml:"maxAge"`
+}
+
+func (ac *AuditConf) Validate() error {
+	var errs error
+	if ac.MaxCount < 0 {
+		ac.MaxCount = 0
+		Log.Warnf("audit maxCount is negative, set to 0")
+		errs = errors.Join(errs, errors.New("invalidMaxCount:maxCount must not be negative"))
+	}
+	if ac.MaxAge < 0 {
+		ac.MaxAge = 0
+		Log.Warnf("audit maxAge is negative, set to 0")
+		errs = errors.Join(errs, errors.New("invalidMaxAge:maxAge must not be negative"))
+	}
+	return errs
+}
+
 type SQLConf struct {
 	MaxConnections int `yaml:"maxConnections"`
 }
@@ -146,14 +231,19 @@ type KuiperConf struct {
 		PrometheusPort     int      `yaml:"prometheusPort"`
 		PluginHosts        string   `yaml:"pluginHosts"`
 		Authentication     bool     `yaml:"authentication"`
+		AuthSignKey        string   `yaml:"authSignKey"`
+		AllowedOrigins     []string `yaml:"allowedOrigins"`
 		IgnoreCase         bool     `yaml:"ignoreCase"`
 		SQLConf            *SQLConf `yaml:"sql"`
 		RulePatrolInterval string   `yaml:"rulePatrolInterval"`
 	}
-	Rule   api.RuleOption
-	Sink   *SinkConf
-	Source *SourceConf
-	Store  struct {
+	Rule      api.RuleOption
+	RuleEvent *RuleEventConf `yaml:"ruleEvent"`
+	Tracing   *TracingConf   `yaml:"tracing"`
+	Audit     *AuditConf     `yaml:"audit"`
+	Sink      *SinkConf
+	Source    *SourceConf
+	Store     struct {
 		Type         string `yaml:"type"`
 		ExtStateType string `yaml:"extStateType"`
 		Redis        struct {
@@ -295,6 +385,20 @@ func InitConf() {
 		Config.Sink = &SinkConf{}
 	}
 	_ = Config.Sink.Validate()
+	if Config.RuleEvent == nil {
+		Config.RuleEvent = &RuleEventConf{}
+	}
+	if err := Config.RuleEvent.Validate(); err != nil {
+		Log.Warn(err)
+	}
+	if Config.Tracing == nil {
+		Config.Tracing = &TracingConf{}
+	}
+	_ = Config.Tracing.Validate()
+	if Config.Audit == nil {
+		Config.Audit = &AuditConf{MaxCount: 10000, MaxAge: 720}
+	}
+	_ = Config.Audit.Validate()
 
 	_ = ValidateRuleOption(&Config.Rule)
 }
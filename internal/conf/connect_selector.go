@@ -2,6 +2,8 @@ package conf
 
 import (
 	"fmt"
+	"os"
+	"path"
 	"strings"
 )
 
@@ -43,8 +45,24 @@ func (c *ConSelector) ReadCfgFromYaml() (props map[string]interface{}, err error
 	jsonPath := "sources/" + c.Type + ".json"
 	if c.Type == "mqtt" {
 		jsonPath = "mqtt_source.json"
+	} else if !confFileExists(jsonPath) {
+		// connection types for sinks only like influx
+		jsonPath = "sinks/" + c.Type + ".json"
+		if !confFileExists(jsonPath) {
+			// keys are matched case-insensitively when converted to struct
+			return props, nil
+		}
 	}
 
 	err = CorrectsConfigKeysByJson(props, jsonPath)
 	return props, err
 }
+
+func confFileExists(p string) bool {
+	dir, err := GetConfLoc()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path.Join(dir, p))
+	return err == nil
+}
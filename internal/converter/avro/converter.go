@@ -0,0 +1,168 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avro
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/linkedin/goavro/v2"
+
+	"github.com/lf-edge/ekuiper/internal/pkg/def"
+	"github.com/lf-edge/ekuiper/internal/schema"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/message"
+)
+
+// magicByte is the first byte of the Confluent wire format, followed by the 4 bytes schema id
+const magicByte byte = 0
+
+type schemaCodec struct {
+	codec *goavro.Codec
+	t     *avroType
+}
+
+type Converter struct {
+	*schemaCodec
+	// id is the Confluent schema id. If it is not negative, the data is prefixed by the magic byte and the schema id
+	id int
+	sync.RWMutex
+	// writers are the codecs of the other Confluent schema ids which may be the older versions of the schema
+	writers map[int]*schemaCodec
+}
+
+// LoadConverter loads the avro schema from the registry. If the schema name is a number, it is regarded as the
+// Confluent schema id and the data is encoded and decoded in the Confluent wire format.
+func LoadConverter(schemaName string, messageName string, _ string) (message.Converter, error) {
+	content, err := readSchema(schemaName)
+	if err != nil {
+		return nil, err
+	}
+	id := -1
+	if n, err := strconv.ParseUint(schemaName, 10, 32); err == nil {
+		id = int(n)
+	}
+	return NewConverter(content, messageName, id)
+}
+
+func NewConverter(schemaContent string, messageName string, id int) (message.Converter, error) {
+	c, err := newCodec(schemaContent)
+	if err != nil {
+		return nil, err
+	}
+	if c.t.kind != "record" {
+		return nil, fmt.Errorf("the avro schema must be a record, but got %s", c.t.kind)
+	}
+	if messageName != "" && c.t.name != messageName && !strings.HasSuffix(c.t.name, "."+messageName) {
+		return nil, fmt.Errorf("message type %s not found in the avro schema, found %s", messageName, c.t.name)
+	}
+	return &Converter{
+		schemaCodec: c,
+		id:          id,
+		writers:     make(map[int]*schemaCodec),
+	}, nil
+}
+
+func newCodec(schemaContent string) (*schemaCodec, error) {
+	cc, err := goavro.NewCodec(schemaContent)
+	if err != nil {
+		return nil, fmt.Errorf("invalid avro schema: %v", err)
+	}
+	t, err := parseSchema(schemaContent)
+	if err != nil {
+		return nil, fmt.Errorf("invalid avro schema: %v", err)
+	}
+	return &schemaCodec{codec: cc, t: t}, nil
+}
+
+func readSchema(schemaName string) (string, error) {
+	ffs, err := schema.GetSchemaFile(def.AVRO, schemaName)
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(ffs.SchemaFile)
+	if err != nil {
+		return "", fmt.Errorf("cannot read schema file %s: %s", ffs.SchemaFile, err)
+	}
+	return string(content), nil
+}
+
+func (c *Converter) Encode(d interface{}) ([]byte, error) {
+	switch m := d.(type) {
+	case map[string]interface{}:
+		native, err := toNative(m, c.t, cast.CONVERT_SAMEKIND)
+		if err != nil {
+			return nil, err
+		}
+		var buf []byte
+		if c.id >= 0 {
+			buf = make([]byte, 5, 64)
+			buf[0] = magicByte
+			binary.BigEndian.PutUint32(buf[1:], uint32(c.id))
+		}
+		return c.codec.BinaryFromNative(buf, native)
+	default:
+		return nil, fmt.Errorf("unsupported type %v, must be a map", d)
+	}
+}
+
+func (c *Converter) Decode(b []byte) (interface{}, error) {
+	w := c.schemaCodec
+	if c.id >= 0 {
+		if len(b) < 5 || b[0] != magicByte {
+			return nil, fmt.Errorf("invalid confluent avro data, must start with the magic byte and the schema id")
+		}
+		id := int(binary.BigEndian.Uint32(b[1:5]))
+		b = b[5:]
+		if id != c.id {
+			var err error
+			w, err = c.writer(id)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	native, _, err := w.codec.NativeFromBinary(b)
+	if err != nil {
+		return nil, err
+	}
+	return fromNative(native, w.t)
+}
+
+// writer gets the codec of the schema id which the data was written with
+func (c *Converter) writer(id int) (*schemaCodec, error) {
+	c.RLock()
+	w, ok := c.writers[id]
+	c.RUnlock()
+	if ok {
+		return w, nil
+	}
+	content, err := readSchema(strconv.Itoa(id))
+	if err != nil {
+		return nil, fmt.Errorf("cannot find the writer schema of id %d: %v", id, err)
+	}
+	w, err = newCodec(content)
+	if err != nil {
+		return nil, err
+	}
+	c.Lock()
+	c.writers[id] = w
+	c.Unlock()
+	return w, nil
+}
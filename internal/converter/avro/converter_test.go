@@ -0,0 +1,244 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avro
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/schema"
+	"github.com/lf-edge/ekuiper/internal/testx"
+)
+
+const bookSchema = `{"type": "record", "name": "Book", "fields": [{"name": "name", "type": "string"}, {"name": "id", "type": "long"}]}`
+
+func TestEncode(t *testing.T) {
+	c, err := NewConverter(bookSchema, "Book", -1)
+	require.NoError(t, err)
+	tests := []struct {
+		m map[string]interface{}
+		r []byte
+		e string
+	}{
+		{
+			m: map[string]interface{}{
+				"name": "test",
+				"id":   1,
+			},
+			r: []byte{0x08, 0x74, 0x65, 0x73, 0x74, 0x02},
+		}, {
+			m: map[string]interface{}{
+				"name":  "test",
+				"id":    1.0,
+				"other": "ignored",
+			},
+			r: []byte{0x08, 0x74, 0x65, 0x73, 0x74, 0x02},
+		}, {
+			m: map[string]interface{}{
+				"name": "test",
+			},
+			e: "cannot encode binary record \"Book\" field \"id\": schema does not specify default value and no value provided",
+		}, {
+			m: map[string]interface{}{
+				"name": 1,
+				"id":   1,
+			},
+			e: "invalid field name: cannot convert int(1) to string",
+		},
+	}
+	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
+	for i, tt := range tests {
+		a, err := c.Encode(tt.m)
+		if !reflect.DeepEqual(tt.e, testx.Errstring(err)) {
+			t.Errorf("%d.error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.e, err)
+		} else if tt.e == "" && !reflect.DeepEqual(tt.r, a) {
+			t.Errorf("%d. \n\nresult mismatch:\n\nexp=%x\n\ngot=%x\n\n", i, tt.r, a)
+		}
+	}
+	_, err = c.Encode([]map[string]interface{}{{"name": "test", "id": 1}})
+	assert.EqualError(t, err, "unsupported type [map[id:1 name:test]], must be a map")
+}
+
+func TestEncodeDecode(t *testing.T) {
+	content, err := os.ReadFile("../../schema/test/test1.avsc")
+	require.NoError(t, err)
+	c, err := NewConverter(string(content), "Person", -1)
+	require.NoError(t, err)
+	ts := time.UnixMilli(1690000000123).UTC()
+	tests := []struct {
+		m map[string]interface{}
+		r map[string]interface{}
+		e string
+	}{
+		{
+			m: map[string]interface{}{
+				"name":     "test",
+				"id":       int64(1),
+				"age":      30,
+				"score":    1.5,
+				"tags":     []interface{}{"a", "b"},
+				"address":  map[string]interface{}{"city": "hz"},
+				"contacts": []map[string]interface{}{{"city": "sh", "zip": "200000"}},
+				"attrs":    map[string]interface{}{"a": 1},
+				"level":    "HIGH",
+				"ts":       ts,
+				"raw":      []byte{0x01, 0x02},
+				"value":    "v",
+			},
+			r: map[string]interface{}{
+				"name":     "test",
+				"id":       int64(1),
+				"age":      int64(30),
+				"score":    1.5,
+				"tags":     []interface{}{"a", "b"},
+				"address":  map[string]interface{}{"city": "hz", "zip": nil},
+				"contacts": []interface{}{map[string]interface{}{"city": "sh", "zip": "200000"}},
+				"attrs":    map[string]interface{}{"a": 1.0},
+				"level":    "HIGH",
+				"ts":       ts,
+				"raw":      []byte{0x01, 0x02},
+				"value":    "v",
+			},
+		}, {
+			m: map[string]interface{}{
+				"name":     "test",
+				"id":       2,
+				"age":      nil,
+				"score":    2,
+				"tags":     []string{},
+				"address":  map[string]interface{}{"city": "hz", "zip": nil},
+				"contacts": []interface{}{},
+				"attrs":    map[string]interface{}{},
+				"level":    "LOW",
+				"ts":       int64(1690000000123),
+				"raw":      "ab",
+				"value":    5.0,
+			},
+			r: map[string]interface{}{
+				"name":     "test",
+				"id":       int64(2),
+				"age":      nil,
+				"score":    2.0,
+				"tags":     []interface{}{},
+				"address":  map[string]interface{}{"city": "hz", "zip": nil},
+				"contacts": []interface{}{},
+				"attrs":    map[string]interface{}{},
+				"level":    "LOW",
+				"ts":       ts,
+				"raw":      []byte("ab"),
+				"value":    int64(5),
+			},
+		}, {
+			m: map[string]interface{}{
+				"name":  "test",
+				"level": "MID",
+			},
+			e: "invalid field level: cannot convert string(MID) to avro type com.example.Level",
+		},
+	}
+	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
+	for i, tt := range tests {
+		a, err := c.Encode(tt.m)
+		if !reflect.DeepEqual(tt.e, testx.Errstring(err)) {
+			t.Errorf("%d.error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.e, err)
+			continue
+		}
+		if tt.e != "" {
+			continue
+		}
+		r, err := c.Decode(a)
+		if err != nil {
+			t.Errorf("%d.decode error: %v", i, err)
+		} else if !reflect.DeepEqual(tt.r, r) {
+			t.Errorf("%d. \n\nresult mismatch:\n\nexp=%v\n\ngot=%v\n\n", i, tt.r, r)
+		}
+	}
+}
+
+func TestNewConverterError(t *testing.T) {
+	tests := []struct {
+		s string
+		n string
+		e string
+	}{
+		{
+			s: bookSchema,
+			n: "Person",
+			e: "message type Person not found in the avro schema, found Book",
+		}, {
+			s: `"string"`,
+			e: "the avro schema must be a record, but got string",
+		}, {
+			s: `{"type": "record", "name": "Book", "fields": [{"name": "name", "type": "str"}]}`,
+			e: "invalid avro schema: Record \"Book\" field 1 ought to be valid Avro named type: unknown type name: \"str\"",
+		},
+	}
+	for i, tt := range tests {
+		_, err := NewConverter(tt.s, tt.n, -1)
+		if !reflect.DeepEqual(tt.e, testx.Errstring(err)) {
+			t.Errorf("%d.error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.e, err)
+		}
+	}
+}
+
+func TestConfluent(t *testing.T) {
+	testx.InitEnv()
+	dataDir, err := conf.GetDataLoc()
+	require.NoError(t, err)
+	etcDir := filepath.Join(dataDir, "schemas", "avro")
+	require.NoError(t, os.MkdirAll(etcDir, os.ModePerm))
+	defer func() {
+		_ = os.RemoveAll(etcDir)
+	}()
+	v2 := `{"type": "record", "name": "Book", "fields": [{"name": "name", "type": "string"}, {"name": "id", "type": "long"}, {"name": "price", "type": ["null", "double"], "default": null}]}`
+	require.NoError(t, os.WriteFile(filepath.Join(etcDir, "1.avsc"), []byte(bookSchema), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(etcDir, "2.avsc"), []byte(v2), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(etcDir, "book.avsc"), []byte(bookSchema), 0o755))
+	require.NoError(t, schema.InitRegistry())
+
+	c, err := LoadConverter("2", "Book", "")
+	require.NoError(t, err)
+	a, err := c.Encode(map[string]interface{}{"name": "test", "id": 1, "price": 9.5})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x00, 0x00, 0x00, 0x00, 0x02, 0x08, 0x74, 0x65, 0x73, 0x74, 0x02, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x23, 0x40}, a)
+	r, err := c.Decode(a)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"name": "test", "id": int64(1), "price": 9.5}, r)
+	// decode with the writer schema of id 1
+	r, err = c.Decode([]byte{0x00, 0x00, 0x00, 0x00, 0x01, 0x08, 0x74, 0x65, 0x73, 0x74, 0x02})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"name": "test", "id": int64(1)}, r)
+	_, err = c.Decode([]byte{0x00, 0x00, 0x00, 0x00, 0x03, 0x08, 0x74, 0x65, 0x73, 0x74, 0x02})
+	assert.EqualError(t, err, "cannot find the writer schema of id 3: schema type avro, file 3 not found")
+	_, err = c.Decode([]byte{0x08, 0x74, 0x65, 0x73, 0x74, 0x02})
+	assert.EqualError(t, err, "invalid confluent avro data, must start with the magic byte and the schema id")
+
+	// not a Confluent schema id
+	c, err = LoadConverter("book", "Book", "")
+	require.NoError(t, err)
+	a, err = c.Encode(map[string]interface{}{"name": "test", "id": 1})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x08, 0x74, 0x65, 0x73, 0x74, 0x02}, a)
+	_, err = LoadConverter("notexist", "Book", "")
+	assert.EqualError(t, err, "schema type avro, file notexist not found")
+}
@@ -0,0 +1,179 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avro
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// avroType is the parsed avro schema which guides the conversion between the eKuiper values and the goavro native values
+type avroType struct {
+	// name is the type name that goavro uses to wrap the union values
+	name string
+	// kind is the avro type like long, record, union etc.
+	kind    string
+	logical string
+	fields  []*avroField
+	// items is the type of array items or map values
+	items   *avroType
+	members []*avroType
+	symbols []string
+}
+
+type avroField struct {
+	name string
+	t    *avroType
+}
+
+var primitiveTypes = map[string]struct{}{
+	"null": {}, "boolean": {}, "int": {}, "long": {}, "float": {}, "double": {}, "bytes": {}, "string": {},
+}
+
+// logicalTypes are the logical types that goavro converts to go types like time.Time
+var logicalTypes = map[string]string{
+	"timestamp-millis": "long",
+	"timestamp-micros": "long",
+	"time-millis":      "int",
+	"time-micros":      "long",
+	"date":             "int",
+	"decimal":          "bytes",
+}
+
+type schemaParser struct {
+	named map[string]*avroType
+}
+
+func parseSchema(s string) (*avroType, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		// a primitive type name without quote
+		v = s
+	}
+	p := &schemaParser{named: make(map[string]*avroType)}
+	return p.parse(v, "")
+}
+
+func (p *schemaParser) parse(v interface{}, ns string) (*avroType, error) {
+	switch st := v.(type) {
+	case string:
+		return p.parseName(st, ns)
+	case []interface{}:
+		u := &avroType{kind: "union", members: make([]*avroType, 0, len(st))}
+		for _, m := range st {
+			mt, err := p.parse(m, ns)
+			if err != nil {
+				return nil, err
+			}
+			u.members = append(u.members, mt)
+		}
+		return u, nil
+	case map[string]interface{}:
+		tn, ok := st["type"].(string)
+		if !ok {
+			return p.parse(st["type"], ns)
+		}
+		switch tn {
+		case "record", "error", "enum", "fixed":
+			name, _ := st["name"].(string)
+			if name == "" {
+				return nil, fmt.Errorf("%s type must have a name", tn)
+			}
+			if namespace, ok := st["namespace"].(string); ok && !strings.Contains(name, ".") {
+				ns = namespace
+			}
+			fullName := name
+			if ns != "" && !strings.Contains(name, ".") {
+				fullName = ns + "." + name
+			}
+			if i := strings.LastIndex(fullName, "."); i > 0 {
+				ns = fullName[:i]
+			} else {
+				ns = ""
+			}
+			at := &avroType{name: fullName, kind: tn}
+			if tn == "error" {
+				at.kind = "record"
+			}
+			// register before parsing the fields to support recursive types
+			p.named[fullName] = at
+			switch tn {
+			case "record", "error":
+				fs, _ := st["fields"].([]interface{})
+				for _, f := range fs {
+					fm, ok := f.(map[string]interface{})
+					if !ok {
+						return nil, fmt.Errorf("invalid field %v of record %s", f, fullName)
+					}
+					fn, _ := fm["name"].(string)
+					ft, err := p.parse(fm["type"], ns)
+					if err != nil {
+						return nil, fmt.Errorf("invalid type of field %s: %v", fn, err)
+					}
+					at.fields = append(at.fields, &avroField{name: fn, t: ft})
+				}
+			case "enum":
+				ss, _ := st["symbols"].([]interface{})
+				for _, s := range ss {
+					if sym, ok := s.(string); ok {
+						at.symbols = append(at.symbols, sym)
+					}
+				}
+			case "fixed":
+				if lt, ok := st["logicalType"].(string); ok && lt == "decimal" {
+					at.logical = lt
+				}
+			}
+			return at, nil
+		case "array", "map":
+			key := "items"
+			if tn == "map" {
+				key = "values"
+			}
+			it, err := p.parse(st[key], ns)
+			if err != nil {
+				return nil, err
+			}
+			return &avroType{name: tn, kind: tn, items: it}, nil
+		default:
+			at, err := p.parseName(tn, ns)
+			if err != nil {
+				return nil, err
+			}
+			if lt, ok := st["logicalType"].(string); ok && logicalTypes[lt] == tn {
+				return &avroType{name: tn + "." + lt, kind: tn, logical: lt}, nil
+			}
+			return at, nil
+		}
+	default:
+		return nil, fmt.Errorf("invalid avro schema %v", v)
+	}
+}
+
+func (p *schemaParser) parseName(name string, ns string) (*avroType, error) {
+	if _, ok := primitiveTypes[name]; ok {
+		return &avroType{name: name, kind: name}, nil
+	}
+	if ns != "" {
+		if at, ok := p.named[ns+"."+name]; ok {
+			return at, nil
+		}
+	}
+	if at, ok := p.named[name]; ok {
+		return at, nil
+	}
+	return nil, fmt.Errorf("unknown avro type %s", name)
+}
@@ -0,0 +1,239 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avro
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+// fromNative converts the goavro native value to eKuiper value. The union wrappers are removed, int and float are
+// converted to int64 and float64.
+func fromNative(v interface{}, t *avroType) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch t.kind {
+	case "union":
+		m, ok := v.(map[string]interface{})
+		if !ok || len(m) != 1 {
+			return nil, fmt.Errorf("invalid union value %v", v)
+		}
+		for k, uv := range m {
+			for _, mt := range t.members {
+				if mt.name == k {
+					return fromNative(uv, mt)
+				}
+			}
+			return nil, fmt.Errorf("unknown union type %s", k)
+		}
+	case "record":
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid record value %v", v)
+		}
+		result := make(map[string]interface{}, len(t.fields))
+		for _, f := range t.fields {
+			fv, err := fromNative(m[f.name], f.t)
+			if err != nil {
+				return nil, fmt.Errorf("invalid field %s: %v", f.name, err)
+			}
+			result[f.name] = fv
+		}
+		return result, nil
+	case "array":
+		a, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid array value %v", v)
+		}
+		result := make([]interface{}, len(a))
+		for i, av := range a {
+			r, err := fromNative(av, t.items)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = r
+		}
+		return result, nil
+	case "map":
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid map value %v", v)
+		}
+		result := make(map[string]interface{}, len(m))
+		for k, mv := range m {
+			r, err := fromNative(mv, t.items)
+			if err != nil {
+				return nil, err
+			}
+			result[k] = r
+		}
+		return result, nil
+	}
+	switch nv := v.(type) {
+	case int32:
+		return int64(nv), nil
+	case float32:
+		return float64(nv), nil
+	case time.Duration:
+		if t.logical == "time-micros" {
+			return nv.Microseconds(), nil
+		}
+		return nv.Milliseconds(), nil
+	case *big.Rat:
+		f, _ := nv.Float64()
+		return f, nil
+	}
+	return v, nil
+}
+
+// toNative converts the eKuiper value to goavro native value according to the schema.
+// For union, it tries to find the member type which matches the value strictly at first.
+func toNative(v interface{}, t *avroType, sn cast.Strictness) (interface{}, error) {
+	switch t.kind {
+	case "null":
+		if v == nil {
+			return nil, nil
+		}
+	case "boolean":
+		return cast.ToBool(v, sn)
+	case "int":
+		if tv, ok := v.(time.Time); ok && t.logical == "date" {
+			return tv, nil
+		}
+		return cast.ToInt32(v, sn)
+	case "long":
+		if tv, ok := v.(time.Time); ok && (t.logical == "timestamp-millis" || t.logical == "timestamp-micros") {
+			return tv, nil
+		}
+		return cast.ToInt64(v, sn)
+	case "float":
+		return cast.ToFloat32(v, sn)
+	case "double":
+		return cast.ToFloat64(v, sn)
+	case "bytes", "fixed":
+		if t.logical == "decimal" {
+			if r, ok := v.(*big.Rat); ok {
+				return r, nil
+			}
+			f, err := cast.ToFloat64(v, cast.CONVERT_SAMEKIND)
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetFloat64(f), nil
+		}
+		return cast.ToBytes(v, sn)
+	case "string":
+		if sn == cast.STRICT {
+			if s, ok := v.(string); ok {
+				return s, nil
+			}
+			break
+		}
+		return cast.ToString(v, sn)
+	case "enum":
+		s, ok := v.(string)
+		if ok {
+			for _, sym := range t.symbols {
+				if s == sym {
+					return s, nil
+				}
+			}
+		}
+	case "union":
+		if v == nil {
+			if nullable(t) {
+				return nil, nil
+			}
+			break
+		}
+		for _, s := range []cast.Strictness{cast.STRICT, sn} {
+			for _, mt := range t.members {
+				if mt.kind == "null" {
+					continue
+				}
+				if r, err := toNative(v, mt, s); err == nil {
+					return goavro.Union(mt.name, r), nil
+				}
+			}
+		}
+	case "record":
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			break
+		}
+		result := make(map[string]interface{}, len(t.fields))
+		for _, f := range t.fields {
+			fv, ok := m[f.name]
+			if !ok {
+				// let goavro set the default value or report the missing field unless it is nullable
+				if nullable(f.t) {
+					result[f.name] = nil
+				}
+				continue
+			}
+			r, err := toNative(fv, f.t, sn)
+			if err != nil {
+				return nil, fmt.Errorf("invalid field %s: %v", f.name, err)
+			}
+			result[f.name] = r
+		}
+		return result, nil
+	case "array":
+		a := reflect.ValueOf(v)
+		if a.Kind() != reflect.Slice {
+			break
+		}
+		result := make([]interface{}, a.Len())
+		for i := range result {
+			r, err := toNative(a.Index(i).Interface(), t.items, sn)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = r
+		}
+		return result, nil
+	case "map":
+		m, err := cast.ToStringMap(v)
+		if err != nil {
+			break
+		}
+		result := make(map[string]interface{}, len(m))
+		for k, mv := range m {
+			r, err := toNative(mv, t.items, sn)
+			if err != nil {
+				return nil, err
+			}
+			result[k] = r
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("cannot convert %[1]T(%[1]v) to avro type %s", v, t.name)
+}
+
+func nullable(t *avroType) bool {
+	for _, mt := range t.members {
+		if mt.kind == "null" {
+			return true
+		}
+	}
+	return false
+}
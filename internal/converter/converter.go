@@ -21,6 +21,7 @@ import (
 	"github.com/lf-edge/ekuiper/internal/converter/binary"
 	"github.com/lf-edge/ekuiper/internal/converter/delimited"
 	"github.com/lf-edge/ekuiper/internal/converter/json"
+	"github.com/lf-edge/ekuiper/internal/converter/msgpack"
 	"github.com/lf-edge/ekuiper/pkg/ast"
 	"github.com/lf-edge/ekuiper/pkg/message"
 )
@@ -40,6 +41,9 @@ var converters = map[string]Instantiator{
 	message.FormatDelimited: func(_ string, _ string, delimiter string) (message.Converter, error) {
 		return delimited.NewConverter(delimiter)
 	},
+	message.FormatMsgpack: func(_ string, _ string, _ string) (message.Converter, error) {
+		return msgpack.GetConverter()
+	},
 }
 
 func GetOrCreateConverter(options *ast.Options) (message.Converter, error) {
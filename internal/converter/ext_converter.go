@@ -17,6 +17,7 @@
 package converter
 
 import (
+	"github.com/lf-edge/ekuiper/internal/converter/avro"
 	"github.com/lf-edge/ekuiper/internal/converter/custom"
 	"github.com/lf-edge/ekuiper/internal/converter/protobuf"
 	"github.com/lf-edge/ekuiper/internal/pkg/def"
@@ -33,4 +34,5 @@ func init() {
 		return protobuf.NewConverter(ffs.SchemaFile, ffs.SoFile, schemaMessageName)
 	}
 	converters[message.FormatCustom] = custom.LoadConverter
+	converters[message.FormatAvro] = avro.LoadConverter
 }
@@ -0,0 +1,98 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package msgpack
+
+import (
+	"bytes"
+	"math"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/message"
+)
+
+type Converter struct{}
+
+var converter = &Converter{}
+
+func GetConverter() (message.Converter, error) {
+	return converter, nil
+}
+
+func (c *Converter) Encode(d interface{}) ([]byte, error) {
+	return msgpack.Marshal(d)
+}
+
+// Decode decodes the msgpack map or array. The integers are decoded as int64 and the floats are decoded as float64
+// to be consistent with json format.
+func (c *Converter) Decode(b []byte) (interface{}, error) {
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+	dec.SetMapDecoder(decodeMap)
+	r, err := dec.DecodeInterface()
+	if err != nil {
+		return nil, err
+	}
+	return normalize(r), nil
+}
+
+// decodeMap decodes the map with any key type like integer into map[string]interface{}
+func decodeMap(dec *msgpack.Decoder) (interface{}, error) {
+	m, err := dec.DecodeUntypedMap()
+	if err != nil || m == nil {
+		return nil, err
+	}
+	result := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		result[cast.ToStringAlways(k)] = v
+	}
+	return result, nil
+}
+
+func normalize(v interface{}) interface{} {
+	switch nv := v.(type) {
+	case int8:
+		return int64(nv)
+	case int16:
+		return int64(nv)
+	case int32:
+		return int64(nv)
+	case uint8:
+		return int64(nv)
+	case uint16:
+		return int64(nv)
+	case uint32:
+		return int64(nv)
+	case uint64:
+		if nv <= math.MaxInt64 {
+			return int64(nv)
+		}
+		return nv
+	case float32:
+		return float64(nv)
+	case map[string]interface{}:
+		for k, mv := range nv {
+			nv[k] = normalize(mv)
+		}
+		return nv
+	case []interface{}:
+		for i, av := range nv {
+			nv[i] = normalize(av)
+		}
+		return nv
+	default:
+		return v
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package msgpack
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/lf-edge/ekuiper/internal/testx"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	tests := []struct {
+		m interface{}
+		r interface{}
+	}{
+		{
+			m: map[string]interface{}{
+				"name":   "test",
+				"id":     1,
+				"temp":   float32(23.5),
+				"hum":    60.1,
+				"ok":     true,
+				"raw":    []byte{0x01, 0x02},
+				"tags":   []interface{}{"a", int8(-1)},
+				"nested": map[string]interface{}{"big": uint64(math.MaxUint64), "small": uint8(1)},
+				"none":   nil,
+			},
+			r: map[string]interface{}{
+				"name":   "test",
+				"id":     int64(1),
+				"temp":   23.5,
+				"hum":    60.1,
+				"ok":     true,
+				"raw":    []byte{0x01, 0x02},
+				"tags":   []interface{}{"a", int64(-1)},
+				"nested": map[string]interface{}{"big": uint64(math.MaxUint64), "small": int64(1)},
+				"none":   nil,
+			},
+		}, {
+			m: []map[string]interface{}{{"id": 1}, {"id": 2}},
+			r: []interface{}{map[string]interface{}{"id": int64(1)}, map[string]interface{}{"id": int64(2)}},
+		}, {
+			m: map[int]interface{}{1: "a", 2: map[int]interface{}{3: "b"}},
+			r: map[string]interface{}{"1": "a", "2": map[string]interface{}{"3": "b"}},
+		},
+	}
+	c, _ := GetConverter()
+	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
+	for i, tt := range tests {
+		a, err := c.Encode(tt.m)
+		if err != nil {
+			t.Errorf("%d.encode error: %v", i, err)
+			continue
+		}
+		r, err := c.Decode(a)
+		if err != nil {
+			t.Errorf("%d.decode error: %v", i, err)
+		} else if !reflect.DeepEqual(tt.r, r) {
+			t.Errorf("%d. \n\nresult mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", i, tt.r, r)
+		}
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	c, _ := GetConverter()
+	b, _ := msgpack.Marshal("test")
+	_, err := c.Decode(b[:3])
+	if testx.Errstring(err) != "unexpected EOF" {
+		t.Errorf("error mismatch, got %v", err)
+	}
+}
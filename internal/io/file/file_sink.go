@@ -48,6 +48,10 @@ type fileSink struct {
 
 	mux sync.Mutex
 	fws map[string]*fileWriter
+	// two-phase commit mode, the files are written with an in progress name and renamed when committing
+	tx bool
+	// the in progress file name to the final file name of the current transaction
+	txFiles map[string]string
 }
 
 func (m *fileSink) Configure(props map[string]interface{}) error {
@@ -245,6 +249,11 @@ func (m *fileSink) GetFws(ctx api.StreamContext, fn string, item interface{}) (*
 			}
 			nfn = filepath.Join(fileDir, newFile)
 		}
+		if m.tx {
+			tfn := inProgressName(ctx, nfn)
+			m.txFiles[tfn] = nfn
+			nfn = tfn
+		}
 
 		fws, e = createFileWriter(ctx, nfn, m.c.FileType, headers, m.c.Compression)
 		if e != nil {
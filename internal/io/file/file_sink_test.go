@@ -26,8 +26,10 @@ import (
 	"github.com/lf-edge/ekuiper/internal/compressor"
 	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
 	"github.com/lf-edge/ekuiper/internal/topo/topotest/mockclock"
 	"github.com/lf-edge/ekuiper/internal/topo/transform"
+	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/message"
 )
 
@@ -593,20 +595,7 @@ func TestFileSinkRolling_Collect(t *testing.T) {
 
 // Test file rolling by count
 func TestFileSinkRollingCount_Collect(t *testing.T) {
-	// Remove existing files
-	err := filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if filepath.Ext(path) == ".dd" {
-			fmt.Println("Deleting file:", path)
-			return os.Remove(path)
-		}
-		return nil
-	})
-	if err != nil {
-		t.Fatal(err)
-	}
+	dir := t.TempDir()
 	conf.IsTesting = true
 	tests := []struct {
 		name     string
@@ -662,7 +651,7 @@ func TestFileSinkRollingCount_Collect(t *testing.T) {
 			// Create a file sink with the temporary file path
 			sink := &fileSink{}
 			err := sink.Configure(map[string]interface{}{
-				"path":               tt.fname,
+				"path":               filepath.Join(dir, tt.fname),
 				"fileType":           tt.ft,
 				"rollingInterval":    0,
 				"rollingCount":       1,
@@ -702,7 +691,7 @@ func TestFileSinkRollingCount_Collect(t *testing.T) {
 					fn = fmt.Sprintf("test_%s_%d.dd", tt.ft, 460+450*i)
 				}
 
-				contents, err := os.ReadFile(fn)
+				contents, err := os.ReadFile(filepath.Join(dir, fn))
 				if err != nil {
 					t.Fatal(err)
 				}
@@ -812,3 +801,124 @@ func TestFileSinkReopen(t *testing.T) {
 		t.Errorf("\nexpected\t %q \nbut got\t\t %q", string(exp), string(contents))
 	}
 }
+
+func TestFileSinkTransaction(t *testing.T) {
+	conf.IsTesting = true
+	dir := t.TempDir()
+	contextLogger := conf.Log.WithField("rule", "testTransaction")
+	tempStore, _ := state.CreateStore("testTransaction", api.AtMostOnce)
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithMeta("testTransaction", "op1", tempStore)
+	tf, _ := transform.GenTransform("", "json", "", "", "", []string{})
+	vCtx := context.WithValue(ctx.(*context.DefaultContext), context.TransKey, tf)
+	props := map[string]interface{}{
+		"path":               filepath.Join(dir, "tx.log"),
+		"fileType":           LINES_TYPE,
+		"format":             "json",
+		"rollingNamePattern": "suffix",
+		"checkInterval":      0,
+	}
+	listFiles := func() []string {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result []string
+		for _, e := range entries {
+			result = append(result, e.Name())
+		}
+		return result
+	}
+
+	mockclock.ResetClock(10)
+	c := mockclock.GetMockClock()
+	sink := &fileSink{}
+	if err := sink.Configure(props); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Open(vCtx); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Recover(vCtx, nil); err != nil {
+		t.Fatal(err)
+	}
+	// Transaction 1 is pre-committed and then committed
+	if err := sink.Collect(vCtx, map[string]interface{}{"key": "value1"}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(listFiles(), []string{"tx-10.log.testTransaction.inprogress"}) {
+		t.Errorf("unexpected files %v before pre-commit", listFiles())
+	}
+	if err := sink.PreCommit(vCtx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(listFiles(), []string{"tx-10.log.testTransaction.1.pending"}) {
+		t.Errorf("unexpected files %v after pre-commit", listFiles())
+	}
+	c.Add(100 * time.Millisecond)
+	if err := sink.Collect(vCtx, map[string]interface{}{"key": "value2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Commit(vCtx, 1); err != nil {
+		t.Fatal(err)
+	}
+	// Transaction 2 is pre-committed but the checkpoint is not completed before crash
+	if err := sink.PreCommit(vCtx, 2); err != nil {
+		t.Fatal(err)
+	}
+	c.Add(100 * time.Millisecond)
+	// Transaction 3 is in progress when crash
+	if err := sink.Collect(vCtx, map[string]interface{}{"key": "value3"}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(listFiles(), []string{"tx-10.log", "tx-110.log.testTransaction.2.pending", "tx-210.log.testTransaction.inprogress"}) {
+		t.Errorf("unexpected files %v before crash", listFiles())
+	}
+	// Recover from the checkpoint 2
+	sink = &fileSink{}
+	if err := sink.Configure(props); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Open(vCtx); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Recover(vCtx, []int64{2}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(listFiles(), []string{"tx-10.log", "tx-110.log"}) {
+		t.Errorf("unexpected files %v after recover", listFiles())
+	}
+	// Commit again is idempotent
+	if err := sink.Commit(vCtx, 2); err != nil {
+		t.Fatal(err)
+	}
+	// Abort discards the current transaction
+	if err := sink.Collect(vCtx, map[string]interface{}{"key": "value4"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Abort(vCtx); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(vCtx); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(listFiles(), []string{"tx-10.log", "tx-110.log"}) {
+		t.Errorf("unexpected files %v after abort", listFiles())
+	}
+	for fn, exp := range map[string]string{"tx-10.log": `{"key":"value1"}`, "tx-110.log": `{"key":"value2"}`} {
+		contents, err := os.ReadFile(filepath.Join(dir, fn))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(contents) != exp {
+			t.Errorf("file %s expected %s but got %s", fn, exp, string(contents))
+		}
+	}
+	// Recover requires rolling name pattern
+	sink = &fileSink{}
+	if err := sink.Configure(map[string]interface{}{"path": filepath.Join(dir, "tx.log")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Recover(vCtx, nil); err == nil {
+		t.Errorf("expect error for recover without rolling name pattern")
+	}
+}
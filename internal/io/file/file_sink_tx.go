@@ -0,0 +1,183 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+// The file sink implements api.TwoPhaseCommitSink by rename-on-commit.
+// In a transaction, the data is written to <file>.<ruleId>.inprogress. When pre-committing, the files are closed and
+// renamed to <file>.<ruleId>.<checkpointId>.pending. When committing, the pending files are renamed to the final name.
+const (
+	pendingFilesKey = "$$pendingFiles_"
+	txDirsKey       = "$$txDirs"
+)
+
+var _ api.TwoPhaseCommitSink = &fileSink{}
+
+func inProgressName(ctx api.StreamContext, fn string) string {
+	return fmt.Sprintf("%s.%s.inprogress", fn, ctx.GetRuleId())
+}
+
+func pendingName(ctx api.StreamContext, fn string, checkpointId int64) string {
+	return fmt.Sprintf("%s.%s.%d.pending", fn, ctx.GetRuleId(), checkpointId)
+}
+
+func (m *fileSink) Recover(ctx api.StreamContext, pending []int64) error {
+	if m.c.RollingNamePattern != "prefix" && m.c.RollingNamePattern != "suffix" {
+		return fmt.Errorf("rollingNamePattern must be prefix or suffix for exactly once file sink to avoid overwriting the committed files")
+	}
+	m.mux.Lock()
+	m.tx = true
+	m.txFiles = make(map[string]string)
+	m.mux.Unlock()
+	for _, cid := range pending {
+		if err := m.Commit(ctx, cid); err != nil {
+			return err
+		}
+	}
+	// Remove the files of the transactions which are not included in the restored checkpoint
+	dirs, err := getTxDirs(ctx)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(m.c.Path, "{{") {
+		dir := filepath.Dir(m.c.Path)
+		if i := sort.SearchStrings(dirs, dir); i == len(dirs) || dirs[i] != dir {
+			dirs = append(dirs, dir)
+		}
+	}
+	uncommitted := regexp.MustCompile(fmt.Sprintf(`\.%s\.(inprogress|\d+\.pending)$`, regexp.QuoteMeta(ctx.GetRuleId())))
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if e.IsDir() {
+				continue
+			}
+			if uncommitted.MatchString(name) {
+				ctx.GetLogger().Infof("file sink removes uncommitted file %s", name)
+				if err := os.Remove(filepath.Join(dir, name)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (m *fileSink) PreCommit(ctx api.StreamContext, checkpointId int64) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	var errs []error
+	for k, v := range m.fws {
+		if e := v.Close(ctx); e != nil {
+			errs = append(errs, e)
+		}
+		delete(m.fws, k)
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	dirs, err := getTxDirs(ctx)
+	if err != nil {
+		return err
+	}
+	files := make([]string, 0, len(m.txFiles))
+	for tfn, fn := range m.txFiles {
+		if err := os.Rename(tfn, pendingName(ctx, fn, checkpointId)); err != nil {
+			return err
+		}
+		files = append(files, fn)
+		dir := filepath.Dir(fn)
+		i := sort.SearchStrings(dirs, dir)
+		if i == len(dirs) || dirs[i] != dir {
+			dirs = append(dirs[:i], append([]string{dir}, dirs[i:]...)...)
+		}
+	}
+	m.txFiles = make(map[string]string)
+	if len(files) > 0 {
+		ctx.GetLogger().Debugf("file sink pre-commits files %v for checkpoint %d", files, checkpointId)
+		if err := ctx.PutState(fmt.Sprintf("%s%d", pendingFilesKey, checkpointId), files); err != nil {
+			return err
+		}
+	}
+	return ctx.PutState(txDirsKey, dirs)
+}
+
+func (m *fileSink) Commit(ctx api.StreamContext, checkpointId int64) error {
+	key := fmt.Sprintf("%s%d", pendingFilesKey, checkpointId)
+	s, err := ctx.GetState(key)
+	if err != nil {
+		return err
+	}
+	files, _ := s.([]string)
+	for _, fn := range files {
+		pfn := pendingName(ctx, fn, checkpointId)
+		if _, err := os.Stat(pfn); os.IsNotExist(err) {
+			// Already committed
+			continue
+		}
+		if err := os.Rename(pfn, fn); err != nil {
+			return err
+		}
+		ctx.GetLogger().Infof("file sink commits file %s", fn)
+	}
+	return ctx.DeleteState(key)
+}
+
+func (m *fileSink) Abort(ctx api.StreamContext) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	var errs []error
+	for k, v := range m.fws {
+		if e := v.Close(ctx); e != nil {
+			errs = append(errs, e)
+		}
+		delete(m.fws, k)
+	}
+	for tfn := range m.txFiles {
+		if e := os.Remove(tfn); e != nil && !os.IsNotExist(e) {
+			errs = append(errs, e)
+		}
+	}
+	m.txFiles = make(map[string]string)
+	return errors.Join(errs...)
+}
+
+func getTxDirs(ctx api.StreamContext) ([]string, error) {
+	s, err := ctx.GetState(txDirsKey)
+	if err != nil {
+		return nil, err
+	}
+	dirs, _ := s.([]string)
+	// copy to avoid changing the saved state
+	return append([]string{}, dirs...), nil
+}
@@ -57,6 +57,12 @@ type FileSource struct {
 	file   string
 	isDir  bool
 	config *FileSourceConfig
+
+	// rewind states. The offset is the file name and the count of the records which have been sent in that file
+	mu         sync.Mutex
+	offset     map[string]interface{}
+	rewindFile string
+	rewindLine int64
 }
 
 func (fs *FileSource) Close(ctx api.StreamContext) error {
@@ -172,6 +178,84 @@ func (fs *FileSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTupl
 	}
 }
 
+// GetOffset returns the file name and the count of sent records in it. The offset is not available for parallel loading
+// or table.
+func (fs *FileSource) GetOffset() (interface{}, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.offset == nil {
+		return nil, nil
+	}
+	return map[string]interface{}{"file": fs.offset["file"], "line": fs.offset["line"]}, nil
+}
+
+// Rewind skips the files before the offset file and the sent records in the offset file for the first load
+func (fs *FileSource) Rewind(offset interface{}) error {
+	if !fs.isRewindable() {
+		conf.Log.Warnf("file source %s does not support rewind for parallel loading or table, ignore it", fs.file)
+		return nil
+	}
+	m, ok := offset.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid file source offset %v", offset)
+	}
+	f, ok := m["file"].(string)
+	if !ok {
+		return fmt.Errorf("invalid file source offset %v", offset)
+	}
+	l, err := cast.ToInt64(m["line"], cast.CONVERT_SAMEKIND)
+	if err != nil {
+		return fmt.Errorf("invalid file source offset %v: %v", offset, err)
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.rewindFile = f
+	fs.rewindLine = l
+	return nil
+}
+
+func (fs *FileSource) isRewindable() bool {
+	return !fs.config.Parallel && !fs.config.IsTable
+}
+
+// rewindSkip returns whether to skip the whole file and how many records to skip in the file when rewinding
+func (fs *FileSource) rewindSkip(file string) (bool, int64) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.rewindFile == "" {
+		return false, 0
+	}
+	if file < fs.rewindFile {
+		return true, 0
+	}
+	line := int64(0)
+	if file == fs.rewindFile {
+		line = fs.rewindLine
+	}
+	// Only rewind once
+	fs.rewindFile = ""
+	fs.rewindLine = 0
+	return false, line
+}
+
+// send sends the tuple with the offset after consuming it, return false if the rule is stopped
+func (fs *FileSource) send(ctx api.StreamContext, consumer chan<- api.SourceTuple, tuple api.SourceTuple, file string, line int64) bool {
+	if fs.isRewindable() {
+		tuple = api.NewOffsetSourceTuple(tuple, map[string]interface{}{"file": file, "line": line})
+	}
+	select {
+	case consumer <- tuple:
+		if fs.isRewindable() {
+			fs.mu.Lock()
+			fs.offset = map[string]interface{}{"file": file, "line": line}
+			fs.mu.Unlock()
+		}
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
 func (fs *FileSource) Load(ctx api.StreamContext, consumer chan<- api.SourceTuple) error {
 	rcvTime := conf.GetNow()
 	if fs.isDir {
@@ -259,10 +343,17 @@ func (fs *FileSource) parseFile(ctx api.StreamContext, file string, consumer cha
 			}
 		}
 	}()
-	return fs.publish(ctx, r, consumer, meta)
+	skipFile, skip := fs.rewindSkip(file)
+	if skipFile {
+		// The file has been read completely before the rewind offset
+		ctx.GetLogger().Infof("Skip file %s which is read before the rewind offset", file)
+		return nil
+	}
+	return fs.publish(ctx, r, consumer, meta, file, skip)
 }
 
-func (fs *FileSource) publish(ctx api.StreamContext, file io.Reader, consumer chan<- api.SourceTuple, meta map[string]interface{}) error {
+// publish sends out the records in the file. The first skip records are ignored for rewinding.
+func (fs *FileSource) publish(ctx api.StreamContext, file io.Reader, consumer chan<- api.SourceTuple, meta map[string]interface{}, fileName string, skip int64) error {
 	ctx.GetLogger().Debug("Start to load")
 	rcvTime := conf.GetNow()
 	switch fs.config.FileType {
@@ -274,10 +365,11 @@ func (fs *FileSource) publish(ctx api.StreamContext, file io.Reader, consumer ch
 			return fmt.Errorf("loaded %s, check error %s", fs.file, err)
 		}
 		ctx.GetLogger().Debug("Sending tuples")
-		for _, m := range resultMap {
-			select {
-			case consumer <- api.NewDefaultSourceTupleWithTime(m, meta, rcvTime):
-			case <-ctx.Done():
+		for i, m := range resultMap {
+			if int64(i) < skip {
+				continue
+			}
+			if !fs.send(ctx, consumer, api.NewDefaultSourceTupleWithTime(m, meta, rcvTime), fileName, int64(i)+1) {
 				return nil
 			}
 			if fs.config.SendInterval > 0 {
@@ -305,6 +397,7 @@ func (fs *FileSource) publish(ctx api.StreamContext, file io.Reader, consumer ch
 			}
 			ctx.GetLogger().Debugf("Got header %v", cols)
 		}
+		line := int64(0)
 		for {
 			record, err := r.Read()
 			if err == io.EOF {
@@ -314,6 +407,10 @@ func (fs *FileSource) publish(ctx api.StreamContext, file io.Reader, consumer ch
 				ctx.GetLogger().Warnf("Read file %s encounter error: %v", fs.file, err)
 				continue
 			}
+			line++
+			if line <= skip {
+				continue
+			}
 			ctx.GetLogger().Debugf("Read" + strings.Join(record, ","))
 			var m map[string]interface{}
 			if cols == nil {
@@ -327,9 +424,7 @@ func (fs *FileSource) publish(ctx api.StreamContext, file io.Reader, consumer ch
 					m[v] = record[i]
 				}
 			}
-			select {
-			case consumer <- api.NewDefaultSourceTupleWithTime(m, meta, rcvTime):
-			case <-ctx.Done():
+			if !fs.send(ctx, consumer, api.NewDefaultSourceTupleWithTime(m, meta, rcvTime), fileName, line) {
 				return nil
 			}
 			if fs.config.SendInterval > 0 {
@@ -340,22 +435,39 @@ func (fs *FileSource) publish(ctx api.StreamContext, file io.Reader, consumer ch
 	case LINES_TYPE:
 		scanner := bufio.NewScanner(file)
 		scanner.Split(bufio.ScanLines)
+		line := int64(0)
 		for scanner.Scan() {
+			line++
+			if line <= skip {
+				continue
+			}
 			var tuples []api.SourceTuple
 			m, err := ctx.DecodeIntoList(scanner.Bytes())
 			if err != nil {
 				tuples = []api.SourceTuple{&xsql.ErrorSourceTuple{
-					Error: fmt.Errorf("Invalid data format, cannot decode %s with error %s", scanner.Text(), err),
+					Error:   fmt.Errorf("Invalid data format, cannot decode %s with error %s", scanner.Text(), err),
+					Payload: append([]byte(nil), scanner.Bytes()...),
 				}}
 			} else {
 				for _, t := range m {
 					tuples = append(tuples, api.NewDefaultSourceTupleWithTime(t, meta, rcvTime))
 				}
 			}
-			for _, tuple := range tuples {
-				select {
-				case consumer <- tuple:
-				case <-ctx.Done():
+			for i, tuple := range tuples {
+				if _, ok := tuple.(*xsql.ErrorSourceTuple); ok {
+					select {
+					case consumer <- tuple:
+					case <-ctx.Done():
+						return nil
+					}
+					continue
+				}
+				// The line is consumed after sending the last tuple of it
+				offset := line - 1
+				if i == len(tuples)-1 {
+					offset = line
+				}
+				if !fs.send(ctx, consumer, tuple, fileName, offset) {
 					return nil
 				}
 			}
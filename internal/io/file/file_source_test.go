@@ -279,3 +279,85 @@ func TestJsonLines(t *testing.T) {
 	}
 	mock.TestSourceOpen(r, exp, t)
 }
+
+func TestJsonFolderRewind(t *testing.T) {
+	path, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc := conf.Clock.(*clock.Mock)
+	f1 := filepath.Join(path, "test", "json", "f1.json")
+	f3 := filepath.Join(path, "test", "json", "f3.json")
+	exp := []api.SourceTuple{
+		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"id": float64(5), "name": "Jane Doe", "height": 1.72}, map[string]interface{}{"file": f3}, mc.Now()),
+		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"id": float64(6), "name": "John Smith", "height": 2.22}, map[string]interface{}{"file": f3}, mc.Now()),
+	}
+	p := map[string]interface{}{
+		"path": filepath.Join(path, "test"),
+	}
+	r := &FileSource{}
+	err = r.Configure("json", p)
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	// f1 and f2 are skipped and the first record of f3 is skipped
+	err = r.Rewind(map[string]interface{}{"file": f3, "line": int64(1)})
+	assert.NoError(t, err)
+	result, err := mock.RunMockSource(r, len(exp))
+	assert.NoError(t, err)
+	for i, v := range result {
+		assert.Equal(t, exp[i].Message(), v.Message())
+		ot, ok := v.(api.OffsetSourceTuple)
+		assert.True(t, ok)
+		assert.Equal(t, map[string]interface{}{"file": f3, "line": int64(i + 2)}, ot.Offset())
+	}
+	// wait for the offset update after sending
+	time.Sleep(10 * time.Millisecond)
+	offset, err := r.GetOffset()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"file": f3, "line": int64(3)}, offset)
+
+	// Rewind to the middle of the first file with the offset decoded from the checkpoint
+	exp = []api.SourceTuple{
+		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"id": float64(2), "name": "Jane Doe", "height": 1.65}, map[string]interface{}{"file": f1}, mc.Now()),
+		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"id": float64(3), "name": "Will Doe", "height": 1.76}, map[string]interface{}{"file": filepath.Join(path, "test", "json", "f2.json")}, mc.Now()),
+	}
+	r = &FileSource{}
+	err = r.Configure("json", p)
+	assert.NoError(t, err)
+	err = r.Rewind(map[string]interface{}{"file": f1, "line": 1})
+	assert.NoError(t, err)
+	mock.TestSourceOpen(r, exp, t)
+}
+
+func TestJsonLinesRewind(t *testing.T) {
+	path, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := map[string]interface{}{
+		"file": filepath.Join(path, "test", "test.lines"),
+	}
+	mc := conf.Clock.(*clock.Mock)
+	exp := []api.SourceTuple{
+		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"id": float64(4), "name": "John Smith"}, meta, mc.Now()),
+		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"id": float64(5), "name": "John Smith"}, meta, mc.Now()),
+	}
+	p := map[string]interface{}{
+		"path":     filepath.Join(path, "test"),
+		"fileType": "lines",
+	}
+	r := &FileSource{}
+	err = r.Configure("test.lines", p)
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	err = r.Rewind(map[string]interface{}{"file": filepath.Join(path, "test", "test.lines"), "line": int64(3)})
+	assert.NoError(t, err)
+	mock.TestSourceOpen(r, exp, t)
+
+	err = r.Rewind("invalid")
+	assert.EqualError(t, err, "invalid file source offset invalid")
+}
@@ -28,6 +28,7 @@ import (
 	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
 	"github.com/lf-edge/ekuiper/internal/pkg/cert"
 	"github.com/lf-edge/ekuiper/internal/pkg/httpx"
+	"github.com/lf-edge/ekuiper/internal/topo/connection/clients"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/cast"
 )
@@ -42,6 +43,8 @@ type ClientConf struct {
 
 	tokens map[string]interface{}
 	client *http.Client
+	// conn is set if the client is shared by connectionSelector
+	conn clients.Connection
 }
 
 type RawConf struct {
@@ -63,8 +66,11 @@ type RawConf struct {
 	Interval    int    `json:"interval"`
 	Incremental bool   `json:"incremental"`
 	ResendUrl   string `json:"resendDestination"`
+	CursorField string `json:"cursorField"`
 	// sink specific properties
 	SendSingle bool `json:"sendSingle"`
+	// Use the shared http client in connection.yaml instead of the tls and timeout properties
+	ConnectionSelector string `json:"connectionSelector"`
 	// inferred properties
 	HeadersTemplate string
 	HeadersMap      map[string]string
@@ -219,13 +225,22 @@ func (cc *ClientConf) InitConf(device string, props map[string]interface{}, with
 		}
 	}
 
-	tr := &http.Transport{
-		TLSClientConfig: tlscfg,
-	}
+	if c.ConnectionSelector != "" {
+		conn, err := clients.GetConnection("http", map[string]interface{}{"connectionSelector": c.ConnectionSelector})
+		if err != nil {
+			return err
+		}
+		cc.conn = conn
+		cc.client = conn.(*connection).client
+	} else {
+		tr := &http.Transport{
+			TLSClientConfig: tlscfg,
+		}
 
-	cc.client = &http.Client{
-		Transport: tr,
-		Timeout:   time.Duration(c.Timeout) * time.Millisecond,
+		cc.client = &http.Client{
+			Transport: tr,
+			Timeout:   time.Duration(c.Timeout) * time.Millisecond,
+		}
 	}
 	cc.config = c
 
@@ -247,6 +262,25 @@ func (cc *ClientConf) InitConf(device string, props map[string]interface{}, with
 	return nil
 }
 
+// release returns the shared http client if any
+func (cc *ClientConf) release(ctx api.StreamContext) error {
+	if cc == nil || cc.conn == nil {
+		return nil
+	}
+	err := clients.ReleaseConnection(ctx, cc.conn)
+	cc.conn = nil
+	return err
+}
+
+// send sends the request by the http client and reports the connectivity of the shared client if any
+func (cc *ClientConf) send(ctx api.StreamContext, bodyType string, method string, u string, headers map[string]string, sendSingle bool, v interface{}) (*http.Response, error) {
+	resp, err := httpx.Send(ctx.GetLogger(), cc.client, bodyType, method, u, headers, sendSingle, v)
+	if cc.conn != nil {
+		clients.ReportConnectionError(cc.conn, err)
+	}
+	return resp, err
+}
+
 // initialize the oAuth access token
 func (cc *ClientConf) auth(ctx api.StreamContext) error {
 	if resp, e := httpx.Send(conf.Log, cc.client, "json", http.MethodPost, cc.accessConf.Url, nil, true, cc.accessConf.Body); e == nil {
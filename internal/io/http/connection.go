@@ -0,0 +1,100 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/pkg/cert"
+	"github.com/lf-edge/ekuiper/internal/topo/connection/clients"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+func init() {
+	clients.RegisterConnectionFactory("http", newConnection)
+}
+
+// connectionConf is the transport part of the http client conf which can be shared
+type connectionConf struct {
+	// Url is only used to check the connectivity
+	Url                string `json:"url"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+	CertificationPath  string `json:"certificationPath"`
+	PrivateKeyPath     string `json:"privateKeyPath"`
+	RootCaPath         string `json:"rootCaPath"`
+	Timeout            int    `json:"timeout"`
+}
+
+// connection is the http client shared by the httppull sources and rest sinks with the same connectionSelector so
+// that they reuse the idle connections of the same transport
+type connection struct {
+	url    string
+	client *http.Client
+}
+
+func newConnection(props map[string]interface{}) (clients.Connection, error) {
+	c := &connectionConf{
+		InsecureSkipVerify: true,
+		Timeout:            DefaultTimeout,
+	}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return nil, fmt.Errorf("fail to parse the properties: %v", err)
+	}
+	if c.Timeout < 0 {
+		return nil, fmt.Errorf("timeout must be greater than or equal to 0")
+	}
+	tlscfg, err := cert.GenerateTLSForClient(cert.TlsConfigurationOptions{
+		SkipCertVerify: c.InsecureSkipVerify,
+		CertFile:       c.CertificationPath,
+		KeyFile:        c.PrivateKeyPath,
+		CaFile:         c.RootCaPath,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &connection{
+		url: c.Url,
+		client: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: tlscfg,
+			},
+			Timeout: time.Duration(c.Timeout) * time.Millisecond,
+		},
+	}, nil
+}
+
+// Ping sends a HEAD request to the url if set. Any response means the server is reachable.
+func (c *connection) Ping(ctx api.StreamContext) error {
+	if c.url == "" {
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (c *connection) Close(_ api.StreamContext) error {
+	c.client.CloseIdleConnections()
+	return nil
+}
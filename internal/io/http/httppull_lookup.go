@@ -18,7 +18,6 @@ import (
 	"time"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
-	"github.com/lf-edge/ekuiper/internal/pkg/httpx"
 	"github.com/lf-edge/ekuiper/pkg/api"
 )
 
@@ -57,10 +56,26 @@ func (l *lookupSource) Lookup(ctx api.StreamContext, _ []string, keys []string,
 	return results, nil
 }
 
+// LookupBatch pulls once and matches the response for each lookup values
+func (l *lookupSource) LookupBatch(ctx api.StreamContext, _ []string, keys []string, values [][]interface{}) ([][]api.SourceTuple, error) {
+	resps, err := l.pull(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([][]api.SourceTuple, len(values))
+	meta := make(map[string]interface{})
+	for i, v := range values {
+		for _, resp := range l.lookupJoin(resps, keys, v) {
+			result[i] = append(result[i], api.NewDefaultSourceTupleWithTime(resp, meta, conf.GetNow()))
+		}
+	}
+	return result, nil
+}
+
 func (l *lookupSource) Close(ctx api.StreamContext) error {
 	logger := ctx.GetLogger()
 	logger.Infof("Closing HTTP pull lookup table")
-	return nil
+	return l.release(ctx)
 }
 
 func (l *lookupSource) lookupJoin(dataMap []map[string]interface{}, keys []string, values []interface{}) []map[string]interface{} {
@@ -94,7 +109,7 @@ func (l *lookupSource) pull(ctx api.StreamContext) ([]map[string]interface{}, er
 		return nil, err
 	}
 	ctx.GetLogger().Debugf("httppull source sending request url: %s, headers: %v, body %s", l.config.Url, headers, l.config.Body)
-	resp, e := httpx.Send(ctx.GetLogger(), l.client, l.config.BodyType, l.config.Method, l.config.Url, headers, true, l.config.Body)
+	resp, e := l.send(ctx, l.config.BodyType, l.config.Method, l.config.Url, headers, true, l.config.Body)
 	if e != nil {
 		ctx.GetLogger().Warnf("Found error %s when trying to reach %v ", e, l)
 		return nil, err
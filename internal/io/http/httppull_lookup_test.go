@@ -339,6 +339,14 @@ func TestLookup(t *testing.T) {
 	tuples, err := r.Lookup(context.Background(), nil, []string{"code"}, []interface{}{float64(200)})
 	require.NoError(t, err)
 	require.Len(t, tuples, 2)
+	batch, err := r.LookupBatch(context.Background(), nil, []string{"code"}, [][]interface{}{{float64(200)}, {float64(404)}})
+	require.NoError(t, err)
+	require.Len(t, batch, 2)
+	require.Len(t, batch[0], 2)
+	for i, tuple := range tuples {
+		require.Equal(t, tuple.Message(), batch[0][i].Message())
+	}
+	require.Len(t, batch[1], 0)
 }
 
 func TestLookupActions(t *testing.T) {
@@ -16,24 +16,32 @@ package http
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/io"
-	"github.com/lf-edge/ekuiper/internal/pkg/httpx"
+	"github.com/lf-edge/ekuiper/internal/pkg/tracer"
+	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
 	"github.com/lf-edge/ekuiper/internal/xsql"
 	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
 )
 
 type pullTimeMeta struct {
 	LastPullTime int64 `json:"lastPullTime"`
 	PullTime     int64 `json:"pullTime"`
+	// Cursor is the value of the cursorField in the last record of the last successful response
+	Cursor interface{} `json:"cursor"`
 }
 
 type PullSource struct {
 	ClientConf
 
-	t *pullTimeMeta
+	// mu guards t and md5 which are the offset of the source
+	mu  sync.Mutex
+	t   *pullTimeMeta
+	md5 string
 }
 
 func (hps *PullSource) Configure(device string, props map[string]interface{}) error {
@@ -49,7 +57,7 @@ func (hps *PullSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTup
 func (hps *PullSource) Close(ctx api.StreamContext) error {
 	logger := ctx.GetLogger()
 	logger.Infof("Closing HTTP pull source")
-	return nil
+	return hps.release(ctx)
 }
 
 func (hps *PullSource) initTimerPull(ctx api.StreamContext, consumer chan<- api.SourceTuple, _ chan<- error) {
@@ -57,18 +65,17 @@ func (hps *PullSource) initTimerPull(ctx api.StreamContext, consumer chan<- api.
 	logger.Infof("Starting HTTP pull source with interval %d", hps.config.Interval)
 	ticker := conf.GetTicker(int64(hps.config.Interval))
 	defer ticker.Stop()
-	omd5 := ""
 
 	// Pulling data at initial start
 	logger.Debugf("Pulling data at initial start")
-	tuples := hps.doPull(ctx, conf.GetNow(), &omd5)
+	tuples := hps.doPull(ctx, conf.GetNow())
 	io.ReceiveTuples(ctx, consumer, tuples)
 
 	for {
 		select {
 		case rcvTime := <-ticker.C:
 			logger.Debugf("Pulling data at %d", rcvTime.UnixMilli())
-			tuples := hps.doPull(ctx, rcvTime, &omd5)
+			tuples := hps.doPull(ctx, rcvTime)
 			io.ReceiveTuples(ctx, consumer, tuples)
 		case <-ctx.Done():
 			return
@@ -76,7 +83,48 @@ func (hps *PullSource) initTimerPull(ctx api.StreamContext, consumer chan<- api.
 	}
 }
 
-func (hps *PullSource) doPull(ctx api.StreamContext, rcvTime time.Time, omd5 *string) []api.SourceTuple {
+// GetOffset returns the last pull time, the cursor and the md5 of the last response
+func (hps *PullSource) GetOffset() (interface{}, error) {
+	hps.mu.Lock()
+	defer hps.mu.Unlock()
+	return hps.offset(), nil
+}
+
+// Rewind restores the last pull time and the cursor so that the url and body templates continue from the offset
+func (hps *PullSource) Rewind(offset interface{}) error {
+	m, ok := offset.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid httppull source offset %v", offset)
+	}
+	lastPullTime, err := cast.ToInt64(m["lastPullTime"], cast.CONVERT_SAMEKIND)
+	if err != nil {
+		return fmt.Errorf("invalid httppull source offset %v: %v", offset, err)
+	}
+	md5, _ := m["md5"].(string)
+	hps.mu.Lock()
+	defer hps.mu.Unlock()
+	hps.t = &pullTimeMeta{
+		LastPullTime: lastPullTime,
+		PullTime:     lastPullTime,
+		Cursor:       m["cursor"],
+	}
+	hps.md5 = md5
+	return nil
+}
+
+func (hps *PullSource) offset() map[string]interface{} {
+	if hps.t == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"lastPullTime": hps.t.LastPullTime,
+		"cursor":       hps.t.Cursor,
+		"md5":          hps.md5,
+	}
+}
+
+func (hps *PullSource) doPull(ctx api.StreamContext, rcvTime time.Time) []api.SourceTuple {
+	hps.mu.Lock()
 	if hps.t == nil {
 		hps.t = &pullTimeMeta{
 			LastPullTime: rcvTime.UnixMilli() - int64(hps.config.Interval),
@@ -86,6 +134,9 @@ func (hps *PullSource) doPull(ctx api.StreamContext, rcvTime time.Time, omd5 *st
 		// only update last pull time when there is no error
 		hps.t.PullTime = rcvTime.UnixMilli()
 	}
+	lastOffset := hps.offset()
+	md5 := hps.md5
+	hps.mu.Unlock()
 	// Parse url which may contain dynamic time range
 	url, err := ctx.ParseTemplate(hps.config.Url, hps.t)
 	if err != nil {
@@ -121,8 +172,10 @@ func (hps *PullSource) doPull(ctx api.StreamContext, rcvTime time.Time, omd5 *st
 		}
 	}
 	ctx.GetLogger().Debugf("httppull source sending request url: %s, headers: %v, body %s", url, headers, hps.config.Body)
-	if resp, e := httpx.Send(ctx.GetLogger(), hps.client, hps.config.BodyType, hps.config.Method, url, headers, true, body); e != nil {
+	if resp, e := hps.send(ctx, hps.config.BodyType, hps.config.Method, url, headers, true, body); e != nil {
 		ctx.GetLogger().Warnf("Found error %s when trying to reach %v ", e, hps)
+		// pull again in the next interval
+		metric.ReportConnectionStatus(ctx, metric.ConnectionReconnecting, e)
 		return []api.SourceTuple{
 			&xsql.ErrorSourceTuple{
 				Error: fmt.Errorf("send request error %v", e),
@@ -130,7 +183,8 @@ func (hps *PullSource) doPull(ctx api.StreamContext, rcvTime time.Time, omd5 *st
 		}
 	} else {
 		ctx.GetLogger().Debugf("httppull source got response %v", resp)
-		results, _, e := hps.parseResponse(ctx, resp, true, omd5)
+		metric.ReportConnectionStatus(ctx, metric.ConnectionConnected, nil)
+		results, _, e := hps.parseResponse(ctx, resp, true, &md5)
 		if e != nil {
 			return []api.SourceTuple{
 				&xsql.ErrorSourceTuple{
@@ -138,15 +192,33 @@ func (hps *PullSource) doPull(ctx api.StreamContext, rcvTime time.Time, omd5 *st
 				},
 			}
 		}
+		hps.mu.Lock()
 		hps.t.LastPullTime = hps.t.PullTime
+		hps.md5 = md5
+		if hps.config.CursorField != "" && len(results) > 0 {
+			if c, ok := results[len(results)-1][hps.config.CursorField]; ok {
+				hps.t.Cursor = c
+			}
+		}
+		newOffset := hps.offset()
+		hps.mu.Unlock()
 		if results == nil {
 			ctx.GetLogger().Debugf("no data to send for incremental")
 			return nil
 		}
 		tuples := make([]api.SourceTuple, len(results))
 		meta := make(map[string]interface{})
+		// Continue the trace of the response if any
+		for k, v := range tracer.HeaderMeta(resp.Header) {
+			meta[k] = v
+		}
 		for i, result := range results {
-			tuples[i] = api.NewDefaultSourceTupleWithTime(result, meta, rcvTime)
+			// The response is consumed only after the last tuple is processed
+			offset := lastOffset
+			if i == len(results)-1 {
+				offset = newOffset
+			}
+			tuples[i] = api.NewOffsetSourceTuple(api.NewDefaultSourceTupleWithTime(result, meta, rcvTime), offset)
 		}
 		return tuples
 	}
@@ -257,6 +257,16 @@ func mockAuthServer() *httptest.Server {
 		jsonOut(w, out)
 	}).Methods(http.MethodPost)
 
+	// data6 returns the next 2 records after the cursor in url
+	router.HandleFunc("/data6", func(w http.ResponseWriter, r *http.Request) {
+		after, _ := strconv.ParseInt(r.URL.Query().Get("after"), 10, 64)
+		out := []map[string]interface{}{
+			{"id": after + 1},
+			{"id": after + 2},
+		}
+		jsonOut(w, out)
+	}).Methods(http.MethodGet)
+
 	server := httptest.NewUnstartedServer(router)
 	err := server.Listener.Close()
 	if err != nil {
@@ -1105,3 +1115,43 @@ func TestPullErrorTest(t *testing.T) {
 		})
 	}
 }
+
+func TestPullCursorRewind(t *testing.T) {
+	conf.IsTesting = false
+	conf.InitClock()
+	r := &PullSource{}
+	server := mockAuthServer()
+	server.Start()
+	defer server.Close()
+	err := r.Configure("", map[string]interface{}{
+		"url":         "http://localhost:52345/data6?after={{.Cursor}}",
+		"interval":    100,
+		"cursorField": "id",
+	})
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	err = r.Rewind(map[string]interface{}{"lastPullTime": int64(100), "cursor": int64(2), "md5": ""})
+	assert.NoError(t, err)
+	result, err := mock.RunMockSource(r, 4)
+	assert.NoError(t, err)
+	var (
+		ids     []interface{}
+		cursors []interface{}
+	)
+	for _, v := range result {
+		ids = append(ids, v.Message()["id"])
+		ot, ok := v.(api.OffsetSourceTuple)
+		if !assert.True(t, ok, "%v", v) {
+			return
+		}
+		cursors = append(cursors, ot.Offset().(map[string]interface{})["cursor"])
+	}
+	assert.Equal(t, []interface{}{float64(3), float64(4), float64(5), float64(6)}, ids)
+	// The offset only moves forward after the last tuple of a response
+	assert.Equal(t, []interface{}{int64(2), float64(4), float64(4), float64(6)}, cursors)
+
+	err = r.Rewind("invalid")
+	assert.EqualError(t, err, "invalid httppull source offset invalid")
+}
@@ -21,7 +21,7 @@ import (
 	"time"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
-	"github.com/lf-edge/ekuiper/internal/pkg/httpx"
+	"github.com/lf-edge/ekuiper/internal/pkg/tracer"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/errorx"
 )
@@ -132,11 +132,15 @@ func (ms *RestSink) sendWithUrl(ctx api.StreamContext, decodedData []byte, v int
 	if err != nil {
 		return nil, fmt.Errorf("rest sink headers template decode error: %v", err)
 	}
-	return httpx.Send(ctx.GetLogger(), ms.client, bodyType, method, u, headers, ms.config.SendSingle, decodedData)
+	// Propagate the trace context of the data to the downstream service
+	for k, hv := range tracer.InjectMap(ctx) {
+		headers[k] = hv
+	}
+	return ms.send(ctx, bodyType, method, u, headers, ms.config.SendSingle, decodedData)
 }
 
 func (ms *RestSink) Close(ctx api.StreamContext) error {
 	logger := ctx.GetLogger()
 	logger.Infof("Closing rest sink")
-	return nil
+	return ms.release(ctx)
 }
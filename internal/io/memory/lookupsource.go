@@ -64,6 +64,19 @@ func (s *lookupsource) Lookup(ctx api.StreamContext, _ []string, keys []string,
 	return s.table.Read(keys, values)
 }
 
+func (s *lookupsource) LookupBatch(ctx api.StreamContext, _ []string, keys []string, values [][]interface{}) ([][]api.SourceTuple, error) {
+	ctx.GetLogger().Debugf("lookup source %s is looking up keys %v with %d values", s.topic, keys, len(values))
+	result := make([][]api.SourceTuple, len(values))
+	for i, v := range values {
+		r, err := s.table.Read(keys, v)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = r
+	}
+	return result, nil
+}
+
 func (s *lookupsource) Close(ctx api.StreamContext) error {
 	ctx.GetLogger().Infof("lookup source %s is closing", s.topic)
 	return store.Unreg(s.topic, s.key)
@@ -133,6 +133,19 @@ func TestLookup(t *testing.T) {
 	if !reflect.DeepEqual(result, expected) {
 		t.Errorf("expect %v but got %v", expected, result)
 	}
+	batch, err := ls.LookupBatch(ctx, []string{}, []string{"gg"}, [][]interface{}{{"value4"}, {"value6"}, {"value2"}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expectedBatch := [][]api.SourceTuple{
+		{expected[1]},
+		nil,
+		{expected[0]},
+	}
+	if !reflect.DeepEqual(batch, expectedBatch) {
+		t.Errorf("expect %v but got %v", expectedBatch, batch)
+	}
 	err = ls.Close(ctx)
 	if err != nil {
 		t.Error(err)
@@ -61,6 +61,8 @@ func TestSharedInmemoryNode(t *testing.T) {
 	go func() {
 		src.Open(ctx, consumer, errorChannel)
 	}()
+	// Wait for the source to subscribe before producing
+	time.Sleep(100 * time.Millisecond)
 
 	//if _, contains := pubTopics[id]; !contains {
 	//	t.Errorf("there should be memory node for topic")
@@ -80,7 +82,7 @@ func TestSharedInmemoryNode(t *testing.T) {
 		select {
 		case res := <-consumer:
 			mc := conf.Clock.(*clock.Mock)
-			expected := api.NewDefaultSourceTupleWithTime(data, map[string]interface{}{"topic": "test_id"}, mc.Now())
+			expected := api.NewOffsetSourceTuple(api.NewDefaultSourceTupleWithTime(data, map[string]interface{}{"topic": "test_id"}, mc.Now()), map[string]interface{}{"epoch": pubsub.Epoch(), "offsets": map[string]interface{}{"test_id": int64(1)}})
 			if !reflect.DeepEqual(expected, res) {
 				t.Errorf("result %s should be equal to %s", res, expected)
 			}
@@ -17,6 +17,7 @@ package pubsub
 import (
 	"regexp"
 	"sync"
+	"time"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/xsql"
@@ -28,19 +29,38 @@ const IdProperty = "topic"
 type pubConsumers struct {
 	count     int
 	consumers map[string]chan api.SourceTuple // The consumer channel list [sourceId]chan
+	// The consumers which receive SeqTuple
+	seqConsumers map[string]struct{}
+	// mu guards the seq and retained of the topic so that the topics can be produced concurrently under the
+	// read lock of the global mu. The consumers are only changed under the write lock of the global mu.
+	mu sync.Mutex
+	// The sequence of the last produced tuple
+	seq int64
+	// The last produced tuples retained for the seq consumers to replay
+	retainSize int
+	retained   []*SeqTuple
 }
 
 type subChan struct {
-	regex *regexp.Regexp
-	ch    chan api.SourceTuple
+	regex      *regexp.Regexp
+	ch         chan api.SourceTuple
+	seq        bool
+	retainSize int
 }
 
 var (
 	pubTopics = make(map[string]*pubConsumers)
 	subExps   = make(map[string]*subChan)
 	mu        = sync.RWMutex{}
+	// epoch identifies the sequences of this process. The sequences restart after the process restarts.
+	epoch = time.Now().UnixNano()
 )
 
+// Epoch returns the epoch of the topic sequences
+func Epoch() int64 {
+	return epoch
+}
+
 func CreatePub(topic string) {
 	mu.Lock()
 	defer mu.Unlock()
@@ -57,6 +77,9 @@ func CreatePub(topic string) {
 	for sourceId, sc := range subExps {
 		if sc.regex.MatchString(topic) {
 			addPubConsumer(topic, sourceId, sc.ch)
+			if sc.seq {
+				addSeqConsumer(topic, sourceId, sc.retainSize)
+			}
 		}
 	}
 }
@@ -81,6 +104,48 @@ func CreateSub(wildcard string, regex *regexp.Regexp, sourceId string, bufferLen
 	return ch
 }
 
+// CreateSeqSub creates a subscription which receives SeqTuple. The topics retain the last retainSize tuples so that
+// the subscription can replay the tuples after the offsets which is the map of topic to the sequence. The offsets
+// are only valid in the same epoch.
+func CreateSeqSub(wildcard string, regex *regexp.Regexp, sourceId string, bufferLength int, retainSize int, offsets map[string]int64) chan api.SourceTuple {
+	mu.Lock()
+	defer mu.Unlock()
+	// Make sure the replayed tuples won't block
+	ch := make(chan api.SourceTuple, bufferLength+retainSize)
+	var topics []string
+	if regex != nil {
+		subExps[sourceId] = &subChan{
+			regex:      regex,
+			ch:         ch,
+			seq:        true,
+			retainSize: retainSize,
+		}
+		for topic := range pubTopics {
+			if regex.MatchString(topic) {
+				topics = append(topics, topic)
+			}
+		}
+	} else {
+		topics = append(topics, wildcard)
+	}
+	for _, topic := range topics {
+		addPubConsumer(topic, sourceId, ch)
+		addSeqConsumer(topic, sourceId, retainSize)
+		if offset, ok := offsets[topic]; ok {
+			for _, t := range pubTopics[topic].retained {
+				if t.Seq > offset {
+					select {
+					case ch <- t:
+					default:
+						conf.Log.Errorf("memory source topic %s drop replayed message %d to %s", topic, t.Seq, sourceId)
+					}
+				}
+			}
+		}
+	}
+	return ch
+}
+
 func CloseSourceConsumerChannel(topic string, sourceId string) {
 	mu.Lock()
 	defer mu.Unlock()
@@ -114,6 +179,15 @@ func Produce(ctx api.StreamContext, topic string, data map[string]interface{}) {
 	doProduce(ctx, topic, api.NewDefaultSourceTupleWithTime(data, map[string]interface{}{"topic": topic}, conf.GetNow()))
 }
 
+// ProduceWithMeta produces the data with the extra metadata such as the trace context of the data
+func ProduceWithMeta(ctx api.StreamContext, topic string, data map[string]interface{}, meta map[string]interface{}) {
+	if meta == nil {
+		meta = make(map[string]interface{}, 1)
+	}
+	meta["topic"] = topic
+	doProduce(ctx, topic, api.NewDefaultSourceTupleWithTime(data, meta, conf.GetNow()))
+}
+
 func ProduceUpdatable(ctx api.StreamContext, topic string, data map[string]interface{}, rowkind string, keyval interface{}) {
 	doProduce(ctx, topic, &UpdatableTuple{
 		DefaultSourceTuple: api.NewDefaultSourceTupleWithTime(data, map[string]interface{}{"topic": topic}, conf.GetNow()),
@@ -123,17 +197,32 @@ func ProduceUpdatable(ctx api.StreamContext, topic string, data map[string]inter
 }
 
 func doProduce(ctx api.StreamContext, topic string, data api.SourceTuple) {
+	mu.RLock()
+	defer mu.RUnlock()
 	c, exists := pubTopics[topic]
 	if !exists {
 		return
 	}
+	// Lock the topic to generate the sequence and broadcast in order
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	logger := ctx.GetLogger()
-	mu.RLock()
-	defer mu.RUnlock()
+	c.seq++
+	st := &SeqTuple{SourceTuple: data, Topic: topic, Seq: c.seq}
+	if c.retainSize > 0 {
+		c.retained = append(c.retained, st)
+		if len(c.retained) > c.retainSize {
+			c.retained = c.retained[len(c.retained)-c.retainSize:]
+		}
+	}
 	// broadcast to all consumers
 	for name, out := range c.consumers {
+		var d api.SourceTuple = data
+		if _, ok := c.seqConsumers[name]; ok {
+			d = st
+		}
 		select {
-		case out <- data:
+		case out <- d:
 			logger.Debugf("memory source broadcast from topic %s to %s done", topic, name)
 		case <-ctx.Done():
 			// rule stop so stop waiting
@@ -144,13 +233,13 @@ func doProduce(ctx api.StreamContext, topic string, data api.SourceTuple) {
 }
 
 func ProduceError(ctx api.StreamContext, topic string, err error) {
+	mu.RLock()
+	defer mu.RUnlock()
 	c, exists := pubTopics[topic]
 	if !exists {
 		return
 	}
 	logger := ctx.GetLogger()
-	mu.RLock()
-	defer mu.RUnlock()
 	// broadcast to all consumers
 	for name, out := range c.consumers {
 		select {
@@ -181,10 +270,23 @@ func addPubConsumer(topic string, sourceId string, ch chan api.SourceTuple) {
 	}
 }
 
+// addSeqConsumer marks the consumer to receive SeqTuple. The topic must exist.
+func addSeqConsumer(topic string, sourceId string, retainSize int) {
+	c := pubTopics[topic]
+	if c.seqConsumers == nil {
+		c.seqConsumers = make(map[string]struct{})
+	}
+	c.seqConsumers[sourceId] = struct{}{}
+	if retainSize > c.retainSize {
+		c.retainSize = retainSize
+	}
+}
+
 func removePubConsumer(topic string, sourceId string, c *pubConsumers) {
 	if _, exists := c.consumers[sourceId]; exists {
 		delete(c.consumers, sourceId)
 	}
+	delete(c.seqConsumers, sourceId)
 	if len(c.consumers) == 0 && c.count == 0 {
 		delete(pubTopics, topic)
 	}
@@ -19,10 +19,13 @@ import (
 	"reflect"
 	"regexp"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/gdexlab/go-render/render"
+	"github.com/stretchr/testify/assert"
 
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
 	"github.com/lf-edge/ekuiper/pkg/api"
 )
 
@@ -126,3 +129,73 @@ func getRegexp(topic string) (*regexp.Regexp, error) {
 	regstr := strings.Replace(strings.ReplaceAll(topic, "+", "([^/]+)"), "#", ".", 1)
 	return regexp.Compile(regstr)
 }
+
+func TestSeqSubReplay(t *testing.T) {
+	Reset()
+	ctx := mockContext.NewMockContext("rule1", "op1")
+	CreatePub("seq/t1")
+	defer RemovePub("seq/t1")
+	c := CreateSeqSub("seq/t1", nil, "seq1", 10, 2, nil)
+	for i := 1; i <= 3; i++ {
+		Produce(ctx, "seq/t1", map[string]interface{}{"id": i})
+	}
+	for i := 1; i <= 3; i++ {
+		st, ok := (<-c).(*SeqTuple)
+		if !ok {
+			t.Fatal("expect SeqTuple")
+		}
+		assert.Equal(t, int64(i), st.Seq)
+		assert.Equal(t, "seq/t1", st.Topic)
+		assert.Equal(t, map[string]interface{}{"id": i}, st.Message())
+	}
+	CloseSourceConsumerChannel("seq/t1", "seq1")
+	// Produced when the consumer is closed
+	Produce(ctx, "seq/t1", map[string]interface{}{"id": 4})
+	// Only retain the last 2 tuples
+	c = CreateSeqSub("seq/t1", nil, "seq1", 10, 2, map[string]int64{"seq/t1": 1})
+	for _, exp := range []int64{3, 4} {
+		st := (<-c).(*SeqTuple)
+		assert.Equal(t, exp, st.Seq)
+	}
+	// The normal consumer receives the original tuple
+	n := CreateSub("seq/t1", nil, "normal", 10)
+	Produce(ctx, "seq/t1", map[string]interface{}{"id": 5})
+	assert.Equal(t, int64(5), (<-c).(*SeqTuple).Seq)
+	_, ok := (<-n).(*api.DefaultSourceTuple)
+	assert.True(t, ok)
+	CloseSourceConsumerChannel("seq/t1", "seq1")
+	CloseSourceConsumerChannel("seq/t1", "normal")
+}
+
+func TestConcurrentProduce(t *testing.T) {
+	Reset()
+	ctx := mockContext.NewMockContext("rule1", "op1")
+	topics := []string{"con/t1", "con/t2"}
+	chs := make([]chan api.SourceTuple, len(topics))
+	for i, topic := range topics {
+		CreatePub(topic)
+		chs[i] = CreateSeqSub(topic, nil, "con"+topic, 200, 10, nil)
+	}
+	var wg sync.WaitGroup
+	for _, topic := range topics {
+		for p := 0; p < 4; p++ {
+			wg.Add(1)
+			go func(topic string) {
+				defer wg.Done()
+				for i := 0; i < 50; i++ {
+					Produce(ctx, topic, map[string]interface{}{"id": i})
+				}
+			}(topic)
+		}
+	}
+	wg.Wait()
+	// The tuples of each topic are received in the order of the sequence
+	for i, topic := range topics {
+		for seq := int64(1); seq <= 200; seq++ {
+			assert.Equal(t, seq, (<-chs[i]).(*SeqTuple).Seq)
+		}
+		assert.Len(t, pubTopics[topic].retained, 10)
+		CloseSourceConsumerChannel(topic, "con"+topic)
+		RemovePub(topic)
+	}
+}
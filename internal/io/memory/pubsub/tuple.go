@@ -21,3 +21,10 @@ type UpdatableTuple struct {
 	Rowkind string
 	Keyval  interface{}
 }
+
+// SeqTuple is the tuple with the sequence in the topic. It is only sent to the consumers created by CreateSeqSub.
+type SeqTuple struct {
+	api.SourceTuple
+	Topic string
+	Seq   int64
+}
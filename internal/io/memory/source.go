@@ -18,7 +18,9 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"sync"
 
+	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/io/memory/pubsub"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/cast"
@@ -28,23 +30,100 @@ type source struct {
 	topic        string
 	topicRegex   *regexp.Regexp
 	bufferLength int
+	retainSize   int
+
+	// The offset is the sequence of the last consumed tuple for each topic in the epoch
+	mu      sync.Mutex
+	epoch   int64
+	offsets map[string]int64
+	rewound bool
 }
 
 func (s *source) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, _ chan<- error) {
-	ch := pubsub.CreateSub(s.topic, s.topicRegex, fmt.Sprintf("%s_%s_%d", ctx.GetRuleId(), ctx.GetOpId(), ctx.GetInstanceId()), s.bufferLength)
+	s.mu.Lock()
+	var offsets map[string]int64
+	if s.rewound {
+		offsets = s.offsets
+		s.rewound = false
+	} else {
+		s.offsets = make(map[string]int64)
+	}
+	s.epoch = pubsub.Epoch()
+	s.mu.Unlock()
+	ch := pubsub.CreateSeqSub(s.topic, s.topicRegex, fmt.Sprintf("%s_%s_%d", ctx.GetRuleId(), ctx.GetOpId(), ctx.GetInstanceId()), s.bufferLength, s.retainSize, offsets)
 	for {
 		select {
 		case v, opened := <-ch:
 			if !opened {
 				return
 			}
-			consumer <- v
+			if st, ok := v.(*pubsub.SeqTuple); ok {
+				v = api.NewOffsetSourceTuple(st.SourceTuple, s.consume(st.Topic, st.Seq))
+			}
+			select {
+			case consumer <- v:
+			case <-ctx.Done():
+				return
+			}
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
+// consume updates the offset of the topic and returns a copy of the offset
+func (s *source) consume(topic string, seq int64) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offsets[topic] = seq
+	return s.offset()
+}
+
+func (s *source) offset() map[string]interface{} {
+	offsets := make(map[string]interface{}, len(s.offsets))
+	for k, v := range s.offsets {
+		offsets[k] = v
+	}
+	return map[string]interface{}{"epoch": s.epoch, "offsets": offsets}
+}
+
+func (s *source) GetOffset() (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.offset(), nil
+}
+
+// Rewind replays the retained tuples after the offset. The offset of another epoch is ignored because the sequences
+// are reset after the process restarts.
+func (s *source) Rewind(offset interface{}) error {
+	m, ok := offset.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid memory source offset %v", offset)
+	}
+	e, err := cast.ToInt64(m["epoch"], cast.CONVERT_SAMEKIND)
+	if err != nil {
+		return fmt.Errorf("invalid memory source offset %v: %v", offset, err)
+	}
+	if e != pubsub.Epoch() {
+		conf.Log.Infof("memory source offset is from the last run, ignore it")
+		return nil
+	}
+	om, _ := m["offsets"].(map[string]interface{})
+	offsets := make(map[string]int64, len(om))
+	for k, v := range om {
+		seq, err := cast.ToInt64(v, cast.CONVERT_SAMEKIND)
+		if err != nil {
+			return fmt.Errorf("invalid memory source offset %v: %v", offset, err)
+		}
+		offsets[k] = seq
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offsets = offsets
+	s.rewound = true
+	return nil
+}
+
 func (s *source) Configure(datasource string, props map[string]interface{}) error {
 	s.topic = datasource
 	s.bufferLength = 1024
@@ -53,6 +132,13 @@ func (s *source) Configure(datasource string, props map[string]interface{}) erro
 			s.bufferLength = bl
 		}
 	}
+	if c, ok := props["retainSize"]; ok {
+		rs, err := cast.ToInt(c, cast.CONVERT_SAMEKIND)
+		if err != nil || rs < 0 {
+			return fmt.Errorf("invalid retainSize %v", c)
+		}
+		s.retainSize = rs
+	}
 	if strings.ContainsAny(datasource, "+#") {
 		r, err := getRegexp(datasource)
 		if err != nil {
@@ -18,8 +18,14 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lf-edge/ekuiper/internal/io/memory/pubsub"
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
 	"github.com/lf-edge/ekuiper/internal/testx"
+	"github.com/lf-edge/ekuiper/pkg/api"
 )
 
 func TestTopic(t *testing.T) {
@@ -88,3 +94,60 @@ func TestTopic(t *testing.T) {
 		}
 	}
 }
+
+func TestSourceRewind(t *testing.T) {
+	pubsub.Reset()
+	pubsub.CreatePub("rewind/t1")
+	defer pubsub.RemovePub("rewind/t1")
+	pctx := mockContext.NewMockContext("rule0", "op0")
+	// Open the source and consume n tuples
+	run := func(s *source, n int) []api.SourceTuple {
+		ctx, cancel := mockContext.NewMockContext("rule1", "op1").WithCancel()
+		consumer := make(chan api.SourceTuple)
+		go s.Open(ctx, consumer, nil)
+		// wait for the subscription
+		time.Sleep(10 * time.Millisecond)
+		var result []api.SourceTuple
+		for len(result) < n {
+			result = append(result, <-consumer)
+		}
+		cancel()
+		_ = s.Close(ctx)
+		return result
+	}
+
+	s := &source{}
+	err := s.Configure("rewind/t1", map[string]interface{}{"retainSize": 5})
+	assert.NoError(t, err)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		for i := 1; i <= 3; i++ {
+			pubsub.Produce(pctx, "rewind/t1", map[string]interface{}{"id": i})
+		}
+	}()
+	result := run(s, 3)
+	for i, v := range result {
+		assert.Equal(t, map[string]interface{}{"id": i + 1}, v.Message())
+		assert.Equal(t, map[string]interface{}{"epoch": pubsub.Epoch(), "offsets": map[string]interface{}{"rewind/t1": int64(i + 1)}}, v.(api.OffsetSourceTuple).Offset())
+	}
+	// Produced when the rule is stopped
+	pubsub.Produce(pctx, "rewind/t1", map[string]interface{}{"id": 4})
+
+	// Restart from the offset of the first tuple
+	s = &source{}
+	err = s.Configure("rewind/t1", map[string]interface{}{"retainSize": 5})
+	assert.NoError(t, err)
+	err = s.Rewind(result[0].(api.OffsetSourceTuple).Offset())
+	assert.NoError(t, err)
+	result = run(s, 3)
+	for i, v := range result {
+		assert.Equal(t, map[string]interface{}{"id": i + 2}, v.Message())
+	}
+
+	// Offset of the last run is ignored
+	err = s.Rewind(map[string]interface{}{"epoch": int64(1), "offsets": map[string]interface{}{"rewind/t1": int64(1)}})
+	assert.NoError(t, err)
+	assert.False(t, s.rewound)
+	err = s.Rewind("invalid")
+	assert.EqualError(t, err, "invalid memory source offset invalid")
+}
@@ -38,7 +38,7 @@ func TestSourceOpen(r api.Source, exp []api.SourceTuple, t *testing.T) {
 	}
 	for i, v := range result {
 		switch v.(type) {
-		case *api.DefaultSourceTuple:
+		case *api.DefaultSourceTuple, api.OffsetSourceTuple:
 			assert.Equal(t, exp[i].Message(), v.Message())
 			assert.Equal(t, exp[i].Meta(), v.Meta())
 		default:
@@ -18,6 +18,7 @@ import (
 	"fmt"
 
 	"github.com/lf-edge/ekuiper/internal/compressor"
+	"github.com/lf-edge/ekuiper/internal/pkg/tracer"
 	"github.com/lf-edge/ekuiper/internal/topo/connection/clients"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/cast"
@@ -32,6 +33,9 @@ type AdConf struct {
 	Retained    bool   `json:"retained"`
 	Compression string `json:"compression"`
 	ResendTopic string `json:"resendDestination"`
+	// TraceInPayload adds the trace context as the traceparent and tracestate fields of the payload as MQTT v3.1.1
+	// has no user properties
+	TraceInPayload bool `json:"traceInPayload"`
 }
 
 type MQTTSink struct {
@@ -99,6 +103,9 @@ func (ms *MQTTSink) CollectResend(ctx api.StreamContext, item interface{}) error
 
 func (ms *MQTTSink) collectWithTopic(ctx api.StreamContext, item interface{}, topic string) error {
 	logger := ctx.GetLogger()
+	if ms.adconf.TraceInPayload {
+		item = tracer.InjectPayload(ctx, item)
+	}
 	jsonBytes, _, err := ctx.TransformOutput(item)
 	if err != nil {
 		return err
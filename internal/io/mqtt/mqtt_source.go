@@ -24,7 +24,9 @@ import (
 	"github.com/lf-edge/ekuiper/internal/compressor"
 	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/io"
+	"github.com/lf-edge/ekuiper/internal/pkg/tracer"
 	"github.com/lf-edge/ekuiper/internal/topo/connection/clients"
+	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
 	"github.com/lf-edge/ekuiper/internal/xsql"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/cast"
@@ -36,6 +38,8 @@ type MQTTSource struct {
 	format string
 	tpc    string
 	buflen int
+	// read the trace context from the payload fields
+	traceInPayload bool
 
 	config map[string]interface{}
 	model  modelVersion
@@ -51,6 +55,7 @@ type MQTTConfig struct {
 	KubeedgeModelFile string `json:"kubeedgeModelFile"`
 	KubeedgeVersion   string `json:"kubeedgeVersion"`
 	Decompression     string `json:"decompression"`
+	TraceInPayload    bool   `json:"traceInPayload"`
 }
 
 func (ms *MQTTSource) WithSchema(_ string) *MQTTSource {
@@ -72,6 +77,7 @@ func (ms *MQTTSource) Configure(topic string, props map[string]interface{}) erro
 	ms.tpc = topic
 	ms.format = cfg.Format
 	ms.qos = cfg.Qos
+	ms.traceInPayload = cfg.TraceInPayload
 	ms.config = props
 
 	if cfg.Decompression != "" {
@@ -124,12 +130,16 @@ func subscribe(ms *MQTTSource, ctx api.StreamContext, consumer chan<- api.Source
 	} else {
 		log.Infof("Successfully subscribed to topic %s.", ms.tpc)
 		var tuples []api.SourceTuple
+		// lost is set when the connection is lost. The client reconnects automatically and resubscribes the topic
+		lost := false
 		for {
 			select {
 			case <-ctx.Done():
 				log.Infof("Exit subscription to mqtt messagebus topic %s.", ms.tpc)
 				return nil
 			case e1 := <-err:
+				metric.ReportConnectionStatus(ctx, metric.ConnectionReconnecting, e1)
+				lost = true
 				tuples = []api.SourceTuple{
 					&xsql.ErrorSourceTuple{
 						Error: fmt.Errorf("the subscription to mqtt topic %s have error %s.\n", ms.tpc, e1.Error()),
@@ -140,6 +150,10 @@ func subscribe(ms *MQTTSource, ctx api.StreamContext, consumer chan<- api.Source
 					log.Infof("Exit subscription to mqtt messagebus topic %s.", ms.tpc)
 					return nil
 				}
+				if lost {
+					metric.ReportConnectionStatus(ctx, metric.ConnectionConnected, nil)
+					lost = false
+				}
 				tuples = getTuples(ctx, ms, env)
 			}
 			io.ReceiveTuples(ctx, consumer, tuples)
@@ -174,7 +188,8 @@ func getTuples(ctx api.StreamContext, ms *MQTTSource, env interface{}) []api.Sou
 	if e != nil {
 		return []api.SourceTuple{
 			&xsql.ErrorSourceTuple{
-				Error: fmt.Errorf("Invalid data format, cannot decode %s with error %s", string(msg.Payload()), e),
+				Error:   fmt.Errorf("Invalid data format, cannot decode %s with error %s", string(msg.Payload()), e),
+				Payload: msg.Payload(),
 			},
 		}
 	}
@@ -190,7 +205,11 @@ func getTuples(ctx api.StreamContext, ms *MQTTSource, env interface{}) []api.Sou
 				ctx.GetLogger().Errorf(v)
 			}
 		}
-		tuples = append(tuples, api.NewDefaultSourceTupleWithTime(result, meta, rcvTime))
+		tmeta := meta
+		if ms.traceInPayload {
+			tmeta = tracer.ExtractPayload(result, meta)
+		}
+		tuples = append(tuples, api.NewDefaultSourceTupleWithTime(result, tmeta, rcvTime))
 	}
 	return tuples
 }
@@ -0,0 +1,79 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build redisdb || !core
+
+package redis
+
+import (
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/lf-edge/ekuiper/internal/topo/connection/clients"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+func init() {
+	clients.RegisterConnectionFactory("redis", newConnection)
+}
+
+type connectionConf struct {
+	// host:port address.
+	Addr     string `json:"addr,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Db       int    `json:"db,omitempty"`
+}
+
+// connection is the redis client shared by the redis lookup sources and sinks with the same connectionSelector
+type connection struct {
+	cli *redis.Client
+}
+
+func newConnection(props map[string]interface{}) (clients.Connection, error) {
+	c := &connectionConf{}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return nil, err
+	}
+	if c.Addr == "" {
+		return nil, errors.New("redis addr is null")
+	}
+	return &connection{
+		cli: redis.NewClient(&redis.Options{
+			Addr:     c.Addr,
+			Username: c.Username,
+			Password: c.Password,
+			DB:       c.Db,
+		}),
+	}, nil
+}
+
+func (c *connection) Ping(ctx api.StreamContext) error {
+	return c.cli.Ping(ctx).Err()
+}
+
+func (c *connection) Close(_ api.StreamContext) error {
+	return c.cli.Close()
+}
+
+// getClient gets the redis client of the connection selector in the props or creates a new one
+func getClient(props map[string]interface{}) (clients.Connection, *redis.Client, error) {
+	conn, err := clients.GetConnection("redis", props)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, conn.(*connection).cli, nil
+}
@@ -17,18 +17,21 @@
 package redis
 
 import (
-	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 
 	"github.com/redis/go-redis/v9"
 
 	cnf "github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/connection/clients"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/cast"
 )
 
+const preloadBatchSize = 1000
+
 type conf struct {
 	// host:port address.
 	Addr     string `json:"addr,omitempty"`
@@ -36,12 +39,15 @@ type conf struct {
 	// Optional password. Must match the password specified in the
 	Password string `json:"password,omitempty"`
 	DataType string `json:"dataType,omitempty"`
+	// Use the shared connection in connection.yaml. The db of the connection is used instead of the datasource.
+	ConnectionSelector string `json:"connectionSelector,omitempty"`
 }
 
 type lookupSource struct {
-	c   *conf
-	db  int
-	cli *redis.Client
+	c    *conf
+	db   int
+	conn clients.Connection
+	cli  *redis.Client
 }
 
 func (s *lookupSource) Configure(datasource string, props map[string]interface{}) error {
@@ -59,20 +65,20 @@ func (s *lookupSource) Configure(datasource string, props map[string]interface{}
 	if err != nil {
 		return err
 	}
-	if cfg.Addr == "" {
+	if cfg.Addr == "" && cfg.ConnectionSelector == "" {
 		return errors.New("redis addr is null")
 	}
 	if cfg.DataType != "string" && cfg.DataType != "list" {
 		return errors.New("redis dataType must be string or list")
 	}
 	s.c = cfg
-	s.cli = redis.NewClient(&redis.Options{
-		Addr:     s.c.Addr,
-		Username: s.c.Username,
-		Password: s.c.Password,
-		DB:       s.db,
+	s.conn, s.cli, err = getClient(map[string]interface{}{
+		"addr":               s.c.Addr,
+		"username":           s.c.Username,
+		"password":           s.c.Password,
+		"db":                 s.db,
+		"connectionSelector": s.c.ConnectionSelector,
 	})
-	_, err = s.cli.Ping(context.Background()).Result()
 	return err
 }
 
@@ -123,9 +129,115 @@ func (s *lookupSource) Lookup(ctx api.StreamContext, _ []string, keys []string,
 	}
 }
 
+// LookupBatch gets the keys by MGET for string type or by a pipeline of LRANGE for list type in one round trip
+func (s *lookupSource) LookupBatch(ctx api.StreamContext, _ []string, keys []string, values [][]interface{}) ([][]api.SourceTuple, error) {
+	rcvTime := cnf.GetNow()
+	ctx.GetLogger().Debugf("Lookup redis %v with %d values", keys, len(values))
+	if len(keys) != 1 {
+		return nil, fmt.Errorf("redis lookup only support one key, but got %v", keys)
+	}
+	rkeys := make([]string, len(values))
+	for i, v := range values {
+		rkeys[i] = fmt.Sprintf("%v", v[0])
+	}
+	result := make([][]api.SourceTuple, len(values))
+	if s.c.DataType == "string" {
+		res, err := s.cli.MGet(ctx, rkeys...).Result()
+		if err != nil {
+			return nil, err
+		}
+		for i, r := range res {
+			rs, ok := r.(string)
+			if !ok { // nil for the key not found
+				result[i] = []api.SourceTuple{}
+				continue
+			}
+			m := make(map[string]interface{})
+			err = json.Unmarshal(cast.StringToBytes(rs), &m)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = []api.SourceTuple{api.NewDefaultSourceTupleWithTime(m, nil, rcvTime)}
+		}
+		return result, nil
+	}
+	cmds := make([]*redis.StringSliceCmd, len(rkeys))
+	_, err := s.cli.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i, k := range rkeys {
+			cmds[i] = p.LRange(ctx, k, 0, -1)
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	for i, cmd := range cmds {
+		res, err := cmd.Result()
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+		ret := make([]api.SourceTuple, 0, len(res))
+		for _, r := range res {
+			m := make(map[string]interface{})
+			err = json.Unmarshal(cast.StringToBytes(r), &m)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, api.NewDefaultSourceTupleWithTime(m, nil, rcvTime))
+		}
+		result[i] = ret
+	}
+	return result, nil
+}
+
+// Preload scans all the keys of the data type in the db and reads them in batches
+func (s *lookupSource) Preload(ctx api.StreamContext, fields []string, keys []string) ([][]interface{}, [][]api.SourceTuple, error) {
+	var (
+		values  [][]interface{}
+		results [][]api.SourceTuple
+		cursor  uint64
+	)
+	// A key may be returned more than once by scan
+	seen := make(map[string]bool)
+	for {
+		rkeys, next, err := s.cli.ScanType(ctx, cursor, "*", preloadBatchSize, s.c.DataType).Result()
+		if err != nil {
+			return nil, nil, err
+		}
+		batch := make([][]interface{}, 0, len(rkeys))
+		for _, k := range rkeys {
+			if !seen[k] {
+				seen[k] = true
+				batch = append(batch, []interface{}{keyValue(k)})
+			}
+		}
+		if len(batch) > 0 {
+			rs, err := s.LookupBatch(ctx, fields, keys, batch)
+			if err != nil {
+				return nil, nil, err
+			}
+			values = append(values, batch...)
+			results = append(results, rs...)
+		}
+		if next == 0 {
+			return values, results, nil
+		}
+		cursor = next
+	}
+}
+
+// keyValue returns the integer of the key if the key is the string of an integer so that the preloaded key matches the
+// numeric lookup values. The key of other formats like "007" is kept as a string and looked up by the string value.
+func keyValue(k string) interface{} {
+	if i, err := strconv.ParseInt(k, 10, 64); err == nil && strconv.FormatInt(i, 10) == k {
+		return i
+	}
+	return k
+}
+
 func (s *lookupSource) Close(ctx api.StreamContext) error {
 	ctx.GetLogger().Infof("Closing redis lookup source")
-	return s.cli.Close()
+	return clients.ReleaseConnection(ctx, s.conn)
 }
 
 func GetLookupSource() api.LookupSource {
@@ -146,6 +146,97 @@ func TestList(t *testing.T) {
 	}
 }
 
+func TestBatch(t *testing.T) {
+	contextLogger := econf.Log.WithField("rule", "test")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	mc := econf.Clock.(*clock.Mock)
+	tests := []struct {
+		dataType string
+		values   [][]interface{}
+		result   [][]api.SourceTuple
+	}{
+		{
+			dataType: "string",
+			values:   [][]interface{}{{2}, {3}, {1}},
+			result: [][]api.SourceTuple{
+				{api.NewDefaultSourceTupleWithTime(map[string]interface{}{"id": float64(2), "name": "Susan", "address": float64(22), "mobile": "666433"}, nil, mc.Now())},
+				{},
+				{api.NewDefaultSourceTupleWithTime(map[string]interface{}{"id": float64(1), "name": "John", "address": float64(34), "mobile": "334433"}, nil, mc.Now())},
+			},
+		}, {
+			dataType: "list",
+			values:   [][]interface{}{{"group4"}, {"group1"}, {"group2"}},
+			result: [][]api.SourceTuple{
+				{},
+				{
+					api.NewDefaultSourceTupleWithTime(map[string]interface{}{"id": float64(2), "name": "Susan"}, nil, mc.Now()),
+					api.NewDefaultSourceTupleWithTime(map[string]interface{}{"id": float64(1), "name": "John"}, nil, mc.Now()),
+				},
+				{api.NewDefaultSourceTupleWithTime(map[string]interface{}{"id": float64(3), "name": "Nancy"}, nil, mc.Now())},
+			},
+		},
+	}
+	for i, tt := range tests {
+		ls := GetLookupSource()
+		err := ls.Configure("0", map[string]interface{}{"addr": addr, "datatype": tt.dataType})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		actual, err := ls.(api.BatchLookupSource).LookupBatch(ctx, []string{}, []string{"id"}, tt.values)
+		if err != nil {
+			t.Errorf("Test %d: %v", i, err)
+			continue
+		}
+		if len(actual) != len(tt.result) {
+			t.Errorf("Test %d: expected %v, actual %v", i, tt.result, actual)
+			continue
+		}
+		for j := range actual {
+			if len(actual[j]) != len(tt.result[j]) || !deepEqual(actual[j], tt.result[j]) {
+				t.Errorf("Test %d: expected %v, actual %v", i, tt.result, actual)
+			}
+		}
+	}
+}
+
+func TestPreload(t *testing.T) {
+	contextLogger := econf.Log.WithField("rule", "test")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	tests := []struct {
+		dataType string
+		result   map[interface{}]int
+	}{
+		{
+			dataType: "string",
+			result:   map[interface{}]int{int64(1): 1, int64(2): 1},
+		}, {
+			dataType: "list",
+			result:   map[interface{}]int{"group1": 2, "group2": 1, "group3": 1},
+		},
+	}
+	for i, tt := range tests {
+		ls := GetLookupSource()
+		err := ls.Configure("0", map[string]interface{}{"addr": addr, "datatype": tt.dataType})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		values, results, err := ls.(api.PreloadLookupSource).Preload(ctx, []string{}, []string{"id"})
+		if err != nil {
+			t.Errorf("Test %d: %v", i, err)
+			continue
+		}
+		actual := make(map[interface{}]int, len(values))
+		for j, v := range values {
+			actual[v[0]] = len(results[j])
+		}
+		if !reflect.DeepEqual(actual, tt.result) {
+			t.Errorf("Test %d: expected %v, actual %v", i, tt.result, actual)
+		}
+	}
+}
+
 func deepEqual(a []api.SourceTuple, b []api.SourceTuple) bool {
 	for i, val := range a {
 		if !reflect.DeepEqual(val.Message(), b[i].Message()) || !reflect.DeepEqual(val.Meta(), b[i].Meta()) {
@@ -24,6 +24,7 @@ import (
 
 	"github.com/redis/go-redis/v9"
 
+	"github.com/lf-edge/ekuiper/internal/topo/connection/clients"
 	"github.com/lf-edge/ekuiper/internal/topo/transform"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/ast"
@@ -49,11 +50,14 @@ type config struct {
 	DataTemplate string        `json:"dataTemplate"`
 	Fields       []string      `json:"fields"`
 	DataField    string        `json:"dataField"`
+	// Use the shared connection in connection.yaml instead of addr, username, password and db
+	ConnectionSelector string `json:"connectionSelector"`
 }
 
 type RedisSink struct {
-	c   *config
-	cli *redis.Client
+	c    *config
+	conn clients.Connection
+	cli  *redis.Client
 }
 
 func (r *RedisSink) Configure(props map[string]interface{}) error {
@@ -79,13 +83,13 @@ func (r *RedisSink) Open(ctx api.StreamContext) (err error) {
 	logger := ctx.GetLogger()
 	logger.Debug("Opening redis sink")
 
-	r.cli = redis.NewClient(&redis.Options{
-		Addr:     r.c.Addr,
-		Username: r.c.Username,
-		Password: r.c.Password,
-		DB:       r.c.Db, // use default DB
+	r.conn, r.cli, err = getClient(map[string]interface{}{
+		"addr":               r.c.Addr,
+		"username":           r.c.Username,
+		"password":           r.c.Password,
+		"db":                 r.c.Db,
+		"connectionSelector": r.c.ConnectionSelector,
 	})
-	_, err = r.cli.Ping(ctx).Result()
 	return err
 }
 
@@ -134,8 +138,7 @@ func (r *RedisSink) Collect(ctx api.StreamContext, data interface{}) error {
 
 func (r *RedisSink) Close(ctx api.StreamContext) error {
 	ctx.GetLogger().Infof("Closing redis sink")
-	err := r.cli.Close()
-	return err
+	return clients.ReleaseConnection(ctx, r.conn)
 }
 
 func (r *RedisSink) save(ctx api.StreamContext, data map[string]interface{}, val string) error {
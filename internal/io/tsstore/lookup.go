@@ -0,0 +1,106 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tsstore
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/kv"
+)
+
+// lookupSource looks up the rows in the range before now of the series
+type lookupSource struct {
+	series string
+	level  int64
+	rng    int64
+	db     kv.Tskv
+}
+
+func (s *lookupSource) Configure(datasource string, props map[string]interface{}) error {
+	if err := validateSeries(datasource); err != nil {
+		return err
+	}
+	c := &sourceConf{}
+	err := cast.MapToStruct(props, c)
+	if err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	s.level, s.rng, err = c.parse()
+	if err != nil {
+		return err
+	}
+	s.series = datasource
+	return nil
+}
+
+func (s *lookupSource) Open(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("Opening tsstore lookup source for series %s", s.series)
+	var err error
+	s.db, err = getSeries(s.series, s.level)
+	return err
+}
+
+func (s *lookupSource) Lookup(ctx api.StreamContext, _ []string, keys []string, values []interface{}) ([]api.SourceTuple, error) {
+	r, err := s.LookupBatch(ctx, nil, keys, [][]interface{}{values})
+	if err != nil {
+		return nil, err
+	}
+	return r[0], nil
+}
+
+// LookupBatch reads the range once for all the values
+func (s *lookupSource) LookupBatch(ctx api.StreamContext, _ []string, keys []string, values [][]interface{}) ([][]api.SourceTuple, error) {
+	ctx.GetLogger().Debugf("tsstore lookup source %s is looking up keys %v with %d values", s.series, keys, len(values))
+	var start int64
+	if s.rng > 0 {
+		start = conf.GetNowInMilli() - s.rng
+	}
+	records, err := readRange(s.db, start, math.MaxInt64)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read series %s: %v", s.series, err)
+	}
+	result := make([][]api.SourceTuple, len(values))
+	for i, v := range values {
+		for _, r := range records {
+			match := true
+			for j, k := range keys {
+				if val, ok := r.data[k]; !ok || val != v[j] {
+					match = false
+					break
+				}
+			}
+			if match {
+				result[i] = append(result[i], api.NewDefaultSourceTupleWithTime(r.data, map[string]interface{}{"timestamp": r.ts}, time.UnixMilli(r.ts)))
+			}
+		}
+	}
+	return result, nil
+}
+
+func (s *lookupSource) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("Closing tsstore lookup source for series %s", s.series)
+	return nil
+}
+
+func GetLookupSource() api.LookupSource {
+	return &lookupSource{}
+}
+
+var _ api.BatchLookupSource = &lookupSource{}
@@ -0,0 +1,60 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tsstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+func TestLookup(t *testing.T) {
+	dropSeries(t, "lookupTest")
+	mc := conf.Clock.(*clock.Mock)
+	mc.Set(time.UnixMilli(10000))
+	rows := map[int64]map[string]interface{}{
+		1000: {"id": int64(1), "temperature": 20.0},
+		6000: {"id": int64(1), "temperature": 21.0},
+		7000: {"id": int64(2), "temperature": 22.0},
+		8000: {"id": int64(1), "temperature": 23.0},
+	}
+	saveRows(t, "lookupTest", rows, 1000, 6000, 7000, 8000)
+
+	ctx := mockContext.NewMockContext("ruleTsLookup", "op1")
+	ls := GetLookupSource().(*lookupSource)
+	require.NoError(t, ls.Configure("lookupTest", map[string]interface{}{"range": "5s"}))
+	require.NoError(t, ls.Open(ctx))
+	defer ls.Close(ctx)
+	result, err := ls.Lookup(ctx, nil, []string{"id"}, []interface{}{int64(1)})
+	require.NoError(t, err)
+	assert.Equal(t, []api.SourceTuple{
+		api.NewDefaultSourceTupleWithTime(rows[6000], map[string]interface{}{"timestamp": int64(6000)}, time.UnixMilli(6000)),
+		api.NewDefaultSourceTupleWithTime(rows[8000], map[string]interface{}{"timestamp": int64(8000)}, time.UnixMilli(8000)),
+	}, result)
+
+	batch, err := ls.LookupBatch(ctx, nil, []string{"id"}, [][]interface{}{{int64(2)}, {int64(3)}})
+	require.NoError(t, err)
+	assert.Equal(t, [][]api.SourceTuple{
+		{api.NewDefaultSourceTupleWithTime(rows[7000], map[string]interface{}{"timestamp": int64(7000)}, time.UnixMilli(7000))},
+		nil,
+	}, batch)
+}
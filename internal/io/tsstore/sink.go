@@ -0,0 +1,234 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tsstore
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/transform"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/kv"
+)
+
+// The expired data is deleted at most once in the interval
+const purgeInterval int64 = 1000
+
+// errNotSaved is the error of the row which is not after the last saved one
+var errNotSaved = errors.New("data is not saved")
+
+type downsampleConf struct {
+	Interval  string `json:"interval"`
+	Retention string `json:"retention"`
+}
+
+type sinkConf struct {
+	Series string `json:"series"`
+	// The field of the timestamp in milliseconds. If not set, the time when the sink receives the data is used.
+	TsField    string            `json:"tsField"`
+	Retention  string            `json:"retention"`
+	Downsample []*downsampleConf `json:"downsample"`
+	Fields     []string          `json:"fields"`
+	DataField  string            `json:"dataField"`
+}
+
+type sink struct {
+	c         *sinkConf
+	retention int64
+	levels    []*downsampler
+	raw       kv.Tskv
+	// the timestamp of the last saved row
+	last int64
+	// the timestamp when the expired data is deleted last time
+	purged int64
+}
+
+func (s *sink) Configure(props map[string]interface{}) error {
+	c := &sinkConf{}
+	err := cast.MapToStruct(props, c)
+	if err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if err := validateSeries(c.Series); err != nil {
+		return err
+	}
+	s.retention, err = parseDuration("retention", c.Retention)
+	if err != nil {
+		return err
+	}
+	s.levels = make([]*downsampler, 0, len(c.Downsample))
+	intervals := make(map[int64]bool, len(c.Downsample))
+	for _, dc := range c.Downsample {
+		interval, err := parseDuration("downsample interval", dc.Interval)
+		if err != nil {
+			return err
+		}
+		if interval <= 0 {
+			return fmt.Errorf("downsample interval is required")
+		}
+		if intervals[interval] {
+			return fmt.Errorf("duplicate downsample interval %s", dc.Interval)
+		}
+		intervals[interval] = true
+		retention, err := parseDuration("downsample retention", dc.Retention)
+		if err != nil {
+			return err
+		}
+		s.levels = append(s.levels, newDownsampler(interval, retention, c.TsField))
+	}
+	s.c = c
+	return nil
+}
+
+func (s *sink) Open(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("Opening tsstore sink for series %s", s.c.Series)
+	var err error
+	s.raw, err = getSeries(s.c.Series, 0)
+	if err != nil {
+		return err
+	}
+	s.last, err = s.raw.Last(nil)
+	if err != nil {
+		return err
+	}
+	for _, l := range s.levels {
+		l.db, err = getSeries(s.c.Series, l.interval)
+		if err != nil {
+			return err
+		}
+		if err := l.restore(s.raw); err != nil {
+			return fmt.Errorf("fail to restore the downsampling of interval %dms: %v", l.interval, err)
+		}
+	}
+	s.purged = 0
+	return nil
+}
+
+func (s *sink) Collect(ctx api.StreamContext, item interface{}) error {
+	ctx.GetLogger().Debugf("tsstore sink receive %v", item)
+	data, _, err := transform.TransItem(item, s.c.DataField, s.c.Fields)
+	if err != nil {
+		return fmt.Errorf("fail to select fields %v for data %v", s.c.Fields, item)
+	}
+	var rows []map[string]interface{}
+	switch d := data.(type) {
+	case []map[string]interface{}:
+		rows = d
+	case map[string]interface{}:
+		return s.save(ctx, d)
+	case []interface{}:
+		rows = make([]map[string]interface{}, 0, len(d))
+		for _, el := range d {
+			m, ok := el.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("unrecognized format of %s", el)
+			}
+			rows = append(rows, m)
+		}
+	default:
+		return fmt.Errorf("unrecognized format of %s", data)
+	}
+	// The rows which are not saved because of the timestamp do not stop the others
+	var notSaved error
+	for _, row := range rows {
+		if err := s.save(ctx, row); err != nil {
+			if !errors.Is(err, errNotSaved) {
+				return err
+			}
+			if notSaved == nil {
+				notSaved = err
+			}
+		}
+	}
+	return notSaved
+}
+
+// save saves the row into the series. The timestamps in the series must be increasing, so a row whose timestamp field
+// is not after the last saved one is rejected. Without the timestamp field, the rows received in the same millisecond
+// are saved one millisecond apart.
+func (s *sink) save(ctx api.StreamContext, data map[string]interface{}) error {
+	ts := conf.GetNowInMilli()
+	if s.c.TsField == "" {
+		if ts <= s.last {
+			ts = s.last + 1
+		}
+	} else {
+		v, ok := data[s.c.TsField]
+		if !ok {
+			return fmt.Errorf("field %s does not exist in data %v", s.c.TsField, data)
+		}
+		if t, ok := v.(time.Time); ok {
+			ts = t.UnixMilli()
+		} else {
+			var err error
+			ts, err = cast.ToInt64(v, cast.CONVERT_SAMEKIND)
+			if err != nil {
+				return fmt.Errorf("field %s is not a timestamp in data %v", s.c.TsField, data)
+			}
+		}
+	}
+	if ts <= s.last {
+		return fmt.Errorf("%w to series %s: the timestamp %d is not after the last saved %d", errNotSaved, s.c.Series, ts, s.last)
+	}
+	inserted, err := s.raw.Set(ts, data)
+	if err != nil {
+		return fmt.Errorf("fail to save data to series %s: %v", s.c.Series, err)
+	}
+	if !inserted {
+		return fmt.Errorf("%w to series %s: the timestamp %d is rejected by the store", errNotSaved, s.c.Series, ts)
+	}
+	s.last = ts
+	for _, l := range s.levels {
+		if err := l.add(ts, data); err != nil {
+			return fmt.Errorf("fail to downsample series %s with interval %dms: %v", s.c.Series, l.interval, err)
+		}
+	}
+	return s.purge(ctx, ts)
+}
+
+// purge deletes the data older than the retention of the series and the downsampled series
+func (s *sink) purge(ctx api.StreamContext, now int64) error {
+	if now-s.purged < purgeInterval {
+		return nil
+	}
+	s.purged = now
+	if s.retention > 0 {
+		if err := s.raw.DeleteBefore(now - s.retention); err != nil {
+			return err
+		}
+	}
+	for _, l := range s.levels {
+		if l.retention > 0 {
+			if err := l.db.DeleteBefore(now - l.retention); err != nil {
+				return err
+			}
+		}
+	}
+	ctx.GetLogger().Debugf("tsstore sink purges the expired data of series %s at %d", s.c.Series, now)
+	return nil
+}
+
+func (s *sink) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("Closing tsstore sink for series %s", s.c.Series)
+	// The current buckets are restored from the raw series when opening again
+	return nil
+}
+
+func GetSink() api.Sink {
+	return &sink{}
+}
@@ -0,0 +1,148 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tsstore
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
+	"github.com/lf-edge/ekuiper/internal/pkg/store"
+	"github.com/lf-edge/ekuiper/internal/testx"
+)
+
+func init() {
+	testx.InitEnv()
+}
+
+// dropSeries drops the series and its downsampled series after the test
+func dropSeries(t *testing.T, series string, intervals ...int64) {
+	t.Cleanup(func() {
+		_ = store.DropTS("tsstore_" + series)
+		for _, i := range intervals {
+			_ = store.DropTS(fmt.Sprintf("tsstore_%s_%d", series, i))
+		}
+	})
+}
+
+func readAll(t *testing.T, series string, interval int64) []record {
+	db, err := getSeries(series, interval)
+	require.NoError(t, err)
+	r, err := readRange(db, 0, math.MaxInt64)
+	require.NoError(t, err)
+	return r
+}
+
+func TestSinkConfigure(t *testing.T) {
+	tests := []struct {
+		props map[string]interface{}
+		err   error
+	}{
+		{
+			props: map[string]interface{}{},
+			err:   errors.New("series name is required"),
+		},
+		{
+			props: map[string]interface{}{"series": "a;b"},
+			err:   errors.New("invalid series name a;b, only letters, digits and underscore are allowed"),
+		},
+		{
+			props: map[string]interface{}{"series": "s1", "retention": "1x"},
+			err:   errors.New("invalid retention 1x: time: unknown unit \"x\" in duration \"1x\""),
+		},
+		{
+			props: map[string]interface{}{"series": "s1", "downsample": []map[string]interface{}{{"retention": "1h"}}},
+			err:   errors.New("downsample interval is required"),
+		},
+		{
+			props: map[string]interface{}{"series": "s1", "downsample": []map[string]interface{}{{"interval": "1m"}, {"interval": "60s"}}},
+			err:   errors.New("duplicate downsample interval 60s"),
+		},
+	}
+	for i, tt := range tests {
+		err := GetSink().Configure(tt.props)
+		assert.Equal(t, tt.err, err, "case %d", i)
+	}
+	s := GetSink().(*sink)
+	require.NoError(t, s.Configure(map[string]interface{}{"series": "s1", "retention": "1h", "downsample": []map[string]interface{}{{"interval": "1m", "retention": "24h"}}}))
+	assert.Equal(t, int64(3600000), s.retention)
+	assert.Equal(t, []*downsampler{newDownsampler(60000, 86400000, "")}, s.levels)
+}
+
+func TestSinkDownsample(t *testing.T) {
+	dropSeries(t, "sinkTest", 1000)
+	ctx := mockContext.NewMockContext("ruleTs", "op1")
+	props := map[string]interface{}{
+		"series":     "sinkTest",
+		"tsField":    "ts",
+		"retention":  "10s",
+		"downsample": []map[string]interface{}{{"interval": "1s"}},
+	}
+	s := GetSink()
+	require.NoError(t, s.Configure(props))
+	require.NoError(t, s.Open(ctx))
+	require.NoError(t, s.Collect(ctx, []map[string]interface{}{
+		{"ts": int64(1000), "temperature": 20, "status": "ok"},
+		{"ts": int64(1500), "temperature": 21.5, "status": "warn"},
+		{"ts": int64(2000), "temperature": 22, "status": "ok"},
+		{"ts": int64(3000), "temperature": 23, "status": "ok"},
+	}))
+	// The row of the same time or a late row is rejected
+	assert.EqualError(t, s.Collect(ctx, map[string]interface{}{"ts": int64(3000), "temperature": 25, "status": "ok"}), "data is not saved to series sinkTest: the timestamp 3000 is not after the last saved 3000")
+	// The other rows of the batch are saved
+	assert.EqualError(t, s.Collect(ctx, []interface{}{
+		map[string]interface{}{"ts": int64(2500), "temperature": 25, "status": "ok"},
+		map[string]interface{}{"ts": int64(3200), "temperature": 25, "status": "ok"},
+	}), "data is not saved to series sinkTest: the timestamp 2500 is not after the last saved 3000")
+	require.NoError(t, s.Close(ctx))
+	assert.Equal(t, []record{
+		{ts: 1000, data: map[string]interface{}{"ts": int64(1000), "temperature": 20, "status": "ok"}},
+		{ts: 1500, data: map[string]interface{}{"ts": int64(1500), "temperature": 21.5, "status": "warn"}},
+		{ts: 2000, data: map[string]interface{}{"ts": int64(2000), "temperature": 22, "status": "ok"}},
+		{ts: 3000, data: map[string]interface{}{"ts": int64(3000), "temperature": 23, "status": "ok"}},
+		{ts: 3200, data: map[string]interface{}{"ts": int64(3200), "temperature": 25, "status": "ok"}},
+	}, readAll(t, "sinkTest", 0))
+	assert.Equal(t, []record{
+		{ts: 1000, data: map[string]interface{}{"ts": int64(1000), "temperature": 20.75, "status": "warn"}},
+		{ts: 2000, data: map[string]interface{}{"ts": int64(2000), "temperature": 22.0, "status": "ok"}},
+	}, readAll(t, "sinkTest", 1000))
+
+	// The bucket of 3000 is restored after restart
+	s = GetSink()
+	require.NoError(t, s.Configure(props))
+	require.NoError(t, s.Open(ctx))
+	require.NoError(t, s.Collect(ctx, map[string]interface{}{"ts": int64(15000), "temperature": 30, "status": "ok"}))
+	assert.Equal(t, []record{
+		{ts: 1000, data: map[string]interface{}{"ts": int64(1000), "temperature": 20.75, "status": "warn"}},
+		{ts: 2000, data: map[string]interface{}{"ts": int64(2000), "temperature": 22.0, "status": "ok"}},
+		{ts: 3000, data: map[string]interface{}{"ts": int64(3000), "temperature": 24.0, "status": "ok"}},
+	}, readAll(t, "sinkTest", 1000))
+	// The raw data before 5000 is expired
+	assert.Equal(t, []record{
+		{ts: 15000, data: map[string]interface{}{"ts": int64(15000), "temperature": 30, "status": "ok"}},
+	}, readAll(t, "sinkTest", 0))
+	require.NoError(t, s.Close(ctx))
+
+	s = GetSink()
+	require.NoError(t, s.Configure(map[string]interface{}{"series": "sinkTest", "tsField": "ts"}))
+	require.NoError(t, s.Open(ctx))
+	assert.EqualError(t, s.Collect(ctx, map[string]interface{}{"temperature": 30}), "field ts does not exist in data map[temperature:30]")
+	assert.EqualError(t, s.Collect(ctx, map[string]interface{}{"ts": "now"}), "field ts is not a timestamp in data map[ts:now]")
+}
@@ -0,0 +1,172 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tsstore
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/infra"
+	"github.com/lf-edge/ekuiper/pkg/kv"
+)
+
+type sourceConf struct {
+	// The interval of the downsampled series to read. Empty to read the raw series.
+	Downsample string `json:"downsample"`
+	// Only read the data in the range before now. Empty to read all the history.
+	Range string `json:"range"`
+	// The interval to read the new data. Empty to read only once.
+	Interval string `json:"interval"`
+}
+
+// parse returns the series interval and the range of the configuration in milliseconds
+func (c *sourceConf) parse() (int64, int64, error) {
+	level, err := parseDuration("downsample", c.Downsample)
+	if err != nil {
+		return 0, 0, err
+	}
+	r, err := parseDuration("range", c.Range)
+	if err != nil {
+		return 0, 0, err
+	}
+	return level, r, nil
+}
+
+// source reads the history in the range of the series when opening and then the new data in every interval
+type source struct {
+	series   string
+	level    int64
+	rng      int64
+	interval int64
+
+	mu sync.Mutex
+	// the timestamp of the last read row
+	offset int64
+}
+
+func (s *source) Configure(datasource string, props map[string]interface{}) error {
+	if err := validateSeries(datasource); err != nil {
+		return err
+	}
+	c := &sourceConf{}
+	err := cast.MapToStruct(props, c)
+	if err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	s.level, s.rng, err = c.parse()
+	if err != nil {
+		return err
+	}
+	s.interval, err = parseDuration("interval", c.Interval)
+	if err != nil {
+		return err
+	}
+	s.series = datasource
+	return nil
+}
+
+func (s *source) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	ctx.GetLogger().Infof("Opening tsstore source for series %s", s.series)
+	db, err := getSeries(s.series, s.level)
+	if err != nil {
+		infra.DrainError(ctx, err, errCh)
+		return
+	}
+	s.mu.Lock()
+	start := s.offset + 1
+	s.mu.Unlock()
+	if s.rng > 0 {
+		if from := conf.GetNowInMilli() - s.rng; from > start {
+			start = from
+		}
+	}
+	if start, err = s.read(ctx, db, consumer, start); err != nil {
+		infra.DrainError(ctx, err, errCh)
+		return
+	}
+	if s.interval == 0 {
+		<-ctx.Done()
+		return
+	}
+	ticker := conf.GetTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if start, err = s.read(ctx, db, consumer, start); err != nil {
+				infra.DrainError(ctx, err, errCh)
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// read sends the rows from the start and returns the start of the next read
+func (s *source) read(ctx api.StreamContext, db kv.Tskv, consumer chan<- api.SourceTuple, start int64) (int64, error) {
+	records, err := readRange(db, start, math.MaxInt64)
+	if err != nil {
+		return start, fmt.Errorf("fail to read series %s: %v", s.series, err)
+	}
+	ctx.GetLogger().Debugf("tsstore source reads %d rows from %d", len(records), start)
+	for _, r := range records {
+		tuple := api.NewOffsetSourceTuple(api.NewDefaultSourceTupleWithTime(r.data, map[string]interface{}{"timestamp": r.ts}, time.UnixMilli(r.ts)), r.ts)
+		select {
+		case consumer <- tuple:
+		case <-ctx.Done():
+			return start, nil
+		}
+		s.mu.Lock()
+		s.offset = r.ts
+		s.mu.Unlock()
+		start = r.ts + 1
+	}
+	return start, nil
+}
+
+func (s *source) GetOffset() (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.offset, nil
+}
+
+// Rewind continues to read the rows after the offset. It is called before Open.
+func (s *source) Rewind(offset interface{}) error {
+	o, err := cast.ToInt64(offset, cast.CONVERT_ALL)
+	if err != nil {
+		return fmt.Errorf("invalid offset %v", offset)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offset = o
+	return nil
+}
+
+func (s *source) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("Closing tsstore source for series %s", s.series)
+	return nil
+}
+
+func GetSource() api.Source {
+	return &source{}
+}
+
+var _ api.Rewindable = &source{}
@@ -0,0 +1,124 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tsstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+func saveRows(t *testing.T, series string, rows map[int64]map[string]interface{}, keys ...int64) {
+	db, err := getSeries(series, 0)
+	require.NoError(t, err)
+	for _, k := range keys {
+		ok, err := db.Set(k, rows[k])
+		require.NoError(t, err)
+		require.True(t, ok)
+	}
+}
+
+func receive(t *testing.T, consumer <-chan api.SourceTuple, n int) []api.SourceTuple {
+	result := make([]api.SourceTuple, 0, n)
+	for i := 0; i < n; i++ {
+		select {
+		case tuple := <-consumer:
+			result = append(result, tuple)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout to receive tuple %d", i)
+		}
+	}
+	return result
+}
+
+func TestSourceConfigure(t *testing.T) {
+	s := GetSource()
+	assert.EqualError(t, s.Configure("", nil), "series name is required")
+	assert.EqualError(t, s.Configure("s1", map[string]interface{}{"range": "-1h"}), "invalid range -1h: must not be negative")
+	assert.EqualError(t, s.Configure("s1", map[string]interface{}{"interval": "abc"}), "invalid interval abc: time: invalid duration \"abc\"")
+	require.NoError(t, s.Configure("s1", map[string]interface{}{"downsample": "1m", "range": "1h", "interval": "1s"}))
+	ss := s.(*source)
+	assert.Equal(t, int64(60000), ss.level)
+	assert.Equal(t, int64(3600000), ss.rng)
+	assert.Equal(t, int64(1000), ss.interval)
+}
+
+func TestSource(t *testing.T) {
+	dropSeries(t, "sourceTest")
+	mc := conf.Clock.(*clock.Mock)
+	mc.Set(time.UnixMilli(10000))
+	rows := map[int64]map[string]interface{}{
+		1000:  {"temperature": 20.0},
+		6000:  {"temperature": 21.0},
+		8000:  {"temperature": 22.0},
+		12000: {"temperature": 23.0},
+	}
+	saveRows(t, "sourceTest", rows, 1000, 6000, 8000)
+
+	s := GetSource().(*source)
+	require.NoError(t, s.Configure("sourceTest", map[string]interface{}{"range": "5s", "interval": "1s"}))
+	ctx, cancel := mockContext.NewMockContext("ruleTsSource", "op1").WithCancel()
+	consumer := make(chan api.SourceTuple, 10)
+	errCh := make(chan error, 1)
+	go s.Open(ctx, consumer, errCh)
+	// Only the history in the range is read
+	result := receive(t, consumer, 2)
+	for i, ts := range []int64{6000, 8000} {
+		assert.Equal(t, rows[ts], result[i].Message())
+		assert.Equal(t, map[string]interface{}{"timestamp": ts}, result[i].Meta())
+		assert.Equal(t, ts, result[i].Timestamp().UnixMilli())
+	}
+	offset, err := s.GetOffset()
+	require.NoError(t, err)
+	assert.Equal(t, int64(8000), offset)
+
+	// The new data is read in the next interval. The ticker may not be created yet, so tick until it is read.
+	saveRows(t, "sourceTest", rows, 12000)
+	var tuple api.SourceTuple
+	for tuple == nil {
+		mc.Add(time.Second)
+		select {
+		case tuple = <-consumer:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	assert.Equal(t, rows[12000], tuple.Message())
+	cancel()
+
+	// Continue from the offset after rewinding
+	s = GetSource().(*source)
+	require.NoError(t, s.Configure("sourceTest", nil))
+	require.NoError(t, s.Rewind(int64(6000)))
+	ctx, cancel = mockContext.NewMockContext("ruleTsSource", "op1").WithCancel()
+	defer cancel()
+	go s.Open(ctx, consumer, errCh)
+	result = receive(t, consumer, 2)
+	assert.Equal(t, rows[8000], result[0].Message())
+	assert.Equal(t, rows[12000], result[1].Message())
+	select {
+	case tuple := <-consumer:
+		t.Errorf("unexpected tuple %v", tuple)
+	case err := <-errCh:
+		t.Errorf("unexpected error %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
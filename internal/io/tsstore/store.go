@@ -0,0 +1,208 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tsstore saves the time series in the built-in time series store of eKuiper, so that the rules can save
+// and query the history on the edge without an external database. Each series is a Tskv whose key is the timestamp
+// in milliseconds and the value is the row. Each downsampled series is saved in a separate Tskv.
+package tsstore
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+	"regexp"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/pkg/store"
+	"github.com/lf-edge/ekuiper/pkg/kv"
+)
+
+func init() {
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+	gob.Register(time.Time{})
+}
+
+// The series name is used in the table name of the sqlite store
+var seriesRegex = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+func validateSeries(series string) error {
+	if series == "" {
+		return fmt.Errorf("series name is required")
+	}
+	if !seriesRegex.MatchString(series) {
+		return fmt.Errorf("invalid series name %s, only letters, digits and underscore are allowed", series)
+	}
+	return nil
+}
+
+// getSeries returns the store of the series. The interval is that of the downsampled series, 0 for the raw series.
+func getSeries(series string, interval int64) (kv.Tskv, error) {
+	if interval == 0 {
+		return store.GetTS("tsstore_" + series)
+	}
+	return store.GetTS(fmt.Sprintf("tsstore_%s_%d", series, interval))
+}
+
+// parseDuration parses the duration property to milliseconds. The empty value is 0.
+func parseDuration(name string, value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %s: %v", name, value, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid %s %s: must not be negative", name, value)
+	}
+	return d.Milliseconds(), nil
+}
+
+type record struct {
+	ts   int64
+	data map[string]interface{}
+}
+
+// readRange reads the rows whose timestamp is between start and end (both inclusive) in time order
+func readRange(db kv.Tskv, start int64, end int64) ([]record, error) {
+	r, ok := db.(kv.TskvRange)
+	if !ok {
+		return nil, fmt.Errorf("the time series store %T does not support reading a range", db)
+	}
+	keys, err := r.Keys(start, end)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]record, 0, len(keys))
+	for _, k := range keys {
+		var data map[string]interface{}
+		found, err := db.Get(k, &data)
+		if err != nil {
+			return nil, err
+		}
+		// deleted by the retention
+		if !found {
+			continue
+		}
+		result = append(result, record{ts: k, data: data})
+	}
+	return result, nil
+}
+
+// downsampler aggregates the rows of the raw series into the buckets of the interval. When a row of a later bucket
+// arrives, the current bucket is saved into the downsampled series with the average of the numeric fields and the
+// last value of the other fields. The key of the saved row is the start of the bucket.
+type downsampler struct {
+	interval  int64
+	retention int64
+	// The field of the timestamp which is set to the start of the bucket instead of the average
+	tsField string
+	db      kv.Tskv
+	// the start of the current bucket, -1 if it is empty
+	start int64
+	count map[string]int64
+	sum   map[string]float64
+	last  map[string]interface{}
+}
+
+func newDownsampler(interval int64, retention int64, tsField string) *downsampler {
+	return &downsampler{
+		interval:  interval,
+		retention: retention,
+		tsField:   tsField,
+		start:     -1,
+	}
+}
+
+// restore rebuilds the current bucket from the raw series after the last saved bucket, so that no state is needed
+// to continue the downsampling after restart. If the series has never been downsampled, the whole history is.
+func (d *downsampler) restore(raw kv.Tskv) error {
+	last, err := d.db.Last(nil)
+	if err != nil {
+		return err
+	}
+	var from int64
+	if last > 0 {
+		from = last + d.interval
+	}
+	records, err := readRange(raw, from, math.MaxInt64)
+	if err != nil {
+		return err
+	}
+	d.start = -1
+	for _, r := range records {
+		if err := d.add(r.ts, r.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *downsampler) add(ts int64, data map[string]interface{}) error {
+	start := ts - ts%d.interval
+	if d.start >= 0 && start != d.start {
+		if err := d.flush(); err != nil {
+			return err
+		}
+	}
+	if d.start < 0 {
+		d.start = start
+		d.count = make(map[string]int64)
+		d.sum = make(map[string]float64)
+		d.last = make(map[string]interface{})
+	}
+	for k, v := range data {
+		if k == d.tsField {
+			continue
+		}
+		var f float64
+		switch n := v.(type) {
+		case int:
+			f = float64(n)
+		case int32:
+			f = float64(n)
+		case int64:
+			f = float64(n)
+		case float32:
+			f = float64(n)
+		case float64:
+			f = n
+		default:
+			d.last[k] = v
+			continue
+		}
+		d.sum[k] += f
+		d.count[k]++
+	}
+	return nil
+}
+
+func (d *downsampler) flush() error {
+	row := make(map[string]interface{}, len(d.sum)+len(d.last)+1)
+	for k, v := range d.last {
+		row[k] = v
+	}
+	for k, s := range d.sum {
+		row[k] = s / float64(d.count[k])
+	}
+	if d.tsField != "" {
+		row[d.tsField] = d.start
+	}
+	if _, err := d.db.Set(d.start, row); err != nil {
+		return err
+	}
+	d.start = -1
+	return nil
+}
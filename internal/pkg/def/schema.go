@@ -19,9 +19,11 @@ type SchemaType string
 const (
 	PROTOBUF SchemaType = "protobuf"
 	CUSTOM   SchemaType = "custom"
+	AVRO     SchemaType = "avro"
 )
 
 var SchemaTypes = []SchemaType{
 	PROTOBUF,
 	CUSTOM,
+	AVRO,
 }
@@ -12,6 +12,10 @@ const ExpireTimeMinutes = 10
 
 type Token struct {
 	jwt.StandardClaims
+	// Role is the role of the token owner. Empty role is treated as admin to be compatible with the legacy tokens
+	Role string `json:"role,omitempty"`
+	// Scopes limits the permissions of the role if set, e.g. rule:read or rule:write:rule1
+	Scopes []string `json:"scopes,omitempty"`
 }
 
 type ErrorType int8
@@ -56,6 +60,23 @@ func CreateToken(signKeyName, issuer, aud string) (string, error) {
 	return token.SignedString(signKey)
 }
 
+// CreateScopedToken creates a token with the id, subject, role and scopes claims which can be revoked by the id
+func CreateScopedToken(signKeyName, issuer, aud, id, subject, role string, scopes []string, expireAt time.Time) (string, error) {
+	tk := &Token{Role: role, Scopes: scopes}
+	tk.Id = id
+	tk.Subject = subject
+	tk.Issuer = issuer
+	tk.Audience = aud
+	tk.IssuedAt = time.Now().Unix()
+	tk.ExpiresAt = expireAt.Unix()
+	token := jwt.NewWithClaims(jwt.GetSigningMethod("RS256"), tk)
+	signKey, err := GetPrivateKeyWithKeyName(signKeyName)
+	if err != nil {
+		return "", err
+	}
+	return token.SignedString(signKey)
+}
+
 func ParseToken(th string) (*Token, error) {
 	tk := &Token{}
 	token, err := jwt.ParseWithClaims(th, tk, func(token *jwt.Token) (interface{}, error) {
@@ -0,0 +1,191 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package namespace scopes the definitions like streams, rules, conf keys and schemas to tenants.
+// The definitions of a namespace are saved with the qualified name like tenant1@demo. The definitions
+// of the default namespace keep the plain name to be compatible with the existing data.
+package namespace
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lf-edge/ekuiper/pkg/ast"
+	"github.com/lf-edge/ekuiper/pkg/kv"
+)
+
+const (
+	Default   = "default"
+	Separator = "@"
+)
+
+var nsRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Validate checks the namespace name which can only contain letters, digits, underscore and hyphen
+func Validate(ns string) error {
+	if !nsRegex.MatchString(ns) {
+		return fmt.Errorf("invalid namespace %s, only letters, digits, underscore and hyphen are allowed", ns)
+	}
+	return nil
+}
+
+func isDefault(ns string) bool {
+	return ns == "" || ns == Default
+}
+
+// Qualify returns the qualified name of the name in the namespace. It is idempotent, so a name already qualified
+// by the same namespace is returned as it is. The names of the default namespace are not qualified.
+func Qualify(ns, name string) string {
+	if isDefault(ns) || strings.HasPrefix(name, ns+Separator) {
+		return name
+	}
+	return ns + Separator + name
+}
+
+// Split returns the namespace and the plain name of a qualified name
+func Split(qualified string) (string, string) {
+	if i := strings.Index(qualified, Separator); i > 0 {
+		return qualified[:i], qualified[i+1:]
+	}
+	return Default, qualified
+}
+
+// Of returns the namespace of a qualified name like the rule id
+func Of(qualified string) string {
+	ns, _ := Split(qualified)
+	return ns
+}
+
+// Name returns the plain name of a qualified name
+func Name(qualified string) string {
+	_, name := Split(qualified)
+	return name
+}
+
+// Filter returns the plain names of the qualified names which belong to the namespace
+func Filter(ns string, qualified []string) []string {
+	if ns == "" {
+		return qualified
+	}
+	result := make([]string, 0, len(qualified))
+	for _, q := range qualified {
+		n, name := Split(q)
+		if n == ns || (isDefault(n) && isDefault(ns)) {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// QualifyOptions qualifies the conf key and the schema of the stream options which are also scoped to the namespace
+func QualifyOptions(ns string, options *ast.Options) {
+	if isDefault(ns) || options == nil {
+		return
+	}
+	if options.CONF_KEY != "" {
+		options.CONF_KEY = Qualify(ns, options.CONF_KEY)
+	}
+	if options.SCHEMAID != "" {
+		options.SCHEMAID = Qualify(ns, options.SCHEMAID)
+	}
+}
+
+// KV returns a view of the kv store which only contains the keys of the namespace. The keys of the view are the plain
+// names. If the namespace is empty, the store itself is returned which contains the qualified keys of all namespaces.
+func KV(store kv.KeyValue, ns string) kv.KeyValue {
+	if ns == "" {
+		return store
+	}
+	return &nsKV{KeyValue: store, ns: ns}
+}
+
+// OfKV returns the namespace of the kv view or the default namespace if it is not a view
+func OfKV(store kv.KeyValue) string {
+	if s, ok := store.(*nsKV); ok {
+		return s.ns
+	}
+	return Default
+}
+
+type nsKV struct {
+	kv.KeyValue
+	ns string
+}
+
+func (s *nsKV) Setnx(key string, value interface{}) error {
+	return s.KeyValue.Setnx(Qualify(s.ns, key), value)
+}
+
+func (s *nsKV) Set(key string, value interface{}) error {
+	return s.KeyValue.Set(Qualify(s.ns, key), value)
+}
+
+func (s *nsKV) Get(key string, val interface{}) (bool, error) {
+	return s.KeyValue.Get(Qualify(s.ns, key), val)
+}
+
+func (s *nsKV) GetKeyedState(key string) (interface{}, error) {
+	return s.KeyValue.GetKeyedState(Qualify(s.ns, key))
+}
+
+func (s *nsKV) SetKeyedState(key string, value interface{}) error {
+	return s.KeyValue.SetKeyedState(Qualify(s.ns, key), value)
+}
+
+func (s *nsKV) Delete(key string) error {
+	return s.KeyValue.Delete(Qualify(s.ns, key))
+}
+
+func (s *nsKV) Keys() ([]string, error) {
+	keys, err := s.KeyValue.Keys()
+	if err != nil {
+		return nil, err
+	}
+	return Filter(s.ns, keys), nil
+}
+
+func (s *nsKV) All() (map[string]string, error) {
+	all, err := s.KeyValue.All()
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]string)
+	for k, v := range all {
+		ns, name := Split(k)
+		if ns == s.ns || (isDefault(ns) && isDefault(s.ns)) {
+			result[name] = v
+		}
+	}
+	return result, nil
+}
+
+// Clean only removes the keys of the namespace
+func (s *nsKV) Clean() error {
+	keys, err := s.Keys()
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := s.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Drop only removes the keys of the namespace instead of the whole store
+func (s *nsKV) Drop() error {
+	return s.Clean()
+}
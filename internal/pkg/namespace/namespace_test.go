@@ -0,0 +1,106 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package namespace
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/pkg/store"
+	"github.com/lf-edge/ekuiper/internal/testx"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestQualify(t *testing.T) {
+	tests := []struct {
+		ns        string
+		name      string
+		qualified string
+	}{
+		{"", "demo", "demo"},
+		{Default, "demo", "demo"},
+		{"tenant1", "demo", "tenant1@demo"},
+		{"tenant1", "tenant1@demo", "tenant1@demo"},
+		{"tenant1", "tenant2@demo", "tenant1@tenant2@demo"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.qualified, Qualify(tt.ns, tt.name), "%s %s", tt.ns, tt.name)
+	}
+	ns, name := Split("tenant1@demo")
+	assert.Equal(t, "tenant1", ns)
+	assert.Equal(t, "demo", name)
+	assert.Equal(t, Default, Of("demo"))
+	assert.Equal(t, "demo", Name("demo"))
+
+	assert.NoError(t, Validate("tenant_1-a"))
+	assert.EqualError(t, Validate("tenant@1"), "invalid namespace tenant@1, only letters, digits, underscore and hyphen are allowed")
+
+	opts := &ast.Options{CONF_KEY: "demo", SCHEMAID: "schema1.Msg"}
+	QualifyOptions("tenant1", opts)
+	assert.Equal(t, &ast.Options{CONF_KEY: "tenant1@demo", SCHEMAID: "tenant1@schema1.Msg"}, opts)
+	opts = &ast.Options{CONF_KEY: "demo"}
+	QualifyOptions(Default, opts)
+	assert.Equal(t, "demo", opts.CONF_KEY)
+}
+
+func TestKV(t *testing.T) {
+	testx.InitEnv()
+	db, err := store.GetKV("namespaceTest")
+	require.NoError(t, err)
+	require.NoError(t, db.Clean())
+	t1 := KV(db, "tenant1")
+	t2 := KV(db, "tenant2")
+	def := KV(db, Default)
+	require.NoError(t, def.Setnx("demo", "default"))
+	require.NoError(t, t1.Setnx("demo", "tenant1"))
+	require.NoError(t, t2.Setnx("demo", "tenant2"))
+	require.NoError(t, t1.Setnx("other", "tenant1"))
+
+	var v string
+	found, err := t1.Get("demo", &v)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "tenant1", v)
+	found, _ = t2.Get("other", &v)
+	assert.False(t, found)
+
+	keys, err := t1.Keys()
+	require.NoError(t, err)
+	sort.Strings(keys)
+	assert.Equal(t, []string{"demo", "other"}, keys)
+	keys, err = def.Keys()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"demo"}, keys)
+	// The root store has the qualified keys of all namespaces
+	keys, err = KV(db, "").Keys()
+	require.NoError(t, err)
+	sort.Strings(keys)
+	assert.Equal(t, []string{"demo", "tenant1@demo", "tenant1@other", "tenant2@demo"}, keys)
+
+	all, err := t2.All()
+	require.NoError(t, err)
+	assert.Len(t, all, 1)
+	assert.Equal(t, "tenant1", OfKV(t1))
+	assert.Equal(t, Default, OfKV(db))
+
+	require.NoError(t, t1.Drop())
+	keys, err = db.Keys()
+	require.NoError(t, err)
+	sort.Strings(keys)
+	assert.Equal(t, []string{"demo", "tenant2@demo"}, keys)
+}
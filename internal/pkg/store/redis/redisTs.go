@@ -96,6 +96,18 @@ func (t *ts) Last(value interface{}) (int64, error) {
 	return getLast(t.db, t.key, value)
 }
 
+func (t *ts) Keys(start int64, end int64) ([]int64, error) {
+	reply, err := t.db.ZRangeByScoreWithScores(context.Background(), t.key, &redis.ZRangeBy{Min: strconv.FormatInt(start, 10), Max: strconv.FormatInt(end, 10)}).Result()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]int64, len(reply))
+	for i, z := range reply {
+		keys[i] = int64(z.Score)
+	}
+	return keys, nil
+}
+
 func (t *ts) Delete(key int64) error {
 	return t.db.ZRemRangeByScore(context.Background(), t.key, strconv.FormatInt(key, 10), strconv.FormatInt(key, 10)).Err()
 }
@@ -47,6 +47,13 @@ func TestRedisTsGet(t *testing.T) {
 	common.TestTsGet(ks, t)
 }
 
+func TestRedisTsKeys(t *testing.T) {
+	ks, db, minRedis := setupTRedisKv()
+	defer cleanRedisKv(db, minRedis)
+
+	common.TestTsKeys(ks, t)
+}
+
 func TestRedisTsDelete(t *testing.T) {
 	ks, db, minRedis := setupTRedisKv()
 	defer cleanRedisKv(db, minRedis)
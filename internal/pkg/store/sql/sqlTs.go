@@ -74,7 +74,7 @@ func (t *ts) Set(key int64, value interface{}) (bool, error) {
 	return true, nil
 }
 
-func (t ts) Get(key int64, value interface{}) (bool, error) {
+func (t *ts) Get(key int64, value interface{}) (bool, error) {
 	result := false
 	err := t.database.Apply(func(db *sql.DB) error {
 		query := fmt.Sprintf("SELECT val FROM %s WHERE key=%d;", t.table, key)
@@ -101,7 +101,7 @@ func (t ts) Get(key int64, value interface{}) (bool, error) {
 	return result, nil
 }
 
-func (t ts) Last(value interface{}) (int64, error) {
+func (t *ts) Last(value interface{}) (int64, error) {
 	_, err := t.Get(t.last, value)
 	if err != nil {
 		return 0, err
@@ -109,7 +109,31 @@ func (t ts) Last(value interface{}) (int64, error) {
 	return t.last, nil
 }
 
-func (t ts) Delete(key int64) error {
+func (t *ts) Keys(start int64, end int64) ([]int64, error) {
+	var keys []int64
+	err := t.database.Apply(func(db *sql.DB) error {
+		query := fmt.Sprintf("SELECT key FROM %s WHERE key>=%d AND key<=%d ORDER BY key;", t.table, start, end)
+		rows, err := db.Query(query)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var k int64
+			if err := rows.Scan(&k); err != nil {
+				return err
+			}
+			keys = append(keys, k)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (t *ts) Delete(key int64) error {
 	return t.database.Apply(func(db *sql.DB) error {
 		query := fmt.Sprintf("DELETE FROM %s WHERE key=%d;", t.table, key)
 		_, err := db.Exec(query)
@@ -117,7 +141,7 @@ func (t ts) Delete(key int64) error {
 	})
 }
 
-func (t ts) DeleteBefore(key int64) error {
+func (t *ts) DeleteBefore(key int64) error {
 	return t.database.Apply(func(db *sql.DB) error {
 		query := fmt.Sprintf("DELETE FROM %s WHERE key<%d;", t.table, key)
 		_, err := db.Exec(query)
@@ -125,11 +149,11 @@ func (t ts) DeleteBefore(key int64) error {
 	})
 }
 
-func (t ts) Close() error {
+func (t *ts) Close() error {
 	return nil
 }
 
-func (t ts) Drop() error {
+func (t *ts) Drop() error {
 	return t.database.Apply(func(db *sql.DB) error {
 		query := fmt.Sprintf("Drop table %s;", t.table)
 		_, err := db.Exec(query)
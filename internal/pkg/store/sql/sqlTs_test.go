@@ -52,6 +52,13 @@ func TestSqlTsGet(t *testing.T) {
 	common.TestTsGet(ks, t)
 }
 
+func TestSqlTsKeys(t *testing.T) {
+	ks, db, abs := setupTSqlKv()
+	defer cleanTSqlKv(db, abs)
+
+	common.TestTsKeys(ks, t)
+}
+
 func TestSqlTsDelete(t *testing.T) {
 	ks, db, abs := setupTSqlKv()
 	defer cleanTSqlKv(db, abs)
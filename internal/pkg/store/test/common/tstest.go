@@ -60,6 +60,25 @@ func TestTsGet(ks kv.Tskv, t *testing.T) {
 	}
 }
 
+func TestTsKeys(ks kv.Tskv, t *testing.T) {
+	load(ks, t)
+
+	r, ok := ks.(kv.TskvRange)
+	if !ok {
+		t.Fatalf("%T does not implement kv.TskvRange", ks)
+	}
+	if keys, err := r.Keys(1500, 3000); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual([]int64{1500, 2000, 3000}, keys) {
+		t.Errorf("Keys expect [1500 2000 3000] but got %v", keys)
+	}
+	if keys, err := r.Keys(3001, 4000); err != nil {
+		t.Error(err)
+	} else if len(keys) != 0 {
+		t.Errorf("Keys expect empty but got %v", keys)
+	}
+}
+
 func TestTsDelete(ks kv.Tskv, t *testing.T) {
 	load(ks, t)
 
@@ -0,0 +1,194 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+// spanKey is the key of the span in the context of a sink to propagate the trace context to the external system
+const spanKey = "$$span"
+
+// metaCarrier reads and writes the trace context in the metadata of a source tuple
+type metaCarrier map[string]interface{}
+
+func (m metaCarrier) Get(key string) string {
+	if v, ok := m[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+func (m metaCarrier) Set(key string, value string) {
+	m[key] = value
+}
+
+func (m metaCarrier) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// metaValuer is implemented by the rows and collections to read the metadata
+type metaValuer interface {
+	Meta(key, table string) (interface{}, bool)
+}
+
+// valuerCarrier reads the trace context from the metadata of the data flowing in the rule
+type valuerCarrier struct {
+	metaValuer
+}
+
+func (v valuerCarrier) Get(key string) string {
+	if r, ok := v.Meta(key, ""); ok {
+		if s, ok := r.(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+func (v valuerCarrier) Set(_ string, _ string) {
+	// read only
+}
+
+func (v valuerCarrier) Keys() []string {
+	return []string{TraceParent, TraceState}
+}
+
+// HeaderMeta returns the trace context in the http headers as metadata. It returns nil if no trace context is found.
+func HeaderMeta(header http.Header) map[string]interface{} {
+	if !IsEnabled() || header.Get(TraceParent) == "" {
+		return nil
+	}
+	result := map[string]interface{}{TraceParent: header.Get(TraceParent)}
+	if ts := header.Get(TraceState); ts != "" {
+		result[TraceState] = ts
+	}
+	return result
+}
+
+// spanStreamContext carries the span of the data being sent by a sink
+type spanStreamContext struct {
+	api.StreamContext
+	span trace.Span
+}
+
+func (c *spanStreamContext) Value(key interface{}) interface{} {
+	if key == spanKey {
+		return c.span
+	}
+	return c.StreamContext.Value(key)
+}
+
+// WithSpan returns a context for the sink to send the data of the span. The context is not changed if the span is nil.
+func WithSpan(ctx api.StreamContext, span trace.Span) api.StreamContext {
+	if span == nil {
+		return ctx
+	}
+	return &spanStreamContext{StreamContext: ctx, span: span}
+}
+
+// Inject writes the trace context of the data being sent to the carrier such as the headers of the outgoing message.
+// It returns false if the data is not traced.
+func Inject(ctx api.StreamContext, carrier propagation.TextMapCarrier) bool {
+	if !IsEnabled() {
+		return false
+	}
+	span, ok := ctx.Value(spanKey).(trace.Span)
+	if !ok || !span.SpanContext().IsValid() {
+		return false
+	}
+	propagator.Inject(trace.ContextWithSpan(context.Background(), span), carrier)
+	return true
+}
+
+// InjectMap returns the trace context of the data being sent as a map of headers. It returns nil if the data is not
+// traced.
+func InjectMap(ctx api.StreamContext) map[string]string {
+	if !IsEnabled() {
+		return nil
+	}
+	m := make(propagation.MapCarrier)
+	if !Inject(ctx, m) {
+		return nil
+	}
+	return m
+}
+
+// InjectPayload adds the trace context of the data being sent as fields of the payload for the protocols without
+// headers such as MQTT v3.1.1. The data may be shared by the sinks, so the map or the maps in the slice are copied. It
+// returns the original data if the data is not traced.
+func InjectPayload(ctx api.StreamContext, data interface{}) interface{} {
+	tc := InjectMap(ctx)
+	if tc == nil {
+		return data
+	}
+	switch d := data.(type) {
+	case map[string]interface{}:
+		return injectFields(d, tc)
+	case []map[string]interface{}:
+		result := make([]map[string]interface{}, len(d))
+		for i, m := range d {
+			result[i] = injectFields(m, tc)
+		}
+		return result
+	default:
+		return data
+	}
+}
+
+func injectFields(m map[string]interface{}, tc map[string]string) map[string]interface{} {
+	result := make(map[string]interface{}, len(m)+len(tc))
+	for k, v := range m {
+		result[k] = v
+	}
+	for k, v := range tc {
+		result[k] = v
+	}
+	return result
+}
+
+// ExtractPayload moves the trace context fields in the decoded payload to a copy of the metadata so that the source
+// span continues the trace. The fields are deleted from the payload. It returns the original metadata if the payload
+// does not carry a trace context.
+func ExtractPayload(data map[string]interface{}, meta map[string]interface{}) map[string]interface{} {
+	if !IsEnabled() {
+		return meta
+	}
+	tp, ok := data[TraceParent].(string)
+	if !ok {
+		return meta
+	}
+	result := make(map[string]interface{}, len(meta)+2)
+	for k, v := range meta {
+		result[k] = v
+	}
+	result[TraceParent] = tp
+	delete(data, TraceParent)
+	if ts, ok := data[TraceState].(string); ok {
+		result[TraceState] = ts
+		delete(data, TraceState)
+	}
+	return result
+}
@@ -0,0 +1,155 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracer traces the messages through the rules by OpenTelemetry. The trace context of a message is carried
+// by the metadata of the tuple in the W3C trace context format so that it is propagated to all the downstream nodes.
+// All the functions do nothing if the tracing is not enabled.
+package tracer
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+const (
+	// TraceParent is the metadata key and header of the W3C trace context
+	TraceParent = "traceparent"
+	// TraceState is the metadata key and header of the W3C trace state
+	TraceState = "tracestate"
+
+	instrumentationName = "github.com/lf-edge/ekuiper"
+)
+
+var (
+	enabled    atomic.Bool
+	provider   *sdktrace.TracerProvider
+	tracer     = trace.NewNoopTracerProvider().Tracer(instrumentationName)
+	propagator = propagation.TraceContext{}
+)
+
+// Init sets up the exporter to send the spans to the OTLP collector. It must be called before any rule starts.
+func Init(c *conf.TracingConf) error {
+	if c == nil || !c.Enable {
+		return nil
+	}
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(c.Endpoint)}
+	if c.UrlPath != "" {
+		opts = append(opts, otlptracehttp.WithURLPath(c.UrlPath))
+	}
+	if c.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if len(c.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(c.Headers))
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return err
+	}
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		// Follow the sampling decision of the upstream if the message carries a trace context
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(c.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", c.ServiceName))),
+	)
+	tracer = provider.Tracer(instrumentationName)
+	enabled.Store(true)
+	conf.Log.Infof("tracing is enabled to export to %s", c.Endpoint)
+	return nil
+}
+
+// Shutdown flushes the pending spans and stops the tracing
+func Shutdown() error {
+	if !enabled.Load() {
+		return nil
+	}
+	enabled.Store(false)
+	tracer = trace.NewNoopTracerProvider().Tracer(instrumentationName)
+	return provider.Shutdown(context.Background())
+}
+
+// IsEnabled checks if the tracing is enabled. Check it before preparing anything for tracing to avoid the overhead.
+func IsEnabled() bool {
+	return enabled.Load()
+}
+
+// StartSourceSpan starts the span of a message received by a source. The span continues the trace of the trace
+// context in the metadata if any. If the span is sampled, the returned metadata is a copy of the original one with the
+// trace context of the span so that the downstream nodes create the child spans. The original metadata may be shared
+// by the rules, so it is never changed.
+func StartSourceSpan(ctx api.StreamContext, meta map[string]interface{}) (trace.Span, map[string]interface{}) {
+	if !IsEnabled() {
+		return nil, meta
+	}
+	parent := propagator.Extract(context.Background(), metaCarrier(meta))
+	spanCtx, span := tracer.Start(parent, ctx.GetOpId(), trace.WithSpanKind(trace.SpanKindConsumer), trace.WithAttributes(attributes(ctx)...))
+	if !span.SpanContext().IsSampled() {
+		return span, meta
+	}
+	result := make(map[string]interface{}, len(meta)+2)
+	for k, v := range meta {
+		result[k] = v
+	}
+	propagator.Inject(spanCtx, metaCarrier(result))
+	return span, result
+}
+
+// StartSpan starts a child span of the trace carried by the metadata of the data. It returns nil if the data does not
+// carry a trace context.
+func StartSpan(ctx api.StreamContext, data interface{}, kind trace.SpanKind) trace.Span {
+	if !IsEnabled() {
+		return nil
+	}
+	m, ok := data.(metaValuer)
+	if !ok {
+		return nil
+	}
+	parent := propagator.Extract(context.Background(), valuerCarrier{m})
+	if !trace.SpanContextFromContext(parent).IsValid() {
+		return nil
+	}
+	_, span := tracer.Start(parent, ctx.GetOpId(), trace.WithSpanKind(kind), trace.WithAttributes(attributes(ctx)...))
+	return span
+}
+
+// EndSpan ends the span and records the error if any. It does nothing for a nil span.
+func EndSpan(span trace.Span, err error) {
+	if span == nil {
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func attributes(ctx api.StreamContext) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("rule", ctx.GetRuleId()),
+		attribute.String("op", ctx.GetOpId()),
+		attribute.Int("instance", ctx.GetInstanceId()),
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+// collectorStub is an in-process OTLP/HTTP collector which records the received spans
+type collectorStub struct {
+	sync.Mutex
+	spans []*tracepb.Span
+}
+
+func (c *collectorStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	req := &coltracepb.ExportTraceServiceRequest{}
+	if err := proto.Unmarshal(body, req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.Lock()
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+	c.Unlock()
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *collectorStub) byName() map[string]*tracepb.Span {
+	c.Lock()
+	defer c.Unlock()
+	result := make(map[string]*tracepb.Span, len(c.spans))
+	for _, s := range c.spans {
+		result[s.Name] = s
+	}
+	return result
+}
+
+func newContext(t *testing.T, opId string) api.StreamContext {
+	store, err := state.CreateStore("rule1", api.AtMostOnce)
+	require.NoError(t, err)
+	return context.Background().WithMeta("rule1", opId, store)
+}
+
+func TestDisabled(t *testing.T) {
+	require.False(t, IsEnabled())
+	ctx := newContext(t, "source")
+	meta := map[string]interface{}{"topic": "demo"}
+	span, result := StartSourceSpan(ctx, meta)
+	assert.Nil(t, span)
+	assert.Equal(t, meta, result)
+	assert.Nil(t, StartSpan(ctx, &xsql.Tuple{Metadata: meta}, trace.SpanKindInternal))
+	assert.Equal(t, ctx, WithSpan(ctx, nil))
+	assert.Nil(t, InjectMap(ctx))
+	data := map[string]interface{}{"a": 1}
+	assert.Equal(t, data, InjectPayload(ctx, data))
+	assert.Equal(t, meta, ExtractPayload(map[string]interface{}{TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, meta))
+	EndSpan(nil, nil)
+	assert.NoError(t, Shutdown())
+}
+
+func TestTracing(t *testing.T) {
+	stub := &collectorStub{}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	c := &conf.TracingConf{
+		Enable:      true,
+		Endpoint:    strings.TrimPrefix(server.URL, "http://"),
+		Insecure:    true,
+		SampleRatio: 0,
+	}
+	require.NoError(t, c.Validate())
+	require.NoError(t, Init(c))
+	require.True(t, IsEnabled())
+
+	// Not sampled without upstream trace context as the ratio is 0
+	ctx := newContext(t, "source")
+	span, meta := StartSourceSpan(ctx, map[string]interface{}{"topic": "demo"})
+	assert.NotContains(t, meta, TraceParent)
+	EndSpan(span, nil)
+
+	// Continue the upstream trace
+	const (
+		traceId  = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parentId = "00f067aa0ba902b7"
+	)
+	incoming := map[string]interface{}{"topic": "demo", TraceParent: "00-" + traceId + "-" + parentId + "-01"}
+	span, meta = StartSourceSpan(ctx, incoming)
+	require.NotNil(t, span)
+	// the original metadata is not changed
+	assert.Equal(t, "00-"+traceId+"-"+parentId+"-01", incoming[TraceParent])
+	assert.Equal(t, "demo", meta["topic"])
+	sourceSpanId := span.SpanContext().SpanID().String()
+	assert.Equal(t, "00-"+traceId+"-"+sourceSpanId+"-01", meta[TraceParent])
+	EndSpan(span, nil)
+
+	tuple := &xsql.Tuple{Message: map[string]interface{}{"a": 1}, Metadata: meta}
+	opSpan := StartSpan(newContext(t, "op_filter"), tuple, trace.SpanKindInternal)
+	require.NotNil(t, opSpan)
+	EndSpan(opSpan, errors.New("filter error"))
+
+	sinkCtx := newContext(t, "sink_rest")
+	sinkSpan := StartSpan(sinkCtx, tuple, trace.SpanKindProducer)
+	require.NotNil(t, sinkSpan)
+	assert.Nil(t, InjectMap(sinkCtx))
+	headers := InjectMap(WithSpan(sinkCtx, sinkSpan))
+	assert.Equal(t, "00-"+traceId+"-"+sinkSpan.SpanContext().SpanID().String()+"-01", headers[TraceParent])
+	// The trace context is added to a copy of the payload
+	payload := []map[string]interface{}{{"a": 1}}
+	injected := InjectPayload(WithSpan(sinkCtx, sinkSpan), payload).([]map[string]interface{})
+	assert.Equal(t, headers[TraceParent], injected[0][TraceParent])
+	assert.NotContains(t, payload[0], TraceParent)
+	// The trace context in the payload is moved to the metadata
+	received := injected[0]
+	rmeta := ExtractPayload(received, map[string]interface{}{"topic": "demo"})
+	assert.Equal(t, map[string]interface{}{"a": 1}, received)
+	assert.Equal(t, map[string]interface{}{"topic": "demo", TraceParent: headers[TraceParent]}, rmeta)
+	EndSpan(sinkSpan, nil)
+
+	// The data without trace context is not traced
+	assert.Nil(t, StartSpan(sinkCtx, &xsql.Tuple{Metadata: map[string]interface{}{}}, trace.SpanKindProducer))
+
+	// Flush the spans to the collector
+	require.NoError(t, Shutdown())
+	assert.False(t, IsEnabled())
+	spans := stub.byName()
+	require.Len(t, spans, 3)
+	for _, name := range []string{"source", "op_filter", "sink_rest"} {
+		s, ok := spans[name]
+		require.True(t, ok, name)
+		assert.Equal(t, traceId, hex.EncodeToString(s.TraceId), name)
+	}
+	assert.Equal(t, parentId, hex.EncodeToString(spans["source"].ParentSpanId))
+	assert.Equal(t, sourceSpanId, hex.EncodeToString(spans["op_filter"].ParentSpanId))
+	assert.Equal(t, sourceSpanId, hex.EncodeToString(spans["sink_rest"].ParentSpanId))
+	assert.Equal(t, tracepb.Status_STATUS_CODE_ERROR, spans["op_filter"].Status.Code)
+	assert.Equal(t, tracepb.Span_SPAN_KIND_PRODUCER, spans["sink_rest"].Kind)
+}
@@ -17,6 +17,7 @@ package runtime
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
 	kctx "github.com/lf-edge/ekuiper/internal/topo/context"
@@ -35,6 +36,8 @@ type PortableFunc struct {
 	reg        *PluginMeta // initial plugin meta, only used for initialize the function instance
 	dataCh     DataReqChannel
 	isAgg      int // 0 - not calculate yet, 1 - no, 2 - yes
+	isInc      int // 0 - not calculate yet, 1 - no, 2 - yes
+	noBatch    bool
 }
 
 func NewPortableFunc(symbolName string, reg *PluginMeta) (_ *PortableFunc, e error) {
@@ -167,6 +170,113 @@ func (f *PortableFunc) IsAggregate() bool {
 	}
 }
 
+// IsIncremental checks if the plugin function implements the incremental aggregate methods.
+// The plugin built by the old SDK replies invalid func which is regarded as not incremental.
+func (f *PortableFunc) IsIncremental() bool {
+	if f.isInc > 0 {
+		return f.isInc > 1
+	}
+	r, err := f.call("IsIncremental", nil)
+	if err != nil {
+		conf.Log.Infof("function %s is not incremental: %v", f.symbolName, err)
+		f.isInc = 1
+		return false
+	}
+	if b, ok := r.(bool); ok && b {
+		f.isInc = 2
+		return true
+	}
+	f.isInc = 1
+	return false
+}
+
+// Init and the other incremental aggregate methods pass the accumulator along with the call,
+// so that the accumulator is kept in the rule instead of in the plugin process.
+func (f *PortableFunc) Init(ctx api.FunctionContext) (interface{}, error) {
+	ctxRaw, err := encodeCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return f.call("Init", []interface{}{ctxRaw})
+}
+
+func (f *PortableFunc) Accumulate(ctx api.FunctionContext, acc interface{}, args []interface{}) (interface{}, error) {
+	ctxRaw, err := encodeCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return f.call("Accumulate", []interface{}{acc, args, ctxRaw})
+}
+
+// AccumulateBatch accumulates the rows by one call of the plugin. If the plugin is built by the SDK without the batch
+// support, the rows are accumulated one by one.
+func (f *PortableFunc) AccumulateBatch(ctx api.FunctionContext, acc interface{}, rows [][]interface{}) (interface{}, error) {
+	if !f.noBatch {
+		ctxRaw, err := encodeCtx(ctx)
+		if err != nil {
+			return nil, err
+		}
+		r, err := f.call("AccumulateBatch", []interface{}{acc, rows, ctxRaw})
+		if err == nil || !strings.Contains(err.Error(), "invalid func") {
+			return r, err
+		}
+		conf.Log.Infof("function %s does not support batch accumulation: %v", f.symbolName, err)
+		f.noBatch = true
+	}
+	var err error
+	for _, args := range rows {
+		if acc, err = f.Accumulate(ctx, acc, args); err != nil {
+			return nil, err
+		}
+	}
+	return acc, nil
+}
+
+func (f *PortableFunc) Retract(ctx api.FunctionContext, acc interface{}, args []interface{}) (interface{}, error) {
+	ctxRaw, err := encodeCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return f.call("Retract", []interface{}{acc, args, ctxRaw})
+}
+
+func (f *PortableFunc) Merge(ctx api.FunctionContext, acc1 interface{}, acc2 interface{}) (interface{}, error) {
+	ctxRaw, err := encodeCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return f.call("Merge", []interface{}{acc1, acc2, ctxRaw})
+}
+
+func (f *PortableFunc) Result(ctx api.FunctionContext, acc interface{}) (interface{}, error) {
+	ctxRaw, err := encodeCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return f.call("Result", []interface{}{acc, ctxRaw})
+}
+
+// call sends the request of the function method and returns the result
+func (f *PortableFunc) call(funcName string, arg interface{}) (interface{}, error) {
+	jsonArg, err := encode(funcName, arg)
+	if err != nil {
+		return nil, err
+	}
+	res, err := f.dataCh.Req(jsonArg)
+	if err != nil {
+		return nil, err
+	}
+	fr := &FuncReply{}
+	err = json.Unmarshal(res, fr)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal function result %s", string(res))
+	}
+	if !fr.State {
+		return nil, fmt.Errorf("%s return state is false, got %+v", funcName, fr.Result)
+	}
+	return fr.Result, nil
+}
+
 func (f *PortableFunc) Close() error {
 	return f.dataCh.Close()
 	// Symbol must be closed by instance manager
@@ -0,0 +1,168 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+// mockAvgChannel acts as the plugin side of an incremental avg function.
+// The accumulator is {"sum": float64, "count": float64} which is passed along with the requests.
+type mockAvgChannel struct {
+	incremental bool
+	batch       bool
+	reqs        []string
+}
+
+func (c *mockAvgChannel) Req(req []byte) ([]byte, error) {
+	d := &FuncData{}
+	if err := json.Unmarshal(req, d); err != nil {
+		return nil, err
+	}
+	c.reqs = append(c.reqs, d.Func)
+	var r interface{}
+	switch d.Func {
+	case "IsAggregate":
+		r = true
+	case "IsIncremental":
+		if !c.incremental {
+			return json.Marshal(FuncReply{State: false, Result: fmt.Sprintf("invalid func %s", d.Func)})
+		}
+		r = true
+	case "Init":
+		r = map[string]interface{}{"sum": 0, "count": 0}
+	case "Accumulate", "Retract":
+		args := d.Arg.([]interface{})
+		acc, ok := args[0].(map[string]interface{})
+		if !ok {
+			return json.Marshal(FuncReply{State: false, Result: "invalid accumulator"})
+		}
+		v := args[1].([]interface{})[0].(float64)
+		if d.Func == "Accumulate" {
+			r = map[string]interface{}{"sum": acc["sum"].(float64) + v, "count": acc["count"].(float64) + 1}
+		} else {
+			r = map[string]interface{}{"sum": acc["sum"].(float64) - v, "count": acc["count"].(float64) - 1}
+		}
+	case "AccumulateBatch":
+		if !c.batch {
+			return json.Marshal(FuncReply{State: false, Result: fmt.Sprintf("invalid func %s", d.Func)})
+		}
+		args := d.Arg.([]interface{})
+		acc := args[0].(map[string]interface{})
+		sum, count := acc["sum"].(float64), acc["count"].(float64)
+		for _, row := range args[1].([]interface{}) {
+			sum += row.([]interface{})[0].(float64)
+			count++
+		}
+		r = map[string]interface{}{"sum": sum, "count": count}
+	case "Merge":
+		args := d.Arg.([]interface{})
+		acc1, acc2 := args[0].(map[string]interface{}), args[1].(map[string]interface{})
+		r = map[string]interface{}{"sum": acc1["sum"].(float64) + acc2["sum"].(float64), "count": acc1["count"].(float64) + acc2["count"].(float64)}
+	case "Result":
+		acc := d.Arg.([]interface{})[0].(map[string]interface{})
+		if acc["count"].(float64) == 0 {
+			r = nil
+		} else {
+			r = acc["sum"].(float64) / acc["count"].(float64)
+		}
+	default:
+		return json.Marshal(FuncReply{State: false, Result: fmt.Sprintf("invalid func %s", d.Func)})
+	}
+	return json.Marshal(FuncReply{State: true, Result: r})
+}
+
+func (c *mockAvgChannel) Close() error {
+	return nil
+}
+
+func TestIncrementalFunc(t *testing.T) {
+	contextLogger := conf.Log.WithField("rule", "TestIncrementalFunc")
+	tempStore, _ := state.CreateStore("TestIncrementalFunc", api.AtMostOnce)
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithMeta("TestIncrementalFunc", "op1", tempStore)
+	fctx := context.NewDefaultFuncContext(ctx, 1)
+
+	ch := &mockAvgChannel{incremental: true}
+	f := &PortableFunc{symbolName: "avg", dataCh: ch}
+	var af api.AggregateFunction = f
+	assert.True(t, af.IsAggregate())
+	assert.True(t, af.IsIncremental())
+	// cached
+	assert.True(t, af.IsIncremental())
+	assert.Equal(t, []string{"IsAggregate", "IsIncremental"}, ch.reqs)
+
+	acc, err := af.Init(fctx)
+	assert.NoError(t, err)
+	for _, v := range []float64{1, 2, 6} {
+		acc, err = af.Accumulate(fctx, acc, []interface{}{v})
+		assert.NoError(t, err)
+	}
+	r, err := af.Result(fctx, acc)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(3), r)
+
+	acc, err = af.Retract(fctx, acc, []interface{}{float64(6)})
+	assert.NoError(t, err)
+	r, err = af.Result(fctx, acc)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, r)
+
+	acc2, err := af.Init(fctx)
+	assert.NoError(t, err)
+	acc2, err = af.Accumulate(fctx, acc2, []interface{}{float64(9)})
+	assert.NoError(t, err)
+	acc, err = af.Merge(fctx, acc, acc2)
+	assert.NoError(t, err)
+	r, err = af.Result(fctx, acc)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(4), r)
+
+	_, err = af.Accumulate(fctx, nil, []interface{}{float64(1)})
+	assert.Error(t, err)
+
+	// Accumulate the rows in one call, or one by one if the plugin does not support
+	rows := [][]interface{}{{float64(1)}, {float64(2)}, {float64(6)}}
+	for _, batch := range []bool{true, false} {
+		ch = &mockAvgChannel{incremental: true, batch: batch}
+		f = &PortableFunc{symbolName: "avg", dataCh: ch}
+		acc, err = f.Init(fctx)
+		assert.NoError(t, err)
+		acc, err = f.AccumulateBatch(fctx, acc, rows)
+		assert.NoError(t, err)
+		r, err = f.Result(fctx, acc)
+		assert.NoError(t, err)
+		assert.Equal(t, float64(3), r)
+		if batch {
+			assert.Equal(t, []string{"Init", "AccumulateBatch", "Result"}, ch.reqs)
+		} else {
+			assert.Equal(t, []string{"Init", "AccumulateBatch", "Accumulate", "Accumulate", "Accumulate", "Result"}, ch.reqs)
+			assert.True(t, f.noBatch)
+		}
+	}
+
+	// The plugin by the old SDK does not support the incremental methods
+	ch = &mockAvgChannel{}
+	f = &PortableFunc{symbolName: "avg", dataCh: ch}
+	assert.False(t, f.IsIncremental())
+}
@@ -18,9 +18,13 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/namespace"
 	"github.com/lf-edge/ekuiper/internal/pkg/store"
+	"github.com/lf-edge/ekuiper/internal/topo/lookup/cache"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
 	"github.com/lf-edge/ekuiper/internal/xsql"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/cast"
@@ -31,6 +35,9 @@ import (
 type RuleProcessor struct {
 	db           kv.KeyValue
 	ruleStatusDb kv.KeyValue
+	// ns is the namespace of the view. The root processor has no namespace and accepts the qualified rule ids.
+	ns   string
+	root *RuleProcessor
 }
 
 func NewRuleProcessor() *RuleProcessor {
@@ -49,6 +56,32 @@ func NewRuleProcessor() *RuleProcessor {
 	return processor
 }
 
+// WithNamespace returns the view of the namespace whose rules are named without the namespace. The rules got from
+// the view have the qualified id which is used as the rule id in runtime.
+func (p *RuleProcessor) WithNamespace(ns string) *RuleProcessor {
+	root := p
+	if p.root != nil {
+		root = p.root
+	}
+	if ns == "" {
+		return root
+	}
+	return &RuleProcessor{
+		db:           namespace.KV(root.db, ns),
+		ruleStatusDb: namespace.KV(root.ruleStatusDb, ns),
+		ns:           ns,
+		root:         root,
+	}
+}
+
+// qualify returns the runtime id of the rule
+func (p *RuleProcessor) qualify(id string) string {
+	if p.ns == "" {
+		return id
+	}
+	return namespace.Qualify(p.ns, id)
+}
+
 func (p *RuleProcessor) ExecCreateWithValidation(name, ruleJson string) (*api.Rule, error) {
 	rule, err := p.GetRuleByJson(name, ruleJson)
 	if err != nil {
@@ -99,6 +132,8 @@ func (p *RuleProcessor) ExecReplaceRuleState(name string, triggered bool) (err e
 	}
 
 	rule.Triggered = triggered
+	// The rule is saved with the id in its namespace
+	rule.Id = namespace.Name(p.qualify(name))
 	ruleJson, err := json.Marshal(rule)
 	if err != nil {
 		return fmt.Errorf("Marshal rule %s error : %s.", name, err)
@@ -128,7 +163,12 @@ func (p *RuleProcessor) GetRuleById(id string) (*api.Rule, error) {
 	if !f {
 		return nil, errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("Rule %s is not found.", id))
 	}
-	return p.GetRuleByJsonValidated(s1)
+	rule, err := p.GetRuleByJsonValidated(s1)
+	if err != nil {
+		return nil, err
+	}
+	rule.Id = p.qualify(id)
+	return rule, nil
 }
 
 func (p *RuleProcessor) getDefaultRule(name, sql string) *api.Rule {
@@ -181,12 +221,16 @@ func (p *RuleProcessor) GetRuleByJson(id, ruleJson string) (*api.Rule, error) {
 	if rule.Id == "" && id == "" {
 		return nil, fmt.Errorf("Missing rule id.")
 	}
-	if id != "" && rule.Id != "" && id != rule.Id {
+	if p.ns != "" && strings.Contains(rule.Id, namespace.Separator) && namespace.Of(rule.Id) != p.ns {
+		return nil, fmt.Errorf("Rule %s does not belong to namespace %s.", rule.Id, p.ns)
+	}
+	if id != "" && rule.Id != "" && namespace.Name(id) != namespace.Name(rule.Id) {
 		return nil, fmt.Errorf("RuleId is not consistent with rule id.")
 	}
-	if rule.Id == "" {
+	if id != "" {
 		rule.Id = id
 	}
+	rule.Id = p.qualify(rule.Id)
 	if rule.Sql != "" {
 		if rule.Graph != nil {
 			return nil, fmt.Errorf("Rule %s has both sql and graph.", rule.Id)
@@ -255,12 +299,18 @@ func (p *RuleProcessor) ExecDrop(name string) (string, error) {
 	result := fmt.Sprintf("Rule %s is dropped.", name)
 	var ruleJson string
 	if ok, _ := p.db.Get(name, &ruleJson); ok {
-		if err := cleanSinkCache(name); err != nil {
+		if err := cleanSinkCache(p.qualify(name)); err != nil {
 			result = fmt.Sprintf("%s. Clean sink cache faile: %s.", result, err)
 		}
-		if err := cleanCheckpoint(name); err != nil {
+		if err := cleanCheckpoint(p.qualify(name)); err != nil {
 			result = fmt.Sprintf("%s. Clean checkpoint cache faile: %s.", result, err)
 		}
+		if err := state.DeleteSavepoints(p.qualify(name)); err != nil {
+			result = fmt.Sprintf("%s. Clean savepoints faile: %s.", result, err)
+		}
+		if err := cache.DeleteSaved(p.qualify(name)); err != nil {
+			result = fmt.Sprintf("%s. Clean lookup cache faile: %s.", result, err)
+		}
 
 	}
 	err := p.db.Delete(name)
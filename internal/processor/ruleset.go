@@ -41,6 +41,14 @@ func NewRulesetProcessor(r *RuleProcessor, s *StreamProcessor) *RulesetProcessor
 	}
 }
 
+// WithNamespace returns the view of the namespace which exports and imports the definitions of the namespace only
+func (rs *RulesetProcessor) WithNamespace(ns string) *RulesetProcessor {
+	return &RulesetProcessor{
+		r: rs.r.WithNamespace(ns),
+		s: rs.s.WithNamespace(ns),
+	}
+}
+
 func (rs *RulesetProcessor) Export() (io.ReadSeeker, []int, error) {
 	var all Ruleset
 	allStreams, err := rs.s.GetAll()
@@ -112,7 +120,7 @@ func (rs *RulesetProcessor) Import(content []byte) ([]string, []int, error) {
 	counts := make([]int, 3)
 	// restore streams
 	for k, v := range all.Streams {
-		_, e := rs.s.ExecStreamSql(v)
+		_, e := rs.s.forKey(k).ExecStreamSql(v)
 		if e != nil {
 			conf.Log.Errorf("Fail to import stream %s(%s) with error: %v", k, v, e)
 		} else {
@@ -121,7 +129,7 @@ func (rs *RulesetProcessor) Import(content []byte) ([]string, []int, error) {
 	}
 	// restore tables
 	for k, v := range all.Tables {
-		_, e := rs.s.ExecStreamSql(v)
+		_, e := rs.s.forKey(k).ExecStreamSql(v)
 		if e != nil {
 			conf.Log.Errorf("Fail to import table %s(%s) with error: %v", k, v, e)
 		} else {
@@ -156,7 +164,7 @@ func (rs *RulesetProcessor) ImportRuleSet(all Ruleset) Ruleset {
 	counts := make([]int, 3)
 	// restore streams
 	for k, v := range all.Streams {
-		_, e := rs.s.ExecStreamSql(v)
+		_, e := rs.s.forKey(k).ExecStreamSql(v)
 		if e != nil {
 			conf.Log.Errorf("Fail to import stream %s(%s) with error: %v", k, v, e)
 			_ = rs.s.streamStatusDb.Set(k, e.Error())
@@ -167,7 +175,7 @@ func (rs *RulesetProcessor) ImportRuleSet(all Ruleset) Ruleset {
 	}
 	// restore tables
 	for k, v := range all.Tables {
-		_, e := rs.s.ExecStreamSql(v)
+		_, e := rs.s.forKey(k).ExecStreamSql(v)
 		if e != nil {
 			conf.Log.Errorf("Fail to import table %s(%s) with error: %v", k, v, e)
 			_ = rs.s.tableStatusDb.Set(k, e.Error())
@@ -24,6 +24,7 @@ import (
 	"golang.org/x/text/language"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/namespace"
 	"github.com/lf-edge/ekuiper/internal/pkg/store"
 	"github.com/lf-edge/ekuiper/internal/schema"
 	"github.com/lf-edge/ekuiper/internal/topo/lookup"
@@ -40,6 +41,9 @@ type StreamProcessor struct {
 	db             kv.KeyValue
 	streamStatusDb kv.KeyValue
 	tableStatusDb  kv.KeyValue
+	// ns is the namespace of the view. The root processor has no namespace and accepts the qualified names.
+	ns   string
+	root *StreamProcessor
 }
 
 func NewStreamProcessor() *StreamProcessor {
@@ -63,6 +67,40 @@ func NewStreamProcessor() *StreamProcessor {
 	return processor
 }
 
+// WithNamespace returns the view of the namespace whose definitions are named without the namespace
+func (p *StreamProcessor) WithNamespace(ns string) *StreamProcessor {
+	root := p
+	if p.root != nil {
+		root = p.root
+	}
+	if ns == "" {
+		return root
+	}
+	return &StreamProcessor{
+		db:             namespace.KV(root.db, ns),
+		streamStatusDb: namespace.KV(root.streamStatusDb, ns),
+		tableStatusDb:  namespace.KV(root.tableStatusDb, ns),
+		ns:             ns,
+		root:           root,
+	}
+}
+
+// qualify returns the qualified name which is used by the runtime like the lookup table instance
+func (p *StreamProcessor) qualify(name string) string {
+	if p.ns == "" {
+		return name
+	}
+	return namespace.Qualify(p.ns, name)
+}
+
+// forKey returns the view of the namespace which the key belongs to if the processor is the root
+func (p *StreamProcessor) forKey(key string) *StreamProcessor {
+	if p.ns == "" && namespace.Of(key) != namespace.Default {
+		return p.WithNamespace(namespace.Of(key))
+	}
+	return p
+}
+
 func (p *StreamProcessor) ExecStmt(statement string) (result []string, err error) {
 	parser := xsql.NewParser(strings.NewReader(statement))
 	stmt, err := xsql.Language.Parse(parser)
@@ -135,8 +173,9 @@ func (p *StreamProcessor) RecoverLookupTable() error {
 				}
 				switch s := stmt.(type) {
 				case *ast.StreamStmt:
-					log.Infof("Starting lookup table %s", s.Name)
-					e = lookup.CreateInstance(string(s.Name), s.Options.TYPE, s.Options)
+					log.Infof("Starting lookup table %s", k)
+					namespace.QualifyOptions(namespace.Of(k), s.Options)
+					e = lookup.CreateInstance(k, s.Options.TYPE, s.Options)
 					if e != nil {
 						log.Errorf("%s", e.Error())
 						return e
@@ -153,8 +192,10 @@ func (p *StreamProcessor) RecoverLookupTable() error {
 
 func (p *StreamProcessor) execSave(stmt *ast.StreamStmt, statement string, replace bool) error {
 	if stmt.StreamType == ast.TypeTable && stmt.Options.KIND == ast.StreamKindLookup {
-		log.Infof("Creating lookup table %s", stmt.Name)
-		err := lookup.CreateInstance(string(stmt.Name), stmt.Options.TYPE, stmt.Options)
+		log.Infof("Creating lookup table %s", p.qualify(string(stmt.Name)))
+		opts := *stmt.Options
+		namespace.QualifyOptions(p.ns, &opts)
+		err := lookup.CreateInstance(p.qualify(string(stmt.Name)), opts.TYPE, &opts)
 		if err != nil {
 			return err
 		}
@@ -176,6 +217,9 @@ func (p *StreamProcessor) execSave(stmt *ast.StreamStmt, statement string, repla
 }
 
 func (p *StreamProcessor) ExecReplaceStream(name string, statement string, st ast.StreamType) (string, error) {
+	if v := p.forKey(name); v != p {
+		return v.ExecReplaceStream(namespace.Name(name), statement, st)
+	}
 	parser := xsql.NewParser(strings.NewReader(statement))
 	stmt, err := xsql.Language.Parse(parser)
 	if err != nil {
@@ -187,7 +231,7 @@ func (p *StreamProcessor) ExecReplaceStream(name string, statement string, st as
 		if s.StreamType != st {
 			return "", errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("%s %s is not found", ast.StreamTypeMap[st], s.Name))
 		}
-		if string(s.Name) != name {
+		if string(s.Name) != namespace.Name(name) {
 			return "", fmt.Errorf("Replace %s fails: the sql statement must update the %s source.", name, name)
 		}
 		err = p.execSave(s, statement, true)
@@ -365,6 +409,7 @@ func (p *StreamProcessor) GetInferredSchema(name string, st ast.StreamType) (ast
 		return nil, fmt.Errorf("Describe %s fails, cannot parse the data \"%s\" to a stream statement", ast.StreamTypeMap[st], statement)
 	}
 	if stmt.Options.SCHEMAID != "" {
+		namespace.QualifyOptions(p.forKey(name).ns, stmt.Options)
 		return schema.InferFromSchemaFile(stmt.Options.FORMAT, stmt.Options.SCHEMAID)
 	}
 	return nil, nil
@@ -387,6 +432,7 @@ func (p *StreamProcessor) GetInferredJsonSchema(name string, st ast.StreamType)
 	}
 	sfs := stmt.StreamFields
 	if stmt.Options.SCHEMAID != "" {
+		namespace.QualifyOptions(p.forKey(name).ns, stmt.Options)
 		sfs, err = schema.InferFromSchemaFile(stmt.Options.FORMAT, stmt.Options.SCHEMAID)
 		if err != nil {
 			return nil, err
@@ -413,7 +459,7 @@ func (p *StreamProcessor) execDrop(stmt ast.NameNode, st ast.StreamType) (string
 
 func (p *StreamProcessor) DropStream(name string, st ast.StreamType) (string, error) {
 	if st == ast.TypeTable {
-		err := lookup.DropInstance(name)
+		err := lookup.DropInstance(p.qualify(name))
 		if err != nil {
 			return "", err
 		}
@@ -0,0 +1,193 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build schema || !core
+
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lf-edge/ekuiper/internal/pkg/def"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+	"github.com/lf-edge/ekuiper/pkg/message"
+)
+
+func init() {
+	inferes[message.FormatAvro] = InferAvro
+}
+
+// InferAvro infers the schema from the top level record of an avro schema file
+func InferAvro(schemaFile string, messageName string) (ast.StreamFields, error) {
+	ffs, err := GetSchemaFile(def.AVRO, schemaFile)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(ffs.SchemaFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read schema file %s: %s", ffs.SchemaFile, err)
+	}
+	var s interface{}
+	if err := json.Unmarshal(content, &s); err != nil {
+		return nil, fmt.Errorf("parse schema file %s failed: %s", ffs.SchemaFile, err)
+	}
+	ai := &avroInferer{named: make(map[string]map[string]interface{}), visiting: make(map[string]bool)}
+	ft, err := ai.fieldType(s, "")
+	if err != nil {
+		return nil, fmt.Errorf("parse schema file %s failed: %s", ffs.SchemaFile, err)
+	}
+	rt, ok := ft.(*ast.RecType)
+	if !ok || ai.top == "" {
+		return nil, fmt.Errorf("the avro schema %s must be a record", schemaFile)
+	}
+	if messageName != "" && ai.top != messageName && !strings.HasSuffix(ai.top, "."+messageName) {
+		return nil, fmt.Errorf("message type %s not found in schema file %s", messageName, schemaFile)
+	}
+	return rt.StreamFields, nil
+}
+
+type avroInferer struct {
+	// named is the definitions of the named types by full name
+	named map[string]map[string]interface{}
+	// visiting is the records in converting to stop the recursive types
+	visiting map[string]bool
+	// top is the full name of the top level record
+	top string
+}
+
+func (a *avroInferer) fieldType(v interface{}, ns string) (ast.FieldType, error) {
+	switch st := v.(type) {
+	case string:
+		if ft := avroPrimitiveType(st); ft != nil {
+			return ft, nil
+		}
+		fullName := st
+		if _, ok := a.named[ns+"."+st]; ok && ns != "" {
+			fullName = ns + "." + st
+		}
+		d, ok := a.named[fullName]
+		if !ok {
+			return nil, fmt.Errorf("unknown avro type %s", st)
+		}
+		return a.namedType(d, fullName)
+	case []interface{}:
+		// the nullable union is the type of its first non-null member
+		for _, m := range st {
+			if m != "null" {
+				return a.fieldType(m, ns)
+			}
+		}
+		return nil, fmt.Errorf("union must have a non-null type")
+	case map[string]interface{}:
+		tn, ok := st["type"].(string)
+		if !ok {
+			return a.fieldType(st["type"], ns)
+		}
+		switch tn {
+		case "record", "error", "enum", "fixed":
+			name, _ := st["name"].(string)
+			if namespace, ok := st["namespace"].(string); ok && namespace != "" && !strings.Contains(name, ".") {
+				name = namespace + "." + name
+			} else if ns != "" && !strings.Contains(name, ".") {
+				name = ns + "." + name
+			}
+			a.named[name] = st
+			if a.top == "" && tn != "enum" && tn != "fixed" {
+				a.top = name
+			}
+			return a.namedType(st, name)
+		case "array":
+			it, err := a.fieldType(st["items"], ns)
+			if err != nil {
+				return nil, err
+			}
+			switch t := it.(type) {
+			case *ast.BasicType:
+				return &ast.ArrayType{Type: t.Type}, nil
+			case *ast.RecType:
+				return &ast.ArrayType{Type: ast.STRUCT, FieldType: t}, nil
+			default:
+				return &ast.ArrayType{Type: ast.ARRAY, FieldType: t}, nil
+			}
+		case "map":
+			// the keys of the map are dynamic
+			return &ast.RecType{}, nil
+		default:
+			switch st["logicalType"] {
+			case "timestamp-millis", "timestamp-micros", "date":
+				return &ast.BasicType{Type: ast.DATETIME}, nil
+			case "decimal":
+				return &ast.BasicType{Type: ast.FLOAT}, nil
+			}
+			return a.fieldType(tn, ns)
+		}
+	default:
+		return nil, fmt.Errorf("invalid avro type %v", v)
+	}
+}
+
+func (a *avroInferer) namedType(d map[string]interface{}, fullName string) (ast.FieldType, error) {
+	switch d["type"] {
+	case "enum":
+		return &ast.BasicType{Type: ast.STRINGS}, nil
+	case "fixed":
+		if d["logicalType"] == "decimal" {
+			return &ast.BasicType{Type: ast.FLOAT}, nil
+		}
+		return &ast.BasicType{Type: ast.BYTEA}, nil
+	}
+	if a.visiting[fullName] {
+		return &ast.RecType{}, nil
+	}
+	a.visiting[fullName] = true
+	defer delete(a.visiting, fullName)
+	ns := ""
+	if i := strings.LastIndex(fullName, "."); i > 0 {
+		ns = fullName[:i]
+	}
+	fs, _ := d["fields"].([]interface{})
+	result := make(ast.StreamFields, 0, len(fs))
+	for _, f := range fs {
+		fm, ok := f.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid field %v of record %s", f, fullName)
+		}
+		name, _ := fm["name"].(string)
+		ft, err := a.fieldType(fm["type"], ns)
+		if err != nil {
+			return nil, fmt.Errorf("invalid type for field '%s': %v", name, err)
+		}
+		result = append(result, ast.StreamField{Name: name, FieldType: ft})
+	}
+	return &ast.RecType{StreamFields: result}, nil
+}
+
+func avroPrimitiveType(name string) ast.FieldType {
+	switch name {
+	case "boolean":
+		return &ast.BasicType{Type: ast.BOOLEAN}
+	case "int", "long":
+		return &ast.BasicType{Type: ast.BIGINT}
+	case "float", "double":
+		return &ast.BasicType{Type: ast.FLOAT}
+	case "bytes":
+		return &ast.BasicType{Type: ast.BYTEA}
+	case "string":
+		return &ast.BasicType{Type: ast.STRINGS}
+	}
+	return nil
+}
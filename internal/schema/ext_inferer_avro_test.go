@@ -0,0 +1,92 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build schema || !core
+
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/testx"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestInferAvro(t *testing.T) {
+	testx.InitEnv()
+	// Move test schema file to etc dir
+	etcDir, err := conf.GetDataLoc()
+	if err != nil {
+		t.Fatal(err)
+	}
+	etcDir = filepath.Join(etcDir, "schemas", "avro")
+	err = os.MkdirAll(etcDir, os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bytesRead, err := os.ReadFile("test/test1.avsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(etcDir, "test1.avsc"), bytesRead, 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = os.RemoveAll(etcDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	err = InitRegistry()
+	if err != nil {
+		t.Errorf("InitRegistry error: %v", err)
+		return
+	}
+	// Test infer
+	result, err := InferAvro("test1", "Person")
+	if err != nil {
+		t.Errorf("InferAvro error: %v", err)
+		return
+	}
+	address := &ast.RecType{StreamFields: []ast.StreamField{
+		{Name: "city", FieldType: &ast.BasicType{Type: ast.STRINGS}},
+		{Name: "zip", FieldType: &ast.BasicType{Type: ast.STRINGS}},
+	}}
+	expected := ast.StreamFields{
+		{Name: "name", FieldType: &ast.BasicType{Type: ast.STRINGS}},
+		{Name: "id", FieldType: &ast.BasicType{Type: ast.BIGINT}},
+		{Name: "age", FieldType: &ast.BasicType{Type: ast.BIGINT}},
+		{Name: "score", FieldType: &ast.BasicType{Type: ast.FLOAT}},
+		{Name: "tags", FieldType: &ast.ArrayType{Type: ast.STRINGS}},
+		{Name: "address", FieldType: address},
+		{Name: "contacts", FieldType: &ast.ArrayType{Type: ast.STRUCT, FieldType: address}},
+		{Name: "attrs", FieldType: &ast.RecType{}},
+		{Name: "level", FieldType: &ast.BasicType{Type: ast.STRINGS}},
+		{Name: "ts", FieldType: &ast.BasicType{Type: ast.DATETIME}},
+		{Name: "raw", FieldType: &ast.BasicType{Type: ast.BYTEA}},
+		{Name: "value", FieldType: &ast.BasicType{Type: ast.BIGINT}},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("InferAvro result is not expected, got %v, expected %v", result, expected)
+	}
+	_, err = InferAvro("test1", "Book")
+	if err == nil || err.Error() != "message type Book not found in schema file test1" {
+		t.Errorf("InferAvro error mismatch, got %v", err)
+	}
+}
@@ -45,7 +45,7 @@ func (i *Info) Validate() error {
 		return fmt.Errorf("cannot specify both content and file")
 	}
 	switch i.Type {
-	case def.PROTOBUF:
+	case def.PROTOBUF, def.AVRO:
 		if i.Content == "" && i.FilePath == "" {
 			return fmt.Errorf("must specify content or file")
 		}
@@ -61,4 +61,5 @@ func (i *Info) Validate() error {
 
 var schemaExt = map[def.SchemaType]string{
 	def.PROTOBUF: ".proto",
+	def.AVRO:     ".avsc",
 }
@@ -94,6 +94,22 @@ func TestSchemaInfo(t *testing.T) {
 			},
 			err: errors.New("soFile is required"),
 		},
+		{
+			i: &Info{
+				Type:    "avro",
+				Name:    "aa",
+				Content: "bb",
+			},
+			err: nil,
+		},
+		{
+			i: &Info{
+				Type:   "avro",
+				Name:   "aa",
+				SoPath: "bb",
+			},
+			err: errors.New("must specify content or file"),
+		},
 	}
 	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
 	for i, tt := range tests {
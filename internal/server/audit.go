@@ -0,0 +1,357 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lf-edge/ekuiper/internal/server/audit"
+	"github.com/lf-edge/ekuiper/internal/server/middleware"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+const (
+	anonymous = "anonymous"
+	// maxAuditBody is the max length of the request body and error message saved in the audit record
+	maxAuditBody = 64 * 1024
+	// maskedSecret replaces the secrets in the audit record
+	maskedSecret = "***"
+)
+
+// definitionGetters returns the definition of a resource instance to be saved in the audit log. The key is the
+// resource like rule or the resource with its type like plugin/sinks. Components can register their getters in init.
+var definitionGetters = map[string]func(typ, name string) (interface{}, error){
+	"rule": func(_, name string) (interface{}, error) {
+		return ruleProcessor.GetRuleJson(name)
+	},
+	"stream": func(_, name string) (interface{}, error) {
+		return streamProcessor.GetStream(name, ast.TypeStream)
+	},
+	"table": func(_, name string) (interface{}, error) {
+		return streamProcessor.GetStream(name, ast.TypeTable)
+	},
+}
+
+// getDefinition returns the definition in string or empty if not found
+func getDefinition(resource, typ, name string) string {
+	if name == "" {
+		return ""
+	}
+	g, ok := definitionGetters[resource+"/"+typ]
+	if !ok {
+		g, ok = definitionGetters[resource]
+	}
+	if !ok {
+		return ""
+	}
+	d, err := g(typ, name)
+	if err != nil || d == nil {
+		return ""
+	}
+	if s, ok := d.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(d)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// maskSecrets hides the password properties in the json definition like the configuration printing does, and the
+// password of the urls such as the database url. The json string values like the configurations in the import data
+// are masked recursively. The definition is returned unchanged if it is not json or has no secret.
+func maskSecrets(s string) string {
+	v, ok := parseJson(s)
+	if !ok {
+		return s
+	}
+	if masked, changed := maskValue("", v); changed {
+		if b, err := marshalJson(masked); err == nil {
+			return b
+		}
+	}
+	return s
+}
+
+func parseJson(s string) (interface{}, bool) {
+	t := strings.TrimSpace(s)
+	if !strings.HasPrefix(t, "{") && !strings.HasPrefix(t, "[") {
+		return nil, false
+	}
+	dec := json.NewDecoder(strings.NewReader(t))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return nil, false
+	}
+	return v, true
+}
+
+func marshalJson(v interface{}) (string, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+func maskValue(key string, v interface{}) (interface{}, bool) {
+	switch vt := v.(type) {
+	case map[string]interface{}:
+		changed := false
+		for k, vv := range vt {
+			if nv, c := maskValue(k, vv); c {
+				vt[k] = nv
+				changed = true
+			}
+		}
+		return vt, changed
+	case []interface{}:
+		changed := false
+		for i, vv := range vt {
+			if nv, c := maskValue(key, vv); c {
+				vt[i] = nv
+				changed = true
+			}
+		}
+		return vt, changed
+	case string:
+		if strings.Contains(strings.ToLower(key), "password") {
+			return maskedSecret, vt != ""
+		}
+		if nested, ok := parseJson(vt); ok {
+			if masked, c := maskValue("", nested); c {
+				if b, err := marshalJson(masked); err == nil {
+					return b, true
+				}
+			}
+			return vt, false
+		}
+		if u, err := url.Parse(vt); err == nil && u.Scheme != "" && u.User != nil {
+			if _, ok := u.User.Password(); ok {
+				return u.Redacted(), true
+			}
+		}
+		return vt, false
+	default:
+		if strings.Contains(strings.ToLower(key), "password") && v != nil {
+			return maskedSecret, true
+		}
+		return v, false
+	}
+}
+
+// bodyDefinition returns the request body to save in the audit record if the resource has no definition. The secrets
+// in the json body are masked. The body which is not json is saved as a digest as the secrets cannot be found.
+func bodyDefinition(body string) string {
+	if body == "" {
+		return ""
+	}
+	if _, ok := parseJson(body); ok {
+		return body
+	}
+	sum := sha256.Sum256([]byte(body))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func saveAudit(r *audit.Record) {
+	r.Before = truncate(maskSecrets(r.Before))
+	r.After = truncate(maskSecrets(r.After))
+	if err := audit.Save(r); err != nil {
+		logger.Warnf("fail to save audit record of %s %s: %v", r.Operation, r.Name, err)
+	}
+}
+
+func truncate(s string) string {
+	if len(s) > maxAuditBody {
+		return s[:maxAuditBody]
+	}
+	return s
+}
+
+// auditResponseWriter records the status code and the beginning of the response body which is the error message
+// for the failed requests
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *auditResponseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *auditResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.body.Len() < maxAuditBody {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *auditResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// restResourceType returns the type of the resource like the schema type or plugin type
+func restResourceType(r *http.Request, resource string) string {
+	if t, ok := mux.Vars(r)["type"]; ok {
+		return t
+	}
+	path := r.URL.Path
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			path = tpl
+		}
+	}
+	path = strings.TrimPrefix(path, middleware.NamespacePrefix)
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(parts) < 2 || strings.HasPrefix(parts[1], "{") {
+		return ""
+	}
+	// The rest path is in plural while the plugin type of rpc is not
+	if resource == "plugin" && parts[1] == "portables" {
+		return "portable"
+	}
+	return parts[1]
+}
+
+// readAuditBody reads the json or text body and restores it for the handler
+func readAuditBody(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+	if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && strings.HasPrefix(mt, "multipart/") {
+		return ""
+	}
+	b, err := io.ReadAll(r.Body)
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// auditMiddleware records the mutating rest requests into the audit log including the denied ones, so it must be
+// applied before the auth middleware.
+func auditMiddleware(needToken bool) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := middleware.RestPermission(r)
+			if p.Action == middleware.ActionRead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			operation := r.URL.Path
+			if route := mux.CurrentRoute(r); route != nil {
+				if tpl, err := route.GetPathTemplate(); err == nil {
+					operation = tpl
+				}
+			}
+			principal := anonymous
+			if needToken {
+				principal = ""
+				if tk, err := middleware.Authenticate(r.Header.Get("Authorization")); err == nil {
+					principal = middleware.Principal(tk)
+				}
+			}
+			typ := restResourceType(r, p.Resource)
+			rec := &audit.Record{
+				Principal: principal,
+				Source:    "rest",
+				Operation: r.Method + " " + operation,
+				Resource:  p.Resource,
+				Name:      p.Name,
+				Before:    getDefinition(p.Resource, typ, p.Name),
+			}
+			body := readAuditBody(r)
+			aw := &auditResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(aw, r)
+
+			rec.Status = aw.status
+			if rec.Status == 0 {
+				rec.Status = http.StatusOK
+			}
+			if rec.Status < http.StatusBadRequest {
+				rec.Result = audit.ResultSuccess
+			} else {
+				rec.Result = audit.ResultFailure
+				rec.Error = strings.TrimSpace(aw.body.String())
+			}
+			rec.After = getDefinition(p.Resource, typ, p.Name)
+			if rec.After == "" {
+				rec.After = bodyDefinition(body)
+			}
+			saveAudit(rec)
+		})
+	}
+}
+
+// auditHandler queries the audit records by the time range in milliseconds and the resource
+func auditHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := &audit.Filter{
+		Resource:  q.Get("resource"),
+		Name:      q.Get("name"),
+		Principal: q.Get("principal"),
+	}
+	for k, v := range map[string]*int64{"from": &f.From, "to": &f.To} {
+		if s := q.Get(k); s != "" {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				handleError(w, fmt.Errorf("%s must be a unix timestamp in milliseconds but got %s", k, s), "Invalid query", logger)
+				return
+			}
+			*v = n
+		}
+	}
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			handleError(w, fmt.Errorf("limit must be a positive integer but got %s", s), "Invalid query", logger)
+			return
+		}
+		f.Limit = n
+	}
+	records, err := audit.Query(f)
+	if err != nil {
+		handleError(w, err, "query audit records error", logger)
+		return
+	}
+	jsonResponse(records, w, logger)
+}
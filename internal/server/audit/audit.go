@@ -0,0 +1,221 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit records the management operations into the configured kv store
+package audit
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/store"
+	"github.com/lf-edge/ekuiper/pkg/kv"
+)
+
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+	// DefaultLimit is the default max number of records returned by a query
+	DefaultLimit = 100
+	// keyLen is the length of the zero padded nanosecond timestamp key, so that the keys are sortable as strings
+	keyLen = 19
+)
+
+// Record is an audited operation
+type Record struct {
+	Id        string `json:"id"`
+	Timestamp int64  `json:"timestamp"`
+	Principal string `json:"principal"`
+	// Source is where the operation comes from, rest or rpc
+	Source string `json:"source"`
+	// Operation is the rest route like "DELETE /rules/{name}" or the rpc method like "Server.DropRule"
+	Operation string `json:"operation"`
+	Resource  string `json:"resource"`
+	Name      string `json:"name,omitempty"`
+	// Before and After are the definitions of the resource before and after the operation if available
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+	Result string `json:"result"`
+	// Status is the http status code of the rest operation
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Filter is the conditions to query the records. Zero value fields are ignored.
+type Filter struct {
+	// From and To are the inclusive time range in milliseconds
+	From      int64
+	To        int64
+	Resource  string
+	Name      string
+	Principal string
+	Limit     int
+}
+
+var (
+	auditDb   kv.KeyValue
+	auditOnce sync.Once
+	auditErr  error
+
+	// mu guards the index of the keys and the retention
+	mu      sync.Mutex
+	lastKey int64
+	// index is the ascending keys of the records loaded from the db once, so that the queries do not scan the db
+	index []string
+	// The retention, 0 means no limit
+	maxCount int
+	maxAge   time.Duration
+)
+
+// Init sets the retention of the records
+func Init(c *conf.AuditConf) {
+	if c == nil {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	maxCount = c.MaxCount
+	maxAge = time.Duration(c.MaxAge) * time.Hour
+}
+
+func getAuditDb() (kv.KeyValue, error) {
+	auditOnce.Do(func() {
+		auditDb, auditErr = store.GetKV("audit")
+		if auditErr != nil {
+			return
+		}
+		var keys []string
+		keys, auditErr = auditDb.Keys()
+		if auditErr != nil {
+			return
+		}
+		index = make([]string, 0, len(keys))
+		for _, k := range keys {
+			if _, err := parseKey(k); err == nil {
+				index = append(index, k)
+			}
+		}
+		sort.Strings(index)
+	})
+	return auditDb, auditErr
+}
+
+func parseKey(k string) (int64, error) {
+	if len(k) != keyLen {
+		return 0, fmt.Errorf("invalid audit key %s", k)
+	}
+	return strconv.ParseInt(k, 10, 64)
+}
+
+// nextKey returns an unique and increasing key based on the current time
+func nextKey() int64 {
+	k := time.Now().UnixNano()
+	if k <= lastKey {
+		k = lastKey + 1
+	}
+	lastKey = k
+	return k
+}
+
+// prune deletes the oldest records out of the retention
+func prune(db kv.KeyValue, now int64) {
+	n := 0
+	if maxCount > 0 && len(index) > maxCount {
+		n = len(index) - maxCount
+	}
+	if maxAge > 0 {
+		oldest := fmt.Sprintf("%0*d", keyLen, now-int64(maxAge))
+		for n < len(index) && index[n] < oldest {
+			n++
+		}
+	}
+	for _, k := range index[:n] {
+		_ = db.Delete(k)
+	}
+	index = index[n:]
+}
+
+// Save persists the record and deletes the records out of the retention. The id and timestamp are generated.
+func Save(r *Record) error {
+	db, err := getAuditDb()
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	k := nextKey()
+	r.Id = fmt.Sprintf("%0*d", keyLen, k)
+	r.Timestamp = k / int64(time.Millisecond)
+	if err := db.Setnx(r.Id, r); err != nil {
+		return err
+	}
+	index = append(index, r.Id)
+	prune(db, k)
+	return nil
+}
+
+// Query returns the records matching the filter, the latest first
+func Query(f *Filter) ([]*Record, error) {
+	db, err := getAuditDb()
+	if err != nil {
+		return nil, err
+	}
+	// The saved keys are never changed, so the snapshot is safe to read without the lock
+	mu.Lock()
+	keys := index
+	mu.Unlock()
+	end := len(keys)
+	if f.To > 0 {
+		// The first key after the end of the To millisecond
+		to := fmt.Sprintf("%0*d", keyLen, (f.To+1)*int64(time.Millisecond))
+		end = sort.SearchStrings(keys, to)
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	result := make([]*Record, 0)
+	for i := end - 1; i >= 0 && len(result) < limit; i-- {
+		k := keys[i]
+		n, _ := parseKey(k)
+		// The keys are sorted descending, so the rest are all earlier
+		if f.From > 0 && n/int64(time.Millisecond) < f.From {
+			break
+		}
+		r := &Record{}
+		found, err := db.Get(k, r)
+		if err != nil {
+			return nil, err
+		}
+		// May be pruned
+		if !found {
+			continue
+		}
+		if f.Resource != "" && r.Resource != f.Resource {
+			continue
+		}
+		if f.Name != "" && r.Name != f.Name {
+			continue
+		}
+		if f.Principal != "" && r.Principal != f.Principal {
+			continue
+		}
+		result = append(result, r)
+	}
+	return result, nil
+}
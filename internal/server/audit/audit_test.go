@@ -0,0 +1,98 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/testx"
+)
+
+func TestSaveAndQuery(t *testing.T) {
+	testx.InitEnv()
+	start := time.Now().UnixMilli()
+	records := []*Record{
+		{Principal: "alice", Source: "rest", Operation: "POST /streams", Resource: "stream", After: "create stream auditDemo () WITH (DATASOURCE=\"demo\")", Result: ResultSuccess},
+		{Principal: "bob", Source: "rpc", Operation: "Server.DropRule", Resource: "rule", Name: "auditRule", Before: "{\"id\":\"auditRule\"}", Result: ResultFailure, Error: "forbidden"},
+		{Principal: "alice", Source: "rest", Operation: "PUT /rules/{name}", Resource: "rule", Name: "auditRule", Before: "{\"id\":\"auditRule\"}", After: "{\"id\":\"auditRule\",\"sql\":\"SELECT 1\"}", Result: ResultSuccess},
+	}
+	for _, r := range records {
+		require.NoError(t, Save(r))
+		assert.Len(t, r.Id, keyLen)
+		assert.GreaterOrEqual(t, r.Timestamp, start)
+	}
+	end := time.Now().UnixMilli()
+
+	result, err := Query(&Filter{Resource: "rule", Name: "auditRule", From: start, To: end})
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+	assert.Equal(t, records[2], result[0])
+	assert.Equal(t, records[1], result[1])
+
+	result, err = Query(&Filter{Principal: "alice", From: start})
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+	assert.Equal(t, records[2].Id, result[0].Id)
+	assert.Equal(t, records[0].Id, result[1].Id)
+
+	result, err = Query(&Filter{From: start, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, records[2].Id, result[0].Id)
+
+	result, err = Query(&Filter{From: end + 1})
+	require.NoError(t, err)
+	assert.Len(t, result, 0)
+
+	result, err = Query(&Filter{To: start - 1, Resource: "rule", Name: "auditRule"})
+	require.NoError(t, err)
+	assert.Len(t, result, 0)
+}
+
+func TestRetention(t *testing.T) {
+	testx.InitEnv()
+	Init(&conf.AuditConf{MaxCount: 2, MaxAge: 1})
+	defer Init(&conf.AuditConf{})
+	start := time.Now().UnixMilli()
+	for _, name := range []string{"r1", "r2", "r3"} {
+		require.NoError(t, Save(&Record{Source: "rest", Operation: "DELETE /rules/{name}", Resource: "rule", Name: name, Result: ResultSuccess}))
+	}
+	result, err := Query(&Filter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+	assert.Equal(t, "r3", result[0].Name)
+	assert.Equal(t, "r2", result[1].Name)
+	db, err := getAuditDb()
+	require.NoError(t, err)
+	keys, err := db.Keys()
+	require.NoError(t, err)
+	assert.Len(t, keys, 2)
+
+	// All the records expire after the max age
+	mu.Lock()
+	prune(db, (start+2*time.Hour.Milliseconds())*int64(time.Millisecond))
+	mu.Unlock()
+	result, err = Query(&Filter{})
+	require.NoError(t, err)
+	assert.Len(t, result, 0)
+	keys, err = db.Keys()
+	require.NoError(t, err)
+	assert.Len(t, keys, 0)
+}
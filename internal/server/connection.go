@@ -0,0 +1,60 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lf-edge/ekuiper/internal/topo/connection/clients"
+)
+
+// connectionSelectorOf returns the connection selector like mqtt.localconnection of the request
+func connectionSelectorOf(r *http.Request) (string, string) {
+	vars := mux.Vars(r)
+	typ := strings.ToLower(vars["type"])
+	return typ, typ + "." + strings.ToLower(vars["name"])
+}
+
+// list the health of the shared connections in use
+func connectionsHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	jsonResponse(clients.GetConnectionStatus(), w, logger)
+}
+
+// get the health of a shared connection in use
+func connectionHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	_, selector := connectionSelectorOf(r)
+	status, err := clients.GetConnectionStatusBySelector(selector)
+	if err != nil {
+		handleError(w, err, "describe connection error", logger)
+		return
+	}
+	jsonResponse(status, w, logger)
+}
+
+// test the connectivity of a connection declared in connection.yaml
+func connectionTestHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	typ, selector := connectionSelectorOf(r)
+	if err := clients.TestConnection(typ, selector); err != nil {
+		handleError(w, err, "test connection error", logger)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
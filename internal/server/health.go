@@ -0,0 +1,88 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/lf-edge/ekuiper/internal/topo/rule"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+// readiness is the result of the readiness check of the server
+type readiness struct {
+	Ready bool `json:"ready"`
+	// Rules are the ids of the rules which should be running but are not healthy
+	Rules []string `json:"rules,omitempty"`
+}
+
+func getRuleHealth(name string) (*rule.Health, error) {
+	if rs, ok := registry.Load(name); ok {
+		return rs.GetHealth()
+	} else {
+		return nil, errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("Rule %s is not found", name))
+	}
+}
+
+// getReadiness checks the health of all the rules. The server is ready only if all the rules which should be running
+// are running and none of their connections is disconnected.
+func getReadiness() *readiness {
+	result := &readiness{Ready: true}
+	for _, id := range registry.Keys() {
+		h, err := getRuleHealth(id)
+		if err != nil {
+			// the rule is deleted during the check
+			continue
+		}
+		if !h.Ready() {
+			result.Ready = false
+			result.Rules = append(result.Rules, id)
+		}
+	}
+	sort.Strings(result.Rules)
+	return result
+}
+
+// get the health of a rule and its sources and sinks
+func ruleHealthHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	h, err := getRuleHealth(ruleIdOf(r))
+	if err != nil {
+		handleError(w, err, "get rule health error", logger)
+		return
+	}
+	jsonResponse(h, w, logger)
+}
+
+// readiness probe for orchestration, responds 503 if any rule is not ready
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	result := getReadiness()
+	if result.Ready {
+		jsonResponse(result, w, logger)
+		return
+	}
+	b, err := json.Marshal(result)
+	if err != nil {
+		handleError(w, err, "", logger)
+		return
+	}
+	w.Header().Set(ContentType, ContentTypeJSON)
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_, _ = w.Write(b)
+}
@@ -0,0 +1,100 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/processor"
+	"github.com/lf-edge/ekuiper/internal/topo/rule"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestHealthRoutes(t *testing.T) {
+	streamProcessor = processor.NewStreamProcessor()
+	ruleProcessor = processor.NewRuleProcessor()
+	r := mux.NewRouter()
+	r.HandleFunc("/ready", readyHandler).Methods(http.MethodGet)
+	for _, router := range []*mux.Router{r, namespaceRouter(r)} {
+		router.HandleFunc("/rules/{name}/health", ruleHealthHandler).Methods(http.MethodGet)
+	}
+	do := func(path string) (int, string) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code, w.Body.String()
+	}
+	health := func(path string) *rule.Health {
+		code, body := do(path)
+		require.Equal(t, http.StatusOK, code, body)
+		h := &rule.Health{}
+		require.NoError(t, json.Unmarshal([]byte(body), h))
+		return h
+	}
+
+	_, err := streamProcessor.ExecStmt(`CREATE STREAM healthDemo () WITH (DATASOURCE="healthDemo", TYPE="memory", FORMAT="json")`)
+	require.NoError(t, err)
+	defer func() {
+		_, _ = streamProcessor.DropStream("healthDemo", ast.TypeStream)
+	}()
+	_, err = createRule("", "healthRule1", `{"sql":"SELECT * FROM healthDemo","actions":[{"log":{}}],"triggered":false}`)
+	require.NoError(t, err)
+	defer func() {
+		deleteRule("healthRule1")
+		_, _ = ruleProcessor.ExecDrop("healthRule1")
+	}()
+
+	assert.Equal(t, rule.HealthStopped, health("/rules/healthRule1/health").Status)
+	code, body := do("/ready")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, `{"ready":true}`, body)
+
+	_, err = createRule("", "healthRule2", `{"sql":"SELECT * FROM healthDemo","actions":[{"log":{}}]}`)
+	require.NoError(t, err)
+	defer func() {
+		deleteRule("healthRule2")
+		_, _ = ruleProcessor.ExecDrop("healthRule2")
+	}()
+	assert.Eventually(t, func() bool {
+		h := health("/rules/healthRule2/health")
+		return h.Status == rule.HealthHealthy && len(h.Nodes) == 2
+	}, time.Second, 10*time.Millisecond)
+	code, _ = do("/ready")
+	assert.Equal(t, http.StatusOK, code)
+
+	// The mqtt sink fails to open
+	_, err = createRule("", "healthRule3", `{"sql":"SELECT * FROM healthDemo","actions":[{"mqtt":{"server":"tcp://127.0.0.1:1","topic":"healthDemo"}}]}`)
+	require.NoError(t, err)
+	defer func() {
+		deleteRule("healthRule3")
+		_, _ = ruleProcessor.ExecDrop("healthRule3")
+	}()
+	assert.Eventually(t, func() bool {
+		return health("/rules/healthRule3/health").Status == rule.HealthUnhealthy
+	}, time.Second, 10*time.Millisecond)
+	code, body = do("/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, `{"ready":false,"rules":["healthRule3"]}`, body)
+
+	code, _ = do("/rules/notExist/health")
+	assert.Equal(t, http.StatusNotFound, code)
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/gorilla/mux"
 
 	"github.com/lf-edge/ekuiper/internal/meta"
+	"github.com/lf-edge/ekuiper/internal/pkg/namespace"
 	"github.com/lf-edge/ekuiper/internal/topo/node"
 	"github.com/lf-edge/ekuiper/pkg/ast"
 )
@@ -50,15 +51,17 @@ func (m metaComp) rest(r *mux.Router) {
 	r.HandleFunc("/metadata/sinks/{name}", newSinkMetaHandler).Methods(http.MethodGet)
 	r.HandleFunc("/metadata/sources", sourcesMetaHandler).Methods(http.MethodGet)
 	r.HandleFunc("/metadata/sources/{name}", sourceMetaHandler).Methods(http.MethodGet)
-	r.HandleFunc("/metadata/sources/yaml/{name}", sourceConfHandler).Methods(http.MethodGet)
-	r.HandleFunc("/metadata/sources/{name}/confKeys/{confKey}", sourceConfKeyHandler).Methods(http.MethodDelete, http.MethodPut)
-	r.HandleFunc("/metadata/sinks/yaml/{name}", sinkConfHandler).Methods(http.MethodGet)
-	r.HandleFunc("/metadata/sinks/{name}/confKeys/{confKey}", sinkConfKeyHandler).Methods(http.MethodDelete, http.MethodPut)
-
 	r.HandleFunc("/metadata/connections", connectionsMetaHandler).Methods(http.MethodGet)
 	r.HandleFunc("/metadata/connections/{name}", connectionMetaHandler).Methods(http.MethodGet)
-	r.HandleFunc("/metadata/connections/yaml/{name}", connectionConfHandler).Methods(http.MethodGet)
-	r.HandleFunc("/metadata/connections/{name}/confKeys/{confKey}", connectionConfKeyHandler).Methods(http.MethodDelete, http.MethodPut)
+	// The conf keys are scoped to the namespace and are saved with the qualified name
+	for _, router := range []*mux.Router{r, namespaceRouter(r)} {
+		router.HandleFunc("/metadata/sources/yaml/{name}", sourceConfHandler).Methods(http.MethodGet)
+		router.HandleFunc("/metadata/sources/{name}/confKeys/{confKey}", sourceConfKeyHandler).Methods(http.MethodDelete, http.MethodPut)
+		router.HandleFunc("/metadata/sinks/yaml/{name}", sinkConfHandler).Methods(http.MethodGet)
+		router.HandleFunc("/metadata/sinks/{name}/confKeys/{confKey}", sinkConfKeyHandler).Methods(http.MethodDelete, http.MethodPut)
+		router.HandleFunc("/metadata/connections/yaml/{name}", connectionConfHandler).Methods(http.MethodGet)
+		router.HandleFunc("/metadata/connections/{name}/confKeys/{confKey}", connectionConfKeyHandler).Methods(http.MethodDelete, http.MethodPut)
+	}
 
 	r.HandleFunc("/metadata/resources", resourcesHandler).Methods(http.MethodGet)
 	r.HandleFunc("/metadata/sources/connection/{name}", sourceConnectionHandler).Methods(http.MethodPost)
@@ -175,7 +178,7 @@ func sourceConfHandler(w http.ResponseWriter, r *http.Request) {
 	pluginName := vars["name"]
 	language := getLanguage(r)
 	configOperatorKey := fmt.Sprintf(meta.SourceCfgOperatorKeyTemplate, pluginName)
-	ret, err := meta.GetYamlConf(configOperatorKey, language)
+	ret, err := getNamespaceYamlConf(namespaceOf(r), configOperatorKey, language)
 	if err != nil {
 		handleError(w, err, "", logger)
 		return
@@ -191,7 +194,7 @@ func connectionConfHandler(w http.ResponseWriter, r *http.Request) {
 	pluginName := vars["name"]
 	language := getLanguage(r)
 	configOperatorKey := fmt.Sprintf(meta.ConnectionCfgOperatorKeyTemplate, pluginName)
-	ret, err := meta.GetYamlConf(configOperatorKey, language)
+	ret, err := getNamespaceYamlConf(namespaceOf(r), configOperatorKey, language)
 	if err != nil {
 		handleError(w, err, "", logger)
 		return
@@ -207,7 +210,7 @@ func sinkConfHandler(w http.ResponseWriter, r *http.Request) {
 	pluginName := vars["name"]
 	language := getLanguage(r)
 	configOperatorKey := fmt.Sprintf(meta.SinkCfgOperatorKeyTemplate, pluginName)
-	ret, err := meta.GetYamlConf(configOperatorKey, language)
+	ret, err := getNamespaceYamlConf(namespaceOf(r), configOperatorKey, language)
 	if err != nil {
 		handleError(w, err, "", logger)
 		return
@@ -222,7 +225,7 @@ func sourceConfKeyHandler(w http.ResponseWriter, r *http.Request) {
 	var err error
 	vars := mux.Vars(r)
 	pluginName := vars["name"]
-	confKey := vars["confKey"]
+	confKey := namespace.Qualify(namespaceOf(r), vars["confKey"])
 	language := getLanguage(r)
 	switch r.Method {
 	case http.MethodDelete:
@@ -247,7 +250,7 @@ func sinkConfKeyHandler(w http.ResponseWriter, r *http.Request) {
 	var err error
 	vars := mux.Vars(r)
 	pluginName := vars["name"]
-	confKey := vars["confKey"]
+	confKey := namespace.Qualify(namespaceOf(r), vars["confKey"])
 	language := getLanguage(r)
 	switch r.Method {
 	case http.MethodDelete:
@@ -272,7 +275,7 @@ func connectionConfKeyHandler(w http.ResponseWriter, r *http.Request) {
 	var err error
 	vars := mux.Vars(r)
 	pluginName := vars["name"]
-	confKey := vars["confKey"]
+	confKey := namespace.Qualify(namespaceOf(r), vars["confKey"])
 	language := getLanguage(r)
 	switch r.Method {
 	case http.MethodDelete:
@@ -357,3 +360,22 @@ func sourceConnectionHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusOK)
 }
+
+// getNamespaceYamlConf returns the conf keys of the namespace without the namespace prefix
+func getNamespaceYamlConf(ns, configOperatorKey, language string) ([]byte, error) {
+	b, err := meta.GetYamlConf(configOperatorKey, language)
+	if err != nil || ns == "" {
+		return b, err
+	}
+	all := make(map[string]interface{})
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+	result := make(map[string]interface{})
+	for k, v := range all {
+		if n, name := namespace.Split(k); n == ns {
+			result[name] = v
+		}
+	}
+	return json.Marshal(result)
+}
@@ -3,11 +3,9 @@ package middleware
 import (
 	"fmt"
 	"net/http"
-
-	"github.com/lf-edge/ekuiper/internal/pkg/jwt"
 )
 
-var notAuth = []string{"/", "/ping"}
+var notAuth = []string{"/", "/ping", "/ready"}
 
 var Auth = func(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -19,19 +17,13 @@ var Auth = func(next http.Handler) http.Handler {
 			}
 		}
 
-		tokenHeader := r.Header.Get("Authorization")
-
-		if tokenHeader == "" {
-			http.Error(w, "missing_token", http.StatusUnauthorized)
-			return
-		}
-		tk, err := jwt.ParseToken(tokenHeader)
+		tk, err := Authenticate(r.Header.Get("Authorization"))
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusUnauthorized)
 			return
 		}
-		if tk.StandardClaims.Audience != "eKuiper" {
-			http.Error(w, fmt.Sprintf("audience field should be eKuiper, but got %s", tk.StandardClaims.Audience), http.StatusUnauthorized)
+		if err := Authorize(tk, RestPermission(r)); err != nil {
+			http.Error(w, fmt.Sprintf("forbidden: %v", err), http.StatusForbidden)
 			return
 		}
 
@@ -6,6 +6,7 @@ import (
 	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/lf-edge/ekuiper/internal/pkg/jwt"
 )
@@ -15,6 +16,11 @@ func genToken(signKeyName, issuer, aud string) string {
 	return tkStr
 }
 
+func genScopedToken(role string, scopes []string) string {
+	tkStr, _ := jwt.CreateScopedToken("sample_key", "sample_key.pub", "eKuiper", "", "", role, scopes, time.Now().Add(time.Minute))
+	return tkStr
+}
+
 func Test_AUTH(t *testing.T) {
 	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -54,6 +60,34 @@ func Test_AUTH(t *testing.T) {
 			res:      httptest.NewRecorder(),
 			wantCode: 401,
 		},
+		{
+			name:     "viewer read",
+			args:     args{th: genScopedToken(RoleViewer, nil)},
+			req:      httptest.NewRequest(http.MethodGet, "http://127.0.0.1:9081/streams", nil),
+			res:      httptest.NewRecorder(),
+			wantCode: 200,
+		},
+		{
+			name:     "viewer write",
+			args:     args{th: genScopedToken(RoleViewer, nil)},
+			req:      httptest.NewRequest(http.MethodDelete, "http://127.0.0.1:9081/streams", nil),
+			res:      httptest.NewRecorder(),
+			wantCode: 403,
+		},
+		{
+			name:     "unknown role",
+			args:     args{th: genScopedToken("guest", nil)},
+			req:      httptest.NewRequest(http.MethodGet, "http://127.0.0.1:9081/streams", nil),
+			res:      httptest.NewRecorder(),
+			wantCode: 403,
+		},
+		{
+			name:     "scope not allowed",
+			args:     args{th: genScopedToken(RoleAdmin, []string{"rule:*"})},
+			req:      httptest.NewRequest(http.MethodGet, "http://127.0.0.1:9081/streams", nil),
+			res:      httptest.NewRecorder(),
+			wantCode: 403,
+		},
 		{
 			name:     "no need token path",
 			args:     args{th: ""},
@@ -61,6 +95,13 @@ func Test_AUTH(t *testing.T) {
 			res:      httptest.NewRecorder(),
 			wantCode: 200,
 		},
+		{
+			name:     "no need token for readiness",
+			args:     args{th: ""},
+			req:      httptest.NewRequest(http.MethodGet, "http://127.0.0.1:9081/ready", nil),
+			res:      httptest.NewRecorder(),
+			wantCode: 200,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,195 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lf-edge/ekuiper/internal/pkg/jwt"
+	"github.com/lf-edge/ekuiper/internal/pkg/namespace"
+)
+
+// NamespacePrefix is the path prefix of the rest routes of a namespace
+const NamespacePrefix = "/namespaces/{namespace}"
+
+const (
+	RoleViewer       = "viewer"
+	RoleRuleOperator = "ruleOperator"
+	RoleAdmin        = "admin"
+)
+
+const (
+	ActionRead  = "read"
+	ActionWrite = "write"
+)
+
+// Permission is the resource and action required by a request. Name is the name of the resource instance if any.
+type Permission struct {
+	Resource string
+	Action   string
+	Name     string
+}
+
+func (p Permission) String() string {
+	if p.Name == "" {
+		return p.Resource + ":" + p.Action
+	}
+	return p.Resource + ":" + p.Action + ":" + p.Name
+}
+
+var viewerPermissions = []string{
+	"stream:read", "table:read", "rule:read", "ruleset:read", "config:read", "plugin:read",
+	"metadata:read", "schema:read", "service:read", "metrics:read", "connection:read",
+}
+
+// rolePermissions defines the permissions of each role in the format of resource:action. * matches any.
+var rolePermissions = map[string][]string{
+	RoleViewer:       viewerPermissions,
+	RoleRuleOperator: append([]string{"stream:write", "table:write", "rule:write"}, viewerPermissions...),
+	RoleAdmin:        {"*:*"},
+}
+
+// resources maps the first segment of the rest path to the resource name
+var resources = map[string]string{
+	"streams":     "stream",
+	"tables":      "table",
+	"rules":       "rule",
+	"ruleset":     "ruleset",
+	"configs":     "config",
+	"config":      "config",
+	"data":        "data",
+	"plugins":     "plugin",
+	"metadata":    "metadata",
+	"schemas":     "schema",
+	"services":    "service",
+	"tokens":      "token",
+	"metrics":     "metrics",
+	"audit":       "audit",
+	"connections": "connection",
+}
+
+// readRoutes are the non GET routes which do not change anything
+var readRoutes = map[string]bool{
+	"POST /rules/validate":                     true,
+	"POST /ruleset/export":                     true,
+	"POST /data/export":                        true,
+	"POST /metadata/sources/connection/{name}": true,
+	"POST /metadata/sinks/connection/{name}":   true,
+	"POST /connections/{type}/{name}/test":     true,
+}
+
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// ValidateScopes checks the format of the scopes which must be resource:action or resource:action:name. The name can
+// be a pattern like tenant1@* to match all resources of a namespace.
+func ValidateScopes(scopes []string) error {
+	for _, s := range scopes {
+		parts := strings.Split(s, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return fmt.Errorf("invalid scope %s, must be in the format of resource:action[:name]", s)
+		}
+		for _, p := range parts {
+			if p == "" {
+				return fmt.Errorf("invalid scope %s, must be in the format of resource:action[:name]", s)
+			}
+		}
+		if parts[1] != ActionRead && parts[1] != ActionWrite && parts[1] != "*" {
+			return fmt.Errorf("invalid scope %s, action must be read, write or *", s)
+		}
+	}
+	return nil
+}
+
+// RestPermission returns the permission required by a rest request according to its route
+// The routes of a namespace share the permissions of the plain routes and the name is qualified by the namespace.
+func RestPermission(r *http.Request) Permission {
+	p := r.URL.Path
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			p = tpl
+		}
+	}
+	p = strings.TrimPrefix(p, NamespacePrefix)
+	seg := strings.SplitN(strings.TrimPrefix(p, "/"), "/", 2)[0]
+	resource, ok := resources[seg]
+	if !ok {
+		resource = seg
+	}
+	action := ActionWrite
+	if r.Method == http.MethodGet || r.Method == http.MethodHead || readRoutes[r.Method+" "+p] {
+		action = ActionRead
+	}
+	vars := mux.Vars(r)
+	name := vars["name"]
+	if ns, ok := vars["namespace"]; ok {
+		name = namespace.Qualify(ns, name)
+	}
+	return Permission{Resource: resource, Action: action, Name: name}
+}
+
+// Authorize checks if the token has the permission by its role and scopes
+func Authorize(tk *jwt.Token, p Permission) error {
+	role := tk.Role
+	if role == "" {
+		role = RoleAdmin
+	}
+	perms, ok := rolePermissions[role]
+	if !ok {
+		return fmt.Errorf("unknown role %s", role)
+	}
+	if !matchAny(perms, p) {
+		return fmt.Errorf("role %s has no permission %s", role, p)
+	}
+	if len(tk.Scopes) > 0 && !matchAny(tk.Scopes, p) {
+		return fmt.Errorf("token scopes %v do not allow %s", tk.Scopes, p)
+	}
+	return nil
+}
+
+func matchAny(patterns []string, p Permission) bool {
+	for _, pattern := range patterns {
+		parts := strings.Split(pattern, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			continue
+		}
+		if parts[0] != "*" && parts[0] != p.Resource {
+			continue
+		}
+		if parts[1] != "*" && parts[1] != p.Action {
+			continue
+		}
+		if len(parts) == 3 && !matchName(parts[2], p.Name) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func matchName(pattern, name string) bool {
+	if pattern == "*" || pattern == name {
+		return true
+	}
+	ok, err := path.Match(pattern, name)
+	return err == nil && ok
+}
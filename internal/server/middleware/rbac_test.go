@@ -0,0 +1,151 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lf-edge/ekuiper/internal/pkg/jwt"
+	"github.com/lf-edge/ekuiper/internal/testx"
+)
+
+func TestRestPermission(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		p      Permission
+	}{
+		{http.MethodGet, "/streams", Permission{Resource: "stream", Action: ActionRead}},
+		{http.MethodPost, "/streams", Permission{Resource: "stream", Action: ActionWrite}},
+		{http.MethodDelete, "/rules/rule1", Permission{Resource: "rule", Action: ActionWrite, Name: "rule1"}},
+		{http.MethodPost, "/rules/rule1/start", Permission{Resource: "rule", Action: ActionWrite, Name: "rule1"}},
+		{http.MethodGet, "/rules/rule1/status", Permission{Resource: "rule", Action: ActionRead, Name: "rule1"}},
+		{http.MethodGet, "/rules/rule1/health", Permission{Resource: "rule", Action: ActionRead, Name: "rule1"}},
+		{http.MethodPost, "/rules/validate", Permission{Resource: "rule", Action: ActionRead}},
+		{http.MethodPost, "/ruleset/import", Permission{Resource: "ruleset", Action: ActionWrite}},
+		{http.MethodPost, "/ruleset/export", Permission{Resource: "ruleset", Action: ActionRead}},
+		{http.MethodPut, "/plugins/sinks/file", Permission{Resource: "plugin", Action: ActionWrite, Name: "file"}},
+		{http.MethodPut, "/metadata/sources/mqtt/confKeys/demo", Permission{Resource: "metadata", Action: ActionWrite, Name: "mqtt"}},
+		{http.MethodDelete, "/tokens/abc", Permission{Resource: "token", Action: ActionWrite}},
+		{http.MethodPost, "/connections/sql/mysql/test", Permission{Resource: "connection", Action: ActionRead, Name: "mysql"}},
+		{http.MethodDelete, "/namespaces/tenant1/rules/rule1", Permission{Resource: "rule", Action: ActionWrite, Name: "tenant1@rule1"}},
+		{http.MethodPost, "/namespaces/tenant1/rules/validate", Permission{Resource: "rule", Action: ActionRead, Name: "tenant1@"}},
+		{http.MethodGet, "/namespaces/default/streams", Permission{Resource: "stream", Action: ActionRead}},
+	}
+	var got Permission
+	r := mux.NewRouter()
+	h := func(w http.ResponseWriter, r *http.Request) {
+		got = RestPermission(r)
+	}
+	r.HandleFunc("/streams", h).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/rules/{name}", h).Methods(http.MethodDelete)
+	r.HandleFunc("/rules/{name}/start", h).Methods(http.MethodPost)
+	r.HandleFunc("/rules/{name}/status", h).Methods(http.MethodGet)
+	r.HandleFunc("/rules/{name}/health", h).Methods(http.MethodGet)
+	r.HandleFunc("/rules/validate", h).Methods(http.MethodPost)
+	r.HandleFunc("/ruleset/import", h).Methods(http.MethodPost)
+	r.HandleFunc("/ruleset/export", h).Methods(http.MethodPost)
+	r.HandleFunc("/plugins/sinks/{name}", h).Methods(http.MethodPut)
+	r.HandleFunc("/metadata/sources/{name}/confKeys/{confKey}", h).Methods(http.MethodPut)
+	r.HandleFunc("/tokens/{id}", h).Methods(http.MethodDelete)
+	r.HandleFunc("/connections/{type}/{name}/test", h).Methods(http.MethodPost)
+	nr := r.PathPrefix(NamespacePrefix).Subrouter()
+	nr.HandleFunc("/streams", h).Methods(http.MethodGet)
+	nr.HandleFunc("/rules/validate", h).Methods(http.MethodPost)
+	nr.HandleFunc("/rules/{name}", h).Methods(http.MethodDelete)
+	for _, tt := range tests {
+		got = Permission{}
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+		assert.Equal(t, tt.p, got, "%s %s", tt.method, tt.path)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	tests := []struct {
+		role   string
+		scopes []string
+		p      Permission
+		err    string
+	}{
+		{
+			role: "",
+			p:    Permission{Resource: "plugin", Action: ActionWrite},
+		}, {
+			role: RoleAdmin,
+			p:    Permission{Resource: "token", Action: ActionWrite},
+		}, {
+			role: RoleViewer,
+			p:    Permission{Resource: "rule", Action: ActionRead, Name: "rule1"},
+		}, {
+			role: RoleViewer,
+			p:    Permission{Resource: "rule", Action: ActionWrite, Name: "rule1"},
+			err:  "role viewer has no permission rule:write:rule1",
+		}, {
+			role: RoleViewer,
+			p:    Permission{Resource: "data", Action: ActionRead},
+			err:  "role viewer has no permission data:read",
+		}, {
+			role: RoleRuleOperator,
+			p:    Permission{Resource: "rule", Action: ActionWrite, Name: "rule1"},
+		}, {
+			role: RoleRuleOperator,
+			p:    Permission{Resource: "plugin", Action: ActionWrite},
+			err:  "role ruleOperator has no permission plugin:write",
+		}, {
+			role:   RoleRuleOperator,
+			scopes: []string{"rule:*:rule1"},
+			p:      Permission{Resource: "rule", Action: ActionWrite, Name: "rule1"},
+		}, {
+			role:   RoleRuleOperator,
+			scopes: []string{"rule:*:rule1"},
+			p:      Permission{Resource: "rule", Action: ActionWrite, Name: "rule2"},
+			err:    "token scopes [rule:*:rule1] do not allow rule:write:rule2",
+		}, {
+			role:   RoleRuleOperator,
+			scopes: []string{"*:*:tenant1@*"},
+			p:      Permission{Resource: "stream", Action: ActionWrite, Name: "tenant1@demo"},
+		}, {
+			role:   RoleRuleOperator,
+			scopes: []string{"*:*:tenant1@*"},
+			p:      Permission{Resource: "stream", Action: ActionWrite, Name: "tenant2@demo"},
+			err:    "token scopes [*:*:tenant1@*] do not allow stream:write:tenant2@demo",
+		}, {
+			role:   RoleAdmin,
+			scopes: []string{"*:read"},
+			p:      Permission{Resource: "plugin", Action: ActionWrite},
+			err:    "token scopes [*:read] do not allow plugin:write",
+		}, {
+			role: "guest",
+			p:    Permission{Resource: "rule", Action: ActionRead},
+			err:  "unknown role guest",
+		},
+	}
+	for i, tt := range tests {
+		err := Authorize(&jwt.Token{Role: tt.role, Scopes: tt.scopes}, tt.p)
+		assert.Equal(t, tt.err, testx.Errstring(err), "case %d", i)
+	}
+}
+
+func TestValidateScopes(t *testing.T) {
+	assert.NoError(t, ValidateScopes([]string{"rule:read", "rule:write:rule1", "*:*"}))
+	assert.EqualError(t, ValidateScopes([]string{"rule"}), "invalid scope rule, must be in the format of resource:action[:name]")
+	assert.EqualError(t, ValidateScopes([]string{"rule::a"}), "invalid scope rule::a, must be in the format of resource:action[:name]")
+	assert.EqualError(t, ValidateScopes([]string{"rule:delete"}), "invalid scope rule:delete, action must be read, write or *")
+}
@@ -0,0 +1,211 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/jwt"
+	"github.com/lf-edge/ekuiper/internal/pkg/store"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+	"github.com/lf-edge/ekuiper/pkg/kv"
+)
+
+const (
+	Audience = "eKuiper"
+	// DefaultTokenExpire is the default expiration of the issued tokens in seconds
+	DefaultTokenExpire = 24 * 60 * 60
+)
+
+// TokenRequest is the request to issue a scoped token
+type TokenRequest struct {
+	Name   string   `json:"name"`
+	Role   string   `json:"role"`
+	Scopes []string `json:"scopes"`
+	// Expire is the expiration in seconds
+	Expire int64 `json:"expire"`
+}
+
+// TokenInfo is the metadata of an issued token. The token string itself is never saved.
+type TokenInfo struct {
+	Id        string   `json:"id"`
+	Name      string   `json:"name"`
+	Role      string   `json:"role"`
+	Scopes    []string `json:"scopes,omitempty"`
+	IssuedAt  int64    `json:"issuedAt"`
+	ExpiresAt int64    `json:"expiresAt"`
+	Revoked   bool     `json:"revoked"`
+}
+
+var (
+	tokenDb   kv.KeyValue
+	tokenOnce sync.Once
+	tokenErr  error
+)
+
+func getTokenDb() (kv.KeyValue, error) {
+	tokenOnce.Do(func() {
+		tokenDb, tokenErr = store.GetKV("authTokens")
+	})
+	return tokenDb, tokenErr
+}
+
+// Authenticate parses the token and checks its audience and revocation
+func Authenticate(th string) (*jwt.Token, error) {
+	if th == "" {
+		return nil, fmt.Errorf("missing_token")
+	}
+	tk, err := jwt.ParseToken(th)
+	if err != nil {
+		return nil, err
+	}
+	if tk.StandardClaims.Audience != Audience {
+		return nil, fmt.Errorf("audience field should be eKuiper, but got %s", tk.StandardClaims.Audience)
+	}
+	// Only the tokens issued by the server have the uuid id and can be revoked
+	if _, e := uuid.Parse(tk.Id); e == nil {
+		db, err := getTokenDb()
+		if err != nil {
+			return nil, err
+		}
+		info := &TokenInfo{}
+		found, err := db.Get(tk.Id, info)
+		if err != nil {
+			return nil, err
+		}
+		if found && info.Revoked {
+			return nil, fmt.Errorf("token %s has been revoked", tk.Id)
+		}
+	}
+	return tk, nil
+}
+
+// Principal returns the identity of the token owner. It is the subject of the token if set, otherwise the id of
+// the issued token or the issuer of the legacy token.
+func Principal(tk *jwt.Token) string {
+	switch {
+	case tk == nil:
+		return ""
+	case tk.Subject != "":
+		return tk.Subject
+	case tk.Id != "":
+		return tk.Id
+	default:
+		return tk.Issuer
+	}
+}
+
+// IssueToken signs a new token with the key configured by basic.authSignKey
+func IssueToken(req *TokenRequest) (*TokenInfo, string, error) {
+	signKey := conf.Config.Basic.AuthSignKey
+	if signKey == "" {
+		return nil, "", fmt.Errorf("token issuing is disabled, please set basic.authSignKey")
+	}
+	if !IsValidRole(req.Role) {
+		return nil, "", fmt.Errorf("invalid role %s, must be one of %s, %s and %s", req.Role, RoleViewer, RoleRuleOperator, RoleAdmin)
+	}
+	if err := ValidateScopes(req.Scopes); err != nil {
+		return nil, "", err
+	}
+	if req.Expire < 0 {
+		return nil, "", fmt.Errorf("expire must not be negative")
+	}
+	if req.Expire == 0 {
+		req.Expire = DefaultTokenExpire
+	}
+	db, err := getTokenDb()
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	expireAt := now.Add(time.Duration(req.Expire) * time.Second)
+	info := &TokenInfo{
+		Id:        uuid.New().String(),
+		Name:      req.Name,
+		Role:      req.Role,
+		Scopes:    req.Scopes,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expireAt.Unix(),
+	}
+	th, err := jwt.CreateScopedToken(signKey, signKey+".pub", Audience, info.Id, info.Name, info.Role, info.Scopes, expireAt)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := db.Setnx(info.Id, info); err != nil {
+		return nil, "", err
+	}
+	return info, th, nil
+}
+
+// ListTokens returns the issued tokens which are not expired
+func ListTokens() ([]*TokenInfo, error) {
+	db, err := getTokenDb()
+	if err != nil {
+		return nil, err
+	}
+	keys, err := db.Keys()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	result := make([]*TokenInfo, 0, len(keys))
+	for _, k := range keys {
+		info := &TokenInfo{}
+		found, err := db.Get(k, info)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+		// The expired tokens cannot be used anymore, so just clean them
+		if info.ExpiresAt < now {
+			_ = db.Delete(k)
+			continue
+		}
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].IssuedAt < result[j].IssuedAt
+	})
+	return result, nil
+}
+
+// RevokeToken marks the token as revoked so that it cannot be used anymore
+func RevokeToken(id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("token %s is not found", id))
+	}
+	db, err := getTokenDb()
+	if err != nil {
+		return err
+	}
+	info := &TokenInfo{}
+	found, err := db.Get(id, info)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("token %s is not found", id))
+	}
+	info.Revoked = true
+	return db.Set(id, info)
+}
@@ -0,0 +1,73 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/testx"
+)
+
+func TestTokenLifecycle(t *testing.T) {
+	testx.InitEnv()
+	conf.Config.Basic.AuthSignKey = ""
+	_, _, err := IssueToken(&TokenRequest{Role: RoleViewer})
+	assert.EqualError(t, err, "token issuing is disabled, please set basic.authSignKey")
+
+	conf.Config.Basic.AuthSignKey = "sample_key"
+	defer func() {
+		conf.Config.Basic.AuthSignKey = ""
+	}()
+	_, _, err = IssueToken(&TokenRequest{Role: "guest"})
+	assert.EqualError(t, err, "invalid role guest, must be one of viewer, ruleOperator and admin")
+	_, _, err = IssueToken(&TokenRequest{Role: RoleViewer, Scopes: []string{"rule"}})
+	assert.EqualError(t, err, "invalid scope rule, must be in the format of resource:action[:name]")
+
+	info, th, err := IssueToken(&TokenRequest{Name: "ci", Role: RoleRuleOperator, Scopes: []string{"rule:*"}})
+	require.NoError(t, err)
+	assert.Equal(t, "ci", info.Name)
+	assert.Equal(t, int64(DefaultTokenExpire), info.ExpiresAt-info.IssuedAt)
+
+	tk, err := Authenticate(th)
+	require.NoError(t, err)
+	assert.Equal(t, info.Id, tk.Id)
+	assert.Equal(t, RoleRuleOperator, tk.Role)
+	assert.Equal(t, []string{"rule:*"}, tk.Scopes)
+	assert.Equal(t, "ci", Principal(tk))
+
+	tokens, err := ListTokens()
+	require.NoError(t, err)
+	found := false
+	for _, token := range tokens {
+		if token.Id == info.Id {
+			found = true
+			assert.False(t, token.Revoked)
+		}
+	}
+	assert.True(t, found)
+
+	require.NoError(t, RevokeToken(info.Id))
+	_, err = Authenticate(th)
+	assert.EqualError(t, err, "token "+info.Id+" has been revoked")
+	assert.EqualError(t, RevokeToken("notexist"), "token notexist is not found")
+	assert.EqualError(t, RevokeToken("5b6a3ff4-0f5e-4c86-8a8b-2f56c3b2e4a1"), "token 5b6a3ff4-0f5e-4c86-8a8b-2f56c3b2e4a1 is not found")
+
+	_, err = Authenticate("")
+	assert.EqualError(t, err, "missing_token")
+}
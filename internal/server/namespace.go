@@ -0,0 +1,61 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lf-edge/ekuiper/internal/pkg/namespace"
+	"github.com/lf-edge/ekuiper/internal/processor"
+	"github.com/lf-edge/ekuiper/internal/server/middleware"
+)
+
+// namespaceOf returns the namespace in the rest path. It is empty for the plain routes which are served by the root
+// processors, so that the default namespace is kept as it was and the qualified names of all namespaces are accessible.
+func namespaceOf(r *http.Request) string {
+	return mux.Vars(r)["namespace"]
+}
+
+func streamProcessorOf(r *http.Request) *processor.StreamProcessor {
+	return streamProcessor.WithNamespace(namespaceOf(r))
+}
+
+func ruleProcessorOf(r *http.Request) *processor.RuleProcessor {
+	return ruleProcessor.WithNamespace(namespaceOf(r))
+}
+
+// ruleIdOf returns the runtime id of the rule in the rest path
+func ruleIdOf(r *http.Request) string {
+	return namespace.Qualify(namespaceOf(r), mux.Vars(r)["name"])
+}
+
+// namespaceRouter returns the sub router of the routes prefixed by /namespaces/{namespace}
+func namespaceRouter(r *mux.Router) *mux.Router {
+	nr := r.PathPrefix(middleware.NamespacePrefix).Subrouter()
+	nr.Use(namespaceMiddleware)
+	return nr
+}
+
+func namespaceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := namespace.Validate(namespaceOf(r)); err != nil {
+			handleError(w, err, "", logger)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,106 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/processor"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestNamespaceRoutes(t *testing.T) {
+	streamProcessor = processor.NewStreamProcessor()
+	ruleProcessor = processor.NewRuleProcessor()
+	rulesetProcessor = processor.NewRulesetProcessor(ruleProcessor, streamProcessor)
+	r := mux.NewRouter()
+	for _, router := range []*mux.Router{r, namespaceRouter(r)} {
+		router.HandleFunc("/streams", streamsHandler).Methods(http.MethodGet, http.MethodPost)
+		router.HandleFunc("/streams/{name}", streamHandler).Methods(http.MethodGet, http.MethodDelete)
+		router.HandleFunc("/rules", rulesHandler).Methods(http.MethodGet, http.MethodPost)
+		router.HandleFunc("/rules/{name}", ruleHandler).Methods(http.MethodGet, http.MethodDelete)
+		router.HandleFunc("/ruleset/export", exportHandler).Methods(http.MethodPost)
+	}
+	do := func(method, path, body string) (int, string) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, bytes.NewBufferString(body)))
+		return w.Code, w.Body.String()
+	}
+	list := func(path string) []string {
+		code, body := do(http.MethodGet, path, "")
+		require.Equal(t, http.StatusOK, code, body)
+		var result []string
+		require.NoError(t, json.Unmarshal([]byte(body), &result))
+		return result
+	}
+
+	// The same stream name in different namespaces
+	create := `{"sql":"CREATE stream nsDemo () WITH (DATASOURCE=\"nsDemo\", TYPE=\"memory\", FORMAT=\"json\")"}`
+	for _, ns := range []string{"nsTenant1", "nsTenant2"} {
+		code, body := do(http.MethodPost, "/namespaces/"+ns+"/streams", create)
+		require.Equal(t, http.StatusCreated, code, body)
+	}
+	defer func() {
+		_, _ = streamProcessor.DropStream("nsTenant1@nsDemo", ast.TypeStream)
+		_, _ = streamProcessor.DropStream("nsTenant2@nsDemo", ast.TypeStream)
+	}()
+	assert.Equal(t, []string{"nsDemo"}, list("/namespaces/nsTenant1/streams"))
+	assert.Contains(t, list("/streams"), "nsTenant1@nsDemo")
+	assert.NotContains(t, list("/namespaces/default/streams"), "nsTenant1@nsDemo")
+	code, _ := do(http.MethodGet, "/namespaces/nsTenant1/streams/nsDemo", "")
+	assert.Equal(t, http.StatusOK, code)
+	code, body := do(http.MethodGet, "/namespaces/nsTenant3/streams/nsDemo", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, body, "nsDemo is not found")
+	code, body = do(http.MethodGet, "/namespaces/bad@ns/streams", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "invalid namespace bad@ns, only letters, digits, underscore and hyphen are allowed\n", body)
+
+	// The rule resolves the stream in its namespace
+	rule := `{"id":"nsRule","sql":"SELECT * FROM nsDemo","actions":[{"log":{}}],"triggered":false}`
+	code, body = do(http.MethodPost, "/rules", rule)
+	assert.Equal(t, http.StatusBadRequest, code, body)
+	code, body = do(http.MethodPost, "/namespaces/nsTenant1/rules", rule)
+	require.Equal(t, http.StatusCreated, code, body)
+	assert.Equal(t, "Rule nsTenant1@nsRule was created successfully.", body)
+	code, body = do(http.MethodGet, "/namespaces/nsTenant1/rules/nsRule", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, rule, body)
+	code, _ = do(http.MethodGet, "/namespaces/nsTenant2/rules/nsRule", "")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, body = do(http.MethodPost, "/namespaces/nsTenant1/ruleset/export", "")
+	require.Equal(t, http.StatusOK, code)
+	exported := &processor.Ruleset{}
+	require.NoError(t, json.Unmarshal([]byte(body), exported))
+	assert.Len(t, exported.Streams, 1)
+	assert.Contains(t, exported.Streams, "nsDemo")
+	assert.Len(t, exported.Rules, 1)
+	assert.Contains(t, exported.Rules, "nsRule")
+
+	code, body = do(http.MethodDelete, "/namespaces/nsTenant1/rules/nsRule", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "Rule nsRule is dropped.", body)
+	_, err := ruleProcessor.GetRuleById("nsTenant1@nsRule")
+	assert.Error(t, err)
+}
@@ -37,6 +37,16 @@ var nativeManager *native.Manager
 
 func init() {
 	components["plugin"] = pluginComp{}
+	for _, t := range []plugin.PluginType{plugin.SOURCE, plugin.SINK, plugin.FUNCTION} {
+		pt := t
+		definitionGetters["plugin/"+plugin.PluginTypes[pt]] = func(_, name string) (interface{}, error) {
+			j, ok := nativeManager.GetPluginInfo(pt, name)
+			if !ok {
+				return nil, errorx.NewWithCode(errorx.NOT_FOUND, "not found")
+			}
+			return j, nil
+		}
+	}
 }
 
 type pluginComp struct{}
@@ -35,6 +35,13 @@ var portableManager *portable.Manager
 
 func init() {
 	components["portable"] = portableComp{}
+	definitionGetters["plugin/"+plugin.PluginTypes[plugin.PORTABLE]] = func(_, name string) (interface{}, error) {
+		j, ok := portableManager.GetPluginInfo(name)
+		if !ok {
+			return nil, errorx.NewWithCode(errorx.NOT_FOUND, "not found")
+		}
+		return j, nil
+	}
 }
 
 type portableComp struct{}
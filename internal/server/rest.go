@@ -141,34 +141,53 @@ func createRestServer(ip string, port int, needToken bool) *http.Server {
 	r := mux.NewRouter()
 	r.HandleFunc("/", rootHandler).Methods(http.MethodGet, http.MethodPost)
 	r.HandleFunc("/ping", pingHandler).Methods(http.MethodGet)
-	r.HandleFunc("/streams", streamsHandler).Methods(http.MethodGet, http.MethodPost)
-	r.HandleFunc("/streams/{name}", streamHandler).Methods(http.MethodGet, http.MethodDelete, http.MethodPut)
-	r.HandleFunc("/streams/{name}/schema", streamSchemaHandler).Methods(http.MethodGet)
-	r.HandleFunc("/tables", tablesHandler).Methods(http.MethodGet, http.MethodPost)
-	r.HandleFunc("/tables/{name}", tableHandler).Methods(http.MethodGet, http.MethodDelete, http.MethodPut)
-	r.HandleFunc("/tables/{name}/schema", tableSchemaHandler).Methods(http.MethodGet)
-	r.HandleFunc("/rules", rulesHandler).Methods(http.MethodGet, http.MethodPost)
-	r.HandleFunc("/rules/{name}", ruleHandler).Methods(http.MethodDelete, http.MethodGet, http.MethodPut)
-	r.HandleFunc("/rules/{name}/status", getStatusRuleHandler).Methods(http.MethodGet)
-	r.HandleFunc("/rules/{name}/start", startRuleHandler).Methods(http.MethodPost)
-	r.HandleFunc("/rules/{name}/stop", stopRuleHandler).Methods(http.MethodPost)
-	r.HandleFunc("/rules/{name}/restart", restartRuleHandler).Methods(http.MethodPost)
-	r.HandleFunc("/rules/{name}/topo", getTopoRuleHandler).Methods(http.MethodGet)
-	r.HandleFunc("/rules/validate", validateRuleHandler).Methods(http.MethodPost)
-	r.HandleFunc("/ruleset/export", exportHandler).Methods(http.MethodPost)
-	r.HandleFunc("/ruleset/import", importHandler).Methods(http.MethodPost)
+	r.HandleFunc("/ready", readyHandler).Methods(http.MethodGet)
+	// The definitions of a namespace are managed by the same routes prefixed by /namespaces/{namespace}
+	for _, router := range []*mux.Router{r, namespaceRouter(r)} {
+		router.HandleFunc("/streams", streamsHandler).Methods(http.MethodGet, http.MethodPost)
+		router.HandleFunc("/streams/{name}", streamHandler).Methods(http.MethodGet, http.MethodDelete, http.MethodPut)
+		router.HandleFunc("/streams/{name}/schema", streamSchemaHandler).Methods(http.MethodGet)
+		router.HandleFunc("/tables", tablesHandler).Methods(http.MethodGet, http.MethodPost)
+		router.HandleFunc("/tables/{name}", tableHandler).Methods(http.MethodGet, http.MethodDelete, http.MethodPut)
+		router.HandleFunc("/tables/{name}/schema", tableSchemaHandler).Methods(http.MethodGet)
+		router.HandleFunc("/rules", rulesHandler).Methods(http.MethodGet, http.MethodPost)
+		router.HandleFunc("/rules/{name}", ruleHandler).Methods(http.MethodDelete, http.MethodGet, http.MethodPut)
+		router.HandleFunc("/rules/{name}/status", getStatusRuleHandler).Methods(http.MethodGet)
+		router.HandleFunc("/rules/{name}/start", startRuleHandler).Methods(http.MethodPost)
+		router.HandleFunc("/rules/{name}/stop", stopRuleHandler).Methods(http.MethodPost)
+		router.HandleFunc("/rules/{name}/restart", restartRuleHandler).Methods(http.MethodPost)
+		router.HandleFunc("/rules/{name}/topo", getTopoRuleHandler).Methods(http.MethodGet)
+		router.HandleFunc("/rules/{name}/health", ruleHealthHandler).Methods(http.MethodGet)
+		router.HandleFunc("/rules/{name}/nodes/{node}/tap", tapHandler).Methods(http.MethodGet)
+		router.HandleFunc("/rules/{name}/savepoints", savepointsHandler).Methods(http.MethodGet, http.MethodPost)
+		router.HandleFunc("/rules/{name}/savepoints/import", savepointImportHandler).Methods(http.MethodPost)
+		router.HandleFunc("/rules/{name}/savepoints/{savepoint}", savepointHandler).Methods(http.MethodDelete)
+		router.HandleFunc("/rules/{name}/savepoints/{savepoint}/export", savepointExportHandler).Methods(http.MethodGet)
+		router.HandleFunc("/rules/{name}/savepoints/{savepoint}/restore", savepointRestoreHandler).Methods(http.MethodPost)
+		router.HandleFunc("/rules/validate", validateRuleHandler).Methods(http.MethodPost)
+		router.HandleFunc("/ruleset/export", exportHandler).Methods(http.MethodPost)
+		router.HandleFunc("/ruleset/import", importHandler).Methods(http.MethodPost)
+	}
 	r.HandleFunc("/configs", configurationUpdateHandler).Methods(http.MethodPatch)
 	r.HandleFunc("/config/uploads", fileUploadHandler).Methods(http.MethodPost, http.MethodGet)
 	r.HandleFunc("/config/uploads/{name}", fileDeleteHandler).Methods(http.MethodDelete)
 	r.HandleFunc("/data/export", configurationExportHandler).Methods(http.MethodGet, http.MethodPost)
 	r.HandleFunc("/data/import", configurationImportHandler).Methods(http.MethodPost)
 	r.HandleFunc("/data/import/status", configurationStatusHandler).Methods(http.MethodGet)
+	r.HandleFunc("/tokens", tokensHandler).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/tokens/{id}", tokenHandler).Methods(http.MethodDelete)
+	r.HandleFunc("/audit", auditHandler).Methods(http.MethodGet)
+	r.HandleFunc("/connections", connectionsHandler).Methods(http.MethodGet)
+	r.HandleFunc("/connections/{type}/{name}", connectionHandler).Methods(http.MethodGet)
+	r.HandleFunc("/connections/{type}/{name}/test", connectionTestHandler).Methods(http.MethodPost)
 	// Register extended routes
 	for k, v := range components {
 		logger.Infof("register rest endpoint for component %s", k)
 		v.rest(r)
 	}
 
+	// The audit middleware runs before auth to record the denied requests too
+	r.Use(auditMiddleware(needToken))
 	if needToken {
 		r.Use(middleware.Auth)
 	}
@@ -334,6 +353,51 @@ func fileDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("ok"))
 }
 
+type tokenResponse struct {
+	*middleware.TokenInfo
+	Token string `json:"token"`
+}
+
+// issue a scoped token or list the issued tokens
+func tokensHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	switch r.Method {
+	case http.MethodPost:
+		req := &middleware.TokenRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			handleError(w, err, "Invalid body", logger)
+			return
+		}
+		info, th, err := middleware.IssueToken(req)
+		if err != nil {
+			handleError(w, err, "Issue token error", logger)
+			return
+		}
+		w.Header().Add(ContentType, ContentTypeJSON)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(&tokenResponse{TokenInfo: info, Token: th})
+	case http.MethodGet:
+		tokens, err := middleware.ListTokens()
+		if err != nil {
+			handleError(w, err, "List tokens error", logger)
+			return
+		}
+		jsonResponse(tokens, w, logger)
+	}
+}
+
+// revoke an issued token
+func tokenHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	id := mux.Vars(r)["id"]
+	if err := middleware.RevokeToken(id); err != nil {
+		handleError(w, err, "Revoke token error", logger)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Token %s is revoked.", id)
+}
+
 type information struct {
 	Version       string `json:"version"`
 	Os            string `json:"os"`
@@ -381,9 +445,9 @@ func sourcesManageHandler(w http.ResponseWriter, r *http.Request, st ast.StreamT
 			}
 		}
 		if kind != "" {
-			content, err = streamProcessor.ShowTable(kind)
+			content, err = streamProcessorOf(r).ShowTable(kind)
 		} else {
-			content, err = streamProcessor.ShowStream(st)
+			content, err = streamProcessorOf(r).ShowStream(st)
 		}
 		if err != nil {
 			handleError(w, err, fmt.Sprintf("%s command error", cases.Title(language.Und).String(ast.StreamTypeMap[st])), logger)
@@ -396,7 +460,7 @@ func sourcesManageHandler(w http.ResponseWriter, r *http.Request, st ast.StreamT
 			handleError(w, err, "Invalid body", logger)
 			return
 		}
-		content, err := streamProcessor.ExecStreamSql(v.Sql)
+		content, err := streamProcessorOf(r).ExecStreamSql(v.Sql)
 		if err != nil {
 			handleError(w, err, fmt.Sprintf("%s command error", cases.Title(language.Und).String(ast.StreamTypeMap[st])), logger)
 			return
@@ -413,14 +477,14 @@ func sourceManageHandler(w http.ResponseWriter, r *http.Request, st ast.StreamTy
 
 	switch r.Method {
 	case http.MethodGet:
-		content, err := streamProcessor.DescStream(name, st)
+		content, err := streamProcessorOf(r).DescStream(name, st)
 		if err != nil {
 			handleError(w, err, fmt.Sprintf("describe %s error", ast.StreamTypeMap[st]), logger)
 			return
 		}
 		jsonResponse(content, w, logger)
 	case http.MethodDelete:
-		content, err := streamProcessor.DropStream(name, st)
+		content, err := streamProcessorOf(r).DropStream(name, st)
 		if err != nil {
 			handleError(w, err, fmt.Sprintf("delete %s error", ast.StreamTypeMap[st]), logger)
 			return
@@ -433,7 +497,7 @@ func sourceManageHandler(w http.ResponseWriter, r *http.Request, st ast.StreamTy
 			handleError(w, err, "Invalid body", logger)
 			return
 		}
-		content, err := streamProcessor.ExecReplaceStream(name, v.Sql, st)
+		content, err := streamProcessorOf(r).ExecReplaceStream(name, v.Sql, st)
 		if err != nil {
 			handleError(w, err, fmt.Sprintf("%s command error", cases.Title(language.Und).String(ast.StreamTypeMap[st])), logger)
 			return
@@ -473,7 +537,7 @@ func tableSchemaHandler(w http.ResponseWriter, r *http.Request) {
 func sourceSchemaHandler(w http.ResponseWriter, r *http.Request, st ast.StreamType) {
 	vars := mux.Vars(r)
 	name := vars["name"]
-	content, err := streamProcessor.GetInferredJsonSchema(name, st)
+	content, err := streamProcessorOf(r).GetInferredJsonSchema(name, st)
 	if err != nil {
 		handleError(w, err, fmt.Sprintf("get schema of %s error", ast.StreamTypeMap[st]), logger)
 		return
@@ -491,7 +555,7 @@ func rulesHandler(w http.ResponseWriter, r *http.Request) {
 			handleError(w, err, "Invalid body", logger)
 			return
 		}
-		id, err := createRule("", string(body))
+		id, err := createRule(namespaceOf(r), "", string(body))
 		if err != nil {
 			handleError(w, err, "", logger)
 			return
@@ -499,7 +563,7 @@ func rulesHandler(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusCreated)
 		fmt.Fprintf(w, "Rule %s was created successfully.", id)
 	case http.MethodGet:
-		content, err := getAllRulesWithStatus()
+		content, err := getAllRulesWithStatus(namespaceOf(r))
 		if err != nil {
 			handleError(w, err, "Show rules error", logger)
 			return
@@ -516,7 +580,7 @@ func ruleHandler(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodGet:
-		rule, err := ruleProcessor.GetRuleJson(name)
+		rule, err := ruleProcessorOf(r).GetRuleJson(name)
 		if err != nil {
 			handleError(w, err, "Describe rule error", logger)
 			return
@@ -524,8 +588,8 @@ func ruleHandler(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add(ContentType, ContentTypeJSON)
 		w.Write([]byte(rule))
 	case http.MethodDelete:
-		deleteRule(name)
-		content, err := ruleProcessor.ExecDrop(name)
+		deleteRule(ruleIdOf(r))
+		content, err := ruleProcessorOf(r).ExecDrop(name)
 		if err != nil {
 			handleError(w, err, "Delete rule error", logger)
 			return
@@ -533,7 +597,7 @@ func ruleHandler(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(content))
 	case http.MethodPut:
-		_, err := ruleProcessor.GetRuleById(name)
+		_, err := ruleProcessorOf(r).GetRuleById(name)
 		if err != nil {
 			handleError(w, err, "Rule not found", logger)
 			return
@@ -544,29 +608,31 @@ func ruleHandler(w http.ResponseWriter, r *http.Request) {
 			handleError(w, err, "Invalid body", logger)
 			return
 		}
-		err = updateRule(name, string(body))
+		m, err := updateRule(ruleIdOf(r), string(body))
 		if err != nil {
 			handleError(w, err, "Update rule error", logger)
 			return
 		}
 		// Update to db after validation
-		_, err = ruleProcessor.ExecUpdate(name, string(body))
+		_, err = ruleProcessorOf(r).ExecUpdate(name, string(body))
 		if err != nil {
 			handleError(w, err, "Update rule error, suggest to delete it and recreate", logger)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, "Rule %s was updated successfully.", name)
+		if lost := m.String(); lost != "" {
+			fmt.Fprintf(w, "Rule %s was updated successfully. %s", name, lost)
+		} else {
+			fmt.Fprintf(w, "Rule %s was updated successfully.", name)
+		}
 	}
 }
 
 // get status of a rule
 func getStatusRuleHandler(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
-	vars := mux.Vars(r)
-	name := vars["name"]
 
-	content, err := getRuleStatus(name)
+	content, err := getRuleStatus(ruleIdOf(r))
 	if err != nil {
 		handleError(w, err, "get rule status error", logger)
 		return
@@ -582,7 +648,7 @@ func startRuleHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["name"]
 
-	err := startRule(name)
+	err := startRule(ruleIdOf(r))
 	if err != nil {
 		handleError(w, err, "start rule error", logger)
 		return
@@ -594,10 +660,8 @@ func startRuleHandler(w http.ResponseWriter, r *http.Request) {
 // stop a rule
 func stopRuleHandler(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
-	vars := mux.Vars(r)
-	name := vars["name"]
 
-	result := stopRule(name)
+	result := stopRule(ruleIdOf(r))
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(result))
 }
@@ -608,7 +672,7 @@ func restartRuleHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["name"]
 
-	err := restartRule(name)
+	err := restartRule(ruleIdOf(r))
 	if err != nil {
 		handleError(w, err, "restart rule error", logger)
 		return
@@ -620,10 +684,8 @@ func restartRuleHandler(w http.ResponseWriter, r *http.Request) {
 // get topo of a rule
 func getTopoRuleHandler(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
-	vars := mux.Vars(r)
-	name := vars["name"]
 
-	content, err := getRuleTopo(name)
+	content, err := getRuleTopo(ruleIdOf(r))
 	if err != nil {
 		handleError(w, err, "get rule topo error", logger)
 		return
@@ -685,14 +747,14 @@ func importHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		content = buf.Bytes()
 	}
-	rules, counts, err := rulesetProcessor.Import(content)
+	rules, counts, err := rulesetProcessor.WithNamespace(namespaceOf(r)).Import(content)
 	if err != nil {
 		handleError(w, nil, "Import ruleset error", logger)
 		return
 	}
 	infra.SafeRun(func() error {
 		for _, name := range rules {
-			rul, ee := ruleProcessor.GetRuleById(name)
+			rul, ee := ruleProcessorOf(r).GetRuleById(name)
 			if ee != nil {
 				logger.Error(ee)
 				continue
@@ -709,7 +771,7 @@ func importHandler(w http.ResponseWriter, r *http.Request) {
 
 func exportHandler(w http.ResponseWriter, r *http.Request) {
 	const name = "ekuiper_export.json"
-	exported, _, err := rulesetProcessor.Export()
+	exported, _, err := rulesetProcessor.WithNamespace(namespaceOf(r)).Export()
 	if err != nil {
 		handleError(w, err, "export error", logger)
 		return
@@ -1191,7 +1253,7 @@ func importRuleSetPartial(all processor.Ruleset) processor.Ruleset {
 		_, err := ruleProcessor.GetRuleJson(k)
 		if err == nil {
 			// the rule already exist, update
-			err = updateRule(k, v)
+			_, err = updateRule(k, v)
 			if err != nil {
 				ruleSetRsp.Rules[k] = err.Error()
 				continue
@@ -1205,7 +1267,7 @@ func importRuleSetPartial(all processor.Ruleset) processor.Ruleset {
 			}
 		} else {
 			// not found, create
-			_, err2 := createRule(k, v)
+			_, err2 := createRule("", k, v)
 			if err2 != nil {
 				ruleSetRsp.Rules[k] = err2.Error()
 				continue
@@ -59,12 +59,13 @@ func (r *rpcComp) serve() {
 	if err != nil {
 		logger.Fatal("Format of service Server isn'restHttpType correct. ", err)
 	}
+	handler := &rpcHandler{srv: rpcSrv, authenticate: conf.Config.Basic.Authentication}
 	srvRpc := &http.Server{
 		Addr:         cast.JoinHostPortInt(ipRpc, portRpc),
 		WriteTimeout: time.Second * 15,
 		ReadTimeout:  time.Second * 15,
 		IdleTimeout:  time.Second * 60,
-		Handler:      rpcSrv,
+		Handler:      handler,
 	}
 	r.s = srvRpc
 	go func() {
@@ -147,7 +148,7 @@ func (t *Server) Stream(stream string, reply *string) error {
 }
 
 func (t *Server) CreateRule(rule *model.RPCArgDesc, reply *string) error {
-	id, err := createRule(rule.Name, rule.Json)
+	id, err := createRule("", rule.Name, rule.Json)
 	if err != nil {
 		return fmt.Errorf("Create rule %s error : %s.", id, err)
 	} else {
@@ -213,7 +214,7 @@ func (t *Server) DescRule(name string, reply *string) error {
 }
 
 func (t *Server) ShowRules(_ int, reply *string) error {
-	r, err := getAllRulesWithStatus()
+	r, err := getAllRulesWithStatus("")
 	if err != nil {
 		return fmt.Errorf("Show rule error : %s.", err)
 	}
@@ -0,0 +1,305 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build rpc || !core
+// +build rpc !core
+
+package server
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"net/http"
+	"net/rpc"
+	"strings"
+	"sync"
+
+	"github.com/lf-edge/ekuiper/internal/pkg/jwt"
+	"github.com/lf-edge/ekuiper/internal/pkg/model"
+	"github.com/lf-edge/ekuiper/internal/plugin"
+	"github.com/lf-edge/ekuiper/internal/server/audit"
+	"github.com/lf-edge/ekuiper/internal/server/middleware"
+)
+
+const rpcConnected = "200 Connected to Go RPC"
+
+func perm(resource, action string) middleware.Permission {
+	return middleware.Permission{Resource: resource, Action: action}
+}
+
+// rpcPermissions maps the rpc methods to the permissions like the corresponding rest api.
+// The methods not listed here require the admin role.
+var rpcPermissions = map[string]middleware.Permission{
+	"Server.CreateQuery":         perm("rule", middleware.ActionWrite),
+	"Server.GetQueryResult":      perm("rule", middleware.ActionRead),
+	"Server.CreateRule":          perm("rule", middleware.ActionWrite),
+	"Server.GetStatusRule":       perm("rule", middleware.ActionRead),
+	"Server.GetTopoRule":         perm("rule", middleware.ActionRead),
+	"Server.StartRule":           perm("rule", middleware.ActionWrite),
+	"Server.StopRule":            perm("rule", middleware.ActionWrite),
+	"Server.RestartRule":         perm("rule", middleware.ActionWrite),
+	"Server.DescRule":            perm("rule", middleware.ActionRead),
+	"Server.ShowRules":           perm("rule", middleware.ActionRead),
+	"Server.DropRule":            perm("rule", middleware.ActionWrite),
+	"Server.ValidateRule":        perm("rule", middleware.ActionRead),
+	"Server.Import":              perm("ruleset", middleware.ActionWrite),
+	"Server.Export":              perm("ruleset", middleware.ActionRead),
+	"Server.ImportConfiguration": perm("data", middleware.ActionWrite),
+	"Server.GetStatusImport":     perm("data", middleware.ActionRead),
+	"Server.ExportConfiguration": perm("data", middleware.ActionRead),
+	"Server.CreatePlugin":        perm("plugin", middleware.ActionWrite),
+	"Server.RegisterPlugin":      perm("plugin", middleware.ActionWrite),
+	"Server.DropPlugin":          perm("plugin", middleware.ActionWrite),
+	"Server.DescPlugin":          perm("plugin", middleware.ActionRead),
+	"Server.ShowPlugins":         perm("plugin", middleware.ActionRead),
+	"Server.DescUdf":             perm("plugin", middleware.ActionRead),
+	"Server.ShowUdfs":            perm("plugin", middleware.ActionRead),
+	"Server.CreateSchema":        perm("schema", middleware.ActionWrite),
+	"Server.DropSchema":          perm("schema", middleware.ActionWrite),
+	"Server.DescSchema":          perm("schema", middleware.ActionRead),
+	"Server.ShowSchemas":         perm("schema", middleware.ActionRead),
+	"Server.CreateService":       perm("service", middleware.ActionWrite),
+	"Server.DropService":         perm("service", middleware.ActionWrite),
+	"Server.DescService":         perm("service", middleware.ActionRead),
+	"Server.DescServiceFunc":     perm("service", middleware.ActionRead),
+	"Server.ShowServices":        perm("service", middleware.ActionRead),
+	"Server.ShowServiceFuncs":    perm("service", middleware.ActionRead),
+}
+
+// rpcPermission returns the permission of the rpc call according to the method and its argument
+func rpcPermission(method string, arg interface{}) middleware.Permission {
+	if method == "Server.Stream" {
+		if s, ok := arg.(*string); ok {
+			return streamPermission(*s)
+		}
+	}
+	p, ok := rpcPermissions[method]
+	if !ok {
+		return perm("rpc", method)
+	}
+	switch a := arg.(type) {
+	case *string:
+		p.Name = *a
+	case *model.RPCArgDesc:
+		p.Name = a.Name
+	case *model.RPCTypedArgDesc:
+		p.Name = a.Name
+	case *model.PluginDesc:
+		p.Name = a.Name
+	}
+	return p
+}
+
+// streamPermission infers the permission from the stream statement like "create stream demo ()" or "show tables"
+func streamPermission(stmt string) middleware.Permission {
+	fields := strings.Fields(strings.ToLower(stmt))
+	p := perm("stream", middleware.ActionWrite)
+	if len(fields) > 0 && (fields[0] == "show" || fields[0] == "describe" || fields[0] == "desc") {
+		p.Action = middleware.ActionRead
+	}
+	if len(fields) > 1 && strings.HasPrefix(fields[1], "table") {
+		p.Resource = "table"
+	}
+	if len(fields) > 2 {
+		p.Name = strings.SplitN(strings.Fields(stmt)[2], "(", 2)[0]
+	}
+	return p
+}
+
+// rpcResourceType returns the type of the resource in the rpc argument like the schema type or plugin type
+func rpcResourceType(arg interface{}) string {
+	switch a := arg.(type) {
+	case *model.RPCTypedArgDesc:
+		return a.Type
+	case *model.PluginDesc:
+		if a.Type >= 0 && a.Type < len(plugin.PluginTypes) {
+			return plugin.PluginTypes[a.Type]
+		}
+	}
+	return ""
+}
+
+// rpcArgContent returns the content of the rpc argument which is saved as the after definition in the audit log
+// if the definition cannot be found, such as the dropped resources.
+func rpcArgContent(arg interface{}) string {
+	switch a := arg.(type) {
+	case *string:
+		return *a
+	case *model.RPCArgDesc:
+		return a.Json
+	case *model.RPCTypedArgDesc:
+		return a.Json
+	case *model.PluginDesc:
+		return a.Json
+	case *model.ImportDataDesc:
+		return a.FileName
+	}
+	return ""
+}
+
+// rpcHandler serves the rpc over http like rpc.Server and records the mutating calls into the audit log. If
+// authentication is enabled, it requires a valid token in the CONNECT request and checks the permission of each call.
+type rpcHandler struct {
+	srv          *rpc.Server
+	authenticate bool
+}
+
+func (h *rpcHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "CONNECT" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = io.WriteString(w, "405 must CONNECT\n")
+		return
+	}
+	var (
+		tk  *jwt.Token
+		err error
+	)
+	principal := anonymous
+	if h.authenticate {
+		tk, err = middleware.Authenticate(req.Header.Get("Authorization"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		principal = middleware.Principal(tk)
+	}
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		logger.Errorf("rpc hijacking %s: %v", req.RemoteAddr, err)
+		return
+	}
+	_, _ = io.WriteString(conn, "HTTP/1.0 "+rpcConnected+"\n\n")
+	buf := bufio.NewWriter(conn)
+	h.srv.ServeCodec(&serverCodec{
+		rwc:          conn,
+		dec:          gob.NewDecoder(conn),
+		enc:          gob.NewEncoder(buf),
+		encBuf:       buf,
+		authenticate: h.authenticate,
+		token:        tk,
+		principal:    principal,
+		pending:      make(map[uint64]*pendingAudit),
+	})
+}
+
+type pendingAudit struct {
+	record  *audit.Record
+	typ     string
+	content string
+}
+
+// serverCodec is the gob codec same as the default one of net/rpc. It checks the permission after reading
+// the request body so that the denied call gets an error response. The mutating calls are audited when the
+// response is written.
+type serverCodec struct {
+	rwc          io.ReadWriteCloser
+	dec          *gob.Decoder
+	enc          *gob.Encoder
+	encBuf       *bufio.Writer
+	closed       bool
+	authenticate bool
+	token        *jwt.Token
+	principal    string
+	method       string
+	seq          uint64
+	// the requests are read in one goroutine while the responses are written in the call goroutines
+	mu      sync.Mutex
+	pending map[uint64]*pendingAudit
+}
+
+func (c *serverCodec) ReadRequestHeader(r *rpc.Request) error {
+	err := c.dec.Decode(r)
+	c.method = r.ServiceMethod
+	c.seq = r.Seq
+	return err
+}
+
+func (c *serverCodec) ReadRequestBody(body interface{}) error {
+	if err := c.dec.Decode(body); err != nil {
+		return err
+	}
+	// body is nil when the server discards an invalid request
+	if body == nil {
+		return nil
+	}
+	p := rpcPermission(c.method, body)
+	if p.Action != middleware.ActionRead {
+		typ := rpcResourceType(body)
+		c.mu.Lock()
+		c.pending[c.seq] = &pendingAudit{
+			record: &audit.Record{
+				Principal: c.principal,
+				Source:    "rpc",
+				Operation: c.method,
+				Resource:  p.Resource,
+				Name:      p.Name,
+				Before:    getDefinition(p.Resource, typ, p.Name),
+			},
+			typ:     typ,
+			content: rpcArgContent(body),
+		}
+		c.mu.Unlock()
+	}
+	if c.authenticate {
+		if err := middleware.Authorize(c.token, p); err != nil {
+			return fmt.Errorf("forbidden: %v", err)
+		}
+	}
+	return nil
+}
+
+func (c *serverCodec) WriteResponse(r *rpc.Response, body interface{}) (err error) {
+	c.mu.Lock()
+	pa, ok := c.pending[r.Seq]
+	delete(c.pending, r.Seq)
+	c.mu.Unlock()
+	if ok {
+		rec := pa.record
+		if r.Error == "" {
+			rec.Result = audit.ResultSuccess
+		} else {
+			rec.Result = audit.ResultFailure
+			rec.Error = truncate(r.Error)
+		}
+		rec.After = getDefinition(rec.Resource, pa.typ, rec.Name)
+		if rec.After == "" && pa.content != rec.Name {
+			rec.After = pa.content
+		}
+		saveAudit(rec)
+	}
+	if err = c.enc.Encode(r); err != nil {
+		if c.encBuf.Flush() == nil {
+			_ = c.Close()
+		}
+		return
+	}
+	if err = c.enc.Encode(body); err != nil {
+		if c.encBuf.Flush() == nil {
+			_ = c.Close()
+		}
+		return
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *serverCodec) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.rwc.Close()
+}
@@ -0,0 +1,120 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build rpc || !core
+// +build rpc !core
+
+package server
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/rpc"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/pkg/jwt"
+	"github.com/lf-edge/ekuiper/internal/pkg/model"
+	"github.com/lf-edge/ekuiper/internal/server/audit"
+	"github.com/lf-edge/ekuiper/internal/server/middleware"
+)
+
+func TestRpcPermission(t *testing.T) {
+	name := "rule1"
+	stmt := "create stream demo (temp float) WITH (DATASOURCE=\"demo\")"
+	showTables := "show tables"
+	tests := []struct {
+		method string
+		arg    interface{}
+		p      middleware.Permission
+	}{
+		{"Server.StartRule", &name, middleware.Permission{Resource: "rule", Action: middleware.ActionWrite, Name: "rule1"}},
+		{"Server.CreateRule", &model.RPCArgDesc{Name: "rule2"}, middleware.Permission{Resource: "rule", Action: middleware.ActionWrite, Name: "rule2"}},
+		{"Server.ShowPlugins", new(int), middleware.Permission{Resource: "plugin", Action: middleware.ActionRead}},
+		{"Server.Stream", &stmt, middleware.Permission{Resource: "stream", Action: middleware.ActionWrite, Name: "demo"}},
+		{"Server.Stream", &showTables, middleware.Permission{Resource: "table", Action: middleware.ActionRead}},
+		{"Server.Unknown", &name, middleware.Permission{Resource: "rpc", Action: "Server.Unknown"}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.p, rpcPermission(tt.method, tt.arg), tt.method)
+	}
+}
+
+type mockRpcServer int
+
+func (s *mockRpcServer) ShowRules(_ int, reply *string) error {
+	*reply = "rules"
+	return nil
+}
+
+func (s *mockRpcServer) DropRule(name string, reply *string) error {
+	*reply = "dropped " + name
+	return nil
+}
+
+func dialWithToken(t *testing.T, address, token string) (*rpc.Client, string) {
+	conn, err := net.Dial("tcp", address)
+	require.NoError(t, err)
+	_, err = io.WriteString(conn, "CONNECT "+rpc.DefaultRPCPath+" HTTP/1.0\nAuthorization: "+token+"\n\n")
+	require.NoError(t, err)
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: "CONNECT"})
+	require.NoError(t, err)
+	if resp.Status != rpcConnected {
+		_ = conn.Close()
+		return nil, resp.Status
+	}
+	return rpc.NewClient(conn), resp.Status
+}
+
+func TestRpcAuthHandler(t *testing.T) {
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName("Server", new(mockRpcServer)))
+	from := time.Now().UnixMilli()
+	ts := httptest.NewServer(&rpcHandler{srv: srv, authenticate: true})
+	defer ts.Close()
+	address := strings.TrimPrefix(ts.URL, "http://")
+
+	_, status := dialWithToken(t, address, "")
+	assert.Equal(t, "401 Unauthorized", status)
+
+	th, err := jwt.CreateScopedToken("sample_key", "sample_key.pub", middleware.Audience, "", "ci", middleware.RoleViewer, nil, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	client, status := dialWithToken(t, address, th)
+	require.Equal(t, rpcConnected, status)
+	defer client.Close()
+	var reply string
+	require.NoError(t, client.Call("Server.ShowRules", 0, &reply))
+	assert.Equal(t, "rules", reply)
+	err = client.Call("Server.DropRule", "rule1", &reply)
+	assert.EqualError(t, err, "forbidden: role viewer has no permission rule:write:rule1")
+	// The connection is still usable after a denied call
+	require.NoError(t, client.Call("Server.ShowRules", 0, &reply))
+
+	records, err := audit.Query(&audit.Filter{From: from, Resource: "rule"})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "ci", records[0].Principal)
+	assert.Equal(t, "rpc", records[0].Source)
+	assert.Equal(t, "Server.DropRule", records[0].Operation)
+	assert.Equal(t, "rule1", records[0].Name)
+	assert.Equal(t, audit.ResultFailure, records[0].Result)
+	assert.Equal(t, "forbidden: role viewer has no permission rule:write:rule1", records[0].Error)
+}
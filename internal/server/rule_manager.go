@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/namespace"
 	"github.com/lf-edge/ekuiper/internal/topo/rule"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/cast"
@@ -60,6 +61,17 @@ func (rr *RuleRegistry) Load(key string) (value *rule.RuleState, ok bool) {
 	return result, ok
 }
 
+// Keys returns the ids of all the rules in runtime
+func (rr *RuleRegistry) Keys() []string {
+	rr.RLock()
+	defer rr.RUnlock()
+	keys := make([]string, 0, len(rr.internal))
+	for k := range rr.internal {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
 // Delete Atomic get and delete. Only run when deleting a rule in runtime.
 func (rr *RuleRegistry) Delete(key string) (*rule.RuleState, bool) {
 	rr.Lock()
@@ -71,12 +83,13 @@ func (rr *RuleRegistry) Delete(key string) (*rule.RuleState, bool) {
 	return result, ok
 }
 
-func createRule(name, ruleJson string) (string, error) {
+// createRule creates the rule in the namespace. The returned id is the runtime id qualified by the namespace.
+func createRule(ns, name, ruleJson string) (string, error) {
 	var rs *rule.RuleState = nil
 	var err error = nil
 
 	// Validate the rule json
-	r, err := ruleProcessor.GetRuleByJson(name, ruleJson)
+	r, err := ruleProcessor.WithNamespace(ns).GetRuleByJson(name, ruleJson)
 	if err != nil {
 		return "", fmt.Errorf("invalid rule json: %v", err)
 	}
@@ -152,21 +165,22 @@ func recoverRule(r *api.Rule) string {
 	return fmt.Sprintf("Rule %s was started.", r.Id)
 }
 
-func updateRule(ruleId, ruleJson string) error {
+// updateRule updates the running rule and returns how the operator states are migrated
+func updateRule(ruleId, ruleJson string) (*rule.StateMigration, error) {
 	// Validate the rule json
 	r, err := ruleProcessor.GetRuleByJson(ruleId, ruleJson)
 	if err != nil {
-		return fmt.Errorf("Invalid rule json: %v", err)
+		return nil, fmt.Errorf("Invalid rule json: %v", err)
 	}
 	if rs, ok := registry.Load(r.Id); ok {
-		err := rs.UpdateTopo(r)
+		m, err := rs.UpdateTopo(r)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		err = ruleProcessor.ExecReplaceRuleState(rs.RuleId, r.Triggered)
-		return err
+		return m, err
 	} else {
-		return fmt.Errorf("Rule %s registry not found, try to delete it and recreate", r.Id)
+		return nil, fmt.Errorf("Rule %s registry not found, try to delete it and recreate", r.Id)
 	}
 }
 
@@ -199,7 +213,8 @@ func reRunRule(name string, isInternal bool) error {
 				return err
 			}
 		}
-		return rs.UpdateTopo(rs.Rule)
+		_, err := rs.UpdateTopo(rs.Rule)
+		return err
 	}
 }
 
@@ -272,8 +287,9 @@ func getRuleStatus(name string) (string, error) {
 	}
 }
 
-func getAllRulesWithStatus() ([]map[string]interface{}, error) {
-	ruleIds, err := ruleProcessor.GetAllRules()
+// getAllRulesWithStatus lists the rules in the namespace or all rules if the namespace is empty
+func getAllRulesWithStatus(ns string) ([]map[string]interface{}, error) {
+	ruleIds, err := ruleProcessor.WithNamespace(ns).GetAllRules()
 	if err != nil {
 		return nil, err
 	}
@@ -281,11 +297,11 @@ func getAllRulesWithStatus() ([]map[string]interface{}, error) {
 	result := make([]map[string]interface{}, len(ruleIds))
 	for i, id := range ruleIds {
 		ruleName := id
-		rule, _ := ruleProcessor.GetRuleById(id)
+		rule, _ := ruleProcessor.WithNamespace(ns).GetRuleById(id)
 		if rule != nil && rule.Name != "" {
 			ruleName = rule.Name
 		}
-		s, err := getRuleState(id)
+		s, err := getRuleState(namespace.Qualify(ns, id))
 		if err != nil {
 			s = fmt.Sprintf("error: %s", err)
 		}
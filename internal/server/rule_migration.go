@@ -23,6 +23,7 @@ import (
 	"github.com/lf-edge/ekuiper/internal/binder/function"
 	"github.com/lf-edge/ekuiper/internal/binder/io"
 	"github.com/lf-edge/ekuiper/internal/meta"
+	"github.com/lf-edge/ekuiper/internal/pkg/namespace"
 	store2 "github.com/lf-edge/ekuiper/internal/pkg/store"
 	"github.com/lf-edge/ekuiper/internal/plugin"
 	"github.com/lf-edge/ekuiper/internal/processor"
@@ -78,6 +79,8 @@ func ruleTraverse(rule *api.Rule, de *dependencies) {
 		if err != nil {
 			return
 		}
+		ns := namespace.Of(rule.Id)
+		store = namespace.KV(store, ns)
 		// streams
 		streamsFromStmt := xsql.GetStreams(stmt)
 		for _, s := range streamsFromStmt {
@@ -87,11 +90,12 @@ func ruleTraverse(rule *api.Rule, de *dependencies) {
 			}
 			if streamStmt.StreamType == ast.TypeStream {
 				// get streams
-				de.streams = append(de.streams, string(streamStmt.Name))
+				de.streams = append(de.streams, namespace.Qualify(ns, string(streamStmt.Name)))
 			} else if streamStmt.StreamType == ast.TypeTable {
 				// get tables
-				de.tables = append(de.tables, string(streamStmt.Name))
+				de.tables = append(de.tables, namespace.Qualify(ns, string(streamStmt.Name)))
 			}
+			namespace.QualifyOptions(ns, streamStmt.Options)
 
 			// get source type
 			de.sources = append(de.sources, streamStmt.Options.TYPE)
@@ -0,0 +1,162 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lf-edge/ekuiper/internal/topo/rule"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+)
+
+// createSavepoint saves the current states of a rule as a savepoint
+func createSavepoint(ruleId, name string) error {
+	if err := state.ValidateSavepointName(name); err != nil {
+		return err
+	}
+	rs, ok := registry.Load(ruleId)
+	if !ok {
+		return fmt.Errorf("Rule %s is not found in registry, please check if it is created", ruleId)
+	}
+	states, err := rs.Savepoint()
+	if err != nil {
+		return err
+	}
+	sp, err := state.NewSavepoint(ruleId, name, states)
+	if err != nil {
+		return err
+	}
+	return state.SaveSavepoint(sp)
+}
+
+// restoreSavepoint starts the rule with the states of a savepoint
+func restoreSavepoint(ruleId, name string) (*rule.StateMigration, error) {
+	sp, err := state.GetSavepoint(ruleId, name)
+	if err != nil {
+		return nil, err
+	}
+	states, err := sp.GetStates()
+	if err != nil {
+		return nil, err
+	}
+	rs, ok := registry.Load(ruleId)
+	if !ok {
+		return nil, fmt.Errorf("Rule %s is not found in registry, please check if it is created", ruleId)
+	}
+	m, err := rs.Restore(states)
+	if err != nil {
+		return m, err
+	}
+	// The rule is saved as started only if it is restored successfully
+	if err := ruleProcessor.ExecReplaceRuleState(ruleId, true); err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+// list or create savepoints of a rule
+func savepointsHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	ruleId := ruleIdOf(r)
+	switch r.Method {
+	case http.MethodGet:
+		content, err := state.ListSavepoints(ruleId)
+		if err != nil {
+			handleError(w, err, "List savepoints error", logger)
+			return
+		}
+		jsonResponse(content, w, logger)
+	case http.MethodPost:
+		req := &state.Savepoint{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			handleError(w, err, "Invalid body", logger)
+			return
+		}
+		if err := createSavepoint(ruleId, req.Name); err != nil {
+			handleError(w, err, "Create savepoint error", logger)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "Savepoint %s was created.", req.Name)
+	}
+}
+
+// delete a savepoint
+func savepointHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	name := mux.Vars(r)["savepoint"]
+	if err := state.DeleteSavepoint(ruleIdOf(r), name); err != nil {
+		handleError(w, err, "Delete savepoint error", logger)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Savepoint %s was deleted.", name)
+}
+
+// export a savepoint with its states as a file which can be imported on another node
+func savepointExportHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	vars := mux.Vars(r)
+	sp, err := state.GetSavepoint(ruleIdOf(r), vars["savepoint"])
+	if err != nil {
+		handleError(w, err, "Export savepoint error", logger)
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s_%s.json", vars["name"], sp.Name))
+	jsonResponse(sp, w, logger)
+}
+
+// import an exported savepoint for the rule
+func savepointImportHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	sp := &state.Savepoint{}
+	if err := json.NewDecoder(r.Body).Decode(sp); err != nil {
+		handleError(w, err, "Invalid body", logger)
+		return
+	}
+	// The savepoint can be imported for a rule with another id
+	sp.RuleId = ruleIdOf(r)
+	if _, err := sp.GetStates(); err != nil {
+		handleError(w, err, "Import savepoint error", logger)
+		return
+	}
+	if err := state.SaveSavepoint(sp); err != nil {
+		handleError(w, err, "Import savepoint error", logger)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, "Savepoint %s was imported.", sp.Name)
+}
+
+// start the rule from a savepoint
+func savepointRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	vars := mux.Vars(r)
+	m, err := restoreSavepoint(ruleIdOf(r), vars["savepoint"])
+	if err != nil {
+		handleError(w, err, "Restore savepoint error", logger)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	if lost := m.String(); lost != "" {
+		fmt.Fprintf(w, "Rule %s was started from savepoint %s. %s", vars["name"], vars["savepoint"], lost)
+	} else {
+		fmt.Fprintf(w, "Rule %s was started from savepoint %s.", vars["name"], vars["savepoint"])
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/processor"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestSavepointRoutes(t *testing.T) {
+	streamProcessor = processor.NewStreamProcessor()
+	ruleProcessor = processor.NewRuleProcessor()
+	r := mux.NewRouter()
+	for _, router := range []*mux.Router{r, namespaceRouter(r)} {
+		router.HandleFunc("/rules/{name}/savepoints", savepointsHandler).Methods(http.MethodGet, http.MethodPost)
+		router.HandleFunc("/rules/{name}/savepoints/import", savepointImportHandler).Methods(http.MethodPost)
+		router.HandleFunc("/rules/{name}/savepoints/{savepoint}", savepointHandler).Methods(http.MethodDelete)
+		router.HandleFunc("/rules/{name}/savepoints/{savepoint}/export", savepointExportHandler).Methods(http.MethodGet)
+		router.HandleFunc("/rules/{name}/savepoints/{savepoint}/restore", savepointRestoreHandler).Methods(http.MethodPost)
+	}
+	do := func(method, path, body string) (int, string) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, bytes.NewBufferString(body)))
+		return w.Code, w.Body.String()
+	}
+
+	_, err := streamProcessor.ExecStmt(`CREATE STREAM spDemo () WITH (DATASOURCE="spDemo", TYPE="memory", FORMAT="json")`)
+	require.NoError(t, err)
+	defer func() {
+		_, _ = streamProcessor.DropStream("spDemo", ast.TypeStream)
+	}()
+	for _, id := range []string{"spRule1", "spRule2"} {
+		_, err = createRule("", id, `{"sql":"SELECT count(*) FROM spDemo GROUP BY TumblingWindow(ss, 10)","actions":[{"log":{}}],"triggered":false}`)
+		require.NoError(t, err)
+		defer func(id string) {
+			deleteRule(id)
+			_, _ = ruleProcessor.ExecDrop(id)
+		}(id)
+	}
+
+	code, body := do(http.MethodPost, "/rules/spRule1/savepoints", `{"name":"sp1"}`)
+	require.Equal(t, http.StatusCreated, code, body)
+	assert.Equal(t, "Savepoint sp1 was created.", body)
+	defer func() {
+		_ = state.DeleteSavepoint("spRule1", "sp1")
+	}()
+	code, body = do(http.MethodPost, "/rules/spRule1/savepoints", `{"name":"sp1"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "Create savepoint error: savepoint sp1 of rule spRule1 already exists\n", body)
+	code, _ = do(http.MethodPost, "/rules/spRule1/savepoints", `{"name":"sp 1"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, body = do(http.MethodGet, "/rules/spRule1/savepoints", "")
+	require.Equal(t, http.StatusOK, code)
+	var list []*state.Savepoint
+	require.NoError(t, json.Unmarshal([]byte(body), &list))
+	require.Len(t, list, 1)
+	assert.Equal(t, "sp1", list[0].Name)
+	assert.Nil(t, list[0].States)
+
+	// Export from one rule and import to another
+	code, exported := do(http.MethodGet, "/rules/spRule1/savepoints/sp1/export", "")
+	require.Equal(t, http.StatusOK, code)
+	code, body = do(http.MethodPost, "/rules/spRule2/savepoints/import", exported)
+	require.Equal(t, http.StatusCreated, code, body)
+	assert.Equal(t, "Savepoint sp1 was imported.", body)
+	sp, err := state.GetSavepoint("spRule2", "sp1")
+	require.NoError(t, err)
+	assert.Equal(t, "spRule2", sp.RuleId)
+	code, _ = do(http.MethodPost, "/rules/spRule2/savepoints/import", `{"name":"sp2","states":"aW52YWxpZA=="}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, body = do(http.MethodPost, "/rules/spRule2/savepoints/sp1/restore", "")
+	require.Equal(t, http.StatusOK, code, body)
+	assert.Equal(t, "Rule spRule2 was started from savepoint sp1.", body)
+	code, _ = do(http.MethodPost, "/rules/spRule2/savepoints/sp2/restore", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, body = do(http.MethodDelete, "/rules/spRule2/savepoints/sp1", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "Savepoint sp1 was deleted.", body)
+	code, _ = do(http.MethodDelete, "/rules/spRule2/savepoints/sp1", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
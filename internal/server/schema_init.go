@@ -25,12 +25,16 @@ import (
 	"github.com/gorilla/mux"
 
 	"github.com/lf-edge/ekuiper/internal/pkg/def"
+	"github.com/lf-edge/ekuiper/internal/pkg/namespace"
 	"github.com/lf-edge/ekuiper/internal/schema"
 	"github.com/lf-edge/ekuiper/pkg/errorx"
 )
 
 func init() {
 	components["schema"] = schemaComp{}
+	definitionGetters["schema"] = func(typ, name string) (interface{}, error) {
+		return schema.GetSchema(def.SchemaType(typ), name)
+	}
 }
 
 type schemaComp struct{}
@@ -43,8 +47,11 @@ func (sc schemaComp) register() {
 }
 
 func (sc schemaComp) rest(r *mux.Router) {
-	r.HandleFunc("/schemas/{type}", schemasHandler).Methods(http.MethodGet, http.MethodPost)
-	r.HandleFunc("/schemas/{type}/{name}", schemaHandler).Methods(http.MethodPut, http.MethodDelete, http.MethodGet)
+	// The schemas are scoped to the namespace and are saved with the qualified name
+	for _, router := range []*mux.Router{r, namespaceRouter(r)} {
+		router.HandleFunc("/schemas/{type}", schemasHandler).Methods(http.MethodGet, http.MethodPost)
+		router.HandleFunc("/schemas/{type}/{name}", schemaHandler).Methods(http.MethodPut, http.MethodDelete, http.MethodGet)
+	}
 }
 
 func schemasHandler(w http.ResponseWriter, r *http.Request) {
@@ -58,7 +65,7 @@ func schemasHandler(w http.ResponseWriter, r *http.Request) {
 			handleError(w, err, "", logger)
 			return
 		}
-		jsonResponse(l, w, logger)
+		jsonResponse(namespace.Filter(namespaceOf(r), l), w, logger)
 	case http.MethodPost:
 		sch := &schema.Info{Type: def.SchemaType(st)}
 		err := json.NewDecoder(r.Body).Decode(sch)
@@ -70,13 +77,14 @@ func schemasHandler(w http.ResponseWriter, r *http.Request) {
 			handleError(w, nil, "Invalid body", logger)
 			return
 		}
+		sch.Name = namespace.Qualify(namespaceOf(r), sch.Name)
 		err = schema.Register(sch)
 		if err != nil {
 			handleError(w, err, "schema create command error", logger)
 			return
 		}
 		w.WriteHeader(http.StatusCreated)
-		fmt.Fprintf(w, "%s schema %s is created", sch.Type, sch.Name)
+		fmt.Fprintf(w, "%s schema %s is created", sch.Type, namespace.Name(sch.Name))
 	}
 }
 
@@ -85,9 +93,10 @@ func schemaHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	st := vars["type"]
 	name := vars["name"]
+	qualified := namespace.Qualify(namespaceOf(r), name)
 	switch r.Method {
 	case http.MethodGet:
-		j, err := schema.GetSchema(def.SchemaType(st), name)
+		j, err := schema.GetSchema(def.SchemaType(st), qualified)
 		if err != nil {
 			handleError(w, err, "", logger)
 			return
@@ -95,9 +104,10 @@ func schemaHandler(w http.ResponseWriter, r *http.Request) {
 			handleError(w, errorx.NewWithCode(errorx.NOT_FOUND, "not found"), "", logger)
 			return
 		}
+		j.Name = name
 		jsonResponse(j, w, logger)
 	case http.MethodDelete:
-		err := schema.DeleteSchema(def.SchemaType(st), name)
+		err := schema.DeleteSchema(def.SchemaType(st), qualified)
 		if err != nil {
 			handleError(w, err, fmt.Sprintf("delete %s schema %s error", st, name), logger)
 			return
@@ -119,13 +129,14 @@ func schemaHandler(w http.ResponseWriter, r *http.Request) {
 			handleError(w, nil, "Invalid body", logger)
 			return
 		}
+		sch.Name = qualified
 		err = schema.CreateOrUpdateSchema(sch)
 		if err != nil {
 			handleError(w, err, "schema update command error", logger)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, "%s schema %s is updated", sch.Type, sch.Name)
+		fmt.Fprintf(w, "%s schema %s is updated", sch.Type, name)
 	}
 }
 
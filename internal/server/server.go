@@ -35,7 +35,9 @@ import (
 	"github.com/lf-edge/ekuiper/internal/keyedstate"
 	meta2 "github.com/lf-edge/ekuiper/internal/meta"
 	"github.com/lf-edge/ekuiper/internal/pkg/store"
+	"github.com/lf-edge/ekuiper/internal/pkg/tracer"
 	"github.com/lf-edge/ekuiper/internal/processor"
+	"github.com/lf-edge/ekuiper/internal/server/audit"
 	"github.com/lf-edge/ekuiper/internal/topo/connection/factory"
 	"github.com/lf-edge/ekuiper/internal/topo/rule"
 	"github.com/lf-edge/ekuiper/pkg/api"
@@ -99,6 +101,7 @@ func StartUp(Version string) {
 		panic(err)
 	}
 	keyedstate.InitKeyedStateKV()
+	audit.Init(conf.Config.Audit)
 
 	meta2.InitYamlConfigManager()
 	ruleProcessor = processor.NewRuleProcessor()
@@ -126,6 +129,11 @@ func StartUp(Version string) {
 	initRuleset()
 
 	registry = &RuleRegistry{internal: make(map[string]*rule.RuleState)}
+	// Subscribe the rule lifecycle events before the rules start
+	rule.InitEventBus(conf.Config.RuleEvent)
+	if err := tracer.Init(conf.Config.Tracing); err != nil {
+		logger.Errorf("init tracing error: %v", err)
+	}
 	// Start lookup tables
 	streamProcessor.RecoverLookupTable()
 	// Start rules
@@ -195,6 +203,10 @@ func StartUp(Version string) {
 		logger.Infof("close service %s", k)
 		v.close()
 	}
+	rule.CloseEventBus()
+	if err := tracer.Shutdown(); err != nil {
+		logger.Errorf("shutdown tracing error: %v", err)
+	}
 
 	os.Exit(0)
 }
@@ -33,6 +33,9 @@ var serviceManager *service.Manager
 
 func init() {
 	components["service"] = serviceComp{}
+	definitionGetters["service"] = func(_, name string) (interface{}, error) {
+		return serviceManager.Get(name)
+	}
 }
 
 type serviceComp struct{}
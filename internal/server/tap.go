@@ -0,0 +1,182 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/node"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+var tapUpgrader = websocket.Upgrader{
+	HandshakeTimeout: 10 * time.Second,
+	CheckOrigin:      checkTapOrigin,
+}
+
+// checkTapOrigin accepts the requests without origin such as the non-browser clients, the requests from the same host
+// and the origins configured in basic.allowedOrigins. "*" allows any origin.
+func checkTapOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, o := range conf.Config.Basic.AllowedOrigins {
+		if o == "*" || strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTapOptions parses the query parameters direction, filter, rate and limit
+func parseTapOptions(r *http.Request) (*node.TapOptions, error) {
+	q := r.URL.Query()
+	opts := &node.TapOptions{Direction: q.Get("direction")}
+	if f := q.Get("filter"); f != "" {
+		expr, err := xsql.NewParser(strings.NewReader(f)).ParseExpr()
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter %s: %v", f, err)
+		}
+		opts.Filter = expr
+	}
+	for _, p := range []struct {
+		name string
+		v    *int
+	}{{"rate", &opts.Rate}, {"limit", &opts.Limit}} {
+		if s := q.Get(p.name); s != "" {
+			i, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %s, must be an integer", p.name, s)
+			}
+			*p.v = i
+		}
+	}
+	return opts, opts.Validate()
+}
+
+func tapNode(ruleId string, nodeName string, opts *node.TapOptions) (*node.TapSubscriber, error) {
+	rs, ok := registry.Load(ruleId)
+	if !ok {
+		return nil, errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("Rule %s is not found", ruleId))
+	}
+	return rs.Tap(nodeName, opts)
+}
+
+// tapHandler streams the data of a node of the running rule by WebSocket if requested, otherwise by server-sent events
+func tapHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	opts, err := parseTapOptions(r)
+	if err != nil {
+		handleError(w, err, "invalid tap options", logger)
+		return
+	}
+	sub, err := tapNode(ruleIdOf(r), mux.Vars(r)["node"], opts)
+	if err != nil {
+		handleError(w, err, "tap rule node error", logger)
+		return
+	}
+	defer sub.Close()
+	if websocket.IsWebSocketUpgrade(r) {
+		tapWebsocket(w, r, sub)
+	} else {
+		tapSSE(w, r, sub)
+	}
+}
+
+func tapSSE(w http.ResponseWriter, r *http.Request, sub *node.TapSubscriber) {
+	rc := http.NewResponseController(w)
+	// The stream lasts longer than the write timeout of the server
+	_ = rc.SetWriteDeadline(time.Time{})
+	w.Header().Set(ContentType, "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	_ = rc.Flush()
+	for {
+		ev, err := sub.Next(r.Context())
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				_, _ = fmt.Fprint(w, "event: end\ndata: {}\n\n")
+				_ = rc.Flush()
+			}
+			return
+		}
+		b, err := json.Marshal(ev)
+		if err != nil {
+			logger.Warnf("tap event of node %s cannot be marshaled: %v", ev.Node, err)
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", b); err != nil {
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func tapWebsocket(w http.ResponseWriter, r *http.Request, sub *node.TapSubscriber) {
+	conn, err := tapUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logger.Warnf("tap websocket upgrade error: %v", err)
+		return
+	}
+	defer conn.Close()
+	_ = conn.UnderlyingConn().SetDeadline(time.Time{})
+	// The hijacked request is not canceled when the client leaves, so read until the connection is closed
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	for {
+		ev, err := sub.Next(ctx)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "tap ends"))
+			}
+			return
+		}
+		if err := conn.WriteJSON(ev); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/io/memory/pubsub"
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
+	"github.com/lf-edge/ekuiper/internal/processor"
+	"github.com/lf-edge/ekuiper/internal/topo/node"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestTapRoutes(t *testing.T) {
+	streamProcessor = processor.NewStreamProcessor()
+	ruleProcessor = processor.NewRuleProcessor()
+	r := mux.NewRouter()
+	r.HandleFunc("/rules/{name}/nodes/{node}/tap", tapHandler).Methods(http.MethodGet)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	_, err := streamProcessor.ExecStmt(`CREATE STREAM tapDemo () WITH (DATASOURCE="tapDemo", TYPE="memory", FORMAT="json")`)
+	require.NoError(t, err)
+	defer func() {
+		_, _ = streamProcessor.DropStream("tapDemo", ast.TypeStream)
+	}()
+	_, err = createRule("", "tapRule", `{"sql":"SELECT * FROM tapDemo","actions":[{"log":{}}]}`)
+	require.NoError(t, err)
+	defer func() {
+		deleteRule("tapRule")
+		_, _ = ruleProcessor.ExecDrop("tapRule")
+	}()
+	ctx := mockContext.NewMockContext("tapRule", "producer")
+	// Keep producing until the tap is attached
+	produce := func(stop chan struct{}) {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			case <-time.After(10 * time.Millisecond):
+				pubsub.Produce(ctx, "tapDemo", map[string]interface{}{"a": i % 3})
+			}
+		}
+	}
+
+	resp, err := http.Get(ts.URL + "/rules/tapRule/nodes/notExist/tap")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp.Body.Close()
+	resp, err = http.Get(ts.URL + "/rules/tapRule/nodes/log_0/tap?rate=a")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+
+	// Server-sent events
+	assert.Eventually(t, func() bool {
+		h, err := getRuleHealth("tapRule")
+		return err == nil && len(h.Nodes) == 2
+	}, time.Second, 10*time.Millisecond)
+	stop := make(chan struct{})
+	go produce(stop)
+	resp, err = http.Get(ts.URL + "/rules/tapRule/nodes/log_0/tap?filter=" + url.QueryEscape("a = 1") + "&limit=2")
+	require.NoError(t, err)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	var events []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			events = append(events, line)
+		}
+	}
+	resp.Body.Close()
+	close(stop)
+	require.Len(t, events, 4)
+	for _, e := range events[:2] {
+		ev := &node.TapEvent{}
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(e, "data: ")), ev))
+		assert.Equal(t, "log_0", ev.Node)
+		assert.Equal(t, node.TapIn, ev.Direction)
+		assert.Equal(t, map[string]interface{}{"a": float64(1)}, ev.Data)
+	}
+	assert.Equal(t, []string{"event: end", "data: {}"}, events[2:])
+
+	// WebSocket
+	stop = make(chan struct{})
+	go produce(stop)
+	defer close(stop)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/rules/tapRule/nodes/tapDemo/tap?direction=out&limit=1", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	ev := &node.TapEvent{}
+	require.NoError(t, conn.ReadJSON(ev))
+	assert.Equal(t, "tapDemo", ev.Node)
+	assert.Equal(t, node.TapOut, ev.Direction)
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure))
+}
+
+func TestCheckTapOrigin(t *testing.T) {
+	origins := conf.Config.Basic.AllowedOrigins
+	defer func() { conf.Config.Basic.AllowedOrigins = origins }()
+	conf.Config.Basic.AllowedOrigins = []string{"http://manager.example.com:9082/"}
+	tests := []struct {
+		origin string
+		ok     bool
+	}{
+		{origin: "", ok: true},
+		{origin: "http://localhost:9081", ok: true},
+		{origin: "http://manager.example.com:9082", ok: true},
+		{origin: "http://evil.example.com", ok: false},
+		{origin: "%", ok: false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "http://localhost:9081/rules/r1/nodes/demo/tap", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		assert.Equal(t, tt.ok, checkTapOrigin(r), tt.origin)
+	}
+	conf.Config.Basic.AllowedOrigins = []string{"*"}
+	r := httptest.NewRequest(http.MethodGet, "http://localhost:9081/rules/r1/nodes/demo/tap", nil)
+	r.Header.Set("Origin", "http://evil.example.com")
+	assert.True(t, checkTapOrigin(r))
+}
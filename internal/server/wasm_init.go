@@ -34,6 +34,13 @@ var wasmManager *wasm.Manager
 
 func init() {
 	components["wasm"] = wasmComp{}
+	definitionGetters["plugin/"+plugin.PluginTypes[plugin.WASM]] = func(_, name string) (interface{}, error) {
+		j, ok := wasmManager.GetPluginInfo(name)
+		if !ok {
+			return nil, errorx.NewWithCode(errorx.NOT_FOUND, "not found")
+		}
+		return j, nil
+	}
 }
 
 type wasmComp struct{}
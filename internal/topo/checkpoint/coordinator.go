@@ -29,6 +29,9 @@ type pendingCheckpoint struct {
 	checkpointId   int64
 	isDiscarded    bool
 	notYetAckTasks map[string]bool
+	// waiter receives the checkpoint id once completed and is closed if discarded, only for triggered checkpoints
+	waiter chan int64
+	mu     sync.Mutex
 }
 
 func newPendingCheckpoint(checkpointId int64, tasksToWaitFor []Responder) *pendingCheckpoint {
@@ -61,6 +64,22 @@ func (c *pendingCheckpoint) finalize() *completedCheckpoint {
 
 func (c *pendingCheckpoint) dispose(_ bool) {
 	c.isDiscarded = true
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.waiter != nil {
+		close(c.waiter)
+		c.waiter = nil
+	}
+}
+
+func (c *pendingCheckpoint) notify() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.waiter != nil {
+		c.waiter <- c.checkpointId
+		close(c.waiter)
+		c.waiter = nil
+	}
 }
 
 type completedCheckpoint struct {
@@ -89,6 +108,7 @@ func (s *checkpointStore) getLatest() *completedCheckpoint {
 type Coordinator struct {
 	tasksToTrigger          []Responder
 	tasksToWaitFor          []Responder
+	sourceTasks             []StreamTask
 	sinkTasks               []SinkTask
 	pendingCheckpoints      *sync.Map
 	completedCheckpoints    *checkpointStore
@@ -101,6 +121,8 @@ type Coordinator struct {
 	store                   api.Store
 	ctx                     api.StreamContext
 	activated               bool
+	// triggers receives the waiters of the checkpoints triggered manually
+	triggers chan chan int64
 }
 
 func NewCoordinator(ruleId string, sources []StreamTask, operators []NonSourceTask, sinks []SinkTask, qos api.Qos, store api.Store, interval int, ctx api.StreamContext) *Coordinator {
@@ -135,6 +157,7 @@ func NewCoordinator(ruleId string, sources []StreamTask, operators []NonSourceTa
 	return &Coordinator{
 		tasksToTrigger:     sourceResponders,
 		tasksToWaitFor:     allResponders,
+		sourceTasks:        sources,
 		sinkTasks:          sinks,
 		pendingCheckpoints: new(sync.Map),
 		completedCheckpoints: &checkpointStore{
@@ -146,6 +169,7 @@ func NewCoordinator(ruleId string, sources []StreamTask, operators []NonSourceTa
 		store:          store,
 		ctx:            ctx,
 		cleanThreshold: 100,
+		triggers:       make(chan chan int64, 1),
 	}
 }
 
@@ -179,25 +203,21 @@ func (c *Coordinator) Activate() error {
 
 					// TODO Check if all tasks are running
 
-					// Create a pending checkpoint
-					checkpointId := cast.TimeToUnixMilli(n)
-					checkpoint := newPendingCheckpoint(checkpointId, c.tasksToWaitFor)
-					logger.Debugf("Create checkpoint %d", checkpointId)
-					c.pendingCheckpoints.Store(checkpointId, checkpoint)
-					// Let the sources send out a barrier
-					for _, r := range c.tasksToTrigger {
-						go func(t Responder) {
-							if err := t.TriggerCheckpoint(checkpointId); err != nil {
-								logger.Infof("Fail to trigger checkpoint for source %s with error %v, cancel it", t.GetName(), err)
-								c.cancel(checkpointId)
-							}
-						}(r)
-					}
+					c.trigger(cast.TimeToUnixMilli(n), nil)
 					toBeClean++
 					if toBeClean >= c.cleanThreshold {
 						c.store.Clean()
 						toBeClean = 0
 					}
+				case w := <-c.triggers:
+					checkpointId := conf.GetNowInMilli()
+					for {
+						if _, ok := c.pendingCheckpoints.Load(checkpointId); !ok {
+							break
+						}
+						checkpointId++
+					}
+					c.trigger(checkpointId, w)
 				case s := <-c.signal:
 					switch s.Message {
 					case STOP:
@@ -205,6 +225,7 @@ func (c *Coordinator) Activate() error {
 						if c.ticker != nil {
 							c.ticker.Stop()
 						}
+						c.disposeAll()
 						return nil
 					case ACK:
 						logger.Debugf("Receive ack from %s for checkpoint %d", s.OpId, s.CheckpointId)
@@ -227,6 +248,7 @@ func (c *Coordinator) Activate() error {
 						c.ticker.Stop()
 						logger.Infoln("Stop coordinator ticker")
 					}
+					c.disposeAll()
 					return nil
 				}
 			}
@@ -236,6 +258,36 @@ func (c *Coordinator) Activate() error {
 	return nil
 }
 
+// trigger creates a pending checkpoint and lets the sources send out a barrier
+func (c *Coordinator) trigger(checkpointId int64, waiter chan int64) {
+	logger := c.ctx.GetLogger()
+	checkpoint := newPendingCheckpoint(checkpointId, c.tasksToWaitFor)
+	checkpoint.waiter = waiter
+	logger.Debugf("Create checkpoint %d", checkpointId)
+	c.pendingCheckpoints.Store(checkpointId, checkpoint)
+	for _, r := range c.tasksToTrigger {
+		go func(t Responder) {
+			if err := t.TriggerCheckpoint(checkpointId); err != nil {
+				logger.Infof("Fail to trigger checkpoint for source %s with error %v, cancel it", t.GetName(), err)
+				c.cancel(checkpointId)
+			}
+		}(r)
+	}
+}
+
+// TriggerCheckpoint triggers a checkpoint immediately besides the periodical ones. The returned channel receives the
+// checkpoint id once the checkpoint is completed and is closed if the checkpoint is discarded or another triggered
+// checkpoint is waiting to start.
+func (c *Coordinator) TriggerCheckpoint() <-chan int64 {
+	w := make(chan int64, 1)
+	select {
+	case c.triggers <- w:
+	default:
+		close(w)
+	}
+	return w
+}
+
 func (c *Coordinator) Deactivate() error {
 	if c.ticker != nil {
 		c.ticker.Stop()
@@ -244,6 +296,24 @@ func (c *Coordinator) Deactivate() error {
 	return nil
 }
 
+// disposeAll discards the pending checkpoints and the checkpoints waiting to be triggered when the coordinator stops
+// so that their waiters are released immediately
+func (c *Coordinator) disposeAll() {
+	c.pendingCheckpoints.Range(func(a1 interface{}, a2 interface{}) bool {
+		c.pendingCheckpoints.Delete(a1)
+		a2.(*pendingCheckpoint).dispose(true)
+		return true
+	})
+	for {
+		select {
+		case w := <-c.triggers:
+			close(w)
+		default:
+			return
+		}
+	}
+}
+
 func (c *Coordinator) cancel(checkpointId int64) {
 	logger := c.ctx.GetLogger()
 	if checkpoint, ok := c.pendingCheckpoints.Load(checkpointId); ok {
@@ -264,19 +334,32 @@ func (c *Coordinator) complete(checkpointId int64) {
 			// TODO handle checkpoint error
 			return
 		}
-		c.completedCheckpoints.add(ccp.(*pendingCheckpoint).finalize())
+		pc := ccp.(*pendingCheckpoint)
+		c.completedCheckpoints.add(pc.finalize())
 		c.pendingCheckpoints.Delete(checkpointId)
+		pc.notify()
 		// Drop the previous pendingCheckpoints
 		c.pendingCheckpoints.Range(func(a1 interface{}, a2 interface{}) bool {
 			cid := a1.(int64)
 			cp := a2.(*pendingCheckpoint)
 			if cid < checkpointId {
 				// TODO revisit how to abort a checkpoint, discard callback
-				cp.isDiscarded = true
+				cp.dispose(true)
 				c.pendingCheckpoints.Delete(cid)
 			}
 			return true
 		})
+		// Notify the sinks to commit the pre-committed transactions and the sources to commit the offsets
+		for _, t := range c.sinkTasks {
+			if tt, ok := t.(CheckpointListener); ok {
+				tt.NotifyCheckpointComplete(checkpointId)
+			}
+		}
+		for _, t := range c.sourceTasks {
+			if tt, ok := t.(CheckpointListener); ok {
+				tt.NotifyCheckpointComplete(checkpointId)
+			}
+		}
 		logger.Debugf("Totally complete checkpoint %d", checkpointId)
 	} else {
 		logger.Infof("Cannot find checkpoint %d to complete", checkpointId)
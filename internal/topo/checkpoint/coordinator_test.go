@@ -0,0 +1,48 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkpoint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+func TestDeactivateReleasesWaiter(t *testing.T) {
+	c := NewCoordinator("rule1", nil, nil, nil, api.AtLeastOnce, nil, 3600000, context.Background())
+	require.NoError(t, c.Activate())
+	w := c.TriggerCheckpoint()
+	// Wait for the checkpoint to be pending as no task acks it
+	assert.Eventually(t, func() bool {
+		n := 0
+		c.pendingCheckpoints.Range(func(_, _ interface{}) bool {
+			n++
+			return true
+		})
+		return n == 1
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, c.Deactivate())
+	select {
+	case _, ok := <-w:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("the waiter of the pending checkpoint is not released")
+	}
+}
@@ -1,4 +1,4 @@
-// Copyright 2021-2022 EMQ Technologies Co., Ltd.
+// Copyright 2021-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -37,6 +37,28 @@ type SinkTask interface {
 	NonSourceTask
 }
 
+// CheckpointListener is a task which is notified when the checkpoint is completed
+type CheckpointListener interface {
+	// NotifyCheckpointComplete is called after the checkpoint is completed and saved
+	NotifyCheckpointComplete(checkpointId int64)
+}
+
+// TwoPhaseCommitTask is a task which takes part in the two-phase commit. The sink tasks commit the transactions for
+// end-to-end exactly once.
+type TwoPhaseCommitTask interface {
+	// PreCommit is called when the barrier is received and before the state snapshot
+	PreCommit(checkpointId int64) error
+	CheckpointListener
+}
+
+// SnapshotTask is a task which reads the state snapshot of the checkpoint. The source tasks record the offsets in the
+// snapshot and commit them to the external system when the checkpoint completes.
+type SnapshotTask interface {
+	// OnSnapshot is called with the snapshot which will be saved for the checkpoint
+	OnSnapshot(checkpointId int64, snapshot map[string]interface{}) error
+	CheckpointListener
+}
+
 type BufferOrEvent struct {
 	Data    interface{}
 	Channel string
@@ -44,6 +66,8 @@ type BufferOrEvent struct {
 
 type StreamCheckpointContext interface {
 	Snapshot() error
+	// GetSnapshot returns the snapshot which is taken but not saved yet
+	GetSnapshot() map[string]interface{}
 	SaveState(checkpointId int64) error
 }
 
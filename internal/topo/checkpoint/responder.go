@@ -1,4 +1,4 @@
-// Copyright 2021-2022 EMQ Technologies Co., Ltd.
+// Copyright 2021-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -57,11 +57,22 @@ func (re *ResponderExecutor) TriggerCheckpoint(checkpointId int64) error {
 	}
 	// broadcast barrier
 	re.task.Broadcast(barrier)
+	// pre-commit the transaction before snapshot so that the pending transaction is saved in the state
+	if t, ok := re.task.(TwoPhaseCommitTask); ok {
+		if err := t.PreCommit(checkpointId); err != nil {
+			return err
+		}
+	}
 	// Save key state to the global state
 	err := sctx.Snapshot()
 	if err != nil {
 		return err
 	}
+	if t, ok := re.task.(SnapshotTask); ok {
+		if err := t.OnSnapshot(checkpointId, sctx.GetSnapshot()); err != nil {
+			return err
+		}
+	}
 	go infra.SafeRun(func() error {
 		state := ACK
 		err := sctx.SaveState(checkpointId)
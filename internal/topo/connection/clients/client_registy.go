@@ -28,6 +28,9 @@ type clientRegistry struct {
 	supportedClientType []string
 	clientFactory       map[string]ClientFactoryFunc
 	shareClientStore    map[string]ClientWrapper
+	connectionFactory   map[string]ConnectionFactoryFunc
+	// selector -> the bookkeeping of the shared connections and client wrappers
+	entries map[string]*sharedEntry
 }
 
 var gClientRegistry = clientRegistry{
@@ -35,6 +38,8 @@ var gClientRegistry = clientRegistry{
 	Lock:                sync.Mutex{},
 	supportedClientType: make([]string, 0),
 	shareClientStore:    make(map[string]ClientWrapper),
+	connectionFactory:   make(map[string]ConnectionFactoryFunc),
+	entries:             make(map[string]*sharedEntry),
 }
 
 func RegisterClientFactory(clientType string, creatorFunc ClientFactoryFunc) {
@@ -57,35 +62,29 @@ func getConnectionSelector(props map[string]interface{}) (ConnectionSelector str
 	return "", nil
 }
 
+// GetClient returns the message client of the connection type. Like GetConnection, the client is created without
+// holding the registry lock because the client wrappers connect when created.
 func GetClient(connectionType string, props map[string]interface{}) (api.MessageClient, error) {
-	gClientRegistry.Lock.Lock()
-	defer gClientRegistry.Lock.Unlock()
-
 	connectSelector, err := getConnectionSelector(props)
 	if err != nil {
 		return nil, err
 	}
+	gClientRegistry.Lock.Lock()
 	if connectSelector != "" {
-		if cliWpr, found := gClientRegistry.shareClientStore[connectSelector]; found {
-			cliWpr.AddRef()
+		if cliWpr, ok := refSharedClient(connectSelector); ok {
+			gClientRegistry.Lock.Unlock()
 			return cliWpr, nil
 		}
 	}
-
 	clientCreator, ok := gClientRegistry.clientFactory[connectionType]
+	gClientRegistry.Lock.Unlock()
 	if !ok {
 		conf.Log.Errorf("can not find clientCreator for connection type : %s. only support %s", connectionType, gClientRegistry.supportedClientType)
 		return nil, fmt.Errorf("can not find clientCreator for connection type : %s. only support %s", connectionType, gClientRegistry.supportedClientType)
 	}
 
 	if connectSelector != "" {
-		selectCfg := &conf.ConSelector{
-			ConnSelectorStr: connectSelector,
-		}
-		if err := selectCfg.Init(); err != nil {
-			return nil, err
-		}
-		cf, err := selectCfg.ReadCfgFromYaml()
+		cf, err := ReadConnectionConf(connectionType, connectSelector)
 		if err != nil {
 			return nil, err
 		}
@@ -94,9 +93,28 @@ func GetClient(connectionType string, props map[string]interface{}) (api.Message
 			conf.Log.Errorf("can not create client for connection selector : %s have error %s", connectSelector, err)
 			return nil, err
 		}
+		gClientRegistry.Lock.Lock()
+		// The shared client may be created by another rule in the meantime
+		if shared, ok := refSharedClient(connectSelector); ok {
+			gClientRegistry.Lock.Unlock()
+			cliWpr.Release(connectionContext())
+			return shared, nil
+		}
 		cliWpr.SetConnectionSelector(connectSelector)
-		conf.Log.Infof("Init client wrapper for client type %s and connection selector %s", connectionType, connectSelector)
+		stats := newConnectionStats()
+		stats.OnConnected()
+		if sh, ok := cliWpr.(StatsHolder); ok {
+			sh.SetStats(stats)
+		}
 		gClientRegistry.shareClientStore[connectSelector] = cliWpr
+		gClientRegistry.entries[connectSelector] = &sharedEntry{
+			typ:      connectionType,
+			selector: connectSelector,
+			refCnt:   1,
+			stats:    stats,
+		}
+		gClientRegistry.Lock.Unlock()
+		conf.Log.Infof("Init client wrapper for client type %s and connection selector %s", connectionType, connectSelector)
 		return cliWpr, nil
 	} else {
 		cliWpr, err := clientCreator(props)
@@ -109,6 +127,19 @@ func GetClient(connectionType string, props map[string]interface{}) (api.Message
 	}
 }
 
+// refSharedClient adds a reference to the shared client wrapper if it exists. The caller must hold the registry lock.
+func refSharedClient(selector string) (ClientWrapper, bool) {
+	cliWpr, found := gClientRegistry.shareClientStore[selector]
+	if !found {
+		return nil, false
+	}
+	cliWpr.AddRef()
+	if entry, ok := gClientRegistry.entries[selector]; ok {
+		entry.refCnt++
+	}
+	return cliWpr, true
+}
+
 func ReleaseClient(ctx api.StreamContext, cli api.MessageClient) {
 	log := ctx.GetLogger()
 
@@ -116,10 +147,17 @@ func ReleaseClient(ctx api.StreamContext, cli api.MessageClient) {
 	sel := wrapper.GetConnectionSelector()
 	ok := wrapper.Release(ctx)
 
-	if sel != "" && ok {
+	if sel == "" {
+		return
+	}
+	gClientRegistry.Lock.Lock()
+	defer gClientRegistry.Lock.Unlock()
+	if entry, found := gClientRegistry.entries[sel]; found {
+		entry.refCnt--
+	}
+	if ok {
 		log.Infof("remove mqtt client wrapper for connection selector %s", sel)
-		gClientRegistry.Lock.Lock()
 		delete(gClientRegistry.shareClientStore, sel)
-		gClientRegistry.Lock.Unlock()
+		delete(gClientRegistry.entries, sel)
 	}
 }
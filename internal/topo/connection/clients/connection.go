@@ -0,0 +1,361 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clients
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	kctx "github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+// Connection is a connection resource of a connector which is not a pub/sub message client, such as a sql database
+// handle, a redis client or a http client. Connections declared in etc/connections/connection.yaml are shared by all
+// the sources and sinks referring to them by connectionSelector.
+type Connection interface {
+	// Ping checks the connectivity of the connection
+	Ping(ctx api.StreamContext) error
+	Close(ctx api.StreamContext) error
+}
+
+type ConnectionFactoryFunc func(props map[string]interface{}) (Connection, error)
+
+// StatsHolder is implemented by the connections or client wrappers which can report the changes of the underlying link
+// such as a lost connection and a reconnection.
+type StatsHolder interface {
+	SetStats(stats *ConnectionStats)
+}
+
+const (
+	StatusConnected    = "connected"
+	StatusDisconnected = "disconnected"
+)
+
+// ConnectionStats records the health of a shared connection. It is safe for concurrent use.
+type ConnectionStats struct {
+	sync.RWMutex
+	connected   bool
+	lastErr     string
+	since       time.Time
+	connects    int64
+	disconnects int64
+}
+
+func newConnectionStats() *ConnectionStats {
+	return &ConnectionStats{since: conf.GetNow()}
+}
+
+// OnConnected marks the connection as connected. Connecting again after the first time counts as a reconnection.
+func (s *ConnectionStats) OnConnected() {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	if !s.connected {
+		s.connected = true
+		s.since = conf.GetNow()
+		s.connects++
+	}
+}
+
+// OnDisconnected marks the connection as disconnected with the cause
+func (s *ConnectionStats) OnDisconnected(err error) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	if err != nil {
+		s.lastErr = err.Error()
+	}
+	if s.connected {
+		s.connected = false
+		s.since = conf.GetNow()
+		s.disconnects++
+	}
+}
+
+// ConnectionStatus is the health snapshot of a shared connection
+type ConnectionStatus struct {
+	Selector    string `json:"selector"`
+	Type        string `json:"type"`
+	Status      string `json:"status"`
+	RefCount    int    `json:"refCount"`
+	LastError   string `json:"lastError,omitempty"`
+	Since       int64  `json:"since"`
+	Reconnects  int64  `json:"reconnects"`
+	Disconnects int64  `json:"disconnects"`
+}
+
+func (s *ConnectionStats) status() ConnectionStatus {
+	s.RLock()
+	defer s.RUnlock()
+	r := ConnectionStatus{
+		Status:      StatusDisconnected,
+		LastError:   s.lastErr,
+		Since:       s.since.UnixMilli(),
+		Disconnects: s.disconnects,
+	}
+	if s.connected {
+		r.Status = StatusConnected
+	}
+	if s.connects > 1 {
+		r.Reconnects = s.connects - 1
+	}
+	return r
+}
+
+// sharedEntry is the bookkeeping of a shared connection or a shared client wrapper
+type sharedEntry struct {
+	typ      string
+	selector string
+	refCnt   int
+	stats    *ConnectionStats
+	// conn is only set for Connection. Client wrappers are kept in shareClientStore
+	conn Connection
+}
+
+func RegisterConnectionFactory(connectionType string, creatorFunc ConnectionFactoryFunc) {
+	gClientRegistry.Lock.Lock()
+	gClientRegistry.connectionFactory[connectionType] = creatorFunc
+	gClientRegistry.Lock.Unlock()
+}
+
+// GetConnection returns the connection of the connection type. If the props has a connectionSelector, the shared
+// connection declared in connection.yaml is returned and its reference count is increased. Otherwise, a new connection
+// is created by the props. A new connection is pinged before returning so that the rule fails to start if the
+// connection is not reachable. The connection is created and pinged without holding the registry lock so that a slow
+// endpoint does not block the others.
+func GetConnection(connectionType string, props map[string]interface{}) (Connection, error) {
+	connectSelector, err := getConnectionSelector(props)
+	if err != nil {
+		return nil, err
+	}
+	gClientRegistry.Lock.Lock()
+	if connectSelector != "" {
+		if conn, ok := refSharedConnection(connectSelector); ok {
+			gClientRegistry.Lock.Unlock()
+			return conn, nil
+		}
+	}
+	creator, ok := gClientRegistry.connectionFactory[connectionType]
+	gClientRegistry.Lock.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("can not find connection factory for connection type : %s", connectionType)
+	}
+	if connectSelector == "" {
+		return createConnection(creator, props)
+	}
+	cf, err := ReadConnectionConf(connectionType, connectSelector)
+	if err != nil {
+		return nil, err
+	}
+	stats := newConnectionStats()
+	conn, err := createConnection(creator, cf)
+	if err != nil {
+		conf.Log.Errorf("can not create connection for connection selector : %s have error %s", connectSelector, err)
+		return nil, err
+	}
+	gClientRegistry.Lock.Lock()
+	// The shared connection may be created by another rule in the meantime
+	if shared, ok := refSharedConnection(connectSelector); ok {
+		gClientRegistry.Lock.Unlock()
+		_ = conn.Close(connectionContext())
+		return shared, nil
+	}
+	if sh, ok := conn.(StatsHolder); ok {
+		sh.SetStats(stats)
+	}
+	stats.OnConnected()
+	gClientRegistry.entries[connectSelector] = &sharedEntry{
+		typ:      connectionType,
+		selector: connectSelector,
+		refCnt:   1,
+		stats:    stats,
+		conn:     conn,
+	}
+	gClientRegistry.Lock.Unlock()
+	conf.Log.Infof("Init shared connection for connection type %s and connection selector %s", connectionType, connectSelector)
+	return conn, nil
+}
+
+// refSharedConnection increases the reference count of the shared connection if it exists. The caller must hold the
+// registry lock.
+func refSharedConnection(selector string) (Connection, bool) {
+	entry, found := gClientRegistry.entries[selector]
+	if !found || entry.conn == nil {
+		return nil, false
+	}
+	entry.refCnt++
+	conf.Log.Infof("shared connection %s add reference, total refcount %d", selector, entry.refCnt)
+	return entry.conn, true
+}
+
+func createConnection(creator ConnectionFactoryFunc, props map[string]interface{}) (Connection, error) {
+	conn, err := creator(props)
+	if err != nil {
+		return nil, err
+	}
+	ctx := connectionContext()
+	if err := conn.Ping(ctx); err != nil {
+		_ = conn.Close(ctx)
+		return nil, fmt.Errorf("%s: connection test failed: %v", errorx.IOErr, err)
+	}
+	return conn, nil
+}
+
+// ReleaseConnection decreases the reference count of a shared connection and closes it when no one refers to it.
+// A connection which is not shared is closed directly.
+func ReleaseConnection(ctx api.StreamContext, conn Connection) error {
+	if conn == nil {
+		return nil
+	}
+	gClientRegistry.Lock.Lock()
+	defer gClientRegistry.Lock.Unlock()
+	for sel, entry := range gClientRegistry.entries {
+		if entry.conn != conn {
+			continue
+		}
+		entry.refCnt--
+		if entry.refCnt > 0 {
+			return nil
+		}
+		ctx.GetLogger().Infof("remove shared connection for connection selector %s", sel)
+		delete(gClientRegistry.entries, sel)
+		return conn.Close(ctx)
+	}
+	return conn.Close(ctx)
+}
+
+// ReportConnectionError updates the health of a shared connection by the result of an operation. The connectors call
+// it when an operation fails for a connection problem or succeeds again.
+func ReportConnectionError(conn Connection, err error) {
+	gClientRegistry.Lock.Lock()
+	defer gClientRegistry.Lock.Unlock()
+	for _, entry := range gClientRegistry.entries {
+		if entry.conn == conn {
+			if err != nil {
+				entry.stats.OnDisconnected(err)
+			} else {
+				entry.stats.OnConnected()
+			}
+			return
+		}
+	}
+}
+
+// GetConnectionStatus returns the health of all the shared connections and client wrappers in use
+func GetConnectionStatus() []ConnectionStatus {
+	gClientRegistry.Lock.Lock()
+	defer gClientRegistry.Lock.Unlock()
+	result := make([]ConnectionStatus, 0, len(gClientRegistry.entries))
+	for _, entry := range gClientRegistry.entries {
+		result = append(result, entry.status())
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Selector < result[j].Selector
+	})
+	return result
+}
+
+// GetConnectionStatusBySelector returns the health of a shared connection or client wrapper in use
+func GetConnectionStatusBySelector(selector string) (ConnectionStatus, error) {
+	gClientRegistry.Lock.Lock()
+	defer gClientRegistry.Lock.Unlock()
+	entry, ok := gClientRegistry.entries[selector]
+	if !ok {
+		return ConnectionStatus{}, errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("connection %s is not in use", selector))
+	}
+	return entry.status(), nil
+}
+
+func (e *sharedEntry) status() ConnectionStatus {
+	s := e.stats.status()
+	s.Selector = e.selector
+	s.Type = e.typ
+	s.RefCount = e.refCnt
+	return s
+}
+
+// TestConnection checks the connectivity of a connection declared in connection.yaml. If the connection is in use,
+// the shared connection is pinged and its health is updated. Otherwise, a temporary connection is created and closed.
+func TestConnection(connectionType string, selector string) error {
+	ctx := connectionContext()
+	gClientRegistry.Lock.Lock()
+	entry, found := gClientRegistry.entries[selector]
+	gClientRegistry.Lock.Unlock()
+	if found {
+		if entry.conn == nil {
+			if s := entry.stats.status(); s.Status != StatusConnected {
+				return fmt.Errorf("%s: connection %s is disconnected: %s", errorx.IOErr, selector, s.LastError)
+			}
+			return nil
+		}
+		err := entry.conn.Ping(ctx)
+		ReportConnectionError(entry.conn, err)
+		return err
+	}
+	cf, err := ReadConnectionConf(connectionType, selector)
+	if err != nil {
+		return err
+	}
+	gClientRegistry.Lock.Lock()
+	creator, isConn := gClientRegistry.connectionFactory[connectionType]
+	cliCreator, isCli := gClientRegistry.clientFactory[connectionType]
+	gClientRegistry.Lock.Unlock()
+	switch {
+	case isConn:
+		conn, err := createConnection(creator, cf)
+		if err != nil {
+			return err
+		}
+		return conn.Close(ctx)
+	case isCli:
+		// client wrappers connect when created
+		cli, err := cliCreator(cf)
+		if err != nil {
+			return err
+		}
+		cli.Release(ctx)
+		return nil
+	default:
+		return fmt.Errorf("can not find connection factory for connection type : %s", connectionType)
+	}
+}
+
+// ReadConnectionConf reads the props of a connection selector in connection.yaml
+func ReadConnectionConf(connectionType string, selector string) (map[string]interface{}, error) {
+	selectCfg := &conf.ConSelector{
+		ConnSelectorStr: selector,
+	}
+	if err := selectCfg.Init(); err != nil {
+		return nil, err
+	}
+	if selectCfg.Type != connectionType {
+		return nil, fmt.Errorf("connection selector %s is not a %s connection", selector, connectionType)
+	}
+	return selectCfg.ReadCfgFromYaml()
+}
+
+func connectionContext() api.StreamContext {
+	contextLogger := conf.Log.WithField("connection", "shared")
+	return kctx.WithValue(kctx.Background(), kctx.LoggerKey, contextLogger)
+}
@@ -0,0 +1,166 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clients
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+type fakeConnection struct {
+	addr    string
+	pingErr error
+	closed  bool
+}
+
+func (f *fakeConnection) Ping(_ api.StreamContext) error {
+	return f.pingErr
+}
+
+func (f *fakeConnection) Close(_ api.StreamContext) error {
+	f.closed = true
+	return nil
+}
+
+func setupConnectionYaml(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "etc", "connections"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "data"), 0o755))
+	content := "fake:\n  conn1:\n    addr: host1\n  down:\n    addr: unreachable\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "etc", "connections", "connection.yaml"), []byte(content), 0o644))
+	t.Setenv(conf.KuiperBaseKey, dir)
+}
+
+func TestSharedConnection(t *testing.T) {
+	setupConnectionYaml(t)
+	var created []*fakeConnection
+	RegisterConnectionFactory("fake", func(props map[string]interface{}) (Connection, error) {
+		c := &fakeConnection{addr: props["addr"].(string)}
+		if c.addr == "unreachable" {
+			c.pingErr = errors.New("connection refused")
+		}
+		created = append(created, c)
+		return c, nil
+	})
+	ctx := connectionContext()
+
+	// Connections without selector are not shared
+	c1, err := GetConnection("fake", map[string]interface{}{"addr": "host0"})
+	require.NoError(t, err)
+	c2, err := GetConnection("fake", map[string]interface{}{"addr": "host0"})
+	require.NoError(t, err)
+	assert.NotSame(t, c1, c2)
+	require.NoError(t, ReleaseConnection(ctx, c1))
+	assert.True(t, c1.(*fakeConnection).closed)
+	assert.Empty(t, GetConnectionStatus())
+
+	// Connections with the same selector are shared
+	s1, err := GetConnection("fake", map[string]interface{}{"connectionSelector": "fake.conn1"})
+	require.NoError(t, err)
+	s2, err := GetConnection("fake", map[string]interface{}{"connectionSelector": "fake.Conn1"})
+	require.NoError(t, err)
+	assert.Same(t, s1, s2)
+	assert.Equal(t, "host1", s1.(*fakeConnection).addr)
+	status, err := GetConnectionStatusBySelector("fake.conn1")
+	require.NoError(t, err)
+	assert.Equal(t, StatusConnected, status.Status)
+	assert.Equal(t, 2, status.RefCount)
+
+	// Health is updated by the reported errors
+	ReportConnectionError(s1, errors.New("timeout"))
+	ReportConnectionError(s1, nil)
+	status, _ = GetConnectionStatusBySelector("fake.conn1")
+	assert.Equal(t, StatusConnected, status.Status)
+	assert.Equal(t, "timeout", status.LastError)
+	assert.Equal(t, int64(1), status.Reconnects)
+	assert.Equal(t, int64(1), status.Disconnects)
+
+	// Test connection pings the one in use and updates its health
+	s1.(*fakeConnection).pingErr = errors.New("broken pipe")
+	assert.Error(t, TestConnection("fake", "fake.conn1"))
+	status, _ = GetConnectionStatusBySelector("fake.conn1")
+	assert.Equal(t, StatusDisconnected, status.Status)
+	s1.(*fakeConnection).pingErr = nil
+
+	// The connection is closed when the last reference is released
+	require.NoError(t, ReleaseConnection(ctx, s1))
+	assert.False(t, s1.(*fakeConnection).closed)
+	require.NoError(t, ReleaseConnection(ctx, s2))
+	assert.True(t, s1.(*fakeConnection).closed)
+	_, err = GetConnectionStatusBySelector("fake.conn1")
+	assert.Error(t, err)
+
+	// Unreachable connections fail to create
+	_, err = GetConnection("fake", map[string]interface{}{"connectionSelector": "fake.down"})
+	assert.Error(t, err)
+	assert.True(t, created[len(created)-1].closed)
+	assert.Error(t, TestConnection("fake", "fake.down"))
+	assert.NoError(t, TestConnection("fake", "fake.conn1"))
+	_, err = GetConnection("fake", map[string]interface{}{"connectionSelector": "mqtt.conn1"})
+	assert.Error(t, err)
+	assert.Empty(t, GetConnectionStatus())
+}
+
+type slowConnection struct {
+	fakeConnection
+	ping chan struct{}
+}
+
+func (s *slowConnection) Ping(_ api.StreamContext) error {
+	<-s.ping
+	return nil
+}
+
+func TestSlowConnection(t *testing.T) {
+	setupConnectionYaml(t)
+	ping := make(chan struct{})
+	RegisterConnectionFactory("fake", func(props map[string]interface{}) (Connection, error) {
+		return &slowConnection{fakeConnection: fakeConnection{addr: props["addr"].(string)}, ping: ping}, nil
+	})
+	type result struct {
+		conn Connection
+		err  error
+	}
+	results := make(chan result, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			c, err := GetConnection("fake", map[string]interface{}{"connectionSelector": "fake.conn1"})
+			results <- result{conn: c, err: err}
+		}()
+	}
+	// The status api is not blocked by the pinging connections
+	assert.Empty(t, GetConnectionStatus())
+	close(ping)
+	r1, r2 := <-results, <-results
+	require.NoError(t, r1.err)
+	require.NoError(t, r2.err)
+	// Only one connection is shared and the duplicated one is closed
+	assert.Same(t, r1.conn, r2.conn)
+	status, err := GetConnectionStatusBySelector("fake.conn1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, status.RefCount)
+	ctx := connectionContext()
+	require.NoError(t, ReleaseConnection(ctx, r1.conn))
+	require.NoError(t, ReleaseConnection(ctx, r2.conn))
+	assert.Empty(t, GetConnectionStatus())
+}
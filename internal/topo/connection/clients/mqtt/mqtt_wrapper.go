@@ -49,6 +49,7 @@ type mqttClientWrapper struct {
 	conSelector string
 
 	connected bool
+	stats     *clients.ConnectionStats
 
 	refLock sync.RWMutex
 	refCnt  uint64
@@ -87,6 +88,7 @@ func (mc *mqttClientWrapper) onConnectHandler(_ pahoMqtt.Client) {
 	mc.subLock.Lock()
 	defer mc.subLock.Unlock()
 	mc.connected = true
+	mc.stats.OnConnected()
 	for topic, subscription := range mc.topicSubscriptions {
 		token := mc.cli.conn.Subscribe(topic, subscription.qos, subscription.topicHandler)
 		if token.Error() != nil {
@@ -108,6 +110,7 @@ func (mc *mqttClientWrapper) onConnectLost(_ pahoMqtt.Client, err error) {
 	mc.connected = false
 	e := fmt.Errorf("The connection to mqtt broker %s client id %s disconnected with error: %s ", mc.cli.srv, mc.cli.clientid, err.Error())
 	conf.Log.Warnf(e.Error())
+	mc.stats.OnDisconnected(err)
 	for _, sub := range mc.topicSubscriptions {
 		if sub != nil {
 			// broadcast errors to all consumers
@@ -272,6 +275,13 @@ func (mc *mqttClientWrapper) SetConnectionSelector(conSelector string) {
 	mc.conSelector = conSelector
 }
 
+// SetStats implements clients.StatsHolder to report the connection lost and reconnection of the shared client
+func (mc *mqttClientWrapper) SetStats(stats *clients.ConnectionStats) {
+	mc.subLock.Lock()
+	defer mc.subLock.Unlock()
+	mc.stats = stats
+}
+
 func (mc *mqttClientWrapper) GetConnectionSelector() string {
 	return mc.conSelector
 }
@@ -32,8 +32,9 @@ import (
 )
 
 const (
-	LoggerKey    = "$$logger"
-	RuleStartKey = "$$ruleStart"
+	LoggerKey     = "$$logger"
+	RuleStartKey  = "$$ruleStart"
+	DeadLetterKey = "$$deadLetter"
 )
 
 type DefaultContext struct {
@@ -251,11 +252,23 @@ func (c *DefaultContext) DeleteState(key string) error {
 	return nil
 }
 
+// GetStates returns a copy of the current states of the operator
+func (c *DefaultContext) GetStates() map[string]interface{} {
+	if c.state == nil {
+		return nil
+	}
+	return cast.SyncMapToMap(c.state)
+}
+
 func (c *DefaultContext) Snapshot() error {
 	c.snapshot = cast.SyncMapToMap(c.state)
 	return nil
 }
 
+func (c *DefaultContext) GetSnapshot() map[string]interface{} {
+	return c.snapshot
+}
+
 func (c *DefaultContext) SaveState(checkpointId int64) error {
 	err := c.store.SaveState(checkpointId, c.opId, c.snapshot)
 	if err != nil {
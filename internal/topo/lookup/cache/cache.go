@@ -15,16 +15,30 @@
 package cache
 
 import (
+	"container/list"
 	"context"
+	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/pkg/api"
 )
 
+const (
+	// PolicyLRU evicts the least recently used key when the cache is full
+	PolicyLRU = "lru"
+	// PolicyLFU evicts the least frequently used key when the cache is full
+	PolicyLFU = "lfu"
+)
+
 type item struct {
+	key        string
 	data       []api.SourceTuple
 	expiration int64
+	// the access count for lfu
+	freq int
+	elem *list.Element
 }
 
 type Cache struct {
@@ -32,21 +46,47 @@ type Cache struct {
 	cacheMissingKey bool
 	cancel          context.CancelFunc
 	items           map[string]*item
+	// size is the max number of keys, 0 means unbounded
+	size      int
+	evictor   evictor
+	evictions int64
 	sync.RWMutex
 }
 
+// NewCache creates an unbounded cache
 func NewCache(expireTime int, cacheMissingKey bool) *Cache {
+	c, _ := NewSizedCache(expireTime, cacheMissingKey, 0, "")
+	return c
+}
+
+// NewSizedCache creates a cache which holds at most size keys, 0 means unbounded. When it is full, a key is evicted
+// by the policy which is lru by default or lfu.
+func NewSizedCache(expireTime int, cacheMissingKey bool, size int, policy string) (*Cache, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("invalid cache size %d, must not be negative", size)
+	}
 	c := &Cache{
 		expireTime:      expireTime,
 		cacheMissingKey: cacheMissingKey,
 		items:           make(map[string]*item),
+		size:            size,
+	}
+	if size > 0 {
+		switch policy {
+		case "", PolicyLRU:
+			c.evictor = &lruEvictor{l: list.New()}
+		case PolicyLFU:
+			c.evictor = &lfuEvictor{buckets: make(map[int]*list.List)}
+		default:
+			return nil, fmt.Errorf("invalid cache policy %s, must be %s or %s", policy, PolicyLRU, PolicyLFU)
+		}
 	}
 	if expireTime > 0 {
 		ctx, cancel := context.WithCancel(context.Background())
 		c.cancel = cancel
 		go c.run(ctx)
 	}
-	return c
+	return c, nil
 }
 
 func (c *Cache) run(ctx context.Context) {
@@ -68,7 +108,7 @@ func (c *Cache) deleteExpired() {
 	c.Lock()
 	for k, v := range c.items {
 		if v.expiration > 0 && now > v.expiration {
-			delete(c.items, k)
+			c.remove(k, v)
 		}
 	}
 	c.Unlock()
@@ -78,30 +118,192 @@ func (c *Cache) Set(key string, value []api.SourceTuple) {
 	if (value == nil || len(value) == 0) && !c.cacheMissingKey {
 		return
 	}
+	var expiration int64
+	if c.expireTime > 0 {
+		expiration = conf.GetNowInMilli() + int64(c.expireTime*1000)
+	}
 	c.Lock()
 	defer c.Unlock()
-	if c.expireTime > 0 {
-		c.items[key] = &item{data: value, expiration: conf.GetNowInMilli() + int64(c.expireTime*1000)}
-	} else {
-		c.items[key] = &item{data: value}
+	c.set(key, value, expiration, 0)
+}
+
+// set adds or updates the key. The caller must hold the lock.
+func (c *Cache) set(key string, value []api.SourceTuple, expiration int64, freq int) {
+	if c.items == nil { // closed
+		return
+	}
+	if v, ok := c.items[key]; ok {
+		v.data = value
+		v.expiration = expiration
+		if c.evictor != nil {
+			c.evictor.touch(v)
+		}
+		return
+	}
+	it := &item{key: key, data: value, expiration: expiration, freq: freq}
+	if c.evictor != nil {
+		for len(c.items) >= c.size {
+			victim := c.evictor.victim()
+			c.remove(victim.key, victim)
+			c.evictions++
+		}
+		c.evictor.add(it)
+	}
+	c.items[key] = it
+}
+
+func (c *Cache) remove(key string, it *item) {
+	delete(c.items, key)
+	if c.evictor != nil {
+		c.evictor.remove(it)
 	}
 }
 
 func (c *Cache) Get(key string) ([]api.SourceTuple, bool) {
-	c.RLock()
-	defer c.RUnlock()
+	if c.evictor != nil {
+		// The access is recorded for eviction
+		c.Lock()
+		defer c.Unlock()
+	} else {
+		c.RLock()
+		defer c.RUnlock()
+	}
 	if v, ok := c.items[key]; ok {
 		if v.expiration > 0 && conf.GetNowInMilli() > v.expiration {
 			return nil, false
 		}
+		if c.evictor != nil {
+			c.evictor.touch(v)
+		}
 		return v.data, true
 	}
 	return nil, false
 }
 
+// Stats returns the number of the keys and the number of the evicted keys
+func (c *Cache) Stats() (size int, evictions int64) {
+	c.RLock()
+	defer c.RUnlock()
+	return len(c.items), c.evictions
+}
+
 func (c *Cache) Close() {
 	if c.cancel != nil {
 		c.cancel()
 	}
+	c.Lock()
+	defer c.Unlock()
 	c.items = nil
 }
+
+// evictor tracks the access of the keys to select the key to evict
+type evictor interface {
+	add(it *item)
+	touch(it *item)
+	remove(it *item)
+	victim() *item
+	// ordered returns the items from the first to the last to evict
+	ordered() []*item
+}
+
+// lruEvictor keeps the items from the most recently used to the least recently used
+type lruEvictor struct {
+	l *list.List
+}
+
+func (e *lruEvictor) add(it *item) {
+	it.elem = e.l.PushFront(it)
+}
+
+func (e *lruEvictor) touch(it *item) {
+	e.l.MoveToFront(it.elem)
+}
+
+func (e *lruEvictor) remove(it *item) {
+	e.l.Remove(it.elem)
+}
+
+func (e *lruEvictor) victim() *item {
+	return e.l.Back().Value.(*item)
+}
+
+func (e *lruEvictor) ordered() []*item {
+	result := make([]*item, 0, e.l.Len())
+	for el := e.l.Back(); el != nil; el = el.Prev() {
+		result = append(result, el.Value.(*item))
+	}
+	return result
+}
+
+// lfuEvictor keeps the items in the buckets of the access count. The items in a bucket are ordered by the recency.
+type lfuEvictor struct {
+	buckets map[int]*list.List
+	minFreq int
+}
+
+func (e *lfuEvictor) add(it *item) {
+	if it.freq < 1 {
+		it.freq = 1
+	}
+	e.push(it)
+	if it.freq < e.minFreq || len(e.buckets) == 1 {
+		e.minFreq = it.freq
+	}
+}
+
+func (e *lfuEvictor) push(it *item) {
+	b, ok := e.buckets[it.freq]
+	if !ok {
+		b = list.New()
+		e.buckets[it.freq] = b
+	}
+	it.elem = b.PushFront(it)
+}
+
+func (e *lfuEvictor) touch(it *item) {
+	e.detach(it)
+	if _, ok := e.buckets[e.minFreq]; !ok && e.minFreq == it.freq {
+		e.minFreq++
+	}
+	it.freq++
+	e.push(it)
+}
+
+func (e *lfuEvictor) detach(it *item) {
+	b := e.buckets[it.freq]
+	b.Remove(it.elem)
+	if b.Len() == 0 {
+		delete(e.buckets, it.freq)
+	}
+}
+
+func (e *lfuEvictor) remove(it *item) {
+	e.detach(it)
+	if _, ok := e.buckets[e.minFreq]; !ok && len(e.buckets) > 0 {
+		e.minFreq = 0
+		for f := range e.buckets {
+			if e.minFreq == 0 || f < e.minFreq {
+				e.minFreq = f
+			}
+		}
+	}
+}
+
+func (e *lfuEvictor) victim() *item {
+	return e.buckets[e.minFreq].Back().Value.(*item)
+}
+
+func (e *lfuEvictor) ordered() []*item {
+	freqs := make([]int, 0, len(e.buckets))
+	for f := range e.buckets {
+		freqs = append(freqs, f)
+	}
+	sort.Ints(freqs)
+	var result []*item
+	for _, f := range freqs {
+		for el := e.buckets[f].Back(); el != nil; el = el.Prev() {
+			result = append(result, el.Value.(*item))
+		}
+	}
+	return result
+}
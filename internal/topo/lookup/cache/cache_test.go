@@ -20,8 +20,11 @@ import (
 	"time"
 
 	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/store"
 	"github.com/lf-edge/ekuiper/pkg/api"
 )
 
@@ -120,3 +123,101 @@ func TestNoExpiration(t *testing.T) {
 		return
 	}
 }
+
+func tuples(a int) []api.SourceTuple {
+	return []api.SourceTuple{api.NewDefaultSourceTupleWithTime(map[string]interface{}{"a": a}, nil, conf.GetNow())}
+}
+
+func keysOf(c *Cache) []string {
+	var keys []string
+	for _, it := range c.evictor.ordered() {
+		keys = append(keys, it.key)
+	}
+	return keys
+}
+
+func TestSizedCache(t *testing.T) {
+	_, err := NewSizedCache(0, false, -1, "")
+	assert.EqualError(t, err, "invalid cache size -1, must not be negative")
+	_, err = NewSizedCache(0, false, 2, "fifo")
+	assert.EqualError(t, err, "invalid cache policy fifo, must be lru or lfu")
+
+	tests := []struct {
+		policy    string
+		keys      []string
+		evictions int64
+	}{
+		// b is evicted as the least recently used
+		{policy: PolicyLRU, keys: []string{"c", "a", "d"}, evictions: 1},
+		// c is evicted as the least frequently used
+		{policy: PolicyLFU, keys: []string{"d", "a", "b"}, evictions: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			c, err := NewSizedCache(0, false, 3, tt.policy)
+			require.NoError(t, err)
+			defer c.Close()
+			c.Set("a", tuples(1))
+			c.Set("b", tuples(2))
+			c.Set("c", tuples(3))
+			_, _ = c.Get("a")
+			_, _ = c.Get("b")
+			_, _ = c.Get("c")
+			_, _ = c.Get("a")
+			_, _ = c.Get("b")
+			_, _ = c.Get("c")
+			_, _ = c.Get("a")
+			_, _ = c.Get("b")
+			if tt.policy == PolicyLRU {
+				_, _ = c.Get("c")
+				_, _ = c.Get("a")
+			}
+			c.Set("d", tuples(4))
+			assert.Equal(t, tt.keys, keysOf(c))
+			size, evictions := c.Stats()
+			assert.Equal(t, 3, size)
+			assert.Equal(t, tt.evictions, evictions)
+			r, ok := c.Get("d")
+			assert.True(t, ok)
+			assert.Equal(t, tuples(4)[0].Message(), r[0].Message())
+		})
+	}
+}
+
+func TestPersist(t *testing.T) {
+	require.NoError(t, store.SetupDefault())
+	defer func() {
+		require.NoError(t, DeleteSaved("persistRule"))
+	}()
+	mc := conf.Clock.(*clock.Mock)
+	c, err := NewSizedCache(10, true, 3, PolicyLFU)
+	require.NoError(t, err)
+	c.Set("a", tuples(1))
+	c.Set("b", nil)
+	mc.Add(5 * time.Second)
+	c.Set("c", tuples(3))
+	_, _ = c.Get("a")
+	require.NoError(t, c.Save("persistRule/lookup"))
+	c.Close()
+
+	// a and b are expired
+	mc.Add(6 * time.Second)
+	c, err = NewSizedCache(10, true, 3, PolicyLFU)
+	require.NoError(t, err)
+	defer c.Close()
+	count, err := c.Load("persistRule/lookup")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	r, ok := c.Get("c")
+	require.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"a": 3}, r[0].Message())
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+
+	require.NoError(t, DeleteSaved("persistRule"))
+	c2 := NewCache(0, false)
+	defer c2.Close()
+	count, err = c2.Load("persistRule/lookup")
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
@@ -0,0 +1,139 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"encoding/gob"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/store"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/kv"
+)
+
+func init() {
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+	gob.Register(time.Time{})
+}
+
+// Entry is the persisted form of a cached key
+type Entry struct {
+	Key        string
+	Messages   []map[string]interface{}
+	Metas      []map[string]interface{}
+	Expiration int64
+	Freq       int
+}
+
+var (
+	cacheDb   kv.KeyValue
+	cacheOnce sync.Once
+	cacheErr  error
+)
+
+func getCacheDb() (kv.KeyValue, error) {
+	cacheOnce.Do(func() {
+		cacheDb, cacheErr = store.GetKV("lookupCache")
+	})
+	return cacheDb, cacheErr
+}
+
+// Save persists the cached keys which are not expired under the name. The keys are saved in the eviction order so
+// that the recency is kept after loading.
+func (c *Cache) Save(name string) error {
+	db, err := getCacheDb()
+	if err != nil {
+		return err
+	}
+	c.RLock()
+	var items []*item
+	if c.evictor != nil {
+		items = c.evictor.ordered()
+	} else {
+		items = make([]*item, 0, len(c.items))
+		for _, it := range c.items {
+			items = append(items, it)
+		}
+	}
+	now := conf.GetNowInMilli()
+	entries := make([]*Entry, 0, len(items))
+	for _, it := range items {
+		if it.expiration > 0 && now > it.expiration {
+			continue
+		}
+		e := &Entry{Key: it.key, Expiration: it.expiration, Freq: it.freq}
+		for _, t := range it.data {
+			e.Messages = append(e.Messages, t.Message())
+			e.Metas = append(e.Metas, t.Meta())
+		}
+		entries = append(entries, e)
+	}
+	c.RUnlock()
+	return db.Set(name, entries)
+}
+
+// Load restores the cached keys saved under the name and returns the number of the loaded keys. The expired keys are
+// skipped.
+func (c *Cache) Load(name string) (int, error) {
+	db, err := getCacheDb()
+	if err != nil {
+		return 0, err
+	}
+	var entries []*Entry
+	if ok, err := db.Get(name, &entries); err != nil || !ok {
+		return 0, err
+	}
+	now := conf.GetNowInMilli()
+	rcvTime := conf.GetNow()
+	c.Lock()
+	defer c.Unlock()
+	count := 0
+	for _, e := range entries {
+		if e.Expiration > 0 && now > e.Expiration {
+			continue
+		}
+		data := make([]api.SourceTuple, 0, len(e.Messages))
+		for i, m := range e.Messages {
+			data = append(data, api.NewDefaultSourceTupleWithTime(m, e.Metas[i], rcvTime))
+		}
+		c.set(e.Key, data, e.Expiration, e.Freq)
+		count++
+	}
+	return count, nil
+}
+
+// DeleteSaved deletes the caches saved by the lookup nodes of a rule
+func DeleteSaved(ruleId string) error {
+	db, err := getCacheDb()
+	if err != nil {
+		return err
+	}
+	keys, err := db.Keys()
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if strings.HasPrefix(k, ruleId+"/") {
+			if err := db.Delete(k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+const DeadLetterEmitter = "$$deadLetter"
+
+// NewDeadLetterNode creates the sink node to receive the failed records of a rule.
+// The action is defined in the same way as a rule action like {"memory": {"topic": "dlq"}}
+func NewDeadLetterNode(action map[string]interface{}) (*SinkNode, error) {
+	if len(action) != 1 {
+		return nil, fmt.Errorf("deadLetter must define exactly one sink action but found %d", len(action))
+	}
+	for name, a := range action {
+		props, ok := a.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expect map[string]interface{} type for the deadLetter properties, but found %v", a)
+		}
+		return NewSinkNode("deadLetter_"+name, name, props), nil
+	}
+	return nil, nil
+}
+
+// sendDeadLetter sends the failed data with the error to the dead letter sink of the rule if configured.
+// It never blocks the caller so the record is dropped if the dead letter sink is busy.
+func sendDeadLetter(ctx api.StreamContext, payload interface{}, err error) {
+	ch, ok := ctx.Value(context.DeadLetterKey).(chan<- interface{})
+	if !ok {
+		return
+	}
+	now := conf.GetNowInMilli()
+	record := &xsql.Tuple{
+		Emitter: DeadLetterEmitter,
+		Message: map[string]interface{}{
+			"rule":      ctx.GetRuleId(),
+			"node":      ctx.GetOpId(),
+			"error":     err.Error(),
+			"payload":   deadLetterPayload(payload),
+			"timestamp": now,
+		},
+		Timestamp: now,
+	}
+	select {
+	case ch <- record:
+	default:
+		ctx.GetLogger().Warnf("dead letter buffer is full, drop record %v", record.Message)
+	}
+}
+
+func deadLetterPayload(data interface{}) interface{} {
+	switch d := data.(type) {
+	case []byte:
+		return string(d)
+	case xsql.Collection:
+		return d.ToMaps()
+	case xsql.Row:
+		return d.ToMap()
+	default:
+		return d
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+func TestNewDeadLetterNode(t *testing.T) {
+	tests := []struct {
+		action map[string]interface{}
+		name   string
+		err    string
+	}{
+		{
+			action: map[string]interface{}{"memory": map[string]interface{}{"topic": "dlq"}},
+			name:   "deadLetter_memory",
+		}, {
+			action: map[string]interface{}{},
+			err:    "deadLetter must define exactly one sink action but found 0",
+		}, {
+			action: map[string]interface{}{"memory": map[string]interface{}{}, "log": map[string]interface{}{}},
+			err:    "deadLetter must define exactly one sink action but found 2",
+		}, {
+			action: map[string]interface{}{"memory": "dlq"},
+			err:    "expect map[string]interface{} type for the deadLetter properties, but found dlq",
+		},
+	}
+	for _, tt := range tests {
+		n, err := NewDeadLetterNode(tt.action)
+		if tt.err != "" {
+			assert.EqualError(t, err, tt.err)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, tt.name, n.GetName())
+	}
+}
+
+type errOp struct{}
+
+func (o *errOp) Apply(_ api.StreamContext, _ interface{}, _ *xsql.FunctionValuer, _ *xsql.AggregateFunctionValuer) interface{} {
+	return errors.New("eval error")
+}
+
+type errSink struct{}
+
+func (s *errSink) Open(_ api.StreamContext) error {
+	return nil
+}
+
+func (s *errSink) Configure(_ map[string]interface{}) error {
+	return nil
+}
+
+func (s *errSink) Collect(_ api.StreamContext, _ interface{}) error {
+	return errors.New("sink error")
+}
+
+func (s *errSink) Close(_ api.StreamContext) error {
+	return nil
+}
+
+func deadLetterContext(opId string, dl chan<- interface{}) (api.StreamContext, func()) {
+	contextLogger := conf.Log.WithField("rule", "TestDeadLetter")
+	tempStore, _ := state.CreateStore("TestDeadLetter", api.AtMostOnce)
+	cctx, cancel := context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithCancel()
+	ctx := cctx.WithMeta("TestDeadLetter", opId, tempStore)
+	return context.WithValue(ctx.(*context.DefaultContext), context.DeadLetterKey, dl), cancel
+}
+
+func receiveDeadLetter(t *testing.T, dl chan interface{}) map[string]interface{} {
+	select {
+	case r := <-dl:
+		tuple, ok := r.(*xsql.Tuple)
+		require.True(t, ok)
+		assert.Equal(t, DeadLetterEmitter, tuple.Emitter)
+		return tuple.Message
+	case <-time.After(time.Second):
+		t.Fatal("dead letter is not received")
+	}
+	return nil
+}
+
+func TestDeadLetterOperation(t *testing.T) {
+	dl := make(chan interface{}, 10)
+	ctx, cancel := deadLetterContext("project", dl)
+	defer cancel()
+	op := New("project", &api.RuleOption{BufferLength: 10, SendError: true})
+	op.SetOperation(&errOp{})
+	out := make(chan interface{}, 10)
+	require.NoError(t, op.AddOutput(out, "sink"))
+	op.Exec(ctx, make(chan error, 1))
+	op.input <- &xsql.Tuple{Emitter: "demo", Message: map[string]interface{}{"a": 1}}
+
+	assert.Equal(t, map[string]interface{}{
+		"rule":      "TestDeadLetter",
+		"node":      "project",
+		"error":     "eval error",
+		"payload":   map[string]interface{}{"a": 1},
+		"timestamp": conf.GetNowInMilli(),
+	}, receiveDeadLetter(t, dl))
+	// The error is still sent downstream as sendError is on
+	select {
+	case r := <-out:
+		assert.EqualError(t, r.(error), "eval error")
+	case <-time.After(time.Second):
+		t.Fatal("error is not sent downstream")
+	}
+}
+
+func TestDeadLetterSink(t *testing.T) {
+	conf.InitConf()
+	dl := make(chan interface{}, 10)
+	ctx, cancel := deadLetterContext("errSink", dl)
+	defer cancel()
+	s := NewSinkNodeWithSink("errSink", &errSink{}, nil)
+	s.Open(ctx, make(chan error, 1))
+	s.input <- []map[string]interface{}{{"a": 1}}
+
+	assert.Equal(t, map[string]interface{}{
+		"rule":      "TestDeadLetter",
+		"node":      "errSink",
+		"error":     "sink error",
+		"payload":   []map[string]interface{}{{"a": 1}},
+		"timestamp": conf.GetNowInMilli(),
+	}, receiveDeadLetter(t, dl))
+}
+
+func TestDeadLetterNotConfigured(t *testing.T) {
+	contextLogger := conf.Log.WithField("rule", "TestDeadLetter")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	// should not panic or block
+	sendDeadLetter(ctx, []byte("abc"), errors.New("decode error"))
+	assert.Equal(t, "abc", deadLetterPayload([]byte("abc")))
+}
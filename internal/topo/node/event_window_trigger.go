@@ -195,6 +195,7 @@ func (o *WindowOperator) execEventWindow(ctx api.StreamContext, inputs []*xsql.T
 					o.triggerTS = append(o.triggerTS, d.GetTimestamp())
 				}
 				inputs = append(inputs, d)
+				o.traceTuple(ctx, d, nil)
 				o.statManager.ProcessTimeEnd()
 				_ = ctx.PutState(WindowInputsKey, inputs)
 			default:
@@ -0,0 +1,258 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"encoding/gob"
+	"fmt"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lf-edge/ekuiper/internal/pkg/tracer"
+	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+	"github.com/lf-edge/ekuiper/pkg/infra"
+)
+
+const IntervalJoinStateKey = "$$intervalJoinState"
+
+// IntervalJoinEntry is a buffered tuple waiting to join the tuples of the other stream
+type IntervalJoinEntry struct {
+	Tuple *xsql.Tuple
+	// Matched is used by outer join to emit the tuple without match when it expires
+	Matched bool
+}
+
+// IntervalJoinState is the buffered tuples of both streams which are saved in the checkpoint
+type IntervalJoinState struct {
+	Lefts  []*IntervalJoinEntry
+	Rights []*IntervalJoinEntry
+}
+
+func init() {
+	gob.Register(&IntervalJoinState{})
+}
+
+// IntervalJoinNode joins two streams by the event time interval without window.
+// It must run in event time mode so that the tuples arrive in order from the watermark node. Each tuple is joined
+// with the buffered tuples of the other stream in the interval, and the buffered tuples are removed once they cannot
+// be joined by any later tuple according to the watermark.
+type IntervalJoinNode struct {
+	*defaultSinkNode
+	statManager metric.StatManager
+	left        string
+	right       string
+	joinType    ast.JoinType
+	condition   ast.Expr
+	// The event time of the right tuple minus the left tuple must be in [lower, upper]
+	lower int64
+	upper int64
+	state *IntervalJoinState
+}
+
+func NewIntervalJoinNode(name string, left string, right string, joinType ast.JoinType, condition ast.Expr, lower int64, upper int64, options *api.RuleOption) *IntervalJoinNode {
+	return &IntervalJoinNode{
+		defaultSinkNode: &defaultSinkNode{
+			input: make(chan interface{}, options.BufferLength),
+			defaultNode: &defaultNode{
+				outputs:   make(map[string]chan<- interface{}),
+				name:      name,
+				sendError: options.SendError,
+			},
+		},
+		left:      left,
+		right:     right,
+		joinType:  joinType,
+		condition: condition,
+		lower:     lower,
+		upper:     upper,
+		state:     &IntervalJoinState{},
+	}
+}
+
+func (n *IntervalJoinNode) Exec(ctx api.StreamContext, errCh chan<- error) {
+	n.ctx = ctx
+	log := ctx.GetLogger()
+	log.Debugf("IntervalJoinNode %s is started", n.name)
+
+	if len(n.outputs) <= 0 {
+		infra.DrainError(ctx, fmt.Errorf("no output channel found"), errCh)
+		return
+	}
+	stats, err := metric.NewStatManager(ctx, "op")
+	if err != nil {
+		infra.DrainError(ctx, fmt.Errorf("fail to create stat manager"), errCh)
+		return
+	}
+	n.statManager = stats
+	n.statManagers = []metric.StatManager{stats}
+	if s, err := ctx.GetState(IntervalJoinStateKey); err == nil {
+		switch st := s.(type) {
+		case *IntervalJoinState:
+			n.state = st
+			log.Infof("Restore interval join state with %d left tuples and %d right tuples", len(st.Lefts), len(st.Rights))
+		case nil:
+			log.Debugf("Restore interval join state, nothing")
+		default:
+			infra.DrainError(ctx, fmt.Errorf("restore interval join state %v error, invalid type", st), errCh)
+			return
+		}
+	} else {
+		log.Warnf("Restore interval join state fails: %s", err)
+	}
+	go func() {
+		err := infra.SafeRun(func() error {
+			fv, _ := xsql.NewFunctionValuersForOp(ctx)
+			for {
+				log.Debugf("IntervalJoinNode %s is looping", n.name)
+				select {
+				case item, opened := <-n.input:
+					processed := false
+					if item, processed = n.preprocess(item); processed {
+						break
+					}
+					n.statManager.IncTotalRecordsIn()
+					n.statManager.ProcessTimeStart()
+					if !opened {
+						n.statManager.IncTotalExceptions("input channel closed")
+						break
+					}
+					switch d := item.(type) {
+					case error:
+						_ = n.Broadcast(d)
+						n.statManager.IncTotalExceptions(d.Error())
+					case *xsql.WatermarkTuple:
+						sets := &xsql.JoinTuples{Content: make([]*xsql.JoinTuple, 0)}
+						n.evict(d.GetTimestamp(), sets)
+						n.emit(sets)
+						_ = ctx.PutState(IntervalJoinStateKey, n.state)
+					case *xsql.Tuple:
+						log.Debugf("IntervalJoinNode receive tuple input %s", d)
+						sets := &xsql.JoinTuples{Content: make([]*xsql.JoinTuple, 0)}
+						// The tuples are sent in order by the watermark node, so the later tuples cannot be earlier than it
+						n.evict(d.GetTimestamp(), sets)
+						span := tracer.StartSpan(ctx, d, trace.SpanKindInternal)
+						err := n.join(d, fv, sets)
+						tracer.EndSpan(span, err)
+						if err != nil {
+							_ = n.Broadcast(err)
+							n.statManager.IncTotalExceptions(err.Error())
+						} else {
+							n.emit(sets)
+						}
+						_ = ctx.PutState(IntervalJoinStateKey, n.state)
+					default:
+						e := fmt.Errorf("run interval join error: expect *xsql.Tuple type but got %[1]T(%[1]v)", d)
+						_ = n.Broadcast(e)
+						n.statManager.IncTotalExceptions(e.Error())
+					}
+					n.statManager.ProcessTimeEnd()
+					n.statManager.SetBufferLength(int64(len(n.input)))
+				case <-ctx.Done():
+					log.Infoln("Cancelling interval join node....")
+					return nil
+				}
+			}
+		})
+		if err != nil {
+			infra.DrainError(ctx, err, errCh)
+		}
+	}()
+}
+
+// join the tuple with the buffered tuples of the other stream and buffer it
+func (n *IntervalJoinNode) join(d *xsql.Tuple, fv *xsql.FunctionValuer, sets *xsql.JoinTuples) error {
+	var (
+		isLeft  bool
+		others  []*IntervalJoinEntry
+		matched bool
+	)
+	switch d.Emitter {
+	case n.left:
+		isLeft, others = true, n.state.Rights
+	case n.right:
+		others = n.state.Lefts
+	default:
+		return fmt.Errorf("run interval join error: unknown emitter %s", d.Emitter)
+	}
+	for _, e := range others {
+		left, right := e.Tuple, d
+		if isLeft {
+			left, right = d, e.Tuple
+		}
+		diff := right.Timestamp - left.Timestamp
+		if diff < n.lower || diff > n.upper {
+			continue
+		}
+		merged := &xsql.JoinTuple{}
+		merged.AddTuple(left)
+		merged.AddTuple(right)
+		if n.condition != nil {
+			ve := &xsql.ValuerEval{Valuer: xsql.MultiValuer(merged, fv)}
+			switch r := ve.Eval(n.condition).(type) {
+			case error:
+				return fmt.Errorf("run interval join error: %s", r)
+			case bool:
+				if !r {
+					continue
+				}
+			default:
+				return fmt.Errorf("run interval join error: invalid join condition that returns non-bool value %[1]T(%[1]v)", r)
+			}
+		}
+		e.Matched = true
+		matched = true
+		sets.Content = append(sets.Content, merged)
+	}
+	entry := &IntervalJoinEntry{Tuple: d, Matched: matched}
+	if isLeft {
+		n.state.Lefts = append(n.state.Lefts, entry)
+	} else {
+		n.state.Rights = append(n.state.Rights, entry)
+	}
+	return nil
+}
+
+// evict removes the buffered tuples which cannot be joined by any tuple later than the watermark.
+// For outer join, the removed tuples without match are emitted alone.
+func (n *IntervalJoinNode) evict(watermark int64, sets *xsql.JoinTuples) {
+	emitLeft := n.joinType == ast.LEFT_JOIN || n.joinType == ast.FULL_JOIN
+	emitRight := n.joinType == ast.RIGHT_JOIN || n.joinType == ast.FULL_JOIN
+	n.state.Lefts = n.evictEntries(n.state.Lefts, watermark-n.upper, emitLeft, sets)
+	n.state.Rights = n.evictEntries(n.state.Rights, watermark+n.lower, emitRight, sets)
+}
+
+// evictEntries removes the entries whose timestamp is less than the expire time. The entries are in time order.
+func (n *IntervalJoinNode) evictEntries(entries []*IntervalJoinEntry, expire int64, emit bool, sets *xsql.JoinTuples) []*IntervalJoinEntry {
+	i := 0
+	for ; i < len(entries) && entries[i].Tuple.Timestamp < expire; i++ {
+		if emit && !entries[i].Matched {
+			merged := &xsql.JoinTuple{}
+			merged.AddTuple(entries[i].Tuple)
+			sets.Content = append(sets.Content, merged)
+		}
+		entries[i] = nil
+	}
+	return entries[i:]
+}
+
+func (n *IntervalJoinNode) emit(sets *xsql.JoinTuples) {
+	if sets.Len() > 0 {
+		_ = n.Broadcast(sets)
+		n.statManager.IncTotalRecordsOut()
+	}
+}
@@ -17,6 +17,9 @@ package node
 import (
 	"fmt"
 
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lf-edge/ekuiper/internal/pkg/tracer"
 	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
 	"github.com/lf-edge/ekuiper/internal/xsql"
 	"github.com/lf-edge/ekuiper/pkg/api"
@@ -112,7 +115,9 @@ func (n *JoinAlignNode) Exec(ctx api.StreamContext, errCh chan<- error) {
 						_ = n.Broadcast(d)
 					case *xsql.Tuple:
 						log.Debugf("JoinAlignNode receive tuple input %s", d)
+						span := tracer.StartSpan(ctx, d, trace.SpanKindInternal)
 						n.alignBatch(ctx, d)
+						tracer.EndSpan(span, nil)
 					case *xsql.WindowTuples:
 						if d.WindowRange != nil { // real window
 							log.Debugf("JoinAlignNode receive window input %s", d)
@@ -15,9 +15,17 @@
 package node
 
 import (
+	"errors"
 	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/namespace"
+	"github.com/lf-edge/ekuiper/internal/pkg/tracer"
+	"github.com/lf-edge/ekuiper/internal/topo/checkpoint"
 	"github.com/lf-edge/ekuiper/internal/topo/lookup"
 	"github.com/lf-edge/ekuiper/internal/topo/lookup/cache"
 	nodeConf "github.com/lf-edge/ekuiper/internal/topo/node/conf"
@@ -33,8 +41,39 @@ type LookupConf struct {
 	Cache           bool `json:"cache"`
 	CacheTTL        int  `json:"cacheTtl"`
 	CacheMissingKey bool `json:"cacheMissingKey"`
+	// CacheSize is the max number of the cached keys, 0 means unbounded
+	CacheSize int `json:"cacheSize"`
+	// CachePolicy is the eviction policy when the cache is full, lru or lfu
+	CachePolicy string `json:"cachePolicy"`
+	// CachePersist saves the cache when the rule stops and loads it when the rule starts
+	CachePersist bool `json:"cachePersist"`
+	// Preload reads the whole table into the cache when the rule starts
+	Preload bool `json:"preload"`
+	// BatchSize is the max number of lookup values in one batch query if the source supports it
+	BatchSize int `json:"batchSize"`
+	// Async looks up the inputs concurrently and emits the results in the input order
+	Async bool `json:"async"`
+	// MaxInflight is the max number of the concurrent lookups in async mode
+	MaxInflight int `json:"maxInflight"`
 }
 
+var errInputClosed = errors.New("input channel closed")
+
+const (
+	defaultLookupBatchSize   = 100
+	defaultLookupMaxInflight = 16
+
+	LookupCacheHitTotal  = "lookup_cache_hit_total"
+	LookupCacheMissTotal = "lookup_cache_miss_total"
+	LookupCacheHitRatio  = "lookup_cache_hit_ratio"
+	LookupRequestTotal   = "lookup_request_total"
+	LookupLatencyUs      = "lookup_latency_us"
+	LookupCacheSize      = "lookup_cache_size"
+	LookupCacheEvictions = "lookup_cache_eviction_total"
+)
+
+var LookupMetricNames = []string{LookupCacheHitTotal, LookupCacheMissTotal, LookupCacheHitRatio, LookupRequestTotal, LookupLatencyUs, LookupCacheSize, LookupCacheEvictions}
+
 // LookupNode will look up the data from the external source when receiving an event
 type LookupNode struct {
 	*defaultSinkNode
@@ -47,6 +86,43 @@ type LookupNode struct {
 	conf       *LookupConf
 	fields     []string
 	keys       []string
+	stats      lookupStats
+	cache      *cache.Cache
+}
+
+// lookupStats are the metrics of the cache and the queries to the lookup source. They are updated by the concurrent
+// lookups in async mode.
+type lookupStats struct {
+	hits     int64
+	misses   int64
+	requests int64
+	// the total latency of the requests in microseconds
+	latency int64
+}
+
+func (s *lookupStats) observe(start time.Time) {
+	atomic.AddInt64(&s.requests, 1)
+	atomic.AddInt64(&s.latency, int64(time.Since(start)/time.Microsecond))
+}
+
+// lookupRow is a row to look up with its evaluated lookup values
+type lookupRow struct {
+	row xsql.TupleRow
+	cvs []interface{}
+	key string
+	// if any of the value is nil, the lookup will always return empty result
+	hasNil bool
+}
+
+// lookupReq is an input of the node. The lookup values are evaluated in the node goroutine so that the function
+// valuer is not shared by the concurrent lookups.
+type lookupReq struct {
+	start time.Time
+	// the input which does not need lookup like the error and the watermark
+	pass interface{}
+	rows []*lookupRow
+	// the span of the tuple input, it ends when the result is emitted so that it covers the async lookup
+	span trace.Span
 }
 
 func NewLookupNode(name string, fields []string, keys []string, joinType ast.JoinType, vals []ast.Expr, srcOptions *ast.Options, options *api.RuleOption) (*LookupNode, error) {
@@ -62,6 +138,23 @@ func NewLookupNode(name string, fields []string, keys []string, joinType ast.Joi
 			return nil, err
 		}
 	}
+	if lookupConf.BatchSize < 0 {
+		return nil, fmt.Errorf("invalid lookup batchSize %d, must not be negative", lookupConf.BatchSize)
+	}
+	if lookupConf.MaxInflight < 0 {
+		return nil, fmt.Errorf("invalid lookup maxInflight %d, must not be negative", lookupConf.MaxInflight)
+	}
+	if lookupConf.CacheSize < 0 {
+		return nil, fmt.Errorf("invalid lookup cacheSize %d, must not be negative", lookupConf.CacheSize)
+	}
+	switch lookupConf.CachePolicy {
+	case "", cache.PolicyLRU, cache.PolicyLFU:
+	default:
+		return nil, fmt.Errorf("invalid lookup cachePolicy %s, must be %s or %s", lookupConf.CachePolicy, cache.PolicyLRU, cache.PolicyLFU)
+	}
+	if (lookupConf.CachePersist || lookupConf.Preload) && !lookupConf.Cache {
+		return nil, fmt.Errorf("lookup cachePersist and preload require the cache to be enabled")
+	}
 	n := &LookupNode{
 		fields:     fields,
 		keys:       keys,
@@ -98,18 +191,77 @@ func (n *LookupNode) Exec(ctx api.StreamContext, errCh chan<- error) {
 	}
 	n.statManager = stats
 	n.statManagers = []metric.StatManager{stats}
+	if n.conf.Cache {
+		n.cache, err = cache.NewSizedCache(n.conf.CacheTTL, n.conf.CacheMissingKey, n.conf.CacheSize, n.conf.CachePolicy)
+		if err != nil {
+			infra.DrainError(ctx, err, errCh)
+			return
+		}
+	}
 	go func() {
 		err := infra.SafeRun(func() error {
-			ns, err := lookup.Attach(n.name)
+			// The lookup table instance is named by the qualified name in the namespace of the rule
+			tableName := namespace.Qualify(namespace.Of(ctx.GetRuleId()), n.name)
+			ns, err := lookup.Attach(tableName)
 			if err != nil {
 				return err
 			}
-			defer lookup.Detach(n.name)
+			defer lookup.Detach(tableName)
 			fv, _ := xsql.NewFunctionValuersForOp(ctx)
-			var c *cache.Cache
-			if n.conf.Cache {
-				c = cache.NewCache(n.conf.CacheTTL, n.conf.CacheMissingKey)
+			c := n.cache
+			if c != nil {
 				defer c.Close()
+				// The cache is saved per lookup node of the rule
+				cacheName := ctx.GetRuleId() + "/" + n.name
+				if n.conf.CachePersist {
+					defer func() {
+						if err := c.Save(cacheName); err != nil {
+							log.Warnf("save lookup cache %s error: %v", cacheName, err)
+						}
+					}()
+					if count, err := c.Load(cacheName); err != nil {
+						log.Warnf("load lookup cache %s error: %v", cacheName, err)
+					} else {
+						log.Infof("load %d keys into lookup cache %s", count, cacheName)
+					}
+				}
+				if n.conf.Preload {
+					n.preload(ctx, ns, c)
+				}
+			}
+			// In async mode, the lookups run concurrently and their results are emitted by the input order
+			var (
+				futures chan chan *lookupResult
+				sem     chan struct{}
+			)
+			if n.conf.Async {
+				maxInflight := n.conf.MaxInflight
+				if maxInflight == 0 {
+					maxInflight = defaultLookupMaxInflight
+				}
+				futures = make(chan chan *lookupResult, maxInflight)
+				sem = make(chan struct{}, maxInflight)
+				go func() {
+					err := infra.SafeRun(func() error {
+						for {
+							select {
+							case f := <-futures:
+								select {
+								case r := <-f:
+									n.emit(r)
+									<-sem
+								case <-ctx.Done():
+									return nil
+								}
+							case <-ctx.Done():
+								return nil
+							}
+						}
+					})
+					if err != nil {
+						infra.DrainError(ctx, err, errCh)
+					}
+				}()
 			}
 			// Start the lookup source loop
 			for {
@@ -117,65 +269,43 @@ func (n *LookupNode) Exec(ctx api.StreamContext, errCh chan<- error) {
 				select {
 				// process incoming item from both streams(transformed) and tables
 				case item, opened := <-n.input:
+					// The barrier must be forwarded after the results of the inputs before it
+					if n.conf.Async && isBarrier(item) && !waitInflight(ctx, sem) {
+						log.Infoln("Cancelling lookup node....")
+						return nil
+					}
 					processed := false
 					if item, processed = n.preprocess(item); processed {
 						break
 					}
-					n.statManager.IncTotalRecordsIn()
-					n.statManager.ProcessTimeStart()
+					var req *lookupReq
 					if !opened {
-						n.statManager.IncTotalExceptions("input channel closed")
+						req = &lookupReq{start: time.Now(), pass: errInputClosed}
+					} else {
+						req = n.prepare(ctx, item, fv)
+					}
+					if !n.conf.Async {
+						n.emit(n.execute(ctx, ns, c, req))
 						break
 					}
-					switch d := item.(type) {
-					case error:
-						_ = n.Broadcast(d)
-						n.statManager.IncTotalExceptions(d.Error())
-					case *xsql.WatermarkTuple:
-						_ = n.Broadcast(d)
-					case xsql.TupleRow:
-						log.Debugf("Lookup Node receive tuple input %s", d)
-						n.statManager.ProcessTimeStart()
-						sets := &xsql.JoinTuples{Content: make([]*xsql.JoinTuple, 0)}
-						err := n.lookup(ctx, d, fv, ns, sets, c)
-						if err != nil {
-							_ = n.Broadcast(err)
-							n.statManager.IncTotalExceptions(err.Error())
-						} else {
-							_ = n.Broadcast(sets)
-							n.statManager.IncTotalRecordsOut()
-						}
-						n.statManager.ProcessTimeEnd()
-						n.statManager.SetBufferLength(int64(len(n.input)))
-					case *xsql.WindowTuples:
-						log.Debugf("Lookup Node receive window input %s", d)
-						n.statManager.ProcessTimeStart()
-						sets := &xsql.JoinTuples{Content: make([]*xsql.JoinTuple, 0)}
-						err := d.Range(func(i int, r xsql.ReadonlyRow) (bool, error) {
-							tr, ok := r.(xsql.TupleRow)
-							if !ok {
-								return false, fmt.Errorf("Invalid window element, must be a tuple row but got %v", r)
-							}
-							err := n.lookup(ctx, tr, fv, ns, sets, c)
-							if err != nil {
-								return false, err
-							}
-							return true, nil
-						})
-						if err != nil {
-							_ = n.Broadcast(err)
-							n.statManager.IncTotalExceptions(err.Error())
-						} else {
-							_ = n.Broadcast(sets)
-							n.statManager.IncTotalRecordsOut()
-						}
-						n.statManager.ProcessTimeEnd()
-						n.statManager.SetBufferLength(int64(len(n.input)))
-					default:
-						e := fmt.Errorf("run lookup node error: invalid input type but got %[1]T(%[1]v)", d)
-						_ = n.Broadcast(e)
-						n.statManager.IncTotalExceptions(e.Error())
+					select {
+					case sem <- struct{}{}:
+					case <-ctx.Done():
+						log.Infoln("Cancelling lookup node....")
+						return nil
 					}
+					f := make(chan *lookupResult, 1)
+					futures <- f
+					go func() {
+						var r *lookupResult
+						if err := infra.SafeRun(func() error {
+							r = n.execute(ctx, ns, c, req)
+							return nil
+						}); err != nil {
+							r = &lookupResult{req: req, result: err}
+						}
+						f <- r
+					}()
 				case <-ctx.Done():
 					log.Infoln("Cancelling lookup node....")
 					return nil
@@ -188,94 +318,262 @@ func (n *LookupNode) Exec(ctx api.StreamContext, errCh chan<- error) {
 	}()
 }
 
-// lookup will lookup the cache firstly, if expires, read the external source
-func (n *LookupNode) lookup(ctx api.StreamContext, d xsql.TupleRow, fv *xsql.FunctionValuer, ns api.LookupSource, tuples *xsql.JoinTuples, c *cache.Cache) error {
+func isBarrier(item interface{}) bool {
+	b, ok := item.(*checkpoint.BufferOrEvent)
+	if !ok {
+		return false
+	}
+	_, ok = b.Data.(*checkpoint.Barrier)
+	return ok
+}
+
+// waitInflight waits until the results of all the in-flight lookups are emitted by occupying all the slots. It returns
+// false if the node is cancelled.
+func waitInflight(ctx api.StreamContext, sem chan struct{}) bool {
+	for i := 0; i < cap(sem); i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	for i := 0; i < cap(sem); i++ {
+		<-sem
+	}
+	return true
+}
+
+// GetExtraMetrics returns the metrics of the lookup cache and the lookup source
+func (n *LookupNode) GetExtraMetrics() ([]string, []interface{}) {
+	hits := atomic.LoadInt64(&n.stats.hits)
+	misses := atomic.LoadInt64(&n.stats.misses)
+	requests := atomic.LoadInt64(&n.stats.requests)
+	var ratio float64
+	if hits+misses > 0 {
+		ratio = float64(hits) / float64(hits+misses)
+	}
+	var latency int64
+	if requests > 0 {
+		latency = atomic.LoadInt64(&n.stats.latency) / requests
+	}
+	var (
+		size      int
+		evictions int64
+	)
+	if n.cache != nil {
+		size, evictions = n.cache.Stats()
+	}
+	return LookupMetricNames, []interface{}{hits, misses, ratio, requests, latency, size, evictions}
+}
+
+// preload reads the whole table into the cache. The rule still runs with the cold cache if it fails.
+func (n *LookupNode) preload(ctx api.StreamContext, ns api.LookupSource, c *cache.Cache) {
+	ps, ok := ns.(api.PreloadLookupSource)
+	if !ok {
+		ctx.GetLogger().Warnf("lookup source %s does not support preload", n.sourceType)
+		return
+	}
+	start := time.Now()
+	values, results, err := ps.Preload(ctx, n.fields, n.keys)
+	n.stats.observe(start)
+	if err != nil {
+		ctx.GetLogger().Warnf("preload lookup source %s error: %v", n.sourceType, err)
+		return
+	}
+	for i, v := range values {
+		c.Set(cast.ToKeyString(v), results[i])
+	}
+	ctx.GetLogger().Infof("preload %d keys from lookup source %s", len(values), n.sourceType)
+}
+
+// prepare evaluates the lookup values of the input rows
+func (n *LookupNode) prepare(ctx api.StreamContext, item interface{}, fv *xsql.FunctionValuer) *lookupReq {
+	req := &lookupReq{start: time.Now()}
+	switch d := item.(type) {
+	case error, *xsql.WatermarkTuple:
+		req.pass = d
+	case xsql.TupleRow:
+		ctx.GetLogger().Debugf("Lookup Node receive tuple input %s", d)
+		req.span = tracer.StartSpan(ctx, d, trace.SpanKindInternal)
+		req.rows = []*lookupRow{n.evalRow(d, fv)}
+	case *xsql.WindowTuples:
+		ctx.GetLogger().Debugf("Lookup Node receive window input %s", d)
+		req.rows = make([]*lookupRow, 0, d.Len())
+		err := d.Range(func(i int, r xsql.ReadonlyRow) (bool, error) {
+			tr, ok := r.(xsql.TupleRow)
+			if !ok {
+				return false, fmt.Errorf("Invalid window element, must be a tuple row but got %v", r)
+			}
+			req.rows = append(req.rows, n.evalRow(tr, fv))
+			return true, nil
+		})
+		if err != nil {
+			req.pass = err
+		}
+	default:
+		req.pass = fmt.Errorf("run lookup node error: invalid input type but got %[1]T(%[1]v)", d)
+	}
+	return req
+}
+
+func (n *LookupNode) evalRow(d xsql.TupleRow, fv *xsql.FunctionValuer) *lookupRow {
 	ve := &xsql.ValuerEval{Valuer: xsql.MultiValuer(d, fv)}
-	cvs := make([]interface{}, len(n.vals))
-	hasNil := false
+	lr := &lookupRow{row: d, cvs: make([]interface{}, len(n.vals))}
 	for i, val := range n.vals {
-		cvs[i] = ve.Eval(val)
-		if cvs[i] == nil {
-			hasNil = true
+		lr.cvs[i] = ve.Eval(val)
+		if lr.cvs[i] == nil {
+			lr.hasNil = true
+		}
+	}
+	lr.key = cast.ToKeyString(lr.cvs)
+	return lr
+}
+
+type lookupResult struct {
+	req    *lookupReq
+	result interface{}
+}
+
+// execute looks up the rows of the request and joins them with the results. It returns the request and the joined
+// tuples or an error.
+func (n *LookupNode) execute(ctx api.StreamContext, ns api.LookupSource, c *cache.Cache, req *lookupReq) *lookupResult {
+	if req.pass != nil {
+		return &lookupResult{req: req, result: req.pass}
+	}
+	results, err := n.lookup(ctx, ns, c, req.rows)
+	if err != nil {
+		return &lookupResult{req: req, result: err}
+	}
+	sets := &xsql.JoinTuples{Content: make([]*xsql.JoinTuple, 0)}
+	for _, r := range req.rows {
+		n.join(ctx, r.row, results[r.key], sets)
+	}
+	return &lookupResult{req: req, result: sets}
+}
+
+// emit sends the result and updates the metrics. It is called in one goroutine.
+func (n *LookupNode) emit(lr *lookupResult) {
+	err, _ := lr.result.(error)
+	tracer.EndSpan(lr.req.span, err)
+	n.statManager.IncTotalRecordsIn()
+	n.statManager.SetProcessTimeStart(lr.req.start)
+	switch d := lr.result.(type) {
+	case error:
+		if d == errInputClosed {
+			n.statManager.IncTotalExceptions(d.Error())
+			return
 		}
+		_ = n.Broadcast(d)
+		n.statManager.IncTotalExceptions(d.Error())
+		return
+	case *xsql.WatermarkTuple:
+		_ = n.Broadcast(d)
+		return
+	case *xsql.JoinTuples:
+		_ = n.Broadcast(d)
+		n.statManager.IncTotalRecordsOut()
 	}
+	n.statManager.ProcessTimeEnd()
+	n.statManager.SetBufferLength(int64(len(n.input)))
+}
+
+// lookup will lookup the cache firstly, and then read the external source for the missing or expired values. The
+// distinct values are looked up in batches if the source supports it. It returns the results of each lookup key.
+func (n *LookupNode) lookup(ctx api.StreamContext, ns api.LookupSource, c *cache.Cache, rows []*lookupRow) (map[string][]api.SourceTuple, error) {
+	results := make(map[string][]api.SourceTuple, len(rows))
 	var (
-		r  []api.SourceTuple
-		e  error
-		ok bool
+		missKeys []string
+		missVals [][]interface{}
 	)
-	if !hasNil { // if any of the value is nil, the lookup will always return empty result
+	for _, r := range rows {
+		if r.hasNil {
+			continue
+		}
+		if _, ok := results[r.key]; ok {
+			continue
+		}
 		if c != nil {
-			k := fmt.Sprintf("%v", cvs)
-			r, ok = c.Get(k)
-			if !ok {
-				r, e = ns.Lookup(ctx, n.fields, n.keys, cvs)
-				if e != nil {
-					return e
-				}
-				c.Set(k, r)
+			if cached, ok := c.Get(r.key); ok {
+				atomic.AddInt64(&n.stats.hits, 1)
+				results[r.key] = cached
+				continue
 			}
-		} else {
-			r, e = ns.Lookup(ctx, n.fields, n.keys, cvs)
+			atomic.AddInt64(&n.stats.misses, 1)
 		}
+		// mark as looking up to dedup the values
+		results[r.key] = nil
+		missKeys = append(missKeys, r.key)
+		missVals = append(missVals, r.cvs)
 	}
-	if e != nil {
-		return e
-	} else {
-		if len(r) == 0 {
-			if n.joinType == ast.LEFT_JOIN {
-				merged := &xsql.JoinTuple{}
-				merged.AddTuple(d)
-				tuples.Content = append(tuples.Content, merged)
-			} else {
-				ctx.GetLogger().Debugf("Lookup Node %s no result found for tuple %s", n.name, d)
-				return nil
+	if len(missVals) == 0 {
+		return results, nil
+	}
+	var fetched [][]api.SourceTuple
+	if bs, ok := ns.(api.BatchLookupSource); ok && len(missVals) > 1 {
+		batchSize := n.conf.BatchSize
+		if batchSize == 0 {
+			batchSize = defaultLookupBatchSize
+		}
+		fetched = make([][]api.SourceTuple, 0, len(missVals))
+		for i := 0; i < len(missVals); i += batchSize {
+			end := i + batchSize
+			if end > len(missVals) {
+				end = len(missVals)
+			}
+			start := time.Now()
+			rs, err := bs.LookupBatch(ctx, n.fields, n.keys, missVals[i:end])
+			n.stats.observe(start)
+			if err != nil {
+				return nil, err
 			}
+			if len(rs) != end-i {
+				return nil, fmt.Errorf("lookup batch of %d values returns %d results", end-i, len(rs))
+			}
+			fetched = append(fetched, rs...)
 		}
-		for _, v := range r {
-			merged := &xsql.JoinTuple{}
-			merged.AddTuple(d)
-			t := &xsql.Tuple{
-				Emitter:   n.name,
-				Message:   v.Message(),
-				Metadata:  v.Meta(),
-				Timestamp: conf.GetNowInMilli(),
+	} else {
+		fetched = make([][]api.SourceTuple, len(missVals))
+		for i, cvs := range missVals {
+			start := time.Now()
+			rs, err := ns.Lookup(ctx, n.fields, n.keys, cvs)
+			n.stats.observe(start)
+			if err != nil {
+				return nil, err
 			}
-			merged.AddTuple(t)
-			tuples.Content = append(tuples.Content, merged)
+			fetched[i] = rs
 		}
-		return nil
 	}
+	for i, k := range missKeys {
+		results[k] = fetched[i]
+		if c != nil {
+			c.Set(k, fetched[i])
+		}
+	}
+	return results, nil
 }
 
-func (n *LookupNode) merge(ctx api.StreamContext, d xsql.TupleRow, r []map[string]interface{}) {
-	n.statManager.ProcessTimeStart()
-	sets := &xsql.JoinTuples{Content: make([]*xsql.JoinTuple, 0)}
-
+// join merges the row with each of its lookup results
+func (n *LookupNode) join(ctx api.StreamContext, d xsql.TupleRow, r []api.SourceTuple, tuples *xsql.JoinTuples) {
 	if len(r) == 0 {
 		if n.joinType == ast.LEFT_JOIN {
 			merged := &xsql.JoinTuple{}
 			merged.AddTuple(d)
-			sets.Content = append(sets.Content, merged)
+			tuples.Content = append(tuples.Content, merged)
 		} else {
 			ctx.GetLogger().Debugf("Lookup Node %s no result found for tuple %s", n.name, d)
-			return
 		}
+		return
 	}
 	for _, v := range r {
 		merged := &xsql.JoinTuple{}
 		merged.AddTuple(d)
 		t := &xsql.Tuple{
 			Emitter:   n.name,
-			Message:   v,
+			Message:   v.Message(),
+			Metadata:  v.Meta(),
 			Timestamp: conf.GetNowInMilli(),
 		}
 		merged.AddTuple(t)
-		sets.Content = append(sets.Content, merged)
+		tuples.Content = append(tuples.Content, merged)
 	}
-
-	_ = n.Broadcast(sets)
-	n.statManager.ProcessTimeEnd()
-	n.statManager.IncTotalRecordsOut()
-	n.statManager.SetBufferLength(int64(len(n.input)))
 }
@@ -17,17 +17,25 @@ package node
 import (
 	"fmt"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/lf-edge/ekuiper/internal/binder"
 	"github.com/lf-edge/ekuiper/internal/binder/io"
 	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/plugin"
+	"github.com/lf-edge/ekuiper/internal/testx"
+	"github.com/lf-edge/ekuiper/internal/topo/checkpoint"
 	"github.com/lf-edge/ekuiper/internal/topo/context"
 	"github.com/lf-edge/ekuiper/internal/topo/lookup"
+	"github.com/lf-edge/ekuiper/internal/topo/lookup/cache"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
 	"github.com/lf-edge/ekuiper/internal/xsql"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/ast"
@@ -105,6 +113,79 @@ func (m *mockLookupSrc) Close(_ api.StreamContext) error {
 	return nil
 }
 
+// mockBatchLookupSrc returns the value itself for each lookup value and records the queries. The lookup of a smaller
+// value takes longer to test the output order of the async lookups.
+type mockBatchLookupSrc struct {
+	mu       sync.Mutex
+	batches  [][]interface{}
+	inflight int32
+	maxIn    int32
+	// the preloaded values, 1, 2 and 3 by default
+	preload [][]interface{}
+}
+
+var mockBatch = &mockBatchLookupSrc{}
+
+func (m *mockBatchLookupSrc) Open(_ api.StreamContext) error {
+	return nil
+}
+
+func (m *mockBatchLookupSrc) Configure(_ string, _ map[string]interface{}) error {
+	return nil
+}
+
+func (m *mockBatchLookupSrc) Lookup(ctx api.StreamContext, fields []string, keys []string, values []interface{}) ([]api.SourceTuple, error) {
+	r, err := m.LookupBatch(ctx, fields, keys, [][]interface{}{values})
+	if err != nil {
+		return nil, err
+	}
+	return r[0], nil
+}
+
+func (m *mockBatchLookupSrc) LookupBatch(_ api.StreamContext, _ []string, _ []string, values [][]interface{}) ([][]api.SourceTuple, error) {
+	in := atomic.AddInt32(&m.inflight, 1)
+	defer atomic.AddInt32(&m.inflight, -1)
+	m.mu.Lock()
+	if in > m.maxIn {
+		m.maxIn = in
+	}
+	var batch []interface{}
+	for _, v := range values {
+		batch = append(batch, v[0])
+	}
+	m.batches = append(m.batches, batch)
+	m.mu.Unlock()
+	time.Sleep(time.Duration(10-values[0][0].(int)) * 10 * time.Millisecond)
+	result := make([][]api.SourceTuple, len(values))
+	for i, v := range values {
+		if v[0].(int) > 0 {
+			result[i] = []api.SourceTuple{api.NewDefaultSourceTupleWithTime(map[string]interface{}{"newA": v[0]}, nil, conf.GetNow())}
+		}
+	}
+	return result, nil
+}
+
+func (m *mockBatchLookupSrc) Preload(ctx api.StreamContext, fields []string, keys []string) ([][]interface{}, [][]api.SourceTuple, error) {
+	values := m.preload
+	if values == nil {
+		values = [][]interface{}{{1}, {2}, {3}}
+	}
+	results, err := m.LookupBatch(ctx, fields, keys, values)
+	return values, results, err
+}
+
+func (m *mockBatchLookupSrc) Close(_ api.StreamContext) error {
+	return nil
+}
+
+func (m *mockBatchLookupSrc) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.batches = nil
+	m.maxIn = 0
+	m.preload = nil
+}
+
 type mockFac struct{}
 
 func (m *mockFac) Source(_ string) (api.Source, error) {
@@ -116,8 +197,11 @@ func (m *mockFac) SourcePluginInfo(_ string) (plugin.EXTENSION_TYPE, string, str
 }
 
 func (m *mockFac) LookupSource(name string) (api.LookupSource, error) {
-	if name == "mock" {
+	switch name {
+	case "mock":
 		return &mockLookupSrc{}, nil
+	case "mockBatch":
+		return mockBatch, nil
 	}
 	return nil, nil
 }
@@ -535,3 +619,181 @@ func TestCachedLookup(t *testing.T) {
 		return
 	}
 }
+
+func newMockBatchLookupNode(t *testing.T, ruleId string, lc *LookupConf, opts ...func(*LookupNode)) (*LookupNode, chan interface{}, func()) {
+	options := &ast.Options{
+		DATASOURCE: "mockBatch",
+		TYPE:       "mockBatch",
+		KIND:       "lookup",
+	}
+	require.NoError(t, lookup.CreateInstance("mockBatch", "mockBatch", options))
+	mockBatch.reset()
+	contextLogger := conf.Log.WithField("rule", ruleId)
+	tempStore, _ := state.CreateStore(ruleId, api.AtMostOnce)
+	ctx, cancel := context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithMeta(ruleId, "mockBatch", tempStore).WithCancel()
+	l, err := NewLookupNode("mockBatch", []string{}, []string{"a"}, ast.LEFT_JOIN, []ast.Expr{&ast.FieldRef{Name: "a"}}, options, &api.RuleOption{BufferLength: 10})
+	require.NoError(t, err)
+	l.conf = lc
+	outputCh := make(chan interface{}, 10)
+	l.outputs["mock"] = outputCh
+	for _, opt := range opts {
+		opt(l)
+	}
+	l.Exec(ctx, make(chan error, 1))
+	return l, outputCh, func() {
+		cancel()
+		_ = lookup.DropInstance("mockBatch")
+	}
+}
+
+func lookupOutput(t *testing.T, outputCh chan interface{}) []interface{} {
+	select {
+	case output := <-outputCh:
+		if b, ok := output.(*checkpoint.BufferOrEvent); ok {
+			output = b.Data
+		}
+		sets, ok := output.(*xsql.JoinTuples)
+		require.True(t, ok, "expect join tuples but got %v", output)
+		var result []interface{}
+		for _, jt := range sets.Content {
+			a, _ := jt.Tuples[0].Value("a", "")
+			if len(jt.Tuples) == 1 {
+				result = append(result, []interface{}{a})
+			} else {
+				v, _ := jt.Tuples[1].Value("newA", "")
+				result = append(result, []interface{}{a, v})
+			}
+		}
+		return result
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "receive output timeout")
+	}
+	return nil
+}
+
+func TestBatchLookup(t *testing.T) {
+	l, outputCh, stop := newMockBatchLookupNode(t, "TestBatchLookup", &LookupConf{Cache: true, CacheTTL: 100, CacheMissingKey: true, BatchSize: 2})
+	defer stop()
+	window := &xsql.WindowTuples{}
+	for _, a := range []interface{}{1, 2, 1, nil, 3, 0} {
+		window = window.AddTuple(&xsql.Tuple{Emitter: "demo", Message: map[string]interface{}{"a": a}})
+	}
+	l.input <- window
+	exp := []interface{}{[]interface{}{1, 1}, []interface{}{2, 2}, []interface{}{1, 1}, []interface{}{nil}, []interface{}{3, 3}, []interface{}{0}}
+	assert.Equal(t, exp, lookupOutput(t, outputCh))
+	// The distinct values are looked up by batch
+	assert.Equal(t, [][]interface{}{{1, 2}, {3, 0}}, mockBatch.batches)
+	// All are cached except the null value
+	l.input <- window
+	assert.Equal(t, exp, lookupOutput(t, outputCh))
+	assert.Len(t, mockBatch.batches, 2)
+	names, values := l.GetExtraMetrics()
+	assert.Equal(t, LookupMetricNames, names)
+	assert.Equal(t, []interface{}{int64(4), int64(4), 0.5, int64(2)}, values[:4])
+}
+
+func TestAsyncLookup(t *testing.T) {
+	l, outputCh, stop := newMockBatchLookupNode(t, "TestAsyncLookup", &LookupConf{Async: true, MaxInflight: 3})
+	defer stop()
+	for i := 1; i <= 6; i++ {
+		l.input <- &xsql.Tuple{Emitter: "demo", Message: map[string]interface{}{"a": i}}
+	}
+	// The later inputs finish earlier, but the outputs keep the input order
+	for i := 1; i <= 6; i++ {
+		assert.Equal(t, []interface{}{[]interface{}{i, i}}, lookupOutput(t, outputCh))
+	}
+	mockBatch.mu.Lock()
+	defer mockBatch.mu.Unlock()
+	assert.Len(t, mockBatch.batches, 6)
+	assert.True(t, mockBatch.maxIn > 1 && mockBatch.maxIn <= 3, "max inflight %d", mockBatch.maxIn)
+}
+
+func TestPreloadLookupNumberKey(t *testing.T) {
+	l, outputCh, stop := newMockBatchLookupNode(t, "TestPreloadLookupNumberKey", &LookupConf{Cache: true, CacheSize: 10, Preload: true}, func(_ *LookupNode) {
+		mockBatch.preload = [][]interface{}{{1}, {1000000}}
+	})
+	defer stop()
+	// The int keys are preloaded, and the numbers decoded from json are float64
+	window := &xsql.WindowTuples{}
+	for _, a := range []interface{}{float64(1000000), int64(1)} {
+		window = window.AddTuple(&xsql.Tuple{Emitter: "demo", Message: map[string]interface{}{"a": a}})
+	}
+	l.input <- window
+	assert.Equal(t, []interface{}{[]interface{}{float64(1000000), 1000000}, []interface{}{int64(1), 1}}, lookupOutput(t, outputCh))
+	// All hit the preloaded cache
+	assert.Equal(t, [][]interface{}{{1, 1000000}}, mockBatch.batches)
+	_, values := l.GetExtraMetrics()
+	assert.Equal(t, []interface{}{int64(2), int64(0)}, values[:2])
+}
+
+// barrierResponder sends the checkpoint id to the output when the barrier is processed
+type barrierResponder chan<- interface{}
+
+func (r barrierResponder) TriggerCheckpoint(checkpointId int64) error {
+	r <- checkpointId
+	return nil
+}
+
+func (r barrierResponder) GetName() string {
+	return "mockBatch"
+}
+
+func TestAsyncLookupBarrier(t *testing.T) {
+	l, outputCh, stop := newMockBatchLookupNode(t, "TestAsyncLookupBarrier", &LookupConf{Async: true, MaxInflight: 3}, func(l *LookupNode) {
+		l.qos = api.AtLeastOnce
+		l.barrierHandler = checkpoint.NewBarrierTracker(barrierResponder(l.outputs["mock"]), 1)
+	})
+	defer stop()
+	for i := 1; i <= 2; i++ {
+		l.input <- &checkpoint.BufferOrEvent{Data: &xsql.Tuple{Emitter: "demo", Message: map[string]interface{}{"a": i}}}
+	}
+	l.input <- &checkpoint.BufferOrEvent{Data: &checkpoint.Barrier{CheckpointId: 1}}
+	l.input <- &checkpoint.BufferOrEvent{Data: &xsql.Tuple{Emitter: "demo", Message: map[string]interface{}{"a": 3}}}
+	// The barrier is forwarded after the results of the previous inputs
+	for i := 1; i <= 2; i++ {
+		assert.Equal(t, []interface{}{[]interface{}{i, i}}, lookupOutput(t, outputCh))
+	}
+	select {
+	case output := <-outputCh:
+		assert.Equal(t, int64(1), output)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "receive barrier timeout")
+	}
+	assert.Equal(t, []interface{}{[]interface{}{3, 3}}, lookupOutput(t, outputCh))
+}
+
+func TestPreloadLookup(t *testing.T) {
+	testx.InitEnv()
+	defer func() {
+		_ = cache.DeleteSaved("TestPreloadLookup")
+	}()
+	lc := &LookupConf{Cache: true, CacheSize: 10, CachePersist: true, Preload: true}
+	l, outputCh, stop := newMockBatchLookupNode(t, "TestPreloadLookup", lc)
+	window := &xsql.WindowTuples{}
+	for _, a := range []interface{}{2, 1} {
+		window = window.AddTuple(&xsql.Tuple{Emitter: "demo", Message: map[string]interface{}{"a": a}})
+	}
+	l.input <- window
+	assert.Equal(t, []interface{}{[]interface{}{2, 2}, []interface{}{1, 1}}, lookupOutput(t, outputCh))
+	// Only the preload query
+	assert.Equal(t, [][]interface{}{{1, 2, 3}}, mockBatch.batches)
+	_, values := l.GetExtraMetrics()
+	assert.Equal(t, []interface{}{int64(2), int64(0), 1.0, int64(1)}, values[:4])
+	assert.Equal(t, []interface{}{3, int64(0)}, values[5:])
+	stop()
+	// The cache is saved when the rule stops
+	assert.Eventually(t, func() bool {
+		c := cache.NewCache(0, false)
+		defer c.Close()
+		count, _ := c.Load("TestPreloadLookup/mockBatch")
+		return count == 3
+	}, time.Second, 10*time.Millisecond)
+
+	// The saved cache is loaded without preload
+	lc.Preload = false
+	l, outputCh, stop = newMockBatchLookupNode(t, "TestPreloadLookup", lc)
+	defer stop()
+	l.input <- &xsql.Tuple{Emitter: "demo", Message: map[string]interface{}{"a": 3}}
+	assert.Equal(t, []interface{}{[]interface{}{3, 3}}, lookupOutput(t, outputCh))
+	assert.Len(t, mockBatch.batches, 0)
+}
@@ -0,0 +1,108 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metric
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+const (
+	ConnectionConnected    = "connected"
+	ConnectionReconnecting = "reconnecting"
+	ConnectionDisconnected = "disconnected"
+
+	ConnectionStatus = "connection_status"
+
+	// ConnectionStatusKey is the context key of the ConnectionReporter of a source or sink instance
+	ConnectionStatusKey = "$$connectionStatus"
+)
+
+// ConnectionReporter receives the changes of the connection status of a source or sink instance
+type ConnectionReporter interface {
+	SetConnectionStatus(status string, err error)
+}
+
+// ReportConnectionStatus is called by the sources and sinks to report the status of their connection to the external
+// system. It does nothing if the context does not belong to a source or sink node instance.
+func ReportConnectionStatus(ctx api.StreamContext, status string, err error) {
+	if r, ok := ctx.Value(ConnectionStatusKey).(ConnectionReporter); ok && r != nil {
+		r.SetConnectionStatus(status, err)
+	}
+}
+
+// ConnectionHealth is the health snapshot of the connection of a source or sink instance
+type ConnectionHealth struct {
+	Status    string `json:"status"`
+	LastError string `json:"lastError,omitempty"`
+	// Since is the time in milliseconds when the connection changed to the current status
+	Since int64 `json:"since"`
+}
+
+// NodeHealth is the connection health of an instance of a source or sink node
+type NodeHealth struct {
+	Node     string `json:"node"`
+	Type     string `json:"type"`
+	Instance int    `json:"instance"`
+	ConnectionHealth
+}
+
+// connectionHealth is shared by the copies of a stat manager. Unlike the metrics, it is updated by the connectors which
+// may run in other goroutines, so it is guarded by a lock.
+type connectionHealth struct {
+	sync.RWMutex
+	status  string
+	lastErr string
+	since   time.Time
+}
+
+func (h *connectionHealth) set(status string, err error) bool {
+	// fast path for the sinks which report connected after each successful send
+	if err == nil {
+		h.RLock()
+		unchanged := h.status == status
+		h.RUnlock()
+		if unchanged {
+			return false
+		}
+	}
+	h.Lock()
+	defer h.Unlock()
+	if err != nil {
+		h.lastErr = err.Error()
+	}
+	if h.status == status {
+		return false
+	}
+	h.status = status
+	h.since = conf.GetNow()
+	return true
+}
+
+func (h *connectionHealth) get() *ConnectionHealth {
+	h.RLock()
+	defer h.RUnlock()
+	if h.status == "" {
+		return nil
+	}
+	return &ConnectionHealth{
+		Status:    h.status,
+		LastError: h.lastErr,
+		Since:     h.since.UnixMilli(),
+	}
+}
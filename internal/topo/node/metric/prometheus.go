@@ -43,6 +43,7 @@ type MetricGroup struct {
 	ProcessLatencyHist *prometheus.HistogramVec
 	ProcessLatency     *prometheus.GaugeVec
 	BufferLength       *prometheus.GaugeVec
+	ConnectionStatus   *prometheus.GaugeVec
 }
 
 type PrometheusMetrics struct {
@@ -51,7 +52,7 @@ type PrometheusMetrics struct {
 
 func newPrometheusMetrics() *PrometheusMetrics {
 	var (
-		labelNames = []string{"rule", "type", "op", "instance"}
+		labelNames = []string{"namespace", "rule", "type", "op", "instance"}
 		prefixes   = []string{"kuiper_source", "kuiper_op", "kuiper_sink"}
 	)
 	var vecs []*MetricGroup
@@ -82,7 +83,11 @@ func newPrometheusMetrics() *PrometheusMetrics {
 			Name: prefix + "_" + BufferLength,
 			Help: "The length of the plan buffer which is shared by all instances of " + prefix,
 		}, labelNames)
-		prometheus.MustRegister(totalRecordsIn, totalRecordsOut, totalExceptions, processLatency, processLatencyHist, bufferLength)
+		connectionStatus := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prefix + "_" + ConnectionStatus,
+			Help: "The connection status of " + prefix + ", 2 for connected, 1 for reconnecting and 0 for disconnected",
+		}, labelNames)
+		prometheus.MustRegister(totalRecordsIn, totalRecordsOut, totalExceptions, processLatency, processLatencyHist, bufferLength, connectionStatus)
 		vecs = append(vecs, &MetricGroup{
 			TotalRecordsIn:     totalRecordsIn,
 			TotalRecordsOut:    totalRecordsOut,
@@ -90,6 +95,7 @@ func newPrometheusMetrics() *PrometheusMetrics {
 			ProcessLatency:     processLatency,
 			ProcessLatencyHist: processLatencyHist,
 			BufferLength:       bufferLength,
+			ConnectionStatus:   connectionStatus,
 		})
 	}
 	return &PrometheusMetrics{vecs: vecs}
@@ -44,6 +44,9 @@ type StatManager interface {
 	SetBufferLength(l int64)
 	SetProcessTimeStart(t time.Time)
 	GetMetrics() []interface{}
+	ConnectionReporter
+	// GetConnectionHealth returns the connection health of a source or sink instance, nil if never reported
+	GetConnectionHealth() *ConnectionHealth
 	// Clean remove all metrics history
 	Clean(ruleId string)
 }
@@ -59,6 +62,7 @@ type DefaultStatManager struct {
 	totalExceptions   int64
 	lastException     string
 	lastExceptionTime time.Time
+	health            *connectionHealth
 	// configs
 	opType           string //"source", "op", "sink"
 	prefix           string
@@ -85,6 +89,7 @@ func NewStatManager(ctx api.StreamContext, opType string) (StatManager, error) {
 		prefix:     prefix,
 		opId:       ctx.GetOpId(),
 		instanceId: ctx.GetInstanceId(),
+		health:     &connectionHealth{},
 	}
 	return getStatManager(ctx, ds)
 }
@@ -125,6 +130,14 @@ func (sm *DefaultStatManager) SetProcessTimeStart(t time.Time) {
 	sm.lastInvocation = t
 }
 
+func (sm *DefaultStatManager) SetConnectionStatus(status string, err error) {
+	sm.health.set(status, err)
+}
+
+func (sm *DefaultStatManager) GetConnectionHealth() *ConnectionHealth {
+	return sm.health.get()
+}
+
 func (sm *DefaultStatManager) GetMetrics() []interface{} {
 	result := []interface{}{
 		sm.totalRecordsIn,
@@ -23,6 +23,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/namespace"
 	"github.com/lf-edge/ekuiper/pkg/api"
 )
 
@@ -36,19 +37,22 @@ func getStatManager(ctx api.StreamContext, dsm DefaultStatManager) (StatManager,
 		// assign prometheus
 		mg := GetPrometheusMetrics().GetMetricsGroup(dsm.opType)
 		strInId := strconv.Itoa(dsm.instanceId)
-		mg.TotalRecordsIn.DeleteLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
-		mg.TotalRecordsOut.DeleteLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
-		mg.TotalExceptions.DeleteLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
-		mg.ProcessLatency.DeleteLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
-		mg.ProcessLatencyHist.DeleteLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
-		mg.BufferLength.DeleteLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
-
-		psm.pTotalRecordsIn = mg.TotalRecordsIn.WithLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
-		psm.pTotalRecordsOut = mg.TotalRecordsOut.WithLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
-		psm.pTotalExceptions = mg.TotalExceptions.WithLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
-		psm.pProcessLatency = mg.ProcessLatency.WithLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
-		psm.pProcessLatencyHist = mg.ProcessLatencyHist.WithLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
-		psm.pBufferLength = mg.BufferLength.WithLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
+		ns, rule := namespace.Split(ctx.GetRuleId())
+		mg.TotalRecordsIn.DeleteLabelValues(ns, rule, dsm.opType, dsm.opId, strInId)
+		mg.TotalRecordsOut.DeleteLabelValues(ns, rule, dsm.opType, dsm.opId, strInId)
+		mg.TotalExceptions.DeleteLabelValues(ns, rule, dsm.opType, dsm.opId, strInId)
+		mg.ProcessLatency.DeleteLabelValues(ns, rule, dsm.opType, dsm.opId, strInId)
+		mg.ProcessLatencyHist.DeleteLabelValues(ns, rule, dsm.opType, dsm.opId, strInId)
+		mg.BufferLength.DeleteLabelValues(ns, rule, dsm.opType, dsm.opId, strInId)
+		mg.ConnectionStatus.DeleteLabelValues(ns, rule, dsm.opType, dsm.opId, strInId)
+
+		psm.pTotalRecordsIn = mg.TotalRecordsIn.WithLabelValues(ns, rule, dsm.opType, dsm.opId, strInId)
+		psm.pTotalRecordsOut = mg.TotalRecordsOut.WithLabelValues(ns, rule, dsm.opType, dsm.opId, strInId)
+		psm.pTotalExceptions = mg.TotalExceptions.WithLabelValues(ns, rule, dsm.opType, dsm.opId, strInId)
+		psm.pProcessLatency = mg.ProcessLatency.WithLabelValues(ns, rule, dsm.opType, dsm.opId, strInId)
+		psm.pProcessLatencyHist = mg.ProcessLatencyHist.WithLabelValues(ns, rule, dsm.opType, dsm.opId, strInId)
+		psm.pBufferLength = mg.BufferLength.WithLabelValues(ns, rule, dsm.opType, dsm.opId, strInId)
+		psm.pConnectionStatus = mg.ConnectionStatus.WithLabelValues(ns, rule, dsm.opType, dsm.opId, strInId)
 		sm = psm
 	} else {
 		sm = &dsm
@@ -65,6 +69,7 @@ type PrometheusStatManager struct {
 	pProcessLatency     prometheus.Gauge
 	pProcessLatencyHist prometheus.Observer
 	pBufferLength       prometheus.Gauge
+	pConnectionStatus   prometheus.Gauge
 }
 
 func (sm *PrometheusStatManager) IncTotalRecordsIn() {
@@ -95,14 +100,35 @@ func (sm *PrometheusStatManager) SetBufferLength(l int64) {
 	sm.pBufferLength.Set(float64(l))
 }
 
+func (sm *PrometheusStatManager) SetConnectionStatus(status string, err error) {
+	if sm.health.set(status, err) {
+		sm.pConnectionStatus.Set(connectionStatusValue(status))
+	}
+}
+
 func (sm *PrometheusStatManager) Clean(ruleId string) {
 	if conf.Config != nil && conf.Config.Basic.Prometheus {
 		mg := GetPrometheusMetrics().GetMetricsGroup(sm.opType)
 		strInId := strconv.Itoa(sm.instanceId)
-		mg.TotalRecordsIn.DeleteLabelValues(ruleId, sm.opType, sm.opId, strInId)
-		mg.TotalRecordsOut.DeleteLabelValues(ruleId, sm.opType, sm.opId, strInId)
-		mg.TotalExceptions.DeleteLabelValues(ruleId, sm.opType, sm.opId, strInId)
-		mg.ProcessLatency.DeleteLabelValues(ruleId, sm.opType, sm.opId, strInId)
-		mg.BufferLength.DeleteLabelValues(ruleId, sm.opType, sm.opId, strInId)
+		ns, rule := namespace.Split(ruleId)
+		mg.TotalRecordsIn.DeleteLabelValues(ns, rule, sm.opType, sm.opId, strInId)
+		mg.TotalRecordsOut.DeleteLabelValues(ns, rule, sm.opType, sm.opId, strInId)
+		mg.TotalExceptions.DeleteLabelValues(ns, rule, sm.opType, sm.opId, strInId)
+		mg.ProcessLatency.DeleteLabelValues(ns, rule, sm.opType, sm.opId, strInId)
+		mg.BufferLength.DeleteLabelValues(ns, rule, sm.opType, sm.opId, strInId)
+		mg.ConnectionStatus.DeleteLabelValues(ns, rule, sm.opType, sm.opId, strInId)
+	}
+}
+
+// connectionStatusValue is the value of the connection status gauge: 2 for connected, 1 for reconnecting and 0 for
+// disconnected
+func connectionStatusValue(status string) float64 {
+	switch status {
+	case ConnectionConnected:
+		return 2
+	case ConnectionReconnecting:
+		return 1
+	default:
+		return 0
 	}
 }
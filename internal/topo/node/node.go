@@ -16,6 +16,7 @@ package node
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/lf-edge/ekuiper/internal/binder/io"
 	"github.com/lf-edge/ekuiper/internal/conf"
@@ -24,6 +25,7 @@ import (
 	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
 	"github.com/lf-edge/ekuiper/internal/xsql"
 	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
 )
 
 type OperatorNode interface {
@@ -37,11 +39,17 @@ type OperatorNode interface {
 	RemoveMetrics(name string)
 }
 
+// ExtraMetricsNode is a node which has the metrics of its own besides the common metrics of each instance
+type ExtraMetricsNode interface {
+	GetExtraMetrics() (names []string, values []interface{})
+}
+
 type DataSourceNode interface {
 	api.Emitter
 	Open(ctx api.StreamContext, errCh chan<- error)
 	GetName() string
 	GetMetrics() [][]interface{}
+	GetConnectionHealth() []*metric.ConnectionHealth
 	RemoveMetrics(ruleId string)
 	Broadcast(val interface{}) error
 	GetStreamContext() api.StreamContext
@@ -56,6 +64,7 @@ type defaultNode struct {
 	statManagers []metric.StatManager
 	ctx          api.StreamContext
 	qos          api.Qos
+	tap          tapPoint
 }
 
 func (o *defaultNode) AddOutput(output chan<- interface{}, name string) error {
@@ -90,6 +99,14 @@ func (o *defaultNode) GetMetrics() (result [][]interface{}) {
 	return result
 }
 
+// GetConnectionHealth returns the connection health of each instance. The item is nil if the instance has not reported.
+func (o *defaultNode) GetConnectionHealth() (result []*metric.ConnectionHealth) {
+	for _, stats := range o.statManagers {
+		result = append(result, stats.GetConnectionHealth())
+	}
+	return result
+}
+
 func (o *defaultNode) RemoveMetrics(ruleId string) {
 	for _, stats := range o.statManagers {
 		stats.Clean(ruleId)
@@ -100,6 +117,9 @@ func (o *defaultNode) Broadcast(val interface{}) error {
 	if _, ok := val.(error); ok && !o.sendError {
 		return nil
 	}
+	if o.tap.active() {
+		o.tap.publish(o.name, TapOut, val)
+	}
 	if o.qos >= api.AtLeastOnce {
 		boe := &checkpoint.BufferOrEvent{
 			Data:    val,
@@ -137,6 +157,22 @@ func (o *defaultNode) GetStreamContext() api.StreamContext {
 	return o.ctx
 }
 
+// Tap attaches a subscriber to receive the data of the running node
+func (o *defaultNode) Tap(opts *TapOptions) (*TapSubscriber, error) {
+	return o.tap.subscribe(o.ctx, opts)
+}
+
+// isIOError checks if the error is a recoverable error of the connection to the external system
+func isIOError(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), errorx.IOErr)
+}
+
+// withConnectionReporter returns a copy of the context for a source or sink instance so that the connector can report
+// its connection status to the stats of the instance by metric.ReportConnectionStatus
+func withConnectionReporter(ctx api.StreamContext, instance int, stats metric.StatManager) api.StreamContext {
+	return context.WithValue(ctx.WithInstance(instance).(*context.DefaultContext), metric.ConnectionStatusKey, stats)
+}
+
 type defaultSinkNode struct {
 	*defaultNode
 	input          chan interface{}
@@ -173,10 +209,13 @@ func (o *defaultSinkNode) preprocess(data interface{}) (interface{}, bool) {
 			if o.barrierHandler.Process(b, o.ctx) {
 				return nil, true
 			} else {
-				return b.Data, false
+				data = b.Data
 			}
 		}
 	}
+	if o.tap.active() {
+		o.tap.publish(o.name, TapIn, data)
+	}
 	return data, false
 }
 
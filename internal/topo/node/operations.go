@@ -18,6 +18,9 @@ import (
 	"fmt"
 	"sync"
 
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lf-edge/ekuiper/internal/pkg/tracer"
 	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
 	"github.com/lf-edge/ekuiper/internal/xsql"
 	"github.com/lf-edge/ekuiper/pkg/api"
@@ -139,13 +142,20 @@ func (o *UnaryOperator) doOp(ctx api.StreamContext, errCh chan<- error) {
 
 			stats.IncTotalRecordsIn()
 			stats.ProcessTimeStart()
+			span := tracer.StartSpan(ctx, item, trace.SpanKindInternal)
 			result := o.op.Apply(exeCtx, item, fv, afv)
+			if e, ok := result.(error); ok {
+				tracer.EndSpan(span, e)
+			} else {
+				tracer.EndSpan(span, nil)
+			}
 
 			switch val := result.(type) {
 			case nil:
 				continue
 			case error:
 				logger.Errorf("Operation %s error: %s", ctx.GetOpId(), val)
+				sendDeadLetter(ctx, item, val)
 				_ = o.Broadcast(val)
 				stats.IncTotalExceptions(val.Error())
 				continue
@@ -19,9 +19,13 @@ import (
 	"strings"
 	"sync"
 
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/lf-edge/ekuiper/internal/binder/io"
 	"github.com/lf-edge/ekuiper/internal/conf"
 	sinkUtil "github.com/lf-edge/ekuiper/internal/io/sink"
+	"github.com/lf-edge/ekuiper/internal/pkg/namespace"
+	"github.com/lf-edge/ekuiper/internal/pkg/tracer"
 	"github.com/lf-edge/ekuiper/internal/topo/context"
 	"github.com/lf-edge/ekuiper/internal/topo/node/cache"
 	nodeConf "github.com/lf-edge/ekuiper/internal/topo/node/conf"
@@ -68,8 +72,16 @@ type SinkNode struct {
 	isMock  bool
 	// states varies after restart
 	sinks []api.Sink
+	// two-phase commit states, only available when the qos is exactly once and the sink supports it
+	txSink      api.TwoPhaseCommitSink
+	txCtx       api.StreamContext
+	txResult    chan<- error
+	pendingTxns []int64
+	commitCh    chan int64
 }
 
+const PendingTransactionsKey = "$$pendingTransactions"
+
 func NewSinkNode(name string, sinkType string, props map[string]interface{}) *SinkNode {
 	bufferLength := 1024
 	if c, ok := props["bufferLength"]; ok {
@@ -130,14 +142,25 @@ func (m *SinkNode) Open(ctx api.StreamContext, result chan<- error) {
 			ctx = context.WithValue(ctx.(*context.DefaultContext), context.TransKey, tf)
 
 			m.reset()
+			m.commitCh = make(chan int64, 1)
+			// The sink conf key is scoped to the namespace of the rule. It is qualified before spawning the workers
+			// which read the options concurrently.
+			if rid, ok := m.options[nodeConf.ResourceID].(string); ok && !m.isMock {
+				m.options[nodeConf.ResourceID] = namespace.Qualify(namespace.Of(ctx.GetRuleId()), rid)
+			}
 			logger.Infof("open sink node %d instances", m.concurrency)
 			for i := 0; i < m.concurrency; i++ { // workers
 				go func(instance int) {
 					panicOrError := infra.SafeRun(func() error {
-						var (
-							sink api.Sink
-							err  error
-						)
+						var sink api.Sink
+						stats, err := metric.NewStatManager(ctx, "sink")
+						if err != nil {
+							return err
+						}
+						m.mutex.Lock()
+						m.statManagers = append(m.statManagers, stats)
+						m.mutex.Unlock()
+						ctx := withConnectionReporter(ctx, ctx.GetInstanceId(), stats)
 						if !m.isMock {
 							logger.Debugf("Trying to get sink for rule %s with options %v\n", ctx.GetRuleId(), m.options)
 							sink, err = getSink(m.sinkType, m.options)
@@ -150,20 +173,20 @@ func (m *SinkNode) Open(ctx api.StreamContext, result chan<- error) {
 							m.mutex.Unlock()
 							logger.Debugf("Now is to open sink for rule %s.\n", ctx.GetRuleId())
 							if err := sink.Open(ctx); err != nil {
+								stats.SetConnectionStatus(metric.ConnectionDisconnected, err)
 								return err
 							}
 							logger.Debugf("Successfully open sink for rule %s.\n", ctx.GetRuleId())
 						} else {
 							sink = m.sinks[instance]
 						}
+						stats.SetConnectionStatus(metric.ConnectionConnected, nil)
 
-						stats, err := metric.NewStatManager(ctx, "sink")
-						if err != nil {
-							return err
+						if m.qos == api.ExactlyOnce {
+							if ts, ok := sink.(api.TwoPhaseCommitSink); ok {
+								return m.runTransaction(ctx, ts, sconf, stats, result)
+							}
 						}
-						m.mutex.Lock()
-						m.statManagers = append(m.statManagers, stats)
-						m.mutex.Unlock()
 
 						// The sink flow is: receive -> batch -> cache -> send.
 						// In the outside loop, send received data to batch/cache by dataCh and receive data be dataOutCh
@@ -205,6 +228,10 @@ func (m *SinkNode) Open(ctx api.StreamContext, result chan<- error) {
 							dataOutCh = c.Out
 						}
 
+						// The spans of the data in dataCh to propagate the trace context when sending. Only used when the data is
+						// sent in the order of receiving, otherwise the sink span ends once the data is batched or cached.
+						var spans []trace.Span
+						traceSend := tracer.IsEnabled() && !sconf.EnableCache && !sconf.isBatchSinkEnabled()
 						receiveQ := func(data interface{}) {
 							processed := false
 							if data, processed = m.preprocess(data); processed {
@@ -217,15 +244,23 @@ func (m *SinkNode) Open(ctx api.StreamContext, result chan<- error) {
 								ctx.GetLogger().Debugf("receive empty in sink")
 								return
 							}
+							span := tracer.StartSpan(ctx, data, trace.SpanKindProducer)
 							if sconf.isBatchSinkEnabled() {
 								for _, out := range outs {
 									sendManager.RecvData(out)
 								}
+								tracer.EndSpan(span, nil)
 							} else {
 								select {
 								case dataCh <- outs:
+									if traceSend {
+										spans = append(spans, span)
+									} else {
+										tracer.EndSpan(span, nil)
+									}
 								default:
 									ctx.GetLogger().Warnf("sink node %s instance %d buffer is full, drop data %v", m.name, instance, outs)
+									tracer.EndSpan(span, fmt.Errorf("buffer is full"))
 								}
 							}
 							if resendCh != nil {
@@ -240,9 +275,23 @@ func (m *SinkNode) Open(ctx api.StreamContext, result chan<- error) {
 							stats.ProcessTimeStart()
 							stats.SetBufferLength(bufferLen(dataCh, c, rq))
 							ctx.GetLogger().Debugf("sending data: %v", data)
-							err := doCollectMaps(ctx, sink, sconf, data, stats, false)
+							var span trace.Span
+							if len(spans) > 0 {
+								span = spans[0]
+								spans[0] = nil
+								spans = spans[1:]
+							}
+							err := doCollectMaps(tracer.WithSpan(ctx, span), sink, sconf, data, stats, false)
+							tracer.EndSpan(span, err)
+							if !sconf.EnableCache && err != nil {
+								sendDeadLetter(ctx, data, err)
+							}
 							if sconf.EnableCache {
 								ack := checkAck(ctx, data, err)
+								// The failed data which will not be retried by the cache
+								if ack && err != nil {
+									sendDeadLetter(ctx, data, err)
+								}
 								if sconf.ResendAlterQueue {
 									// If ack is false, add it to the resend queue
 									if !ack {
@@ -280,6 +329,9 @@ func (m *SinkNode) Open(ctx api.StreamContext, result chan<- error) {
 							}
 							err := doCollectMaps(ctx, sink, sconf, data, stats, true)
 							ack := checkAck(ctx, data, err)
+							if ack && err != nil {
+								sendDeadLetter(ctx, data, err)
+							}
 							select {
 							case rq.Ack <- ack:
 								if ack {
@@ -381,6 +433,126 @@ func (m *SinkNode) Open(ctx api.StreamContext, result chan<- error) {
 	}()
 }
 
+// runTransaction runs the sink instance in two-phase commit mode. The data is sent synchronously without cache or batch
+// so that each transaction contains exactly the data between two barriers.
+func (m *SinkNode) runTransaction(ctx api.StreamContext, sink api.TwoPhaseCommitSink, sconf *SinkConf, stats metric.StatManager, result chan<- error) error {
+	logger := ctx.GetLogger()
+	if m.concurrency > 1 {
+		return fmt.Errorf("sink %s does not support concurrency %d for exactly once qos", m.name, m.concurrency)
+	}
+	if sconf.EnableCache || sconf.isBatchSinkEnabled() {
+		logger.Warnf("sink node %s runs in two-phase commit mode, cache and batch are disabled", m.name)
+	}
+	var pending []int64
+	if s, err := ctx.GetState(PendingTransactionsKey); err == nil && s != nil {
+		if p, ok := s.([]int64); ok {
+			pending = p
+		}
+	}
+	logger.Infof("sink node %s recovers pending transactions %v", m.name, pending)
+	if err := sink.Recover(ctx, pending); err != nil {
+		return fmt.Errorf("recover transactions %v for sink %s fails: %v", pending, m.name, err)
+	}
+	if err := ctx.PutState(PendingTransactionsKey, []int64{}); err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	m.txSink = sink
+	m.txCtx = ctx
+	m.txResult = result
+	m.pendingTxns = nil
+	m.mutex.Unlock()
+	for {
+		select {
+		case data := <-m.input:
+			processed := false
+			if data, processed = m.preprocess(data); processed {
+				break
+			}
+			stats.IncTotalRecordsIn()
+			outs := itemToMap(data)
+			if sconf.Omitempty && (data == nil || len(outs) == 0) {
+				ctx.GetLogger().Debugf("receive empty in sink")
+				break
+			}
+			stats.ProcessTimeStart()
+			span := tracer.StartSpan(ctx, data, trace.SpanKindProducer)
+			err := doCollectMaps(tracer.WithSpan(ctx, span), sink, sconf, outs, stats, false)
+			tracer.EndSpan(span, err)
+			stats.ProcessTimeEnd()
+			// The data must not be lost in a transaction, fail the rule to recover from the last checkpoint
+			if err != nil {
+				if e := sink.Abort(ctx); e != nil {
+					logger.Warnf("abort sink node %s transaction fails: %v", m.name, e)
+				}
+				return fmt.Errorf("sink %s fails to collect data in transaction: %v", m.name, err)
+			}
+		case checkpointId := <-m.commitCh:
+			m.commit(ctx, checkpointId)
+		case <-ctx.Done():
+			logger.Infof("sink node %s instance 0 done", m.name)
+			if err := sink.Abort(ctx); err != nil {
+				logger.Warnf("abort sink node %s transaction fails: %v", m.name, err)
+			}
+			if err := sink.Close(ctx); err != nil {
+				logger.Warnf("close sink node %s instance 0 fails: %v", m.name, err)
+			}
+			return nil
+		}
+	}
+}
+
+// commit commits all the pending transactions until the completed checkpoint. The failed ones will be retried
+// when the next checkpoint completes or when recovering.
+func (m *SinkNode) commit(ctx api.StreamContext, checkpointId int64) {
+	i := 0
+	for ; i < len(m.pendingTxns) && m.pendingTxns[i] <= checkpointId; i++ {
+		if err := m.txSink.Commit(ctx, m.pendingTxns[i]); err != nil {
+			ctx.GetLogger().Errorf("sink node %s fails to commit transaction %d: %v", m.name, m.pendingTxns[i], err)
+			break
+		}
+		ctx.GetLogger().Debugf("sink node %s commits transaction %d", m.name, m.pendingTxns[i])
+	}
+	m.pendingTxns = m.pendingTxns[i:]
+	if err := ctx.PutState(PendingTransactionsKey, append([]int64{}, m.pendingTxns...)); err != nil {
+		ctx.GetLogger().Warnf("sink node %s fails to save pending transactions: %v", m.name, err)
+	}
+}
+
+// PreCommit implements checkpoint.TwoPhaseCommitTask. It is called in the sink instance goroutine when the barrier arrives.
+func (m *SinkNode) PreCommit(checkpointId int64) error {
+	m.mutex.RLock()
+	sink, ctx := m.txSink, m.txCtx
+	m.mutex.RUnlock()
+	if sink == nil {
+		return nil
+	}
+	if err := sink.PreCommit(ctx, checkpointId); err != nil {
+		if e := sink.Abort(ctx); e != nil {
+			ctx.GetLogger().Warnf("abort sink node %s transaction fails: %v", m.name, e)
+		}
+		err = fmt.Errorf("sink %s fails to pre-commit transaction %d: %v", m.name, checkpointId, err)
+		infra.DrainError(ctx, err, m.txResult)
+		return err
+	}
+	m.pendingTxns = append(m.pendingTxns, checkpointId)
+	return ctx.PutState(PendingTransactionsKey, append([]int64{}, m.pendingTxns...))
+}
+
+// NotifyCheckpointComplete implements checkpoint.TwoPhaseCommitTask. It is called by the coordinator.
+func (m *SinkNode) NotifyCheckpointComplete(checkpointId int64) {
+	m.mutex.RLock()
+	isTx := m.txSink != nil
+	m.mutex.RUnlock()
+	if !isTx {
+		return
+	}
+	select {
+	case m.commitCh <- checkpointId:
+	case <-m.ctx.Done():
+	}
+}
+
 func bufferLen(dataCh chan []map[string]interface{}, c *cache.SyncCache, rq *cache.SyncCache) int64 {
 	l := len(dataCh)
 	if c != nil {
@@ -425,8 +597,8 @@ func (m *SinkNode) parseConf(logger api.Logger) (*SinkConf, error) {
 	m.concurrency = sconf.Concurrency
 	if sconf.Format == "" {
 		sconf.Format = "json"
-	} else if sconf.Format != message.FormatJson && sconf.Format != message.FormatProtobuf && sconf.Format != message.FormatBinary && sconf.Format != message.FormatCustom && sconf.Format != message.FormatDelimited {
-		logger.Warnf("invalid type for format property, should be json protobuf or binary but found %s", sconf.Format)
+	} else if !message.IsFormatSupported(sconf.Format) {
+		logger.Warnf("invalid type for format property, should be json, protobuf, binary, delimited, custom, avro or msgpack but found %s", sconf.Format)
 		sconf.Format = "json"
 	}
 	err = cast.MapToStruct(m.options, &sconf.SinkConf)
@@ -450,6 +622,9 @@ func (m *SinkNode) reset() {
 		m.sinks = nil
 	}
 	m.statManagers = nil
+	m.txSink = nil
+	m.txCtx = nil
+	m.pendingTxns = nil
 }
 
 func doCollectMaps(ctx api.StreamContext, sink api.Sink, sconf *SinkConf, outs []map[string]interface{}, stats metric.StatManager, isResend bool) error {
@@ -518,10 +693,12 @@ func doCollectData(ctx api.StreamContext, sink api.Sink, outData interface{}, st
 func sendDataToSink(ctx api.StreamContext, sink api.Sink, outData interface{}, stats metric.StatManager) error {
 	if err := sink.Collect(ctx, outData); err != nil {
 		stats.IncTotalExceptions(err.Error())
+		reportSendError(stats, err)
 		return err
 	} else {
 		ctx.GetLogger().Debugf("success")
 		stats.IncTotalRecordsOut()
+		stats.SetConnectionStatus(metric.ConnectionConnected, nil)
 		return nil
 	}
 }
@@ -537,14 +714,24 @@ func resendDataToSink(ctx api.StreamContext, sink api.Sink, outData interface{},
 	}
 	if err != nil {
 		stats.IncTotalExceptions(err.Error())
+		reportSendError(stats, err)
 		return err
 	} else {
 		ctx.GetLogger().Debugf("success resend")
 		stats.IncTotalRecordsOut()
+		stats.SetConnectionStatus(metric.ConnectionConnected, nil)
 		return nil
 	}
 }
 
+// reportSendError marks the connection as reconnecting if the sink fails for an io error. The sink will try to send
+// again with the following data or by the cache.
+func reportSendError(stats metric.StatManager, err error) {
+	if isIOError(err) {
+		stats.SetConnectionStatus(metric.ConnectionReconnecting, err)
+	}
+}
+
 func getSink(name string, action map[string]interface{}) (api.Sink, error) {
 	var (
 		s   api.Sink
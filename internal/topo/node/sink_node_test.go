@@ -31,10 +31,14 @@ import (
 	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/schema"
 	"github.com/lf-edge/ekuiper/internal/testx"
+	"github.com/lf-edge/ekuiper/internal/topo/checkpoint"
 	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
 	"github.com/lf-edge/ekuiper/internal/topo/topotest/mocknode"
 	"github.com/lf-edge/ekuiper/internal/topo/transform"
 	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
 )
 
 func init() {
@@ -782,3 +786,82 @@ func TestSinkCache(t *testing.T) {
 		})
 	}
 }
+
+func TestTransactionSink(t *testing.T) {
+	conf.InitConf()
+	transform.RegisterAdditionalFuncs()
+	contextLogger := conf.Log.WithField("rule", "TestTransactionSink")
+	tempStore, _ := state.CreateStore("TestTransactionSink", api.AtMostOnce)
+	cctx, cancel := context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithCancel()
+	defer cancel()
+	ctx := cctx.WithMeta("TestTransactionSink", "mockSink", tempStore)
+
+	mockSink := mocknode.NewMockTxSink()
+	s := NewSinkNodeWithSink("mockSink", mockSink, nil)
+	s.AddInputCount()
+	s.SetQos(api.ExactlyOnce)
+	signal := make(chan *checkpoint.Signal, 10)
+	s.SetBarrierHandler(checkpoint.NewBarrierTracker(checkpoint.NewResponderExecutor(signal, s), 1))
+	s.Open(ctx, make(chan error, 1))
+
+	s.input <- &checkpoint.BufferOrEvent{Data: []map[string]interface{}{{"a": 1}}, Channel: "op"}
+	s.input <- &checkpoint.BufferOrEvent{Data: &checkpoint.Barrier{CheckpointId: 1, OpId: "op"}, Channel: "op"}
+	s.input <- &checkpoint.BufferOrEvent{Data: []map[string]interface{}{{"a": 2}}, Channel: "op"}
+	select {
+	case sg := <-signal:
+		assert.Equal(t, checkpoint.ACK, sg.Message)
+	case <-time.After(time.Second):
+		t.Fatal("checkpoint is not acked")
+	}
+	// Pre-committed but invisible before checkpoint complete
+	assert.Equal(t, 1, mockSink.GetPending())
+	assert.Equal(t, 0, len(mockSink.GetResults()))
+	st, _ := ctx.GetState(PendingTransactionsKey)
+	assert.Equal(t, []int64{1}, st)
+
+	s.NotifyCheckpointComplete(1)
+	for i := 0; i < 10; i++ {
+		if len(mockSink.GetResults()) > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, [][]byte{[]byte(`[{"a":1}]`)}, mockSink.GetResults())
+	assert.Equal(t, 0, mockSink.GetPending())
+	st, _ = ctx.GetState(PendingTransactionsKey)
+	assert.Equal(t, []int64{}, st)
+}
+
+func TestSinkConnectionHealth(t *testing.T) {
+	conf.InitConf()
+	transform.RegisterAdditionalFuncs()
+	contextLogger := conf.Log.WithField("rule", "TestSinkConnectionHealth")
+	ctx, cancel := context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithCancel()
+	defer cancel()
+
+	hitch := make(chan int, 10)
+	mockSink := mocknode.NewMockResendSink(hitch)
+	s := NewSinkNodeWithSink("mockSink", mockSink, nil)
+	s.Open(ctx, make(chan error))
+	// the mock sink fails with io error for the first data and succeeds for the second
+	expects := []struct {
+		status  string
+		lastErr string
+	}{
+		{status: metric.ConnectionReconnecting, lastErr: "io error: mock io error"},
+		{status: metric.ConnectionConnected, lastErr: "io error: mock io error"},
+	}
+	for i, e := range expects {
+		s.input <- []map[string]interface{}{{"a": i}}
+		select {
+		case <-hitch:
+		case <-time.After(time.Second):
+			t.Fatalf("sink is not hit for data %d", i)
+		}
+		// the mock sink signals before the status is updated
+		assert.Eventually(t, func() bool {
+			h := s.GetConnectionHealth()
+			return len(h) == 1 && h[0] != nil && h[0].Status == e.status && h[0].LastError == e.lastErr
+		}, time.Second, 10*time.Millisecond, "data %d", i)
+	}
+}
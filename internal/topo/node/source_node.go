@@ -20,6 +20,7 @@ import (
 
 	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/converter"
+	"github.com/lf-edge/ekuiper/internal/pkg/tracer"
 	"github.com/lf-edge/ekuiper/internal/topo/context"
 	nodeConf "github.com/lf-edge/ekuiper/internal/topo/node/conf"
 	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
@@ -41,6 +42,8 @@ type SourceNode struct {
 	sources      []api.Source
 	preprocessOp UnOperation
 	schema       map[string]*ast.JsonStreamField
+	// The offsets of the pending checkpoints to be committed by the api.OffsetCommitter source
+	pendingOffsets map[int64]interface{}
 }
 
 func NewSourceNode(name string, st ast.StreamType, op UnOperation, options *ast.Options, sendError bool, schema map[string]*ast.JsonStreamField) *SourceNode {
@@ -128,10 +131,12 @@ func (m *SourceNode) Open(ctx api.StreamContext, errCh chan<- error) {
 						m.statManagers = append(m.statManagers, stats)
 						m.mutex.Unlock()
 
-						si, err = getSourceInstance(m, instance)
+						si, err = getSourceInstance(m, instance, stats)
 						if err != nil {
+							stats.SetConnectionStatus(metric.ConnectionDisconnected, err)
 							return err
 						}
+						stats.SetConnectionStatus(metric.ConnectionConnected, nil)
 						m.mutex.Lock()
 						m.sources = append(m.sources, si.source)
 						m.mutex.Unlock()
@@ -143,6 +148,8 @@ func (m *SourceNode) Open(ctx api.StreamContext, errCh chan<- error) {
 							buffer.Close()
 						}()
 						logger.Infof("Start source %s instance %d successfully", m.name, instance)
+						// reconnecting is set when the source reports an io error and reset by the next data
+						reconnecting := false
 						for {
 							select {
 							case <-ctx.Done():
@@ -155,20 +162,33 @@ func (m *SourceNode) Open(ctx api.StreamContext, errCh chan<- error) {
 								m.schema = nil
 								return nil
 							case err := <-si.errorCh:
+								stats.SetConnectionStatus(metric.ConnectionDisconnected, err)
 								return err
 							case data := <-buffer.Out:
 								if t, ok := data.(*xsql.ErrorSourceTuple); ok {
 									logger.Errorf("Source %s error: %v", ctx.GetOpId(), t.Error)
+									if t.Payload != nil {
+										sendDeadLetter(ctx, t.Payload, t.Error)
+									}
 									stats.IncTotalExceptions(t.Error.Error())
+									if isIOError(t.Error) {
+										stats.SetConnectionStatus(metric.ConnectionReconnecting, t.Error)
+										reconnecting = true
+									}
 									continue
 								}
+								if reconnecting {
+									stats.SetConnectionStatus(metric.ConnectionConnected, nil)
+									reconnecting = false
+								}
 								stats.IncTotalRecordsIn()
 								rcvTime := conf.GetNow()
 								if !data.Timestamp().IsZero() {
 									rcvTime = data.Timestamp()
 								}
 								stats.SetProcessTimeStart(rcvTime)
-								tuple := &xsql.Tuple{Emitter: m.name, Message: data.Message(), Timestamp: rcvTime.UnixMilli(), Metadata: data.Meta()}
+								span, meta := tracer.StartSourceSpan(ctx, data.Meta())
+								tuple := &xsql.Tuple{Emitter: m.name, Message: data.Message(), Timestamp: rcvTime.UnixMilli(), Metadata: meta}
 								var processedData interface{}
 								if m.preprocessOp != nil {
 									processedData = m.preprocessOp.Apply(ctx, tuple, nil, nil)
@@ -179,18 +199,28 @@ func (m *SourceNode) Open(ctx api.StreamContext, errCh chan<- error) {
 								// blocking
 								switch val := processedData.(type) {
 								case nil:
+									tracer.EndSpan(span, nil)
 									continue
 								case error:
 									logger.Errorf("Source %s preprocess error: %s", ctx.GetOpId(), val)
+									sendDeadLetter(ctx, tuple.Message, val)
 									_ = m.Broadcast(val)
 									stats.IncTotalExceptions(val.Error())
+									tracer.EndSpan(span, val)
 								default:
 									_ = m.Broadcast(val)
 									stats.IncTotalRecordsOut()
+									tracer.EndSpan(span, nil)
 								}
 								stats.SetBufferLength(int64(buffer.GetLength()))
 								if rw, ok := si.source.(api.Rewindable); ok {
-									if offset, err := rw.GetOffset(); err != nil {
+									var offset interface{}
+									if ot, ok := data.(api.OffsetSourceTuple); ok {
+										offset = ot.Offset()
+									} else {
+										offset, err = rw.GetOffset()
+									}
+									if err != nil {
 										infra.DrainError(ctx, err, errCh)
 									} else {
 										err = ctx.PutState(OffsetKey, offset)
@@ -218,6 +248,62 @@ func (m *SourceNode) Open(ctx api.StreamContext, errCh chan<- error) {
 
 func (m *SourceNode) reset() {
 	m.statManagers = nil
+	m.pendingOffsets = nil
+}
+
+// OnSnapshot records the offset of the checkpoint for the api.OffsetCommitter source. The offset is read from the
+// snapshot rather than the live state which may have moved on, so that the committed offset is the saved one.
+func (m *SourceNode) OnSnapshot(checkpointId int64, snapshot map[string]interface{}) error {
+	if len(m.offsetCommitters()) == 0 {
+		return nil
+	}
+	offset := snapshot[OffsetKey]
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.pendingOffsets == nil {
+		m.pendingOffsets = make(map[int64]interface{})
+	}
+	m.pendingOffsets[checkpointId] = offset
+	return nil
+}
+
+// NotifyCheckpointComplete commits the offset of the completed checkpoint to the api.OffsetCommitter source
+func (m *SourceNode) NotifyCheckpointComplete(checkpointId int64) {
+	m.mutex.Lock()
+	offset, ok := m.pendingOffsets[checkpointId]
+	for cid := range m.pendingOffsets {
+		if cid <= checkpointId {
+			delete(m.pendingOffsets, cid)
+		}
+	}
+	m.mutex.Unlock()
+	if !ok || offset == nil {
+		return
+	}
+	for _, oc := range m.offsetCommitters() {
+		if err := oc.CommitOffset(m.ctx, offset); err != nil {
+			m.ctx.GetLogger().Warnf("Source %s fails to commit offset of checkpoint %d: %v", m.name, checkpointId, err)
+		} else {
+			m.ctx.GetLogger().Debugf("Source %s commits offset %v of checkpoint %d", m.name, offset, checkpointId)
+		}
+	}
+}
+
+// offsetCommitters returns the sources which commit the offsets. The shared source instance is not rewound by a rule, so
+// it is not committed either.
+func (m *SourceNode) offsetCommitters() []api.OffsetCommitter {
+	if m.options.SHARED {
+		return nil
+	}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	var result []api.OffsetCommitter
+	for _, s := range m.sources {
+		if oc, ok := s.(api.OffsetCommitter); ok {
+			result = append(result, oc)
+		}
+	}
+	return result
 }
 
 func (m *SourceNode) close() {
@@ -18,7 +18,13 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
 	nodeConf "github.com/lf-edge/ekuiper/internal/topo/node/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/ast"
 	"github.com/lf-edge/ekuiper/pkg/cast"
 )
@@ -113,3 +119,59 @@ type httpPullSourceConfig struct {
 	InsecureSkipVerify bool                   `json:"insecureSkipVerify"`
 	Headers            map[string]interface{} `json:"headers"`
 }
+
+type mockOffsetCommitter struct {
+	committed []interface{}
+}
+
+func (m *mockOffsetCommitter) Open(_ api.StreamContext, _ chan<- api.SourceTuple, _ chan<- error) {}
+
+func (m *mockOffsetCommitter) Configure(_ string, _ map[string]interface{}) error {
+	return nil
+}
+
+func (m *mockOffsetCommitter) Close(_ api.StreamContext) error {
+	return nil
+}
+
+func (m *mockOffsetCommitter) GetOffset() (interface{}, error) {
+	return nil, nil
+}
+
+func (m *mockOffsetCommitter) Rewind(_ interface{}) error {
+	return nil
+}
+
+func (m *mockOffsetCommitter) CommitOffset(_ api.StreamContext, offset interface{}) error {
+	m.committed = append(m.committed, offset)
+	return nil
+}
+
+func TestSourceCommitOffset(t *testing.T) {
+	contextLogger := conf.Log.WithField("rule", "TestSourceCommitOffset")
+	tempStore, _ := state.CreateStore("TestSourceCommitOffset", api.AtMostOnce)
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithMeta("TestSourceCommitOffset", "source", tempStore)
+
+	mc := &mockOffsetCommitter{}
+	n := NewSourceNode("test", ast.TypeStream, nil, &ast.Options{TYPE: "mock"}, false, nil)
+	n.ctx = ctx
+	n.sources = []api.Source{mc}
+
+	sctx := ctx.(*context.DefaultContext)
+	for cid := int64(1); cid <= 3; cid++ {
+		_ = ctx.PutState(OffsetKey, int(cid))
+		assert.NoError(t, sctx.Snapshot())
+		// The offset moves on after the snapshot, which is not saved in the checkpoint
+		_ = ctx.PutState(OffsetKey, int(cid)+10)
+		assert.NoError(t, n.OnSnapshot(cid, sctx.GetSnapshot()))
+	}
+	// Nothing committed before the checkpoint completes
+	assert.Empty(t, mc.committed)
+	// The completed checkpoint subsumes the previous ones
+	n.NotifyCheckpointComplete(2)
+	assert.Equal(t, []interface{}{2}, mc.committed)
+	n.NotifyCheckpointComplete(1)
+	assert.Equal(t, []interface{}{2}, mc.committed)
+	n.NotifyCheckpointComplete(3)
+	assert.Equal(t, []interface{}{2, 3}, mc.committed)
+}
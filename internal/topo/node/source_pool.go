@@ -22,7 +22,9 @@ import (
 
 	"github.com/lf-edge/ekuiper/internal/binder/io"
 	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/namespace"
 	kctx "github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
 	"github.com/lf-edge/ekuiper/internal/topo/state"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/infra"
@@ -34,11 +36,16 @@ var pool = &sourcePool{
 	registry: make(map[string]*sourceSingleton),
 }
 
-// node is readonly
-func getSourceInstance(node *SourceNode, index int) (*sourceInstance, error) {
+// sharedKey returns the key of the shared source. The streams of different namespaces may have the same name.
+func sharedKey(node *SourceNode) string {
+	return fmt.Sprintf("%s.%s", node.sourceType, namespace.Qualify(namespace.Of(node.ctx.GetRuleId()), node.name))
+}
+
+// node is readonly. The connection status of a source which is not shared is reported to the stats of the instance.
+func getSourceInstance(node *SourceNode, index int, stats metric.StatManager) (*sourceInstance, error) {
 	var si *sourceInstance
 	if node.options.SHARED {
-		rkey := fmt.Sprintf("%s.%s", node.sourceType, node.name)
+		rkey := sharedKey(node)
 		s, ok := pool.load(rkey)
 		if !ok {
 			ns, err := io.Source(node.sourceType)
@@ -75,7 +82,7 @@ func getSourceInstance(node *SourceNode, index int) (*sourceInstance, error) {
 			}
 			go func() {
 				err := infra.SafeRun(func() error {
-					nctx := node.ctx.WithInstance(index)
+					nctx := withConnectionReporter(node.ctx, index, stats)
 					defer si.source.Close(nctx)
 					si.source.Open(nctx, si.dataCh.In, si.errorCh)
 					return nil
@@ -100,7 +107,7 @@ func getSourceInstance(node *SourceNode, index int) (*sourceInstance, error) {
 // ONLY apply to shared instance
 func removeSourceInstance(node *SourceNode) {
 	for i := 0; i < node.concurrency; i++ {
-		rkey := fmt.Sprintf("%s.%s", node.sourceType, node.name)
+		rkey := sharedKey(node)
 		pool.deleteInstance(rkey, node, i)
 	}
 }
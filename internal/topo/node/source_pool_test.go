@@ -55,10 +55,10 @@ func TestSourcePool(t *testing.T) {
 	n2.ctx = ctx.WithMeta("mockRule2", "test2", tempStore)
 
 	// Test add source instance
-	getSourceInstance(n, 0)
-	getSourceInstance(n1, 0)
-	getSourceInstance(n, 1)
-	getSourceInstance(n2, 0)
+	getSourceInstance(n, 0, nil)
+	getSourceInstance(n1, 0, nil)
+	getSourceInstance(n, 1, nil)
+	getSourceInstance(n2, 0, nil)
 
 	poolLen := len(pool.registry)
 	if poolLen != 1 {
@@ -0,0 +1,246 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+const (
+	TapIn  = "in"
+	TapOut = "out"
+
+	defaultTapBufferLength = 1024
+)
+
+// TapOptions are the options of a tap subscriber
+type TapOptions struct {
+	// Direction is TapIn or TapOut to receive the input or output data only, empty to receive both
+	Direction string
+	// Filter is the condition of the rows to receive. The rows of a collection are filtered one by one.
+	Filter ast.Expr
+	// Rate is the max number of events per second, 0 means no limit
+	Rate int
+	// Limit is the number of events to receive before the subscriber ends, 0 means no limit
+	Limit int
+}
+
+func (o *TapOptions) Validate() error {
+	switch o.Direction {
+	case "", TapIn, TapOut:
+	default:
+		return fmt.Errorf("invalid tap direction %s, must be %s or %s", o.Direction, TapIn, TapOut)
+	}
+	if o.Rate < 0 {
+		return fmt.Errorf("invalid tap rate %d, must not be negative", o.Rate)
+	}
+	if o.Limit < 0 {
+		return fmt.Errorf("invalid tap limit %d, must not be negative", o.Limit)
+	}
+	return nil
+}
+
+// TapNode is a node which can be tapped
+type TapNode interface {
+	GetName() string
+	Tap(opts *TapOptions) (*TapSubscriber, error)
+}
+
+// TapEvent is the data of a node received by a tap subscriber
+type TapEvent struct {
+	Node      string `json:"node"`
+	Direction string `json:"direction"`
+	Timestamp int64  `json:"timestamp"`
+	// Data is a map for a row, a list of maps for a collection or the error message
+	Data interface{} `json:"data"`
+	// Dropped is the number of events dropped before this one by the rate limit or the full buffer
+	Dropped int64 `json:"dropped,omitempty"`
+
+	// the cloned data of the node before filtering and conversion
+	raw interface{}
+}
+
+// TapSubscriber receives the sampled data of a node. It is created by attaching to a node of a running rule.
+type TapSubscriber struct {
+	opts    *TapOptions
+	ch      chan *TapEvent
+	tap     *tapPoint
+	done    <-chan struct{}
+	fv      *xsql.FunctionValuer
+	dropped int64
+	// the timestamp of the last event for the rate limit
+	last int64
+	sent int
+}
+
+// Next returns the next event which passes the filter and the rate limit. It returns io.EOF if the limit is reached
+// or the node is stopped.
+func (s *TapSubscriber) Next(ctx context.Context) (*TapEvent, error) {
+	if s.opts.Limit > 0 && s.sent >= s.opts.Limit {
+		return nil, io.EOF
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-s.done:
+			return nil, io.EOF
+		case ev := <-s.ch:
+			data, ok := s.convert(ev.raw)
+			if !ok {
+				continue
+			}
+			if s.opts.Rate > 0 && s.sent > 0 && ev.Timestamp-s.last < int64(time.Second/time.Millisecond)/int64(s.opts.Rate) {
+				atomic.AddInt64(&s.dropped, 1)
+				continue
+			}
+			s.last = ev.Timestamp
+			s.sent++
+			return &TapEvent{
+				Node:      ev.Node,
+				Direction: ev.Direction,
+				Timestamp: ev.Timestamp,
+				Data:      data,
+				Dropped:   atomic.SwapInt64(&s.dropped, 0),
+			}, nil
+		}
+	}
+}
+
+// Close detaches the subscriber from the node
+func (s *TapSubscriber) Close() {
+	s.tap.unsubscribe(s)
+}
+
+// convert filters the data and converts it to be serialized
+func (s *TapSubscriber) convert(raw interface{}) (interface{}, bool) {
+	switch d := raw.(type) {
+	case error:
+		if s.opts.Filter != nil {
+			return nil, false
+		}
+		return d.Error(), true
+	case xsql.Collection:
+		if s.opts.Filter == nil {
+			return d.ToMaps(), true
+		}
+		var indexes []int
+		_ = d.Range(func(i int, r xsql.ReadonlyRow) (bool, error) {
+			if s.match(r) {
+				indexes = append(indexes, i)
+			}
+			return true, nil
+		})
+		if len(indexes) == 0 {
+			return nil, false
+		}
+		return d.Filter(indexes).ToMaps(), true
+	case xsql.TupleRow:
+		if s.opts.Filter != nil && !s.match(d) {
+			return nil, false
+		}
+		return d.ToMap(), true
+	default:
+		return nil, false
+	}
+}
+
+func (s *TapSubscriber) match(r xsql.Valuer) bool {
+	ve := &xsql.ValuerEval{Valuer: xsql.MultiValuer(r, s.fv)}
+	b, ok := ve.Eval(s.opts.Filter).(bool)
+	return ok && b
+}
+
+// tapPoint publishes the data of a node to the attached subscribers. When no subscriber is attached, it only costs
+// an atomic load for each data, so the node runs as usual.
+type tapPoint struct {
+	count int32
+	mu    sync.RWMutex
+	subs  map[*TapSubscriber]struct{}
+}
+
+func (t *tapPoint) active() bool {
+	return atomic.LoadInt32(&t.count) > 0
+}
+
+func (t *tapPoint) subscribe(ctx api.StreamContext, opts *TapOptions) (*TapSubscriber, error) {
+	if ctx == nil {
+		return nil, fmt.Errorf("the node is not running")
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	fv, _ := xsql.NewFunctionValuersForOp(ctx)
+	s := &TapSubscriber{
+		opts: opts,
+		ch:   make(chan *TapEvent, defaultTapBufferLength),
+		tap:  t,
+		done: ctx.Done(),
+		fv:   fv,
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.subs == nil {
+		t.subs = make(map[*TapSubscriber]struct{})
+	}
+	t.subs[s] = struct{}{}
+	atomic.StoreInt32(&t.count, int32(len(t.subs)))
+	return s, nil
+}
+
+func (t *tapPoint) unsubscribe(s *TapSubscriber) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.subs, s)
+	atomic.StoreInt32(&t.count, int32(len(t.subs)))
+}
+
+// publish sends a copy of the data to the subscribers without blocking the node. The data is converted by the
+// subscriber so that the filter is not evaluated in the node goroutine.
+func (t *tapPoint) publish(name string, direction string, val interface{}) {
+	switch vt := val.(type) {
+	case error:
+	case xsql.Collection:
+		val = vt.Clone()
+	case xsql.TupleRow:
+		val = vt.Clone()
+	default:
+		// The watermark and other control data are not tapped
+		return
+	}
+	ev := &TapEvent{Node: name, Direction: direction, Timestamp: conf.GetNowInMilli(), raw: val}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for s := range t.subs {
+		if s.opts.Direction != "" && s.opts.Direction != direction {
+			continue
+		}
+		select {
+		case s.ch <- ev:
+		default:
+			atomic.AddInt64(&s.dropped, 1)
+		}
+	}
+}
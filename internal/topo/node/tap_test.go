@@ -0,0 +1,109 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	gocontext "context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+func TestTap(t *testing.T) {
+	conf.InitClock()
+	mc := conf.Clock.(*clock.Mock)
+	contextLogger := conf.Log.WithField("rule", "TestTap")
+	tempStore, _ := state.CreateStore("TestTap", api.AtMostOnce)
+	ctx, cancel := context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithMeta("TestTap", "op1", tempStore).WithCancel()
+	defer cancel()
+	out := make(chan interface{}, 100)
+	n := &defaultSinkNode{
+		input: make(chan interface{}, 100),
+		defaultNode: &defaultNode{
+			name:    "op1",
+			outputs: map[string]chan<- interface{}{"sink": out},
+			ctx:     ctx,
+		},
+	}
+	row := func(a int) *xsql.Tuple {
+		return &xsql.Tuple{Emitter: "demo", Message: xsql.Message{"a": a}}
+	}
+	assert.False(t, n.tap.active())
+
+	_, err := n.Tap(&TapOptions{Direction: "both"})
+	assert.EqualError(t, err, "invalid tap direction both, must be in or out")
+
+	filter, err := xsql.NewParser(strings.NewReader("a > 1")).ParseExpr()
+	require.NoError(t, err)
+	all, err := n.Tap(&TapOptions{Limit: 3})
+	require.NoError(t, err)
+	filtered, err := n.Tap(&TapOptions{Direction: TapOut, Filter: filter, Rate: 1})
+	require.NoError(t, err)
+	assert.True(t, n.tap.active())
+
+	n.preprocess(row(1))
+	_ = n.Broadcast(row(2))
+	_ = n.Broadcast(&xsql.WindowTuples{Content: []xsql.TupleRow{row(1), row(3)}})
+	_ = n.Broadcast(errors.New("mock error"))
+	_ = n.Broadcast(row(4))
+
+	bg := gocontext.Background()
+	exp := []*TapEvent{
+		{Node: "op1", Direction: TapIn, Data: map[string]interface{}{"a": 1}},
+		{Node: "op1", Direction: TapOut, Data: map[string]interface{}{"a": 2}},
+		{Node: "op1", Direction: TapOut, Data: []map[string]interface{}{{"a": 1}, {"a": 3}}},
+	}
+	for _, e := range exp {
+		ev, err := all.Next(bg)
+		require.NoError(t, err)
+		ev.Timestamp = 0
+		assert.Equal(t, e, ev)
+	}
+	// Ends after the limit
+	_, err = all.Next(bg)
+	assert.Equal(t, io.EOF, err)
+	all.Close()
+
+	// The input and the rows not matching the filter are skipped, and the events are limited to 1 per second
+	ev, err := filtered.Next(bg)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a": 2}, ev.Data)
+	mc.Add(time.Second)
+	_ = n.Broadcast(row(5))
+	ev, err = filtered.Next(bg)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a": 5}, ev.Data)
+	// The collection and the row 4 are dropped by the rate limit
+	assert.Equal(t, int64(2), ev.Dropped)
+
+	// The subscriber ends when the node stops
+	cancel()
+	_, err = filtered.Next(bg)
+	assert.Equal(t, io.EOF, err)
+	filtered.Close()
+	assert.False(t, n.tap.active())
+}
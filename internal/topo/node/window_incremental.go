@@ -0,0 +1,635 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/lf-edge/ekuiper/internal/binder/function"
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+const (
+	WindowPanesKey      = "$$windowPanes"
+	WindowPaneOriginKey = "$$windowPaneOrigin"
+)
+
+// WindowPane is the partial aggregation of the tuples in a time slice of the window.
+// The pane size is the gcd of the window length and the hopping interval so that each window consists of whole panes.
+// For tumbling window, there is only one pane for each window. For count window, the pane is a slice of the tuple
+// sequence instead of the time, and the start is the sequence number of its first tuple.
+type WindowPane struct {
+	Start int64
+	// First is the timestamp of the earliest tuple in the pane
+	First  int64
+	Groups []*PaneGroup
+	index  map[string]*PaneGroup
+}
+
+// PaneGroup is the accumulators of a group by key in the pane
+type PaneGroup struct {
+	Key string
+	// Row is the first tuple of the group, it is used to evaluate the non aggregate fields
+	Row  *xsql.Tuple
+	Accs []interface{}
+}
+
+func init() {
+	gob.Register([]*WindowPane{})
+	gob.Register([]interface{}{})
+}
+
+type boundIncAgg struct {
+	call *ast.Call
+	f    api.AggregateFunction
+	fctx api.FunctionContext
+}
+
+// incAggWindow accumulates the tuples into the panes and merges the panes of the window when triggered.
+// It supports the tumbling and hopping window, and the count and session window of processing time which are decided
+// by the planner.
+type incAggWindow struct {
+	condition  ast.Expr
+	dimensions ast.Dimensions
+	aggs       []*boundIncAgg
+	fv         *xsql.FunctionValuer
+	paneSize   int64
+	// The panes are aligned to the origin which is a window end time
+	origin int64
+	// sorted by the start time
+	panes []*WindowPane
+}
+
+func (o *WindowOperator) newIncAggWindow(ctx api.StreamContext) (*incAggWindow, error) {
+	fv, _ := xsql.NewFunctionValuersForOp(ctx)
+	w := &incAggWindow{
+		condition:  o.window.IncCondition,
+		dimensions: o.window.Dimensions,
+		fv:         fv,
+		paneSize:   o.window.Length,
+	}
+	switch o.window.Type {
+	case ast.HOPPING_WINDOW, ast.COUNT_WINDOW:
+		w.paneSize = gcd(o.window.Length, o.window.Interval)
+	}
+	for _, c := range o.window.IncAggFuncs {
+		// The user defined function is resolved first because the built-in functions cannot be overridden
+		if f, fctx, ok := fv.IncAggFunc(c.Name, c.FuncId); ok {
+			w.aggs = append(w.aggs, &boundIncAgg{call: c, f: f, fctx: fctx})
+			continue
+		}
+		f, ok := function.BuiltinIncAggFunc(c.Name)
+		if !ok {
+			return nil, fmt.Errorf("function %s cannot be calculated incrementally", c.Name)
+		}
+		w.aggs = append(w.aggs, &boundIncAgg{call: c, f: f, fctx: context.NewDefaultFuncContext(ctx, c.FuncId)})
+	}
+	if s, err := ctx.GetState(WindowPanesKey); err == nil {
+		switch st := s.(type) {
+		case []*WindowPane:
+			w.panes = st
+			ctx.GetLogger().Infof("Restore window state with %d panes", len(st))
+		case nil:
+			ctx.GetLogger().Debugf("Restore window state, nothing")
+		default:
+			return nil, fmt.Errorf("restore window state `panes` %v error, invalid type", st)
+		}
+	} else {
+		ctx.GetLogger().Warnf("Restore window state fails: %s", err)
+	}
+	if s, err := ctx.GetState(WindowPaneOriginKey); err == nil && s != nil {
+		if si, ok := s.(int64); ok {
+			w.origin = si
+		} else {
+			return nil, fmt.Errorf("restore window state `paneOrigin` %v error, invalid type", s)
+		}
+	}
+	return w, nil
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// accumulate evaluates the group by key and the aggregate arguments of the tuple, and accumulates them into the pane
+// of the position which is the timestamp or the sequence number of the tuple for count window
+func (w *incAggWindow) accumulate(d *xsql.Tuple, pos int64) error {
+	ve := &xsql.ValuerEval{Valuer: xsql.MultiValuer(d, w.fv, &xsql.WildcardValuer{Data: d})}
+	if w.condition != nil {
+		switch r := ve.Eval(w.condition).(type) {
+		case error:
+			return fmt.Errorf("run Where error: %s", r)
+		case bool:
+			if !r {
+				return nil
+			}
+		case nil: // nil is false
+			return nil
+		default:
+			return fmt.Errorf("run Where error: invalid condition that returns non-bool value %[1]T(%[1]v)", r)
+		}
+	}
+	var key string
+	for _, dim := range w.dimensions {
+		r := ve.Eval(dim.Expr)
+		if _, ok := r.(error); ok {
+			return fmt.Errorf("run Group By error: %v", r)
+		}
+		key += fmt.Sprintf("%v,", r)
+	}
+	args := make([][]interface{}, len(w.aggs))
+	for i, agg := range w.aggs {
+		args[i] = make([]interface{}, len(agg.call.Args))
+		for j, arg := range agg.call.Args {
+			args[i][j] = ve.Eval(arg)
+			if e, ok := args[i][j].(error); ok {
+				return fmt.Errorf("call func %s error: %v", agg.call.Name, e)
+			}
+		}
+	}
+	p := w.getPane(pos)
+	if len(p.Groups) == 0 || d.Timestamp < p.First {
+		p.First = d.Timestamp
+	}
+	g, ok := p.index[key]
+	if !ok {
+		g = &PaneGroup{Key: key, Row: d, Accs: make([]interface{}, len(w.aggs))}
+		for i, agg := range w.aggs {
+			acc, err := agg.f.Init(agg.fctx)
+			if err != nil {
+				return fmt.Errorf("call func %s error: %v", agg.call.Name, err)
+			}
+			g.Accs[i] = acc
+		}
+		p.Groups = append(p.Groups, g)
+		p.index[key] = g
+	}
+	for i, agg := range w.aggs {
+		acc, err := agg.f.Accumulate(agg.fctx, g.Accs[i], args[i])
+		if err != nil {
+			return fmt.Errorf("call func %s error: %v", agg.call.Name, err)
+		}
+		g.Accs[i] = acc
+	}
+	return nil
+}
+
+// getPane returns the pane of the position, the pane is created if not exist
+func (w *incAggWindow) getPane(pos int64) *WindowPane {
+	offset := (pos - w.origin) % w.paneSize
+	if offset < 0 {
+		offset += w.paneSize
+	}
+	start := pos - offset
+	// The tuples arrive in order mostly
+	i := len(w.panes)
+	if i == 0 || w.panes[i-1].Start != start {
+		i = sort.Search(len(w.panes), func(j int) bool { return w.panes[j].Start >= start })
+	} else {
+		i--
+	}
+	if i < len(w.panes) && w.panes[i].Start == start {
+		p := w.panes[i]
+		if p.index == nil {
+			// restored from the state
+			p.index = make(map[string]*PaneGroup, len(p.Groups))
+			for _, g := range p.Groups {
+				p.index[g.Key] = g
+			}
+		}
+		return p
+	}
+	p := &WindowPane{Start: start, index: make(map[string]*PaneGroup)}
+	w.panes = append(w.panes, nil)
+	copy(w.panes[i+1:], w.panes[i:])
+	w.panes[i] = p
+	return p
+}
+
+// first returns the timestamp of the earliest tuple in the panes
+func (w *incAggWindow) first() int64 {
+	var r int64 = math.MaxInt64
+	for _, p := range w.panes {
+		if p.First < r {
+			r = p.First
+		}
+	}
+	return r
+}
+
+// merge calculates the aggregation results of the panes and returns the collection for the downstream operators.
+// The aggregate calls are cached in the rows so that they are evaluated as field values.
+func (w *incAggWindow) merge(panes []*WindowPane, wr *xsql.WindowRange) interface{} {
+	var (
+		keys   []string
+		groups = make(map[string][]*PaneGroup)
+	)
+	for _, p := range panes {
+		for _, g := range p.Groups {
+			if _, ok := groups[g.Key]; !ok {
+				keys = append(keys, g.Key)
+			}
+			groups[g.Key] = append(groups[g.Key], g)
+		}
+	}
+	if len(w.dimensions) == 0 {
+		result := &xsql.WindowTuples{Content: make([]xsql.TupleRow, 0), WindowRange: wr}
+		gs := groups[""]
+		if len(gs) > 0 {
+			result.Content = append(result.Content, gs[0].Row.Clone().(*xsql.Tuple))
+		}
+		for i, agg := range w.aggs {
+			result.Set(agg.call.CachedField, w.result(i, gs))
+		}
+		return result
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	result := &xsql.GroupedTuplesSet{Groups: make([]*xsql.GroupedTuples, 0, len(keys)), WindowRange: wr}
+	for _, k := range keys {
+		gs := groups[k]
+		gt := &xsql.GroupedTuples{Content: []xsql.TupleRow{gs[0].Row.Clone().(*xsql.Tuple)}, WindowRange: wr}
+		for i, agg := range w.aggs {
+			gt.Set(agg.call.CachedField, w.result(i, gs))
+		}
+		result.Groups = append(result.Groups, gt)
+	}
+	return result
+}
+
+// result merges the accumulators of the ith aggregate function of the group in all panes
+func (w *incAggWindow) result(i int, gs []*PaneGroup) interface{} {
+	agg := w.aggs[i]
+	var (
+		acc interface{}
+		err error
+	)
+	if len(gs) == 1 {
+		acc = gs[0].Accs[i]
+	} else {
+		// Merge into a new accumulator so that the accumulators of the panes are not changed
+		acc, err = agg.f.Init(agg.fctx)
+		for _, g := range gs {
+			if err != nil {
+				break
+			}
+			acc, err = agg.f.Merge(agg.fctx, acc, g.Accs[i])
+		}
+	}
+	if err == nil {
+		var r interface{}
+		r, err = agg.f.Result(agg.fctx, acc)
+		if err == nil {
+			return r
+		}
+	}
+	return fmt.Errorf("call func %s error: %v", agg.call.Name, err)
+}
+
+func (o *WindowOperator) execIncAggWindow(ctx api.StreamContext, w *incAggWindow) {
+	log := ctx.GetLogger()
+	var (
+		timeoutTicker *clock.Timer
+		firstTime     int64
+		nextTime      int64
+		firstC        <-chan time.Time
+		timeout       <-chan time.Time
+		c             <-chan time.Time
+	)
+	switch o.window.Type {
+	case ast.HOPPING_WINDOW, ast.COUNT_WINDOW:
+		o.interval = o.window.Interval
+	default:
+		// The session window ticks by the window length like tumbling window
+		o.interval = o.window.Length
+	}
+	if o.window.Type != ast.COUNT_WINDOW {
+		var firstTicker *clock.Timer
+		firstTime, firstTicker = getFirstTimer(ctx, o.window.RawInterval, o.window.TimeUnit)
+		firstC = firstTicker.C
+		w.origin = firstTime
+	}
+	// resume the previous window
+	if len(w.panes) > 0 && o.triggerTime > 0 {
+		switch o.window.Type {
+		case ast.TUMBLING_WINDOW, ast.HOPPING_WINDOW:
+			nextTick := conf.GetNowInMilli() + o.interval
+			for next := o.triggerTime + o.interval; next <= nextTick; next += o.interval {
+				log.Debugf("triggered by restore inputs")
+				o.incAggScan(ctx, w, next)
+			}
+			_ = ctx.PutState(WindowPanesKey, w.panes)
+			_ = ctx.PutState(TriggerTimeKey, o.triggerTime)
+		case ast.SESSION_WINDOW:
+			// The gaps between the restored tuples are unknown, so the session times out if no tuple arrives
+			timeoutTicker = conf.GetTimer(o.window.Interval)
+			timeout = timeoutTicker.C
+		}
+	}
+	for {
+		select {
+		// process incoming item
+		case item, opened := <-o.input:
+			processed := false
+			if item, processed = o.preprocess(item); processed {
+				break
+			}
+			o.statManager.IncTotalRecordsIn()
+			o.statManager.ProcessTimeStart()
+			if !opened {
+				o.statManager.IncTotalExceptions("input channel closed")
+				break
+			}
+			switch d := item.(type) {
+			case error:
+				_ = o.Broadcast(d)
+				o.statManager.IncTotalExceptions(d.Error())
+			case *xsql.Tuple:
+				log.Debugf("Incremental window receive tuple %s", d.Message)
+				pos := d.Timestamp
+				if o.window.Type == ast.COUNT_WINDOW {
+					pos = int64(o.msgCount)
+					o.msgCount++
+				}
+				err := w.accumulate(d, pos)
+				o.traceTuple(ctx, d, err)
+				if err != nil {
+					_ = o.Broadcast(err)
+					o.statManager.IncTotalExceptions(err.Error())
+				}
+				switch o.window.Type {
+				case ast.SESSION_WINDOW:
+					if timeoutTicker != nil {
+						timeoutTicker.Stop()
+						timeoutTicker.Reset(time.Duration(o.window.Interval) * time.Millisecond)
+					} else {
+						timeoutTicker = conf.GetTimer(o.window.Interval)
+						timeout = timeoutTicker.C
+						o.triggerTime = d.Timestamp
+						_ = ctx.PutState(TriggerTimeKey, o.triggerTime)
+						log.Debugf("Session window set start time %d", o.triggerTime)
+					}
+				case ast.COUNT_WINDOW:
+					if int64(o.msgCount)%o.window.Interval == 0 {
+						o.incAggCountScan(ctx, w)
+					}
+					_ = ctx.PutState(MsgCountKey, o.msgCount)
+				}
+				o.statManager.ProcessTimeEnd()
+				o.statManager.SetBufferLength(int64(len(o.input)))
+				_ = ctx.PutState(WindowPanesKey, w.panes)
+			default:
+				e := fmt.Errorf("run Window error: expect xsql.Tuple type but got %[1]T(%[1]v)", d)
+				_ = o.Broadcast(e)
+				o.statManager.IncTotalExceptions(e.Error())
+			}
+		case now := <-firstC:
+			log.Debugf("First tick at %v(%d), defined at %d", now, now.UnixMilli(), firstTime)
+			o.ticker = conf.GetTicker(o.interval)
+			firstC = nil
+			c = o.ticker.C
+			o.incAggTick(ctx, w, firstTime)
+			nextTime = firstTime + o.interval
+		case now := <-c:
+			log.Debugf("Successive tick at %v(%d)", now, now.UnixMilli())
+			o.incAggTick(ctx, w, nextTime)
+			nextTime += o.interval
+		case now := <-timeout:
+			if len(w.panes) > 0 {
+				o.statManager.ProcessTimeStart()
+				log.Debugf("triggered by timeout")
+				o.incAggScan(ctx, w, cast.TimeToUnixMilli(now))
+				// expire all panes, so that when timer scans there is no item
+				w.panes = nil
+				o.statManager.ProcessTimeEnd()
+				_ = ctx.PutState(WindowPanesKey, w.panes)
+				_ = ctx.PutState(TriggerTimeKey, o.triggerTime)
+				timeoutTicker = nil
+			}
+		// is cancelling
+		case <-ctx.Done():
+			log.Infoln("Cancelling window....")
+			if o.ticker != nil {
+				o.ticker.Stop()
+			}
+			return
+		}
+	}
+}
+
+// execIncAggEventWindow is the event time version of execIncAggWindow. The watermark op sends the tuples in time order
+// and all the tuples before a watermark are received before it, so the windows ended before the watermark are merged
+// to emit when the watermark arrives.
+func (o *WindowOperator) execIncAggEventWindow(ctx api.StreamContext, w *incAggWindow) {
+	log := ctx.GetLogger()
+	if o.window.Type == ast.HOPPING_WINDOW {
+		o.interval = o.window.Interval
+	} else {
+		o.interval = o.window.Length
+	}
+	// The end of the next window to emit, 0 if the first tuple has not arrived. The first window is aligned to the
+	// timestamp of the first tuple like the buffering event time window.
+	var nextWindowEnd int64
+	if w.origin > 0 {
+		nextWindowEnd = w.origin
+		if o.triggerTime >= w.origin {
+			nextWindowEnd = o.triggerTime + o.interval
+		}
+	}
+	for {
+		select {
+		// process incoming item
+		case item, opened := <-o.input:
+			if !opened {
+				o.statManager.IncTotalExceptions("input channel closed")
+				break
+			}
+			processed := false
+			if item, processed = o.preprocess(item); processed {
+				break
+			}
+			switch d := item.(type) {
+			case error:
+				_ = o.Broadcast(d)
+				o.statManager.IncTotalExceptions(d.Error())
+			case *xsql.WatermarkTuple:
+				watermarkTs := d.GetTimestamp()
+				log.Debugf("Incremental window receive watermark %d", watermarkTs)
+				if nextWindowEnd == 0 {
+					break
+				}
+				o.statManager.ProcessTimeStart()
+				for nextWindowEnd <= watermarkTs {
+					o.incAggScan(ctx, w, nextWindowEnd)
+					nextWindowEnd += o.interval
+				}
+				o.statManager.ProcessTimeEnd()
+				_ = ctx.PutState(WindowPanesKey, w.panes)
+				_ = ctx.PutState(TriggerTimeKey, o.triggerTime)
+			case *xsql.Tuple:
+				o.statManager.IncTotalRecordsIn()
+				o.statManager.ProcessTimeStart()
+				log.Debugf("Incremental event window receive tuple %s", d.Message)
+				if nextWindowEnd == 0 {
+					// first tuple, set the window start time, which will set to triggerTime
+					o.triggerTime = d.Timestamp
+					w.origin = getAlignedWindowEndTime(time.UnixMilli(d.Timestamp), o.window.RawInterval, o.window.TimeUnit).UnixMilli()
+					nextWindowEnd = w.origin
+					_ = ctx.PutState(TriggerTimeKey, o.triggerTime)
+					_ = ctx.PutState(WindowPaneOriginKey, w.origin)
+				}
+				err := w.accumulate(d, d.Timestamp)
+				o.traceTuple(ctx, d, err)
+				if err != nil {
+					_ = o.Broadcast(err)
+					o.statManager.IncTotalExceptions(err.Error())
+				}
+				o.statManager.ProcessTimeEnd()
+				o.statManager.SetBufferLength(int64(len(o.input)))
+				_ = ctx.PutState(WindowPanesKey, w.panes)
+			default:
+				e := fmt.Errorf("run Window error: expect xsql.Tuple type but got %[1]T(%[1]v)", d)
+				_ = o.Broadcast(e)
+				o.statManager.IncTotalExceptions(e.Error())
+			}
+		// is cancelling
+		case <-ctx.Done():
+			log.Infoln("Cancelling window....")
+			return
+		}
+	}
+}
+
+func (o *WindowOperator) incAggTick(ctx api.StreamContext, w *incAggWindow, n int64) {
+	if o.window.Type == ast.SESSION_WINDOW {
+		// Only emit the session which has lasted for a whole window length like tick
+		if len(w.panes) == 0 || n-o.window.Length < w.first() {
+			return
+		}
+	}
+	o.statManager.ProcessTimeStart()
+	ctx.GetLogger().Debugf("triggered by ticker at %d", n)
+	o.incAggScan(ctx, w, n)
+	o.statManager.ProcessTimeEnd()
+	_ = ctx.PutState(WindowPanesKey, w.panes)
+	_ = ctx.PutState(TriggerTimeKey, o.triggerTime)
+}
+
+// incAggScan is the incremental version of scan. The panes before the trigger time are merged to emit,
+// and the expired panes are removed like the tuples in scan.
+func (o *WindowOperator) incAggScan(ctx api.StreamContext, w *incAggWindow, triggerTime int64) {
+	log := ctx.GetLogger()
+	log.Debugf("window %s triggered at %s(%d)", o.name, time.Unix(triggerTime/1000, triggerTime%1000), triggerTime)
+	var (
+		delta       int64
+		windowStart int64
+		windowEnd   = triggerTime
+		length      = o.window.Length + o.window.Delay
+		included    []*WindowPane
+	)
+	if o.window.Type == ast.HOPPING_WINDOW {
+		delta = o.calDelta(triggerTime, log)
+	}
+	i := 0
+	for _, p := range w.panes {
+		if o.window.Type == ast.HOPPING_WINDOW {
+			// Expired only if the last possible tuple of the pane is expired
+			if triggerTime-(p.Start+w.paneSize-1) > length+delta {
+				log.Debugf("pane %d expired", p.Start)
+				continue
+			}
+			w.panes[i] = p
+			i++
+		} else if p.Start >= triggerTime {
+			w.panes[i] = p
+			i++
+		}
+		if p.Start < triggerTime {
+			included = append(included, p)
+		}
+	}
+	for j := i; j < len(w.panes); j++ {
+		w.panes[j] = nil
+	}
+	w.panes = w.panes[:i]
+
+	switch o.window.Type {
+	case ast.TUMBLING_WINDOW, ast.SESSION_WINDOW:
+		windowStart = o.triggerTime
+	case ast.HOPPING_WINDOW:
+		windowStart = o.triggerTime - o.window.Interval
+	}
+	if windowStart <= 0 {
+		windowStart = windowEnd - length
+	}
+	results := w.merge(included, xsql.NewWindowRange(windowStart, windowEnd))
+	log.Debugf("window %s triggered for %d panes", o.name, len(included))
+	if results != nil {
+		log.Debugf("Sent: %v", results)
+		_ = o.Broadcast(results)
+		o.statManager.IncTotalRecordsOut()
+	}
+	o.triggerTime = triggerTime
+	log.Debugf("new trigger time %d", o.triggerTime)
+}
+
+// incAggCountScan is the incremental version of the count window trigger. It is triggered every interval tuples and
+// merges the panes of the last length tuples. Like the rest tuples of TupleList, only the panes which are included by
+// the next window are kept.
+func (o *WindowOperator) incAggCountScan(ctx api.StreamContext, w *incAggWindow) {
+	log := ctx.GetLogger()
+	n := int64(o.msgCount)
+	if n < o.window.Length {
+		return
+	}
+	var included []*WindowPane
+	i := 0
+	for _, p := range w.panes {
+		if p.Start >= n-o.window.Length {
+			included = append(included, p)
+		}
+		if p.Start >= n+o.window.Interval-o.window.Length {
+			w.panes[i] = p
+			i++
+		}
+	}
+	for j := i; j < len(w.panes); j++ {
+		w.panes[j] = nil
+	}
+	w.panes = w.panes[:i]
+	triggerTime := conf.GetNowInMilli()
+	results := w.merge(included, xsql.NewWindowRange(triggerTime, triggerTime))
+	log.Debugf("count window %s triggered for %d panes", o.name, len(included))
+	if results != nil {
+		log.Debugf("Sent: %v", results)
+		_ = o.Broadcast(results)
+		o.statManager.IncTotalRecordsOut()
+	}
+}
@@ -0,0 +1,486 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+	"github.com/lf-edge/ekuiper/pkg/infra"
+)
+
+const (
+	WindowKeysKey       = "$$windowKeys"
+	KeyedWindowStateKey = "$$keyedWindow_"
+)
+
+// KeyedWindowState is the window state of one partition key.
+// It is saved into the checkpoint store separately for each key.
+type KeyedWindowState struct {
+	Inputs      []*xsql.Tuple
+	TriggerTime int64
+	MsgCount    int
+	// For event time only
+	NextWindowEndTs int64
+	PrevWindowEndTs int64
+	LastTicked      bool
+	TriggerTS       []int64
+	DelayTS         []int64
+}
+
+func init() {
+	gob.Register(KeyedWindowState{})
+}
+
+type windowPartition struct {
+	*KeyedWindowState
+	key string
+	// The session timeout timer of processing time session window. The gen is increased when resetting the timer
+	// so that the outdated timeout can be ignored.
+	timer *clock.Timer
+	gen   int
+}
+
+type partitionEvent struct {
+	key string
+	gen int
+	ts  int64
+}
+
+// windowPartitions holds all the partitions of a keyed window and syncs them to the state
+type windowPartitions struct {
+	parts map[string]*windowPartition
+}
+
+func (ps *windowPartitions) get(key string) (*windowPartition, bool) {
+	p, ok := ps.parts[key]
+	return p, ok
+}
+
+func (ps *windowPartitions) getOrCreate(ctx api.StreamContext, key string, triggerTime int64) *windowPartition {
+	if p, ok := ps.parts[key]; ok {
+		return p
+	}
+	p := &windowPartition{
+		KeyedWindowState: &KeyedWindowState{TriggerTime: triggerTime},
+		key:              key,
+	}
+	ps.parts[key] = p
+	ps.saveKeys(ctx)
+	return p
+}
+
+func (ps *windowPartitions) save(ctx api.StreamContext, p *windowPartition) {
+	_ = ctx.PutState(KeyedWindowStateKey+p.key, *p.KeyedWindowState)
+}
+
+func (ps *windowPartitions) remove(ctx api.StreamContext, key string) {
+	if p, ok := ps.parts[key]; ok {
+		if p.timer != nil {
+			p.timer.Stop()
+		}
+		delete(ps.parts, key)
+		_ = ctx.DeleteState(KeyedWindowStateKey + key)
+		ps.saveKeys(ctx)
+	}
+}
+
+func (ps *windowPartitions) saveKeys(ctx api.StreamContext) {
+	_ = ctx.PutState(WindowKeysKey, ps.sortedKeys())
+}
+
+// sortedKeys returns the keys in order so that the output of the partitions are deterministic
+func (ps *windowPartitions) sortedKeys() []string {
+	keys := make([]string, 0, len(ps.parts))
+	for k := range ps.parts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (ps *windowPartitions) stopTimers() {
+	for _, p := range ps.parts {
+		if p.timer != nil {
+			p.timer.Stop()
+		}
+	}
+}
+
+func (o *WindowOperator) isKeyed() bool {
+	return len(o.window.Keys) > 0 && o.window.Type != ast.NOT_WINDOW
+}
+
+func (o *WindowOperator) restorePartitions(ctx api.StreamContext) (*windowPartitions, error) {
+	ps := &windowPartitions{parts: make(map[string]*windowPartition)}
+	s, err := ctx.GetState(WindowKeysKey)
+	if err != nil || s == nil {
+		return ps, err
+	}
+	keys, ok := s.([]string)
+	if !ok {
+		return nil, fmt.Errorf("restore window state `keys` %v error, invalid type", s)
+	}
+	for _, key := range keys {
+		st, err := ctx.GetState(KeyedWindowStateKey + key)
+		if err != nil {
+			return nil, err
+		}
+		ks, ok := st.(KeyedWindowState)
+		if !ok {
+			return nil, fmt.Errorf("restore window state of key %s error, invalid type %T", key, st)
+		}
+		ps.parts[key] = &windowPartition{KeyedWindowState: &ks, key: key}
+	}
+	ctx.GetLogger().Infof("Restore keyed window state for %d keys", len(ps.parts))
+	return ps, nil
+}
+
+// partitionKey calculates the partition key by the group by dimensions
+func (o *WindowOperator) partitionKey(ctx api.StreamContext, d *xsql.Tuple) (string, error) {
+	fv, _ := xsql.NewFunctionValuersForOp(ctx)
+	ve := &xsql.ValuerEval{Valuer: xsql.MultiValuer(d, fv)}
+	var name string
+	for _, k := range o.window.Keys {
+		r := ve.Eval(k)
+		if e, ok := r.(error); ok {
+			return "", fmt.Errorf("run window partition key error: %v", e)
+		}
+		name += fmt.Sprintf("%v,", r)
+	}
+	return name, nil
+}
+
+// scanPartition scans the inputs of one partition with its own trigger time
+func (o *WindowOperator) scanPartition(ctx api.StreamContext, p *windowPartition, triggerTime int64) {
+	o.triggerTime = p.TriggerTime
+	p.Inputs = o.scan(p.Inputs, triggerTime, ctx)
+	p.TriggerTime = o.triggerTime
+}
+
+func (o *WindowOperator) resetSessionTimer(ctx api.StreamContext, p *windowPartition, timeoutCh chan<- partitionEvent) {
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	p.gen++
+	key, gen := p.key, p.gen
+	p.timer = conf.Clock.AfterFunc(time.Duration(o.window.Interval)*time.Millisecond, func() {
+		select {
+		case timeoutCh <- partitionEvent{key: key, gen: gen}:
+		case <-ctx.Done():
+		}
+	})
+}
+
+func (o *WindowOperator) execKeyedProcessingWindow(ctx api.StreamContext, parts *windowPartitions, errCh chan<- error) {
+	log := ctx.GetLogger()
+	var (
+		firstTicker *clock.Timer
+		firstTime   int64
+		nextTime    int64
+		period      int64
+		firstC      <-chan time.Time
+		c           <-chan time.Time
+	)
+	switch o.window.Type {
+	case ast.TUMBLING_WINDOW, ast.SESSION_WINDOW:
+		firstTime, firstTicker = getFirstTimer(ctx, o.window.RawInterval, o.window.TimeUnit)
+		o.interval = o.window.Interval
+		period = o.window.Length
+	case ast.HOPPING_WINDOW:
+		firstTime, firstTicker = getFirstTimer(ctx, o.window.RawInterval, o.window.TimeUnit)
+		o.interval = o.window.Interval
+		period = o.window.Interval
+	case ast.SLIDING_WINDOW:
+		o.interval = o.window.Length
+	case ast.COUNT_WINDOW:
+		o.interval = o.window.Interval
+	}
+	if firstTicker != nil {
+		firstC = firstTicker.C
+	}
+	lastTick := conf.GetNowInMilli()
+	timeoutCh := make(chan partitionEvent, 100)
+	delayCh := make(chan partitionEvent, 100)
+	if o.window.Type == ast.SESSION_WINDOW {
+		// Restored sessions are waiting for the timeout again
+		for _, p := range parts.parts {
+			o.resetSessionTimer(ctx, p, timeoutCh)
+		}
+	}
+	for {
+		select {
+		case dl := <-delayCh:
+			if p, ok := parts.get(dl.key); ok {
+				o.statManager.ProcessTimeStart()
+				o.scanPartition(ctx, p, dl.ts)
+				o.statManager.ProcessTimeEnd()
+				parts.save(ctx, p)
+			}
+		// process incoming item
+		case item, opened := <-o.input:
+			processed := false
+			if item, processed = o.preprocess(item); processed {
+				break
+			}
+			o.statManager.IncTotalRecordsIn()
+			o.statManager.ProcessTimeStart()
+			if !opened {
+				o.statManager.IncTotalExceptions("input channel closed")
+				break
+			}
+			switch d := item.(type) {
+			case error:
+				_ = o.Broadcast(d)
+				o.statManager.IncTotalExceptions(d.Error())
+			case *xsql.Tuple:
+				log.Debugf("Keyed window receive tuple %s", d.Message)
+				key, err := o.partitionKey(ctx, d)
+				o.traceTuple(ctx, d, err)
+				if err != nil {
+					_ = o.Broadcast(err)
+					o.statManager.IncTotalExceptions(err.Error())
+					break
+				}
+				p := parts.getOrCreate(ctx, key, lastTick)
+				p.Inputs = append(p.Inputs, d)
+				switch o.window.Type {
+				case ast.SLIDING_WINDOW:
+					if o.isMatchCondition(ctx, d) {
+						if o.window.Delay > 0 {
+							go func(key string, ts int64) {
+								select {
+								case <-time.After(time.Duration(o.window.Delay) * time.Millisecond):
+									delayCh <- partitionEvent{key: key, ts: ts}
+								case <-ctx.Done():
+								}
+							}(key, d.Timestamp+o.window.Delay)
+						} else {
+							o.scanPartition(ctx, p, d.Timestamp)
+						}
+					}
+				case ast.SESSION_WINDOW:
+					if p.timer == nil {
+						p.TriggerTime = d.Timestamp
+						log.Debugf("Session window of key %s set start time %d", key, p.TriggerTime)
+					}
+					o.resetSessionTimer(ctx, p, timeoutCh)
+				case ast.COUNT_WINDOW:
+					p.MsgCount++
+					if int64(p.MsgCount)%o.window.Interval == 0 {
+						p.MsgCount = 0
+						tl, er := NewTupleList(p.Inputs, int(o.window.Length))
+						if er != nil {
+							infra.DrainError(ctx, er, errCh)
+							return
+						}
+						triggerTime := conf.GetNowInMilli()
+						for tl.hasMoreCountWindow() {
+							tsets := tl.nextCountWindow()
+							tsets.WindowRange = xsql.NewWindowRange(p.TriggerTime, triggerTime)
+							log.Debugf("Sent: %v", tsets)
+							_ = o.Broadcast(tsets)
+							o.statManager.IncTotalRecordsOut()
+						}
+						p.TriggerTime = triggerTime
+						p.Inputs = tl.getRestTuples()
+					}
+				}
+				o.statManager.ProcessTimeEnd()
+				o.statManager.SetBufferLength(int64(len(o.input)))
+				parts.save(ctx, p)
+			default:
+				e := fmt.Errorf("run Window error: expect xsql.Tuple type but got %[1]T(%[1]v)", d)
+				_ = o.Broadcast(e)
+				o.statManager.IncTotalExceptions(e.Error())
+			}
+		case to := <-timeoutCh:
+			p, ok := parts.get(to.key)
+			if !ok || p.gen != to.gen {
+				break
+			}
+			if len(p.Inputs) > 0 {
+				o.statManager.ProcessTimeStart()
+				log.Debugf("Session window of key %s triggered by timeout", to.key)
+				o.scanPartition(ctx, p, conf.GetNowInMilli())
+				o.statManager.ProcessTimeEnd()
+			}
+			// The session is closed, a new session will start for the next event of the key
+			parts.remove(ctx, to.key)
+		case now := <-firstC:
+			log.Debugf("First tick at %v(%d), defined at %d", now, now.UnixMilli(), firstTime)
+			o.ticker = conf.GetTicker(period)
+			firstC = nil
+			c = o.ticker.C
+			o.tickPartitions(ctx, parts, firstTime)
+			lastTick = firstTime
+			nextTime = firstTime + period
+		case now := <-c:
+			log.Debugf("Successive tick at %v(%d)", now, now.UnixMilli())
+			o.tickPartitions(ctx, parts, nextTime)
+			lastTick = nextTime
+			nextTime += period
+		// is cancelling
+		case <-ctx.Done():
+			log.Infoln("Cancelling window....")
+			if o.ticker != nil {
+				o.ticker.Stop()
+			}
+			parts.stopTimers()
+			return
+		}
+	}
+}
+
+// tickPartitions triggers all the partitions of time window by the shared aligned ticker
+func (o *WindowOperator) tickPartitions(ctx api.StreamContext, parts *windowPartitions, n int64) {
+	o.statManager.ProcessTimeStart()
+	defer o.statManager.ProcessTimeEnd()
+	for _, key := range parts.sortedKeys() {
+		p := parts.parts[key]
+		switch o.window.Type {
+		case ast.SESSION_WINDOW:
+			if len(p.Inputs) == 0 || n-o.window.Length < p.Inputs[0].Timestamp {
+				continue
+			}
+			o.scanPartition(ctx, p, n)
+			parts.save(ctx, p)
+		default:
+			// A partition without inputs is idle, remove it to release the memory
+			if len(p.Inputs) == 0 {
+				parts.remove(ctx, key)
+				continue
+			}
+			o.scanPartition(ctx, p, n)
+			parts.save(ctx, p)
+		}
+	}
+}
+
+func (o *WindowOperator) execKeyedEventWindow(ctx api.StreamContext, parts *windowPartitions) {
+	log := ctx.GetLogger()
+	for {
+		select {
+		// process incoming item
+		case item, opened := <-o.input:
+			if !opened {
+				o.statManager.IncTotalExceptions("input channel closed")
+				break
+			}
+			processed := false
+			if item, processed = o.preprocess(item); processed {
+				break
+			}
+			switch d := item.(type) {
+			case error:
+				_ = o.Broadcast(d)
+				o.statManager.IncTotalExceptions(d.Error())
+			case *xsql.WatermarkTuple:
+				watermarkTs := d.GetTimestamp()
+				for _, key := range parts.sortedKeys() {
+					p := parts.parts[key]
+					o.advancePartition(ctx, p, watermarkTs)
+					if len(p.Inputs) == 0 && len(p.TriggerTS) == 0 && len(p.DelayTS) == 0 {
+						parts.remove(ctx, key)
+					} else {
+						parts.save(ctx, p)
+					}
+				}
+			case *xsql.Tuple:
+				o.statManager.ProcessTimeStart()
+				o.statManager.IncTotalRecordsIn()
+				log.Debugf("keyed event window receive tuple %s", d.Message)
+				key, err := o.partitionKey(ctx, d)
+				o.traceTuple(ctx, d, err)
+				if err != nil {
+					_ = o.Broadcast(err)
+					o.statManager.IncTotalExceptions(err.Error())
+					break
+				}
+				// first tuple of the key, set the window start time
+				p := parts.getOrCreate(ctx, key, d.Timestamp)
+				if o.window.Type == ast.SLIDING_WINDOW && o.isMatchCondition(ctx, d) {
+					p.TriggerTS = append(p.TriggerTS, d.GetTimestamp())
+				}
+				p.Inputs = append(p.Inputs, d)
+				o.statManager.ProcessTimeEnd()
+				parts.save(ctx, p)
+			default:
+				e := fmt.Errorf("run Window error: expect xsql.Event type but got %[1]T(%[1]v)", d)
+				_ = o.Broadcast(e)
+				o.statManager.IncTotalExceptions(e.Error())
+			}
+		// is cancelling
+		case <-ctx.Done():
+			log.Infoln("Cancelling window....")
+			return
+		}
+	}
+}
+
+// advancePartition triggers all the windows of the partition which end before the watermark
+func (o *WindowOperator) advancePartition(ctx api.StreamContext, p *windowPartition, watermarkTs int64) {
+	if o.window.Type == ast.SLIDING_WINDOW {
+		for len(p.DelayTS) > 0 && watermarkTs >= p.DelayTS[0] {
+			o.scanPartition(ctx, p, p.DelayTS[0])
+			p.DelayTS = p.DelayTS[1:]
+		}
+	}
+	windowEndTs := p.NextWindowEndTs
+	ticked := false
+	// Session window needs a recalculation of window because its window end depends on the inputs
+	if windowEndTs == math.MaxInt64 || o.window.Type == ast.SESSION_WINDOW || o.window.Type == ast.SLIDING_WINDOW {
+		windowEndTs, ticked = o.nextPartitionWindow(p, watermarkTs)
+	}
+	for windowEndTs <= watermarkTs && windowEndTs >= 0 {
+		if o.window.Type == ast.SESSION_WINDOW && !p.LastTicked {
+			p.TriggerTime = p.Inputs[0].Timestamp
+		}
+		if windowEndTs > 0 {
+			if o.window.Type == ast.SLIDING_WINDOW {
+				for len(p.TriggerTS) > 0 && p.TriggerTS[0] <= watermarkTs {
+					if o.window.Delay > 0 {
+						p.DelayTS = append(p.DelayTS, p.TriggerTS[0]+o.window.Delay)
+					} else {
+						o.scanPartition(ctx, p, p.TriggerTS[0])
+					}
+					p.TriggerTS = p.TriggerTS[1:]
+				}
+			} else {
+				o.scanPartition(ctx, p, windowEndTs)
+			}
+		}
+		p.PrevWindowEndTs = windowEndTs
+		p.LastTicked = ticked
+		windowEndTs, ticked = o.nextPartitionWindow(p, watermarkTs)
+	}
+	p.NextWindowEndTs = windowEndTs
+}
+
+func (o *WindowOperator) nextPartitionWindow(p *windowPartition, watermarkTs int64) (int64, bool) {
+	if o.window.Type == ast.SESSION_WINDOW {
+		return o.trigger.getNextSessionWindow(p.Inputs, watermarkTs)
+	}
+	return o.trigger.getNextWindow(p.Inputs, p.PrevWindowEndTs, watermarkTs), false
+}
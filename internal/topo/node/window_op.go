@@ -21,8 +21,10 @@ import (
 	"time"
 
 	"github.com/benbjohnson/clock"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/tracer"
 	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
 	"github.com/lf-edge/ekuiper/internal/xsql"
 	"github.com/lf-edge/ekuiper/pkg/api"
@@ -40,6 +42,14 @@ type WindowConfig struct {
 	Delay            int64
 	RawInterval      int
 	TimeUnit         ast.Token
+	// Keys partition the window by the group by dimensions, each key has its own window state and trigger
+	Keys []ast.Expr
+	// IncAggFuncs are the aggregate calls calculated incrementally. If set, the window accumulates the tuples into
+	// panes and emits the aggregation results grouped by the Dimensions instead of the tuples
+	IncAggFuncs []*ast.Call
+	Dimensions  ast.Dimensions
+	// IncCondition is the where condition to filter the tuples before accumulating them into the panes
+	IncCondition ast.Expr
 }
 
 type WindowOperator struct {
@@ -124,6 +134,68 @@ func (o *WindowOperator) Exec(ctx api.StreamContext, errCh chan<- error) {
 	}
 	o.statManager = stats
 	o.statManagers = []metric.StatManager{stats}
+	if len(o.window.IncAggFuncs) > 0 {
+		w, err := o.newIncAggWindow(ctx)
+		if err != nil {
+			infra.DrainError(ctx, err, errCh)
+			return
+		}
+		if !o.isEventTime {
+			o.triggerTime = conf.GetNowInMilli()
+		}
+		if s, err := ctx.GetState(TriggerTimeKey); err == nil && s != nil {
+			if si, ok := s.(int64); ok {
+				o.triggerTime = si
+			} else {
+				infra.DrainError(ctx, fmt.Errorf("restore window state `triggerTime` %v error, invalid type", s), errCh)
+				return
+			}
+		}
+		o.msgCount = 0
+		if s, err := ctx.GetState(MsgCountKey); err == nil && s != nil {
+			if si, ok := s.(int); ok {
+				o.msgCount = si
+			} else {
+				infra.DrainError(ctx, fmt.Errorf("restore window state `msgCount` %v error, invalid type", s), errCh)
+				return
+			}
+		}
+		go func() {
+			err := infra.SafeRun(func() error {
+				if o.isEventTime {
+					o.execIncAggEventWindow(ctx, w)
+				} else {
+					o.execIncAggWindow(ctx, w)
+				}
+				return nil
+			})
+			if err != nil {
+				infra.DrainError(ctx, err, errCh)
+			}
+		}()
+		return
+	}
+	if o.isKeyed() {
+		parts, err := o.restorePartitions(ctx)
+		if err != nil {
+			infra.DrainError(ctx, err, errCh)
+			return
+		}
+		go func() {
+			err := infra.SafeRun(func() error {
+				if o.isEventTime {
+					o.execKeyedEventWindow(ctx, parts)
+				} else {
+					o.execKeyedProcessingWindow(ctx, parts, errCh)
+				}
+				return nil
+			})
+			if err != nil {
+				infra.DrainError(ctx, err, errCh)
+			}
+		}()
+		return
+	}
 	var inputs []*xsql.Tuple
 	if s, err := ctx.GetState(WindowInputsKey); err == nil {
 		switch st := s.(type) {
@@ -338,6 +410,7 @@ func (o *WindowOperator) execProcessingWindow(ctx api.StreamContext, inputs []*x
 			case *xsql.Tuple:
 				log.Debugf("Event window receive tuple %s", d.Message)
 				inputs = append(inputs, d)
+				o.traceTuple(ctx, d, nil)
 				switch o.window.Type {
 				case ast.NOT_WINDOW:
 					inputs = o.scan(inputs, d.Timestamp, ctx)
@@ -637,6 +710,12 @@ func (o *WindowOperator) GetMetrics() [][]interface{} {
 	return o.defaultNode.GetMetrics()
 }
 
+// traceTuple records the span of a tuple received by the window. The tuple is merged into the window result, so its
+// trace ends here.
+func (o *WindowOperator) traceTuple(ctx api.StreamContext, d *xsql.Tuple, err error) {
+	tracer.EndSpan(tracer.StartSpan(ctx, d, trace.SpanKindInternal), err)
+}
+
 func (o *WindowOperator) isMatchCondition(ctx api.StreamContext, d *xsql.Tuple) bool {
 	if o.triggerCondition == nil || o.window.Type != ast.SLIDING_WINDOW {
 		return true
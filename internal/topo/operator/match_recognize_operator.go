@@ -0,0 +1,517 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"encoding/gob"
+	"fmt"
+	"sort"
+
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+const (
+	MatchRecognizeKeysKey  = "$$matchRecognizeKeys"
+	MatchRecognizeStateKey = "$$matchRecognize_"
+	// matchNavPrefix is the prefix of the cached field of the navigation functions like PREV
+	matchNavPrefix = "$$mr"
+)
+
+// MatchRun is a partial match which is a state of the NFA
+type MatchRun struct {
+	// Start is the sequence number of the first row in the partition
+	Start int64
+	// Index is the index of the current pattern term and Count is the number of rows mapped to it
+	Index int
+	Count int
+	Rows  []*xsql.Tuple
+	// Vars are the pattern variables which the rows are mapped to
+	Vars []string
+}
+
+// MatchPartition is the NFA state of a partition. It is saved into the checkpoint store separately for each key.
+type MatchPartition struct {
+	// History is the latest rows of the partition before the current row, which is used by PREV
+	History []*xsql.Tuple
+	Runs    []*MatchRun
+	// Seq is the sequence number of the next row
+	Seq int64
+	// Last is the timestamp of the last row
+	Last int64
+}
+
+func init() {
+	gob.Register(MatchPartition{})
+}
+
+// expire discards the runs which start before the ts. The history is discarded too if the partition has no row
+// since the ts, so that an idle partition can be removed.
+func (mp *MatchPartition) expire(ts int64) bool {
+	changed := false
+	// The runs may be shared with the saved state, so filter them into a new slice
+	runs := make([]*MatchRun, 0, len(mp.Runs))
+	for _, r := range mp.Runs {
+		if r.Rows[0].Timestamp >= ts {
+			runs = append(runs, r)
+		} else {
+			changed = true
+		}
+	}
+	mp.Runs = runs
+	if len(mp.History) > 0 && mp.Last < ts {
+		mp.History = nil
+		changed = true
+	}
+	return changed
+}
+
+// isIdle checks if the partition has no state to keep, so it can be removed
+func (mp *MatchPartition) isIdle() bool {
+	return len(mp.Runs) == 0 && len(mp.History) == 0
+}
+
+// matchNav is the navigation function call to access the value of the other rows
+type matchNav struct {
+	name string
+	arg  ast.Expr
+	// offset of PREV
+	offset int
+	// the pattern variable of FIRST and LAST, empty to navigate all rows of the match
+	variable string
+}
+
+// MatchRecognizeOp detects the pattern of the rows in each partition by NFA. The rows must arrive in time order,
+// which is guaranteed by the watermark node in event time mode. The partial matches are kept as the runs of the NFA.
+// Once a run matches the whole pattern, it emits a row with the measures and discards all the partial matches of
+// the partition so that the next match starts after the last row of the current match.
+type MatchRecognizeOp struct {
+	PartitionBy []ast.Expr
+	Measures    ast.Fields
+	Pattern     []*ast.PatternTerm
+	Defines     map[string]ast.Expr
+	// Within is the max time span in milliseconds from the first row to the last row of a match, 0 means no limit
+	Within int64
+
+	vars map[string]struct{}
+	navs map[string]*matchNav
+	// the number of previous rows kept for PREV
+	historyLen int
+	partitions map[string]*MatchPartition
+	// the timestamp of the last expiration of all the partitions
+	lastSweep int64
+}
+
+func NewMatchRecognizeOp(mr *ast.MatchRecognize, within int64) *MatchRecognizeOp {
+	p := &MatchRecognizeOp{
+		PartitionBy: mr.PartitionBy,
+		Measures:    mr.Measures,
+		Pattern:     mr.Pattern,
+		Defines:     make(map[string]ast.Expr, len(mr.Defines)),
+		Within:      within,
+		vars:        make(map[string]struct{}, len(mr.Pattern)),
+		navs:        make(map[string]*matchNav),
+	}
+	for _, t := range mr.Pattern {
+		p.vars[t.Var] = struct{}{}
+	}
+	exprs := make([]ast.Expr, 0, len(mr.Defines)+len(mr.Measures))
+	for _, d := range mr.Defines {
+		p.Defines[d.Var] = d.Condition
+		exprs = append(exprs, d.Condition)
+	}
+	for _, m := range mr.Measures {
+		exprs = append(exprs, m.Expr)
+	}
+	for _, e := range exprs {
+		ast.WalkFunc(e, func(n ast.Node) bool {
+			if c, ok := n.(*ast.Call); ok && xsql.IsMatchNavFunc(c.Name) {
+				p.addNav(c)
+				return false
+			}
+			return true
+		})
+	}
+	return p
+}
+
+// addNav caches the navigation call so that it is evaluated by the match valuer
+func (p *MatchRecognizeOp) addNav(c *ast.Call) {
+	nav := &matchNav{name: c.Name, arg: c.Args[0], offset: 1}
+	switch c.Name {
+	case "prev":
+		if len(c.Args) > 1 {
+			nav.offset = c.Args[1].(*ast.IntegerLiteral).Val
+		}
+		if nav.offset > p.historyLen {
+			p.historyLen = nav.offset
+		}
+	default:
+		ast.WalkFunc(nav.arg, func(n ast.Node) bool {
+			if f, ok := n.(*ast.FieldRef); ok {
+				if _, ok := p.vars[string(f.StreamName)]; ok {
+					nav.variable = string(f.StreamName)
+					return false
+				}
+			}
+			return true
+		})
+	}
+	c.CachedField = fmt.Sprintf("%s_%s_%d", matchNavPrefix, c.Name, c.FuncId)
+	c.Cached = true
+	p.navs[c.CachedField] = nav
+}
+
+// Apply
+/*
+ *  input: *xsql.Tuple
+ *  output: *xsql.Tuple of the measures when a match is found
+ */
+func (p *MatchRecognizeOp) Apply(ctx api.StreamContext, data interface{}, fv *xsql.FunctionValuer, _ *xsql.AggregateFunctionValuer) interface{} {
+	log := ctx.GetLogger()
+	log.Debugf("match recognize plan receive %v", data)
+	if p.partitions == nil {
+		if err := p.restore(ctx); err != nil {
+			return fmt.Errorf("run match recognize error: %s", err)
+		}
+	}
+	switch input := data.(type) {
+	case error:
+		return input
+	case *xsql.Tuple:
+		result, err := p.match(ctx, input, fv)
+		p.sweep(ctx, input.Timestamp)
+		if err != nil {
+			return fmt.Errorf("run match recognize error: %s", err)
+		}
+		if result == nil {
+			return nil
+		}
+		return result
+	default:
+		return fmt.Errorf("run match recognize error: invalid input %[1]T(%[1]v)", input)
+	}
+}
+
+func (p *MatchRecognizeOp) restore(ctx api.StreamContext) error {
+	p.partitions = make(map[string]*MatchPartition)
+	s, err := ctx.GetState(MatchRecognizeKeysKey)
+	if err != nil || s == nil {
+		return err
+	}
+	keys, ok := s.([]string)
+	if !ok {
+		return fmt.Errorf("restore match recognize state `keys` %v error, invalid type", s)
+	}
+	for _, key := range keys {
+		st, err := ctx.GetState(MatchRecognizeStateKey + key)
+		if err != nil {
+			return err
+		}
+		mp, ok := st.(MatchPartition)
+		if !ok {
+			return fmt.Errorf("restore match recognize state of key %s error, invalid type %T", key, st)
+		}
+		p.partitions[key] = &mp
+	}
+	ctx.GetLogger().Infof("Restore match recognize state for %d partitions", len(p.partitions))
+	return nil
+}
+
+func (p *MatchRecognizeOp) save(ctx api.StreamContext, key string, part *MatchPartition) {
+	_ = ctx.PutState(MatchRecognizeStateKey+key, *part)
+}
+
+func (p *MatchRecognizeOp) remove(ctx api.StreamContext, key string) {
+	delete(p.partitions, key)
+	_ = ctx.DeleteState(MatchRecognizeStateKey + key)
+}
+
+func (p *MatchRecognizeOp) saveKeys(ctx api.StreamContext) {
+	keys := make([]string, 0, len(p.partitions))
+	for k := range p.partitions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	_ = ctx.PutState(MatchRecognizeKeysKey, keys)
+}
+
+// sweep expires the runs and the history of all the partitions by WITHIN and removes the idle partitions. The rows
+// arrive in time order, so the expired runs of the partitions without new rows can never complete. It runs at most
+// once per WITHIN.
+func (p *MatchRecognizeOp) sweep(ctx api.StreamContext, now int64) {
+	if p.Within <= 0 || now-p.lastSweep < p.Within {
+		return
+	}
+	p.lastSweep = now
+	removed := false
+	for key, part := range p.partitions {
+		if !part.expire(now - p.Within) {
+			continue
+		}
+		if part.isIdle() {
+			p.remove(ctx, key)
+			removed = true
+		} else {
+			p.save(ctx, key, part)
+		}
+	}
+	if removed {
+		p.saveKeys(ctx)
+	}
+}
+
+// match feeds the row to the NFA of its partition and returns the result row if the pattern is matched
+func (p *MatchRecognizeOp) match(ctx api.StreamContext, row *xsql.Tuple, fv *xsql.FunctionValuer) (*xsql.Tuple, error) {
+	ve := &xsql.ValuerEval{Valuer: xsql.MultiValuer(row, fv)}
+	keys := make([]interface{}, len(p.PartitionBy))
+	var key string
+	for i, e := range p.PartitionBy {
+		r := ve.Eval(e)
+		if err, ok := r.(error); ok {
+			return nil, fmt.Errorf("evaluate PARTITION BY error: %v", err)
+		}
+		keys[i] = r
+		key += fmt.Sprintf("%v,", r)
+	}
+	part, ok := p.partitions[key]
+	if !ok {
+		part = &MatchPartition{}
+		p.partitions[key] = part
+	} else if p.Within > 0 {
+		part.expire(row.Timestamp - p.Within)
+	}
+	seq := part.Seq
+	part.Seq++
+	var (
+		runs    []*MatchRun
+		matched *MatchRun
+		// The runs of the same start at the same NFA state are duplicated
+		visited = make(map[[3]int64]struct{})
+	)
+	// The runs are in the order of the start, so the first complete run is the longest match
+	candidates := append(part.Runs, &MatchRun{Start: seq, Index: -1})
+loop:
+	for _, run := range candidates {
+		for _, next := range p.transitions(run) {
+			nr := run.extend(next, row, p.Pattern[next].Var)
+			k := [3]int64{nr.Start, int64(nr.Index), int64(nr.Count)}
+			if _, ok := visited[k]; ok {
+				continue
+			}
+			ok, err := p.define(nr, part.History, fv)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			visited[k] = struct{}{}
+			if p.isComplete(nr) {
+				matched = nr
+				break loop
+			}
+			runs = append(runs, nr)
+		}
+	}
+	var result *xsql.Tuple
+	if matched != nil {
+		msg, err := p.measure(matched, keys, part.History, fv)
+		if err != nil {
+			return nil, err
+		}
+		result = &xsql.Tuple{Emitter: row.Emitter, Message: msg, Timestamp: row.Timestamp, Metadata: row.Metadata}
+		runs = nil
+	}
+	part.Runs = runs
+	if p.historyLen > 0 {
+		part.History = append(part.History, row)
+		if len(part.History) > p.historyLen {
+			part.History = part.History[len(part.History)-p.historyLen:]
+		}
+	}
+	part.Last = row.Timestamp
+	// The partition without live runs or history is removed, and it is created again by its next row
+	if part.isIdle() {
+		p.remove(ctx, key)
+	} else {
+		p.save(ctx, key, part)
+	}
+	if ok == part.isIdle() {
+		p.saveKeys(ctx)
+	}
+	return result, nil
+}
+
+// transitions returns the index of the pattern terms which the next row can be mapped to
+func (p *MatchRecognizeOp) transitions(run *MatchRun) []int {
+	var next []int
+	if run.Index >= 0 {
+		t := p.Pattern[run.Index]
+		if t.Max < 0 || run.Count < t.Max {
+			next = append(next, run.Index)
+		}
+		if run.Count < t.Min {
+			return next
+		}
+	}
+	// The optional terms can be skipped
+	for i := run.Index + 1; i < len(p.Pattern); i++ {
+		next = append(next, i)
+		if p.Pattern[i].Min > 0 {
+			break
+		}
+	}
+	return next
+}
+
+// isComplete checks if the run has matched the whole pattern. The run completes as soon as possible, so the
+// quantifiers at the end of the pattern match as few rows as possible.
+func (p *MatchRecognizeOp) isComplete(run *MatchRun) bool {
+	if run.Count < p.Pattern[run.Index].Min {
+		return false
+	}
+	for i := run.Index + 1; i < len(p.Pattern); i++ {
+		if p.Pattern[i].Min > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// define evaluates the condition of the pattern variable which the last row of the run is mapped to
+func (p *MatchRecognizeOp) define(run *MatchRun, history []*xsql.Tuple, fv *xsql.FunctionValuer) (bool, error) {
+	v := run.Vars[len(run.Vars)-1]
+	cond, ok := p.Defines[v]
+	if !ok {
+		return true, nil
+	}
+	ve := &xsql.ValuerEval{Valuer: xsql.MultiValuer(&matchValuer{op: p, run: run, history: history, fv: fv}, fv)}
+	switch r := ve.Eval(cond).(type) {
+	case error:
+		return false, fmt.Errorf("evaluate DEFINE %s error: %v", v, r)
+	case bool:
+		return r, nil
+	case nil:
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid DEFINE %s condition that returns non-bool value %[2]T(%[2]v)", v, r)
+	}
+}
+
+// measure calculates the output row of the match with the partition fields and the measures
+func (p *MatchRecognizeOp) measure(run *MatchRun, keys []interface{}, history []*xsql.Tuple, fv *xsql.FunctionValuer) (xsql.Message, error) {
+	msg := make(xsql.Message, len(keys)+len(p.Measures))
+	for i, e := range p.PartitionBy {
+		if f, ok := e.(*ast.FieldRef); ok {
+			msg[f.Name] = keys[i]
+		}
+	}
+	ve := &xsql.ValuerEval{Valuer: xsql.MultiValuer(&matchValuer{op: p, run: run, history: history, fv: fv}, fv)}
+	for _, m := range p.Measures {
+		r := ve.Eval(m.Expr)
+		if err, ok := r.(error); ok {
+			return nil, fmt.Errorf("evaluate MEASURES %s error: %v", m.AName, err)
+		}
+		msg[m.AName] = r
+	}
+	return msg, nil
+}
+
+// extend returns a new run with the row mapped to the pattern term
+func (r *MatchRun) extend(index int, row *xsql.Tuple, v string) *MatchRun {
+	nr := &MatchRun{
+		Start: r.Start,
+		Index: index,
+		Count: 1,
+		Rows:  make([]*xsql.Tuple, len(r.Rows), len(r.Rows)+1),
+		Vars:  make([]string, len(r.Vars), len(r.Vars)+1),
+	}
+	if index == r.Index {
+		nr.Count = r.Count + 1
+	}
+	copy(nr.Rows, r.Rows)
+	copy(nr.Vars, r.Vars)
+	nr.Rows = append(nr.Rows, row)
+	nr.Vars = append(nr.Vars, v)
+	return nr
+}
+
+// matchValuer evaluates the expressions of MATCH_RECOGNIZE. The field without pattern variable refers to the last row
+// of the run, and the field with pattern variable like A.temperature refers to the last row mapped to A.
+type matchValuer struct {
+	op      *MatchRecognizeOp
+	run     *MatchRun
+	history []*xsql.Tuple
+	fv      *xsql.FunctionValuer
+}
+
+func (v *matchValuer) Value(key, table string) (interface{}, bool) {
+	if nav, ok := v.op.navs[key]; ok {
+		return v.navigate(nav), true
+	}
+	row := v.run.Rows[len(v.run.Rows)-1]
+	if _, ok := v.op.vars[table]; ok {
+		row = v.lastOf(table)
+		if row == nil {
+			return nil, false
+		}
+	}
+	return row.Value(key, table)
+}
+
+func (v *matchValuer) Meta(key, table string) (interface{}, bool) {
+	return v.run.Rows[len(v.run.Rows)-1].Meta(key, table)
+}
+
+func (v *matchValuer) lastOf(variable string) *xsql.Tuple {
+	for i := len(v.run.Vars) - 1; i >= 0; i-- {
+		if v.run.Vars[i] == variable {
+			return v.run.Rows[i]
+		}
+	}
+	return nil
+}
+
+// navigate evaluates the argument of the navigation function on the row it points to
+func (v *matchValuer) navigate(nav *matchNav) interface{} {
+	var row *xsql.Tuple
+	switch nav.name {
+	case "prev":
+		// The rows of a run are consecutive, so the previous row of the last row is in the history
+		if nav.offset <= len(v.history) {
+			row = v.history[len(v.history)-nav.offset]
+		}
+	case "first":
+		for i, r := range v.run.Rows {
+			if nav.variable == "" || v.run.Vars[i] == nav.variable {
+				row = r
+				break
+			}
+		}
+	case "last":
+		if nav.variable == "" {
+			row = v.run.Rows[len(v.run.Rows)-1]
+		} else {
+			row = v.lastOf(nav.variable)
+		}
+	}
+	if row == nil {
+		return nil
+	}
+	ve := &xsql.ValuerEval{Valuer: xsql.MultiValuer(row, v.fv)}
+	return ve.Eval(nav.arg)
+}
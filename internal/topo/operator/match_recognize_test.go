@@ -0,0 +1,209 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+func TestMatchRecognizeOp_Apply(t *testing.T) {
+	letters := func(ls string) []interface{} {
+		r := make([]interface{}, len(ls))
+		for i, l := range ls {
+			r[i] = &xsql.Tuple{Emitter: "src", Message: xsql.Message{"v": string(l), "n": i}, Timestamp: int64(i * 100)}
+		}
+		return r
+	}
+	tests := []struct {
+		sql    string
+		within int64
+		data   []interface{}
+		result []map[string]interface{}
+	}{
+		{ // quantifier in the middle
+			sql:  `SELECT * FROM src MATCH_RECOGNIZE (MEASURES FIRST(B.n) AS fb, LAST(B.n) AS lb, C.n AS c PATTERN (A B+ C) DEFINE A AS v = 'a', B AS v = 'b', C AS v = 'c')`,
+			data: letters("abbbcabc"),
+			result: []map[string]interface{}{
+				{"fb": 1, "lb": 3, "c": 4},
+				{"fb": 6, "lb": 6, "c": 7},
+			},
+		},
+		{ // skip the optional variable
+			sql:  `SELECT * FROM src MATCH_RECOGNIZE (MEASURES A.n AS a, LAST(B.n) AS b, C.n AS c PATTERN (A B? C) DEFINE A AS v = 'a', B AS v = 'b', C AS v = 'c')`,
+			data: letters("acabcabbc"),
+			result: []map[string]interface{}{
+				{"a": 0, "b": nil, "c": 1},
+				{"a": 2, "b": 3, "c": 4},
+			},
+		},
+		{ // the match starts from the earliest row
+			sql:  `SELECT * FROM src MATCH_RECOGNIZE (MEASURES FIRST(A.n) AS start, B.n AS last PATTERN (A+ B) DEFINE A AS v = 'a', B AS v = 'b')`,
+			data: letters("baaab"),
+			result: []map[string]interface{}{
+				{"start": 1, "last": 4},
+			},
+		},
+		{ // the next match starts after the last row of the previous match
+			sql:  `SELECT * FROM src MATCH_RECOGNIZE (MEASURES FIRST(n) AS start, LAST(n) AS last PATTERN (A{2}))`,
+			data: letters("aaaaa"),
+			result: []map[string]interface{}{
+				{"start": 0, "last": 1},
+				{"start": 2, "last": 3},
+			},
+		},
+		{ // the quantifier at the end matches as few rows as possible
+			sql:  `SELECT * FROM src MATCH_RECOGNIZE (MEASURES LAST(n) AS last PATTERN (A B{2,}) DEFINE A AS v = 'a', B AS v = 'b')`,
+			data: letters("abbbb"),
+			result: []map[string]interface{}{
+				{"last": 2},
+			},
+		},
+		{ // within
+			sql:    `SELECT * FROM src MATCH_RECOGNIZE (MEASURES A.n AS a, B.n AS b PATTERN (A C* B) DEFINE A AS v = 'a', B AS v = 'b')`,
+			within: 200,
+			data:   letters("acbaccbacb"),
+			result: []map[string]interface{}{
+				{"a": 0, "b": 2},
+				{"a": 7, "b": 9},
+			},
+		},
+		{ // prev with offset and partition
+			sql: `SELECT * FROM src MATCH_RECOGNIZE (PARTITION BY k MEASURES A.n AS n PATTERN (A) DEFINE A AS n - PREV(n, 2) = 4)`,
+			data: []interface{}{
+				&xsql.Tuple{Emitter: "src", Message: xsql.Message{"k": "x", "n": 1}},
+				&xsql.Tuple{Emitter: "src", Message: xsql.Message{"k": "y", "n": 1}},
+				&xsql.Tuple{Emitter: "src", Message: xsql.Message{"k": "x", "n": 3}},
+				&xsql.Tuple{Emitter: "src", Message: xsql.Message{"k": "x", "n": 5}},
+				&xsql.Tuple{Emitter: "src", Message: xsql.Message{"k": "y", "n": 5}},
+			},
+			result: []map[string]interface{}{
+				{"k": "x", "n": 5},
+			},
+		},
+		{ // the variable refers to the last row mapped to it
+			sql:  `SELECT * FROM src MATCH_RECOGNIZE (MEASURES A.n AS a, B.n AS b PATTERN (A B) DEFINE B AS n > A.n AND v = A.v)`,
+			data: letters("abba"),
+			result: []map[string]interface{}{
+				{"a": 1, "b": 2},
+			},
+		},
+	}
+	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
+	contextLogger := conf.Log.WithField("rule", "TestMatchRecognizeOp_Apply")
+	for i, tt := range tests {
+		stmt, err := xsql.NewParser(strings.NewReader(tt.sql)).Parse()
+		if err != nil {
+			t.Errorf("%d. parse error: %s", i, err)
+			continue
+		}
+		tempStore, _ := state.CreateStore("mockRule"+strconv.Itoa(i), api.AtMostOnce)
+		ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithMeta("mockRule"+strconv.Itoa(i), "project", tempStore)
+		pp := NewMatchRecognizeOp(stmt.MatchRecognize, tt.within)
+		fv, afv := xsql.NewFunctionValuersForOp(ctx)
+		var r []map[string]interface{}
+		for _, d := range tt.data {
+			switch opResult := pp.Apply(ctx, d, fv, afv).(type) {
+			case nil:
+			case *xsql.Tuple:
+				r = append(r, opResult.Message)
+			default:
+				t.Errorf("%d. unexpected result %v", i, opResult)
+			}
+		}
+		if !reflect.DeepEqual(tt.result, r) {
+			t.Errorf("%d.\n\nresult mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", i, tt.result, r)
+		}
+	}
+}
+
+func TestMatchRecognizeOp_Restore(t *testing.T) {
+	stmt, err := xsql.NewParser(strings.NewReader(`SELECT * FROM src MATCH_RECOGNIZE (MEASURES FIRST(A.n) AS start, B.n AS last PATTERN (A+ B) DEFINE A AS n > PREV(n), B AS n < PREV(n))`)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	contextLogger := conf.Log.WithField("rule", "TestMatchRecognizeOp_Restore")
+	tempStore, _ := state.CreateStore("mockRule0", api.AtMostOnce)
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithMeta("mockRule0", "project", tempStore)
+	fv, afv := xsql.NewFunctionValuersForOp(ctx)
+	pp := NewMatchRecognizeOp(stmt.MatchRecognize, 0)
+	for _, n := range []int{1, 2, 3} {
+		if r := pp.Apply(ctx, &xsql.Tuple{Emitter: "src", Message: xsql.Message{"n": n}}, fv, afv); r != nil {
+			t.Fatalf("unexpected result %v", r)
+		}
+	}
+	// The new operator continues the partial match from the state
+	pp = NewMatchRecognizeOp(stmt.MatchRecognize, 0)
+	r := pp.Apply(ctx, &xsql.Tuple{Emitter: "src", Message: xsql.Message{"n": 0}}, fv, afv)
+	exp := xsql.Message{"start": 2, "last": 0}
+	if tr, ok := r.(*xsql.Tuple); !ok || !reflect.DeepEqual(exp, tr.Message) {
+		t.Errorf("result mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", exp, r)
+	}
+}
+
+func TestMatchRecognizeOp_Expire(t *testing.T) {
+	stmt, err := xsql.NewParser(strings.NewReader(`SELECT * FROM src MATCH_RECOGNIZE (PARTITION BY k MEASURES A.n AS a, B.n AS b PATTERN (A B) DEFINE A AS v = 'a', B AS v = 'b' AND n > PREV(n))`)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	contextLogger := conf.Log.WithField("rule", "TestMatchRecognizeOp_Expire")
+	tempStore, _ := state.CreateStore("mockRule0", api.AtMostOnce)
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithMeta("mockRule0", "project", tempStore)
+	fv, afv := xsql.NewFunctionValuersForOp(ctx)
+	pp := NewMatchRecognizeOp(stmt.MatchRecognize, 200)
+	apply := func(k, v string, n int, ts int64) interface{} {
+		return pp.Apply(ctx, &xsql.Tuple{Emitter: "src", Message: xsql.Message{"k": k, "v": v, "n": n}, Timestamp: ts}, fv, afv)
+	}
+	for i := 0; i < 10; i++ {
+		if r := apply(strconv.Itoa(i), "a", i, int64(i*10)); r != nil {
+			t.Fatalf("unexpected result %v", r)
+		}
+	}
+	if len(pp.partitions) != 10 {
+		t.Fatalf("expect 10 partitions but got %d", len(pp.partitions))
+	}
+	keys, _ := ctx.GetState(MatchRecognizeKeysKey)
+	if len(keys.([]string)) != 10 {
+		t.Errorf("expect 10 saved keys but got %v", keys)
+	}
+	// The partitions without rows in WITHIN are expired
+	r := apply("9", "b", 100, 250)
+	exp := xsql.Message{"k": "9", "a": 9, "b": 100}
+	if tr, ok := r.(*xsql.Tuple); !ok || !reflect.DeepEqual(exp, tr.Message) {
+		t.Errorf("result mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", exp, r)
+	}
+	if len(pp.partitions) != 5 {
+		t.Errorf("expect 5 partitions after expiration but got %d", len(pp.partitions))
+	}
+	// The expired run and history is discarded
+	if r := apply("0", "b", 100, 260); r != nil {
+		t.Errorf("unexpected result %v", r)
+	}
+	if st, _ := ctx.GetState(MatchRecognizeStateKey + "0,"); st.(MatchPartition).Seq != 1 {
+		t.Errorf("expect the partition to be created again but got %v", st)
+	}
+	if st, _ := ctx.GetState(MatchRecognizeStateKey + "1,"); st != nil {
+		t.Errorf("expect the state of the expired partition to be deleted but got %v", st)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"strings"
 
 	"github.com/lf-edge/ekuiper/internal/binder/function"
+	"github.com/lf-edge/ekuiper/internal/pkg/namespace"
 	"github.com/lf-edge/ekuiper/internal/schema"
 	"github.com/lf-edge/ekuiper/internal/xsql"
 	"github.com/lf-edge/ekuiper/pkg/ast"
@@ -42,6 +43,8 @@ func decorateStmt(s *ast.SelectStatement, store kv.KeyValue) ([]*streamInfo, []*
 		if err != nil {
 			return nil, nil, nil, fmt.Errorf("fail to get stream %s, please check if stream is created", s)
 		}
+		// The conf key and schema are scoped to the namespace of the stream
+		namespace.QualifyOptions(namespace.OfKV(store), streamStmt.Options)
 		si, err := convertStreamInfo(streamStmt)
 		if err != nil {
 			return nil, nil, nil, err
@@ -71,6 +74,12 @@ func decorateStmt(s *ast.SelectStatement, store kv.KeyValue) ([]*streamInfo, []*
 				fieldsMap.reserve(field.Name, streamStmt.stmt.Name)
 			}
 		}
+		// The measures are the fields of the match results
+		if s.MatchRecognize != nil {
+			for _, m := range s.MatchRecognize.Measures {
+				fieldsMap.reserve(m.AName, dsn)
+			}
+		}
 	}
 	var (
 		walkErr            error
@@ -159,7 +168,7 @@ func decorateStmt(s *ast.SelectStatement, store kv.KeyValue) ([]*streamInfo, []*
 		case ast.Fields:
 			return false
 		case *ast.Call:
-			if function.IsAnalyticFunc(f.Name) {
+			if function.IsAnalyticCall(f) {
 				f.CachedField = fmt.Sprintf("%s_%s_%d", function.AnalyticPrefix, f.Name, f.FuncId)
 				f.Cached = true
 				analyticFuncs = append(analyticFuncs, &ast.Call{
@@ -185,7 +194,7 @@ func decorateStmt(s *ast.SelectStatement, store kv.KeyValue) ([]*streamInfo, []*
 		ast.WalkFunc(&field, func(n ast.Node) bool {
 			switch f := n.(type) {
 			case *ast.Call:
-				if function.IsAnalyticFunc(f.Name) {
+				if function.IsAnalyticCall(f) {
 					f.CachedField = fmt.Sprintf("%s_%s_%d", function.AnalyticPrefix, f.Name, f.FuncId)
 					f.Cached = true
 					calls = append([]*ast.Call{
@@ -0,0 +1,191 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+// IntervalJoinPlan joins two streams without window. A tuple joins the tuples of the other stream whose event time
+// is in the interval relative to its own event time, which is defined by a BETWEEN predicate in the join condition.
+type IntervalJoinPlan struct {
+	baseLogicalPlan
+	join ast.Join
+	// left is the stream of the from clause and right is the stream of the join clause
+	left  string
+	right string
+	// The event time of the right tuple minus the left tuple must be in [lower, upper]
+	lower int64
+	upper int64
+	// condition is the join condition without the time bound
+	condition ast.Expr
+}
+
+func (p IntervalJoinPlan) Init() *IntervalJoinPlan {
+	p.baseLogicalPlan.self = &p
+	return &p
+}
+
+func (p *IntervalJoinPlan) PushDownPredicate(condition ast.Expr) (ast.Expr, LogicalPlan) {
+	// The condition of outer join filters the joined result, so it cannot be pushed down
+	if p.join.JoinType != ast.INNER_JOIN {
+		return condition, p.self
+	}
+	multipleSourcesCondition, singleSourceCondition := extractCondition(condition)
+	rest, _ := p.baseLogicalPlan.PushDownPredicate(singleSourceCondition)
+	return combine(multipleSourcesCondition, rest), p.self
+}
+
+func (p *IntervalJoinPlan) PruneColumns(fields []ast.Expr) error {
+	f := getFields(p.join.Expr)
+	return p.baseLogicalPlan.PruneColumns(append(fields, f...))
+}
+
+// newIntervalJoinPlan creates the interval join plan if the join of two streams has a time bound like
+// `s2.ts BETWEEN s1.ts - 1000 AND s1.ts + 1000`. It returns nil if there is no such bound.
+func newIntervalJoinPlan(stmt *ast.SelectStatement, streamStmts []*streamInfo, opt *api.RuleOption) (*IntervalJoinPlan, error) {
+	if len(stmt.Joins) != 1 || len(stmt.Sources) != 1 || len(streamStmts) != 2 {
+		return nil, nil
+	}
+	from, ok := stmt.Sources[0].(*ast.Table)
+	if !ok {
+		return nil, nil
+	}
+	join := stmt.Joins[0]
+	// stream name or alias -> stream name
+	names := make(map[ast.StreamName]string, 4)
+	for _, t := range []struct{ name, alias string }{{from.Name, from.Alias}, {join.Name, join.Alias}} {
+		names[ast.StreamName(t.name)] = t.name
+		if t.alias != "" {
+			names[ast.StreamName(t.alias)] = t.name
+		}
+	}
+	timestamps := make(map[string]string, 2)
+	for _, s := range streamStmts {
+		if s.stmt.StreamType != ast.TypeStream {
+			return nil, nil
+		}
+		timestamps[string(s.stmt.Name)] = s.stmt.Options.TIMESTAMP
+	}
+	var (
+		bound     *ast.BinaryExpr
+		condition ast.Expr
+	)
+	for _, c := range splitAnd(join.Expr) {
+		if be, ok := c.(*ast.BinaryExpr); ok && bound == nil && isIntervalBound(be, names) {
+			bound = be
+			continue
+		}
+		condition = combine(condition, c)
+	}
+	if bound == nil {
+		return nil, nil
+	}
+	if join.JoinType == ast.CROSS_JOIN {
+		return nil, errors.New("interval join does not support cross join")
+	}
+	if !opt.IsEventTime {
+		return nil, errors.New("interval join requires event time, please set the rule option isEventTime to true")
+	}
+	target := bound.LHS.(*ast.FieldRef)
+	be := bound.RHS.(*ast.BetweenExpr)
+	ref, lower := splitOffset(be.Lower)
+	_, upper := splitOffset(be.Higher)
+	for _, f := range []*ast.FieldRef{target, ref} {
+		s := names[f.StreamName]
+		if f.Name != timestamps[s] {
+			return nil, fmt.Errorf("the time bound of interval join must be on the TIMESTAMP field of stream %s", s)
+		}
+	}
+	if lower > upper {
+		return nil, fmt.Errorf("invalid interval join bound, the lower bound %d is greater than the upper bound %d", lower, upper)
+	}
+	p := IntervalJoinPlan{
+		join:      join,
+		left:      from.Name,
+		right:     join.Name,
+		lower:     lower,
+		upper:     upper,
+		condition: condition,
+	}
+	// The bound is parsed as target in [ref + lower, ref + upper], reverse it if the target is the left stream
+	if names[target.StreamName] == from.Name {
+		p.lower, p.upper = -upper, -lower
+	}
+	return p.Init(), nil
+}
+
+// isIntervalBound checks if the expression is like `s2.ts BETWEEN s1.ts - 1000 AND s1.ts + 1000`
+func isIntervalBound(be *ast.BinaryExpr, names map[ast.StreamName]string) bool {
+	if be.OP != ast.BETWEEN {
+		return false
+	}
+	target, ok := be.LHS.(*ast.FieldRef)
+	if !ok || !target.IsColumn() {
+		return false
+	}
+	between, ok := be.RHS.(*ast.BetweenExpr)
+	if !ok {
+		return false
+	}
+	lf, _ := splitOffset(between.Lower)
+	hf, _ := splitOffset(between.Higher)
+	if lf == nil || hf == nil || lf.StreamName != hf.StreamName || lf.Name != hf.Name {
+		return false
+	}
+	ts, ok1 := names[target.StreamName]
+	rs, ok2 := names[lf.StreamName]
+	return ok1 && ok2 && ts != rs
+}
+
+// splitOffset splits the expression like `s1.ts + 1000` into the field and the offset
+func splitOffset(expr ast.Expr) (*ast.FieldRef, int64) {
+	switch e := expr.(type) {
+	case *ast.FieldRef:
+		if e.IsColumn() {
+			return e, 0
+		}
+	case *ast.BinaryExpr:
+		f, ok := e.LHS.(*ast.FieldRef)
+		if !ok || !f.IsColumn() {
+			return nil, 0
+		}
+		i, ok := e.RHS.(*ast.IntegerLiteral)
+		if !ok {
+			return nil, 0
+		}
+		switch e.OP {
+		case ast.ADD:
+			return f, int64(i.Val)
+		case ast.SUB:
+			return f, -int64(i.Val)
+		}
+	}
+	return nil, 0
+}
+
+func splitAnd(expr ast.Expr) []ast.Expr {
+	if be, ok := expr.(*ast.BinaryExpr); ok && be.OP == ast.AND {
+		return append(splitAnd(be.LHS), splitAnd(be.RHS)...)
+	}
+	if expr == nil {
+		return nil
+	}
+	return []ast.Expr{expr}
+}
@@ -0,0 +1,100 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestNewIntervalJoinPlan(t *testing.T) {
+	streams := []*streamInfo{
+		{stmt: &ast.StreamStmt{Name: "cmd", StreamType: ast.TypeStream, Options: &ast.Options{TIMESTAMP: "ts"}}},
+		{stmt: &ast.StreamStmt{Name: "ack", StreamType: ast.TypeStream, Options: &ast.Options{TIMESTAMP: "ats"}}},
+	}
+	tests := []struct {
+		sql          string
+		isEventTime  bool
+		nilPlan      bool
+		lower, upper int64
+		condition    string
+		err          string
+	}{
+		{
+			sql:         `SELECT * FROM cmd INNER JOIN ack ON cmd.id = ack.id AND ack.ats BETWEEN cmd.ts AND cmd.ts + 5000`,
+			isEventTime: true,
+			lower:       0,
+			upper:       5000,
+			condition:   "binaryExpr:{ cmd.id = ack.id }",
+		},
+		{
+			sql:         `SELECT * FROM cmd AS c LEFT JOIN ack AS a ON c.ts BETWEEN a.ats - 1000 AND a.ats + 2000`,
+			isEventTime: true,
+			lower:       -2000,
+			upper:       1000,
+		},
+		{
+			sql:         `SELECT * FROM cmd INNER JOIN ack ON cmd.id = ack.id`,
+			isEventTime: true,
+			nilPlan:     true,
+		},
+		{
+			sql:     `SELECT * FROM cmd INNER JOIN ack ON ack.ats BETWEEN cmd.ts AND cmd.ts + 5000`,
+			nilPlan: true,
+			err:     "interval join requires event time, please set the rule option isEventTime to true",
+		},
+		{
+			sql:         `SELECT * FROM cmd INNER JOIN ack ON ack.id BETWEEN cmd.ts AND cmd.ts + 5000`,
+			isEventTime: true,
+			nilPlan:     true,
+			err:         "the time bound of interval join must be on the TIMESTAMP field of stream ack",
+		},
+		{
+			sql:         `SELECT * FROM cmd INNER JOIN ack ON ack.ats BETWEEN cmd.ts + 10 AND cmd.ts - 10`,
+			isEventTime: true,
+			nilPlan:     true,
+			err:         "invalid interval join bound, the lower bound 10 is greater than the upper bound -10",
+		},
+	}
+	for _, tt := range tests {
+		stmt, err := xsql.NewParser(strings.NewReader(tt.sql)).Parse()
+		require.NoError(t, err)
+		p, err := newIntervalJoinPlan(stmt, streams, &api.RuleOption{IsEventTime: tt.isEventTime})
+		if tt.err != "" {
+			assert.EqualError(t, err, tt.err, tt.sql)
+		} else {
+			assert.NoError(t, err, tt.sql)
+		}
+		if tt.nilPlan {
+			assert.Nil(t, p, tt.sql)
+			continue
+		}
+		require.NotNil(t, p, tt.sql)
+		assert.Equal(t, tt.lower, p.lower, tt.sql)
+		assert.Equal(t, tt.upper, p.upper, tt.sql)
+		if tt.condition == "" {
+			assert.Nil(t, p.condition, tt.sql)
+		} else {
+			assert.Equal(t, tt.condition, p.condition.String(), tt.sql)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+// MatchRecognizePlan detects the pattern of the source stream. The plans after it process the match results
+// which only have the partition fields and the measures.
+type MatchRecognizePlan struct {
+	baseLogicalPlan
+	mr *ast.MatchRecognize
+	// within is the max time span of a match in milliseconds, 0 means no limit
+	within int64
+}
+
+func (p MatchRecognizePlan) Init() *MatchRecognizePlan {
+	p.baseLogicalPlan.self = &p
+	return &p
+}
+
+// PushDownPredicate the condition filters the match results, so it cannot be pushed down
+func (p *MatchRecognizePlan) PushDownPredicate(condition ast.Expr) (ast.Expr, LogicalPlan) {
+	return condition, p.self
+}
+
+// PruneColumns the fields of the later plans refer to the match results except the metadata of the last row
+func (p *MatchRecognizePlan) PruneColumns(fields []ast.Expr) error {
+	var fs []ast.Expr
+	for _, f := range fields {
+		if _, ok := f.(*ast.MetaRef); ok {
+			fs = append(fs, f)
+		}
+	}
+	vars := make(map[ast.StreamName]struct{}, len(p.mr.Pattern))
+	for _, t := range p.mr.Pattern {
+		vars[ast.StreamName(t.Var)] = struct{}{}
+	}
+	for _, e := range p.exprs() {
+		for _, f := range getFields(e) {
+			// The field of the pattern variable is the field of the source stream
+			if fr, ok := f.(*ast.FieldRef); ok {
+				if _, ok := vars[fr.StreamName]; ok {
+					f = &ast.FieldRef{StreamName: ast.DefaultStream, Name: fr.Name}
+				}
+			}
+			fs = append(fs, f)
+		}
+	}
+	return p.baseLogicalPlan.PruneColumns(fs)
+}
+
+func (p *MatchRecognizePlan) exprs() []ast.Expr {
+	result := make([]ast.Expr, 0, len(p.mr.PartitionBy)+len(p.mr.Measures)+len(p.mr.Defines))
+	result = append(result, p.mr.PartitionBy...)
+	for _, m := range p.mr.Measures {
+		result = append(result, m.Expr)
+	}
+	for _, d := range p.mr.Defines {
+		result = append(result, d.Condition)
+	}
+	return result
+}
+
+func newMatchRecognizePlan(stmt *ast.SelectStatement, streamStmts []*streamInfo) (*MatchRecognizePlan, error) {
+	mr := stmt.MatchRecognize
+	if len(stmt.Joins) > 0 {
+		return nil, errors.New("MATCH_RECOGNIZE does not support join")
+	}
+	if len(streamStmts) != 1 || streamStmts[0].stmt.StreamType != ast.TypeStream {
+		return nil, errors.New("MATCH_RECOGNIZE can only be applied to a stream")
+	}
+	names := map[ast.StreamName]struct{}{
+		ast.DefaultStream: {},
+		ast.StreamName(stmt.Sources[0].(*ast.Table).Name): {},
+	}
+	if alias := stmt.Sources[0].(*ast.Table).Alias; alias != "" {
+		names[ast.StreamName(alias)] = struct{}{}
+	}
+	for _, t := range mr.Pattern {
+		names[ast.StreamName(t.Var)] = struct{}{}
+	}
+	p := MatchRecognizePlan{mr: mr}
+	for _, e := range p.exprs() {
+		var err error
+		ast.WalkFunc(e, func(n ast.Node) bool {
+			if f, ok := n.(*ast.FieldRef); ok && f.IsColumn() {
+				if _, ok := names[f.StreamName]; !ok {
+					err = fmt.Errorf("unknown pattern variable %s", f.StreamName)
+					return false
+				}
+			}
+			return err == nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if mr.Within != nil {
+		p.within = convertFromUnit(mr.WithinUnit.Val, int64(mr.Within.Val))
+	}
+	return p.Init(), nil
+}
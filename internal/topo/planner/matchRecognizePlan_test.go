@@ -0,0 +1,73 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestNewMatchRecognizePlan(t *testing.T) {
+	stream := &streamInfo{stmt: &ast.StreamStmt{Name: "src", StreamType: ast.TypeStream, Options: &ast.Options{}}}
+	table := &streamInfo{stmt: &ast.StreamStmt{Name: "src", StreamType: ast.TypeTable, Options: &ast.Options{}}}
+	tests := []struct {
+		sql     string
+		streams []*streamInfo
+		within  int64
+		err     string
+	}{
+		{
+			sql:     `SELECT * FROM src MATCH_RECOGNIZE (MEASURES A.temp AS t PATTERN (A B) WITHIN 2 MI DEFINE B AS temp > A.temp)`,
+			streams: []*streamInfo{stream},
+			within:  120000,
+		},
+		{
+			sql:     `SELECT * FROM src AS s MATCH_RECOGNIZE (PARTITION BY s.id MEASURES src.temp AS t PATTERN (A))`,
+			streams: []*streamInfo{stream},
+		},
+		{
+			sql:     `SELECT * FROM src MATCH_RECOGNIZE (MEASURES C.temp AS t PATTERN (A B))`,
+			streams: []*streamInfo{stream},
+			err:     "unknown pattern variable C",
+		},
+		{
+			sql:     `SELECT * FROM src MATCH_RECOGNIZE (PATTERN (A))`,
+			streams: []*streamInfo{table},
+			err:     "MATCH_RECOGNIZE can only be applied to a stream",
+		},
+		{
+			sql:     `SELECT * FROM src MATCH_RECOGNIZE (PATTERN (A)) INNER JOIN src2 ON src.id = src2.id`,
+			streams: []*streamInfo{stream, stream},
+			err:     "MATCH_RECOGNIZE does not support join",
+		},
+	}
+	for _, tt := range tests {
+		stmt, err := xsql.NewParser(strings.NewReader(tt.sql)).Parse()
+		require.NoError(t, err, tt.sql)
+		p, err := newMatchRecognizePlan(stmt, tt.streams)
+		if tt.err != "" {
+			assert.EqualError(t, err, tt.err, tt.sql)
+			continue
+		}
+		require.NoError(t, err, tt.sql)
+		assert.Equal(t, tt.within, p.within, tt.sql)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/namespace"
 	store2 "github.com/lf-edge/ekuiper/internal/pkg/store"
 	"github.com/lf-edge/ekuiper/internal/topo"
 	"github.com/lf-edge/ekuiper/internal/topo/node"
@@ -58,6 +59,8 @@ func PlanSQLWithSourcesAndSinks(rule *api.Rule, sources []*node.SourceNode, sink
 	if err != nil {
 		return nil, err
 	}
+	// The streams are resolved in the namespace of the rule
+	store = namespace.KV(store, namespace.Of(rule.Id))
 	// Create the logical plan and optimize. Logical plans are a linked list
 	lp, err := createLogicalPlan(stmt, rule.Options, store)
 	if err != nil {
@@ -129,6 +132,8 @@ func buildOps(lp LogicalPlan, tp *topo.Topo, options *api.RuleOption, sources []
 		op = srcNode
 	case *WatermarkPlan:
 		op = node.NewWatermarkOp(fmt.Sprintf("%d_watermark", newIndex), t.SendWatermark, t.Emitters, options)
+	case *MatchRecognizePlan:
+		op = Transform(operator.NewMatchRecognizeOp(t.mr, t.within), fmt.Sprintf("%d_match_recognize", newIndex), options)
 	case *AnalyticFuncsPlan:
 		op = Transform(&operator.AnalyticFuncsOp{Funcs: t.funcs, FieldFuncs: t.fieldFuncs}, fmt.Sprintf("%d_analytic", newIndex), options)
 	case *WindowPlan:
@@ -156,6 +161,10 @@ func buildOps(lp LogicalPlan, tp *topo.Topo, options *api.RuleOption, sources []
 			TimeUnit:         t.timeUnit,
 			TriggerCondition: t.triggerCondition,
 			StateFuncs:       t.stateFuncs,
+			Keys:             t.keys,
+			IncAggFuncs:      t.incAggFuncs,
+			Dimensions:       t.dimensions,
+			IncCondition:     t.incCondition,
 		}, options)
 		if err != nil {
 			return nil, 0, err
@@ -166,6 +175,8 @@ func buildOps(lp LogicalPlan, tp *topo.Topo, options *api.RuleOption, sources []
 		op, err = node.NewJoinAlignNode(fmt.Sprintf("%d_join_aligner", newIndex), t.Emitters, options)
 	case *JoinPlan:
 		op = Transform(&operator.JoinOp{Joins: t.joins, From: t.from}, fmt.Sprintf("%d_join", newIndex), options)
+	case *IntervalJoinPlan:
+		op = node.NewIntervalJoinNode(fmt.Sprintf("%d_interval_join", newIndex), t.left, t.right, t.join.JoinType, t.condition, t.lower, t.upper, options)
 	case *FilterPlan:
 		t.ExtractStateFunc()
 		op = Transform(&operator.FilterOp{Condition: t.condition, StateFuncs: t.stateFuncs}, fmt.Sprintf("%d_filter", newIndex), options)
@@ -303,6 +314,7 @@ func createLogicalPlan(stmt *ast.SelectStatement, opt *api.RuleOption, store kv.
 		streamEmitters      []string
 		w                   *ast.Window
 		ds                  ast.Dimensions
+		incAgg              bool
 	)
 
 	streamStmts, analyticFuncs, analyticFieldFuncs, err := decorateStmt(stmt, store)
@@ -335,14 +347,29 @@ func createLogicalPlan(stmt *ast.SelectStatement, opt *api.RuleOption, store kv.
 		}
 	}
 	hasWindow := dimensions != nil && dimensions.GetWindow() != nil
+	var intervalJoin *IntervalJoinPlan
+	if stmt.Joins != nil && !hasWindow && len(lookupTableChildren) == 0 && len(scanTableChildren) == 0 {
+		intervalJoin, err = newIntervalJoinPlan(stmt, streamStmts, opt)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if opt.IsEventTime {
 		p = WatermarkPlan{
-			SendWatermark: hasWindow,
+			SendWatermark: hasWindow || intervalJoin != nil,
 			Emitters:      streamEmitters,
 		}.Init()
 		p.SetChildren(children)
 		children = []LogicalPlan{p}
 	}
+	if stmt.MatchRecognize != nil {
+		p, err = newMatchRecognizePlan(stmt, streamStmts)
+		if err != nil {
+			return nil, err
+		}
+		p.SetChildren(children)
+		children = []LogicalPlan{p}
+	}
 	if len(analyticFuncs) > 0 || len(analyticFieldFuncs) > 0 {
 		p = AnalyticFuncsPlan{
 			funcs:      analyticFuncs,
@@ -380,14 +407,36 @@ func createLogicalPlan(stmt *ast.SelectStatement, opt *api.RuleOption, store kv.
 			if w.TriggerCondition != nil {
 				wp.triggerCondition = w.TriggerCondition
 			}
+			if opt.KeyedWindow && w.WindowType != ast.NOT_WINDOW {
+				if len(streamEmitters) > 1 {
+					return nil, errors.New("keyedWindow option does not support window of multiple streams")
+				}
+				for _, d := range dimensions.GetGroups() {
+					wp.keys = append(wp.keys, d.Expr)
+				}
+			}
 			// TODO calculate limit
-			// TODO incremental aggregate
+			// The window aggregates incrementally by panes if all the aggregate functions support
+			if xsql.WithAggFields(stmt) && incAggCalls(stmt) != nil {
+				reason := incAggFallbackReason(w.WindowType, opt.IsEventTime, len(wp.keys) > 0, len(streamEmitters) > 1 || stmt.Joins != nil, len(analyticFuncs) > 0 || len(analyticFieldFuncs) > 0)
+				if reason == "" {
+					wp.incAggFuncs = extractIncAggFuncs(stmt)
+					wp.dimensions = dimensions.GetGroups()
+					incAgg = true
+				} else {
+					conf.Log.Warnf("The aggregate functions cannot be calculated incrementally by panes because %s, all the events in the window are buffered", reason)
+				}
+			}
 			wp.SetChildren(children)
 			children = []LogicalPlan{wp}
 			p = wp
 		}
 	}
-	if stmt.Joins != nil {
+	if intervalJoin != nil {
+		intervalJoin.SetChildren(children)
+		children = []LogicalPlan{intervalJoin}
+		p = intervalJoin
+	} else if stmt.Joins != nil {
 		if len(lookupTableChildren) == 0 && len(scanTableChildren) == 0 && w == nil {
 			return nil, errors.New("a time window or count window is required to join multiple streams")
 		}
@@ -441,7 +490,8 @@ func createLogicalPlan(stmt *ast.SelectStatement, opt *api.RuleOption, store kv.
 	}
 	if dimensions != nil {
 		ds = dimensions.GetGroups()
-		if ds != nil && len(ds) > 0 {
+		// The group by is done by the window if aggregating incrementally
+		if ds != nil && len(ds) > 0 && !incAgg {
 			p = AggregatePlan{
 				dimensions: ds,
 			}.Init()
@@ -15,6 +15,9 @@
 package planner
 
 import (
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/internal/binder/function"
 	"github.com/lf-edge/ekuiper/internal/xsql"
 	"github.com/lf-edge/ekuiper/pkg/ast"
 )
@@ -30,6 +33,13 @@ type WindowPlan struct {
 	timeUnit         ast.Token
 	limit            int // If limit is not positive, there will be no limit
 	isEventTime      bool
+	// keys partition the window state by the group by dimensions when keyedWindow option is set
+	keys []ast.Expr
+	// incAggFuncs are calculated incrementally in the window, and the window does the group by of the dimensions
+	incAggFuncs []*ast.Call
+	dimensions  ast.Dimensions
+	// incCondition is the where condition which cannot be pushed down and is evaluated by the incremental window
+	incCondition ast.Expr
 
 	stateFuncs []*ast.Call
 }
@@ -40,6 +50,12 @@ func (p WindowPlan) Init() *WindowPlan {
 }
 
 func (p *WindowPlan) PushDownPredicate(condition ast.Expr) (ast.Expr, LogicalPlan) {
+	if len(p.incAggFuncs) > 0 && (p.wtype == ast.COUNT_WINDOW || p.isEventTime) {
+		// The panes cannot be filtered after the window, so the incremental window filters the tuples itself before
+		// accumulating. The count window still counts the filtered tuples and the watermarks are not filtered.
+		p.incCondition = combine(condition, p.incCondition)
+		return nil, p
+	}
 	// not time window depends on the event, so should not filter any
 	if p.wtype == ast.COUNT_WINDOW || p.wtype == ast.SLIDING_WINDOW {
 		return condition, p
@@ -61,6 +77,7 @@ func (p *WindowPlan) PushDownPredicate(condition ast.Expr) (ast.Expr, LogicalPla
 
 func (p *WindowPlan) PruneColumns(fields []ast.Expr) error {
 	f := getFields(p.condition)
+	f = append(f, getFields(p.incCondition)...)
 	f = append(f, getFields(p.triggerCondition)...)
 	return p.baseLogicalPlan.PruneColumns(append(fields, f...))
 }
@@ -99,3 +116,102 @@ func (p *WindowPlan) transform(f *ast.Call) {
 		})
 	}
 }
+
+// extractIncAggFuncs returns all the aggregate calls of the statement if all of them can be calculated incrementally.
+// The calls are cached so that they are evaluated by the value calculated in the window.
+func extractIncAggFuncs(stmt *ast.SelectStatement) []*ast.Call {
+	calls := incAggCalls(stmt)
+	for _, c := range calls {
+		c.Cached = true
+		c.CachedField = fmt.Sprintf("%s_%s_%d", function.IncAggPrefix, c.Name, c.FuncId)
+	}
+	return calls
+}
+
+// incAggCalls returns all the aggregate calls of the statement if all of them can be calculated incrementally
+func incAggCalls(stmt *ast.SelectStatement) []*ast.Call {
+	var (
+		calls   []*ast.Call
+		valid   = true
+		visited = make(map[*ast.Call]struct{})
+		walk    func(n ast.Node) bool
+	)
+	walk = func(n ast.Node) bool {
+		if !valid {
+			return false
+		}
+		switch f := n.(type) {
+		case *ast.FieldRef:
+			if f.IsAlias() {
+				ast.WalkFunc(f.Expression, walk)
+			}
+		case *ast.Call:
+			switch f.FuncType {
+			case ast.FuncTypeAgg:
+				if _, ok := visited[f]; ok {
+					return false
+				}
+				if !isIncAggCall(f) {
+					valid = false
+					return false
+				}
+				visited[f] = struct{}{}
+				calls = append(calls, f)
+				return false
+			case ast.FuncTypeWindow:
+				valid = false
+				return false
+			}
+		}
+		return true
+	}
+	ast.WalkFunc(stmt.Fields, walk)
+	if stmt.Having != nil {
+		ast.WalkFunc(stmt.Having, walk)
+	}
+	if stmt.SortFields != nil {
+		ast.WalkFunc(stmt.SortFields, walk)
+	}
+	if !valid || len(calls) == 0 {
+		return nil
+	}
+	return calls
+}
+
+// incAggFallbackReason returns why the window cannot aggregate incrementally by panes, or empty if it can
+func incAggFallbackReason(wt ast.WindowType, isEventTime bool, keyed bool, multiStreams bool, analytic bool) string {
+	switch {
+	case keyed:
+		return "the window is a keyed window"
+	case multiStreams:
+		return "the window joins multiple streams"
+	case analytic:
+		return "the rule has analytic functions"
+	}
+	switch wt {
+	case ast.TUMBLING_WINDOW, ast.HOPPING_WINDOW:
+		return ""
+	case ast.COUNT_WINDOW, ast.SESSION_WINDOW:
+		if isEventTime {
+			// The event time session window is decided by the gaps of the event timestamps which are not kept by the panes
+			return fmt.Sprintf("the event time window type %s is not supported", wt)
+		}
+		return ""
+	case ast.SLIDING_WINDOW:
+		// Each sliding window is triggered by an event and ends at its timestamp, so the windows do not consist of
+		// fixed panes and every event must be kept until it expires
+		return "the sliding window is triggered by each event"
+	default:
+		return fmt.Sprintf("the window type %s is not supported", wt)
+	}
+}
+
+func isIncAggCall(c *ast.Call) bool {
+	if c.Partition != nil || c.WhenExpr != nil {
+		return false
+	}
+	if _, ok := function.BuiltinIncAggFunc(c.Name); ok {
+		return true
+	}
+	return function.IsIncAggFunc(c.Name)
+}
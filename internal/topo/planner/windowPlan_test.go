@@ -0,0 +1,160 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/pkg/store"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestExtractIncAggFuncs(t *testing.T) {
+	tests := []struct {
+		sql    string
+		cached []string
+	}{
+		{
+			sql:    `SELECT color, count(*) AS c, avg(size) FROM demo GROUP BY color, TUMBLINGWINDOW(ss, 10) HAVING c > 1 ORDER BY max(size)`,
+			cached: []string{"$$ia_count_0", "$$ia_avg_1", "$$ia_max_2"},
+		},
+		{
+			sql:    `SELECT sum(size) + stddev(size) AS s FROM demo GROUP BY HOPPINGWINDOW(ss, 10, 5) HAVING sum(size) > 10`,
+			cached: []string{"$$ia_sum_0", "$$ia_stddev_1", "$$ia_sum_2"},
+		},
+		{
+			sql: `SELECT count(*), collect(size) FROM demo GROUP BY TUMBLINGWINDOW(ss, 10)`,
+		},
+		{
+			sql:    `SELECT count(*), window_start() FROM demo GROUP BY TUMBLINGWINDOW(ss, 10)`,
+			cached: []string{"$$ia_count_0"},
+		},
+		{
+			sql: `SELECT color FROM demo GROUP BY TUMBLINGWINDOW(ss, 10)`,
+		},
+	}
+	for _, tt := range tests {
+		stmt, err := xsql.NewParser(strings.NewReader(tt.sql)).Parse()
+		require.NoError(t, err)
+		calls := extractIncAggFuncs(stmt)
+		var cached []string
+		for _, c := range calls {
+			assert.True(t, c.Cached)
+			cached = append(cached, c.CachedField)
+		}
+		assert.Equal(t, tt.cached, cached, tt.sql)
+	}
+}
+
+func TestIncAggWindowPlan(t *testing.T) {
+	kv, err := store.GetKV("stream")
+	require.NoError(t, err)
+	si, err := json.Marshal(&xsql.StreamInfo{
+		StreamType: ast.TypeStream,
+		Statement:  `CREATE STREAM incDemo (color STRING, size BIGINT, ts BIGINT) WITH (DATASOURCE="incDemo", FORMAT="json", TIMESTAMP="ts");`,
+	})
+	require.NoError(t, err)
+	require.NoError(t, kv.Set("incDemo", string(si)))
+	tests := []struct {
+		sql         string
+		isEventTime bool
+		keyed       bool
+		inc         bool
+	}{
+		{
+			sql: `SELECT color, avg(size) FROM incDemo GROUP BY color, TUMBLINGWINDOW(ss, 10)`,
+			inc: true,
+		},
+		{
+			sql:         `SELECT color, avg(size) FROM incDemo GROUP BY color, TUMBLINGWINDOW(ss, 10)`,
+			isEventTime: true,
+			inc:         true,
+		},
+		{
+			sql:         `SELECT max(size) FROM incDemo GROUP BY HOPPINGWINDOW(ss, 10, 5)`,
+			isEventTime: true,
+			inc:         true,
+		},
+		{
+			sql: `SELECT color, sum(size) FROM incDemo GROUP BY color, COUNTWINDOW(10, 4)`,
+			inc: true,
+		},
+		{
+			sql: `SELECT min(size) FROM incDemo GROUP BY SESSIONWINDOW(ss, 10, 2)`,
+			inc: true,
+		},
+		{
+			sql:         `SELECT min(size) FROM incDemo GROUP BY SESSIONWINDOW(ss, 10, 2)`,
+			isEventTime: true,
+		},
+		{
+			sql: `SELECT count(*) FROM incDemo GROUP BY SLIDINGWINDOW(ss, 10)`,
+		},
+		{
+			sql:         `SELECT count(*) FROM incDemo GROUP BY SLIDINGWINDOW(ss, 10)`,
+			isEventTime: true,
+		},
+		{
+			sql:   `SELECT color, count(*) FROM incDemo GROUP BY color, TUMBLINGWINDOW(ss, 10)`,
+			keyed: true,
+		},
+		{
+			sql: `SELECT count(*), collect(size) FROM incDemo GROUP BY TUMBLINGWINDOW(ss, 10)`,
+		},
+	}
+	for _, tt := range tests {
+		stmt, err := xsql.NewParser(strings.NewReader(tt.sql)).Parse()
+		require.NoError(t, err)
+		p, err := createLogicalPlan(stmt, &api.RuleOption{IsEventTime: tt.isEventTime, KeyedWindow: tt.keyed}, kv)
+		require.NoError(t, err, tt.sql)
+		wp := findWindowPlan(p)
+		require.NotNil(t, wp, tt.sql)
+		assert.Equal(t, tt.inc, len(wp.incAggFuncs) > 0, tt.sql)
+		for _, c := range incAggCalls(stmt) {
+			assert.Equal(t, tt.inc, c.Cached, tt.sql)
+		}
+	}
+}
+
+func TestIncAggFallbackReason(t *testing.T) {
+	assert.Equal(t, "", incAggFallbackReason(ast.TUMBLING_WINDOW, false, false, false, false))
+	assert.Equal(t, "", incAggFallbackReason(ast.HOPPING_WINDOW, true, false, false, false))
+	assert.Equal(t, "", incAggFallbackReason(ast.COUNT_WINDOW, false, false, false, false))
+	assert.Equal(t, "", incAggFallbackReason(ast.SESSION_WINDOW, false, false, false, false))
+	assert.Equal(t, "the event time window type SESSION_WINDOW is not supported", incAggFallbackReason(ast.SESSION_WINDOW, true, false, false, false))
+	assert.Equal(t, "the sliding window is triggered by each event", incAggFallbackReason(ast.SLIDING_WINDOW, false, false, false, false))
+	assert.Equal(t, "the window is a keyed window", incAggFallbackReason(ast.TUMBLING_WINDOW, false, true, false, false))
+	assert.Equal(t, "the window joins multiple streams", incAggFallbackReason(ast.TUMBLING_WINDOW, false, false, true, false))
+	assert.Equal(t, "the rule has analytic functions", incAggFallbackReason(ast.HOPPING_WINDOW, false, false, false, true))
+}
+
+func findWindowPlan(p LogicalPlan) *WindowPlan {
+	if wp, ok := p.(*WindowPlan); ok {
+		return wp
+	}
+	for _, c := range p.Children() {
+		if wp := findWindowPlan(c); wp != nil {
+			return wp
+		}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+)
+
+// The types of the rule lifecycle events
+const (
+	// EventStarted is emitted when the topology of the rule starts to run
+	EventStarted = "started"
+	// EventStopped is emitted when the rule is stopped manually
+	EventStopped = "stopped"
+	// EventStoppedByError is emitted when the rule stops for an error and the restart attempts run out
+	EventStoppedByError = "stoppedByError"
+	// EventRestarted is emitted when the rule restarts for an error by the restart strategy
+	EventRestarted = "restarted"
+	// EventScheduleEntered is emitted when a schedule rule starts in its schedule window
+	EventScheduleEntered = "scheduleEntered"
+	// EventScheduleLeft is emitted when a schedule rule stops as its schedule window ends
+	EventScheduleLeft = "scheduleLeft"
+)
+
+// Event is a state transition of a rule
+type Event struct {
+	RuleId string `json:"ruleId"`
+	Type   string `json:"type"`
+	// Message is the error which causes the transition if any
+	Message string `json:"message,omitempty"`
+	// Attempt is the restart attempt count of the restarted event
+	Attempt   int   `json:"attempt,omitempty"`
+	Timestamp int64 `json:"timestamp"`
+}
+
+func (e *Event) toMap() map[string]interface{} {
+	m := map[string]interface{}{
+		"ruleId":    e.RuleId,
+		"type":      e.Type,
+		"timestamp": e.Timestamp,
+	}
+	if e.Message != "" {
+		m["message"] = e.Message
+	}
+	if e.Attempt > 0 {
+		m["attempt"] = e.Attempt
+	}
+	return m
+}
+
+// EventSubscriber receives the rule events. The events are delivered one by one in a separated goroutine for each
+// subscriber so that a slow subscriber will not block the rules or the other subscribers.
+type EventSubscriber interface {
+	Notify(e *Event) error
+	Close() error
+}
+
+// EventFilter selects the events to deliver. An empty list matches all.
+type EventFilter struct {
+	Types []string
+	Rules []string
+}
+
+func (f *EventFilter) match(e *Event) bool {
+	return contains(f.Types, e.Type) && contains(f.Rules, e.RuleId)
+}
+
+func contains(list []string, v string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+type subscription struct {
+	subscriber EventSubscriber
+	filter     EventFilter
+	ch         chan *Event
+	done       chan struct{}
+}
+
+func (s *subscription) run(id string) {
+	defer close(s.done)
+	for e := range s.ch {
+		if err := s.subscriber.Notify(e); err != nil {
+			conf.Log.Warnf("rule event subscriber %s fails to handle event %s of rule %s: %v", id, e.Type, e.RuleId, err)
+		}
+	}
+	if err := s.subscriber.Close(); err != nil {
+		conf.Log.Warnf("rule event subscriber %s fails to close: %v", id, err)
+	}
+}
+
+type eventBus struct {
+	sync.RWMutex
+	subscriptions map[string]*subscription
+}
+
+var bus = &eventBus{subscriptions: make(map[string]*subscription)}
+
+// Subscribe adds a subscriber of the rule events with the id. The events are dropped if more than bufferLength events
+// are waiting for the subscriber.
+func Subscribe(id string, subscriber EventSubscriber, filter EventFilter, bufferLength int) error {
+	bus.Lock()
+	defer bus.Unlock()
+	if _, ok := bus.subscriptions[id]; ok {
+		return fmt.Errorf("rule event subscriber %s already exists", id)
+	}
+	if bufferLength <= 0 {
+		bufferLength = 1024
+	}
+	s := &subscription{
+		subscriber: subscriber,
+		filter:     filter,
+		ch:         make(chan *Event, bufferLength),
+		done:       make(chan struct{}),
+	}
+	bus.subscriptions[id] = s
+	go s.run(id)
+	return nil
+}
+
+// Unsubscribe removes the subscriber and waits for the buffered events to be handled
+func Unsubscribe(id string) {
+	bus.Lock()
+	s, ok := bus.subscriptions[id]
+	delete(bus.subscriptions, id)
+	bus.Unlock()
+	if ok {
+		close(s.ch)
+		<-s.done
+	}
+}
+
+// InitEventBus subscribes the sinks defined in the configuration to the rule events
+func InitEventBus(c *conf.RuleEventConf) {
+	if c == nil {
+		return
+	}
+	for i, sc := range c.Subscribers {
+		if sc == nil {
+			continue
+		}
+		id := fmt.Sprintf("%s_%d", sc.Type, i)
+		sub, err := newSinkSubscriber(id, sc.Type, sc.Props)
+		if err != nil {
+			conf.Log.Errorf("fail to create rule event subscriber %s: %v", id, err)
+			continue
+		}
+		if err := Subscribe(id, sub, EventFilter{Types: sc.Events, Rules: sc.Rules}, c.BufferLength); err != nil {
+			conf.Log.Errorf("fail to subscribe rule event subscriber %s: %v", id, err)
+		} else {
+			conf.Log.Infof("rule event subscriber %s is subscribed", id)
+		}
+	}
+}
+
+// CloseEventBus removes all the subscribers
+func CloseEventBus() {
+	bus.RLock()
+	ids := make([]string, 0, len(bus.subscriptions))
+	for id := range bus.subscriptions {
+		ids = append(ids, id)
+	}
+	bus.RUnlock()
+	for _, id := range ids {
+		Unsubscribe(id)
+	}
+}
+
+// emit publishes an event without blocking. It may be called with the rule state locked.
+func emit(ruleId string, eventType string, err error, attempt int) {
+	bus.RLock()
+	defer bus.RUnlock()
+	if len(bus.subscriptions) == 0 {
+		return
+	}
+	e := &Event{
+		RuleId:    ruleId,
+		Type:      eventType,
+		Attempt:   attempt,
+		Timestamp: conf.GetNowInMilli(),
+	}
+	if err != nil {
+		e.Message = err.Error()
+	}
+	for id, s := range bus.subscriptions {
+		if !s.filter.match(e) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			conf.Log.Warnf("rule event subscriber %s is busy, drop event %s of rule %s", id, e.Type, e.RuleId)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/internal/binder/io"
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	nodeConf "github.com/lf-edge/ekuiper/internal/topo/node/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/transform"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+// sinkSubscriber sends the rule events by a sink such as rest for webhooks, mqtt for topics and memory for the rules
+// to consume. The events are sent as json.
+type sinkSubscriber struct {
+	sink   api.Sink
+	ctx    api.StreamContext
+	opened bool
+}
+
+func newSinkSubscriber(id string, sinkType string, props map[string]interface{}) (*sinkSubscriber, error) {
+	s, err := io.Sink(sinkType)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return nil, fmt.Errorf("sink type %s not found", sinkType)
+	}
+	if props == nil {
+		props = make(map[string]interface{})
+	}
+	if err := s.Configure(nodeConf.GetSinkConf(sinkType, props)); err != nil {
+		return nil, err
+	}
+	tf, err := transform.GenTransform("", "json", "", "", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	contextLogger := conf.Log.WithField("ruleEvent", id)
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	ctx = context.WithValue(ctx, context.TransKey, tf)
+	return &sinkSubscriber{sink: s, ctx: ctx}, nil
+}
+
+func (s *sinkSubscriber) Notify(e *Event) error {
+	// Open lazily so that the subscriber can recover if the external system is not available at first
+	if !s.opened {
+		if err := s.sink.Open(s.ctx); err != nil {
+			return fmt.Errorf("open sink error: %v", err)
+		}
+		s.opened = true
+	}
+	return s.sink.Collect(s.ctx, e.toMap())
+}
+
+func (s *sinkSubscriber) Close() error {
+	if !s.opened {
+		return nil
+	}
+	return s.sink.Close(s.ctx)
+}
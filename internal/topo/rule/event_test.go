@@ -0,0 +1,163 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/io/memory/pubsub"
+	"github.com/lf-edge/ekuiper/internal/processor"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+type mockSubscriber struct {
+	sync.Mutex
+	events []*Event
+	closed bool
+}
+
+func (m *mockSubscriber) Notify(e *Event) error {
+	m.Lock()
+	defer m.Unlock()
+	m.events = append(m.events, e)
+	return nil
+}
+
+func (m *mockSubscriber) Close() error {
+	m.Lock()
+	defer m.Unlock()
+	m.closed = true
+	return nil
+}
+
+func (m *mockSubscriber) types() []string {
+	m.Lock()
+	defer m.Unlock()
+	result := make([]string, 0, len(m.events))
+	for _, e := range m.events {
+		result = append(result, e.Type)
+	}
+	return result
+}
+
+func TestRuleEvents(t *testing.T) {
+	sp := processor.NewStreamProcessor()
+	sp.ExecStmt(`CREATE STREAM demoEvent () WITH (TYPE="memory", DATASOURCE="event", FORMAT="JSON")`)
+	defer sp.ExecStmt(`DROP STREAM demoEvent`)
+
+	all := &mockSubscriber{}
+	require.NoError(t, Subscribe("all", all, EventFilter{}, 10))
+	crash := &mockSubscriber{}
+	require.NoError(t, Subscribe("crash", crash, EventFilter{Types: []string{EventStoppedByError}, Rules: []string{"testEventCrash"}}, 10))
+	assert.Error(t, Subscribe("all", all, EventFilter{}, 10))
+
+	// start and stop manually
+	r := &api.Rule{
+		Id:      "testEvent",
+		Sql:     "SELECT * FROM demoEvent",
+		Actions: []map[string]interface{}{{"log": map[string]interface{}{}}},
+		// Do not use the defaultOption which may be changed by the schedule tests
+		Options: &api.RuleOption{
+			Concurrency:  1,
+			BufferLength: 1024,
+			Qos:          api.AtMostOnce,
+			Restart:      &api.RestartStrategy{Delay: 1000},
+		},
+	}
+	rs, err := NewRuleState(r)
+	require.NoError(t, err)
+	require.NoError(t, rs.Start())
+	assert.Eventually(t, func() bool {
+		return len(all.types()) == 1
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, rs.Stop())
+	// stop a stopped rule emits nothing
+	require.NoError(t, rs.Stop())
+	require.NoError(t, rs.Close())
+	assert.Eventually(t, func() bool {
+		return len(all.types()) == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{EventStarted, EventStopped}, all.types())
+
+	// The mqtt sink fails to open without the connection, so the rule keeps crashing
+	r = &api.Rule{
+		Id:      "testEventCrash",
+		Sql:     "SELECT * FROM demoEvent",
+		Actions: []map[string]interface{}{{"mqtt": map[string]interface{}{"server": "tcp://127.0.0.1:1", "topic": "test"}}},
+		Options: &api.RuleOption{
+			Concurrency:  1,
+			BufferLength: 1024,
+			Qos:          api.AtMostOnce,
+			Restart: &api.RestartStrategy{
+				Attempts: 1,
+				Delay:    10,
+				MaxDelay: 10,
+			},
+		},
+	}
+	rs, err = NewRuleState(r)
+	require.NoError(t, err)
+	defer rs.Close()
+	require.NoError(t, rs.Start())
+	assert.Eventually(t, func() bool {
+		return len(all.types()) == 5
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{EventStarted, EventStopped, EventStarted, EventRestarted, EventStoppedByError}, all.types())
+	all.Lock()
+	assert.Equal(t, "testEventCrash", all.events[3].RuleId)
+	assert.Equal(t, 1, all.events[3].Attempt)
+	assert.NotEmpty(t, all.events[3].Message)
+	all.Unlock()
+
+	Unsubscribe("crash")
+	assert.Equal(t, []string{EventStoppedByError}, crash.types())
+	assert.True(t, crash.closed)
+	Unsubscribe("all")
+	assert.True(t, all.closed)
+}
+
+func TestSinkSubscriber(t *testing.T) {
+	ch := pubsub.CreateSub("$kuiper/ruleEvent", nil, "testSinkSubscriber", 10)
+	defer pubsub.CloseSourceConsumerChannel("$kuiper/ruleEvent", "testSinkSubscriber")
+	InitEventBus(&conf.RuleEventConf{
+		BufferLength: 10,
+		Subscribers: []*conf.RuleEventSubscriberConf{
+			{
+				Type:   "memory",
+				Events: []string{EventScheduleLeft},
+				Props:  map[string]interface{}{"topic": "$kuiper/ruleEvent"},
+			},
+			{
+				Type: "notExist",
+			},
+		},
+	})
+	defer CloseEventBus()
+	emit("rule1", EventStarted, nil, 0)
+	emit("rule1", EventScheduleLeft, nil, 0)
+	select {
+	case tuple := <-ch:
+		assert.Equal(t, "rule1", tuple.Message()["ruleId"])
+		assert.Equal(t, EventScheduleLeft, tuple.Message()["type"])
+	case <-time.After(time.Second):
+		t.Fatal("timeout to receive the rule event")
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"strings"
+
+	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
+)
+
+const (
+	// HealthHealthy means the rule is running and all the connections are connected
+	HealthHealthy = "healthy"
+	// HealthDegraded means the rule is running but some connections are reconnecting
+	HealthDegraded = "degraded"
+	// HealthUnhealthy means the rule is stopped by error or some connections are disconnected
+	HealthUnhealthy = "unhealthy"
+	// HealthStopped means the rule is not supposed to run, such as stopped manually or waiting for the next schedule
+	HealthStopped = "stopped"
+)
+
+// Health is the health of a rule aggregated from the connections of its sources and sinks
+type Health struct {
+	Status string `json:"status"`
+	// Message is the state of the rule if it is not running
+	Message string              `json:"message,omitempty"`
+	Nodes   []metric.NodeHealth `json:"nodes"`
+}
+
+// Ready checks if the rule is ready to process data. A stopped rule is ready as it is not supposed to run.
+func (h *Health) Ready() bool {
+	return h.Status != HealthUnhealthy
+}
+
+// GetHealth returns the health of the rule
+func (rs *RuleState) GetHealth() (*Health, error) {
+	state, err := rs.GetState()
+	if err != nil {
+		return nil, err
+	}
+	h := &Health{Nodes: []metric.NodeHealth{}}
+	if state != RuleStarted {
+		h.Message = state
+		h.Status = HealthUnhealthy
+		// The schedule rule state may have a suffix of the start failed count
+		for _, s := range []string{RuleStopped, RuleTerminated, RuleWait} {
+			if strings.HasPrefix(state, s) {
+				h.Status = HealthStopped
+				break
+			}
+		}
+		return h, nil
+	}
+	rs.RLock()
+	tp := rs.Topology
+	rs.RUnlock()
+	if tp != nil {
+		if nodes := tp.GetHealth(); nodes != nil {
+			h.Nodes = nodes
+		}
+	}
+	h.Status = aggregateHealth(h.Nodes)
+	return h, nil
+}
+
+func aggregateHealth(nodes []metric.NodeHealth) string {
+	result := HealthHealthy
+	for _, n := range nodes {
+		switch n.Status {
+		case metric.ConnectionDisconnected:
+			return HealthUnhealthy
+		case metric.ConnectionReconnecting:
+			result = HealthDegraded
+		}
+	}
+	return result
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/processor"
+	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+func TestAggregateHealth(t *testing.T) {
+	node := func(status string) metric.NodeHealth {
+		return metric.NodeHealth{ConnectionHealth: metric.ConnectionHealth{Status: status}}
+	}
+	tests := []struct {
+		nodes []metric.NodeHealth
+		exp   string
+	}{
+		{nodes: nil, exp: HealthHealthy},
+		{nodes: []metric.NodeHealth{node(metric.ConnectionConnected), node(metric.ConnectionConnected)}, exp: HealthHealthy},
+		{nodes: []metric.NodeHealth{node(metric.ConnectionConnected), node(metric.ConnectionReconnecting)}, exp: HealthDegraded},
+		{nodes: []metric.NodeHealth{node(metric.ConnectionReconnecting), node(metric.ConnectionDisconnected)}, exp: HealthUnhealthy},
+	}
+	for i, tt := range tests {
+		assert.Equal(t, tt.exp, aggregateHealth(tt.nodes), "case %d", i)
+	}
+}
+
+func TestRuleState_GetHealth(t *testing.T) {
+	sp := processor.NewStreamProcessor()
+	sp.ExecStmt(`CREATE STREAM demoHealth () WITH (TYPE="memory", DATASOURCE="health", FORMAT="JSON")`)
+	defer sp.ExecStmt(`DROP STREAM demoHealth`)
+	r := &api.Rule{
+		Id:  "testHealth",
+		Sql: "SELECT * FROM demoHealth",
+		Actions: []map[string]interface{}{
+			{
+				"log": map[string]interface{}{},
+			},
+		},
+		// Do not use the defaultOption which may be changed by the schedule tests
+		Options: &api.RuleOption{
+			Concurrency:  1,
+			BufferLength: 1024,
+			Qos:          api.AtMostOnce,
+			Restart:      &api.RestartStrategy{Delay: 1000},
+		},
+	}
+	rs, err := NewRuleState(r)
+	require.NoError(t, err)
+	defer rs.Close()
+
+	h, err := rs.GetHealth()
+	require.NoError(t, err)
+	assert.Equal(t, HealthStopped, h.Status)
+	assert.Equal(t, RuleStopped, h.Message)
+	assert.True(t, h.Ready())
+
+	require.NoError(t, rs.Start())
+	assert.Eventually(t, func() bool {
+		h, err = rs.GetHealth()
+		return err == nil && h.Status == HealthHealthy && len(h.Nodes) == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"source", "sink"}, []string{h.Nodes[0].Type, h.Nodes[1].Type})
+	assert.Equal(t, metric.ConnectionConnected, h.Nodes[0].Status)
+	assert.True(t, h.Ready())
+}
@@ -28,11 +28,15 @@ import (
 	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/topo"
 	"github.com/lf-edge/ekuiper/internal/topo/planner"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/infra"
 	"github.com/lf-edge/ekuiper/pkg/schedule"
 )
 
+// savepointTimeout is the max time to wait for the checkpoint triggered by a savepoint
+const savepointTimeout = 30 * time.Second
+
 const (
 	RuleStarted    = "Running"
 	RuleStopped    = "Stopped: canceled manually."
@@ -95,6 +99,8 @@ type RuleState struct {
 	triggered int
 	// temporary storage for topo graph to make sure even rule close, the graph is still available
 	topoGraph *api.PrintableTopo
+	// the states migrated by the last update to start the next topology with, only for rules without checkpoint
+	initState map[string]map[string]interface{}
 	sync.RWMutex
 	cronState cronStateCtx
 }
@@ -119,22 +125,129 @@ func NewRuleState(rule *api.Rule) (*RuleState, error) {
 
 // UpdateTopo update the rule and the topology AND restart the topology
 // Do not need to call restart after update
-func (rs *RuleState) UpdateTopo(rule *api.Rule) error {
-	if _, err := planner.Plan(rule); err != nil {
-		return err
+// The states of the operators are carried over to the operators of the new topology with the same id or the id mapped
+// by the stateMapping of the rule. The returned migration reports the operators whose states are discarded.
+func (rs *RuleState) UpdateTopo(rule *api.Rule) (*StateMigration, error) {
+	tp, err := planner.Plan(rule)
+	if err != nil {
+		return nil, err
+	}
+	nodes := tp.GetNodeNames()
+	if err := validateStateMapping(rule.StateMapping, nodes); err != nil {
+		return nil, err
 	}
 	if err := rs.Stop(); err != nil {
-		return err
+		return nil, err
 	}
 	time.Sleep(1 * time.Millisecond)
+	m, err := rs.migrateStates(rule, nodes)
+	if err != nil {
+		return nil, err
+	}
 	rs.Rule = rule
 	// If not triggered, just ignore start the rule
 	if rule.Triggered {
 		if err := rs.Start(); err != nil {
-			return err
+			return m, err
 		}
 	}
-	return nil
+	return m, nil
+}
+
+// migrateStates prepares the states of the old rule for the operators of the new rule.
+func (rs *RuleState) migrateStates(rule *api.Rule, nodes []string) (*StateMigration, error) {
+	rs.Lock()
+	defer rs.Unlock()
+	states, err := rs.stoppedStates()
+	if err != nil {
+		return nil, err
+	}
+	rs.initState = nil
+	if len(states) == 0 {
+		return &StateMigration{}, nil
+	}
+	return rs.prepareStates(rule, nodes, states)
+}
+
+// stoppedStates returns the states of the stopped rule. For rules with checkpoint, the states of the last checkpoint are
+// returned. Otherwise, the states of the stopped topology are returned.
+func (rs *RuleState) stoppedStates() (map[string]map[string]interface{}, error) {
+	if rs.Rule.Options.Qos >= api.AtLeastOnce {
+		states, err := state.LastSnapshot(rs.RuleId)
+		if err != nil {
+			return nil, fmt.Errorf("fail to read the last checkpoint of rule %s: %v", rs.RuleId, err)
+		}
+		return states, nil
+	} else if rs.initState != nil {
+		// The migrated states have not been used yet
+		return rs.initState, nil
+	} else if rs.Topology != nil {
+		return rs.Topology.GetStates(), nil
+	}
+	return nil, nil
+}
+
+// prepareStates migrates the states for the operators of the rule to start with in the next run. For rules with
+// checkpoint, the migrated states are saved as a new checkpoint to be restored. Otherwise, they are set to the next
+// topology to start.
+func (rs *RuleState) prepareStates(rule *api.Rule, nodes []string, states map[string]map[string]interface{}) (*StateMigration, error) {
+	migrated, m := migrateStates(states, nodes, rule.StateMapping)
+	if rule.Options.Qos >= api.AtLeastOnce {
+		if err := state.SaveSnapshot(rs.RuleId, migrated); err != nil {
+			return nil, fmt.Errorf("fail to save the migrated states of rule %s: %v", rs.RuleId, err)
+		}
+	} else {
+		rs.initState = migrated
+	}
+	if len(m.Lost) > 0 {
+		conf.Log.Warnf("rule %s states are migrated %v and lost %v", rs.RuleId, m.Migrated, m.Lost)
+	} else {
+		conf.Log.Infof("rule %s states are migrated %v", rs.RuleId, m.Migrated)
+	}
+	return m, nil
+}
+
+// Savepoint returns the states of all the operators to be saved as a savepoint. For a running rule with checkpoint, a
+// checkpoint is triggered to take a consistent snapshot. A running rule without checkpoint must be stopped first because
+// its operators keep updating the states.
+func (rs *RuleState) Savepoint() (map[string]map[string]interface{}, error) {
+	rs.RLock()
+	tp := rs.Topology
+	running := rs.triggered == 1 && tp != nil && tp.GetContext() != nil && tp.GetContext().Err() == nil
+	qos := rs.Rule.Options.Qos
+	rs.RUnlock()
+	if running {
+		if qos < api.AtLeastOnce {
+			return nil, fmt.Errorf("rule %s is running without checkpoint, stop it or set qos to 1 or 2 to create a savepoint", rs.RuleId)
+		}
+		return tp.Snapshot(savepointTimeout)
+	}
+	rs.Lock()
+	defer rs.Unlock()
+	return rs.stoppedStates()
+}
+
+// Restore restarts the rule with the states of a savepoint. The states are migrated to the operators like updating
+// the rule.
+func (rs *RuleState) Restore(states map[string]map[string]interface{}) (*StateMigration, error) {
+	tp, err := planner.Plan(rs.Rule)
+	if err != nil {
+		return nil, err
+	}
+	if err := rs.Stop(); err != nil {
+		return nil, err
+	}
+	time.Sleep(1 * time.Millisecond)
+	rs.Lock()
+	m, err := rs.prepareStates(rs.Rule, tp.GetNodeNames(), states)
+	rs.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if err := rs.Start(); err != nil {
+		return m, err
+	}
+	return m, nil
 }
 
 // only used for unit test
@@ -195,6 +308,7 @@ func (rs *RuleState) runTopo(ctx context.Context) {
 		conf.Log.Warnf("rule %s is not initialized or just stopped", rs.RuleId)
 		return
 	}
+	emit(rs.RuleId, EventStarted, nil, 0)
 	err := infra.SafeRun(func() error {
 		count := 0
 		d := option.Delay
@@ -236,6 +350,7 @@ func (rs *RuleState) runTopo(ctx context.Context) {
 				if option.Multiplier > 0 {
 					d = option.Delay * int(math.Pow(option.Multiplier, float64(count)))
 				}
+				emit(rs.RuleId, EventRestarted, er, count)
 			} else {
 				return er
 			}
@@ -250,6 +365,7 @@ func (rs *RuleState) runTopo(ctx context.Context) {
 				rs.topoGraph = rs.Topology.GetTopo()
 			}
 			rs.ActionCh <- ActionSignalStop
+			emit(rs.RuleId, EventStoppedByError, err, 0)
 		}
 
 		rs.Unlock()
@@ -387,9 +503,18 @@ func (rs *RuleState) start() error {
 		if tp, err := planner.Plan(rs.Rule); err != nil {
 			return err
 		} else {
+			if rs.initState != nil {
+				tp.SetInitState(rs.initState)
+				rs.initState = nil
+			}
 			rs.Topology = tp
+			// The graph of the topology stopped by error is outdated
+			rs.topoGraph = nil
 		}
 		rs.triggered = 1
+		if rs.Rule.IsScheduleRule() || rs.Rule.IsLongRunningScheduleRule() {
+			emit(rs.RuleId, EventScheduleEntered, nil, 0)
+		}
 	}
 	if rs.Rule.IsScheduleRule() || rs.Rule.IsLongRunningScheduleRule() {
 		conf.Log.Debugf("rule %v started", rs.RuleId)
@@ -406,7 +531,14 @@ func (rs *RuleState) Stop() error {
 		conf.Log.Debugf("rule %v manual stopped", rs.RuleId)
 	}
 	rs.stopScheduleRule()
-	return rs.stop()
+	running := rs.triggered == 1
+	if err := rs.stop(); err != nil {
+		return err
+	}
+	if running {
+		emit(rs.RuleId, EventStopped, nil, 0)
+	}
+	return nil
 }
 
 func (rs *RuleState) stopScheduleRule() {
@@ -454,6 +586,7 @@ func (rs *RuleState) internalStop() error {
 		rs.Topology.Cancel()
 	}
 	rs.ActionCh <- ActionSignalStop
+	emit(rs.RuleId, EventScheduleLeft, nil, 0)
 	return nil
 }
 
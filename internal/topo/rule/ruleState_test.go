@@ -203,7 +203,7 @@ func TestUpdate(t *testing.T) {
 		require.NoError(t, err)
 		time.Sleep(5 * time.Millisecond)
 		require.Equal(t, 1, rs.triggered, fmt.Sprintf("case %v failed", i))
-		err = rs.UpdateTopo(tt.r)
+		_, err = rs.UpdateTopo(tt.r)
 		time.Sleep(5 * time.Millisecond)
 		require.Equal(t, tt.e, err, fmt.Sprintf("case %v failed", i))
 		require.Equal(t, tt.triggered, rs.triggered, fmt.Sprintf("case %v failed", i))
@@ -252,7 +252,7 @@ func TestUpdateScheduleRule(t *testing.T) {
 		},
 		Options: &scheduleOption2,
 	}
-	err = rs.UpdateTopo(rule2)
+	_, err = rs.UpdateTopo(rule2)
 	require.NoError(t, err)
 	require.Equal(t, "mockCron2", rs.cronState.cron)
 	require.Equal(t, "2s", rs.cronState.duration)
@@ -0,0 +1,83 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// StateMigration reports how the operator states are carried over when a rule is updated
+type StateMigration struct {
+	// Migrated maps the operator id of the new rule to the operator id of the old rule whose state it takes over
+	Migrated map[string]string `json:"migrated,omitempty"`
+	// Lost is the sorted operator ids of the old rule whose states are discarded
+	Lost []string `json:"lost,omitempty"`
+}
+
+func (m *StateMigration) String() string {
+	if m == nil || len(m.Lost) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("The states of operators %s are discarded because no operator of the new rule takes them over.", strings.Join(m.Lost, ", "))
+}
+
+// validateStateMapping checks that the mapped operators exist in the new rule
+func validateStateMapping(mapping map[string]string, nodes []string) error {
+	names := make(map[string]struct{}, len(nodes))
+	for _, n := range nodes {
+		names[n] = struct{}{}
+	}
+	for k := range mapping {
+		if _, ok := names[k]; !ok {
+			return fmt.Errorf("invalid stateMapping: operator %s is not found in the rule, the operators are %s", k, strings.Join(nodes, ", "))
+		}
+	}
+	return nil
+}
+
+// migrateStates carries over the states of the old operators to the new operators with the same id or the mapped id.
+// An old operator which is explicitly mapped is not taken over implicitly by the new operator of the same id.
+func migrateStates(states map[string]map[string]interface{}, nodes []string, mapping map[string]string) (map[string]map[string]interface{}, *StateMigration) {
+	mapped := make(map[string]struct{}, len(mapping))
+	for _, v := range mapping {
+		mapped[v] = struct{}{}
+	}
+	result := make(map[string]map[string]interface{})
+	m := &StateMigration{Migrated: make(map[string]string)}
+	used := make(map[string]struct{})
+	for _, n := range nodes {
+		old, ok := mapping[n]
+		if !ok {
+			if _, isMapped := mapped[n]; isMapped {
+				continue
+			}
+			old = n
+		}
+		if st, ok := states[old]; ok && len(st) > 0 {
+			result[n] = st
+			m.Migrated[n] = old
+			used[old] = struct{}{}
+		}
+	}
+	for opId, st := range states {
+		if _, ok := used[opId]; !ok && len(st) > 0 {
+			m.Lost = append(m.Lost, opId)
+		}
+	}
+	sort.Strings(m.Lost)
+	return result, m
+}
@@ -0,0 +1,227 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/pkg/store"
+	"github.com/lf-edge/ekuiper/internal/processor"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+func TestMigrateStates(t *testing.T) {
+	states := map[string]map[string]interface{}{
+		"2_window":  {"inputs": 1},
+		"3_project": {"lag": 2},
+		"4_filter":  {},
+		"demo":      {"offset": 3},
+	}
+	tests := []struct {
+		name     string
+		nodes    []string
+		mapping  map[string]string
+		result   map[string]map[string]interface{}
+		migrated map[string]string
+		lost     []string
+	}{
+		{
+			name:     "compatible",
+			nodes:    []string{"demo", "2_window", "3_project", "log_0"},
+			result:   states,
+			migrated: map[string]string{"demo": "demo", "2_window": "2_window", "3_project": "3_project"},
+		},
+		{
+			name:     "lost",
+			nodes:    []string{"demo", "2_filter", "3_window", "4_project", "log_0"},
+			result:   map[string]map[string]interface{}{"demo": {"offset": 3}},
+			migrated: map[string]string{"demo": "demo"},
+			lost:     []string{"2_window", "3_project"},
+		},
+		{
+			name:     "mapping",
+			nodes:    []string{"demo", "2_filter", "3_window", "4_project", "log_0"},
+			mapping:  map[string]string{"3_window": "2_window", "4_project": "3_project"},
+			result:   map[string]map[string]interface{}{"demo": {"offset": 3}, "3_window": {"inputs": 1}, "4_project": {"lag": 2}},
+			migrated: map[string]string{"demo": "demo", "3_window": "2_window", "4_project": "3_project"},
+		},
+		{
+			name:     "mapping overrides the same id",
+			nodes:    []string{"demo", "2_window", "3_window", "log_0"},
+			mapping:  map[string]string{"3_window": "2_window"},
+			result:   map[string]map[string]interface{}{"demo": {"offset": 3}, "3_window": {"inputs": 1}},
+			migrated: map[string]string{"demo": "demo", "3_window": "2_window"},
+			lost:     []string{"3_project"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, m := migrateStates(states, tt.nodes, tt.mapping)
+			for k, v := range tt.result {
+				if len(v) == 0 {
+					delete(tt.result, k)
+				}
+			}
+			assert.Equal(t, tt.result, result)
+			assert.Equal(t, tt.migrated, m.Migrated)
+			assert.Equal(t, tt.lost, m.Lost)
+		})
+	}
+
+	assert.NoError(t, validateStateMapping(map[string]string{"3_window": "2_window"}, []string{"demo", "3_window"}))
+	assert.EqualError(t, validateStateMapping(map[string]string{"2_window": "2_window"}, []string{"demo", "3_window"}), "invalid stateMapping: operator 2_window is not found in the rule, the operators are demo, 3_window")
+}
+
+func TestUpdateMigrateStates(t *testing.T) {
+	sp := processor.NewStreamProcessor()
+	sp.ExecStmt(`CREATE STREAM demo () WITH (DATASOURCE="users", FORMAT="JSON")`)
+	defer sp.ExecStmt(`DROP STREAM demo`)
+	qosOption := *defaultOption
+	qosOption.Qos = api.AtLeastOnce
+	for _, option := range []*api.RuleOption{defaultOption, &qosOption} {
+		ruleId := "testMigrate"
+		rs, err := NewRuleState(&api.Rule{
+			Id:      ruleId,
+			Sql:     "SELECT count(*) FROM demo GROUP BY TumblingWindow(ss, 10)",
+			Actions: []map[string]interface{}{{"log": map[string]interface{}{}}},
+			Options: option,
+		})
+		require.NoError(t, err)
+		oldStates := map[string]map[string]interface{}{
+			"2_window": {"inputs": "a"},
+			"gone":     {"lag": "b"},
+		}
+		if option.Qos >= api.AtLeastOnce {
+			require.NoError(t, state.SaveSnapshot(ruleId, oldStates))
+		} else {
+			rs.initState = oldStates
+		}
+		_, err = rs.UpdateTopo(&api.Rule{
+			Id:           ruleId,
+			Sql:          "SELECT count(*) FROM demo WHERE a > 1 GROUP BY TumblingWindow(ss, 10)",
+			Actions:      []map[string]interface{}{{"log": map[string]interface{}{}}},
+			Options:      option,
+			StateMapping: map[string]string{"unknown": "2_window"},
+		})
+		require.EqualError(t, err, "invalid stateMapping: operator unknown is not found in the rule, the operators are demo, 2_filter, 3_window, 4_project, log_0")
+		m, err := rs.UpdateTopo(&api.Rule{
+			Id:           ruleId,
+			Sql:          "SELECT count(*) FROM demo WHERE a > 1 GROUP BY TumblingWindow(ss, 10)",
+			Actions:      []map[string]interface{}{{"log": map[string]interface{}{}}},
+			Options:      option,
+			StateMapping: map[string]string{"3_window": "2_window"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"3_window": "2_window"}, m.Migrated)
+		assert.Equal(t, []string{"gone"}, m.Lost)
+		assert.Equal(t, "The states of operators gone are discarded because no operator of the new rule takes them over.", m.String())
+		expected := map[string]map[string]interface{}{"3_window": {"inputs": "a"}}
+		if option.Qos >= api.AtLeastOnce {
+			last, err := state.LastSnapshot(ruleId)
+			require.NoError(t, err)
+			assert.Equal(t, expected, last)
+			require.NoError(t, store.DropTS(ruleId))
+		} else {
+			assert.Equal(t, expected, rs.initState)
+		}
+		rs.Close()
+	}
+}
+
+func TestSavepointRestore(t *testing.T) {
+	sp := processor.NewStreamProcessor()
+	sp.ExecStmt(`CREATE STREAM spDemo () WITH (DATASOURCE="spDemo", TYPE="memory", FORMAT="JSON")`)
+	defer sp.ExecStmt(`DROP STREAM spDemo`)
+	qosOption := *defaultOption
+	qosOption.Qos = api.AtLeastOnce
+	// The shared default option may be changed to a schedule rule by other tests
+	qosOption.Cron = ""
+	qosOption.Duration = ""
+	qosOption.CronDatetimeRange = nil
+	ruleId := "testSavepoint"
+	rs, err := NewRuleState(&api.Rule{
+		Triggered: true,
+		Id:        ruleId,
+		Sql:       "SELECT count(*) FROM spDemo GROUP BY TumblingWindow(ss, 10)",
+		Actions:   []map[string]interface{}{{"log": map[string]interface{}{}}},
+		Options:   &qosOption,
+	})
+	require.NoError(t, err)
+	defer func() {
+		rs.Close()
+		_ = store.DropTS(ruleId)
+	}()
+	require.NoError(t, rs.Start())
+	require.Eventually(t, func() bool {
+		rs.RLock()
+		defer rs.RUnlock()
+		c := rs.Topology.GetCoordinator()
+		return c != nil && c.IsActivated()
+	}, 5*time.Second, 10*time.Millisecond)
+	// A checkpoint is triggered for the running rule
+	states, err := rs.Savepoint()
+	require.NoError(t, err)
+	assert.Contains(t, states, "2_window")
+
+	m, err := rs.Restore(map[string]map[string]interface{}{
+		"2_window": {"inputs": "a"},
+		"gone":     {"lag": "b"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gone"}, m.Lost)
+	last, err := state.LastSnapshot(ruleId)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]interface{}{"2_window": {"inputs": "a"}}, last)
+	assert.Eventually(t, func() bool {
+		st, _ := rs.GetState()
+		return st == RuleStarted
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSavepointWithoutCheckpoint(t *testing.T) {
+	sp := processor.NewStreamProcessor()
+	sp.ExecStmt(`CREATE STREAM spDemo0 () WITH (DATASOURCE="spDemo0", TYPE="memory", FORMAT="JSON")`)
+	defer sp.ExecStmt(`DROP STREAM spDemo0`)
+	option := *defaultOption
+	option.Qos = api.AtMostOnce
+	option.Cron = ""
+	option.Duration = ""
+	option.CronDatetimeRange = nil
+	rs, err := NewRuleState(&api.Rule{
+		Triggered: true,
+		Id:        "testSavepoint0",
+		Sql:       "SELECT count(*) FROM spDemo0 GROUP BY TumblingWindow(ss, 10)",
+		Actions:   []map[string]interface{}{{"log": map[string]interface{}{}}},
+		Options:   &option,
+	})
+	require.NoError(t, err)
+	defer rs.Close()
+	require.NoError(t, rs.Start())
+	require.Eventually(t, func() bool {
+		st, _ := rs.GetState()
+		return st == RuleStarted
+	}, 5*time.Second, 10*time.Millisecond)
+	// The states of the running operators cannot be read consistently
+	_, err = rs.Savepoint()
+	assert.EqualError(t, err, "rule testSavepoint0 is running without checkpoint, stop it or set qos to 1 or 2 to create a savepoint")
+	require.NoError(t, rs.Stop())
+	_, err = rs.Savepoint()
+	assert.NoError(t, err)
+}
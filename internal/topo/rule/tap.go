@@ -0,0 +1,39 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/internal/topo/node"
+)
+
+// Tap attaches a subscriber to the node of the running rule
+func (rs *RuleState) Tap(name string, opts *node.TapOptions) (*node.TapSubscriber, error) {
+	state, err := rs.GetState()
+	if err != nil {
+		return nil, err
+	}
+	if state != RuleStarted {
+		return nil, fmt.Errorf("rule %s is not running", rs.RuleId)
+	}
+	rs.RLock()
+	tp := rs.Topology
+	rs.RUnlock()
+	if tp == nil {
+		return nil, fmt.Errorf("rule %s is not running", rs.RuleId)
+	}
+	return tp.Tap(name, opts)
+}
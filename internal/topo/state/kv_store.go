@@ -142,3 +142,67 @@ func (s *KVStore) GetOpState(opId string) (*sync.Map, error) {
 func (s *KVStore) Clean() error {
 	return s.db.DeleteBefore(s.checkpoints[0])
 }
+
+// GetSnapshot returns the states of a completed checkpoint keyed by the operator id
+func (s *KVStore) GetSnapshot(checkpointId int64) (map[string]map[string]interface{}, error) {
+	v, ok := s.mapStore.Load(checkpointId)
+	if !ok {
+		return nil, fmt.Errorf("store for checkpoint %d not found", checkpointId)
+	}
+	cstore, ok := v.(*sync.Map)
+	if !ok {
+		return nil, fmt.Errorf("invalid KVStore for checkpointId %d with value %v: should be *sync.Map type", checkpointId, v)
+	}
+	result := make(map[string]map[string]interface{})
+	cstore.Range(func(k, v interface{}) bool {
+		if sm, ok := v.(map[string]interface{}); ok {
+			result[k.(string)] = sm
+		}
+		return true
+	})
+	return result, nil
+}
+
+// LastSnapshot returns the states of the last checkpoint of a rule keyed by the operator id
+func LastSnapshot(ruleId string) (map[string]map[string]interface{}, error) {
+	db, err := ts.GetTS(ruleId)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if _, err := db.Last(&m); err != nil {
+		return nil, err
+	}
+	result := make(map[string]map[string]interface{}, len(m))
+	for opId, v := range m {
+		if sm, ok := v.(map[string]interface{}); ok {
+			result[opId] = sm
+		}
+	}
+	return result, nil
+}
+
+// SaveSnapshot saves the states as a new checkpoint of a rule so that the operators restore them in the next run
+func SaveSnapshot(ruleId string, snapshot map[string]map[string]interface{}) error {
+	db, err := ts.GetTS(ruleId)
+	if err != nil {
+		return err
+	}
+	var m map[string]interface{}
+	last, err := db.Last(&m)
+	if err != nil {
+		return err
+	}
+	checkpointId := conf.GetNowInMilli()
+	if checkpointId <= last {
+		checkpointId = last + 1
+	}
+	m = make(map[string]interface{}, len(snapshot))
+	for opId, v := range snapshot {
+		m[opId] = v
+	}
+	if _, err := db.Set(checkpointId, m); err != nil {
+		return fmt.Errorf("save snapshot err: %v", err)
+	}
+	return nil
+}
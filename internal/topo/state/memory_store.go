@@ -16,14 +16,26 @@ package state
 
 import (
 	"sync"
+
+	"github.com/lf-edge/ekuiper/pkg/cast"
 )
 
-type MemoryStore sync.Map // The current root store of a rule
+type MemoryStore sync.Map // The initial states of the operators keyed by the operator id
 
 func newMemoryStore() *MemoryStore {
 	return &MemoryStore{}
 }
 
+// NewMemoryStoreWithSnapshot creates a memory store whose operators start with the states of the snapshot.
+// It is used to carry over the states of a rule without checkpoint when the rule is updated.
+func NewMemoryStoreWithSnapshot(snapshot map[string]map[string]interface{}) *MemoryStore {
+	m := &sync.Map{}
+	for opId, v := range snapshot {
+		m.Store(opId, v)
+	}
+	return (*MemoryStore)(m)
+}
+
 func (s *MemoryStore) SaveState(_ int64, _ string, _ map[string]interface{}) error {
 	// do nothing
 	return nil
@@ -34,7 +46,10 @@ func (s *MemoryStore) SaveCheckpoint(_ int64) error {
 	return nil
 }
 
-func (s *MemoryStore) GetOpState(_ string) (*sync.Map, error) {
+func (s *MemoryStore) GetOpState(opId string) (*sync.Map, error) {
+	if v, ok := (*sync.Map)(s).Load(opId); ok {
+		return cast.MapToSyncMap(v.(map[string]interface{})), nil
+	}
 	return &sync.Map{}, nil
 }
 
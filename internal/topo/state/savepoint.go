@@ -0,0 +1,175 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/store"
+	"github.com/lf-edge/ekuiper/internal/pkg/store/encoding"
+	"github.com/lf-edge/ekuiper/pkg/kv"
+)
+
+// Savepoint is a snapshot of the states of a rule taken manually and saved under a name.
+// Unlike the checkpoints, the savepoints are kept until deleted and can be exported to restore the rule on another node.
+type Savepoint struct {
+	Name      string `json:"name"`
+	RuleId    string `json:"ruleId"`
+	Timestamp int64  `json:"timestamp"`
+	// States is the gob encoded states keyed by the operator id
+	States []byte `json:"states,omitempty"`
+}
+
+var (
+	savepointDb   kv.KeyValue
+	savepointOnce sync.Once
+	savepointErr  error
+
+	savepointNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+func getSavepointDb() (kv.KeyValue, error) {
+	savepointOnce.Do(func() {
+		savepointDb, savepointErr = store.GetKV("savepoint")
+	})
+	return savepointDb, savepointErr
+}
+
+func savepointKey(ruleId, name string) string {
+	return ruleId + "/" + name
+}
+
+// NewSavepoint creates a savepoint of the states of a rule
+func NewSavepoint(ruleId, name string, states map[string]map[string]interface{}) (*Savepoint, error) {
+	b, err := encoding.Encode(states)
+	if err != nil {
+		return nil, fmt.Errorf("fail to encode the states of rule %s: %v", ruleId, err)
+	}
+	return &Savepoint{Name: name, RuleId: ruleId, Timestamp: conf.GetNowInMilli(), States: b}, nil
+}
+
+// GetStates decodes the states of the savepoint
+func (s *Savepoint) GetStates() (map[string]map[string]interface{}, error) {
+	var states map[string]map[string]interface{}
+	if err := gob.NewDecoder(bytes.NewReader(s.States)).Decode(&states); err != nil {
+		return nil, fmt.Errorf("invalid states of savepoint %s: %v", s.Name, err)
+	}
+	return states, nil
+}
+
+// ValidateSavepointName checks the savepoint name which is a path segment of the rest api
+func ValidateSavepointName(name string) error {
+	if !savepointNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid savepoint name %s, only letters, digits, underscore and hyphen are allowed", name)
+	}
+	return nil
+}
+
+// SaveSavepoint persists a new savepoint
+func SaveSavepoint(s *Savepoint) error {
+	if err := ValidateSavepointName(s.Name); err != nil {
+		return err
+	}
+	db, err := getSavepointDb()
+	if err != nil {
+		return err
+	}
+	if ok, _ := db.Get(savepointKey(s.RuleId, s.Name), &Savepoint{}); ok {
+		return fmt.Errorf("savepoint %s of rule %s already exists", s.Name, s.RuleId)
+	}
+	return db.Setnx(savepointKey(s.RuleId, s.Name), s)
+}
+
+// GetSavepoint returns the savepoint with the states
+func GetSavepoint(ruleId, name string) (*Savepoint, error) {
+	db, err := getSavepointDb()
+	if err != nil {
+		return nil, err
+	}
+	s := &Savepoint{}
+	if ok, err := db.Get(savepointKey(ruleId, name), s); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, fmt.Errorf("savepoint %s of rule %s is not found", name, ruleId)
+	}
+	return s, nil
+}
+
+// ListSavepoints returns the savepoints of a rule without the states, the latest first
+func ListSavepoints(ruleId string) ([]*Savepoint, error) {
+	db, err := getSavepointDb()
+	if err != nil {
+		return nil, err
+	}
+	keys, err := db.Keys()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Savepoint, 0)
+	for _, k := range keys {
+		if !strings.HasPrefix(k, ruleId+"/") {
+			continue
+		}
+		s := &Savepoint{}
+		if ok, err := db.Get(k, s); err != nil || !ok {
+			continue
+		}
+		s.States = nil
+		result = append(result, s)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp > result[j].Timestamp
+	})
+	return result, nil
+}
+
+// DeleteSavepoint removes a savepoint
+func DeleteSavepoint(ruleId, name string) error {
+	db, err := getSavepointDb()
+	if err != nil {
+		return err
+	}
+	if _, err := GetSavepoint(ruleId, name); err != nil {
+		return err
+	}
+	return db.Delete(savepointKey(ruleId, name))
+}
+
+// DeleteSavepoints removes all the savepoints of a rule when the rule is dropped
+func DeleteSavepoints(ruleId string) error {
+	db, err := getSavepointDb()
+	if err != nil {
+		return err
+	}
+	keys, err := db.Keys()
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if strings.HasPrefix(k, ruleId+"/") {
+			if err := db.Delete(k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
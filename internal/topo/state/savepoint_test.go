@@ -0,0 +1,86 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/pkg/store"
+)
+
+func TestSavepoint(t *testing.T) {
+	require.NoError(t, store.SetupDefault())
+	states := map[string]map[string]interface{}{
+		"2_window": {"inputs": map[string]interface{}{"a": int64(1)}},
+		"demo":     {"offset": int64(10)},
+	}
+	sp, err := NewSavepoint("spRule", "sp1", states)
+	require.NoError(t, err)
+	require.NoError(t, SaveSavepoint(sp))
+	defer DeleteSavepoint("spRule", "sp1")
+	assert.EqualError(t, SaveSavepoint(sp), "savepoint sp1 of rule spRule already exists")
+	sp2, err := NewSavepoint("spRule", "bad/name", states)
+	require.NoError(t, err)
+	assert.EqualError(t, SaveSavepoint(sp2), "invalid savepoint name bad/name, only letters, digits, underscore and hyphen are allowed")
+	other, err := NewSavepoint("spRule2", "sp1", nil)
+	require.NoError(t, err)
+	require.NoError(t, SaveSavepoint(other))
+	defer DeleteSavepoint("spRule2", "sp1")
+
+	list, err := ListSavepoints("spRule")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "sp1", list[0].Name)
+	assert.Nil(t, list[0].States)
+
+	got, err := GetSavepoint("spRule", "sp1")
+	require.NoError(t, err)
+	result, err := got.GetStates()
+	require.NoError(t, err)
+	assert.Equal(t, states, result)
+
+	require.NoError(t, DeleteSavepoint("spRule", "sp1"))
+	_, err = GetSavepoint("spRule", "sp1")
+	assert.EqualError(t, err, "savepoint sp1 of rule spRule is not found")
+	assert.EqualError(t, DeleteSavepoint("spRule", "sp1"), "savepoint sp1 of rule spRule is not found")
+
+	// Drop all the savepoints of a rule
+	sp3, err := NewSavepoint("spRule", "sp3", nil)
+	require.NoError(t, err)
+	require.NoError(t, SaveSavepoint(sp3))
+	require.NoError(t, DeleteSavepoints("spRule"))
+	list, err = ListSavepoints("spRule")
+	require.NoError(t, err)
+	assert.Len(t, list, 0)
+	list, err = ListSavepoints("spRule2")
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+}
+
+func TestMemoryStoreWithSnapshot(t *testing.T) {
+	s := NewMemoryStoreWithSnapshot(map[string]map[string]interface{}{"op1": {"a": 1}})
+	m, err := s.GetOpState("op1")
+	require.NoError(t, err)
+	v, ok := m.Load("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	m, err = s.GetOpState("op2")
+	require.NoError(t, err)
+	_, ok = m.Load("a")
+	assert.False(t, ok)
+}
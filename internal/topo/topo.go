@@ -34,6 +34,7 @@ import (
 	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
 	"github.com/lf-edge/ekuiper/internal/topo/state"
 	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
 	"github.com/lf-edge/ekuiper/pkg/infra"
 )
 
@@ -49,7 +50,11 @@ type Topo struct {
 	store       api.Store
 	coordinator *checkpoint.Coordinator
 	topo        *api.PrintableTopo
-	mu          sync.Mutex
+	// deadLetter is the sink to receive the failed records, nil if not configured
+	deadLetter *node.SinkNode
+	// initState is the states migrated from the previous version of the rule, only used once in the next open
+	initState map[string]map[string]interface{}
+	mu        sync.Mutex
 }
 
 func NewWithNameAndOptions(name string, options *api.RuleOption) (*Topo, error) {
@@ -61,6 +66,13 @@ func NewWithNameAndOptions(name string, options *api.RuleOption) (*Topo, error)
 			Edges:   make(map[string][]interface{}),
 		},
 	}
+	if options.DeadLetter != nil {
+		dl, err := node.NewDeadLetterNode(options.DeadLetter)
+		if err != nil {
+			return nil, err
+		}
+		tp.deadLetter = dl
+	}
 	return tp, nil
 }
 
@@ -179,23 +191,30 @@ func (s *Topo) Open() <-chan error {
 			s.mu.Lock()
 			defer s.mu.Unlock()
 			var err error
-			if s.store, err = state.CreateStore(s.name, s.options.Qos); err != nil {
+			if s.initState != nil {
+				s.store = state.NewMemoryStoreWithSnapshot(s.initState)
+				s.initState = nil
+			} else if s.store, err = state.CreateStore(s.name, s.options.Qos); err != nil {
 				return fmt.Errorf("topo %s create store error %v", s.name, err)
 			}
 			s.enableCheckpoint()
+			// open dead letter sink first so that it is ready to receive failures of any node
+			if s.deadLetter != nil {
+				s.deadLetter.Open(s.ctx.WithMeta(s.name, s.deadLetter.GetName(), s.store), s.drain)
+			}
 			// open stream sink, after log sink is ready.
 			for _, snk := range s.sinks {
-				snk.Open(s.ctx.WithMeta(s.name, snk.GetName(), s.store), s.drain)
+				snk.Open(s.nodeContext(snk.GetName()), s.drain)
 			}
 
 			// apply operators, if err bail
 			for _, op := range s.ops {
-				op.Exec(s.ctx.WithMeta(s.name, op.GetName(), s.store), s.drain)
+				op.Exec(s.nodeContext(op.GetName()), s.drain)
 			}
 
 			// open source, if err bail
 			for _, source := range s.sources {
-				source.Open(s.ctx.WithMeta(s.name, source.GetName(), s.store), s.drain)
+				source.Open(s.nodeContext(source.GetName()), s.drain)
 			}
 
 			// activate checkpoint
@@ -212,6 +231,17 @@ func (s *Topo) Open() <-chan error {
 	return s.drain
 }
 
+// nodeContext creates the context for a node. If dead letter is enabled, the context carries the input of the dead
+// letter sink so that the node can send the failed records to it.
+func (s *Topo) nodeContext(name string) api.StreamContext {
+	ctx := s.ctx.WithMeta(s.name, name, s.store)
+	if s.deadLetter != nil {
+		input, _ := s.deadLetter.GetInput()
+		ctx = kctx.WithValue(ctx.(*kctx.DefaultContext), kctx.DeadLetterKey, input)
+	}
+	return ctx
+}
+
 func (s *Topo) enableCheckpoint() error {
 	if s.options.Qos >= api.AtLeastOnce {
 		var sources []checkpoint.StreamTask
@@ -252,6 +282,13 @@ func (s *Topo) GetMetrics() (keys []string, values []interface{}) {
 				values = append(values, v)
 			}
 		}
+		if en, ok := so.(node.ExtraMetricsNode); ok {
+			names, vs := en.GetExtraMetrics()
+			for i, v := range vs {
+				keys = append(keys, "op_"+so.GetName()+"_0_"+names[i])
+				values = append(values, v)
+			}
+		}
 	}
 	for _, sn := range s.sinks {
 		for ins, metrics := range sn.GetMetrics() {
@@ -261,9 +298,44 @@ func (s *Topo) GetMetrics() (keys []string, values []interface{}) {
 			}
 		}
 	}
+	if s.deadLetter != nil {
+		for ins, metrics := range s.deadLetter.GetMetrics() {
+			for i, v := range metrics {
+				keys = append(keys, "sink_"+s.deadLetter.GetName()+"_"+strconv.Itoa(ins)+"_"+metric.MetricNames[i])
+				values = append(values, v)
+			}
+		}
+	}
 	return
 }
 
+// GetHealth returns the connection health of the source and sink instances. An instance which has not reported its
+// status yet, for example, when the sink is still connecting, is regarded as disconnected.
+func (s *Topo) GetHealth() []metric.NodeHealth {
+	var result []metric.NodeHealth
+	collect := func(typ string, name string, hs []*metric.ConnectionHealth) {
+		for ins, h := range hs {
+			nh := metric.NodeHealth{Node: name, Type: typ, Instance: ins}
+			if h != nil {
+				nh.ConnectionHealth = *h
+			} else {
+				nh.Status = metric.ConnectionDisconnected
+			}
+			result = append(result, nh)
+		}
+	}
+	for _, sn := range s.sources {
+		collect("source", sn.GetName(), sn.GetConnectionHealth())
+	}
+	for _, sn := range s.sinks {
+		collect("sink", sn.GetName(), sn.GetConnectionHealth())
+	}
+	if s.deadLetter != nil {
+		collect("sink", s.deadLetter.GetName(), s.deadLetter.GetConnectionHealth())
+	}
+	return result
+}
+
 func (s *Topo) RemoveMetrics() {
 	for _, sn := range s.sources {
 		sn.RemoveMetrics(s.name)
@@ -274,8 +346,111 @@ func (s *Topo) RemoveMetrics() {
 	for _, sn := range s.sinks {
 		sn.RemoveMetrics(s.name)
 	}
+	if s.deadLetter != nil {
+		s.deadLetter.RemoveMetrics(s.name)
+	}
 }
 
 func (s *Topo) GetTopo() *api.PrintableTopo {
 	return s.topo
 }
+
+// GetNodeNames returns the names of all the nodes which are also the operator ids of their states
+func (s *Topo) GetNodeNames() []string {
+	var names []string
+	for _, src := range s.sources {
+		names = append(names, src.GetName())
+	}
+	for _, op := range s.ops {
+		names = append(names, op.GetName())
+	}
+	for _, snk := range s.sinks {
+		names = append(names, snk.GetName())
+	}
+	if s.deadLetter != nil {
+		names = append(names, s.deadLetter.GetName())
+	}
+	return names
+}
+
+// Tap attaches a subscriber to the node of the name to receive its data
+func (s *Topo) Tap(name string, opts *node.TapOptions) (*node.TapSubscriber, error) {
+	var nodes []interface{}
+	for _, src := range s.sources {
+		nodes = append(nodes, src)
+	}
+	for _, op := range s.ops {
+		nodes = append(nodes, op)
+	}
+	for _, snk := range s.sinks {
+		nodes = append(nodes, snk)
+	}
+	if s.deadLetter != nil {
+		nodes = append(nodes, s.deadLetter)
+	}
+	for _, n := range nodes {
+		if tn, ok := n.(node.TapNode); ok && tn.GetName() == name {
+			return tn.Tap(opts)
+		}
+	}
+	return nil, errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("node %s is not found in rule %s", name, s.name))
+}
+
+// GetStates returns the current states of the opened nodes keyed by the operator id.
+// Nodes without any state are omitted.
+func (s *Topo) GetStates() map[string]map[string]interface{} {
+	var ctxs []api.StreamContext
+	for _, src := range s.sources {
+		ctxs = append(ctxs, src.GetStreamContext())
+	}
+	for _, op := range s.ops {
+		ctxs = append(ctxs, op.GetStreamContext())
+	}
+	for _, snk := range s.sinks {
+		ctxs = append(ctxs, snk.GetStreamContext())
+	}
+	if s.deadLetter != nil {
+		ctxs = append(ctxs, s.deadLetter.GetStreamContext())
+	}
+	result := make(map[string]map[string]interface{})
+	for _, ctx := range ctxs {
+		if c, ok := ctx.(*kctx.DefaultContext); ok {
+			if states := c.GetStates(); len(states) > 0 {
+				result[c.GetOpId()] = states
+			}
+		}
+	}
+	return result
+}
+
+// Snapshot returns the states of the running topology by triggering a checkpoint so that the states are consistent
+// among the operators. The states of the running nodes cannot be read safely if checkpoint is not enabled.
+func (s *Topo) Snapshot(timeout time.Duration) (map[string]map[string]interface{}, error) {
+	s.mu.Lock()
+	c, st := s.coordinator, s.store
+	s.mu.Unlock()
+	if c == nil {
+		return nil, fmt.Errorf("rule %s does not enable checkpoint", s.name)
+	}
+	kvStore, ok := st.(*state.KVStore)
+	if !ok {
+		return nil, fmt.Errorf("rule %s does not save checkpoint", s.name)
+	}
+	select {
+	case checkpointId, ok := <-c.TriggerCheckpoint():
+		if !ok {
+			return nil, fmt.Errorf("the checkpoint of rule %s is discarded", s.name)
+		}
+		return kvStore.GetSnapshot(checkpointId)
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("timeout to wait for the checkpoint of rule %s", s.name)
+	}
+}
+
+// SetInitState sets the states for the operators to start with in the next open.
+// It is for the rules without checkpoint, the others restore the states from the last checkpoint.
+func (s *Topo) SetInitState(states map[string]map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.initState = states
+}
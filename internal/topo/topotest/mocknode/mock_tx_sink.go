@@ -0,0 +1,103 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocknode
+
+import (
+	"sync"
+
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+// MockTxSink is a two-phase commit sink which only exposes the committed results
+type MockTxSink struct {
+	sync.Mutex
+	current [][]byte
+	pending map[int64][][]byte
+	results [][]byte
+}
+
+func NewMockTxSink() *MockTxSink {
+	return &MockTxSink{pending: make(map[int64][][]byte)}
+}
+
+func (m *MockTxSink) Open(ctx api.StreamContext) error {
+	ctx.GetLogger().Debugln("Opening mock transaction sink")
+	return nil
+}
+
+func (m *MockTxSink) Configure(_ map[string]interface{}) error {
+	return nil
+}
+
+func (m *MockTxSink) Collect(ctx api.StreamContext, item interface{}) error {
+	v, _, err := ctx.TransformOutput(item)
+	if err != nil {
+		return err
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.current = append(m.current, v)
+	return nil
+}
+
+func (m *MockTxSink) Recover(ctx api.StreamContext, pending []int64) error {
+	for _, cid := range pending {
+		if err := m.Commit(ctx, cid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MockTxSink) PreCommit(_ api.StreamContext, checkpointId int64) error {
+	m.Lock()
+	defer m.Unlock()
+	m.pending[checkpointId] = m.current
+	m.current = nil
+	return nil
+}
+
+func (m *MockTxSink) Commit(_ api.StreamContext, checkpointId int64) error {
+	m.Lock()
+	defer m.Unlock()
+	m.results = append(m.results, m.pending[checkpointId]...)
+	delete(m.pending, checkpointId)
+	return nil
+}
+
+func (m *MockTxSink) Abort(_ api.StreamContext) error {
+	m.Lock()
+	defer m.Unlock()
+	m.current = nil
+	return nil
+}
+
+func (m *MockTxSink) Close(_ api.StreamContext) error {
+	return nil
+}
+
+// GetResults returns the committed results
+func (m *MockTxSink) GetResults() [][]byte {
+	m.Lock()
+	defer m.Unlock()
+	return m.results
+}
+
+// GetPending returns the number of pre-committed but not committed transactions
+func (m *MockTxSink) GetPending() int {
+	m.Lock()
+	defer m.Unlock()
+	return len(m.pending)
+}
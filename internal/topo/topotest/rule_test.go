@@ -1273,10 +1273,10 @@ func TestSingleSQL(t *testing.T) {
 				},
 			},
 			M: map[string]interface{}{
-				"op_6_project_0_exceptions_total":   int64(0),
-				"op_6_project_0_process_latency_us": int64(0),
-				"op_6_project_0_records_in_total":   int64(1),
-				"op_6_project_0_records_out_total":  int64(1),
+				"op_4_project_0_exceptions_total":   int64(0),
+				"op_4_project_0_process_latency_us": int64(0),
+				"op_4_project_0_records_in_total":   int64(1),
+				"op_4_project_0_records_out_total":  int64(1),
 
 				"sink_mockSink_0_exceptions_total":  int64(0),
 				"sink_mockSink_0_records_in_total":  int64(1),
@@ -2116,3 +2116,241 @@ func TestAliasSQL(t *testing.T) {
 		DoRuleTest(t, tests, j, opt, 0)
 	}
 }
+
+func TestIntervalJoinSQL(t *testing.T) {
+	// Reset
+	streamList := []string{"demoE", "demo1E"}
+	HandleStream(false, streamList, t)
+	tests := []RuleTest{
+		{
+			Name: `TestIntervalJoinRule1`,
+			Sql:  `SELECT color, temp, demoE.ts AS ts1, demo1E.ts AS ts2 FROM demoE INNER JOIN demo1E ON demo1E.ts BETWEEN demoE.ts - 200 AND demoE.ts + 200`,
+			R: [][]map[string]interface{}{
+				{{
+					"color": "red",
+					"temp":  25.5,
+					"ts1":   float64(1541152486013),
+					"ts2":   float64(1541152486013),
+				}},
+				{{
+					"color": "blue",
+					"temp":  28.1,
+					"ts1":   float64(1541152487632),
+					"ts2":   float64(1541152487632),
+				}},
+				{{
+					"color": "yellow",
+					"temp":  27.4,
+					"ts1":   float64(1541152488442),
+					"ts2":   float64(1541152488442),
+				}},
+				{{
+					"color": "red",
+					"temp":  25.5,
+					"ts1":   float64(1541152489252),
+					"ts2":   float64(1541152489252),
+				}},
+			},
+		},
+		{
+			Name: `TestIntervalJoinRule2`,
+			Sql:  `SELECT color, hum, demoE.ts AS ts1, demo1E.ts AS ts2 FROM demoE LEFT JOIN demo1E ON demoE.ts BETWEEN demo1E.ts AND demo1E.ts + 1000 AND hum > 60`,
+			R: [][]map[string]interface{}{
+				{{
+					"color": "red",
+					"hum":   float64(65),
+					"ts1":   float64(1541152486013),
+					"ts2":   float64(1541152486013),
+				}},
+				{{
+					"color": "blue",
+					"hum":   float64(75),
+					"ts1":   float64(1541152487632),
+					"ts2":   float64(1541152487632),
+				}},
+				{{
+					"color": "yellow",
+					"hum":   float64(75),
+					"ts1":   float64(1541152488442),
+					"ts2":   float64(1541152487632),
+				}, {
+					"color": "yellow",
+					"hum":   float64(80),
+					"ts1":   float64(1541152488442),
+					"ts2":   float64(1541152488442),
+				}},
+				{{
+					"color": "red",
+					"hum":   float64(80),
+					"ts1":   float64(1541152489252),
+					"ts2":   float64(1541152488442),
+				}},
+				{{
+					"color": "red",
+					"hum":   float64(62),
+					"ts1":   float64(1541152489252),
+					"ts2":   float64(1541152489252),
+				}},
+			},
+		},
+		{
+			Name: `TestIntervalJoinRule3`,
+			Sql:  `SELECT color, hum, demoE.ts AS ts1, demo1E.ts AS ts2 FROM demoE LEFT JOIN demo1E ON demoE.ts BETWEEN demo1E.ts AND demo1E.ts + 1000 AND hum > 70`,
+			R: [][]map[string]interface{}{
+				{{
+					"color": "red",
+					"ts1":   float64(1541152486013),
+				}},
+				{{
+					"color": "blue",
+					"hum":   float64(75),
+					"ts1":   float64(1541152487632),
+					"ts2":   float64(1541152487632),
+				}},
+				{{
+					"color": "yellow",
+					"hum":   float64(75),
+					"ts1":   float64(1541152488442),
+					"ts2":   float64(1541152487632),
+				}, {
+					"color": "yellow",
+					"hum":   float64(80),
+					"ts1":   float64(1541152488442),
+					"ts2":   float64(1541152488442),
+				}},
+				{{
+					"color": "red",
+					"hum":   float64(80),
+					"ts1":   float64(1541152489252),
+					"ts2":   float64(1541152488442),
+				}},
+			},
+			M: map[string]interface{}{
+				"op_4_interval_join_0_exceptions_total":  int64(0),
+				"op_4_interval_join_0_records_out_total": int64(4),
+			},
+		},
+	}
+	HandleStream(true, streamList, t)
+	options := []*api.RuleOption{
+		{
+			BufferLength: 100,
+			SendError:    true,
+			IsEventTime:  true,
+			LateTol:      1000,
+		}, {
+			BufferLength:       100,
+			SendError:          true,
+			Qos:                api.AtLeastOnce,
+			CheckpointInterval: 5000,
+			IsEventTime:        true,
+			LateTol:            1000,
+		},
+	}
+	for j, opt := range options {
+		DoRuleTest(t, tests, j, opt, 0)
+	}
+}
+
+func TestMatchRecognizeSQL(t *testing.T) {
+	// Reset
+	streamList := []string{"sessionDemo", "demo"}
+	HandleStream(false, streamList, t)
+	tests := []RuleTest{
+		{
+			Name: `TestMatchRecognizeRule1`,
+			Sql:  `SELECT * FROM sessionDemo MATCH_RECOGNIZE (MEASURES FIRST(A.temp) AS startTemp, LAST(A.temp) AS endTemp, LAST(A.ts) AS endTs PATTERN (A{3}) DEFINE A AS temp > PREV(temp))`,
+			R: [][]map[string]interface{}{
+				{{
+					"startTemp": 26.2,
+					"endTemp":   28.9,
+					"endTs":     float64(1541152491682),
+				}},
+			},
+			M: map[string]interface{}{
+				"op_2_match_recognize_0_exceptions_total":  int64(0),
+				"op_2_match_recognize_0_records_in_total":  int64(11),
+				"op_2_match_recognize_0_records_out_total": int64(1),
+			},
+		},
+		{
+			Name: `TestMatchRecognizeRule2`,
+			Sql:  `SELECT color, decreased, endTs FROM demo MATCH_RECOGNIZE (PARTITION BY color MEASURES FIRST(A.size) - B.size AS decreased, B.ts AS endTs PATTERN (A B) DEFINE B AS size < A.size)`,
+			R: [][]map[string]interface{}{
+				{{
+					"color":     "blue",
+					"decreased": float64(4),
+					"endTs":     float64(1541152487632),
+				}},
+				{{
+					"color":     "red",
+					"decreased": float64(2),
+					"endTs":     float64(1541152489252),
+				}},
+			},
+		},
+		{
+			Name: `TestMatchRecognizeRule3`,
+			Sql:  `SELECT startTs, endTemp FROM sessionDemo MATCH_RECOGNIZE (MEASURES FIRST(A.ts) AS startTs, LAST(B.temp) AS endTemp PATTERN (A B+ C) WITHIN 2 SS DEFINE B AS temp > PREV(temp), C AS hum > 90) WHERE endTemp > 28`,
+			R: [][]map[string]interface{}{
+				{{
+					"startTs": float64(1541152490872),
+					"endTemp": 28.9,
+				}},
+			},
+		},
+	}
+	HandleStream(true, streamList, t)
+	options := []*api.RuleOption{
+		{
+			BufferLength: 100,
+			SendError:    true,
+		}, {
+			BufferLength:       100,
+			SendError:          true,
+			Qos:                api.AtLeastOnce,
+			CheckpointInterval: 5000,
+		},
+	}
+	for j, opt := range options {
+		DoRuleTest(t, tests, j, opt, 0)
+	}
+}
+
+func TestMatchRecognizeEventTimeSQL(t *testing.T) {
+	// Reset
+	streamList := []string{"sessionDemoE"}
+	HandleStream(false, streamList, t)
+	tests := []RuleTest{
+		{
+			Name: `TestMatchRecognizeEventTimeRule1`,
+			Sql:  `SELECT startTs, endTemp, hum FROM sessionDemoE MATCH_RECOGNIZE (MEASURES FIRST(A.ts) AS startTs, LAST(B.temp) AS endTemp, C.hum AS hum PATTERN (A B+ C) WITHIN 2 SS DEFINE B AS temp > PREV(temp), C AS hum > 90)`,
+			R: [][]map[string]interface{}{
+				{{
+					"startTs": float64(1541152490872),
+					"endTemp": 28.9,
+					"hum":     float64(92),
+				}},
+			},
+		},
+	}
+	HandleStream(true, streamList, t)
+	options := []*api.RuleOption{
+		{
+			BufferLength: 100,
+			SendError:    true,
+			IsEventTime:  true,
+			LateTol:      1000,
+		}, {
+			BufferLength:       100,
+			SendError:          true,
+			Qos:                api.AtLeastOnce,
+			CheckpointInterval: 5000,
+			IsEventTime:        true,
+			LateTol:            1000,
+		},
+	}
+	for j, opt := range options {
+		DoRuleTest(t, tests, j, opt, 0)
+	}
+}
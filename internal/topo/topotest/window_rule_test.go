@@ -1755,3 +1755,393 @@ func TestEventSlidingWindow(t *testing.T) {
 		DoRuleTest(t, tests, j, opt, 10)
 	}
 }
+
+func TestKeyedWindow(t *testing.T) {
+	// Reset
+	streamList := []string{"demoE"}
+	HandleStream(false, streamList, t)
+	tests := []RuleTest{
+		{
+			Name: `TestKeyedWindowRule1`,
+			Sql:  `SELECT color, count(*) AS c, window_start() AS ws, window_end() AS we FROM demoE GROUP BY color, SESSIONWINDOW(ss, 10, 1)`,
+			R: [][]map[string]interface{}{
+				{{
+					"color": "red",
+					"c":     float64(1),
+					"ws":    float64(1541152486013),
+					"we":    float64(1541152487013),
+				}},
+				{{
+					"color": "blue",
+					"c":     float64(1),
+					"ws":    float64(1541152487632),
+					"we":    float64(1541152488632),
+				}},
+				{{
+					"color": "red",
+					"c":     float64(1),
+					"ws":    float64(1541152489252),
+					"we":    float64(1541152490252),
+				}},
+				{{
+					"color": "yellow",
+					"c":     float64(1),
+					"ws":    float64(1541152488442),
+					"we":    float64(1541152489442),
+				}},
+			},
+			M: map[string]interface{}{
+				"op_3_window_0_exceptions_total":  int64(0),
+				"op_3_window_0_records_in_total":  int64(4),
+				"op_3_window_0_records_out_total": int64(4),
+			},
+		},
+	}
+	HandleStream(true, streamList, t)
+	options := []*api.RuleOption{
+		{
+			BufferLength: 100,
+			SendError:    true,
+			IsEventTime:  true,
+			LateTol:      1000,
+			KeyedWindow:  true,
+		},
+		{
+			BufferLength:       100,
+			SendError:          true,
+			Qos:                api.AtLeastOnce,
+			CheckpointInterval: 5000,
+			IsEventTime:        true,
+			LateTol:            1000,
+			KeyedWindow:        true,
+		},
+	}
+	for j, opt := range options {
+		DoRuleTest(t, tests, j, opt, 10)
+	}
+	// Processing time
+	streamList = []string{"demo"}
+	HandleStream(false, streamList, t)
+	tests = []RuleTest{
+		{
+			Name: `TestKeyedWindowRule2`,
+			Sql:  `SELECT color, sum(size) AS s FROM demo GROUP BY color, COUNTWINDOW(2)`,
+			R: [][]map[string]interface{}{
+				{{
+					"color": "blue",
+					"s":     float64(8),
+				}},
+				{{
+					"color": "red",
+					"s":     float64(4),
+				}},
+			},
+			M: map[string]interface{}{
+				"op_2_window_0_exceptions_total":  int64(0),
+				"op_2_window_0_records_in_total":  int64(5),
+				"op_2_window_0_records_out_total": int64(2),
+			},
+		},
+	}
+	HandleStream(true, streamList, t)
+	options = []*api.RuleOption{
+		{
+			BufferLength: 100,
+			SendError:    true,
+			KeyedWindow:  true,
+		},
+		{
+			BufferLength:       100,
+			SendError:          true,
+			Qos:                api.AtLeastOnce,
+			CheckpointInterval: 5000,
+			KeyedWindow:        true,
+		},
+	}
+	for j, opt := range options {
+		DoRuleTest(t, tests, j, opt, 0)
+	}
+}
+
+func TestIncAggWindow(t *testing.T) {
+	// Reset
+	streamList := []string{"demo"}
+	HandleStream(false, streamList, t)
+	tests := []RuleTest{
+		{
+			Name: `TestIncAggWindowRule1`,
+			Sql:  `SELECT color, count(*) AS c, sum(size) AS s, avg(size) AS a, max(size) AS mx, min(size) AS mn, window_start() AS ws FROM demo GROUP BY color, HOPPINGWINDOW(ss, 2, 1) ORDER BY color`,
+			R: [][]map[string]interface{}{
+				{{
+					"color": "blue",
+					"c":     float64(1),
+					"s":     float64(6),
+					"a":     float64(6),
+					"mx":    float64(6),
+					"mn":    float64(6),
+					"ws":    float64(1541152485000),
+				}, {
+					"color": "red",
+					"c":     float64(1),
+					"s":     float64(3),
+					"a":     float64(3),
+					"mx":    float64(3),
+					"mn":    float64(3),
+					"ws":    float64(1541152485000),
+				}},
+				{{
+					"color": "blue",
+					"c":     float64(2),
+					"s":     float64(8),
+					"a":     float64(4),
+					"mx":    float64(6),
+					"mn":    float64(2),
+					"ws":    float64(1541152486000),
+				}, {
+					"color": "red",
+					"c":     float64(1),
+					"s":     float64(3),
+					"a":     float64(3),
+					"mx":    float64(3),
+					"mn":    float64(3),
+					"ws":    float64(1541152486000),
+				}},
+				{{
+					"color": "blue",
+					"c":     float64(1),
+					"s":     float64(2),
+					"a":     float64(2),
+					"mx":    float64(2),
+					"mn":    float64(2),
+					"ws":    float64(1541152487000),
+				}, {
+					"color": "yellow",
+					"c":     float64(1),
+					"s":     float64(4),
+					"a":     float64(4),
+					"mx":    float64(4),
+					"mn":    float64(4),
+					"ws":    float64(1541152487000),
+				}},
+				{{
+					"color": "red",
+					"c":     float64(1),
+					"s":     float64(1),
+					"a":     float64(1),
+					"mx":    float64(1),
+					"mn":    float64(1),
+					"ws":    float64(1541152488000),
+				}, {
+					"color": "yellow",
+					"c":     float64(1),
+					"s":     float64(4),
+					"a":     float64(4),
+					"mx":    float64(4),
+					"mn":    float64(4),
+					"ws":    float64(1541152488000),
+				}},
+			},
+			M: map[string]interface{}{
+				"op_2_window_0_exceptions_total":  int64(0),
+				"op_2_window_0_records_in_total":  int64(5),
+				"op_2_window_0_records_out_total": int64(4),
+			},
+		},
+		{
+			Name: `TestIncAggWindowRule2`,
+			Sql:  `SELECT count(*) AS c, stddev(size) AS sd, var(size) AS v FROM demo GROUP BY TUMBLINGWINDOW(ss, 1) HAVING c > 1`,
+			R: [][]map[string]interface{}{
+				{{
+					"c":  float64(2),
+					"sd": 1.5,
+					"v":  2.25,
+				}},
+			},
+			M: map[string]interface{}{
+				"op_2_window_0_exceptions_total": int64(0),
+				"op_2_window_0_records_in_total": int64(5),
+			},
+		},
+		{
+			Name: `TestIncAggWindowRule3`,
+			Sql:  `SELECT count(*) AS c, sum(size) AS s, max(size) AS m FROM demo GROUP BY COUNTWINDOW(2, 1)`,
+			R: [][]map[string]interface{}{
+				{{
+					"c": float64(2),
+					"s": float64(9),
+					"m": float64(6),
+				}},
+				{{
+					"c": float64(2),
+					"s": float64(8),
+					"m": float64(6),
+				}},
+				{{
+					"c": float64(2),
+					"s": float64(6),
+					"m": float64(4),
+				}},
+				{{
+					"c": float64(2),
+					"s": float64(5),
+					"m": float64(4),
+				}},
+			},
+			M: map[string]interface{}{
+				"op_2_window_0_exceptions_total":  int64(0),
+				"op_2_window_0_records_in_total":  int64(5),
+				"op_2_window_0_records_out_total": int64(4),
+			},
+		},
+	}
+	HandleStream(true, streamList, t)
+	options := []*api.RuleOption{
+		{
+			BufferLength: 100,
+			SendError:    true,
+		},
+		{
+			BufferLength:       100,
+			SendError:          true,
+			Qos:                api.AtLeastOnce,
+			CheckpointInterval: 5000,
+		},
+	}
+	for j, opt := range options {
+		DoRuleTest(t, tests, j, opt, 15)
+	}
+}
+
+func TestIncAggEventWindow(t *testing.T) {
+	// Reset
+	streamList := []string{"demoE"}
+	HandleStream(false, streamList, t)
+	tests := []RuleTest{
+		{
+			Name: `TestIncAggEventWindowRule1`,
+			Sql:  `SELECT color, count(*) AS c, sum(size) AS s, window_start() AS ws, window_end() AS we FROM demoE GROUP BY color, HOPPINGWINDOW(ss, 2, 1) ORDER BY color`,
+			R: [][]map[string]interface{}{
+				{{
+					"color": "red",
+					"c":     float64(1),
+					"s":     float64(3),
+					"ws":    float64(1541152485013),
+					"we":    float64(1541152487000),
+				}},
+				{{
+					"color": "blue",
+					"c":     float64(1),
+					"s":     float64(2),
+					"ws":    float64(1541152486000),
+					"we":    float64(1541152488000),
+				}, {
+					"color": "red",
+					"c":     float64(1),
+					"s":     float64(3),
+					"ws":    float64(1541152486000),
+					"we":    float64(1541152488000),
+				}},
+				{{
+					"color": "blue",
+					"c":     float64(1),
+					"s":     float64(2),
+					"ws":    float64(1541152487000),
+					"we":    float64(1541152489000),
+				}, {
+					"color": "yellow",
+					"c":     float64(1),
+					"s":     float64(4),
+					"ws":    float64(1541152487000),
+					"we":    float64(1541152489000),
+				}},
+				{{
+					"color": "red",
+					"c":     float64(1),
+					"s":     float64(1),
+					"ws":    float64(1541152488000),
+					"we":    float64(1541152490000),
+				}, {
+					"color": "yellow",
+					"c":     float64(1),
+					"s":     float64(4),
+					"ws":    float64(1541152488000),
+					"we":    float64(1541152490000),
+				}},
+				{{
+					"color": "red",
+					"c":     float64(1),
+					"s":     float64(1),
+					"ws":    float64(1541152489000),
+					"we":    float64(1541152491000),
+				}},
+			},
+			M: map[string]interface{}{
+				"op_3_window_0_exceptions_total":  int64(0),
+				"op_3_window_0_records_in_total":  int64(4),
+				"op_3_window_0_records_out_total": int64(5),
+			},
+		},
+		{
+			Name: `TestIncAggEventWindowRule2`,
+			Sql:  `SELECT count(*) AS c, max(size) AS m, window_end() AS we FROM demoE GROUP BY TUMBLINGWINDOW(ss, 2) HAVING c > 0`,
+			R: [][]map[string]interface{}{
+				{{
+					"c":  float64(2),
+					"m":  float64(3),
+					"we": float64(1541152488000),
+				}},
+				{{
+					"c":  float64(2),
+					"m":  float64(4),
+					"we": float64(1541152490000),
+				}},
+			},
+			M: map[string]interface{}{
+				"op_3_window_0_exceptions_total":  int64(0),
+				"op_3_window_0_records_in_total":  int64(4),
+				"op_3_window_0_records_out_total": int64(2),
+			},
+		},
+		{
+			Name: `TestIncAggEventWindowRule3`,
+			Sql:  `SELECT count(*) AS c, max(size) AS m, window_end() AS we FROM demoE WHERE color != "red" GROUP BY TUMBLINGWINDOW(ss, 2) HAVING c > 0`,
+			R: [][]map[string]interface{}{
+				{{
+					"c":  float64(1),
+					"m":  float64(2),
+					"we": float64(1541152488000),
+				}},
+				{{
+					"c":  float64(1),
+					"m":  float64(4),
+					"we": float64(1541152490000),
+				}},
+			},
+			M: map[string]interface{}{
+				"op_3_window_0_exceptions_total":  int64(0),
+				"op_3_window_0_records_in_total":  int64(4),
+				"op_3_window_0_records_out_total": int64(2),
+			},
+		},
+	}
+	HandleStream(true, streamList, t)
+	options := []*api.RuleOption{
+		{
+			BufferLength: 100,
+			SendError:    true,
+			IsEventTime:  true,
+			LateTol:      1000,
+		},
+		{
+			BufferLength:       100,
+			SendError:          true,
+			Qos:                api.AtLeastOnce,
+			CheckpointInterval: 5000,
+			IsEventTime:        true,
+			LateTol:            1000,
+		},
+	}
+	for j, opt := range options {
+		DoRuleTest(t, tests, j, opt, 10)
+	}
+}
@@ -37,7 +37,7 @@ func GenTransform(dt string, format string, schemaId string, delimiter string, d
 		err error
 	)
 	switch format {
-	case message.FormatProtobuf, message.FormatCustom:
+	case message.FormatProtobuf, message.FormatCustom, message.FormatAvro:
 		c, err = converter.GetOrCreateConverter(&ast.Options{FORMAT: format, SCHEMAID: schemaId})
 		if err != nil {
 			return nil, err
@@ -48,7 +48,7 @@ func GenTransform(dt string, format string, schemaId string, delimiter string, d
 			return nil, err
 		}
 		c.(*delimited.Converter).SetColumns(fields)
-	case message.FormatJson:
+	case message.FormatJson, message.FormatMsgpack:
 		c, err = converter.GetOrCreateConverter(&ast.Options{FORMAT: format})
 		if err != nil {
 			return nil, err
@@ -99,7 +99,7 @@ func GenTransform(dt string, format string, schemaId string, delimiter string, d
 			}
 			outBytes, err := c.Encode(d)
 			return outBytes, transformed || selected, err
-		case message.FormatProtobuf, message.FormatCustom, message.FormatDelimited:
+		case message.FormatProtobuf, message.FormatCustom, message.FormatDelimited, message.FormatAvro, message.FormatMsgpack:
 			if transformed && !selected {
 				m := make(map[string]interface{})
 				err := json.Unmarshal(bs, &m)
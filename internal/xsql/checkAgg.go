@@ -24,7 +24,7 @@ func IsAggregate(expr ast.Expr) (r bool) {
 	ast.WalkFunc(expr, func(n ast.Node) bool {
 		switch f := n.(type) {
 		case *ast.Call:
-			if ok := function.IsAggFunc(f.Name); ok {
+			if ok := isAggCall(f); ok {
 				r = true
 				return false
 			}
@@ -40,6 +40,12 @@ func IsAggregate(expr ast.Expr) (r bool) {
 	return
 }
 
+// isAggCall returns true if the call is an aggregate call. The incremental aggregate function with OVER clause is
+// calculated as an analytic function, thus it is not an aggregate call.
+func isAggCall(c *ast.Call) bool {
+	return function.IsAggFunc(c.Name) && !function.IsAnalyticCall(c)
+}
+
 func getOrCalculateAgg(f *ast.FieldRef) bool {
 	if f.IsAlias() {
 		p := f.IsAggregate
@@ -64,7 +70,7 @@ func WithAggFields(stmt *ast.SelectStatement) bool {
 	ast.WalkFunc(stmt.Fields, func(n ast.Node) bool {
 		switch f := n.(type) {
 		case *ast.Call:
-			if ok := function.IsAggFunc(f.Name); ok {
+			if ok := isAggCall(f); ok {
 				r = true
 				return false
 			}
@@ -81,7 +87,7 @@ func HasAggFuncs(node ast.Node) bool {
 	r := false
 	ast.WalkFunc(node, func(n ast.Node) bool {
 		if f, ok := n.(*ast.Call); ok {
-			if ok := function.IsAggFunc(f.Name); ok {
+			if ok := isAggCall(f); ok {
 				r = true
 				return false
 			}
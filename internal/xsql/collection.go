@@ -25,6 +25,8 @@ import (
 // AggregateData Could be a tuple or collection
 type AggregateData interface {
 	AggregateEval(expr ast.Expr, v CallValuer) []interface{}
+	// AggregateRange ranges the valuer of each row so that the aggregation can be calculated incrementally
+	AggregateRange(v CallValuer, f func(rv Valuer) error) error
 }
 
 type SortingData interface {
@@ -193,6 +195,15 @@ func (w *WindowTuples) AggregateEval(expr ast.Expr, v CallValuer) []interface{}
 	return result
 }
 
+func (w *WindowTuples) AggregateRange(v CallValuer, f func(rv Valuer) error) error {
+	for _, t := range w.Content {
+		if err := f(MultiValuer(t, &WindowRangeValuer{WindowRange: w.WindowRange}, v, &WildcardValuer{t})); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Filter the tuples by the given predicate
 func (w *WindowTuples) Filter(indexes []int) Collection {
 	w.cachedMap = nil
@@ -306,6 +317,15 @@ func (s *JoinTuples) AggregateEval(expr ast.Expr, v CallValuer) []interface{} {
 	return result
 }
 
+func (s *JoinTuples) AggregateRange(v CallValuer, f func(rv Valuer) error) error {
+	for _, t := range s.Content {
+		if err := f(MultiValuer(t, &WindowRangeValuer{WindowRange: s.WindowRange}, v, &WildcardValuer{t})); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *JoinTuples) GetWindowRange() *WindowRange {
 	return s.WindowRange
 }
@@ -15,6 +15,7 @@
 package xsql
 
 import (
+	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/errorx"
 )
 
@@ -59,3 +60,14 @@ func (fv *FunctionValuer) Call(name string, funcId int, args []interface{}) (int
 	}
 	return ExecFunc(name, nf, args, fctx)
 }
+
+func (fv *FunctionValuer) IncAggFunc(name string, funcId int) (api.AggregateFunction, api.FunctionContext, bool) {
+	nf, fctx, err := fv.runtime.Get(name, funcId)
+	if err != nil {
+		return nil, nil, false
+	}
+	if af, ok := nf.(api.AggregateFunction); ok && af.IsAggregate() && af.IsIncremental() {
+		return af, fctx, true
+	}
+	return nil, nil, false
+}
@@ -15,7 +15,10 @@
 package xsql
 
 import (
+	"fmt"
+
 	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
 	"github.com/lf-edge/ekuiper/pkg/errorx"
 )
 
@@ -84,3 +87,100 @@ func (v *AggregateFunctionValuer) Call(name string, funcId int, args []interface
 func (v *AggregateFunctionValuer) GetAllTuples() AggregateData {
 	return v.data
 }
+
+func (v *AggregateFunctionValuer) IncAggFunc(name string, funcId int) (api.AggregateFunction, api.FunctionContext, bool) {
+	return v.fv.IncAggFunc(name, funcId)
+}
+
+// evalIncAgg calculates the incremental aggregate function over the buffered rows of the group when the window is
+// triggered. It is only used by the windows which do not aggregate incrementally when the rows arrive. The rows are
+// accumulated in one batch if the function supports. Return false if the function cannot be calculated incrementally.
+func evalIncAgg(expr *ast.Call, valuer CallValuer) (interface{}, bool) {
+	aggreValuer, ok := valuer.(AggregateCallValuer)
+	if !ok {
+		return nil, false
+	}
+	iv, ok := aggreValuer.GetSingleCallValuer().(IncAggFuncValuer)
+	if !ok {
+		return nil, false
+	}
+	f, fctx, ok := iv.IncAggFunc(expr.Name, expr.FuncId)
+	if !ok {
+		return nil, false
+	}
+	acc, err := f.Init(fctx)
+	if err != nil {
+		return fmt.Errorf("call func %s error: %v", expr.Name, err), true
+	}
+	var rows [][]interface{}
+	err = aggreValuer.GetAllTuples().AggregateRange(aggreValuer.GetSingleCallValuer(), func(rv Valuer) error {
+		args := make([]interface{}, len(expr.Args))
+		for i, arg := range expr.Args {
+			args[i] = Eval(arg, rv)
+			if e, ok := args[i].(error); ok {
+				return e
+			}
+		}
+		rows = append(rows, args)
+		return nil
+	})
+	if err == nil && len(rows) > 0 {
+		if bf, ok := f.(BatchAccumulator); ok {
+			acc, err = bf.AccumulateBatch(fctx, acc, rows)
+		} else {
+			for _, args := range rows {
+				if acc, err = f.Accumulate(fctx, acc, args); err != nil {
+					break
+				}
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("call func %s error: %v", expr.Name, err), true
+	}
+	r, err := f.Result(fctx, acc)
+	if err != nil {
+		return fmt.Errorf("call func %s error: %v", expr.Name, err), true
+	}
+	return r, true
+}
+
+// evalAnalyticAgg calculates the incremental aggregate function called with OVER clause. The row is accumulated into
+// the accumulator of its partition which is saved in the function state. The last two args are the when condition
+// result and the partition key like the analytic functions.
+func evalAnalyticAgg(expr *ast.Call, valuer CallValuer, args []interface{}) interface{} {
+	iv, ok := valuer.(IncAggFuncValuer)
+	if !ok {
+		return fmt.Errorf("call func %s error: cannot find the function", expr.Name)
+	}
+	f, fctx, ok := iv.IncAggFunc(expr.Name, expr.FuncId)
+	if !ok {
+		return fmt.Errorf("call func %s error: it is not an incremental aggregate function", expr.Name)
+	}
+	key := args[len(args)-1].(string)
+	validData := args[len(args)-2].(bool)
+	acc, err := fctx.GetState(key)
+	if err != nil {
+		return fmt.Errorf("call func %s error: %v", expr.Name, err)
+	}
+	if acc == nil {
+		acc, err = f.Init(fctx)
+		if err != nil {
+			return fmt.Errorf("call func %s error: %v", expr.Name, err)
+		}
+	}
+	if validData {
+		acc, err = f.Accumulate(fctx, acc, args[:len(args)-2])
+		if err != nil {
+			return fmt.Errorf("call func %s error: %v", expr.Name, err)
+		}
+		if err := fctx.PutState(key, acc); err != nil {
+			return fmt.Errorf("call func %s error: %v", expr.Name, err)
+		}
+	}
+	r, err := f.Result(fctx, acc)
+	if err != nil {
+		return fmt.Errorf("call func %s error: %v", expr.Name, err)
+	}
+	return r
+}
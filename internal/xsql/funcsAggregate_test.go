@@ -0,0 +1,234 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xsql
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lf-edge/ekuiper/internal/binder"
+	"github.com/lf-edge/ekuiper/internal/binder/function"
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/plugin"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+// incAvg is an incremental aggregate function whose Exec must not be called
+type incAvg struct{}
+
+func (f *incAvg) Validate(_ []interface{}) error {
+	return nil
+}
+
+func (f *incAvg) Exec(_ []interface{}, _ api.FunctionContext) (interface{}, bool) {
+	return errors.New("exec should not be called"), false
+}
+
+func (f *incAvg) IsAggregate() bool {
+	return true
+}
+
+func (f *incAvg) IsIncremental() bool {
+	return true
+}
+
+func (f *incAvg) Init(_ api.FunctionContext) (interface{}, error) {
+	return []float64{0, 0}, nil
+}
+
+func (f *incAvg) Accumulate(_ api.FunctionContext, acc interface{}, args []interface{}) (interface{}, error) {
+	v, err := cast.ToFloat64(args[0], cast.CONVERT_SAMEKIND)
+	if err != nil {
+		return nil, err
+	}
+	a := acc.([]float64)
+	return []float64{a[0] + v, a[1] + 1}, nil
+}
+
+func (f *incAvg) Retract(_ api.FunctionContext, acc interface{}, args []interface{}) (interface{}, error) {
+	v, err := cast.ToFloat64(args[0], cast.CONVERT_SAMEKIND)
+	if err != nil {
+		return nil, err
+	}
+	a := acc.([]float64)
+	return []float64{a[0] - v, a[1] - 1}, nil
+}
+
+func (f *incAvg) Merge(_ api.FunctionContext, acc1 interface{}, acc2 interface{}) (interface{}, error) {
+	a1, a2 := acc1.([]float64), acc2.([]float64)
+	return []float64{a1[0] + a2[0], a1[1] + a2[1]}, nil
+}
+
+func (f *incAvg) Result(_ api.FunctionContext, acc interface{}) (interface{}, error) {
+	a := acc.([]float64)
+	if a[1] == 0 {
+		return nil, nil
+	}
+	return a[0] / a[1], nil
+}
+
+// incBatchAvg accumulates all the rows of a group in one call like the portable functions
+type incBatchAvg struct {
+	incAvg
+	batches int
+}
+
+func (f *incBatchAvg) Accumulate(_ api.FunctionContext, _ interface{}, _ []interface{}) (interface{}, error) {
+	return nil, errors.New("accumulate should not be called")
+}
+
+func (f *incBatchAvg) AccumulateBatch(ctx api.FunctionContext, acc interface{}, rows [][]interface{}) (interface{}, error) {
+	f.batches++
+	var err error
+	for _, args := range rows {
+		if acc, err = f.incAvg.Accumulate(ctx, acc, args); err != nil {
+			return nil, err
+		}
+	}
+	return acc, nil
+}
+
+var batchAvg = &incBatchAvg{}
+
+type incAggFactory struct{}
+
+func (f *incAggFactory) Function(name string) (api.Function, error) {
+	switch name {
+	case "inc_avg":
+		return &incAvg{}, nil
+	case "inc_batch_avg":
+		return batchAvg, nil
+	}
+	return nil, nil
+}
+
+func (f *incAggFactory) HasFunctionSet(_ string) bool {
+	return false
+}
+
+func (f *incAggFactory) ConvName(name string) (string, bool) {
+	return name, name == "inc_avg" || name == "inc_batch_avg"
+}
+
+func (f *incAggFactory) FunctionPluginInfo(_ string) (plugin.EXTENSION_TYPE, string, string) {
+	return plugin.NONE_EXTENSION, "", ""
+}
+
+func init() {
+	_ = function.Initialize([]binder.FactoryEntry{{Name: "incAgg", Factory: &incAggFactory{}}})
+}
+
+func newIncAggTestCtx() api.StreamContext {
+	contextLogger := conf.Log.WithField("rule", "TestIncAgg")
+	tempStore, _ := state.CreateStore("TestIncAgg", api.AtMostOnce)
+	return context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithMeta("TestIncAgg", "op1", tempStore)
+}
+
+func TestIncAggFunc(t *testing.T) {
+	stmt, err := NewParser(strings.NewReader("SELECT inc_avg(a) AS r FROM demo GROUP BY b, TUMBLINGWINDOW(ss, 10)")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, WithAggFields(stmt))
+	assert.True(t, function.IsIncAggFunc("inc_avg"))
+	assert.False(t, function.IsIncAggFunc("avg"))
+
+	wt := &WindowTuples{Content: []TupleRow{
+		&Tuple{Emitter: "demo", Message: map[string]interface{}{"a": 1, "b": "x"}},
+		&Tuple{Emitter: "demo", Message: map[string]interface{}{"a": 2.5, "b": "x"}},
+		&Tuple{Emitter: "demo", Message: map[string]interface{}{"a": 6, "b": "y"}},
+	}}
+	fv, afv := NewFunctionValuersForOp(newIncAggTestCtx())
+	afv.SetData(wt)
+	ve := &ValuerEval{Valuer: MultiAggregateValuer(wt, fv, wt, fv, afv, &WildcardValuer{Data: wt})}
+	assert.Equal(t, 3.1666666666666665, ve.Eval(stmt.Fields[0].Expr))
+
+	gt := &GroupedTuples{Content: []TupleRow{
+		&Tuple{Emitter: "demo", Message: map[string]interface{}{"a": 1, "b": "x"}},
+		&Tuple{Emitter: "demo", Message: map[string]interface{}{"a": "invalid", "b": "x"}},
+	}}
+	afv.SetData(gt)
+	ve = &ValuerEval{Valuer: MultiAggregateValuer(gt, fv, gt, fv, afv, &WildcardValuer{Data: gt})}
+	r := ve.Eval(stmt.Fields[0].Expr)
+	assert.EqualError(t, r.(error), "call func inc_avg error: cannot convert string(invalid) to float64")
+}
+
+func TestIncAggBatchFunc(t *testing.T) {
+	stmt, err := NewParser(strings.NewReader("SELECT inc_batch_avg(a) AS r FROM demo GROUP BY COUNTWINDOW(3)")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt := &WindowTuples{Content: []TupleRow{
+		&Tuple{Emitter: "demo", Message: map[string]interface{}{"a": 1}},
+		&Tuple{Emitter: "demo", Message: map[string]interface{}{"a": 2.5}},
+		&Tuple{Emitter: "demo", Message: map[string]interface{}{"a": 6}},
+	}}
+	fv, afv := NewFunctionValuersForOp(newIncAggTestCtx())
+	afv.SetData(wt)
+	ve := &ValuerEval{Valuer: MultiAggregateValuer(wt, fv, wt, fv, afv, &WildcardValuer{Data: wt})}
+	assert.Equal(t, 3.1666666666666665, ve.Eval(stmt.Fields[0].Expr))
+	// All the rows are accumulated in one call
+	assert.Equal(t, 1, batchAvg.batches)
+}
+
+func TestIncAggAnalyticFunc(t *testing.T) {
+	stmt, err := NewParser(strings.NewReader("SELECT inc_avg(a) OVER (PARTITION BY b) AS r, inc_avg(a) OVER (WHEN a > 1) AS r2 FROM demo")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, WithAggFields(stmt))
+	assert.True(t, function.IsAnalyticCall(stmt.Fields[0].Expr.(*ast.Call)))
+
+	_, err = NewParser(strings.NewReader("SELECT avg(a) OVER (PARTITION BY b) FROM demo")).Parse()
+	assert.EqualError(t, err, "Found OVER after non analytic function avg")
+
+	tests := []struct {
+		m  map[string]interface{}
+		r  interface{}
+		r2 interface{}
+	}{
+		{
+			m:  map[string]interface{}{"a": 1, "b": "x"},
+			r:  float64(1),
+			r2: nil,
+		}, {
+			m:  map[string]interface{}{"a": 3, "b": "x"},
+			r:  float64(2),
+			r2: float64(3),
+		}, {
+			m:  map[string]interface{}{"a": 10, "b": "y"},
+			r:  float64(10),
+			r2: 6.5,
+		}, {
+			m:  map[string]interface{}{"a": 5, "b": "x"},
+			r:  float64(3),
+			r2: float64(6),
+		},
+	}
+	fv, _ := NewFunctionValuersForOp(newIncAggTestCtx())
+	for i, tt := range tests {
+		tuple := &Tuple{Emitter: "demo", Message: tt.m}
+		ve := &ValuerEval{Valuer: MultiValuer(tuple, fv)}
+		assert.Equal(t, tt.r, ve.Eval(stmt.Fields[0].Expr), "case %d", i)
+		assert.Equal(t, tt.r2, ve.Eval(stmt.Fields[1].Expr), "case %d", i)
+	}
+}
@@ -140,6 +140,12 @@ func (s *Scanner) Scan() (tok ast.Token, lit string) {
 		return ast.LBRACKET, ast.Tokens[ast.LBRACKET]
 	case ']':
 		return ast.RBRACKET, ast.Tokens[ast.RBRACKET]
+	case '{':
+		return ast.LBRACE, ast.Tokens[ast.LBRACE]
+	case '}':
+		return ast.RBRACE, ast.Tokens[ast.RBRACE]
+	case '?':
+		return ast.QUESTION, ast.Tokens[ast.QUESTION]
 	case ':':
 		return ast.COLON, ast.Tokens[ast.COLON]
 	case '#':
@@ -165,6 +165,12 @@ func (p *Parser) Parse() (*ast.SelectStatement, error) {
 	} else {
 		selects.Sources = src
 	}
+	p.clause = "match_recognize"
+	if mr, err := p.parseMatchRecognize(); err != nil {
+		return nil, err
+	} else {
+		selects.MatchRecognize = mr
+	}
 	p.clause = "join"
 	if joins, err := p.parseJoins(); err != nil {
 		return nil, err
@@ -247,7 +253,7 @@ func (p *Parser) parseSourceLiteral() (string, string, error) {
 	var alias string
 	for {
 		// HASH, DIV & ADD token is specially support for MQTT topic name patterns.
-		if tok, lit := p.scanIgnoreWhitespace(); tok.AllowedSourceToken() {
+		if tok, lit := p.scanIgnoreWhitespace(); tok.AllowedSourceToken() && !isMatchRecognize(tok, lit) {
 			sourceSeg = append(sourceSeg, lit)
 			if tok1, lit1 := p.scanIgnoreWhitespace(); tok1 == ast.AS {
 				if tok2, lit2 := p.scanIgnoreWhitespace(); tok2 == ast.IDENT {
@@ -255,7 +261,7 @@ func (p *Parser) parseSourceLiteral() (string, string, error) {
 				} else {
 					return "", "", fmt.Errorf("found %q, expected JOIN key word.", lit)
 				}
-			} else if tok1.AllowedSourceToken() {
+			} else if tok1.AllowedSourceToken() && !isMatchRecognize(tok1, lit1) {
 				sourceSeg = append(sourceSeg, lit1)
 			} else {
 				p.unscan()
@@ -269,6 +275,195 @@ func (p *Parser) parseSourceLiteral() (string, string, error) {
 	return strings.Join(sourceSeg, ""), alias, nil
 }
 
+const (
+	matchRecognizeKeyword = "MATCH_RECOGNIZE"
+	measuresKeyword       = "MEASURES"
+	patternKeyword        = "PATTERN"
+	withinKeyword         = "WITHIN"
+	defineKeyword         = "DEFINE"
+)
+
+// The clause keywords of MATCH_RECOGNIZE are not reserved words, so they are scanned as identifiers
+func isMatchRecognize(tok ast.Token, lit string) bool {
+	return tok == ast.IDENT && strings.EqualFold(lit, matchRecognizeKeyword)
+}
+
+func (p *Parser) scanKeyword(keyword string) bool {
+	if tok, lit := p.scanIgnoreWhitespace(); tok == ast.IDENT && strings.EqualFold(lit, keyword) {
+		return true
+	}
+	p.unscan()
+	return false
+}
+
+// parseMatchRecognize parses the clause like
+// MATCH_RECOGNIZE ( [PARTITION BY expr, ...] [MEASURES expr AS alias, ...] PATTERN (A B{3} C+) [WITHIN 10 SS] [DEFINE A AS condition, ...] )
+func (p *Parser) parseMatchRecognize() (*ast.MatchRecognize, error) {
+	if !p.scanKeyword(matchRecognizeKeyword) {
+		return nil, nil
+	}
+	if tok, lit := p.scanIgnoreWhitespace(); tok != ast.LPAREN {
+		return nil, fmt.Errorf("found %q, expected ( after MATCH_RECOGNIZE.", lit)
+	}
+	mr := &ast.MatchRecognize{}
+	if tok, _ := p.scanIgnoreWhitespace(); tok == ast.PARTITION {
+		if tok1, lit1 := p.scanIgnoreWhitespace(); tok1 != ast.BY {
+			return nil, fmt.Errorf("found %q, expected by after partition.", lit1)
+		}
+		for {
+			exp, err := p.ParseExpr()
+			if err != nil {
+				return nil, err
+			}
+			mr.PartitionBy = append(mr.PartitionBy, exp)
+			if tok, _ := p.scanIgnoreWhitespace(); tok != ast.COMMA {
+				p.unscan()
+				break
+			}
+		}
+	} else {
+		p.unscan()
+	}
+	if p.scanKeyword(measuresKeyword) {
+		for {
+			field, err := p.parseField()
+			if err != nil {
+				return nil, err
+			}
+			if field.AName == "" {
+				return nil, fmt.Errorf("the measure %s must have an alias", field.Expr)
+			}
+			mr.Measures = append(mr.Measures, *field)
+			if tok, _ := p.scanIgnoreWhitespace(); tok != ast.COMMA {
+				p.unscan()
+				break
+			}
+		}
+	}
+	if !p.scanKeyword(patternKeyword) {
+		tok, lit := p.scanIgnoreWhitespace()
+		if tok == ast.RPAREN {
+			lit = ")"
+		}
+		return nil, fmt.Errorf("found %q, expected PATTERN in MATCH_RECOGNIZE.", lit)
+	}
+	terms, err := p.parsePattern()
+	if err != nil {
+		return nil, err
+	}
+	mr.Pattern = terms
+	if p.scanKeyword(withinKeyword) {
+		tok, lit := p.scanIgnoreWhitespace()
+		if tok != ast.INTEGER {
+			return nil, fmt.Errorf("found %q, expected integer after WITHIN.", lit)
+		}
+		v, err := strconv.Atoi(lit)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("invalid WITHIN value %s, expected positive integer", lit)
+		}
+		mr.Within = &ast.IntegerLiteral{Val: v}
+		if tok, lit := p.scanIgnoreWhitespace(); !tok.IsTimeLiteral() {
+			return nil, fmt.Errorf("found %q, expected time unit DD, HH, MI, SS or MS after WITHIN %d.", lit, v)
+		} else {
+			mr.WithinUnit = &ast.TimeLiteral{Val: tok}
+		}
+	}
+	if p.scanKeyword(defineKeyword) {
+		for {
+			tok, lit := p.scanIgnoreWhitespace()
+			if tok != ast.IDENT {
+				return nil, fmt.Errorf("found %q, expected pattern variable in DEFINE.", lit)
+			}
+			if tok1, lit1 := p.scanIgnoreWhitespace(); tok1 != ast.AS {
+				return nil, fmt.Errorf("found %q, expected AS after pattern variable %s.", lit1, lit)
+			}
+			exp, err := p.ParseExpr()
+			if err != nil {
+				return nil, err
+			}
+			mr.Defines = append(mr.Defines, &ast.PatternDefine{Var: lit, Condition: exp})
+			if tok, _ := p.scanIgnoreWhitespace(); tok != ast.COMMA {
+				p.unscan()
+				break
+			}
+		}
+	}
+	if tok, lit := p.scanIgnoreWhitespace(); tok != ast.RPAREN {
+		return nil, fmt.Errorf("found %q, expected ) to close MATCH_RECOGNIZE.", lit)
+	}
+	if err := validateMatchRecognize(mr); err != nil {
+		return nil, err
+	}
+	return mr, nil
+}
+
+// parsePattern parses the sequence of pattern variables with quantifiers like (A B{3} C+ D? E* F{2,} G{1,3})
+func (p *Parser) parsePattern() ([]*ast.PatternTerm, error) {
+	if tok, lit := p.scanIgnoreWhitespace(); tok != ast.LPAREN {
+		return nil, fmt.Errorf("found %q, expected ( after PATTERN.", lit)
+	}
+	var terms []*ast.PatternTerm
+	for {
+		tok, lit := p.scanIgnoreWhitespace()
+		if tok == ast.RPAREN {
+			break
+		}
+		if tok != ast.IDENT {
+			return nil, fmt.Errorf("found %q, expected pattern variable in PATTERN.", lit)
+		}
+		term := &ast.PatternTerm{Var: lit, Min: 1, Max: 1}
+		switch tok1, _ := p.scanIgnoreWhitespace(); tok1 {
+		case ast.ADD:
+			term.Min, term.Max = 1, -1
+		case ast.ASTERISK:
+			term.Min, term.Max = 0, -1
+		case ast.QUESTION:
+			term.Min, term.Max = 0, 1
+		case ast.LBRACE:
+			if err := p.parseQuantifier(term); err != nil {
+				return nil, err
+			}
+		default:
+			p.unscan()
+		}
+		terms = append(terms, term)
+	}
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("PATTERN must have at least one pattern variable")
+	}
+	return terms, nil
+}
+
+// parseQuantifier parses the quantifier like {n}, {n,}, {,m} and {n,m} after the left brace
+func (p *Parser) parseQuantifier(term *ast.PatternTerm) error {
+	term.Min, term.Max = 0, -1
+	tok, lit := p.scanIgnoreWhitespace()
+	if tok == ast.INTEGER {
+		term.Min, _ = strconv.Atoi(lit)
+		tok, lit = p.scanIgnoreWhitespace()
+		if tok == ast.RBRACE {
+			term.Max = term.Min
+		}
+	}
+	if tok != ast.RBRACE {
+		if tok != ast.COMMA {
+			return fmt.Errorf("found %q, expected , or } in the quantifier of %s.", lit, term.Var)
+		}
+		tok, lit = p.scanIgnoreWhitespace()
+		if tok == ast.INTEGER {
+			term.Max, _ = strconv.Atoi(lit)
+			tok, lit = p.scanIgnoreWhitespace()
+		}
+		if tok != ast.RBRACE {
+			return fmt.Errorf("found %q, expected } in the quantifier of %s.", lit, term.Var)
+		}
+	}
+	if term.Max == 0 || (term.Max > 0 && term.Min > term.Max) {
+		return fmt.Errorf("invalid quantifier {%d,%d} of %s", term.Min, term.Max, term.Var)
+	}
+	return nil
+}
+
 func (p *Parser) parseFieldNameSections(isSubField bool) ([]string, error) {
 	var fieldNameSects []string
 	for {
@@ -623,7 +818,7 @@ func (p *Parser) ParseExpr() (ast.Expr, error) {
 }
 
 func (p *Parser) parseBetween(lhs ast.Expr, op ast.Token) (ast.Expr, error) {
-	alhs, err := p.parseUnaryExpr(false)
+	alhs, err := p.parseBetweenBound()
 	if err != nil {
 		return nil, err
 	}
@@ -631,7 +826,7 @@ func (p *Parser) parseBetween(lhs ast.Expr, op ast.Token) (ast.Expr, error) {
 	if opp != ast.AND {
 		return nil, fmt.Errorf("expect AND expression after between but found %s", opp)
 	}
-	arhs, err := p.parseUnaryExpr(false)
+	arhs, err := p.parseBetweenBound()
 	if err != nil {
 		return nil, err
 	}
@@ -645,6 +840,41 @@ func (p *Parser) parseBetween(lhs ast.Expr, op ast.Token) (ast.Expr, error) {
 	}, nil
 }
 
+// parseBetweenBound parses the bound of BETWEEN which can be an arithmetic expression such as `ts + 1000`.
+// The AND and the operators with lower precedence are not included so that the AND of BETWEEN is not consumed.
+func (p *Parser) parseBetweenBound() (ast.Expr, error) {
+	var err error
+	root := &ast.BinaryExpr{}
+	root.RHS, err = p.parseUnaryExpr(false)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, _ := p.scanIgnoreWhitespace()
+		if op == ast.ASTERISK {
+			op = ast.MUL
+		}
+		switch op {
+		case ast.ADD, ast.SUB, ast.MUL, ast.DIV, ast.MOD, ast.BITWISE_AND, ast.BITWISE_OR, ast.BITWISE_XOR:
+		default:
+			p.unscan()
+			return root.RHS, nil
+		}
+		rhs, err := p.parseUnaryExpr(false)
+		if err != nil {
+			return nil, err
+		}
+		for node := root; ; {
+			r, ok := node.RHS.(*ast.BinaryExpr)
+			if !ok || r.OP.Precedence() >= op.Precedence() {
+				node.RHS = &ast.BinaryExpr{LHS: node.RHS, RHS: rhs, OP: op}
+				break
+			}
+			node = r
+		}
+	}
+}
+
 func (p *Parser) parseUnaryExpr(isSubField bool) (ast.Expr, error) {
 	if tok1, _ := p.scanIgnoreWhitespace(); tok1 == ast.LPAREN {
 		expr, err := p.ParseExpr()
@@ -880,6 +1110,10 @@ func convFuncName(n string) (string, bool) {
 }
 
 func (p *Parser) parseCall(n string) (ast.Expr, error) {
+	// The navigation functions are only available in MATCH_RECOGNIZE
+	if p.clause == "match_recognize" && IsMatchNavFunc(n) {
+		return p.parseMatchNavCall(strings.ToLower(n))
+	}
 	// Check if n function exists and convert it to lowercase for built-in func
 	name, ok := convFuncName(n)
 	if !ok {
@@ -927,6 +1161,10 @@ func (p *Parser) parseCall(n string) (ast.Expr, error) {
 		c := &ast.Call{Name: name, Args: args, FuncId: p.fn, FuncType: ft}
 		p.fn += 1
 		e := p.parseOver(c)
+		// The incremental aggregate function with OVER clause accumulates row by row like the analytic functions
+		if e == nil && ft == ast.FuncTypeAgg && function.IsAnalyticCall(c) {
+			c.FuncType = ast.FuncTypeScalar
+		}
 		return c, e
 	} else {
 		if err != nil {
@@ -955,6 +1193,33 @@ func (p *Parser) parseCall(n string) (ast.Expr, error) {
 	}
 }
 
+func (p *Parser) parseMatchNavCall(name string) (ast.Expr, error) {
+	var args []ast.Expr
+	for {
+		if tok, _ := p.scanIgnoreWhitespace(); tok == ast.RPAREN {
+			break
+		}
+		p.unscan()
+		exp, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, exp)
+		if tok, lit := p.scanIgnoreWhitespace(); tok != ast.COMMA {
+			if tok != ast.RPAREN {
+				return nil, fmt.Errorf("found function call %q, expected ), but with %q.", name, lit)
+			}
+			break
+		}
+	}
+	if err := validateMatchNavFunc(name, args); err != nil {
+		return nil, err
+	}
+	c := &ast.Call{Name: name, Args: args, FuncId: p.fn, FuncType: ast.FuncTypeScalar}
+	p.fn += 1
+	return c, nil
+}
+
 func (p *Parser) parseCaseExpr() (*ast.CaseExpr, error) {
 	c := &ast.CaseExpr{}
 	tok, _ := p.scanIgnoreWhitespace()
@@ -1668,7 +1933,7 @@ func (p *Parser) parseOver(c *ast.Call) error {
 	if tok, _ := p.scanIgnoreWhitespace(); tok != ast.OVER {
 		p.unscan()
 		return nil
-	} else if function.IsAnalyticFunc(c.Name) || function.IsWindowFunc(c.Name) {
+	} else if function.IsAnalyticFunc(c.Name) || function.IsWindowFunc(c.Name) || function.IsIncAggFunc(c.Name) {
 		if tok1, _ := p.scanIgnoreWhitespace(); tok1 == ast.LPAREN {
 			if t, _ := p.scanIgnoreWhitespace(); t == ast.PARTITION {
 				if t1, l1 := p.scanIgnoreWhitespace(); t1 == ast.BY {
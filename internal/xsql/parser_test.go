@@ -1415,6 +1415,47 @@ func TestParser_ParseStatement(t *testing.T) {
 				},
 			},
 		},
+		{
+			s: `SELECT a FROM tbl WHERE f1 BETWEEN b - 2 * 3 AND b + 2 AND f3 > 4`,
+			stmt: &ast.SelectStatement{
+				Fields: []ast.Field{
+					{
+						AName: "",
+						Name:  "a",
+						Expr:  &ast.FieldRef{Name: "a", StreamName: ast.DefaultStream},
+					},
+				},
+				Sources: []ast.Source{&ast.Table{Name: "tbl"}},
+				Condition: &ast.BinaryExpr{
+					OP: ast.AND,
+					LHS: &ast.BinaryExpr{
+						LHS: &ast.FieldRef{Name: "f1", StreamName: ast.DefaultStream},
+						OP:  ast.BETWEEN,
+						RHS: &ast.BetweenExpr{
+							Lower: &ast.BinaryExpr{
+								OP:  ast.SUB,
+								LHS: &ast.FieldRef{Name: "b", StreamName: ast.DefaultStream},
+								RHS: &ast.BinaryExpr{
+									OP:  ast.MUL,
+									LHS: &ast.IntegerLiteral{Val: 2},
+									RHS: &ast.IntegerLiteral{Val: 3},
+								},
+							},
+							Higher: &ast.BinaryExpr{
+								OP:  ast.ADD,
+								LHS: &ast.FieldRef{Name: "b", StreamName: ast.DefaultStream},
+								RHS: &ast.IntegerLiteral{Val: 2},
+							},
+						},
+					},
+					RHS: &ast.BinaryExpr{
+						OP:  ast.GT,
+						LHS: &ast.FieldRef{Name: "f3", StreamName: ast.DefaultStream},
+						RHS: &ast.IntegerLiteral{Val: 4},
+					},
+				},
+			},
+		},
 		{
 			s:   `SELECT a FROM tbl WHERE f1 NOT BETWEEN b`,
 			err: "expect AND expression after between but found EOF",
@@ -4415,6 +4456,151 @@ func TestParser_ParseJoins(t *testing.T) {
 	}
 }
 
+func TestParser_ParseMatchRecognize(t *testing.T) {
+	tests := []struct {
+		s    string
+		stmt *ast.SelectStatement
+		err  string
+	}{
+		{
+			s: `SELECT * FROM demo MATCH_RECOGNIZE (PARTITION BY deviceId MEASURES FIRST(A.temp) AS startTemp, B.ts AS doorTs PATTERN (A{3} C* B) WITHIN 10 SS DEFINE A AS temp > PREV(temp), B AS door = 'open')`,
+			stmt: &ast.SelectStatement{
+				Fields: []ast.Field{
+					{
+						Expr:  &ast.Wildcard{Token: ast.ASTERISK},
+						Name:  "*",
+						AName: "",
+					},
+				},
+				Sources: []ast.Source{&ast.Table{Name: "demo"}},
+				MatchRecognize: &ast.MatchRecognize{
+					PartitionBy: []ast.Expr{&ast.FieldRef{Name: "deviceId", StreamName: ast.DefaultStream}},
+					Measures: []ast.Field{
+						{
+							Name:  "first",
+							AName: "startTemp",
+							Expr: &ast.Call{
+								Name:     "first",
+								FuncId:   0,
+								FuncType: ast.FuncTypeScalar,
+								Args:     []ast.Expr{&ast.FieldRef{Name: "temp", StreamName: "A"}},
+							},
+						},
+						{
+							Name:  "ts",
+							AName: "doorTs",
+							Expr:  &ast.FieldRef{Name: "ts", StreamName: "B"},
+						},
+					},
+					Pattern: []*ast.PatternTerm{
+						{Var: "A", Min: 3, Max: 3},
+						{Var: "C", Min: 0, Max: -1},
+						{Var: "B", Min: 1, Max: 1},
+					},
+					Within:     &ast.IntegerLiteral{Val: 10},
+					WithinUnit: &ast.TimeLiteral{Val: ast.SS},
+					Defines: []*ast.PatternDefine{
+						{
+							Var: "A",
+							Condition: &ast.BinaryExpr{
+								OP:  ast.GT,
+								LHS: &ast.FieldRef{Name: "temp", StreamName: ast.DefaultStream},
+								RHS: &ast.Call{
+									Name:     "prev",
+									FuncId:   1,
+									FuncType: ast.FuncTypeScalar,
+									Args:     []ast.Expr{&ast.FieldRef{Name: "temp", StreamName: ast.DefaultStream}},
+								},
+							},
+						},
+						{
+							Var: "B",
+							Condition: &ast.BinaryExpr{
+								OP:  ast.EQ,
+								LHS: &ast.FieldRef{Name: "door", StreamName: ast.DefaultStream},
+								RHS: &ast.StringLiteral{Val: "open"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			s: `SELECT a FROM demo AS d MATCH_RECOGNIZE (PATTERN (A+ B? C{2,} D{,3} E{1,2})) WHERE a > 1`,
+			stmt: &ast.SelectStatement{
+				Fields: []ast.Field{
+					{
+						Expr:  &ast.FieldRef{Name: "a", StreamName: ast.DefaultStream},
+						Name:  "a",
+						AName: "",
+					},
+				},
+				Sources: []ast.Source{&ast.Table{Name: "demo", Alias: "d"}},
+				MatchRecognize: &ast.MatchRecognize{
+					Pattern: []*ast.PatternTerm{
+						{Var: "A", Min: 1, Max: -1},
+						{Var: "B", Min: 0, Max: 1},
+						{Var: "C", Min: 2, Max: -1},
+						{Var: "D", Min: 0, Max: 3},
+						{Var: "E", Min: 1, Max: 2},
+					},
+				},
+				Condition: &ast.BinaryExpr{
+					OP:  ast.GT,
+					LHS: &ast.FieldRef{Name: "a", StreamName: ast.DefaultStream},
+					RHS: &ast.IntegerLiteral{Val: 1},
+				},
+			},
+		},
+		{
+			s:   `SELECT * FROM demo MATCH_RECOGNIZE (MEASURES A.temp PATTERN (A))`,
+			err: "the measure A.temp must have an alias",
+		},
+		{
+			s:   `SELECT * FROM demo MATCH_RECOGNIZE (MEASURES A.temp AS t)`,
+			err: "found \")\", expected PATTERN in MATCH_RECOGNIZE.",
+		},
+		{
+			s:   `SELECT * FROM demo MATCH_RECOGNIZE (PATTERN ())`,
+			err: "PATTERN must have at least one pattern variable",
+		},
+		{
+			s:   `SELECT * FROM demo MATCH_RECOGNIZE (PATTERN (A{3,1}))`,
+			err: "invalid quantifier {3,1} of A",
+		},
+		{
+			s:   `SELECT * FROM demo MATCH_RECOGNIZE (PATTERN (A) WITHIN 10)`,
+			err: "found \")\", expected time unit DD, HH, MI, SS or MS after WITHIN 10.",
+		},
+		{
+			s:   `SELECT * FROM demo MATCH_RECOGNIZE (PATTERN (A) DEFINE B AS temp > 1)`,
+			err: "pattern variable B is defined but not used in PATTERN",
+		},
+		{
+			s:   `SELECT * FROM demo MATCH_RECOGNIZE (PATTERN (A) DEFINE A AS temp > PREV(temp, a))`,
+			err: "The 2nd argument for prev should be a positive integer.",
+		},
+		{
+			s:   `SELECT * FROM demo MATCH_RECOGNIZE (MEASURES avg(A.temp) AS t PATTERN (A))`,
+			err: "Not allowed to call aggregate functions in MEASURES clause.",
+		},
+		{
+			s:   `SELECT prev(a) FROM demo`,
+			err: "function prev not found",
+		},
+	}
+
+	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
+	for i, tt := range tests {
+		stmt, err := NewParser(strings.NewReader(tt.s)).Parse()
+		if !reflect.DeepEqual(tt.err, testx.Errstring(err)) {
+			t.Errorf("%d. %q: error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.s, tt.err, err)
+		} else if tt.err == "" && !reflect.DeepEqual(tt.stmt, stmt) {
+			t.Errorf("%d. %q\n\nstmt mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", i, tt.s, tt.stmt, stmt)
+		}
+	}
+}
+
 func TestParser_ParseStatements(t *testing.T) {
 	tests := []struct {
 		s     string
@@ -270,6 +270,10 @@ func (jt *JoinTuple) AggregateEval(expr ast.Expr, v CallValuer) []interface{} {
 	return []interface{}{Eval(expr, MultiValuer(jt, v, &WildcardValuer{jt}))}
 }
 
+func (jt *JoinTuple) AggregateRange(v CallValuer, f func(rv Valuer) error) error {
+	return f(MultiValuer(jt, v, &WildcardValuer{jt}))
+}
+
 var _ TupleRow = &JoinTuple{}
 
 // GroupedTuples is a collection of tuples grouped by a key
@@ -404,6 +408,10 @@ func (t *Tuple) AggregateEval(expr ast.Expr, v CallValuer) []interface{} {
 	return []interface{}{Eval(expr, MultiValuer(t, v, &WildcardValuer{t}))}
 }
 
+func (t *Tuple) AggregateRange(v CallValuer, f func(rv Valuer) error) error {
+	return f(MultiValuer(t, v, &WildcardValuer{t}))
+}
+
 func (t *Tuple) GetTimestamp() int64 {
 	return t.Timestamp
 }
@@ -591,6 +599,15 @@ func (s *GroupedTuples) AggregateEval(expr ast.Expr, v CallValuer) []interface{}
 	return result
 }
 
+func (s *GroupedTuples) AggregateRange(v CallValuer, f func(rv Valuer) error) error {
+	for _, t := range s.Content {
+		if err := f(MultiValuer(t, &WindowRangeValuer{WindowRange: s.WindowRange}, v, &WildcardValuer{t})); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *GroupedTuples) Value(key, table string) (interface{}, bool) {
 	r, ok := s.AffiliateRow.Value(key, table)
 	if ok {
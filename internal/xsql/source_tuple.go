@@ -22,6 +22,8 @@ import (
 
 type ErrorSourceTuple struct {
 	Error error `json:"error"`
+	// Payload is the original data which fails to decode. It is nil if the error is not related to a message
+	Payload []byte `json:"payload,omitempty"`
 }
 
 func (t *ErrorSourceTuple) Message() map[string]interface{} {
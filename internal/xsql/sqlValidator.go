@@ -16,6 +16,7 @@ package xsql
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/lf-edge/ekuiper/pkg/ast"
 )
@@ -45,6 +46,74 @@ func Validate(stmt *ast.SelectStatement) error {
 	return validateSRFForbidden(stmt)
 }
 
+var matchNavFuncs = map[string]struct{}{
+	"prev":  {},
+	"first": {},
+	"last":  {},
+}
+
+// IsMatchNavFunc checks if the function is the navigation function of MATCH_RECOGNIZE to access the other rows
+func IsMatchNavFunc(name string) bool {
+	_, ok := matchNavFuncs[strings.ToLower(name)]
+	return ok
+}
+
+func validateMatchNavFunc(name string, args []ast.Expr) error {
+	switch name {
+	case "prev":
+		if len(args) != 1 && len(args) != 2 {
+			return fmt.Errorf("The arguments for %s should be 1 or 2.", name)
+		}
+		if len(args) == 2 {
+			if offset, ok := args[1].(*ast.IntegerLiteral); !ok || offset.Val <= 0 {
+				return fmt.Errorf("The 2nd argument for %s should be a positive integer.", name)
+			}
+		}
+	default:
+		if len(args) != 1 {
+			return fmt.Errorf("The arguments for %s should be 1.", name)
+		}
+	}
+	return nil
+}
+
+// validateMatchRecognize validates the MATCH_RECOGNIZE clause without the stream context
+func validateMatchRecognize(mr *ast.MatchRecognize) error {
+	vars := make(map[string]struct{}, len(mr.Pattern))
+	for _, t := range mr.Pattern {
+		vars[t.Var] = struct{}{}
+	}
+	defined := make(map[string]struct{}, len(mr.Defines))
+	for _, d := range mr.Defines {
+		if _, ok := vars[d.Var]; !ok {
+			return fmt.Errorf("pattern variable %s is defined but not used in PATTERN", d.Var)
+		}
+		if _, ok := defined[d.Var]; ok {
+			return fmt.Errorf("pattern variable %s is defined more than once", d.Var)
+		}
+		defined[d.Var] = struct{}{}
+		if HasAggFuncs(d.Condition) {
+			return fmt.Errorf("Not allowed to call aggregate functions in DEFINE clause.")
+		}
+	}
+	for _, e := range mr.PartitionBy {
+		if HasAggFuncs(e) {
+			return fmt.Errorf("Not allowed to call aggregate functions in PARTITION BY clause.")
+		}
+	}
+	names := make(map[string]struct{}, len(mr.Measures))
+	for _, m := range mr.Measures {
+		if _, ok := names[m.AName]; ok {
+			return fmt.Errorf("duplicate measure %s", m.AName)
+		}
+		names[m.AName] = struct{}{}
+		if HasAggFuncs(m.Expr) {
+			return fmt.Errorf("Not allowed to call aggregate functions in MEASURES clause.")
+		}
+	}
+	return nil
+}
+
 func validateWindowFunction(stmt *ast.SelectStatement) error {
 	if exists := isWindowFunctionExists(stmt); exists {
 		return fmt.Errorf("window functions can only be in select fields")
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/lf-edge/ekuiper/internal/binder/function"
+	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/ast"
 	"github.com/lf-edge/ekuiper/pkg/cast"
 )
@@ -80,6 +81,17 @@ type AggregateCallValuer interface {
 	GetSingleCallValuer() CallValuer
 }
 
+// IncAggFuncValuer gets the instance of the aggregate function which can be calculated incrementally
+type IncAggFuncValuer interface {
+	IncAggFunc(name string, funcId int) (api.AggregateFunction, api.FunctionContext, bool)
+}
+
+// BatchAccumulator is implemented by the incremental aggregate functions which can accumulate the rows in one call,
+// such as the portable plugin functions which call the plugin process once for all the rows instead of once per row
+type BatchAccumulator interface {
+	AccumulateBatch(ctx api.FunctionContext, acc interface{}, rows [][]interface{}) (interface{}, error)
+}
+
 type WildcardValuer struct {
 	Data Wildcarder
 }
@@ -152,6 +164,17 @@ func (a multiValuer) FuncValue(key string) (interface{}, bool) {
 	return nil, false
 }
 
+func (a multiValuer) IncAggFunc(name string, funcId int) (api.AggregateFunction, api.FunctionContext, bool) {
+	for _, valuer := range a {
+		if vv, ok := valuer.(IncAggFuncValuer); ok {
+			if f, fctx, ok := vv.IncAggFunc(name, funcId); ok {
+				return f, fctx, true
+			}
+		}
+	}
+	return nil, nil, false
+}
+
 func (a multiValuer) Call(name string, funcId int, args []interface{}) (interface{}, bool) {
 	for _, valuer := range a {
 		if valuer, ok := valuer.(CallValuer); ok {
@@ -325,6 +348,11 @@ func (v *ValuerEval) Eval(expr ast.Expr) interface{} {
 					val, _ := valuer.Call(expr.Name, expr.FuncId, args)
 					return val
 				}
+				if ft == ast.FuncTypeAgg {
+					if r, ok := evalIncAgg(expr, valuer); ok {
+						return r
+					}
+				}
 				if len(expr.Args) > 0 {
 					switch ft {
 					case ast.FuncTypeAgg:
@@ -380,7 +408,7 @@ func (v *ValuerEval) Eval(expr ast.Expr) interface{} {
 						return fmt.Errorf("unknown function type")
 					}
 				}
-				if function.IsAnalyticFunc(expr.Name) {
+				if function.IsAnalyticCall(expr) {
 					// this data should be recorded or not ? default answer is yes
 					if expr.WhenExpr != nil {
 						validData := true
@@ -409,6 +437,9 @@ func (v *ValuerEval) Eval(expr ast.Expr) interface{} {
 					} else {
 						args = append(args, "self")
 					}
+					if !function.IsAnalyticFunc(expr.Name) {
+						return evalAnalyticAgg(expr, valuer, args)
+					}
 				}
 				val, _ := valuer.Call(expr.Name, expr.FuncId, args)
 				return val
@@ -107,6 +107,24 @@ type LookupSource interface {
 	Closable
 }
 
+// BatchLookupSource is a lookup source which can look up many key values in one query. It is optional, the lookup
+// node uses it to look up the rows of a window together instead of querying row by row.
+type BatchLookupSource interface {
+	LookupSource
+	// LookupBatch receives a list of lookup values, each has the same length as the keys, and returns the query
+	// results of each lookup values in the same order
+	LookupBatch(ctx StreamContext, fields []string, keys []string, values [][]interface{}) ([][]SourceTuple, error)
+}
+
+// PreloadLookupSource is a lookup source which can read the whole table. It is optional, the lookup node uses it to
+// preload the lookup cache when the rule starts.
+type PreloadLookupSource interface {
+	LookupSource
+	// Preload reads all the rows of the table and groups them by the values of the keys. It returns the distinct key
+	// values and the rows of each key values in the same order.
+	Preload(ctx StreamContext, fields []string, keys []string) ([][]interface{}, [][]SourceTuple, error)
+}
+
 type Sink interface {
 	// Open Should be sync function for normal case. The container will run it in go func
 	Open(ctx StreamContext) error
@@ -123,6 +141,23 @@ type ResendSink interface {
 	CollectResend(ctx StreamContext, data interface{}) error
 }
 
+// TwoPhaseCommitSink is a sink which supports end-to-end exactly once by two-phase commit.
+// It is only used when the rule qos is exactly once. The data collected between two checkpoints
+// belongs to one transaction which is identified by the checkpoint id when pre-committing.
+type TwoPhaseCommitSink interface {
+	Sink
+	// Recover Called after Open. The pending are the transactions which are pre-committed in the restored checkpoint.
+	// The sink must commit them and discard all the other unfinished transactions.
+	Recover(ctx StreamContext, pending []int64) error
+	// PreCommit Called when the checkpoint barrier arrives. It must make the current transaction durable but invisible
+	// and start a new transaction for the following data.
+	PreCommit(ctx StreamContext, checkpointId int64) error
+	// Commit Called when the checkpoint is completed. It must be idempotent.
+	Commit(ctx StreamContext, checkpointId int64) error
+	// Abort Called when pre-commit fails or the rule stops. It discards the current transaction which is not pre-committed.
+	Abort(ctx StreamContext) error
+}
+
 type Emitter interface {
 	AddOutput(chan<- interface{}, string) error
 }
@@ -140,6 +175,35 @@ type Rewindable interface {
 	Rewind(offset interface{}) error
 }
 
+// OffsetCommitter is an optional interface for the Rewindable source to commit the offset to the external system,
+// such as the consumer group offset of Kafka. The offset is committed after the checkpoint including it is completed.
+type OffsetCommitter interface {
+	Rewindable
+	CommitOffset(ctx StreamContext, offset interface{}) error
+}
+
+// OffsetSourceTuple is an optional interface for the tuple of a Rewindable source to carry the source offset after
+// consuming it. The source node saves the carried offset instead of calling GetOffset, so that the saved offset always
+// matches the processed data even if the source has read ahead.
+type OffsetSourceTuple interface {
+	SourceTuple
+	Offset() interface{}
+}
+
+type offsetSourceTuple struct {
+	SourceTuple
+	offset interface{}
+}
+
+func (t *offsetSourceTuple) Offset() interface{} {
+	return t.offset
+}
+
+// NewOffsetSourceTuple wraps the source tuple with the offset after consuming it
+func NewOffsetSourceTuple(tuple SourceTuple, offset interface{}) OffsetSourceTuple {
+	return &offsetSourceTuple{SourceTuple: tuple, offset: offset}
+}
+
 type RuleOption struct {
 	Debug              bool             `json:"debug" yaml:"debug"`
 	LogFilename        string           `json:"logFilename" yaml:"logFilename"`
@@ -155,6 +219,9 @@ type RuleOption struct {
 	Cron               string           `json:"cron" yaml:"cron"`
 	Duration           string           `json:"duration" yaml:"duration"`
 	CronDatetimeRange  []DatetimeRange  `json:"cronDatetimeRange" yaml:"cronDatetimeRange"`
+	KeyedWindow        bool             `json:"keyedWindow" yaml:"keyedWindow"`
+	// DeadLetter is the sink action like {"memory": {"topic": "dlq"}} to receive the failed records
+	DeadLetter map[string]interface{} `json:"deadLetter,omitempty" yaml:"deadLetter"`
 }
 
 type DatetimeRange struct {
@@ -205,6 +272,9 @@ type Rule struct {
 	Graph     *RuleGraph               `json:"graph,omitempty"`
 	Actions   []map[string]interface{} `json:"actions,omitempty"`
 	Options   *RuleOption              `json:"options,omitempty"`
+	// StateMapping maps the operator ids of the rule to the operator ids of the previous version whose states are
+	// carried over when updating the rule. Operators with the same id are mapped implicitly.
+	StateMapping map[string]string `json:"stateMapping,omitempty"`
 }
 
 func (r *Rule) IsLongRunningScheduleRule() bool {
@@ -277,6 +347,25 @@ type Function interface {
 	IsAggregate() bool
 }
 
+// AggregateFunction is an optional interface for the aggregate Function to calculate incrementally.
+// Instead of receiving the whole group as slices in Exec, the rows are accumulated into an accumulator one by one.
+// The accumulator must be serializable by encoding/json because it is saved in the state of the rule.
+type AggregateFunction interface {
+	Function
+	// IsIncremental If the incremental methods are supported. If false, the function is calculated by Exec.
+	IsIncremental() bool
+	// Init returns a new accumulator of an empty group
+	Init(ctx FunctionContext) (interface{}, error)
+	// Accumulate adds the arguments of a row into the accumulator and returns the updated accumulator
+	Accumulate(ctx FunctionContext, acc interface{}, args []interface{}) (interface{}, error)
+	// Retract removes the arguments of a row, which is accumulated before, from the accumulator
+	Retract(ctx FunctionContext, acc interface{}, args []interface{}) (interface{}, error)
+	// Merge merges the accumulators of two partial groups into one
+	Merge(ctx FunctionContext, acc1 interface{}, acc2 interface{}) (interface{}, error)
+	// Result returns the aggregation result of the accumulator
+	Result(ctx FunctionContext, acc interface{}) (interface{}, error)
+}
+
 const (
 	AtMostOnce Qos = iota
 	AtLeastOnce
@@ -22,14 +22,15 @@ type Statement interface {
 }
 
 type SelectStatement struct {
-	Fields     Fields
-	Sources    Sources
-	Joins      Joins
-	Condition  Expr
-	Limit      Expr
-	Dimensions Dimensions
-	Having     Expr
-	SortFields SortFields
+	Fields         Fields
+	Sources        Sources
+	MatchRecognize *MatchRecognize
+	Joins          Joins
+	Condition      Expr
+	Limit          Expr
+	Dimensions     Dimensions
+	Having         Expr
+	SortFields     SortFields
 
 	Statement
 }
@@ -151,6 +152,33 @@ type Joins []Join
 
 func (j Joins) node() {}
 
+// MatchRecognize is the clause to detect the event patterns of the source stream.
+// It outputs one row for each match which includes the partition fields and the measures.
+type MatchRecognize struct {
+	PartitionBy []Expr
+	Measures    Fields
+	Pattern     []*PatternTerm
+	// Within limits the time span from the first row to the last row of a match, no limit if nil
+	Within     *IntegerLiteral
+	WithinUnit *TimeLiteral
+	Defines    []*PatternDefine
+
+	Node
+}
+
+// PatternTerm is a pattern variable with its quantifier. Max is -1 if it is unbounded.
+type PatternTerm struct {
+	Var string
+	Min int
+	Max int
+}
+
+// PatternDefine is the condition of the rows which can be mapped to the pattern variable
+type PatternDefine struct {
+	Var       string
+	Condition Expr
+}
+
 type Dimension struct {
 	Expr Expr
 
@@ -82,6 +82,9 @@ const (
 	COLON     //:
 	SEMICOLON //;
 	COLSEP    //\007
+	LBRACE    //{
+	RBRACE    //}
+	QUESTION  //?
 
 	// Keywords
 	SELECT
@@ -163,6 +166,9 @@ var Tokens = []string{
 	SEMICOLON: ";",
 	COLON:     ":",
 	COLSEP:    "\007",
+	LBRACE:    "{",
+	RBRACE:    "}",
+	QUESTION:  "?",
 
 	SELECT:    "SELECT",
 	FROM:      "FROM",
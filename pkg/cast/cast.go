@@ -17,8 +17,10 @@ package cast
 import (
 	"encoding/base64"
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/mitchellh/mapstructure"
@@ -1067,6 +1069,47 @@ func MapToSyncMap(m map[string]interface{}) *sync.Map {
 	return sm
 }
 
+// ToKeyString encodes the values as a key to match the values of different sources. The numbers of the same value are
+// encoded the same regardless of the type, such as int 1, int64 1 and float64 1.0, while the values of different kinds
+// are distinguished, such as the number 1 and the string "1". The bytes are encoded as a string.
+func ToKeyString(values []interface{}) string {
+	var b strings.Builder
+	for i, v := range values {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		switch vt := v.(type) {
+		case nil:
+			b.WriteString("n")
+		case bool:
+			b.WriteString("b:" + strconv.FormatBool(vt))
+		case int, int8, int16, int32, int64:
+			b.WriteString("d:" + strconv.FormatInt(reflect.ValueOf(vt).Int(), 10))
+		case uint, uint8, uint16, uint32, uint64:
+			b.WriteString("d:" + strconv.FormatUint(reflect.ValueOf(vt).Uint(), 10))
+		case float32:
+			b.WriteString("d:" + formatKeyFloat(float64(vt)))
+		case float64:
+			b.WriteString("d:" + formatKeyFloat(vt))
+		case string:
+			b.WriteString("s:" + strconv.Quote(vt))
+		case []byte:
+			b.WriteString("s:" + strconv.Quote(string(vt)))
+		default:
+			b.WriteString(fmt.Sprintf("%T:%v", vt, vt))
+		}
+	}
+	return b.String()
+}
+
+// formatKeyFloat formats the integral float as an integer so that it matches the same integer
+func formatKeyFloat(f float64) string {
+	if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
 func isIntegral64(val float64) bool {
 	return val == float64(int(val))
 }
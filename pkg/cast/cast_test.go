@@ -912,3 +912,24 @@ func TestToByteA(t *testing.T) {
 		}
 	}
 }
+
+func TestToKeyString(t *testing.T) {
+	tests := []struct {
+		a, b  []interface{}
+		equal bool
+	}{
+		{a: []interface{}{1000000}, b: []interface{}{1e+06}, equal: true},
+		{a: []interface{}{int64(3), uint8(3)}, b: []interface{}{float32(3), 3.0}, equal: true},
+		{a: []interface{}{"a", nil}, b: []interface{}{[]byte("a"), nil}, equal: true},
+		{a: []interface{}{1.5}, b: []interface{}{1}, equal: false},
+		{a: []interface{}{1}, b: []interface{}{"1"}, equal: false},
+		{a: []interface{}{true}, b: []interface{}{"true"}, equal: false},
+		{a: []interface{}{"a,b"}, b: []interface{}{"a", "b"}, equal: false},
+		{a: []interface{}{nil}, b: []interface{}{"n"}, equal: false},
+	}
+	for i, tt := range tests {
+		if got := ToKeyString(tt.a) == ToKeyString(tt.b); got != tt.equal {
+			t.Errorf("%d: %s and %s equal %v", i, ToKeyString(tt.a), ToKeyString(tt.b), got)
+		}
+	}
+}
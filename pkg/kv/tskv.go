@@ -23,3 +23,9 @@ type Tskv interface {
 	Close() error
 	Drop() error
 }
+
+// TskvRange is implemented by the Tskv which can list the keys in a range. The users detect it by type assertion.
+type TskvRange interface {
+	// Keys returns the keys between start and end (both inclusive) in ascending order
+	Keys(start int64, end int64) (keys []int64, err error)
+}
@@ -20,17 +20,22 @@ const (
 	FormatProtobuf  = "protobuf"
 	FormatDelimited = "delimited"
 	FormatCustom    = "custom"
+	FormatAvro      = "avro"
+	FormatMsgpack   = "msgpack"
 
 	DefaultField = "self"
 	MetaKey      = "__meta"
 )
 
+// extFormats are the formats which are only supported in the build with schema
+var extFormats = map[string]bool{}
+
 func IsFormatSupported(format string) bool {
 	switch format {
-	case FormatBinary, FormatJson, FormatProtobuf, FormatCustom, FormatDelimited:
+	case FormatBinary, FormatJson, FormatProtobuf, FormatCustom, FormatDelimited, FormatMsgpack:
 		return true
 	default:
-		return false
+		return extFormats[format]
 	}
 }
 
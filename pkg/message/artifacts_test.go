@@ -22,7 +22,7 @@ import (
 
 func TestIsFormatSupported(t *testing.T) {
 	formats := []string{
-		FormatBinary, FormatJson, FormatProtobuf, FormatDelimited, FormatCustom,
+		FormatBinary, FormatJson, FormatProtobuf, FormatDelimited, FormatCustom, FormatMsgpack,
 	}
 	for _, format := range formats {
 		assert.True(t, IsFormatSupported(format))
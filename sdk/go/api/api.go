@@ -61,6 +61,24 @@ type Function interface {
 	IsAggregate() bool
 }
 
+// AggregateFunction is an optional interface for the aggregate function to calculate incrementally.
+// The rows are accumulated into an accumulator one by one instead of passing the whole group to Exec.
+// The accumulator is kept by eKuiper and passed in each call, so it must be serializable by encoding/json.
+// It is received as the decoded json value, e.g. a struct accumulator is received as map[string]interface{}.
+type AggregateFunction interface {
+	Function
+	// Init returns a new accumulator of an empty group
+	Init(ctx FunctionContext) (interface{}, error)
+	// Accumulate adds the arguments of a row into the accumulator and returns the updated accumulator
+	Accumulate(ctx FunctionContext, acc interface{}, args []interface{}) (interface{}, error)
+	// Retract removes the arguments of a row, which is accumulated before, from the accumulator
+	Retract(ctx FunctionContext, acc interface{}, args []interface{}) (interface{}, error)
+	// Merge merges the accumulators of two partial groups into one
+	Merge(ctx FunctionContext, acc1 interface{}, acc2 interface{}) (interface{}, error)
+	// Result returns the aggregation result of the accumulator
+	Result(ctx FunctionContext, acc interface{}) (interface{}, error)
+}
+
 type Sink interface {
 	// Should be sync function for normal case. The container will run it in go func
 	Open(ctx StreamContext) error
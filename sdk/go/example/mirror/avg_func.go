@@ -0,0 +1,138 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/sdk/go/api"
+)
+
+// incAvg calculates the average incrementally. The accumulator is [sum, count].
+type incAvg struct{}
+
+func (f *incAvg) Validate(args []interface{}) error {
+	if len(args) != 1 {
+		return fmt.Errorf("inc_avg function only supports 1 parameter but got %d", len(args))
+	}
+	return nil
+}
+
+// Exec is only called by the old eKuiper versions which do not support incremental aggregate functions
+func (f *incAvg) Exec(args []interface{}, ctx api.FunctionContext) (interface{}, bool) {
+	arg, ok := args[0].([]interface{})
+	if !ok {
+		return fmt.Errorf("arg is not a slice, got %v", args[0]), false
+	}
+	acc, _ := f.Init(ctx)
+	var err error
+	for _, v := range arg {
+		acc, err = f.Accumulate(ctx, acc, []interface{}{v})
+		if err != nil {
+			return err, false
+		}
+	}
+	r, err := f.Result(ctx, acc)
+	if err != nil {
+		return err, false
+	}
+	return r, true
+}
+
+func (f *incAvg) IsAggregate() bool {
+	return true
+}
+
+func (f *incAvg) Init(_ api.FunctionContext) (interface{}, error) {
+	return []interface{}{0.0, 0.0}, nil
+}
+
+func (f *incAvg) Accumulate(_ api.FunctionContext, acc interface{}, args []interface{}) (interface{}, error) {
+	return f.add(acc, args[0], 1)
+}
+
+func (f *incAvg) Retract(_ api.FunctionContext, acc interface{}, args []interface{}) (interface{}, error) {
+	return f.add(acc, args[0], -1)
+}
+
+func (f *incAvg) Merge(_ api.FunctionContext, acc1 interface{}, acc2 interface{}) (interface{}, error) {
+	sum1, count1, err := parseAcc(acc1)
+	if err != nil {
+		return nil, err
+	}
+	sum2, count2, err := parseAcc(acc2)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{sum1 + sum2, count1 + count2}, nil
+}
+
+func (f *incAvg) Result(_ api.FunctionContext, acc interface{}) (interface{}, error) {
+	sum, count, err := parseAcc(acc)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	return sum / count, nil
+}
+
+func (f *incAvg) add(acc interface{}, arg interface{}, sign float64) (interface{}, error) {
+	// Ignore the nil values like the built-in avg function
+	if arg == nil {
+		return acc, nil
+	}
+	sum, count, err := parseAcc(acc)
+	if err != nil {
+		return nil, err
+	}
+	v, err := toFloat(arg)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{sum + sign*v, count + sign}, nil
+}
+
+func parseAcc(acc interface{}) (float64, float64, error) {
+	a, ok := acc.([]interface{})
+	if !ok || len(a) != 2 {
+		return 0, 0, fmt.Errorf("invalid accumulator %v", acc)
+	}
+	sum, err := toFloat(a[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	count, err := toFloat(a[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	return sum, count, nil
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case float32:
+		return float64(n), nil
+	case int:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	default:
+		return 0, fmt.Errorf("inc_avg only supports numeric values but got %v", v)
+	}
+}
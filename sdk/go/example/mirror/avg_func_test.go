@@ -0,0 +1,48 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/lf-edge/ekuiper/sdk/go/mock"
+)
+
+func TestIncAvg(t *testing.T) {
+	tests := []mock.AggFuncTest{
+		{
+			Rows:   [][]interface{}{{1}, {2.5}, {nil}, {6}},
+			Result: 3.1666666666666665,
+		}, {
+			Rows:     [][]interface{}{{1}, {2}, {6}},
+			Retracts: [][]interface{}{{6}},
+			Result:   1.5,
+		}, {
+			Result: nil,
+		},
+	}
+	mock.TestAggFuncIncremental(&incAvg{}, tests, t)
+}
+
+func TestIncAvgExec(t *testing.T) {
+	tests := []mock.FuncTest{
+		{
+			Args:   []interface{}{[]interface{}{1, 2, 6}},
+			Result: float64(3),
+			Ok:     true,
+		},
+	}
+	mock.TestFuncExec(&incAvg{}, tests, t)
+}
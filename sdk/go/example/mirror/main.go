@@ -33,6 +33,9 @@ func main() {
 			"echo": func() api.Function {
 				return &echo{}
 			},
+			"inc_avg": func() api.Function {
+				return &incAvg{}
+			},
 		},
 		Sinks: map[string]sdk.NewSinkFunc{
 			"file": func() api.Sink {
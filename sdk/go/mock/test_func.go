@@ -15,6 +15,7 @@
 package mock
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
 
@@ -38,3 +39,58 @@ func TestFuncExec(f api.Function, tests []FuncTest, t *testing.T) {
 		}
 	}
 }
+
+type AggFuncTest struct {
+	// Args of each row to accumulate
+	Rows [][]interface{}
+	// Args of each row to retract after all rows are accumulated
+	Retracts [][]interface{}
+	Result   interface{}
+}
+
+// TestAggFuncIncremental accumulates the rows of each test one by one and checks the result.
+// The accumulator is encoded and decoded by json between calls like eKuiper does.
+func TestAggFuncIncremental(f api.AggregateFunction, tests []AggFuncTest, t *testing.T) {
+	ctx := newMockFuncContext(newMockContext("rule1", "op1"), 1)
+	for i, tt := range tests {
+		acc, err := f.Init(ctx)
+		if err != nil {
+			t.Errorf("%d init error: %v", i, err)
+			continue
+		}
+		for _, row := range tt.Rows {
+			acc, err = f.Accumulate(ctx, jsonRoundTrip(acc, t), row)
+			if err != nil {
+				break
+			}
+		}
+		for _, row := range tt.Retracts {
+			if err != nil {
+				break
+			}
+			acc, err = f.Retract(ctx, jsonRoundTrip(acc, t), row)
+		}
+		if err != nil {
+			t.Errorf("%d accumulate error: %v", i, err)
+			continue
+		}
+		r, err := f.Result(ctx, jsonRoundTrip(acc, t))
+		if err != nil {
+			t.Errorf("%d result error: %v", i, err)
+		} else if !reflect.DeepEqual(tt.Result, r) {
+			t.Errorf("%d result mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", i, tt.Result, r)
+		}
+	}
+}
+
+func jsonRoundTrip(acc interface{}, t *testing.T) interface{} {
+	b, err := json.Marshal(acc)
+	if err != nil {
+		t.Fatalf("accumulator %v cannot be encoded: %v", acc, err)
+	}
+	var r interface{}
+	if err := json.Unmarshal(b, &r); err != nil {
+		t.Fatalf("accumulator %s cannot be decoded: %v", b, err)
+	}
+	return r
+}
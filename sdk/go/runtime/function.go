@@ -86,6 +86,23 @@ func (s *funcRuntime) run() {
 		case "IsAggregate":
 			result := s.s.IsAggregate()
 			return encodeReply(true, result)
+		case "IsIncremental":
+			_, ok := s.s.(api.AggregateFunction)
+			return encodeReply(true, ok && s.s.IsAggregate())
+		case "Init", "Accumulate", "AccumulateBatch", "Retract", "Merge", "Result":
+			af, ok := s.s.(api.AggregateFunction)
+			if !ok {
+				return encodeReply(false, fmt.Sprintf("function is not incremental, cannot run %s", d.Func))
+			}
+			arg, ok := d.Arg.([]interface{})
+			if !ok {
+				return encodeReply(false, "argument is not interface array")
+			}
+			r, err := execAggFunc(af, d.Func, arg)
+			if err != nil {
+				return encodeReply(false, err.Error())
+			}
+			return encodeReply(true, r)
 		default:
 			return encodeReply(false, fmt.Sprintf("invalid func %s", d.Func))
 		}
@@ -117,6 +134,61 @@ func encodeReply(state bool, arg interface{}) []byte {
 	return r
 }
 
+// execAggFunc runs the incremental aggregate method. The last argument is the function context.
+// The arguments before it are [] for Init, [acc, args] for Accumulate and Retract, [acc, rows] for AccumulateBatch,
+// [acc1, acc2] for Merge and [acc] for Result.
+func execAggFunc(af api.AggregateFunction, name string, arg []interface{}) (interface{}, error) {
+	farg, fctx, err := parseFuncContextArgs(arg)
+	if err != nil {
+		return nil, err
+	}
+	switch name {
+	case "Init":
+		return af.Init(fctx)
+	case "Accumulate", "Retract":
+		if len(farg) != 2 {
+			return nil, fmt.Errorf("%s requires the accumulator and the arguments but got %v", name, farg)
+		}
+		args, ok := farg[1].([]interface{})
+		if !ok && farg[1] != nil {
+			return nil, fmt.Errorf("%s arguments %v is not interface array", name, farg[1])
+		}
+		if name == "Accumulate" {
+			return af.Accumulate(fctx, farg[0], args)
+		}
+		return af.Retract(fctx, farg[0], args)
+	case "AccumulateBatch":
+		if len(farg) != 2 {
+			return nil, fmt.Errorf("AccumulateBatch requires the accumulator and the rows but got %v", farg)
+		}
+		rows, ok := farg[1].([]interface{})
+		if !ok && farg[1] != nil {
+			return nil, fmt.Errorf("AccumulateBatch rows %v is not interface array", farg[1])
+		}
+		acc := farg[0]
+		for _, row := range rows {
+			args, ok := row.([]interface{})
+			if !ok && row != nil {
+				return nil, fmt.Errorf("AccumulateBatch arguments %v is not interface array", row)
+			}
+			if acc, err = af.Accumulate(fctx, acc, args); err != nil {
+				return nil, err
+			}
+		}
+		return acc, nil
+	case "Merge":
+		if len(farg) != 2 {
+			return nil, fmt.Errorf("Merge requires two accumulators but got %v", farg)
+		}
+		return af.Merge(fctx, farg[0], farg[1])
+	default: // Result
+		if len(farg) != 1 {
+			return nil, fmt.Errorf("Result requires the accumulator but got %v", farg)
+		}
+		return af.Result(fctx, farg[0])
+	}
+}
+
 func parseFuncContextArgs(args []interface{}) ([]interface{}, api.FunctionContext, error) {
 	if len(args) < 1 {
 		return nil, nil, fmt.Errorf("exec function context not found")
@@ -35,3 +35,36 @@ class Function(object):
     def is_aggregate(self):
         """callback to check if function is for aggregation, return bool"""
         pass
+
+
+class AggregateFunction(Function):
+    """abstract class for eKuiper aggregate function plugin which is calculated incrementally.
+    The accumulator is kept by eKuiper and passed in each call, so it must be json serializable."""
+
+    def is_aggregate(self):
+        return True
+
+    @abstractmethod
+    def init(self, ctx: Context) -> Any:
+        """callback to create the accumulator of an empty group"""
+        pass
+
+    @abstractmethod
+    def accumulate(self, ctx: Context, acc: Any, args: List[Any]) -> Any:
+        """callback to add the args of a row into the accumulator, return the updated accumulator"""
+        pass
+
+    @abstractmethod
+    def retract(self, ctx: Context, acc: Any, args: List[Any]) -> Any:
+        """callback to remove the args of an accumulated row, return the updated accumulator"""
+        pass
+
+    @abstractmethod
+    def merge(self, ctx: Context, acc1: Any, acc2: Any) -> Any:
+        """callback to merge two accumulators, return the merged accumulator"""
+        pass
+
+    @abstractmethod
+    def result(self, ctx: Context, acc: Any) -> Any:
+        """callback to get the aggregation result from the accumulator"""
+        pass
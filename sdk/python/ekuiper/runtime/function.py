@@ -20,7 +20,7 @@ from . import reg
 from .connection import PairChannel
 from .contextimpl import ContextImpl
 from .symbol import SymbolRuntime
-from ..function import Function
+from ..function import Function, AggregateFunction
 
 
 class FunctionRuntime(SymbolRuntime):
@@ -61,25 +61,31 @@ class FunctionRuntime(SymbolRuntime):
                 args = c['arg']
                 if isinstance(args, list) is False or len(args) < 1:
                     return encode_reply(False, 'invalid arg')
-                fmeta = json.loads(args[-1])
-                if 'ruleId' in fmeta and 'opId' in fmeta and 'instanceId' in fmeta \
-                        and 'funcId' in fmeta:
-                    key = f"{fmeta['ruleId']}_{fmeta['opId']}_{fmeta['instanceId']}" \
-                          f"_{fmeta['funcId']}"
-                    if key in self.funcs:
-                        fctx = self.funcs[key]
-                    else:
-                        fctx = ContextImpl(fmeta)
-                        self.funcs[key] = fctx
-                else:
+                fctx = self.get_context(args[-1])
+                if fctx is None:
                     return encode_reply(False,
-                                        f'invalid arg: {fmeta} ruleId, opId, instanceId and funcId'
+                                        f'invalid arg: {args[-1]} ruleId, opId, instanceId and funcId'
                                         f' are required')
                 r = self.s.exec(args[:-1], fctx)
                 return encode_reply(True, r)
             elif name == "IsAggregate":
                 r = self.s.is_aggregate()
                 return encode_reply(True, r)
+            elif name == "IsIncremental":
+                r = isinstance(self.s, AggregateFunction) and self.s.is_aggregate()
+                return encode_reply(True, r)
+            elif name in ("Init", "Accumulate", "AccumulateBatch", "Retract", "Merge", "Result"):
+                if not isinstance(self.s, AggregateFunction):
+                    return encode_reply(False, f'function is not incremental, cannot run {name}')
+                args = c['arg']
+                if isinstance(args, list) is False or len(args) < 1:
+                    return encode_reply(False, 'invalid arg')
+                fctx = self.get_context(args[-1])
+                if fctx is None:
+                    return encode_reply(False,
+                                        f'invalid arg: {args[-1]} ruleId, opId, instanceId and funcId'
+                                        f' are required')
+                return encode_reply(True, self.exec_agg(name, args[:-1], fctx))
             else:
                 return encode_reply(False, "invalid func {}".format(name))
         except Exception:
@@ -89,6 +95,36 @@ class FunctionRuntime(SymbolRuntime):
                 logging.error(traceback.format_exc())
                 return encode_reply(False, traceback.format_exc())
 
+    def get_context(self, raw: str):
+        fmeta = json.loads(raw)
+        if 'ruleId' in fmeta and 'opId' in fmeta and 'instanceId' in fmeta \
+                and 'funcId' in fmeta:
+            key = f"{fmeta['ruleId']}_{fmeta['opId']}_{fmeta['instanceId']}" \
+                  f"_{fmeta['funcId']}"
+            if key not in self.funcs:
+                self.funcs[key] = ContextImpl(fmeta)
+            return self.funcs[key]
+        return None
+
+    def exec_agg(self, name: str, args: list, fctx: ContextImpl):
+        """args are [] for Init, [acc, args] for Accumulate and Retract, [acc, rows] for
+        AccumulateBatch, [acc1, acc2] for Merge and [acc] for Result"""
+        if name == "Init":
+            return self.s.init(fctx)
+        elif name == "Accumulate":
+            return self.s.accumulate(fctx, args[0], args[1])
+        elif name == "AccumulateBatch":
+            acc = args[0]
+            for row in args[1]:
+                acc = self.s.accumulate(fctx, acc, row)
+            return acc
+        elif name == "Retract":
+            return self.s.retract(fctx, args[0], args[1])
+        elif name == "Merge":
+            return self.s.merge(fctx, args[0], args[1])
+        else:
+            return self.s.result(fctx, args[0])
+
     def stop(self):
         self.running = False
         # noinspection PyBroadException